@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/user/goeth/internal/batch"
+	"github.com/user/goeth/internal/config"
+)
+
+func newBatchCmd(loader config.Loader, sys *system) *cobra.Command {
+	var path string
+	var keepGoing bool
+	cmd := &cobra.Command{
+		Use:   "batch",
+		Short: "Run goeth commands read from a file, one per line",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var in io.Reader = cmd.InOrStdin()
+			if path != "-" {
+				file, err := os.Open(path)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				in = file
+			}
+			commands, err := batch.Read(in)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			global := globalArgs(cmd.Root())
+			failed := 0
+			for _, command := range commands {
+				err := runBatchCommand(cmd, loader, sys, append(global, command.Args...))
+				if err == nil {
+					continue
+				}
+				err = fmt.Errorf("%s:%d: %w", path, command.Line, err)
+				if !keepGoing {
+					return err
+				}
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				failed++
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d commands failed", failed, len(commands))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&path, "file", "f", "", "File with one goeth command per line, or - for stdin")
+	cmd.Flags().BoolVar(&keepGoing, "continue-on-error", false, "Run the remaining commands after one fails")
+	cmd.MarkFlagRequired("file")
+	return cmd
+}
+
+// runBatchCommand runs one line of a batch file as a goeth command line.
+// Every command shares sys, so they all work over the same netlink handle
+// and namespace.
+func runBatchCommand(cmd *cobra.Command, loader config.Loader, sys *system, args []string) error {
+	line := newRootCommand(sys, loader)
+	found, _, err := line.Find(args)
+	if err == nil && found.Name() == cmd.Name() {
+		return errors.New("batch cannot run batch")
+	}
+	line.SetArgs(args)
+	line.SetIn(cmd.InOrStdin())
+	line.SetOut(cmd.OutOrStdout())
+	line.SetErr(cmd.ErrOrStderr())
+	line.SilenceErrors = true
+	line.SilenceUsage = true
+	return line.ExecuteContext(cmd.Context())
+}
+
+// globalArgs repeats the global flags given to goeth batch, such as --lang,
+// for each command it runs.
+func globalArgs(root *cobra.Command) []string {
+	var args []string
+	// Flags are parsed into the flag set of the subcommand, so only Changed
+	// tells which of the shared global flags were given.
+	root.PersistentFlags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Changed {
+			args = append(args, fmt.Sprintf("--%s=%s", flag.Name, flag.Value))
+		}
+	})
+	return args
+}
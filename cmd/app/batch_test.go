@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/user/goeth/internal/config"
+)
+
+func TestGlobalArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "none", args: []string{"batch", "-f", "-"}},
+		{name: "language", args: []string{"--lang", "ja", "batch", "-f", "-"}, want: []string{"--lang=ja"}},
+		{name: "after the command", args: []string{"batch", "-f", "-", "--no-color", "--lang=ja"}, want: []string{"--lang=ja", "--no-color=true"}},
+		{name: "only the global flags", args: []string{"batch", "-f", "-", "--continue-on-error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sys := localSystem()
+			root := newRootCommand(&sys, config.NewLoader())
+			cmd, rest, err := root.Find(tt.args)
+			if err != nil {
+				t.Fatalf("Find() error = %v", err)
+			}
+			if err := cmd.ParseFlags(rest); err != nil {
+				t.Fatalf("ParseFlags() error = %v", err)
+			}
+			if got := globalArgs(root); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("globalArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunBatchCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantOut string
+		wantErr string
+		// lang is the language the shared system is left in.
+		lang string
+	}{
+		{name: "runs the command", args: []string{"version", "--output", "json"}, wantOut: `"backends": [`, lang: "en"},
+		{name: "shares the system", args: []string{"--lang=ja", "version"}, wantOut: "goeth ", lang: "ja"},
+		{name: "batch is refused", args: []string{"batch", "-f", "-"}, wantErr: "batch cannot run batch"},
+		{name: "unknown command", args: []string{"frobnicate"}, wantErr: `unknown command "frobnicate"`},
+		{name: "failing command", args: []string{"version", "--output", "yaml"}, wantErr: `unknown output format "yaml"`, lang: "en"},
+	}
+	// The language the commands print in is otherwise taken from the locale.
+	t.Setenv("LC_ALL", "C")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sys := localSystem()
+			loader := config.NewLoader()
+			batchCmd := newBatchCmd(loader, &sys)
+			var out, errOut bytes.Buffer
+			batchCmd.SetIn(strings.NewReader(""))
+			batchCmd.SetOut(&out)
+			batchCmd.SetErr(&errOut)
+			batchCmd.SetContext(context.Background())
+
+			err := runBatchCommand(batchCmd, loader, &sys, tt.args)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("runBatchCommand() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("runBatchCommand() error = %v, want %q", err, tt.wantErr)
+			}
+			if !strings.Contains(out.String(), tt.wantOut) {
+				t.Errorf("output = %q, want %q", out.String(), tt.wantOut)
+			}
+			if tt.lang != "" && sys.messages.Lang() != tt.lang {
+				t.Errorf("language = %s, want %s", sys.messages.Lang(), tt.lang)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/user/goeth/internal/config"
+	"github.com/user/goeth/internal/doctor"
+)
+
+func newDoctorCmd(loader config.Loader, sys *system) *cobra.Command {
+	var path string
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the host for common network misconfigurations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d := doctor.Doctor{Messages: sys.messages}
+			if path != "" {
+				cfg, err := loader.Load(path)
+				if err != nil {
+					return err
+				}
+				if err := sys.enter(cfg.Netns); err != nil {
+					return err
+				}
+				d.Config = &cfg
+			}
+			host, ok := sys.provider.(doctor.Host)
+			if !ok {
+				return errors.New("provider cannot inspect the host")
+			}
+			d.Host = host
+			findings, err := d.Run()
+			out := cmd.OutOrStdout()
+			for _, finding := range findings {
+				sys.messages.Fprintf(out, "[%s] %s\n", finding.Check, finding.Problem)
+				sys.messages.Fprintf(out, "    fix: %s\n", finding.Fix)
+			}
+			switch {
+			case len(findings) == 1:
+				sys.messages.Fprintf(out, "Found 1 problem\n")
+			case len(findings) > 1:
+				sys.messages.Fprintf(out, "Found %d problems\n", len(findings))
+			case err == nil:
+				sys.messages.Fprintf(out, "No problems found\n")
+			}
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&path, "file", "f", "", "Also check a JSON configuration against the host")
+	return cmd
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/user/goeth/internal/history"
+	"github.com/user/goeth/pkg/netevent"
+)
+
+func newEventsCmd() *cobra.Command {
+	var file, iface, output string
+	var since time.Duration
+	cmd := &cobra.Command{
+		Use:   "events",
+		Short: "Show the recent changes recorded by 'goeth monitor --history'",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "text" && output != "json" {
+				return fmt.Errorf("--output must be text or json, got %q", output)
+			}
+			if _, err := path.Match(iface, ""); err != nil {
+				return fmt.Errorf("invalid interface pattern %q", iface)
+			}
+			events, err := history.Load(file)
+			if err != nil {
+				return err
+			}
+			if since > 0 {
+				events = history.Since(events, time.Now().Add(-since))
+			}
+			var selected []netevent.Event
+			for _, event := range events {
+				if ok, _ := path.Match(iface, event.Interface); iface == "" || ok {
+					selected = append(selected, event)
+				}
+			}
+			if output == "json" {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				for _, event := range selected {
+					if err := encoder.Encode(event); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			for _, event := range selected {
+				fmt.Fprintf(cmd.OutOrStdout(), "[%s] %s\n", event.Time.Format(time.RFC3339), event.Message)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", defaultHistoryPath, "History file written by 'goeth monitor --history'")
+	cmd.Flags().DurationVar(&since, "since", 0, "Show only the changes of this last period (e.g. 1h); all by default")
+	cmd.Flags().StringVarP(&iface, "interface", "i", "", "Show only the changes of this interface, or of those matching a shell pattern")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format: text, or json for one event document per line")
+	return cmd
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/user/goeth/internal/counters"
+)
+
+// influxTokenEnv carries the InfluxDB 2.x API token, keeping it out of the
+// process list.
+const influxTokenEnv = "GOETH_INFLUX_TOKEN"
+
+func newExportCmd(sys *system) *cobra.Command {
+	var influxURL, graphiteAddr, prefix string
+	var interval time.Duration
+	var once bool
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Push interface counters to InfluxDB or Graphite",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			host, _ := os.Hostname()
+			var exporters []counters.Exporter
+			if influxURL != "" {
+				exporters = append(exporters, counters.Influx{URL: influxURL, Token: os.Getenv(influxTokenEnv), Host: host})
+			}
+			if graphiteAddr != "" {
+				if !cmd.Flags().Changed("prefix") {
+					prefix = "goeth." + counters.GraphiteNode(host)
+				}
+				exporters = append(exporters, counters.Graphite{Address: graphiteAddr, Prefix: prefix})
+			}
+			if len(exporters) == 0 {
+				return errors.New("choose at least one of --influx and --graphite")
+			}
+			pusher := counters.Pusher{
+				Source:    sys.provider,
+				Exporters: exporters,
+				Interval:  interval,
+				Errors:    cmd.ErrOrStderr(),
+			}
+			if once {
+				return pusher.Push()
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return monitorError(pusher.Run(ctx), nil)
+		},
+	}
+	cmd.Flags().StringVar(&influxURL, "influx", "", "InfluxDB write URL, e.g. http://db:8086/write?db=net (token from "+influxTokenEnv+")")
+	cmd.Flags().StringVar(&graphiteAddr, "graphite", "", "Graphite plaintext listener as host:port, e.g. carbon:2003")
+	cmd.Flags().StringVar(&prefix, "prefix", "", "Graphite metric path prefix (default goeth.<hostname>)")
+	cmd.Flags().DurationVarP(&interval, "interval", "t", 10*time.Second, "Push interval")
+	cmd.Flags().BoolVar(&once, "once", false, "Push a single round of samples and exit")
+	return cmd
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/user/goeth/internal/snapshot"
+	"github.com/user/goeth/internal/topology"
+)
+
+// mermaidExtensions select Mermaid output when --format is not given.
+var mermaidExtensions = map[string]bool{".mmd": true, ".mermaid": true}
+
+func newGraphCmd(sys *system) *cobra.Command {
+	var output, format, statePath string
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Draw the interface topology as a Graphviz or Mermaid diagram",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var state snapshot.State
+			var err error
+			if statePath != "" {
+				state, err = snapshot.Load(statePath)
+			} else {
+				state, err = snapshot.Capture(sys.provider)
+			}
+			if err != nil {
+				return err
+			}
+			if format == "" {
+				format = topology.DOT
+				if mermaidExtensions[filepath.Ext(output)] {
+					format = topology.Mermaid
+				}
+			}
+			if output == "" {
+				return topology.Render(cmd.OutOrStdout(), state, format)
+			}
+			file, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			if err := topology.Render(file, state, format); err != nil {
+				file.Close()
+				return err
+			}
+			return file.Close()
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write the diagram to this file instead of stdout")
+	cmd.Flags().StringVar(&format, "format", "", "Diagram format, dot or mermaid (default from the --output extension, else dot)")
+	cmd.Flags().StringVar(&statePath, "state", "", "Draw a state file captured with 'goeth snapshot' instead of the live system")
+	return cmd
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/user/goeth/internal/config"
+)
+
+func newLinkCmd(sys *system) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "link",
+		Short: "Create and change individual links",
+	}
+	cmd.AddCommand(newLinkAddCmd(sys))
+	cmd.AddCommand(newLinkAddVethCmd(sys))
+	cmd.AddCommand(newLinkRenameCmd(sys))
+	cmd.AddCommand(newLinkUpCmd(sys))
+	cmd.AddCommand(newLinkDownCmd(sys))
+	cmd.AddCommand(newLinkSetMTUCmd(sys))
+	cmd.AddCommand(newLinkSetMACCmd(sys))
+	cmd.AddCommand(newLinkSetXDPCmd(sys))
+	return cmd
+}
+
+// defaultCarrierTimeout bounds --wait-carrier; autonegotiation usually
+// finishes within a few seconds.
+const defaultCarrierTimeout = 10 * time.Second
+
+func newLinkAddCmd(sys *system) *cobra.Command {
+	var name, kind, owner, group string
+	var multiQueue bool
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "Create a link that needs no further settings",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Configuration{Interface: name}
+			switch kind {
+			case "dummy":
+				cfg.Dummy = &config.Dummy{}
+			case "bridge":
+				cfg.Bridge = &config.Bridge{}
+			case "tun", "tap":
+				cfg.Tuntap = &config.Tuntap{Mode: kind, Owner: owner, Group: group, MultiQueue: multiQueue}
+			default:
+				return fmt.Errorf("unsupported link type %q (want dummy, bridge, tun or tap)", kind)
+			}
+			if err := config.NewApplier(sys.executor).Apply(cfg); err != nil {
+				return err
+			}
+			sys.messages.Fprintf(cmd.OutOrStdout(), "%s %s is present\n", kind, name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "Name of the new link")
+	cmd.Flags().StringVar(&kind, "type", "dummy", "Link type: dummy, bridge, tun or tap")
+	cmd.Flags().StringVar(&owner, "owner", "", "User allowed to attach to a tun/tap device")
+	cmd.Flags().StringVar(&group, "group", "", "Group allowed to attach to a tun/tap device")
+	cmd.Flags().BoolVar(&multiQueue, "multi-queue", false, "Create a multi-queue tun/tap device")
+	cmd.MarkFlagRequired("name")
+	return cmd
+}
+
+func newLinkAddVethCmd(sys *system) *cobra.Command {
+	var name, peer, peerNetns string
+	cmd := &cobra.Command{
+		Use:   "add-veth",
+		Short: "Create a veth pair",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Configuration{
+				Interface: name,
+				Veth:      &config.Veth{Peer: peer, PeerNetns: peerNetns},
+			}
+			if err := config.NewApplier(sys.executor).Apply(cfg); err != nil {
+				return err
+			}
+			sys.messages.Fprintf(cmd.OutOrStdout(), "veth pair %s <-> %s is present\n", name, peer)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "Name of the veth end in the current namespace")
+	cmd.Flags().StringVar(&peer, "peer", "", "Name of the peer end")
+	cmd.Flags().StringVar(&peerNetns, "peer-netns", "", "Move the peer into this named network namespace")
+	cmd.MarkFlagRequired("name")
+	cmd.MarkFlagRequired("peer")
+	return cmd
+}
+
+func newLinkRenameCmd(sys *system) *cobra.Command {
+	var from, to string
+	cmd := &cobra.Command{
+		Use:   "rename",
+		Short: "Rename a link, taking it down around the rename when it is up",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Configuration{
+				Interface: to,
+				Links:     []config.LinkRule{{RenameFrom: from, Name: to}},
+			}
+			if err := config.NewApplier(sys.executor).Apply(cfg); err != nil {
+				return err
+			}
+			sys.messages.Fprintf(cmd.OutOrStdout(), "%s is named %s\n", from, to)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "Current name of the link")
+	cmd.Flags().StringVar(&to, "to", "", "New name of the link")
+	cmd.MarkFlagRequired("from")
+	cmd.MarkFlagRequired("to")
+	return cmd
+}
+
+func newLinkUpCmd(sys *system) *cobra.Command {
+	var waitCarrier bool
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "up <iface>",
+		Short: "Bring a link up",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Configuration{Interface: args[0], State: "up"}
+			if waitCarrier {
+				cfg.WaitCarrier = config.Duration(timeout)
+			}
+			if err := config.NewApplier(sys.executor).Apply(cfg); err != nil {
+				return err
+			}
+			if waitCarrier {
+				sys.messages.Fprintf(cmd.OutOrStdout(), "%s is up with carrier\n", args[0])
+				return nil
+			}
+			sys.messages.Fprintf(cmd.OutOrStdout(), "%s is up\n", args[0])
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&waitCarrier, "wait-carrier", false, "Block until the link detects a carrier")
+	cmd.Flags().DurationVar(&timeout, "timeout", defaultCarrierTimeout, "How long --wait-carrier waits")
+	return cmd
+}
+
+func newLinkDownCmd(sys *system) *cobra.Command {
+	return &cobra.Command{
+		Use:   "down <iface>",
+		Short: "Bring a link down",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Configuration{Interface: args[0], State: "down"}
+			if err := config.NewApplier(sys.executor).Apply(cfg); err != nil {
+				return err
+			}
+			sys.messages.Fprintf(cmd.OutOrStdout(), "%s is down\n", args[0])
+			return nil
+		},
+	}
+}
+
+func newLinkSetMTUCmd(sys *system) *cobra.Command {
+	var name string
+	var mtu int
+	cmd := &cobra.Command{
+		Use:   "set-mtu",
+		Short: "Change the MTU of a link",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, err := sys.lister.Resolve(name)
+			if err != nil {
+				return err
+			}
+			cfg := config.Configuration{Interface: name, MTU: mtu}
+			if err := config.NewApplier(sys.executor).Apply(cfg); err != nil {
+				return err
+			}
+			sys.messages.Fprintf(cmd.OutOrStdout(), "MTU of %s is %d\n", name, mtu)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&name, "interface", "i", "", "Interface name or index")
+	cmd.Flags().IntVar(&mtu, "mtu", 0, "New MTU")
+	cmd.MarkFlagRequired("interface")
+	cmd.MarkFlagRequired("mtu")
+	return cmd
+}
+
+func newLinkSetMACCmd(sys *system) *cobra.Command {
+	var name, mac string
+	var permanent bool
+	cmd := &cobra.Command{
+		Use:   "set-mac",
+		Short: "Change the MAC address of a link or restore its permanent one",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, err := sys.lister.Resolve(name)
+			if err != nil {
+				return err
+			}
+			if permanent {
+				mac = "permanent"
+			}
+			cfg := config.Configuration{Interface: name, MAC: mac}
+			if err := config.NewApplier(sys.executor).Apply(cfg); err != nil {
+				return err
+			}
+			if permanent {
+				sys.messages.Fprintf(cmd.OutOrStdout(), "%s has its permanent MAC address\n", name)
+				return nil
+			}
+			sys.messages.Fprintf(cmd.OutOrStdout(), "MAC of %s is %s\n", name, mac)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&name, "interface", "i", "", "Interface name or index")
+	cmd.Flags().StringVar(&mac, "mac", "", "New locally administered MAC address, e.g. 02:00:00:aa:bb:cc")
+	cmd.Flags().BoolVar(&permanent, "permanent", false, "Restore the MAC address the device was made with")
+	cmd.MarkFlagRequired("interface")
+	cmd.MarkFlagsOneRequired("mac", "permanent")
+	cmd.MarkFlagsMutuallyExclusive("mac", "permanent")
+	return cmd
+}
+
+func newLinkSetXDPCmd(sys *system) *cobra.Command {
+	var name, pinned, mode string
+	var detach bool
+	cmd := &cobra.Command{
+		Use:   "set-xdp",
+		Short: "Attach a pinned XDP program to a link or detach its program",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, err := sys.lister.Resolve(name)
+			if err != nil {
+				return err
+			}
+			xdp := &config.XDP{Pinned: pinned, Mode: mode}
+			if detach {
+				xdp = &config.XDP{}
+			}
+			cfg := config.Configuration{Interface: name, XDP: xdp}
+			if err := config.NewApplier(sys.executor).Apply(cfg); err != nil {
+				return err
+			}
+			if detach {
+				sys.messages.Fprintf(cmd.OutOrStdout(), "%s has no XDP program\n", name)
+				return nil
+			}
+			sys.messages.Fprintf(cmd.OutOrStdout(), "%s runs the XDP program %s\n", name, pinned)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&name, "interface", "i", "", "Interface name or index")
+	cmd.Flags().StringVar(&pinned, "pinned", "", "bpffs path of the program, e.g. /sys/fs/bpf/ddos")
+	cmd.Flags().StringVar(&mode, "mode", "native", "Attach mode: native or skb")
+	cmd.Flags().BoolVar(&detach, "detach", false, "Detach the attached program")
+	cmd.MarkFlagRequired("interface")
+	cmd.MarkFlagsOneRequired("pinned", "detach")
+	cmd.MarkFlagsMutuallyExclusive("pinned", "detach")
+	return cmd
+}
@@ -1,105 +1,454 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
 
 	"github.com/user/goeth/internal/addresses"
+	"github.com/user/goeth/internal/announce"
+	"github.com/user/goeth/internal/buildinfo"
+	"github.com/user/goeth/internal/cache"
 	"github.com/user/goeth/internal/config"
+	"github.com/user/goeth/internal/counters"
+	"github.com/user/goeth/internal/ethtool"
+	"github.com/user/goeth/internal/i18n"
 	"github.com/user/goeth/internal/interfaces"
+	"github.com/user/goeth/internal/logfile"
 	"github.com/user/goeth/internal/monitor"
+	"github.com/user/goeth/internal/neighbors"
+	"github.com/user/goeth/internal/output"
+	"github.com/user/goeth/internal/pmtu"
+	"github.com/user/goeth/internal/query"
+	"github.com/user/goeth/internal/routes"
+	"github.com/user/goeth/internal/snapshot"
+	"github.com/user/goeth/internal/snmp"
+	"github.com/user/goeth/pkg/netevent"
 )
 
-func main() {
-	lister := interfaces.NewLister(interfaces.NetProvider{})
-	viewer := addresses.NewViewer(addresses.NetProvider{})
-	loader := config.NewLoader()
-	executor := config.NewNetlinkExecutor(config.NetlinkAPI{})
+// Build metadata injected with -ldflags "-X main.Version=... -X main.Commit=... -X main.BuildDate=...".
+var (
+	Version   string
+	Commit    string
+	BuildDate string
+)
+
+// backends lists the kernel interfaces the system integrations compiled into
+// this binary work through.
+var backends = []string{
+	// links, addresses, routes, neighbors and rules, and WireGuard over
+	// generic netlink
+	"netlink",
+	// driver information, offloads, rings and link settings
+	"ethtool",
+	// sysctls under /proc/sys and device attributes under /sys
+	"sysfs",
+	// XDP programs pinned in bpffs
+	"bpf",
+	// packet sockets for announcements, ARP probes and DHCP
+	"packet",
+	// signals of monitor --dbus
+	"dbus",
+}
 
-	root := newRootCommand(lister, viewer, loader, executor)
+func main() {
+	sys := localSystem()
+	root := newRootCommand(&sys, config.NewLoader())
 	if err := root.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(root.ErrOrStderr(), err)
 		os.Exit(1)
 	}
 }
 
-func newRootCommand(lister interfaces.Lister, viewer addresses.Viewer, loader config.Loader, executor config.Executor) *cobra.Command {
+func newRootCommand(sys *system, loader config.Loader) *cobra.Command {
+	var plain, noColor bool
+	var netnsName, lang string
 	cmd := &cobra.Command{
 		Use:   "goeth",
 		Short: "Manage network interfaces and configuration",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			messages, err := selectMessages(lang, plain)
+			if err != nil {
+				return err
+			}
+			if plain {
+				root := cmd.Root()
+				root.SetOut(output.NewPlainWriter(root.OutOrStdout()))
+				root.SetErr(output.NewPlainWriter(root.ErrOrStderr()))
+			}
+			sys.messages = messages
+			sys.noColor = noColor || plain
+			return sys.enter(netnsName)
+		},
 	}
-	cmd.AddCommand(newInterfacesCmd(lister))
-	cmd.AddCommand(newAddressesCmd(viewer))
-	cmd.AddCommand(newApplyCmd(loader, executor))
-	cmd.AddCommand(newMonitorCmd(lister, viewer))
+	cmd.PersistentFlags().BoolVar(&plain, "plain", false, "ASCII-only output without colors, for serial consoles")
+	cmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Do not color additions, removals and updates, even on a terminal (also NO_COLOR)")
+	cmd.PersistentFlags().StringVar(&lang, "lang", "", "Language of human-readable output: "+strings.Join(i18n.Supported(), " or ")+" (default from LC_ALL, LC_MESSAGES or LANG)")
+	cmd.PersistentFlags().StringVar(&netnsName, "netns", "", "Operate inside this named network namespace (see 'ip netns')")
+	cmd.AddCommand(newInterfacesCmd(sys))
+	cmd.AddCommand(newAddressesCmd(sys))
+	cmd.AddCommand(newApplyCmd(loader, sys))
+	cmd.AddCommand(newLinkCmd(sys))
+	cmd.AddCommand(newMonitorCmd(sys))
+	cmd.AddCommand(newWaitCmd(sys))
+	cmd.AddCommand(newEventsCmd())
+	cmd.AddCommand(newExportCmd(sys))
+	cmd.AddCommand(newSnapshotCmd(sys))
+	cmd.AddCommand(newSimulateCmd(loader, sys))
+	cmd.AddCommand(newDoctorCmd(loader, sys))
+	cmd.AddCommand(newGraphCmd(sys))
+	cmd.AddCommand(newBatchCmd(loader, sys))
+	cmd.AddCommand(newVersionCmd())
 	return cmd
 }
 
-func newInterfacesCmd(lister interfaces.Lister) *cobra.Command {
-	return &cobra.Command{
+// Orders of goeth interfaces --sort.
+const (
+	sortByName    = "name"
+	sortByTraffic = "traffic"
+)
+
+func newInterfacesCmd(sys *system) *cobra.Command {
+	var expr, sortBy, nameRegex string
+	var filter interfaces.Filter
+	var stats, details, tree bool
+	cmd := &cobra.Command{
 		Use:   "interfaces",
 		Short: "List network interfaces",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			interfaces, err := lister.List()
+			q, err := parseQuery(expr)
 			if err != nil {
 				return err
 			}
-			if len(interfaces) == 0 {
-				fmt.Fprintln(cmd.OutOrStdout(), "No interfaces found")
+			if sortBy != sortByName && sortBy != sortByTraffic {
+				return fmt.Errorf("--sort must be %s or %s, got %q", sortByName, sortByTraffic, sortBy)
+			}
+			if sortBy == sortByTraffic && !stats {
+				return fmt.Errorf("--sort %s needs --stats", sortByTraffic)
+			}
+			for _, pattern := range filter.Names {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return fmt.Errorf("--match: invalid pattern %q", pattern)
+				}
+			}
+			if nameRegex != "" {
+				if filter.NameRegex, err = regexp.Compile(nameRegex); err != nil {
+					return fmt.Errorf("--match-regex: %w", err)
+				}
+			}
+			list, err := sys.lister.Where(filter).List()
+			if err != nil {
+				return err
+			}
+			if tree {
+				return interfaces.WriteTree(cmd.OutOrStdout(), list)
+			}
+			if details {
+				if err := addDriverInfo(sys, list); err != nil {
+					return err
+				}
+			}
+			if q != nil {
+				return printQuery(cmd.OutOrStdout(), *q, list)
+			}
+			if len(list) == 0 {
+				sys.messages.Fprintf(cmd.OutOrStdout(), "No interfaces found\n")
 				return nil
 			}
-			for _, iface := range interfaces {
-				fmt.Fprintf(cmd.OutOrStdout(), "%s (MTU=%d, HW=%s)\n", iface.Name, iface.MTU, iface.HardwareAddr)
+			samples := make(map[string]counters.Sample)
+			if stats {
+				collected, err := counters.Collect(sys.provider)
+				if err != nil {
+					return err
+				}
+				for _, sample := range collected {
+					samples[sample.Interface] = sample
+				}
+			}
+			if sortBy == sortByTraffic {
+				busiestFirst(list, samples)
+			}
+			for _, iface := range list {
+				fmt.Fprintf(cmd.OutOrStdout(), "%d: %s (%s)\n", iface.Index, iface.Name, describeLink(iface))
+				if details {
+					printDriverInfo(cmd.OutOrStdout(), iface)
+				}
+				if sample, ok := samples[iface.Name]; ok {
+					printLinkStats(cmd.OutOrStdout(), sample)
+				}
 			}
 			return nil
 		},
 	}
+	addQueryFlag(cmd, &expr)
+	cmd.Flags().BoolVar(&stats, "stats", false, "Also show the bytes, packets, errors and drops each interface received and sent")
+	cmd.Flags().StringSliceVar(&filter.Names, "match", nil, "List only interfaces whose name matches this shell pattern, such as 'en*'; repeatable")
+	cmd.Flags().StringVar(&nameRegex, "match-regex", "", `List only interfaces whose name matches this regular expression, e.g. '^(eth|bond)\d+$'`)
+	cmd.Flags().BoolVar(&filter.Up, "up", false, "List only interfaces that are administratively up")
+	cmd.Flags().BoolVar(&filter.Running, "running", false, "List only interfaces that are operationally up")
+	addKindFlags(cmd, &filter, "List")
+	cmd.Flags().BoolVar(&details, "details", false, "Also show the driver, firmware version and bus address behind each interface")
+	cmd.Flags().StringVar(&sortBy, "sort", sortByName, "Order interfaces by name, or with --stats by traffic, busiest first")
+	cmd.Flags().BoolVar(&tree, "tree", false, "Draw stacked devices as a tree: ports below their bridge or bond, VLANs and the like below their parent")
+	cmd.MarkFlagsMutuallyExclusive("query", "stats")
+	for _, other := range []string{"query", "stats", "details"} {
+		cmd.MarkFlagsMutuallyExclusive("tree", other)
+	}
+	return cmd
+}
+
+// addKindFlags adds the filters by kind that goeth interfaces and monitor
+// share, whose help starts with verb. State filters are left to the
+// interfaces command: the monitor would report an interface going down as
+// removed.
+func addKindFlags(cmd *cobra.Command, filter *interfaces.Filter, verb string) {
+	cmd.Flags().BoolVar(&filter.NoLoopback, "no-loopback", false, verb+" no loopback interfaces")
+	cmd.Flags().BoolVar(&filter.Physical, "physical", false, verb+" only physical NICs, no virtual links")
+	cmd.Flags().StringSliceVar(&filter.Kinds, "type", nil, verb+" only interfaces of this kind, such as physical, bridge, vlan, bond, veth, tun or wireguard; repeatable")
+}
+
+// driverInfoProvider is implemented by providers that can tell which driver
+// and hardware back a link.
+type driverInfoProvider interface {
+	DriverInfo(name string) (ethtool.DriverInfo, error)
+}
+
+// addDriverInfo fills in the driver details of each interface. Those whose
+// driver does not tell, such as the loopback, and those gone meanwhile are
+// left without.
+func addDriverInfo(sys *system, list []interfaces.Interface) error {
+	provider, ok := sys.provider.(driverInfoProvider)
+	if !ok {
+		return nil
+	}
+	for i, iface := range list {
+		info, err := provider.DriverInfo(iface.Name)
+		if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENODEV) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		list[i].Driver, list[i].FirmwareVersion, list[i].BusInfo = info.Driver, info.FirmwareVersion, info.BusInfo
+	}
+	return nil
+}
+
+// printDriverInfo shows the driver details of an interface below its line.
+func printDriverInfo(w io.Writer, iface interfaces.Interface) {
+	var fields []string
+	for _, field := range []struct{ name, value string }{
+		{"driver", iface.Driver}, {"firmware", iface.FirmwareVersion}, {"bus", iface.BusInfo},
+	} {
+		if field.value != "" {
+			fields = append(fields, field.name+"="+field.value)
+		}
+	}
+	if len(fields) > 0 {
+		fmt.Fprintf(w, "    %s\n", strings.Join(fields, " "))
+	}
 }
 
-func newAddressesCmd(viewer addresses.Viewer) *cobra.Command {
-	var ifaceName string
+// busiestFirst orders list by the bytes each interface received and sent,
+// most first. The list comes sorted by name, which breaks ties.
+func busiestFirst(list []interfaces.Interface, samples map[string]counters.Sample) {
+	traffic := func(name string) uint64 {
+		return samples[name].Value(counters.RxBytes) + samples[name].Value(counters.TxBytes)
+	}
+	slices.SortStableFunc(list, func(a, b interfaces.Interface) int {
+		return cmp.Compare(traffic(b.Name), traffic(a.Name))
+	})
+}
+
+// printLinkStats shows the counters of an interface below its line.
+func printLinkStats(w io.Writer, sample counters.Sample) {
+	for _, direction := range []struct {
+		name                            string
+		bytes, packets, errors, dropped string
+	}{
+		{"rx", counters.RxBytes, counters.RxPackets, counters.RxErrors, counters.RxDropped},
+		{"tx", counters.TxBytes, counters.TxPackets, counters.TxErrors, counters.TxDropped},
+	} {
+		fmt.Fprintf(w, "    %s %s, %d packets, %d errors, %d dropped\n", direction.name, counters.ByteSize(sample.Value(direction.bytes)),
+			sample.Value(direction.packets), sample.Value(direction.errors), sample.Value(direction.dropped))
+	}
+}
+
+// resolveInterfaces replaces the interface indexes among refs, given to
+// --interface, by the names of those interfaces.
+func resolveInterfaces(lister interfaces.Lister, refs []string) error {
+	for i, ref := range refs {
+		name, err := lister.Resolve(ref)
+		if err != nil {
+			return err
+		}
+		refs[i] = name
+	}
+	return nil
+}
+
+// describeLink lists the properties of iface that goeth interfaces shows
+// beside its name, leaving out those the provider does not know.
+func describeLink(iface interfaces.Interface) string {
+	var fields []string
+	if iface.Kind != "" {
+		fields = append(fields, "kind="+iface.Kind)
+	}
+	fields = append(fields, fmt.Sprintf("MTU=%d", iface.MTU), "HW="+iface.HardwareAddr)
+	if iface.OperState != "" {
+		fields = append(fields, "state="+iface.OperState, "carrier="+onOff(iface.Carrier))
+	}
+	if iface.Speed > 0 {
+		fields = append(fields, fmt.Sprintf("speed=%dMb/s", iface.Speed))
+	}
+	if iface.Duplex != "" {
+		fields = append(fields, "duplex="+iface.Duplex)
+	}
+	return strings.Join(fields, ", ")
+}
+
+// onOff spells a carrier as the monitor does.
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+func newAddressesCmd(sys *system) *cobra.Command {
+	var ifaceNames []string
+	var expr string
 	cmd := &cobra.Command{
 		Use:   "addresses",
 		Short: "Show addresses for an interface",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			addrs, err := viewer.View(ifaceName)
+			q, err := parseQuery(expr)
 			if err != nil {
 				return err
 			}
-			if len(addrs) == 0 {
-				fmt.Fprintf(cmd.OutOrStdout(), "No addresses for %s\n", ifaceName)
-				return nil
+			if err := resolveInterfaces(sys.lister, ifaceNames); err != nil {
+				return err
+			}
+			byName := make(map[string][]string, len(ifaceNames))
+			for _, name := range ifaceNames {
+				if byName[name], err = sys.viewer.View(name); err != nil {
+					return err
+				}
+			}
+			if len(ifaceNames) == 1 {
+				return printAddresses(cmd.OutOrStdout(), sys.messages, q, ifaceNames[0], byName[ifaceNames[0]], "")
 			}
-			for _, addr := range addrs {
-				fmt.Fprintf(cmd.OutOrStdout(), "%s\n", addr)
+			if q != nil {
+				return printQuery(cmd.OutOrStdout(), *q, byName)
+			}
+			for _, name := range ifaceNames {
+				if err := printAddresses(cmd.OutOrStdout(), sys.messages, nil, name, byName[name], name+" "); err != nil {
+					return err
+				}
 			}
 			return nil
 		},
 	}
-	cmd.Flags().StringVarP(&ifaceName, "interface", "i", "", "Interface name")
+	cmd.Flags().StringSliceVarP(&ifaceNames, "interface", "i", nil, "Interface name or index; repeatable")
+	addQueryFlag(cmd, &expr)
 	cmd.MarkFlagRequired("interface")
 	return cmd
 }
 
-func newApplyCmd(loader config.Loader, executor config.Executor) *cobra.Command {
+// printAddresses lists the addresses of one interface, each line after
+// prefix, or the results of q over them when q is set.
+func printAddresses(w io.Writer, messages i18n.Printer, q *query.Query, name string, addrs []string, prefix string) error {
+	if q != nil {
+		return printQuery(w, *q, addrs)
+	}
+	if len(addrs) == 0 {
+		messages.Fprintf(w, "No addresses for %s\n", name)
+		return nil
+	}
+	for _, addr := range addrs {
+		fmt.Fprintf(w, "%s%s\n", prefix, addr)
+	}
+	return nil
+}
+
+func addQueryFlag(cmd *cobra.Command, expr *string) {
+	cmd.Flags().StringVarP(expr, "query", "q", "", `Print the results of a jq-style expression over the JSON form of the list, e.g. '.[] | select(.flags[] == "up") | .hardware_addr'`)
+}
+
+// parseQuery compiles --query before any work is done; it returns nil when
+// the flag is not set.
+func parseQuery(expr string) (*query.Query, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	q, err := query.Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+func printQuery(w io.Writer, q query.Query, v any) error {
+	results, err := q.Eval(v)
+	if err != nil {
+		return err
+	}
+	return query.Print(w, results)
+}
+
+func newApplyCmd(loader config.Loader, sys *system) *cobra.Command {
 	var path string
-	var dryRun bool
+	var dryRun, interactive, force bool
+	retry := config.DefaultRetry
 	cmd := &cobra.Command{
 		Use:   "apply-config",
 		Short: "Apply configuration from a JSON file",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if dryRun && interactive {
+				return errors.New("--dry-run and --interactive cannot be combined")
+			}
 			cfg, err := loader.Load(path)
 			if err != nil {
 				return err
 			}
-			selected := executor
+			for _, warning := range cfg.Warnings() {
+				sys.messages.Fprintf(cmd.ErrOrStderr(), "Warning: %s\n", warning)
+			}
+			// A dry run only prints the configuration, so it does not need
+			// the namespace to exist.
+			if !dryRun {
+				if err := sys.enter(cfg.Netns); err != nil {
+					return err
+				}
+				if err := checkManagers(cmd.ErrOrStderr(), sys, cfg.Interface, force); err != nil {
+					return err
+				}
+			}
+			if interactive {
+				return applyInteractive(cmd.InOrStdin(), cmd.OutOrStdout(), sys.messages, output.NewColorizer(cmd.OutOrStdout(), sys.noColor), cfg, sys.provider)
+			}
+			var notes noteList
+			selected := sys.executor
+			if netlinkExecutor, ok := selected.(config.NetlinkExecutor); ok {
+				netlinkExecutor.Reporter = &notes
+				netlinkExecutor.Retry = &retry
+				netlinkExecutor.Context = cmd.Context()
+				selected = netlinkExecutor
+			}
 			if dryRun {
 				selected = config.ConsoleExecutor{Writer: cmd.OutOrStdout()}
 			}
@@ -107,42 +456,411 @@ func newApplyCmd(loader config.Loader, executor config.Executor) *cobra.Command
 			if err := applier.Apply(cfg); err != nil {
 				return err
 			}
-			fmt.Fprintf(cmd.OutOrStdout(), "Configuration applied to %s\n", cfg.Interface)
+			sys.messages.Fprintf(cmd.OutOrStdout(), "Configuration applied to %s\n", cfg.Interface)
+			for _, note := range notes {
+				sys.messages.Fprintf(cmd.OutOrStdout(), "Note: %s\n", note)
+			}
 			return nil
 		},
 	}
 	cmd.Flags().StringVarP(&path, "file", "f", "", "Path to JSON configuration file")
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print intended operations without touching the network")
+	cmd.Flags().BoolVar(&interactive, "interactive", false, "Review the plan and accept or skip each change before applying it")
+	cmd.Flags().BoolVar(&force, "force", false, "Apply even when a network manager controls the interface")
+	cmd.Flags().IntVar(&retry.Attempts, "retries", retry.Attempts, "Retries after transient netlink errors such as EBUSY or ENOBUFS; 0 fails on the first one")
+	cmd.Flags().DurationVar(&retry.Backoff, "retry-backoff", retry.Backoff, "Wait before the first retry, doubled for each further one")
 	cmd.MarkFlagRequired("file")
 	return cmd
 }
 
-func newMonitorCmd(lister interfaces.Lister, viewer addresses.Viewer) *cobra.Command {
+// checkManagers refuses to configure an interface that NetworkManager or
+// systemd-networkd controls, as each would keep undoing the other's changes.
+// With force it only warns.
+func checkManagers(w io.Writer, sys *system, iface string, force bool) error {
+	detector, ok := sys.provider.(config.ManagerDetector)
+	if !ok {
+		return nil
+	}
+	managers, err := detector.Managers(iface)
+	if err != nil || len(managers) == 0 {
+		return err
+	}
+	names := make([]string, 0, len(managers))
+	releases := make([]string, 0, len(managers))
+	for _, manager := range managers {
+		names = append(names, manager.String())
+		releases = append(releases, manager.Release)
+	}
+	if force {
+		sys.messages.Fprintf(w, "Warning: %s is managed by %s, which may undo these changes\n", iface, strings.Join(names, " and "))
+		return nil
+	}
+	return fmt.Errorf("%s is managed by %s; %s, or pass --force to apply anyway", iface, strings.Join(names, " and "), strings.Join(releases, " and "))
+}
+
+// Monitor modes: rescanning the system every interval, or following
+// netlink notifications.
+const (
+	monitorPoll      = "poll"
+	monitorSubscribe = "subscribe"
+)
+
+// Ways --gateway-probe allows of checking gateways.
+const (
+	gatewayICMP = "icmp"
+	gatewayARP  = "arp"
+)
+
+func newMonitorCmd(sys *system) *cobra.Command {
 	var interval time.Duration
-	var iface string
+	var ifaceRegex, mode string
+	var poll, summaryOnly, quiet, watchNeighbors, stats, failOnRemoval bool
+	var ifaces, neighborIPs, kinds []string
+	var filter interfaces.Filter
+	var sinks sinkOptions
+	var summaryEvery, runFor, probeEvery, gatewayEvery, heartbeatEvery time.Duration
+	var gatewayProbe string
+	var maxEvents int
+	var logPath, logMaxSize, statePath string
+	var logRotateEvery time.Duration
+	var logKeep int
 	cmd := &cobra.Command{
 		Use:   "monitor",
 		Short: "Watch interfaces and addresses for changes",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if poll {
+				mode = monitorPoll
+			}
+			if mode != monitorPoll && mode != monitorSubscribe {
+				return fmt.Errorf("--mode must be %s or %s, got %q", monitorPoll, monitorSubscribe, mode)
+			}
+			var pattern *regexp.Regexp
+			if ifaceRegex != "" {
+				var err error
+				if pattern, err = regexp.Compile(ifaceRegex); err != nil {
+					return fmt.Errorf("--interface-regex: %w", err)
+				}
+			}
+			if err := resolveInterfaces(sys.lister, ifaces); err != nil {
+				return err
+			}
+			events := eventKinds(kinds)
+			out := cmd.OutOrStdout()
+			if logPath != "" {
+				maxSize, err := logfile.ParseSize(logMaxSize)
+				if err != nil {
+					return fmt.Errorf("--log-max-size: %w", err)
+				}
+				file := &logfile.File{Path: logPath, MaxSize: maxSize, MaxAge: logRotateEvery, Keep: logKeep, Errors: cmd.ErrOrStderr()}
+				if err := file.Open(); err != nil {
+					return err
+				}
+				defer file.Close()
+				out = file
+			}
 			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
 			defer stop()
 			watcher := monitor.Watcher{
-				Lister:    lister,
-				Viewer:    viewer,
-				Interval:  interval,
-				Interface: iface,
-				Writer:    cmd.OutOrStdout(),
+				Lister:         sys.lister.Where(filter),
+				Viewer:         sys.viewer,
+				Routes:         &sys.routes,
+				Interval:       interval,
+				Interfaces:     ifaces,
+				InterfaceRegex: pattern,
+				Writer:         out,
+				SummaryEvery:   summaryEvery,
+				SummaryOnly:    summaryOnly,
+				Kinds:          events,
+				Quiet:          quiet,
+				Messages:       sys.messages,
+				Colors:         output.NewColorizer(out, sys.noColor),
+				FailOnRemoval:  failOnRemoval,
+				Duration:       runFor,
+				HeartbeatEvery: heartbeatEvery,
+				MaxEvents:      maxEvents,
+				StatePath:      statePath,
+			}
+			if watchNeighbors || len(neighborIPs) > 0 || slices.Contains(events, netevent.Neighbor) {
+				watcher.Neighbors = &sys.neighbors
+				watcher.NeighborIPs = neighborIPs
 			}
-			if err := watcher.Run(ctx); err != nil {
-				if errors.Is(err, context.Canceled) {
-					return nil
+			if probeEvery > 0 {
+				watcher.Prober = nsAddressProber{ns: sys.ns, prober: announce.Prober{}}
+				watcher.ProbeEvery = probeEvery
+			}
+			if gatewayEvery > 0 {
+				switch gatewayProbe {
+				case gatewayICMP:
+					watcher.Gateways = nsGatewayProber{ns: sys.ns, prober: pmtu.Prober{}}
+				case gatewayARP:
+					watcher.Gateways = nsGatewayProber{ns: sys.ns, prober: announce.Prober{}}
+				default:
+					return fmt.Errorf("--gateway-probe must be %s or %s, got %q", gatewayICMP, gatewayARP, gatewayProbe)
 				}
+				watcher.GatewayEvery = gatewayEvery
+			}
+			if stats || slices.Contains(events, netevent.Traffic) {
+				watcher.Traffic = sys.provider
+			}
+			eventSinks, group, err := sinks.start(sys.provider, cmd.OutOrStdout(), cmd.ErrOrStderr())
+			if err != nil {
 				return err
 			}
+			defer group.stop(cmd.ErrOrStderr())
+			watcher.Sinks = eventSinks
+			cacheErr := make(chan error, 1)
+			if mode == monitorSubscribe {
+				shared := cache.New(sys.updates)
+				subscribed, cancel := context.WithCancel(ctx)
+				defer cancel()
+				go func() {
+					cacheErr <- shared.Run(subscribed)
+					cancel()
+				}()
+				if err := shared.Wait(subscribed); err != nil {
+					cause := <-cacheErr
+					if !errors.Is(cause, cache.ErrSubscribe) {
+						cacheErr <- cause
+						return monitorError(err, cacheErr)
+					}
+					sys.messages.Fprintf(cmd.ErrOrStderr(), "Warning: %v; polling every %s instead\n", cause, interval)
+				} else {
+					ctx = subscribed
+					watcher.Lister = interfaces.NewLister(shared).Where(filter)
+					watcher.Viewer = addresses.NewViewer(shared)
+					cachedRoutes := routes.NewViewer(shared)
+					watcher.Routes = &cachedRoutes
+					if watcher.Neighbors != nil {
+						cachedNeighbors := neighbors.NewViewer(shared)
+						watcher.Neighbors = &cachedNeighbors
+					}
+					watcher.Changes = shared.Changes()
+				}
+			}
+			return monitorError(watcher.Run(ctx), cacheErr)
+		},
+	}
+	cmd.Flags().DurationVarP(&interval, "interval", "t", 5*time.Second, "Polling interval in poll mode, and the period traffic is measured over with --stats")
+	cmd.Flags().StringSliceVarP(&ifaces, "interface", "i", nil, "Interface to monitor by name or index, or a shell pattern such as 'eth*'; repeatable (all by default)")
+	addKindFlags(cmd, &filter, "Monitor")
+	cmd.Flags().StringVar(&ifaceRegex, "interface-regex", "", `Monitor only the interfaces whose name matches this regular expression, e.g. '^(eth|bond)\d+$'`)
+	cmd.Flags().DurationVar(&summaryEvery, "summary-every", 0, "Print a rollup of the changes seen in each period (e.g. 5m)")
+	cmd.Flags().BoolVar(&summaryOnly, "summary-only", false, "Print only the periodic rollups, not each change")
+	cmd.Flags().StringSliceVar(&kinds, "events", nil, "Report only these kinds of change: link, addr, route, neighbor or traffic (all by default); neighbor and traffic imply --neighbors and --stats")
+	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Do not print the header and every interface on start, only the changes")
+	cmd.Flags().BoolVar(&watchNeighbors, "neighbors", false, "Also report ARP and NDP entries that appear, become stale or fail")
+	cmd.Flags().StringSliceVar(&neighborIPs, "neighbor", nil, "Report only the neighbor entries of this IP; repeatable, implies --neighbors")
+	cmd.Flags().DurationVar(&probeEvery, "arp-probe", 0, "ARP-probe the IPv4 addresses of the monitored interfaces on start and every this long (e.g. 1m) for other hosts using them; needs CAP_NET_RAW")
+	cmd.Flags().DurationVar(&gatewayEvery, "gateway-check", 0, "Check on start and every this long (e.g. 30s) that the IPv4 default gateways answer, and report when they stop or start; needs CAP_NET_RAW")
+	cmd.Flags().StringVar(&gatewayProbe, "gateway-probe", gatewayICMP, "How --gateway-check asks the gateways: "+gatewayICMP+" (echo requests) or "+gatewayARP+" (for gateways that drop pings)")
+	cmd.Flags().BoolVar(&stats, "stats", false, "Also report the traffic rates, errors and drops of each interval")
+	cmd.Flags().DurationVar(&heartbeatEvery, "heartbeat", 0, "Also send the webhook and other sinks a heartbeat event this often (e.g. 1m), so they can tell a quiet network from a stopped monitor")
+	cmd.Flags().StringVar(&sinks.webhookURL, "webhook", "", "POST each change as JSON to this URL (signed with "+webhookSecretEnv+" when set)")
+	cmd.Flags().StringVar(&sinks.webhookTemplate, "webhook-template", "", "Render each webhook body from this Go template file of the event instead of the JSON document")
+	cmd.Flags().StringVar(&sinks.webhookType, "webhook-content-type", "application/json", "Content-Type of the webhook bodies")
+	cmd.Flags().IntVar(&sinks.webhookRetries, "webhook-retries", 3, "Retries of a webhook delivery the endpoint could not take")
+	cmd.Flags().StringVar(&sinks.syslogTarget, "syslog", "", "Also log each change to syslog: local, or udp://host[:port], tcp://host[:port], unix:///path, unixgram:///path")
+	cmd.Flags().StringVar(&sinks.syslogFacility, "syslog-facility", "daemon", "Syslog facility, such as daemon or local0")
+	cmd.Flags().StringVar(&sinks.syslogSeverity, "syslog-severity", "notice", "Severity of --syslog and --journald messages, such as notice or warning")
+	cmd.Flags().BoolVar(&sinks.journald, "journald", false, "Also log each change to the systemd journal, with IFACE, EVENT_TYPE, OLD and NEW fields")
+	cmd.Flags().StringVar(&sinks.execLine, "exec", "", "Run this shell command for each change, with GOETH_EVENT_TIME, _KIND, _TYPE, _INTERFACE, _OLD, _NEW and _MESSAGE set")
+	cmd.Flags().IntVar(&sinks.execConcurrency, "exec-concurrency", 1, "How many --exec runs may overlap; above 1 they may finish out of order")
+	cmd.Flags().DurationVar(&sinks.execTimeout, "exec-timeout", 30*time.Second, "Kill an --exec run that takes longer; 0 waits indefinitely")
+	cmd.Flags().DurationVar(&runFor, "for", 0, "Stop monitoring after this long (e.g. 10m) and exit successfully")
+	cmd.Flags().IntVar(&maxEvents, "max-events", 0, "Stop monitoring once this many changes have been reported and exit successfully")
+	cmd.Flags().BoolVar(&failOnRemoval, "fail-on-removal", false, "Exit with an error as soon as a monitored interface is removed or loses its carrier")
+	cmd.Flags().StringVar(&logPath, "log-file", "", "Write the changes to this file instead of stdout, rotating it by size and age")
+	cmd.Flags().StringVar(&logMaxSize, "log-max-size", "10M", "Rotate --log-file before it grows past this size (K, M or G suffix); 0 disables")
+	cmd.Flags().DurationVar(&logRotateEvery, "log-rotate-every", 0, "Also rotate --log-file after it has been written to for this long (e.g. 24h)")
+	cmd.Flags().IntVar(&logKeep, "log-keep", 5, "How many rotated log files to keep as FILE.1, FILE.2 and so on")
+	cmd.Flags().StringVar(&sinks.jsonEvents, "events-json", "", "Also write each change as a JSON document per line to this file, or to stdout with -")
+	cmd.Flags().BoolVar(&sinks.dbus, "dbus", false, "Also emit each change as a Changed signal of org.goeth.Monitor1 on the system bus")
+	cmd.Flags().StringVar(&sinks.snmpTarget, "snmp-trap", "", "Also send each change as an SNMP notification to this host[:port] (port 162 by default)")
+	cmd.Flags().StringVar(&sinks.snmpVersion, "snmp-version", snmp.V2c, "SNMP version of --snmp-trap: 2c or 3")
+	cmd.Flags().StringVar(&sinks.snmpCommunity, "snmp-community", "public", "SNMPv2c community of --snmp-trap")
+	cmd.Flags().StringVar(&sinks.snmpUser, "snmp-user", "", "SNMPv3 user of --snmp-trap")
+	cmd.Flags().StringVar(&sinks.snmpAuth, "snmp-auth", "", "SNMPv3 authentication: md5 or sha, with the pass phrase in "+snmpAuthPasswordEnv+" (none by default)")
+	cmd.Flags().StringVar(&sinks.snmpPriv, "snmp-priv", "", "SNMPv3 privacy: des or aes, with the pass phrase in "+snmpPrivPasswordEnv+" (none by default)")
+	cmd.Flags().StringVar(&sinks.snmpEngineID, "snmp-engine-id", "", "SNMPv3 engine ID of goeth in hex, which receivers configure users against (derived from the host name by default)")
+	cmd.Flags().StringVar(&sinks.snmpBootsFile, "snmp-boots-file", defaultSNMPBootsPath, "File counting the restarts of goeth's SNMPv3 engine, which receivers check notifications against (empty keeps the count at 1)")
+	cmd.Flags().StringVar(&sinks.mqttBroker, "mqtt", "", "Also publish each change as JSON to this MQTT broker: mqtt://host[:port], or mqtts://host[:port] for TLS")
+	cmd.Flags().StringVar(&sinks.mqttTopic, "mqtt-topic", "goeth", "Topic prefix of --mqtt; each change goes to PREFIX/INTERFACE")
+	cmd.Flags().IntVar(&sinks.mqttQoS, "mqtt-qos", 0, "QoS of --mqtt messages: 0 at most once, 1 at least once, 2 exactly once")
+	cmd.Flags().BoolVar(&sinks.mqttRetain, "mqtt-retain", false, "Have the broker retain the last change of each interface for new subscribers")
+	cmd.Flags().StringVar(&sinks.mqttClientID, "mqtt-client-id", "", "MQTT client ID (default goeth-HOSTNAME)")
+	cmd.Flags().StringVar(&sinks.mqttUser, "mqtt-user", "", "MQTT user name, with the password in "+mqttPasswordEnv)
+	cmd.Flags().StringVar(&sinks.mqttCA, "mqtt-ca", "", "Verify an mqtts:// broker against the CA certificates in this PEM file instead of the system roots")
+	cmd.Flags().StringVar(&sinks.mqttCert, "mqtt-cert", "", "Client certificate PEM file presented to an mqtts:// broker, with --mqtt-key")
+	cmd.Flags().StringVar(&sinks.mqttKey, "mqtt-key", "", "Private key PEM file of --mqtt-cert")
+	cmd.Flags().StringVar(&sinks.metricsFile, "metrics-file", "", "Keep change counts by interface, kind and type in this file for the node_exporter textfile collector")
+	cmd.Flags().StringVar(&statePath, "state-file", "", "Save the monitored state to this file and, on start, report what changed since it was saved instead of the whole state")
+	cmd.Flags().StringVar(&sinks.historyPath, "history", "", "Keep the last --history-size changes in this file for 'goeth events' (default "+defaultHistoryPath+" when given without a value)")
+	cmd.Flags().Lookup("history").NoOptDefVal = defaultHistoryPath
+	cmd.Flags().IntVar(&sinks.historySize, "history-size", 1000, "How many changes --history keeps")
+	cmd.Flags().StringVar(&mode, "mode", monitorSubscribe, "How changes are detected: subscribe (netlink notifications, falling back to poll when not permitted) or poll (rescan every interval)")
+	cmd.Flags().BoolVar(&poll, "poll", false, "Same as --mode poll")
+	cmd.Flags().MarkDeprecated("poll", "use --mode poll instead")
+	return cmd
+}
+
+// noteList collects the notes an executor reports during an apply.
+type noteList []string
+
+func (l *noteList) Report(note string) {
+	*l = append(*l, note)
+}
+
+// eventAliases are the short names --events also takes for kinds of
+// netevent.Event.
+var eventAliases = map[string]string{
+	"addr":  netevent.Address,
+	"neigh": netevent.Neighbor,
+}
+
+// eventKinds turns the values of --events into kinds of netevent.Event,
+// leaving unknown ones for the watcher to reject.
+func eventKinds(values []string) []string {
+	var kinds []string
+	for _, value := range values {
+		if kind, ok := eventAliases[value]; ok {
+			value = kind
+		}
+		if !slices.Contains(kinds, value) {
+			kinds = append(kinds, value)
+		}
+	}
+	return kinds
+}
+
+// monitorError reports why monitoring stopped, preferring a cache failure
+// over the cancellation it caused and treating interrupts as a clean exit.
+func monitorError(err error, cacheErr <-chan error) error {
+	select {
+	case cause := <-cacheErr:
+		if cause != nil && !errors.Is(cause, context.Canceled) {
+			return cause
+		}
+	default:
+	}
+	if err == nil || errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+func newSnapshotCmd(sys *system) *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Capture links, addresses and neighbors as a JSON state file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state, err := snapshot.Capture(sys.provider)
+			if err != nil {
+				return err
+			}
+			if output == "" {
+				return snapshot.Write(cmd.OutOrStdout(), state)
+			}
+			file, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			if err := snapshot.Write(file, state); err != nil {
+				file.Close()
+				return err
+			}
+			return file.Close()
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write the state to this file instead of stdout")
+	return cmd
+}
+
+func newSimulateCmd(loader config.Loader, sys *system) *cobra.Command {
+	var path, statePath string
+	cmd := &cobra.Command{
+		Use:   "simulate",
+		Short: "Compute the apply plan for a configuration against a captured state file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loader.Load(path)
+			if err != nil {
+				return err
+			}
+			state, err := snapshot.Load(statePath)
+			if err != nil {
+				return err
+			}
+			sim := snapshot.NewSimulator(state)
+			if err := config.NewApplier(config.NewNetlinkExecutor(sim)).Apply(cfg); err != nil {
+				return err
+			}
+			plan := sim.Plan()
+			if len(plan) == 0 {
+				sys.messages.Fprintf(cmd.OutOrStdout(), "No changes for %s\n", cfg.Interface)
+			} else {
+				sys.messages.Fprintf(cmd.OutOrStdout(), "Plan for %s:\n", cfg.Interface)
+				colors := output.NewColorizer(cmd.OutOrStdout(), sys.noColor)
+				for _, step := range plan {
+					fmt.Fprintf(cmd.OutOrStdout(), " - %s\n", colors.Paint(stepChange(step), step))
+				}
+			}
+			if notes := sim.Notes(); len(notes) > 0 {
+				sys.messages.Fprintf(cmd.OutOrStdout(), "Left unchanged:\n")
+				for _, note := range notes {
+					fmt.Fprintf(cmd.OutOrStdout(), " - %s\n", note)
+				}
+			}
 			return nil
 		},
 	}
-	cmd.Flags().DurationVarP(&interval, "interval", "t", 5*time.Second, "Polling interval")
-	cmd.Flags().StringVarP(&iface, "interface", "i", "", "Interface to monitor (all by default)")
+	cmd.Flags().StringVarP(&path, "file", "f", "", "Path to JSON configuration file")
+	cmd.Flags().StringVar(&statePath, "state", "", "Path to a state file captured with 'goeth snapshot'")
+	cmd.MarkFlagRequired("file")
+	cmd.MarkFlagRequired("state")
+	return cmd
+}
+
+func newVersionCmd() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Show version and build information",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			info := buildinfo.Collect(Version, Commit, BuildDate, backends)
+			switch output {
+			case "text":
+				fmt.Fprintln(cmd.OutOrStdout(), info)
+				fmt.Fprintf(cmd.OutOrStdout(), "backends: %s\n", strings.Join(info.Backends, ", "))
+				return nil
+			case "json":
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(info)
+			}
+			return fmt.Errorf("unknown output format %q (want text or json)", output)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format: text or json")
 	return cmd
 }
+
+// selectMessages picks the output language from --lang or the locale. Plain
+// output is ASCII only, so it keeps English unless a language is requested.
+func selectMessages(lang string, plain bool) (i18n.Printer, error) {
+	if lang == "" {
+		if plain {
+			messages, _ := i18n.New(i18n.English)
+			return messages, nil
+		}
+		messages, _ := i18n.New(i18n.Detect(os.Getenv))
+		return messages, nil
+	}
+	messages, ok := i18n.New(lang)
+	if !ok {
+		return messages, fmt.Errorf("unsupported language %q (want %s)", lang, strings.Join(i18n.Supported(), " or "))
+	}
+	if plain && messages.Lang() != i18n.English {
+		return messages, fmt.Errorf("--plain output is ASCII only and cannot show %s messages", messages.Lang())
+	}
+	return messages, nil
+}
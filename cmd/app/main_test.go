@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/user/goeth/internal/buildinfo"
+	"github.com/user/goeth/internal/config"
+	"github.com/user/goeth/internal/netmanager"
+)
+
+func TestVersionReportsTheCompiledInBackends(t *testing.T) {
+	cmd := newVersionCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--output", "json"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	var info buildinfo.Info
+	if err := json.Unmarshal(out.Bytes(), &info); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if want := []string{"netlink", "ethtool", "sysfs", "bpf", "packet", "dbus"}; !reflect.DeepEqual(info.Backends, want) {
+		t.Errorf("backends = %v, want %v", info.Backends, want)
+	}
+}
+
+// managedProvider reports the managers of each interface. It implements no
+// other part of NetlinkProvider.
+type managedProvider struct {
+	config.NetlinkProvider
+	managers map[string][]netmanager.Manager
+	err      error
+}
+
+func (p managedProvider) Managers(name string) ([]netmanager.Manager, error) {
+	return p.managers[name], p.err
+}
+
+func TestCheckManagers(t *testing.T) {
+	managers := map[string][]netmanager.Manager{
+		"eth0": {{Name: "NetworkManager", PID: 700, Release: "run 'nmcli device set eth0 managed no'"}},
+		"eth1": {
+			{Name: "NetworkManager", PID: 700, Release: "run 'nmcli device set eth1 managed no'"},
+			{Name: "systemd-networkd", PID: 701, Release: "add Unmanaged=yes to its .network file"},
+		},
+	}
+	tests := []struct {
+		name     string
+		provider config.NetlinkProvider
+		iface    string
+		force    bool
+		// wantErr and wantOut must appear in the error and the output, which
+		// are empty when they are.
+		wantErr string
+		wantOut string
+	}{
+		{name: "unmanaged", provider: managedProvider{managers: managers}, iface: "eth2"},
+		{name: "managed", provider: managedProvider{managers: managers}, iface: "eth0",
+			wantErr: "eth0 is managed by NetworkManager (pid 700); run 'nmcli device set eth0 managed no', or pass --force"},
+		{name: "managed twice", provider: managedProvider{managers: managers}, iface: "eth1",
+			wantErr: "NetworkManager (pid 700) and systemd-networkd (pid 701); run 'nmcli device set eth1 managed no' and add Unmanaged=yes"},
+		{name: "forced", provider: managedProvider{managers: managers}, iface: "eth0", force: true,
+			wantOut: "Warning: eth0 is managed by NetworkManager (pid 700), which may undo these changes\n"},
+		{name: "detection fails", provider: managedProvider{err: errors.New("read /run: permission denied")}, iface: "eth0",
+			wantErr: "read /run: permission denied"},
+		{name: "no detector", provider: struct{ config.NetlinkProvider }{}, iface: "eth0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			sys := &system{provider: tt.provider}
+			err := checkManagers(&out, sys, tt.iface, tt.force)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("checkManagers() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("checkManagers() error = %v, want %q", err, tt.wantErr)
+			}
+			if got := out.String(); tt.wantOut == "" && got != "" || !strings.Contains(got, tt.wantOut) {
+				t.Errorf("output = %q, want %q", got, tt.wantOut)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/vishvananda/netns"
+
+	"github.com/user/goeth/internal/addresses"
+	"github.com/user/goeth/internal/announce"
+	"github.com/user/goeth/internal/cache"
+	"github.com/user/goeth/internal/config"
+	"github.com/user/goeth/internal/i18n"
+	"github.com/user/goeth/internal/interfaces"
+	"github.com/user/goeth/internal/monitor"
+	"github.com/user/goeth/internal/namespace"
+	"github.com/user/goeth/internal/neighbors"
+	"github.com/user/goeth/internal/routes"
+)
+
+// system holds the integrations and output settings of the commands. It
+// starts out in the namespace of the process and is switched once, by --netns
+// or by the netns field of a configuration, before a command does any work.
+type system struct {
+	lister interfaces.Lister
+	viewer addresses.Viewer
+	routes routes.Viewer
+	// neighbors lists the ARP and NDP entries the monitor can follow.
+	neighbors neighbors.Viewer
+	executor  config.Executor
+	provider  config.NetlinkProvider
+	updates   cache.Source
+	// netns names the namespace the integrations work in, "" for the
+	// namespace of the process.
+	netns string
+	// ns is the handle of netns, netns.None() for the namespace of the
+	// process, for the integrations that open their own sockets.
+	ns netns.NsHandle
+	// open builds the integrations for a named namespace.
+	open func(name string) (system, error)
+	// messages localizes human-readable output.
+	messages i18n.Printer
+	// noColor keeps changes uncolored even on a terminal.
+	noColor bool
+}
+
+// localSystem works in the namespace of the process.
+func localSystem() system {
+	api := config.NetlinkAPI{}
+	return system{
+		lister:    interfaces.NewLister(interfaces.NetlinkProvider{}),
+		viewer:    addresses.NewViewer(addresses.NetProvider{}),
+		routes:    routes.NewViewer(routes.NetlinkProvider{}),
+		neighbors: neighbors.NewViewer(neighbors.NetlinkProvider{}),
+		executor:  config.NewNetlinkExecutor(api),
+		provider:  api,
+		updates:   cache.NetlinkSource{},
+		ns:        netns.None(),
+		open:      namespaceSystem,
+	}
+}
+
+// namespaceSystem works inside the named namespace. Its handles stay open
+// until the process exits.
+func namespaceSystem(name string) (system, error) {
+	ns, err := namespace.Open(name)
+	if err != nil {
+		return system{}, err
+	}
+	api, err := config.NewNetlinkAPIAt(ns)
+	if err != nil {
+		ns.Close()
+		return system{}, fmt.Errorf("netns %s: %w", name, err)
+	}
+	source, err := cache.NewNetlinkSourceAt(ns)
+	if err != nil {
+		ns.Close()
+		return system{}, fmt.Errorf("netns %s: %w", name, err)
+	}
+	linkProvider, err := interfaces.NewNetlinkProviderAt(ns)
+	if err != nil {
+		ns.Close()
+		return system{}, fmt.Errorf("netns %s: %w", name, err)
+	}
+	routeProvider, err := routes.NewNetlinkProviderAt(ns)
+	if err != nil {
+		ns.Close()
+		return system{}, fmt.Errorf("netns %s: %w", name, err)
+	}
+	neighborProvider, err := neighbors.NewNetlinkProviderAt(ns)
+	if err != nil {
+		ns.Close()
+		return system{}, fmt.Errorf("netns %s: %w", name, err)
+	}
+	return system{
+		lister:    interfaces.NewLister(linkProvider),
+		viewer:    addresses.NewViewer(nsAddresses{ns: ns, provider: addresses.NetProvider{}}),
+		routes:    routes.NewViewer(routeProvider),
+		neighbors: neighbors.NewViewer(neighborProvider),
+		executor:  config.NewNetlinkExecutor(api),
+		provider:  api,
+		updates:   source,
+		netns:     name,
+		ns:        ns,
+		open:      namespaceSystem,
+	}, nil
+}
+
+// enter switches s to the named namespace. An empty name or the current
+// namespace is a no-op; switching between two named namespaces is refused
+// so a configuration cannot silently override --netns.
+func (s *system) enter(name string) error {
+	if name == "" || name == s.netns {
+		return nil
+	}
+	if s.netns != "" {
+		return fmt.Errorf("operating in netns %s, not %s", s.netns, name)
+	}
+	next, err := s.open(name)
+	if err != nil {
+		return err
+	}
+	next.messages = s.messages
+	next.noColor = s.noColor
+	*s = next
+	return nil
+}
+
+// nsAddresses looks up addresses from inside ns.
+type nsAddresses struct {
+	ns       netns.NsHandle
+	provider addresses.Provider
+}
+
+func (p nsAddresses) InterfaceAddresses(name string) (addrs []string, err error) {
+	err = namespace.Do(p.ns, func() error {
+		addrs, err = p.provider.InterfaceAddresses(name)
+		return err
+	})
+	return addrs, err
+}
+
+// nsAddressProber probes addresses from inside ns, as its packet sockets
+// would otherwise be opened on the links of the namespace of the process.
+type nsAddressProber struct {
+	ns     netns.NsHandle
+	prober monitor.AddressProber
+}
+
+func (p nsAddressProber) Probe(ips map[string][]net.IP) (claims []announce.Claim, err error) {
+	err = namespace.Do(p.ns, func() error {
+		claims, err = p.prober.Probe(ips)
+		return err
+	})
+	return claims, err
+}
+
+// nsGatewayProber asks gateways from inside ns.
+type nsGatewayProber struct {
+	ns     netns.NsHandle
+	prober monitor.GatewayProber
+}
+
+func (p nsGatewayProber) Reachable(name string, gateway net.IP) (ok bool, err error) {
+	err = namespace.Do(p.ns, func() error {
+		ok, err = p.prober.Reachable(name, gateway)
+		return err
+	})
+	return ok, err
+}
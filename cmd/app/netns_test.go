@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vishvananda/netns"
+
+	"github.com/user/goeth/internal/announce"
+)
+
+type fakeAddressProber struct {
+	calls int
+}
+
+func (p *fakeAddressProber) Probe(ips map[string][]net.IP) ([]announce.Claim, error) {
+	p.calls++
+	return []announce.Claim{{Device: "eth0", IP: ips["eth0"][0]}}, nil
+}
+
+type fakeGatewayProber struct {
+	calls int
+}
+
+func (p *fakeGatewayProber) Reachable(name string, gateway net.IP) (bool, error) {
+	p.calls++
+	return true, nil
+}
+
+// notANamespace returns a handle that is open but names no namespace, so
+// that entering it fails without privileges.
+func notANamespace(t *testing.T) netns.NsHandle {
+	t.Helper()
+	file, err := os.Create(filepath.Join(t.TempDir(), "not-a-netns"))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return netns.NsHandle(file.Fd())
+}
+
+func TestNamespaceAddressProberProbesInsideTheNamespace(t *testing.T) {
+	ips := map[string][]net.IP{"eth0": {net.ParseIP("192.0.2.10")}}
+
+	local := &fakeAddressProber{}
+	claims, err := nsAddressProber{ns: netns.None(), prober: local}.Probe(ips)
+	if err != nil || len(claims) != 1 || local.calls != 1 {
+		t.Fatalf("Probe() in the namespace of the process = %v, %v after %d calls", claims, err, local.calls)
+	}
+
+	other := &fakeAddressProber{}
+	if _, err := (nsAddressProber{ns: notANamespace(t), prober: other}).Probe(ips); err == nil {
+		t.Error("Probe() succeeded without entering the namespace")
+	}
+	if other.calls != 0 {
+		t.Errorf("the prober ran %d times outside the namespace", other.calls)
+	}
+}
+
+func TestNamespaceGatewayProberAsksInsideTheNamespace(t *testing.T) {
+	gateway := net.ParseIP("192.0.2.1")
+
+	local := &fakeGatewayProber{}
+	ok, err := nsGatewayProber{ns: netns.None(), prober: local}.Reachable("eth0", gateway)
+	if err != nil || !ok || local.calls != 1 {
+		t.Fatalf("Reachable() in the namespace of the process = %v, %v after %d calls", ok, err, local.calls)
+	}
+
+	other := &fakeGatewayProber{}
+	if _, err := (nsGatewayProber{ns: notANamespace(t), prober: other}).Reachable("eth0", gateway); err == nil {
+		t.Error("Reachable() succeeded without entering the namespace")
+	}
+	if other.calls != 0 {
+		t.Errorf("the prober ran %d times outside the namespace", other.calls)
+	}
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/user/goeth/internal/backoff"
+	"github.com/user/goeth/internal/config"
+	"github.com/user/goeth/internal/dbus"
+	"github.com/user/goeth/internal/history"
+	"github.com/user/goeth/internal/logsink"
+	"github.com/user/goeth/internal/monitor"
+	"github.com/user/goeth/internal/mqtt"
+	"github.com/user/goeth/internal/queue"
+	"github.com/user/goeth/internal/snmp"
+	"github.com/user/goeth/internal/trigger"
+	"github.com/user/goeth/internal/webhook"
+	"github.com/user/goeth/pkg/netevent"
+)
+
+// sinkOptions selects where monitor events go besides the output.
+type sinkOptions struct {
+	webhookURL      string
+	webhookRetries  int
+	webhookTemplate string
+	webhookType     string
+	syslogTarget    string
+	syslogFacility  string
+	syslogSeverity  string
+	journald        bool
+	execLine        string
+	execConcurrency int
+	execTimeout     time.Duration
+	historyPath     string
+	historySize     int
+	jsonEvents      string
+	metricsFile     string
+	dbus            bool
+	snmpTarget      string
+	snmpVersion     string
+	snmpCommunity   string
+	snmpUser        string
+	snmpAuth        string
+	snmpPriv        string
+	snmpEngineID    string
+	snmpBootsFile   string
+	mqttBroker      string
+	mqttTopic       string
+	mqttQoS         int
+	mqttRetain      bool
+	mqttClientID    string
+	mqttUser        string
+	mqttCA          string
+	mqttCert        string
+	mqttKey         string
+}
+
+// sinkStopTimeout bounds how long stopping waits for the sinks, which give
+// up on what they still hold after queue.DrainTimeout, to return.
+const sinkStopTimeout = queue.DrainTimeout + time.Second
+
+// sinkGroup is the sinks delivering in the background.
+type sinkGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newSinkGroup() *sinkGroup {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &sinkGroup{ctx: ctx, cancel: cancel}
+}
+
+// run delivers in the background with the Run of a sink.
+func (g *sinkGroup) run(run func(ctx context.Context) error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		run(g.ctx)
+	}()
+}
+
+// stop tells the sinks to stop and waits while they deliver the events they
+// still hold, so that the last ones before the monitor exits are not lost.
+func (g *sinkGroup) stop(stderr io.Writer) {
+	g.cancel()
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(sinkStopTimeout):
+		fmt.Fprintln(stderr, "Warning: exiting with events still being delivered")
+	}
+}
+
+// start sets up the chosen sinks, delivering in the background until the
+// returned group is stopped, and returns them for Watcher.Sinks. links looks
+// up the interfaces SNMP traps describe, stdout receives --events-json - and
+// delivery problems are reported on stderr.
+func (o sinkOptions) start(links config.NetlinkProvider, stdout, stderr io.Writer) (sinks []monitor.EventSink, group *sinkGroup, err error) {
+	group = newSinkGroup()
+	defer func() {
+		if err != nil {
+			group.stop(stderr)
+		}
+	}()
+	if o.webhookURL != "" {
+		hook := webhook.Hook{
+			URL:         o.webhookURL,
+			Secret:      os.Getenv(webhookSecretEnv),
+			Retries:     o.webhookRetries,
+			Policy:      backoff.Policy{Backoff: webhookBackoff, MaxBackoff: webhookMaxBackoff},
+			ContentType: o.webhookType,
+		}
+		if o.webhookTemplate != "" {
+			tmpl, err := loadWebhookTemplate(o.webhookTemplate)
+			if err != nil {
+				return nil, nil, err
+			}
+			hook.Template = tmpl
+		}
+		hookQueue := webhook.NewQueue(hook, webhookQueue)
+		hookQueue.Errors = stderr
+		group.run(hookQueue.Run)
+		host, _ := os.Hostname()
+		sinks = append(sinks, monitor.SinkFunc(func(event netevent.Event) { hookQueue.Send(webhookEvent{Host: host, Event: event}) }))
+	}
+	if o.syslogTarget != "" {
+		priority, err := logsink.Priority(o.syslogFacility, o.syslogSeverity)
+		if err != nil {
+			return nil, nil, err
+		}
+		sink, err := logsink.Dial(o.syslogTarget, priority, syslogQueue)
+		if err != nil {
+			return nil, nil, err
+		}
+		sink.Errors = stderr
+		group.run(sink.Run)
+		sinks = append(sinks, monitor.SinkFunc(func(event netevent.Event) { sink.Send(event.Message) }))
+	}
+	if o.journald {
+		severity, err := logsink.Severity(o.syslogSeverity)
+		if err != nil {
+			return nil, nil, err
+		}
+		journal, err := logsink.DialJournal(logsink.JournalSocket, syslogQueue)
+		if err != nil {
+			return nil, nil, err
+		}
+		journal.Errors = stderr
+		group.run(journal.Run)
+		sinks = append(sinks, monitor.SinkFunc(func(event netevent.Event) { journal.Send(journalFields(event, severity)) }))
+	}
+	if o.execLine != "" {
+		command := trigger.NewCommand(o.execLine, execQueue)
+		command.Concurrency = o.execConcurrency
+		command.Timeout = o.execTimeout
+		command.Output = stderr
+		command.Errors = stderr
+		group.run(command.Run)
+		sinks = append(sinks, monitor.SinkFunc(func(event netevent.Event) { command.Send(eventEnv(event)) }))
+	}
+	if o.historyPath != "" {
+		recorder, err := history.NewRecorder(o.historyPath, o.historySize, historyQueue)
+		if err != nil {
+			return nil, nil, err
+		}
+		recorder.Errors = stderr
+		group.run(recorder.Run)
+		sinks = append(sinks, changesOnly(recorder))
+	}
+	if o.jsonEvents != "" {
+		writer := stdout
+		if o.jsonEvents != "-" {
+			file, err := os.OpenFile(o.jsonEvents, os.O_WRONLY|os.O_APPEND|os.O_CREATE, jsonEventsMode)
+			if err != nil {
+				return nil, nil, err
+			}
+			writer = file
+		}
+		sinks = append(sinks, &monitor.JSONSink{Writer: writer, Errors: stderr})
+	}
+	if o.dbus {
+		conn, err := dbus.Dial(dbus.SystemBus)
+		if err != nil {
+			return nil, nil, err
+		}
+		emitter := dbus.NewEmitter(conn, dbusQueue)
+		emitter.Errors = stderr
+		group.run(emitter.Run)
+		sinks = append(sinks, monitor.SinkFunc(func(event netevent.Event) { emitter.Send(dbusSignal(event)) }))
+	}
+	if o.snmpTarget != "" {
+		engineID, err := hex.DecodeString(o.snmpEngineID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid --snmp-engine-id: %w", err)
+		}
+		sender, err := snmp.Dial(snmp.Config{
+			Target:       o.snmpTarget,
+			Version:      o.snmpVersion,
+			Community:    o.snmpCommunity,
+			User:         o.snmpUser,
+			Auth:         o.snmpAuth,
+			AuthPassword: os.Getenv(snmpAuthPasswordEnv),
+			Priv:         o.snmpPriv,
+			PrivPassword: os.Getenv(snmpPrivPasswordEnv),
+			EngineID:     engineID,
+			BootsFile:    o.snmpBootsFile,
+		}, snmpQueue)
+		if err != nil {
+			return nil, nil, err
+		}
+		sender.Errors = stderr
+		group.run(sender.Run)
+		sinks = append(sinks, monitor.SinkFunc(func(event netevent.Event) { sender.Send(snmpTrap(event, links)) }))
+	}
+	if o.mqttBroker != "" {
+		publisher, err := o.dialMQTT()
+		if err != nil {
+			return nil, nil, err
+		}
+		publisher.Errors = stderr
+		group.run(publisher.Run)
+		host, _ := os.Hostname()
+		sinks = append(sinks, monitor.SinkFunc(func(event netevent.Event) {
+			payload, err := json.Marshal(webhookEvent{Host: host, Event: event})
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				return
+			}
+			// Heartbeats concern no interface and go to TOPIC/heartbeat.
+			level := event.Interface
+			if level == "" {
+				level = event.Kind
+			}
+			publisher.Send(mqtt.Message{Topic: o.mqttTopic + "/" + mqtt.TopicLevel(level), Payload: payload})
+		}))
+	}
+	if o.metricsFile != "" {
+		metrics := monitor.NewMetricsSink(o.metricsFile)
+		metrics.Errors = stderr
+		group.run(metrics.Run)
+		sinks = append(sinks, changesOnly(metrics))
+	}
+	return sinks, group, nil
+}
+
+// changesOnly keeps heartbeats from sink, which records or counts changes.
+func changesOnly(sink monitor.EventSink) monitor.EventSink {
+	return monitor.SinkFunc(func(event netevent.Event) {
+		if event.Kind != netevent.Heartbeat {
+			sink.Send(event)
+		}
+	})
+}
+
+// The D-Bus signal monitor --dbus emits for each event, and how many wait
+// while the bus is slow.
+const (
+	dbusPath      = "/org/goeth/Monitor"
+	dbusInterface = "org.goeth.Monitor1"
+	dbusMember    = "Changed"
+	dbusQueue     = 256
+)
+
+// dbusSignal describes event as a signal with the arguments kind, type,
+// interface, old, new and message.
+func dbusSignal(event netevent.Event) dbus.Signal {
+	return dbus.Signal{
+		Path:      dbusPath,
+		Interface: dbusInterface,
+		Member:    dbusMember,
+		Args:      []string{event.Kind, event.Type, event.Interface, event.Old, event.New, event.Message},
+	}
+}
+
+// snmpAuthPasswordEnv and snmpPrivPasswordEnv carry the SNMPv3 pass phrases
+// of monitor --snmp-trap, keeping them out of the process list.
+const (
+	snmpAuthPasswordEnv = "GOETH_SNMP_AUTH_PASSWORD"
+	snmpPrivPasswordEnv = "GOETH_SNMP_PRIV_PASSWORD"
+)
+
+// snmpQueue is how many traps wait while the receiver is unreachable.
+const snmpQueue = 256
+
+// The notifications monitor --snmp-trap sends. Link state changes are the
+// IF-MIB linkDown and linkUp; every other change is goethChange. goeth has
+// no enterprise number, so goethChange and its objects sit in the Net-SNMP
+// playpen meant for local use.
+const (
+	snmpLinkDown      snmp.OID = "1.3.6.1.6.3.1.1.5.3"
+	snmpLinkUp        snmp.OID = "1.3.6.1.6.3.1.1.5.4"
+	snmpIfIndex       snmp.OID = "1.3.6.1.2.1.2.2.1.1"
+	snmpIfAdminStatus snmp.OID = "1.3.6.1.2.1.2.2.1.7"
+	snmpIfOperStatus  snmp.OID = "1.3.6.1.2.1.2.2.1.8"
+	snmpEnterprise    snmp.OID = "1.3.6.1.4.1.8072.9999.9999"
+	snmpChange                 = snmpEnterprise + ".0.1"
+	snmpEventObjects           = snmpEnterprise + ".1"
+)
+
+// ifStatuses numbers the operational states as ifOperStatus and
+// ifAdminStatus do; states missing here are unknown.
+var ifStatuses = map[string]int{"up": 1, "down": 2, "testing": 3, "unknown": 4, "dormant": 5, "not-present": 6, "lower-layer-down": 7}
+
+// snmpTrap describes event as a notification. A link going up or down is
+// reported as linkUp or linkDown with the IF-MIB objects of the interface,
+// unless it is already gone. The kind, type, interface, old and new state
+// and message of every event follow as strings, in objects 1 to 6 under
+// snmpEventObjects.
+func snmpTrap(event netevent.Event, links config.NetlinkProvider) snmp.Trap {
+	trap := snmp.Trap{OID: snmpChange}
+	if event.Type == "link_up" || event.Type == "link_down" {
+		if link, err := links.LinkByName(event.Interface); err == nil {
+			attrs := link.Attrs()
+			admin := ifStatuses["down"]
+			if attrs.Flags&net.FlagUp != 0 {
+				admin = ifStatuses["up"]
+			}
+			oper, ok := ifStatuses[event.New]
+			if !ok {
+				oper = ifStatuses["unknown"]
+			}
+			trap.OID = snmpLinkDown
+			if event.Type == "link_up" {
+				trap.OID = snmpLinkUp
+			}
+			index := snmp.OID("." + strconv.Itoa(attrs.Index))
+			trap.VarBinds = []snmp.VarBind{
+				{Name: snmpIfIndex + index, Value: attrs.Index},
+				{Name: snmpIfAdminStatus + index, Value: admin},
+				{Name: snmpIfOperStatus + index, Value: oper},
+			}
+		}
+	}
+	for i, value := range []string{event.Kind, event.Type, event.Interface, event.Old, event.New, event.Message} {
+		trap.VarBinds = append(trap.VarBinds, snmp.VarBind{Name: snmpEventObjects + snmp.OID(fmt.Sprintf(".%d.0", i+1)), Value: value})
+	}
+	return trap
+}
+
+// mqttPasswordEnv carries the password of monitor --mqtt, keeping it out of
+// the process list.
+const mqttPasswordEnv = "GOETH_MQTT_PASSWORD"
+
+// mqttQueue is how many events wait while the broker is slow or
+// unreachable.
+const mqttQueue = 256
+
+// dialMQTT connects to the --mqtt broker as goeth-HOSTNAME unless another
+// client ID is given.
+func (o sinkOptions) dialMQTT() (*mqtt.Publisher, error) {
+	if o.mqttQoS < 0 || o.mqttQoS > 2 {
+		return nil, fmt.Errorf("--mqtt-qos must be 0, 1 or 2, not %d", o.mqttQoS)
+	}
+	clientID := o.mqttClientID
+	if clientID == "" {
+		host, _ := os.Hostname()
+		clientID = "goeth-" + host
+	}
+	options := mqtt.Options{
+		Broker:   o.mqttBroker,
+		ClientID: clientID,
+		Username: o.mqttUser,
+		Password: os.Getenv(mqttPasswordEnv),
+		QoS:      byte(o.mqttQoS),
+		Retain:   o.mqttRetain,
+	}
+	if o.mqttCA != "" || o.mqttCert != "" || o.mqttKey != "" {
+		config, err := mqtt.LoadTLS(o.mqttCA, o.mqttCert, o.mqttKey)
+		if err != nil {
+			return nil, err
+		}
+		options.TLS = config
+	}
+	return mqtt.Dial(options, mqttQueue)
+}
+
+// jsonEventsMode is the permission of a new --events-json file.
+const jsonEventsMode = 0o644
+
+// webhookSecretEnv carries the secret monitor --webhook signs events with,
+// keeping it out of the process list.
+const webhookSecretEnv = "GOETH_WEBHOOK_SECRET"
+
+// Webhook delivery: how many events wait while the endpoint is slow, and the
+// backoff between retries.
+const (
+	webhookQueue      = 256
+	webhookBackoff    = time.Second
+	webhookMaxBackoff = 30 * time.Second
+)
+
+// webhookEvent is the JSON document posted for each monitor event.
+type webhookEvent struct {
+	Host string `json:"host,omitempty"`
+	netevent.Event
+}
+
+// loadWebhookTemplate parses the --webhook-template file and tries it on an
+// empty event, so that a misspelt field fails at start rather than on the
+// first change.
+func loadWebhookTemplate(path string) (*template.Template, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("webhook template: %w", err)
+	}
+	tmpl, err := webhook.ParseTemplate(filepath.Base(path), string(text))
+	if err == nil {
+		err = tmpl.Execute(io.Discard, webhookEvent{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("webhook template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// syslogQueue is how many events wait while the syslog server or journald
+// is slow.
+const syslogQueue = 256
+
+// journalFields describes event to journald, so that journalctl -o json
+// yields queryable records.
+func journalFields(event netevent.Event, severity syslog.Priority) []logsink.Field {
+	return []logsink.Field{
+		{Name: "MESSAGE", Value: event.Message},
+		{Name: "PRIORITY", Value: strconv.Itoa(int(severity))},
+		{Name: "SYSLOG_IDENTIFIER", Value: logsink.Tag},
+		{Name: "IFACE", Value: event.Interface},
+		{Name: "EVENT_KIND", Value: event.Kind},
+		{Name: "EVENT_TYPE", Value: event.Type},
+		{Name: "OLD", Value: event.Old},
+		{Name: "NEW", Value: event.New},
+	}
+}
+
+// execQueue is how many events wait while every run of monitor --exec is
+// busy.
+const execQueue = 256
+
+// defaultHistoryPath is where monitor --history keeps events and goeth
+// events reads them when no path is given.
+const defaultHistoryPath = "/var/lib/goeth/events.jsonl"
+
+// defaultSNMPBootsPath is where monitor --snmp-trap counts the boots of its
+// SNMPv3 engine.
+const defaultSNMPBootsPath = "/var/lib/goeth/snmp-engine-boots"
+
+// historyQueue is how many events wait while the history file is written.
+const historyQueue = 256
+
+// eventEnv describes event to a monitor --exec command.
+func eventEnv(event netevent.Event) []string {
+	return []string{
+		"GOETH_EVENT_TIME=" + event.Time.Format(time.RFC3339),
+		"GOETH_EVENT_KIND=" + event.Kind,
+		"GOETH_EVENT_TYPE=" + event.Type,
+		"GOETH_EVENT_INTERFACE=" + event.Interface,
+		"GOETH_EVENT_OLD=" + event.Old,
+		"GOETH_EVENT_NEW=" + event.New,
+		"GOETH_EVENT_MESSAGE=" + event.Message,
+	}
+}
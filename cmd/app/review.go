@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/user/goeth/internal/config"
+	"github.com/user/goeth/internal/i18n"
+	"github.com/user/goeth/internal/output"
+	"github.com/user/goeth/internal/snapshot"
+)
+
+var reviewHelp = []string{
+	"y - apply this change\n",
+	"n - skip this change\n",
+	"a - apply this and all remaining changes\n",
+	"q - skip this and all remaining changes\n",
+}
+
+// stepChange tells apart the plan steps that add, remove or update
+// something by their verb.
+func stepChange(step string) output.Change {
+	verb, _, _ := strings.Cut(step, " ")
+	switch verb {
+	case "add", "create", "attach":
+		return output.Added
+	case "remove", "delete", "detach", "release":
+		return output.Removed
+	}
+	return output.Updated
+}
+
+var errPlanChanged = errors.New("the system changed since the plan was reviewed; run the review again")
+
+// applyInteractive computes the plan for cfg against the current state, lets
+// the operator accept or skip each change and then applies the accepted ones.
+func applyInteractive(in io.Reader, out io.Writer, messages i18n.Printer, colors output.Colorizer, cfg config.Configuration, provider config.NetlinkProvider) error {
+	state, err := snapshot.Capture(provider)
+	if err != nil {
+		return err
+	}
+	var changes [][]string
+	sim := snapshot.NewSimulator(state)
+	if sysctls, ok := provider.(config.SysctlProvider); ok {
+		sim.ReadSysctls(sysctls)
+	}
+	if qdiscs, ok := provider.(config.QdiscProvider); ok {
+		sim.ReadQdiscs(qdiscs)
+	}
+	if offloads, ok := provider.(config.OffloadProvider); ok {
+		sim.ReadOffloads(offloads)
+	}
+	if rings, ok := provider.(config.RingProvider); ok {
+		sim.ReadRings(rings)
+	}
+	if coalesce, ok := provider.(config.CoalesceProvider); ok {
+		sim.ReadCoalesce(coalesce)
+	}
+	if policy, ok := provider.(config.RuleProvider); ok {
+		sim.ReadPolicy(policy)
+	}
+	if programs, ok := provider.(config.XDPProvider); ok {
+		sim.ReadPrograms(programs)
+	}
+	review := snapshot.NewGate(sim, state, func(steps []string) (bool, error) {
+		changes = append(changes, steps)
+		return true, nil
+	})
+	if err := config.NewApplier(config.NewNetlinkExecutor(review)).Apply(cfg); err != nil {
+		return err
+	}
+	for _, note := range review.Notes() {
+		messages.Fprintf(out, "Left unchanged: %s\n", note)
+	}
+	if len(changes) == 0 {
+		messages.Fprintf(out, "No changes for %s\n", cfg.Interface)
+		return nil
+	}
+	accepted := reviewChanges(in, out, messages, colors, changes)
+	count := 0
+	for _, ok := range accepted {
+		if ok {
+			count++
+		}
+	}
+	if count == 0 {
+		messages.Fprintf(out, "No changes applied to %s\n", cfg.Interface)
+		return nil
+	}
+	next := 0
+	gate := snapshot.NewGate(provider, state, func(steps []string) (bool, error) {
+		if next >= len(changes) || !slices.Equal(steps, changes[next]) {
+			return false, fmt.Errorf("%w (unexpected step %q)", errPlanChanged, steps[0])
+		}
+		next++
+		return accepted[next-1], nil
+	})
+	if err := config.NewApplier(config.NewNetlinkExecutor(gate)).Apply(cfg); err != nil {
+		return err
+	}
+	messages.Fprintf(out, "Applied %d of %d changes to %s\n", count, len(changes), cfg.Interface)
+	return nil
+}
+
+// reviewChanges asks about each change in turn, in the style of git add -p.
+// Running out of input skips the remaining changes.
+func reviewChanges(in io.Reader, out io.Writer, messages i18n.Printer, colors output.Colorizer, changes [][]string) []bool {
+	accepted := make([]bool, len(changes))
+	scanner := bufio.NewScanner(in)
+	for i, steps := range changes {
+		painted := make([]string, len(steps))
+		for j, step := range steps {
+			painted[j] = colors.Paint(stepChange(step), step)
+		}
+		fmt.Fprintf(out, "[%d/%d] %s\n", i+1, len(changes), strings.Join(painted, "\n      "))
+		for {
+			messages.Fprintf(out, "Apply this change [y,n,a,q,?]? ")
+			if !scanner.Scan() {
+				fmt.Fprintln(out)
+				return accepted
+			}
+			switch strings.TrimSpace(scanner.Text()) {
+			case "y":
+				accepted[i] = true
+			case "n":
+			case "a":
+				for j := i; j < len(changes); j++ {
+					accepted[j] = true
+				}
+				return accepted
+			case "q":
+				return accepted
+			default:
+				for _, line := range reviewHelp {
+					messages.Fprintf(out, line)
+				}
+				continue
+			}
+			break
+		}
+	}
+	return accepted
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/user/goeth/internal/i18n"
+	"github.com/user/goeth/internal/output"
+)
+
+func TestStepChange(t *testing.T) {
+	tests := []struct {
+		step string
+		want output.Change
+	}{
+		{"add address 192.0.2.10/24 to eth0", output.Added},
+		{"create vlan eth0.100", output.Added},
+		{"attach xdp program to eth0", output.Added},
+		{"remove address 192.0.2.20/24 from eth0", output.Removed},
+		{"delete route 198.51.100.0/24", output.Removed},
+		{"detach xdp program from eth0", output.Removed},
+		{"release dhcp lease on eth0", output.Removed},
+		{"set mtu 9000 on eth0", output.Updated},
+		{"", output.Updated},
+	}
+	for _, tt := range tests {
+		if got := stepChange(tt.step); got != tt.want {
+			t.Errorf("stepChange(%q) = %v, want %v", tt.step, got, tt.want)
+		}
+	}
+}
+
+func TestReviewChanges(t *testing.T) {
+	changes := [][]string{
+		{"add address 192.0.2.10/24 to eth0"},
+		{"remove address 192.0.2.20/24 from eth0", "add address 192.0.2.21/24 to eth0"},
+		{"set mtu 9000 on eth0"},
+	}
+	tests := []struct {
+		name   string
+		input  string
+		colors output.Colorizer
+		want   []bool
+		// shown must appear in the output.
+		shown []string
+	}{
+		{name: "answers each change", input: "y\nn\ny\n", want: []bool{true, false, true}, shown: []string{"[1/3]", "[3/3] set mtu 9000 on eth0"}},
+		{name: "all takes the remaining changes", input: "n\na\n", want: []bool{false, true, true}},
+		{name: "quit skips the remaining changes", input: "y\nq\n", want: []bool{true, false, false}},
+		{name: "running out of input skips the rest", input: "y\n", want: []bool{true, false, false}},
+		{name: "help asks again", input: "?\ny\nn\nn\n", want: []bool{true, false, false}, shown: []string{"a - apply this and all remaining changes"}},
+		{name: "answers are trimmed", input: " y \nn\nn\n", want: []bool{true, false, false}},
+		{name: "colored steps", input: "q\n", colors: output.Colorizer{Enabled: true}, want: []bool{false, false, false}, shown: []string{"[1/3] \x1b[32madd address 192.0.2.10/24 to eth0\x1b[0m"}},
+		{name: "steps of one change share a prompt", input: "n\nq\n", want: []bool{false, false, false}, shown: []string{"[2/3] remove address 192.0.2.20/24 from eth0\n      add address 192.0.2.21/24 to eth0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			got := reviewChanges(strings.NewReader(tt.input), &out, i18n.Printer{}, tt.colors, changes)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reviewChanges() = %v, want %v", got, tt.want)
+			}
+			for _, text := range tt.shown {
+				if !strings.Contains(out.String(), text) {
+					t.Errorf("output lacks %q:\n%s", text, out.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/user/goeth/internal/addresses"
+	"github.com/user/goeth/internal/cache"
+	"github.com/user/goeth/internal/interfaces"
+	"github.com/user/goeth/internal/readiness"
+)
+
+// waitPollInterval is how often wait checks when netlink notifications
+// cannot be subscribed to.
+const waitPollInterval = 250 * time.Millisecond
+
+func newWaitCmd(sys *system) *cobra.Command {
+	var waiter readiness.Waiter
+	var hasCarrier, hasAddress, hasGlobalIPv6 bool
+	cmd := &cobra.Command{
+		Use:   "wait",
+		Short: "Block until an interface exists and meets the given conditions",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, err := sys.lister.Resolve(waiter.Interface)
+			if err != nil {
+				return err
+			}
+			waiter.Interface = name
+			if hasCarrier {
+				waiter.Conditions = append(waiter.Conditions, readiness.HasCarrier)
+			}
+			if hasAddress {
+				waiter.Conditions = append(waiter.Conditions, readiness.HasAddress)
+			}
+			if hasGlobalIPv6 {
+				waiter.Conditions = append(waiter.Conditions, readiness.HasGlobalIPv6)
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			waiter.Lister, waiter.Viewer, waiter.Interval = sys.lister, sys.viewer, waitPollInterval
+
+			shared := cache.New(sys.updates)
+			subscribed, cancel := context.WithCancel(ctx)
+			defer cancel()
+			cacheErr := make(chan error, 1)
+			go func() {
+				cacheErr <- shared.Run(subscribed)
+				cancel()
+			}()
+			if err := shared.Wait(subscribed); err != nil {
+				cause := <-cacheErr
+				if !errors.Is(cause, cache.ErrSubscribe) {
+					return cacheFailure(cause, err)
+				}
+				return waiter.Wait(ctx)
+			}
+			waiter.Lister = interfaces.NewLister(shared)
+			waiter.Viewer = addresses.NewViewer(shared)
+			waiter.Changes = shared.Changes()
+			err = waiter.Wait(subscribed)
+			select {
+			case cause := <-cacheErr:
+				return cacheFailure(cause, err)
+			default:
+				return err
+			}
+		},
+	}
+	cmd.Flags().StringVarP(&waiter.Interface, "interface", "i", "", "Interface to wait for, by name or index")
+	cmd.Flags().BoolVar(&hasCarrier, "has-carrier", false, "Wait until the link has a carrier")
+	cmd.Flags().BoolVar(&hasAddress, "has-address", false, "Wait until the interface has an address other than an IPv6 link-local one")
+	cmd.Flags().BoolVar(&hasGlobalIPv6, "has-global-ipv6", false, "Wait until the interface has a global IPv6 address")
+	cmd.Flags().DurationVar(&waiter.Timeout, "timeout", 0, "Give up with an error after this long (e.g. 60s); 0 waits indefinitely")
+	cmd.MarkFlagRequired("interface")
+	return cmd
+}
+
+// cacheFailure prefers the error that stopped the cache over err, the
+// cancellation it caused.
+func cacheFailure(cause, err error) error {
+	if cause != nil && !errors.Is(cause, context.Canceled) {
+		return cause
+	}
+	return err
+}
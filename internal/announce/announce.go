@@ -0,0 +1,167 @@
+// Package announce tells the neighbors of a link that an address moved to
+// it: with a gratuitous ARP for IPv4 and an unsolicited Neighbor
+// Advertisement for IPv6, so switches and peers update their caches without
+// waiting for stale entries to expire. Both are sent through a packet
+// socket, so the address does not have to be bindable yet. A Prober asks
+// the link the other way round whether another host holds an address.
+package announce
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/user/goeth/internal/checksum"
+)
+
+const (
+	// DefaultCount is used when Announcer.Count is zero.
+	DefaultCount = 3
+	// DefaultInterval is used when Announcer.Interval is zero.
+	DefaultInterval = time.Second
+
+	etherAddrLen = 6
+
+	arpHardwareEther = 1
+	arpRequest       = 1
+	arpLen           = 28
+
+	ipv6HeaderLen   = 40
+	ipv6Version     = 6
+	icmpv6Protocol  = unix.IPPROTO_ICMPV6
+	ndHopLimit      = 255
+	icmpv6NA        = 136
+	naOverride      = 0x20
+	naLen           = 24
+	optTargetLLAddr = 2
+	// optLen is the length of a link-layer address option in units of 8
+	// bytes, as Neighbor Discovery counts it.
+	optLen = 1
+)
+
+var (
+	broadcast = [etherAddrLen]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	// allNodes is ff02::1 and allNodesMAC the Ethernet multicast address it
+	// maps to.
+	allNodes    = net.IPv6linklocalallnodes
+	allNodesMAC = [etherAddrLen]byte{0x33, 0x33, 0x00, 0x00, 0x00, 0x01}
+)
+
+// Announcer sends the announcements for addresses of a network device.
+type Announcer struct {
+	// Count is how many times each address is announced, as a single
+	// packet may be lost; zero means DefaultCount.
+	Count int
+	// Interval separates the rounds; zero means DefaultInterval.
+	Interval time.Duration
+}
+
+// Announce announces ips on device from its current hardware address.
+func (a Announcer) Announce(device string, ips []net.IP) error {
+	iface, err := net.InterfaceByName(device)
+	if err != nil {
+		return fmt.Errorf("look up %s: %w", device, err)
+	}
+	if len(iface.HardwareAddr) != etherAddrLen {
+		return fmt.Errorf("%s has no ethernet address to announce from", device)
+	}
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("open packet socket: %w", err)
+	}
+	defer unix.Close(fd)
+	count, interval := a.Count, a.Interval
+	if count <= 0 {
+		count = DefaultCount
+	}
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	for round := 0; round < count; round++ {
+		if round > 0 {
+			time.Sleep(interval)
+		}
+		for _, ip := range ips {
+			if err := send(fd, iface, ip); err != nil {
+				return fmt.Errorf("announce %s on %s: %w", ip, device, err)
+			}
+		}
+	}
+	return nil
+}
+
+func send(fd int, iface *net.Interface, ip net.IP) error {
+	to := &unix.SockaddrLinklayer{Ifindex: iface.Index, Halen: etherAddrLen}
+	var packet []byte
+	if ip4 := ip.To4(); ip4 != nil {
+		to.Protocol = htons(unix.ETH_P_ARP)
+		copy(to.Addr[:], broadcast[:])
+		packet = gratuitousARP(iface.HardwareAddr, ip4)
+	} else {
+		to.Protocol = htons(unix.ETH_P_IPV6)
+		copy(to.Addr[:], allNodesMAC[:])
+		packet = unsolicitedNA(iface.HardwareAddr, ip.To16())
+	}
+	return unix.Sendto(fd, packet, 0, to)
+}
+
+// gratuitousARP builds an ARP announcement (RFC 5227): a request whose
+// sender and target are both ip, which neighbors use to refresh the entry
+// they hold for it.
+func gratuitousARP(hw net.HardwareAddr, ip net.IP) []byte {
+	return arpRequestFrom(hw, ip, ip)
+}
+
+// arpRequestFrom builds an ARP request for target from sender at hw.
+func arpRequestFrom(hw net.HardwareAddr, sender, target net.IP) []byte {
+	b := make([]byte, arpLen)
+	binary.BigEndian.PutUint16(b[0:2], arpHardwareEther)
+	binary.BigEndian.PutUint16(b[2:4], unix.ETH_P_IP)
+	b[4], b[5] = etherAddrLen, net.IPv4len
+	binary.BigEndian.PutUint16(b[6:8], arpRequest)
+	copy(b[8:14], hw)
+	copy(b[14:18], sender)
+	copy(b[24:28], target)
+	return b
+}
+
+// unsolicitedNA builds an IPv6 packet carrying a Neighbor Advertisement of
+// ip to all nodes, with the override flag set so that caches take hw
+// (RFC 4861 section 7.2.6).
+func unsolicitedNA(hw net.HardwareAddr, ip net.IP) []byte {
+	payloadLen := naLen + 8*optLen
+	b := make([]byte, ipv6HeaderLen+payloadLen)
+	b[0] = ipv6Version << 4
+	binary.BigEndian.PutUint16(b[4:6], uint16(payloadLen))
+	b[6], b[7] = icmpv6Protocol, ndHopLimit
+	copy(b[8:24], ip)
+	copy(b[24:40], allNodes)
+	msg := b[ipv6HeaderLen:]
+	msg[0] = icmpv6NA
+	msg[4] = naOverride
+	copy(msg[8:24], ip)
+	msg[24], msg[25] = optTargetLLAddr, optLen
+	copy(msg[26:32], hw)
+	binary.BigEndian.PutUint16(msg[2:4], icmpv6Checksum(ip, allNodes, msg))
+	return b
+}
+
+// icmpv6Checksum computes the checksum of msg over the IPv6 pseudo-header
+// (RFC 8200 section 8.1).
+func icmpv6Checksum(src, dst net.IP, msg []byte) uint16 {
+	pseudo := make([]byte, 0, 2*net.IPv6len+8+len(msg))
+	pseudo = append(pseudo, src.To16()...)
+	pseudo = append(pseudo, dst.To16()...)
+	pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(len(msg)))
+	pseudo = append(pseudo, 0, 0, 0, icmpv6Protocol)
+	return checksum.Internet(append(pseudo, msg...))
+}
+
+// htons converts a protocol number to the network byte order sockaddr_ll
+// takes it in.
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
@@ -0,0 +1,82 @@
+package announce
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+var testMAC = net.HardwareAddr{0x02, 0x00, 0x5e, 0x00, 0x53, 0x01}
+
+func TestGratuitousARPAnnouncesItself(t *testing.T) {
+	ip := net.ParseIP("192.0.2.10").To4()
+	b := gratuitousARP(testMAC, ip)
+	if len(b) != arpLen || binary.BigEndian.Uint16(b[6:8]) != arpRequest {
+		t.Fatalf("unexpected arp packet: %v", b)
+	}
+	if !bytes.Equal(b[8:14], testMAC) {
+		t.Fatalf("sender hardware address = %v, want %v", net.HardwareAddr(b[8:14]), testMAC)
+	}
+	if !net.IP(b[14:18]).Equal(ip) || !net.IP(b[24:28]).Equal(ip) {
+		t.Fatalf("sender %v and target %v must both be %v", net.IP(b[14:18]), net.IP(b[24:28]), ip)
+	}
+	if !bytes.Equal(b[18:24], make([]byte, etherAddrLen)) {
+		t.Fatalf("target hardware address = %v, want zero", net.HardwareAddr(b[18:24]))
+	}
+}
+
+func TestUnsolicitedNAIsValidNeighborDiscovery(t *testing.T) {
+	ip := net.ParseIP("2001:db8::10")
+	b := unsolicitedNA(testMAC, ip)
+	if b[0]>>4 != ipv6Version || b[6] != icmpv6Protocol || b[7] != ndHopLimit {
+		t.Fatalf("unexpected ipv6 header: %v", b[:ipv6HeaderLen])
+	}
+	if !net.IP(b[8:24]).Equal(ip) || !net.IP(b[24:40]).Equal(allNodes) {
+		t.Fatalf("packet from %v to %v, want from %v to all nodes", net.IP(b[8:24]), net.IP(b[24:40]), ip)
+	}
+	msg := b[ipv6HeaderLen:]
+	if int(binary.BigEndian.Uint16(b[4:6])) != len(msg) {
+		t.Fatalf("payload length %d, want %d", binary.BigEndian.Uint16(b[4:6]), len(msg))
+	}
+	if msg[0] != icmpv6NA || msg[4] != naOverride || !net.IP(msg[8:24]).Equal(ip) {
+		t.Fatalf("unexpected advertisement: %v", msg)
+	}
+	if msg[24] != optTargetLLAddr || !bytes.Equal(msg[26:32], testMAC) {
+		t.Fatalf("unexpected target link-layer option: %v", msg[24:])
+	}
+	if icmpv6Checksum(ip, allNodes, msg) != 0 {
+		t.Fatal("checksum over a valid message must be zero")
+	}
+}
+
+func TestAnnounceRejectsUnknownDevice(t *testing.T) {
+	if err := (Announcer{}).Announce("goeth-missing0", []net.IP{net.ParseIP("192.0.2.10")}); err == nil {
+		t.Fatal("expected an error for a device that does not exist")
+	}
+}
+
+func TestARPProbeCarriesNoSenderAddress(t *testing.T) {
+	ip := net.ParseIP("192.0.2.10").To4()
+	b := arpProbe(testMAC, ip)
+	if !bytes.Equal(b[14:18], make([]byte, net.IPv4len)) || !net.IP(b[24:28]).Equal(ip) {
+		t.Fatalf("probe from %v for %v, want from 0.0.0.0 for %v", net.IP(b[14:18]), net.IP(b[24:28]), ip)
+	}
+}
+
+func TestParseARPReturnsTheSender(t *testing.T) {
+	ip := net.ParseIP("192.0.2.10").To4()
+	reply := gratuitousARP(testMAC, ip)
+	binary.BigEndian.PutUint16(reply[6:8], arpReply)
+	hw, sender, ok := parseARP(reply)
+	if !ok || !bytes.Equal(hw, testMAC) || !sender.Equal(ip) {
+		t.Fatalf("parseARP() = %v, %v, %v", hw, sender, ok)
+	}
+	if _, _, ok := parseARP(reply[:arpLen-1]); ok {
+		t.Fatal("parseARP() accepted a truncated packet")
+	}
+	binary.BigEndian.PutUint16(reply[6:8], 3)
+	if _, _, ok := parseARP(reply); ok {
+		t.Fatal("parseARP() accepted a RARP request")
+	}
+}
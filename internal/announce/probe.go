@@ -0,0 +1,176 @@
+package announce
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// DefaultWait is used when Prober.Wait is zero, the PROBE_WAIT of
+	// RFC 5227.
+	DefaultWait = time.Second
+
+	arpReply = 2
+)
+
+// Claim is another host answering for an address of a device.
+type Claim struct {
+	Device       string
+	IP           net.IP
+	HardwareAddr net.HardwareAddr
+}
+
+// Prober looks for other hosts using the IPv4 addresses of this one by
+// sending ARP probes (RFC 5227) for them, which only a host holding an
+// address answers.
+type Prober struct {
+	// Wait is how long answers are waited for; zero means DefaultWait.
+	Wait time.Duration
+}
+
+// Probe probes the addresses of each device that ips is keyed by at once,
+// and returns the other hosts that answered within Wait, by device and
+// address. Devices without an Ethernet address, such as the loopback, and
+// IPv6 addresses, which the kernel runs Duplicate Address Detection for,
+// are skipped.
+func (p Prober) Probe(ips map[string][]net.IP) ([]Claim, error) {
+	wait := p.Wait
+	if wait <= 0 {
+		wait = DefaultWait
+	}
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, int(htons(unix.ETH_P_ARP)))
+	if err != nil {
+		return nil, fmt.Errorf("open packet socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	probed := make(map[int]*probedDevice)
+	for device, list := range ips {
+		iface, err := net.InterfaceByName(device)
+		if err != nil {
+			return nil, fmt.Errorf("look up %s: %w", device, err)
+		}
+		if len(iface.HardwareAddr) != etherAddrLen {
+			continue
+		}
+		to := &unix.SockaddrLinklayer{Ifindex: iface.Index, Halen: etherAddrLen, Protocol: htons(unix.ETH_P_ARP)}
+		copy(to.Addr[:], broadcast[:])
+		target := &probedDevice{iface: iface}
+		for _, ip := range list {
+			ip4 := ip.To4()
+			if ip4 == nil {
+				continue
+			}
+			if err := unix.Sendto(fd, arpProbe(iface.HardwareAddr, ip4), 0, to); err != nil {
+				return nil, fmt.Errorf("probe %s on %s: %w", ip, device, err)
+			}
+			target.ips = append(target.ips, ip4)
+		}
+		probed[iface.Index] = target
+	}
+	if len(probed) == 0 {
+		return nil, nil
+	}
+
+	var claims []Claim
+	seen := make(map[string]bool)
+	buf := make([]byte, arpLen)
+	for deadline := time.Now().Add(wait); ; {
+		left := time.Until(deadline)
+		if left <= 0 {
+			break
+		}
+		tv := unix.NsecToTimeval(left.Nanoseconds())
+		if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+			return nil, fmt.Errorf("configure packet socket: %w", err)
+		}
+		n, from, err := unix.Recvfrom(fd, buf, 0)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if errors.Is(err, unix.EAGAIN) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read ARP answers: %w", err)
+		}
+		ll, ok := from.(*unix.SockaddrLinklayer)
+		if !ok || ll.Pkttype == unix.PACKET_OUTGOING {
+			continue
+		}
+		target := probed[ll.Ifindex]
+		if target == nil {
+			continue
+		}
+		hw, ip, ok := parseARP(buf[:n])
+		if !ok || hw.String() == target.iface.HardwareAddr.String() || !target.probes(ip) {
+			continue
+		}
+		key := target.iface.Name + " " + ip.String() + " " + hw.String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		claims = append(claims, Claim{Device: target.iface.Name, IP: ip, HardwareAddr: hw})
+	}
+	sort.Slice(claims, func(i, j int) bool {
+		if claims[i].Device != claims[j].Device {
+			return claims[i].Device < claims[j].Device
+		}
+		return claims[i].IP.String() < claims[j].IP.String()
+	})
+	return claims, nil
+}
+
+// Reachable reports whether ip answers an ARP probe on device, which tells
+// whether a neighbor such as a gateway is there without needing it to
+// answer pings.
+func (p Prober) Reachable(device string, ip net.IP) (bool, error) {
+	if ip.To4() == nil {
+		return false, fmt.Errorf("probe target %s is not an IPv4 address", ip)
+	}
+	claims, err := p.Probe(map[string][]net.IP{device: {ip}})
+	return len(claims) > 0, err
+}
+
+// probedDevice is a device that Probe sent probes on, and for which
+// addresses.
+type probedDevice struct {
+	iface *net.Interface
+	ips   []net.IP
+}
+
+func (d *probedDevice) probes(ip net.IP) bool {
+	for _, probed := range d.ips {
+		if probed.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// arpProbe builds an ARP probe (RFC 5227): a request for ip from no sender
+// address, so that neighbors do not take it as a claim, which the holder of
+// ip answers.
+func arpProbe(hw net.HardwareAddr, ip net.IP) []byte {
+	return arpRequestFrom(hw, net.IPv4zero.To4(), ip)
+}
+
+// parseARP returns the sender of an ARP request or reply over Ethernet for
+// IPv4, which is who claims the address.
+func parseARP(b []byte) (net.HardwareAddr, net.IP, bool) {
+	if len(b) < arpLen || binary.BigEndian.Uint16(b[0:2]) != arpHardwareEther || binary.BigEndian.Uint16(b[2:4]) != unix.ETH_P_IP ||
+		b[4] != etherAddrLen || b[5] != net.IPv4len {
+		return nil, nil, false
+	}
+	if op := binary.BigEndian.Uint16(b[6:8]); op != arpRequest && op != arpReply {
+		return nil, nil, false
+	}
+	return net.HardwareAddr(append([]byte(nil), b[8:14]...)), net.IP(append([]byte(nil), b[14:18]...)), true
+}
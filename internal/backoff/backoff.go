@@ -0,0 +1,37 @@
+// Package backoff spaces out the attempts of an operation that may succeed
+// when tried again, such as a netlink request or a webhook delivery.
+package backoff
+
+import (
+	"context"
+	"time"
+)
+
+// Policy is how long to wait between attempts.
+type Policy struct {
+	// Backoff is the wait before the first retry. It doubles with each
+	// further one, up to MaxBackoff when that is set.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Next returns the wait after one of d.
+func (p Policy) Next(d time.Duration) time.Duration {
+	d *= 2
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// Wait sleeps for d or until ctx is done.
+func Wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
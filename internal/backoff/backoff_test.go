@@ -0,0 +1,38 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNextDoublesUpToTheMaximum(t *testing.T) {
+	tests := []struct {
+		policy Policy
+		waits  []time.Duration
+	}{
+		{Policy{Backoff: time.Second}, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}},
+		{Policy{Backoff: time.Second, MaxBackoff: 3 * time.Second}, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}},
+	}
+	for _, tt := range tests {
+		d := tt.policy.Backoff
+		for i, want := range tt.waits {
+			if d != want {
+				t.Errorf("%+v: wait %d = %s, want %s", tt.policy, i+1, d, want)
+			}
+			d = tt.policy.Next(d)
+		}
+	}
+}
+
+func TestWaitEndsWithTheContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Wait(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait() error = %v, want %v", err, context.Canceled)
+	}
+	if err := Wait(context.Background(), time.Millisecond); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+}
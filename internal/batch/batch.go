@@ -0,0 +1,102 @@
+// Package batch reads command files for goeth batch, in the spirit of
+// ip -batch: one command per line, without the program name.
+package batch
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Command is one line of a batch file split into arguments.
+type Command struct {
+	// Line is the 1-based line number the command was read from.
+	Line int
+	Args []string
+}
+
+// Read parses r. Blank lines and lines starting with # are skipped. Arguments
+// are separated by spaces and tabs and may be quoted as in a POSIX shell:
+// single quotes keep everything literally, double quotes allow \" and \\,
+// and a backslash outside quotes escapes the next character.
+func Read(r io.Reader) ([]Command, error) {
+	var commands []Command
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		args, err := split(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		commands = append(commands, Command{Line: line, Args: args})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return commands, nil
+}
+
+// split breaks a line into arguments.
+func split(text string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range text {
+		switch {
+		case escaped:
+			if quote == '"' && r != '"' && r != '\\' {
+				current.WriteRune('\\')
+			}
+			current.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				current.WriteRune(r)
+			}
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			inArg = true
+			switch r {
+			case '\'', '"':
+				quote = r
+			case '\\':
+				escaped = true
+			default:
+				current.WriteRune(r)
+			}
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, errors.New("trailing backslash")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,54 @@
+package batch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	input := `# provision the uplink
+link set-mtu -i eth0 --mtu 9000
+
+	link up eth0 --wait-carrier
+apply-config -f '/etc/goeth/with space.json'
+interfaces --query ".[] | select(.name == \"eth0\") | .mtu"
+addresses -i my\ if ""
+`
+	got, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	want := []Command{
+		{Line: 2, Args: []string{"link", "set-mtu", "-i", "eth0", "--mtu", "9000"}},
+		{Line: 4, Args: []string{"link", "up", "eth0", "--wait-carrier"}},
+		{Line: 5, Args: []string{"apply-config", "-f", "/etc/goeth/with space.json"}},
+		{Line: 6, Args: []string{"interfaces", "--query", `.[] | select(.name == "eth0") | .mtu`}},
+		{Line: 7, Args: []string{"addresses", "-i", "my if", ""}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Read() = %#v, want %#v", got, want)
+	}
+}
+
+func TestReadKeepsOtherEscapesInDoubleQuotes(t *testing.T) {
+	got, err := Read(strings.NewReader(`interfaces -q "a\tb" 'c\d'`))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if want := []string{"interfaces", "-q", `a\tb`, `c\d`}; !reflect.DeepEqual(got[0].Args, want) {
+		t.Fatalf("Args = %q, want %q", got[0].Args, want)
+	}
+}
+
+func TestReadRejectsBrokenQuoting(t *testing.T) {
+	for _, input := range []string{"interfaces\nlink up 'eth0\n", `link up eth0\`} {
+		if _, err := Read(strings.NewReader(input)); err == nil {
+			t.Fatalf("Read(%q) succeeded, want an error", input)
+		}
+	}
+	_, err := Read(strings.NewReader("interfaces\nlink up \"eth0\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Fatalf("expected the line number in the error, got %v", err)
+	}
+}
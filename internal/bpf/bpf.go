@@ -0,0 +1,74 @@
+// Package bpf opens eBPF programs pinned in bpffs through the bpf(2) system
+// call, so neither libbpf nor bpftool is needed.
+package bpf
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// objGetAttr is the part of union bpf_attr that BPF_OBJ_GET reads.
+type objGetAttr struct {
+	pathname  uint64
+	bpfFD     uint32
+	fileFlags uint32
+}
+
+// infoAttr is the part of union bpf_attr that BPF_OBJ_GET_INFO_BY_FD reads.
+type infoAttr struct {
+	bpfFD   uint32
+	infoLen uint32
+	info    uint64
+}
+
+// progInfo is the head of struct bpf_prog_info; the kernel fills in no
+// more than the length it is given.
+type progInfo struct {
+	typ uint32
+	id  uint32
+}
+
+// Pinned is a program opened from its pin.
+type Pinned struct {
+	// FD refers to the program until Close.
+	FD int
+	// ID is the id the kernel gave the program, as links report the
+	// program attached to them.
+	ID uint32
+}
+
+// OpenPinned opens the program pinned at path.
+func OpenPinned(path string) (Pinned, error) {
+	name, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return Pinned{}, err
+	}
+	get := objGetAttr{pathname: uint64(uintptr(unsafe.Pointer(name)))}
+	fd, err := call(unix.BPF_OBJ_GET, unsafe.Pointer(&get), unsafe.Sizeof(get))
+	if err != nil {
+		return Pinned{}, fmt.Errorf("open pinned program %s: %w", path, err)
+	}
+	var info progInfo
+	query := infoAttr{bpfFD: uint32(fd), infoLen: uint32(unsafe.Sizeof(info)), info: uint64(uintptr(unsafe.Pointer(&info)))}
+	if _, err := call(unix.BPF_OBJ_GET_INFO_BY_FD, unsafe.Pointer(&query), unsafe.Sizeof(query)); err != nil {
+		unix.Close(fd)
+		return Pinned{}, fmt.Errorf("read pinned program %s: %w", path, err)
+	}
+	return Pinned{FD: fd, ID: info.id}, nil
+}
+
+// Close releases the file descriptor of the program, which stays loaded
+// while it is pinned or attached.
+func (p Pinned) Close() error {
+	return unix.Close(p.FD)
+}
+
+func call(cmd int, attr unsafe.Pointer, size uintptr) (int, error) {
+	r, _, errno := unix.Syscall(unix.SYS_BPF, uintptr(cmd), uintptr(attr), size)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(r), nil
+}
@@ -0,0 +1,31 @@
+package bpf
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestAttrsMatchKernelLayout(t *testing.T) {
+	if size := unsafe.Sizeof(objGetAttr{}); size != 16 {
+		t.Fatalf("objGetAttr is %d bytes, want 16", size)
+	}
+	if size := unsafe.Sizeof(infoAttr{}); size != 16 {
+		t.Fatalf("infoAttr is %d bytes, want 16", size)
+	}
+}
+
+func TestOpenPinnedMissing(t *testing.T) {
+	_, err := OpenPinned(filepath.Join(t.TempDir(), "missing"))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	// Without privileges or bpf(2) the kernel fails before looking up the
+	// path.
+	if !errors.Is(err, unix.ENOENT) && !errors.Is(err, unix.EPERM) && !errors.Is(err, unix.ENOSYS) && !errors.Is(err, unix.EINVAL) {
+		t.Fatalf("OpenPinned() error = %v", err)
+	}
+}
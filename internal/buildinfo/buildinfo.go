@@ -0,0 +1,76 @@
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// develVersion is reported when neither ldflags nor module metadata carry a version.
+const develVersion = "dev"
+
+// Info describes the running binary.
+type Info struct {
+	Version   string   `json:"version"`
+	Commit    string   `json:"commit,omitempty"`
+	BuildDate string   `json:"build_date,omitempty"`
+	GoVersion string   `json:"go_version"`
+	Platform  string   `json:"platform"`
+	Backends  []string `json:"backends"`
+}
+
+// Collect combines values injected at link time with the module build
+// information embedded by the Go toolchain. Injected values take precedence.
+func Collect(version, commit, buildDate string, backends []string) Info {
+	return collect(debug.ReadBuildInfo, version, commit, buildDate, backends)
+}
+
+func collect(read func() (*debug.BuildInfo, bool), version, commit, buildDate string, backends []string) Info {
+	info := Info{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Backends:  append([]string{}, backends...),
+	}
+	if bi, ok := read(); ok {
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		if bi.GoVersion != "" {
+			info.GoVersion = bi.GoVersion
+		}
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = develVersion
+	}
+	return info
+}
+
+// String renders a one-line human-readable summary.
+func (i Info) String() string {
+	out := "goeth " + i.Version
+	if i.Commit != "" {
+		out += fmt.Sprintf(" (commit %s", i.Commit)
+		if i.BuildDate != "" {
+			out += ", built " + i.BuildDate
+		}
+		out += ")"
+	} else if i.BuildDate != "" {
+		out += fmt.Sprintf(" (built %s)", i.BuildDate)
+	}
+	return fmt.Sprintf("%s %s %s", out, i.GoVersion, i.Platform)
+}
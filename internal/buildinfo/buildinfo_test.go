@@ -0,0 +1,51 @@
+package buildinfo
+
+import (
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func fakeBuildInfo(version string, settings ...debug.BuildSetting) func() (*debug.BuildInfo, bool) {
+	return func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{GoVersion: "go1.22.5", Main: debug.Module{Version: version}, Settings: settings}, true
+	}
+}
+
+func TestCollectPrefersInjectedValues(t *testing.T) {
+	read := fakeBuildInfo("v9.9.9", debug.BuildSetting{Key: "vcs.revision", Value: "deadbeef"})
+	info := collect(read, "0.1.0", "abc123", "2024-01-01T00:00:00Z", []string{"netlink"})
+	if info.Version != "0.1.0" || info.Commit != "abc123" || info.BuildDate != "2024-01-01T00:00:00Z" {
+		t.Fatalf("unexpected info: %#v", info)
+	}
+	if info.GoVersion != "go1.22.5" {
+		t.Fatalf("expected go version from build info, got %s", info.GoVersion)
+	}
+	if len(info.Backends) != 1 || info.Backends[0] != "netlink" {
+		t.Fatalf("unexpected backends: %v", info.Backends)
+	}
+}
+
+func TestCollectFallsBackToBuildInfo(t *testing.T) {
+	read := fakeBuildInfo("v1.2.3",
+		debug.BuildSetting{Key: "vcs.revision", Value: "deadbeef"},
+		debug.BuildSetting{Key: "vcs.time", Value: "2024-02-03T04:05:06Z"},
+	)
+	info := collect(read, "", "", "", nil)
+	if info.Version != "v1.2.3" || info.Commit != "deadbeef" || info.BuildDate != "2024-02-03T04:05:06Z" {
+		t.Fatalf("unexpected info: %#v", info)
+	}
+	if info.Backends == nil {
+		t.Fatal("expected an empty, non-nil backend list")
+	}
+}
+
+func TestCollectDevelVersion(t *testing.T) {
+	info := collect(fakeBuildInfo("(devel)"), "", "", "", nil)
+	if info.Version != develVersion {
+		t.Fatalf("Version = %q, want %q", info.Version, develVersion)
+	}
+	if !strings.HasPrefix(info.String(), "goeth dev go1.22.5") {
+		t.Fatalf("unexpected summary: %s", info.String())
+	}
+}
@@ -0,0 +1,485 @@
+// Package cache keeps an in-memory view of links, addresses, routes and
+// neighbors that is kept
+// current by netlink notifications, so readers do not each rescan the system.
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+
+	"github.com/user/goeth/internal/interfaces"
+	"github.com/user/goeth/internal/namespace"
+	"github.com/user/goeth/internal/neighbors"
+	"github.com/user/goeth/internal/routes"
+)
+
+// updateBuffer is the number of notifications queued while the cache is busy.
+const updateBuffer = 256
+
+// ErrSubscribe marks Run failing to subscribe to notifications, as where
+// netlink multicast groups are not permitted; readers can rescan instead.
+var ErrSubscribe = errors.New("cannot subscribe to netlink notifications")
+
+// Source provides the initial dump and the notification streams.
+type Source interface {
+	LinkList() ([]netlink.Link, error)
+	// AddrList is called with a nil link to list the addresses of every link.
+	AddrList(link netlink.Link, family int) ([]netlink.Addr, error)
+	LinkSubscribe(ch chan<- netlink.LinkUpdate, done <-chan struct{}) error
+	AddrSubscribe(ch chan<- netlink.AddrUpdate, done <-chan struct{}) error
+	// RouteList lists the routes of every table.
+	RouteList(family int) ([]netlink.Route, error)
+	RouteSubscribe(ch chan<- netlink.RouteUpdate, done <-chan struct{}) error
+	// NeighList is called with a zero linkIndex to list the neighbors of
+	// every link.
+	NeighList(linkIndex, family int) ([]netlink.Neigh, error)
+	NeighSubscribe(ch chan<- netlink.NeighUpdate, done <-chan struct{}) error
+}
+
+// SettingsSource is implemented by sources that can fill in the speed and
+// duplex of interfaces, which netlink does not report. The cache reads them
+// whenever it describes a link.
+type SettingsSource interface {
+	LinkSettings(list []interfaces.Interface)
+}
+
+type entry struct {
+	iface     interfaces.Interface
+	addresses map[string]struct{}
+	routes    map[string]routes.Route
+	neighbors map[string]neighbors.Neighbor
+}
+
+// Cache is safe for concurrent use. It implements interfaces.Provider,
+// addresses.Provider, routes.Provider and neighbors.Provider.
+type Cache struct {
+	source Source
+
+	mu     sync.RWMutex
+	links  map[int]*entry
+	loaded chan struct{}
+	once   sync.Once
+	// changed holds one signal for the updates applied since it was last
+	// received.
+	changed chan struct{}
+}
+
+// New creates a Cache reading from source. Call Run to populate it.
+func New(source Source) *Cache {
+	return &Cache{source: source, links: make(map[int]*entry), loaded: make(chan struct{}), changed: make(chan struct{}, 1)}
+}
+
+// Changes receives a value after Run applied updates. Updates that arrive
+// before the value is received are coalesced into it.
+func (c *Cache) Changes() <-chan struct{} {
+	return c.changed
+}
+
+func (c *Cache) notify() {
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Run subscribes to notifications, loads the current state and applies
+// updates until ctx is cancelled or a subscription fails.
+func (c *Cache) Run(ctx context.Context) error {
+	if c.source == nil {
+		return errors.New("cache source is not configured")
+	}
+	done := make(chan struct{})
+	defer close(done)
+	linkUpdates := make(chan netlink.LinkUpdate, updateBuffer)
+	addrUpdates := make(chan netlink.AddrUpdate, updateBuffer)
+	routeUpdates := make(chan netlink.RouteUpdate, updateBuffer)
+	neighUpdates := make(chan netlink.NeighUpdate, updateBuffer)
+	// Subscribing before the dump means nothing that changes during the
+	// dump is missed; replaying those updates afterwards is harmless.
+	if err := c.source.LinkSubscribe(linkUpdates, done); err != nil {
+		return fmt.Errorf("%w: link updates: %w", ErrSubscribe, err)
+	}
+	if err := c.source.AddrSubscribe(addrUpdates, done); err != nil {
+		return fmt.Errorf("%w: address updates: %w", ErrSubscribe, err)
+	}
+	if err := c.source.RouteSubscribe(routeUpdates, done); err != nil {
+		return fmt.Errorf("%w: route updates: %w", ErrSubscribe, err)
+	}
+	if err := c.source.NeighSubscribe(neighUpdates, done); err != nil {
+		return fmt.Errorf("%w: neighbor updates: %w", ErrSubscribe, err)
+	}
+	if err := c.load(); err != nil {
+		return err
+	}
+	c.once.Do(func() { close(c.loaded) })
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case update, ok := <-linkUpdates:
+			if !ok {
+				return errors.New("link update subscription closed")
+			}
+			c.applyLink(update)
+			// The kernel flushes the IPv4 routes of a link that goes
+			// down without a notification for each.
+			if update.Header.Type != unix.RTM_DELLINK && update.Link.Attrs().Flags&net.FlagUp == 0 {
+				if err := c.reloadRoutes(update.Link.Attrs().Index); err != nil {
+					return err
+				}
+			}
+			c.notify()
+		case update, ok := <-addrUpdates:
+			if !ok {
+				return errors.New("address update subscription closed")
+			}
+			c.applyAddr(update)
+			c.notify()
+		case update, ok := <-routeUpdates:
+			if !ok {
+				return errors.New("route update subscription closed")
+			}
+			c.applyRoute(update)
+			c.notify()
+		case update, ok := <-neighUpdates:
+			if !ok {
+				return errors.New("neighbor update subscription closed")
+			}
+			c.applyNeigh(update)
+			c.notify()
+		}
+	}
+}
+
+// Wait blocks until the initial state has been loaded.
+func (c *Cache) Wait(ctx context.Context) error {
+	select {
+	case <-c.loaded:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Cache) load() error {
+	links, err := c.source.LinkList()
+	if err != nil {
+		return fmt.Errorf("list links: %w", err)
+	}
+	addrs, err := c.source.AddrList(nil, netlink.FAMILY_ALL)
+	if err != nil {
+		return fmt.Errorf("list addresses: %w", err)
+	}
+	list, err := c.source.RouteList(netlink.FAMILY_ALL)
+	if err != nil {
+		return fmt.Errorf("list routes: %w", err)
+	}
+	neighs, err := c.source.NeighList(0, netlink.FAMILY_ALL)
+	if err != nil {
+		return fmt.Errorf("list neighbors: %w", err)
+	}
+	described := make([]interfaces.Interface, len(links))
+	for i, link := range links {
+		described[i] = interfaces.Describe(link)
+	}
+	c.linkSettings(described)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.links = make(map[int]*entry, len(links))
+	for _, iface := range described {
+		c.entry(iface.Index).iface = iface
+	}
+	for _, addr := range addrs {
+		if addr.IPNet != nil {
+			c.entry(addr.LinkIndex).addresses[addr.IPNet.String()] = struct{}{}
+		}
+	}
+	for _, route := range list {
+		c.addRoute(route)
+	}
+	for _, neigh := range neighs {
+		if neighbors.Listed(neigh) {
+			c.entry(neigh.LinkIndex).neighbors[neigh.IP.String()] = neighbors.Describe(neigh)
+		}
+	}
+	return nil
+}
+
+// reloadRoutes replaces the cached routes of the link with index by those
+// the kernel lists.
+func (c *Cache) reloadRoutes(index int) error {
+	list, err := c.source.RouteList(netlink.FAMILY_ALL)
+	if err != nil {
+		return fmt.Errorf("list routes: %w", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.links[index]
+	if !ok {
+		return nil
+	}
+	e.routes = make(map[string]routes.Route)
+	for _, route := range list {
+		if routes.Listed(route, index) {
+			described := routes.Describe(route)
+			e.routes[described.Key] = described
+		}
+	}
+	return nil
+}
+
+// entry returns the entry for index, creating it; callers hold mu.
+func (c *Cache) entry(index int) *entry {
+	e, ok := c.links[index]
+	if !ok {
+		e = &entry{addresses: make(map[string]struct{}), routes: make(map[string]routes.Route), neighbors: make(map[string]neighbors.Neighbor)}
+		c.links[index] = e
+	}
+	return e
+}
+
+func (c *Cache) applyLink(update netlink.LinkUpdate) {
+	index := update.Link.Attrs().Index
+	if update.Header.Type == unix.RTM_DELLINK {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.links, index)
+		return
+	}
+	described := []interfaces.Interface{interfaces.Describe(update.Link)}
+	c.linkSettings(described)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entry(index).iface = described[0]
+}
+
+// linkSettings fills in the speed and duplex of list when the source can.
+func (c *Cache) linkSettings(list []interfaces.Interface) {
+	if settings, ok := c.source.(SettingsSource); ok {
+		settings.LinkSettings(list)
+	}
+}
+
+func (c *Cache) applyAddr(update netlink.AddrUpdate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := update.LinkAddress.String()
+	if update.NewAddr {
+		c.entry(update.LinkIndex).addresses[key] = struct{}{}
+		return
+	}
+	if e, ok := c.links[update.LinkIndex]; ok {
+		delete(e.addresses, key)
+	}
+}
+
+func (c *Cache) applyRoute(update netlink.RouteUpdate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if update.Type == unix.RTM_NEWROUTE {
+		c.addRoute(update.Route)
+		return
+	}
+	key := routes.Describe(update.Route).Key
+	for _, index := range routeLinks(update.Route) {
+		if e, ok := c.links[index]; ok {
+			delete(e.routes, key)
+		}
+	}
+}
+
+func (c *Cache) applyNeigh(update netlink.NeighUpdate) {
+	if !neighbors.Listed(update.Neigh) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := update.IP.String()
+	if update.Type == unix.RTM_NEWNEIGH {
+		c.entry(update.LinkIndex).neighbors[key] = neighbors.Describe(update.Neigh)
+		return
+	}
+	if e, ok := c.links[update.LinkIndex]; ok {
+		delete(e.neighbors, key)
+	}
+}
+
+// addRoute caches route for each link it leaves through; callers hold mu.
+func (c *Cache) addRoute(route netlink.Route) {
+	described := routes.Describe(route)
+	for _, index := range routeLinks(route) {
+		c.entry(index).routes[described.Key] = described
+	}
+}
+
+// routeLinks returns the indices of the links route is listed for.
+func routeLinks(route netlink.Route) []int {
+	var indices []int
+	for _, index := range append([]int{route.LinkIndex}, nextHopLinks(route)...) {
+		if index != 0 && routes.Listed(route, index) {
+			indices = append(indices, index)
+		}
+	}
+	return indices
+}
+
+func nextHopLinks(route netlink.Route) []int {
+	indices := make([]int, len(route.MultiPath))
+	for i, hop := range route.MultiPath {
+		indices[i] = hop.LinkIndex
+	}
+	return indices
+}
+
+// ListInterfaces returns the cached interfaces.
+func (c *Cache) ListInterfaces() ([]interfaces.Interface, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	list := make([]interfaces.Interface, 0, len(c.links))
+	for _, e := range c.links {
+		if e.iface.Name != "" {
+			list = append(list, e.iface)
+		}
+	}
+	return list, nil
+}
+
+// InterfaceAddresses returns the cached addresses of the named interface.
+func (c *Cache) InterfaceAddresses(name string) ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, e := range c.links {
+		if e.iface.Name != name {
+			continue
+		}
+		addrs := make([]string, 0, len(e.addresses))
+		for addr := range e.addresses {
+			addrs = append(addrs, addr)
+		}
+		sort.Strings(addrs)
+		return addrs, nil
+	}
+	return nil, fmt.Errorf("interface %s not found", name)
+}
+
+// InterfaceRoutes returns the cached routes through the named interface.
+func (c *Cache) InterfaceRoutes(name string) ([]routes.Route, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, e := range c.links {
+		if e.iface.Name != name {
+			continue
+		}
+		list := make([]routes.Route, 0, len(e.routes))
+		for _, route := range e.routes {
+			list = append(list, route)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+		return list, nil
+	}
+	return nil, fmt.Errorf("interface %s not found", name)
+}
+
+// InterfaceNeighbors returns the cached neighbors of the named interface.
+func (c *Cache) InterfaceNeighbors(name string) ([]neighbors.Neighbor, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, e := range c.links {
+		if e.iface.Name != name {
+			continue
+		}
+		list := make([]neighbors.Neighbor, 0, len(e.neighbors))
+		for _, neigh := range e.neighbors {
+			list = append(list, neigh)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].IP < list[j].IP })
+		return list, nil
+	}
+	return nil, fmt.Errorf("interface %s not found", name)
+}
+
+// NetlinkSource reads from the kernel through github.com/vishvananda/netlink.
+// The zero value reads the network namespace of the calling process.
+type NetlinkSource struct {
+	handle *netlink.Handle
+	netns  *netns.NsHandle
+}
+
+// NewNetlinkSourceAt returns a NetlinkSource that reads inside ns. The
+// namespace handle must stay open for as long as the source is used.
+func NewNetlinkSourceAt(ns netns.NsHandle) (NetlinkSource, error) {
+	handle, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		return NetlinkSource{}, fmt.Errorf("open netlink handle: %w", err)
+	}
+	return NetlinkSource{handle: handle, netns: &ns}, nil
+}
+
+func (s NetlinkSource) nl() *netlink.Handle {
+	if s.handle == nil {
+		return &netlink.Handle{}
+	}
+	return s.handle
+}
+
+// LinkList returns all links.
+func (s NetlinkSource) LinkList() ([]netlink.Link, error) {
+	return s.nl().LinkList()
+}
+
+// AddrList returns the addresses of link, or of every link when link is nil.
+func (s NetlinkSource) AddrList(link netlink.Link, family int) ([]netlink.Addr, error) {
+	return s.nl().AddrList(link, family)
+}
+
+// LinkSettings fills in the speed and duplex of the interfaces in list
+// through ethtool.
+func (s NetlinkSource) LinkSettings(list []interfaces.Interface) {
+	if s.netns == nil {
+		interfaces.ReadLinkSettings(list)
+		return
+	}
+	namespace.Do(*s.netns, func() error {
+		interfaces.ReadLinkSettings(list)
+		return nil
+	})
+}
+
+// LinkSubscribe streams link notifications to ch until done is closed.
+func (s NetlinkSource) LinkSubscribe(ch chan<- netlink.LinkUpdate, done <-chan struct{}) error {
+	return netlink.LinkSubscribeWithOptions(ch, done, netlink.LinkSubscribeOptions{Namespace: s.netns})
+}
+
+// AddrSubscribe streams address notifications to ch until done is closed.
+func (s NetlinkSource) AddrSubscribe(ch chan<- netlink.AddrUpdate, done <-chan struct{}) error {
+	return netlink.AddrSubscribeWithOptions(ch, done, netlink.AddrSubscribeOptions{Namespace: s.netns})
+}
+
+// RouteList returns the routes of every table.
+func (s NetlinkSource) RouteList(family int) ([]netlink.Route, error) {
+	return s.nl().RouteListFiltered(family, &netlink.Route{Table: unix.RT_TABLE_UNSPEC}, netlink.RT_FILTER_TABLE)
+}
+
+// RouteSubscribe streams route notifications to ch until done is closed.
+func (s NetlinkSource) RouteSubscribe(ch chan<- netlink.RouteUpdate, done <-chan struct{}) error {
+	return netlink.RouteSubscribeWithOptions(ch, done, netlink.RouteSubscribeOptions{Namespace: s.netns})
+}
+
+// NeighList returns the neighbors of the link with linkIndex, or of every
+// link when it is zero.
+func (s NetlinkSource) NeighList(linkIndex, family int) ([]netlink.Neigh, error) {
+	return s.nl().NeighList(linkIndex, family)
+}
+
+// NeighSubscribe streams neighbor notifications to ch until done is closed.
+func (s NetlinkSource) NeighSubscribe(ch chan<- netlink.NeighUpdate, done <-chan struct{}) error {
+	return netlink.NeighSubscribeWithOptions(ch, done, netlink.NeighSubscribeOptions{Namespace: s.netns})
+}
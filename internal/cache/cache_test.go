@@ -0,0 +1,273 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+type fakeSource struct {
+	links    []netlink.Link
+	addrs    []netlink.Addr
+	mu       sync.Mutex
+	routes   []netlink.Route
+	linkCh   chan<- netlink.LinkUpdate
+	addrCh   chan<- netlink.AddrUpdate
+	routeCh  chan<- netlink.RouteUpdate
+	neighCh  chan<- netlink.NeighUpdate
+	neighs   []netlink.Neigh
+	subErr   error
+	listErr  error
+	listings int
+}
+
+func (f *fakeSource) LinkList() ([]netlink.Link, error) {
+	f.listings++
+	return f.links, f.listErr
+}
+
+func (f *fakeSource) AddrList(link netlink.Link, family int) ([]netlink.Addr, error) {
+	return f.addrs, nil
+}
+
+func (f *fakeSource) LinkSubscribe(ch chan<- netlink.LinkUpdate, done <-chan struct{}) error {
+	f.linkCh = ch
+	return f.subErr
+}
+
+func (f *fakeSource) AddrSubscribe(ch chan<- netlink.AddrUpdate, done <-chan struct{}) error {
+	f.addrCh = ch
+	return nil
+}
+
+func (f *fakeSource) RouteList(family int) ([]netlink.Route, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]netlink.Route(nil), f.routes...), nil
+}
+
+func (f *fakeSource) RouteSubscribe(ch chan<- netlink.RouteUpdate, done <-chan struct{}) error {
+	f.routeCh = ch
+	return nil
+}
+
+func (f *fakeSource) NeighList(linkIndex, family int) ([]netlink.Neigh, error) {
+	return f.neighs, nil
+}
+
+func (f *fakeSource) NeighSubscribe(ch chan<- netlink.NeighUpdate, done <-chan struct{}) error {
+	f.neighCh = ch
+	return nil
+}
+
+func (f *fakeSource) setRoutes(list ...netlink.Route) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.routes = list
+}
+
+func device(name string, index, mtu int) *netlink.Device {
+	return &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: name, Index: index, MTU: mtu, Flags: net.FlagUp}}
+}
+
+func ipnet(t *testing.T, cidr string) *net.IPNet {
+	t.Helper()
+	ip, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatalf("ParseCIDR(%s) error = %v", cidr, err)
+	}
+	n.IP = ip
+	return n
+}
+
+func startCache(t *testing.T, source *fakeSource) (*Cache, chan error) {
+	t.Helper()
+	c := New(source)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	errs := make(chan error, 1)
+	go func() { errs <- c.Run(ctx) }()
+	waitCtx, stop := context.WithTimeout(ctx, time.Second)
+	defer stop()
+	if err := c.Wait(waitCtx); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	return c, errs
+}
+
+// eventually polls check because updates are applied asynchronously.
+func eventually(t *testing.T, check func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !check() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCacheLoadsInitialState(t *testing.T) {
+	source := &fakeSource{
+		links: []netlink.Link{device("eth0", 2, 1500)},
+		addrs: []netlink.Addr{{IPNet: ipnet(t, "192.0.2.10/24"), LinkIndex: 2}},
+	}
+	c, _ := startCache(t, source)
+	list, err := c.ListInterfaces()
+	if err != nil || len(list) != 1 || list[0].Name != "eth0" || list[0].MTU != 1500 || !reflect.DeepEqual(list[0].Flags, []string{"up"}) {
+		t.Fatalf("ListInterfaces() = %#v, %v", list, err)
+	}
+	addrs, err := c.InterfaceAddresses("eth0")
+	if err != nil || !reflect.DeepEqual(addrs, []string{"192.0.2.10/24"}) {
+		t.Fatalf("InterfaceAddresses() = %v, %v", addrs, err)
+	}
+	if _, err := c.InterfaceAddresses("eth9"); err == nil {
+		t.Fatal("expected error for unknown interface")
+	}
+}
+
+func TestCacheAppliesUpdates(t *testing.T) {
+	source := &fakeSource{links: []netlink.Link{device("eth0", 2, 1500)}}
+	c, _ := startCache(t, source)
+
+	source.linkCh <- netlink.LinkUpdate{Header: unix.NlMsghdr{Type: unix.RTM_NEWLINK}, Link: device("eth1", 3, 9000)}
+	source.addrCh <- netlink.AddrUpdate{LinkAddress: *ipnet(t, "198.51.100.1/24"), LinkIndex: 3, NewAddr: true}
+	eventually(t, func() bool {
+		addrs, err := c.InterfaceAddresses("eth1")
+		return err == nil && len(addrs) == 1
+	})
+
+	source.linkCh <- netlink.LinkUpdate{Header: unix.NlMsghdr{Type: unix.RTM_NEWLINK}, Link: device("lan1", 3, 9000)}
+	source.addrCh <- netlink.AddrUpdate{LinkAddress: *ipnet(t, "198.51.100.1/24"), LinkIndex: 3}
+	source.linkCh <- netlink.LinkUpdate{Header: unix.NlMsghdr{Type: unix.RTM_DELLINK}, Link: device("eth0", 2, 1500)}
+	eventually(t, func() bool {
+		list, _ := c.ListInterfaces()
+		addrs, err := c.InterfaceAddresses("lan1")
+		return len(list) == 1 && list[0].Name == "lan1" && err == nil && len(addrs) == 0
+	})
+	if source.listings != 1 {
+		t.Fatalf("updates must not trigger rescans, got %d listings", source.listings)
+	}
+}
+
+func TestCacheSignalsAppliedUpdates(t *testing.T) {
+	source := &fakeSource{links: []netlink.Link{device("eth0", 2, 1500)}}
+	c, _ := startCache(t, source)
+	select {
+	case <-c.Changes():
+		t.Fatal("loading the initial state is not a change")
+	default:
+	}
+	source.linkCh <- netlink.LinkUpdate{Header: unix.NlMsghdr{Type: unix.RTM_NEWLINK}, Link: device("eth0", 2, 9000)}
+	source.linkCh <- netlink.LinkUpdate{Header: unix.NlMsghdr{Type: unix.RTM_NEWLINK}, Link: device("eth0", 2, 1400)}
+	select {
+	case <-c.Changes():
+	case <-time.After(time.Second):
+		t.Fatal("no change signalled")
+	}
+	eventually(t, func() bool {
+		list, _ := c.ListInterfaces()
+		return len(list) == 1 && list[0].MTU == 1400
+	})
+}
+
+func TestCacheTracksRoutes(t *testing.T) {
+	connected := netlink.Route{LinkIndex: 2, Dst: ipnet(t, "192.0.2.0/24"), Protocol: unix.RTPROT_KERNEL}
+	local := netlink.Route{LinkIndex: 2, Dst: ipnet(t, "192.0.2.10/32"), Table: unix.RT_TABLE_LOCAL, Type: unix.RTN_LOCAL}
+	source := &fakeSource{links: []netlink.Link{device("eth0", 2, 1500), device("eth1", 3, 1500)}, routes: []netlink.Route{connected, local}}
+	c, _ := startCache(t, source)
+	routesOf := func(name string) []string {
+		list, err := c.InterfaceRoutes(name)
+		if err != nil {
+			t.Fatalf("InterfaceRoutes(%s) error = %v", name, err)
+		}
+		described := make([]string, len(list))
+		for i, route := range list {
+			described[i] = route.String()
+		}
+		return described
+	}
+	if got, want := routesOf("eth0"), []string{"192.0.2.0/24 proto kernel"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("routes = %v, want %v without the local table", got, want)
+	}
+	multipath := netlink.Route{Dst: ipnet(t, "198.51.100.0/24"), MultiPath: []*netlink.NexthopInfo{
+		{LinkIndex: 2, Gw: net.ParseIP("192.0.2.1")},
+		{LinkIndex: 3, Gw: net.ParseIP("203.0.113.1")},
+	}}
+	source.routeCh <- netlink.RouteUpdate{Type: unix.RTM_NEWROUTE, Route: multipath}
+	eventually(t, func() bool { return len(routesOf("eth1")) == 1 && len(routesOf("eth0")) == 2 })
+	source.routeCh <- netlink.RouteUpdate{Type: unix.RTM_DELROUTE, Route: multipath}
+	eventually(t, func() bool { return len(routesOf("eth1")) == 0 && len(routesOf("eth0")) == 1 })
+
+	// Going down flushes the IPv4 routes without notifications.
+	source.setRoutes()
+	down := device("eth0", 2, 1500)
+	down.Flags = 0
+	source.linkCh <- netlink.LinkUpdate{Header: unix.NlMsghdr{Type: unix.RTM_NEWLINK}, Link: down}
+	eventually(t, func() bool { return len(routesOf("eth0")) == 0 })
+}
+
+func TestCacheTracksNeighbors(t *testing.T) {
+	mac, _ := net.ParseMAC("02:00:5e:00:53:01")
+	gateway := netlink.Neigh{LinkIndex: 2, Family: netlink.FAMILY_V4, IP: net.ParseIP("192.0.2.1"), HardwareAddr: mac, State: unix.NUD_REACHABLE}
+	multicast := netlink.Neigh{LinkIndex: 2, Family: netlink.FAMILY_V6, IP: net.ParseIP("ff02::1"), State: unix.NUD_NOARP}
+	source := &fakeSource{links: []netlink.Link{device("eth0", 2, 1500)}, neighs: []netlink.Neigh{gateway, multicast}}
+	c, _ := startCache(t, source)
+	neighborsOf := func() []string {
+		list, err := c.InterfaceNeighbors("eth0")
+		if err != nil {
+			t.Fatalf("InterfaceNeighbors() error = %v", err)
+		}
+		described := make([]string, len(list))
+		for i, neigh := range list {
+			described[i] = neigh.String()
+		}
+		return described
+	}
+	if got, want := neighborsOf(), []string{"192.0.2.1 lladdr 02:00:5e:00:53:01 reachable"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("neighbors = %v, want %v", got, want)
+	}
+	stale := gateway
+	stale.State = unix.NUD_STALE
+	source.neighCh <- netlink.NeighUpdate{Type: unix.RTM_NEWNEIGH, Neigh: stale}
+	eventually(t, func() bool {
+		return reflect.DeepEqual(neighborsOf(), []string{"192.0.2.1 lladdr 02:00:5e:00:53:01 stale"})
+	})
+	source.neighCh <- netlink.NeighUpdate{Type: unix.RTM_DELNEIGH, Neigh: stale}
+	eventually(t, func() bool { return len(neighborsOf()) == 0 })
+}
+
+func TestCacheRunFailsWhenSubscriptionCloses(t *testing.T) {
+	source := &fakeSource{}
+	_, errs := startCache(t, source)
+	close(source.linkCh)
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Fatal("expected error when subscription closes")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run() did not return")
+	}
+}
+
+func TestCacheRunPropagatesErrors(t *testing.T) {
+	if err := New(nil).Run(context.Background()); err == nil {
+		t.Fatal("expected error without source")
+	}
+	subErr := &fakeSource{subErr: errors.New("boom")}
+	if err := New(subErr).Run(context.Background()); !errors.Is(err, ErrSubscribe) {
+		t.Fatalf("expected subscribe error, got %v", err)
+	}
+	listErr := &fakeSource{listErr: errors.New("boom")}
+	if err := New(listErr).Run(context.Background()); err == nil {
+		t.Fatal("expected list error")
+	}
+}
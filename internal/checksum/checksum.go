@@ -0,0 +1,21 @@
+// Package checksum computes the checksum of the IP family of protocols,
+// which goeth needs for the ICMP and ICMPv6 messages it builds itself.
+package checksum
+
+import "encoding/binary"
+
+// Internet computes the Internet checksum (RFC 1071) of b. Over a message
+// that carries its checksum it is zero.
+func Internet(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(b[i:]))
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + sum>>16
+	}
+	return ^uint16(sum)
+}
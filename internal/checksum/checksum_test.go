@@ -0,0 +1,23 @@
+package checksum
+
+import "testing"
+
+func TestInternet(t *testing.T) {
+	tests := []struct {
+		name string
+		b    []byte
+		want uint16
+	}{
+		// RFC 1071 section 3 sums these to 0xddf2.
+		{"RFC 1071 example", []byte{0x00, 0x01, 0xf2, 0x03, 0xf4, 0xf5, 0xf6, 0xf7}, 0x220d},
+		{"odd length pads with zero", []byte{0x00, 0x01, 0xf2}, 0x0dfe},
+		{"carries fold back", []byte{0xff, 0xff, 0x00, 0x01}, 0xfffe},
+		{"with its checksum", []byte{0x00, 0x01, 0xf2, 0x03, 0xf4, 0xf5, 0xf6, 0xf7, 0x22, 0x0d}, 0},
+		{"empty", nil, 0xffff},
+	}
+	for _, tt := range tests {
+		if got := Internet(tt.b); got != tt.want {
+			t.Errorf("%s: Internet() = %#04x, want %#04x", tt.name, got, tt.want)
+		}
+	}
+}
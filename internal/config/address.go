@@ -0,0 +1,149 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Address describes a single address entry in the configuration. In JSON it
+// may be written either as a bare CIDR string or as an object carrying
+// additional attributes.
+type Address struct {
+	CIDR string `json:"address"`
+	// TTL expires the address after the given duration. Zero keeps it forever.
+	TTL Duration `json:"ttl,omitempty"`
+	// ValidLifetime and PreferredLifetime set the kernel lifetimes of the
+	// address; unset means forever, and the preferred lifetime defaults to
+	// the valid one. A preferred lifetime of 0s deprecates the address for
+	// graceful renumbering: it keeps working for existing connections but
+	// is no longer chosen for new ones.
+	ValidLifetime     *Duration `json:"valid_lft,omitempty"`
+	PreferredLifetime *Duration `json:"preferred_lft,omitempty"`
+	// Flags are netlink address flags: noprefixroute keeps the kernel from
+	// adding a route for the prefix, nodad skips IPv6 duplicate address
+	// detection and home marks an IPv6 mobility home address.
+	Flags []string `json:"flags,omitempty"`
+	// Scope is global (the default), link or host. Only IPv4 addresses
+	// take a declared scope.
+	Scope string `json:"scope,omitempty"`
+	// Peer is the remote end of a point-to-point address, such as on a
+	// tunnel. The prefix length of the address applies to it.
+	Peer string `json:"peer,omitempty"`
+	// Label names an IPv4 address, such as eth0:web, for tools that list
+	// addresses by label. It begins with the interface name.
+	Label string `json:"label,omitempty"`
+}
+
+// DAD controls waiting for IPv6 duplicate address detection.
+type DAD struct {
+	// Timeout bounds the wait; zero uses defaultDADTimeout.
+	Timeout Duration `json:"timeout,omitempty"`
+	// OnFailure is "fail" (the default) to stop the apply with an error, or
+	// "warn" to report duplicate or still tentative addresses and carry on.
+	OnFailure string `json:"on_failure,omitempty"`
+}
+
+// Announce controls announcing addresses to the neighbors of an interface.
+type Announce struct {
+	// Count is how many times each address is announced; zero announces it
+	// three times.
+	Count int `json:"count,omitempty"`
+	// Interval separates the announcements; zero waits a second.
+	Interval Duration `json:"interval,omitempty"`
+}
+
+func (d DAD) timeout() time.Duration {
+	if d.Timeout == 0 {
+		return defaultDADTimeout
+	}
+	return time.Duration(d.Timeout)
+}
+
+func (d DAD) onFailure() string {
+	if d.OnFailure == "" {
+		return dadFail
+	}
+	return d.OnFailure
+}
+
+// UnmarshalJSON accepts either "192.0.2.10/24" or {"address": "192.0.2.10/24", ...}.
+func (a *Address) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var cidr string
+		if err := json.Unmarshal(data, &cidr); err != nil {
+			return err
+		}
+		*a = Address{CIDR: cidr}
+		return nil
+	}
+	type plain Address
+	var decoded plain
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	if decoded.CIDR == "" {
+		return errors.New("address entry is missing the address field")
+	}
+	*a = Address(decoded)
+	return nil
+}
+
+// String returns the CIDR together with any non-default attributes.
+func (a Address) String() string {
+	var attrs []string
+	if a.Peer != "" {
+		attrs = append(attrs, "peer "+a.Peer)
+	}
+	if a.Scope != "" {
+		attrs = append(attrs, "scope "+a.Scope)
+	}
+	if a.Label != "" {
+		attrs = append(attrs, "label "+a.Label)
+	}
+	attrs = append(attrs, a.Flags...)
+	if a.TTL > 0 {
+		attrs = append(attrs, fmt.Sprintf("expires after %s", time.Duration(a.TTL)))
+	}
+	if a.ValidLifetime != nil {
+		attrs = append(attrs, fmt.Sprintf("valid for %s", time.Duration(*a.ValidLifetime)))
+	}
+	switch {
+	case a.PreferredLifetime == nil:
+	case *a.PreferredLifetime == 0:
+		attrs = append(attrs, "deprecated")
+	default:
+		attrs = append(attrs, fmt.Sprintf("preferred for %s", time.Duration(*a.PreferredLifetime)))
+	}
+	if len(attrs) == 0 {
+		return a.CIDR
+	}
+	return fmt.Sprintf("%s (%s)", a.CIDR, strings.Join(attrs, ", "))
+}
+
+// Duration is a time.Duration that is written as a string such as "30m" in JSON.
+type Duration time.Duration
+
+// UnmarshalJSON parses a Go duration string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+	parsed, err := time.ParseDuration(raw)
+	if err != nil {
+		return err
+	}
+	if parsed < 0 {
+		return fmt.Errorf("duration %q must not be negative", raw)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON writes the duration using time.Duration's string form.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestAddressUnmarshalForms(t *testing.T) {
+	var addrs []Address
+	raw := `["192.0.2.10/24", {"address": "192.0.2.20/24", "ttl": "30m"}]`
+	if err := json.Unmarshal([]byte(raw), &addrs); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(addrs) != 2 {
+		t.Fatalf("expected 2 addresses, got %d", len(addrs))
+	}
+	if addrs[0].CIDR != "192.0.2.10/24" || addrs[0].TTL != 0 {
+		t.Fatalf("unexpected string form: %#v", addrs[0])
+	}
+	if addrs[1].CIDR != "192.0.2.20/24" || time.Duration(addrs[1].TTL) != 30*time.Minute {
+		t.Fatalf("unexpected object form: %#v", addrs[1])
+	}
+}
+
+func TestAddressUnmarshalRequiresAddress(t *testing.T) {
+	var addr Address
+	if err := json.Unmarshal([]byte(`{"ttl": "1h"}`), &addr); err == nil {
+		t.Fatal("expected error when address field is missing")
+	}
+}
+
+func TestDurationRejectsInvalid(t *testing.T) {
+	var d Duration
+	if err := json.Unmarshal([]byte(`"soon"`), &d); err == nil {
+		t.Fatal("expected parse error")
+	}
+	if err := json.Unmarshal([]byte(`"-5m"`), &d); err == nil {
+		t.Fatal("expected error for negative duration")
+	}
+	if err := json.Unmarshal([]byte(`300`), &d); err == nil {
+		t.Fatal("expected error for numeric duration")
+	}
+}
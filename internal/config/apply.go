@@ -6,12 +6,447 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Configuration represents the JSON configuration schema.
 type Configuration struct {
-	Interface string   `json:"interface"`
-	Addresses []string `json:"addresses"`
+	Interface string `json:"interface"`
+	// Netns names the network namespace (as created by "ip netns add") that
+	// Interface lives in. Executors work in whatever namespace their provider
+	// is bound to; apply-config binds it to this one.
+	Netns string `json:"netns,omitempty"`
+	// Profile is "dual-stack" (the default) or "ipv6-only", which rejects
+	// IPv4 settings and makes a gateway-less default route IPv6.
+	Profile   string    `json:"profile,omitempty"`
+	Addresses []Address `json:"addresses"`
+	// UpdateFlags updates the flags of existing addresses that differ from
+	// the declared ones: IPv6 addresses in place, IPv4 ones, which the kernel
+	// cannot change, by removing and adding them again. Without it the
+	// differences are reported.
+	UpdateFlags bool `json:"update_flags,omitempty"`
+	// DHCP4 obtains an IPv4 address for Interface from a DHCP server next to
+	// the declared Addresses. The leased address is not removed as
+	// undeclared, and is renewed by an apply after its renewal time.
+	DHCP4 bool `json:"dhcp4,omitempty"`
+	// DHCP6 obtains an IPv6 address for Interface from a DHCPv6 server, like
+	// DHCP4.
+	DHCP6 bool `json:"dhcp6,omitempty"`
+	// SLAAC turns on address autoconfiguration from router advertisements.
+	// Addresses it configures are not removed as undeclared.
+	SLAAC bool `json:"slaac,omitempty"`
+	// AcquireTimeout bounds the wait for a global IPv6 address from SLAAC or
+	// DHCP6 after which the apply fails; 10s by default.
+	AcquireTimeout Duration `json:"acquire_timeout,omitempty"`
+	// DAD waits for IPv6 duplicate address detection on Addresses to finish
+	// and acts on addresses the kernel marks as duplicates.
+	DAD *DAD `json:"dad,omitempty"`
+	// Announce sends a gratuitous ARP or an unsolicited Neighbor
+	// Advertisement for each address the apply adds, so that switches and
+	// peers learn where it moved without waiting for their caches to
+	// expire. IPv6 addresses are announced once duplicate address detection
+	// is over, so it goes with DAD.
+	Announce *Announce `json:"announce,omitempty"`
+	// MTU sets the interface MTU. With MTUProbe it is the probe's ceiling.
+	MTU int `json:"mtu,omitempty"`
+	// MTUProbe clamps the MTU to the path MTU measured towards a remote host.
+	MTUProbe *MTUProbe `json:"mtu_probe,omitempty"`
+	// MAC sets the hardware address of Interface: a locally administered
+	// unicast address, or "permanent" for the address the device was made with.
+	MAC string `json:"mac,omitempty"`
+	// Sysctls sets kernel parameters of Interface by their sysctl(8) name,
+	// such as net.ipv6.conf.eth0.accept_ra. They are written before the
+	// link is brought up and its addresses are assigned.
+	Sysctls map[string]string `json:"sysctls,omitempty"`
+	// ARP sets how Interface answers and sends ARP, through the sysctls of
+	// net.ipv4.conf.<interface>.
+	ARP *ARP `json:"arp,omitempty"`
+	// IPv6 sets whether Interface configures itself from router
+	// advertisements, through the sysctls of net.ipv6.conf.<interface>.
+	IPv6 *IPv6 `json:"ipv6,omitempty"`
+	// Qdisc sets the root queueing discipline of Interface for egress
+	// shaping. Without it the qdisc is left as it is.
+	Qdisc *Qdisc `json:"qdisc,omitempty"`
+	// Offloads turns device features on (true) or off (false), by the short
+	// names ethtool(8) -K takes, such as gro and tso, or by kernel feature
+	// names, such as rx-gro.
+	Offloads map[string]bool `json:"offloads,omitempty"`
+	// Rings sets ring sizes of Interface by their ethtool(8) -G name: rx,
+	// rx-mini, rx-jumbo or tx.
+	Rings map[string]int `json:"rings,omitempty"`
+	// Coalesce sets interrupt coalescing parameters of Interface by their
+	// ethtool(8) -C name, such as rx-usecs. adaptive-rx and adaptive-tx are
+	// 0 or 1.
+	Coalesce map[string]int `json:"coalesce,omitempty"`
+	// VFs sets the attributes of SR-IOV virtual functions of Interface,
+	// which must be their physical function. The virtual functions must
+	// already exist, as set through sriov_numvfs.
+	VFs []VF `json:"vfs,omitempty"`
+	// XDP attaches a pinned XDP program to Interface before it is brought
+	// up. Without it the attached program is left as it is.
+	XDP *XDP `json:"xdp,omitempty"`
+	// State sets the administrative state of Interface, "up" or "down".
+	// Empty leaves it as it is.
+	State string `json:"state,omitempty"`
+	// WaitCarrier waits up to the given duration for the carrier after
+	// Interface is up. Zero does not wait.
+	WaitCarrier Duration `json:"wait_carrier,omitempty"`
+	// Neighbors lists permanent ARP/NDP entries. When the field is present
+	// (even as an empty list) undeclared permanent entries are removed.
+	Neighbors []Neighbor `json:"neighbors"`
+	// Routes lists routes through Interface in the main table or, with a
+	// table, in another one. When the field is present, undeclared routes
+	// installed by goeth are removed from the main table and from the tables
+	// the configuration uses; routes owned by other protocols are never
+	// touched.
+	Routes []Route `json:"routes"`
+	// Rules lists policy routing rules, as ip-rule(8) adds them. When the
+	// field is present, undeclared rules installed by goeth that lead to a
+	// table the configuration uses are removed, so each interface should
+	// have tables of its own.
+	Rules []Rule `json:"rules"`
+	// SourceRouting generates the routes and rules that make traffic from
+	// each address of Interface leave through it, for one uplink of a
+	// multi-homed host.
+	SourceRouting *SourceRouting `json:"source_routing,omitempty"`
+	// VLANs lists 802.1Q subinterfaces whose parent is Interface. When the
+	// field is present, VLANs on the parent that are not declared are deleted.
+	VLANs []VLAN `json:"vlans"`
+	// MACVLANs lists macvlan sub-interfaces whose parent is Interface. When
+	// the field is present, macvlans on the parent that are not declared are
+	// deleted.
+	MACVLANs []MACVLAN `json:"macvlans"`
+	// IPVLANs lists ipvlan sub-interfaces whose parent is Interface, pruned
+	// the same way as MACVLANs.
+	IPVLANs []IPVLAN `json:"ipvlans"`
+	// Bridge turns Interface into a bridge device, creating it when missing.
+	Bridge *Bridge `json:"bridge,omitempty"`
+	// Bond turns Interface into a bonding (LAG) device, creating it when missing.
+	Bond *Bond `json:"bond,omitempty"`
+	// WireGuard turns Interface into a WireGuard tunnel, creating it when missing.
+	WireGuard *WireGuard `json:"wireguard,omitempty"`
+	// Dummy turns Interface into a dummy device, creating it when missing.
+	Dummy *Dummy `json:"dummy,omitempty"`
+	// Veth turns Interface into one end of a veth pair, creating the pair when missing.
+	Veth *Veth `json:"veth,omitempty"`
+	// Tunnel turns Interface into a point-to-point IP tunnel, creating it when missing.
+	Tunnel *Tunnel `json:"tunnel,omitempty"`
+	// Tuntap turns Interface into a persistent tun or tap device, creating it when missing.
+	Tuntap *Tuntap `json:"tuntap,omitempty"`
+	// Links renames interfaces to predictable names before the rest of the
+	// configuration is applied.
+	Links []LinkRule `json:"links"`
+}
+
+// MTUProbe measures the path MTU through the interface by sending ICMP echo
+// requests with the Don't Fragment bit set to Target.
+type MTUProbe struct {
+	// Target is an IPv4 host beyond the uplink that answers pings.
+	Target string `json:"target"`
+	// Timeout bounds the wait for each probe reply.
+	Timeout Duration `json:"timeout,omitempty"`
+}
+
+// ARP describes the ARP handling of an interface, as hosts fronting virtual
+// IPs need it. Unset fields are left as they are.
+type ARP struct {
+	// ProxyARP answers ARP requests for addresses reachable through other
+	// interfaces.
+	ProxyARP *bool `json:"proxy_arp,omitempty"`
+	// Ignore is arp_ignore: 1 only answers for addresses of the interface
+	// itself, which keeps a virtual IP on loopback from being announced.
+	Ignore *int `json:"ignore,omitempty"`
+	// Announce is arp_announce: 2 always uses the best local address of the
+	// interface as the source of ARP requests.
+	Announce *int `json:"announce,omitempty"`
+}
+
+// IPv6 describes router advertisement handling and address
+// autoconfiguration (SLAAC) of an interface. Unset fields are left as they
+// are.
+type IPv6 struct {
+	// AcceptRA is accept_ra: 0 ignores router advertisements, 1 accepts them
+	// unless the host forwards, 2 accepts them even then.
+	AcceptRA *int `json:"accept_ra,omitempty"`
+	// Autoconf configures addresses from the prefixes advertised.
+	Autoconf *bool `json:"autoconf,omitempty"`
+	// UseTempaddr is use_tempaddr: 1 adds RFC 8981 temporary addresses, 2
+	// also prefers them as source addresses.
+	UseTempaddr *int `json:"use_tempaddr,omitempty"`
+}
+
+// Qdisc describes the root queueing discipline of an interface.
+type Qdisc struct {
+	// Type is "fq_codel" or "tbf".
+	Type string `json:"type"`
+	// Rate limits tbf to a rate in tc(8) units, such as "10mbit".
+	Rate string `json:"rate,omitempty"`
+	// Burst is the tbf bucket size, such as "32kb". It defaults to what Rate
+	// sends in 10ms, and at least one full frame.
+	Burst string `json:"burst,omitempty"`
+	// Latency bounds how long tbf queues packets; it defaults to 50ms.
+	Latency Duration `json:"latency,omitempty"`
+	// Limit is the fq_codel queue size in packets; zero keeps the kernel's.
+	Limit int `json:"limit,omitempty"`
+}
+
+// XDP names the XDP program of an interface.
+type XDP struct {
+	// Pinned is the bpffs path of the program, such as /sys/fs/bpf/ddos;
+	// empty detaches the attached program.
+	Pinned string `json:"pinned,omitempty"`
+	// Mode is "native" (the driver's hook, the default) or "skb" (generic
+	// XDP, for drivers without native support).
+	Mode string `json:"mode,omitempty"`
+}
+
+// VF describes a virtual function of a physical function by its index.
+type VF struct {
+	ID int `json:"id"`
+	// MAC is the address of the virtual function; empty leaves it alone.
+	MAC string `json:"mac,omitempty"`
+	// VLAN tags the traffic of the virtual function, with the 802.1p
+	// priority QoS; zero is untagged.
+	VLAN int `json:"vlan,omitempty"`
+	QoS  int `json:"qos,omitempty"`
+	// SpoofCheck drops frames from the virtual function with another
+	// source MAC; unset leaves it alone.
+	SpoofCheck *bool `json:"spoof_check,omitempty"`
+	// Trust lets the virtual function change its MAC and enter promiscuous
+	// mode; unset leaves it alone.
+	Trust *bool `json:"trust,omitempty"`
+	// MinRate and MaxRate bound the transmit rate in Mbit/s; zero is no
+	// bound.
+	MinRate int `json:"min_rate,omitempty"`
+	MaxRate int `json:"max_rate,omitempty"`
+}
+
+// LinkRule gives the interface matching MAC, PCIPath or RenameFrom the name
+// Name.
+type LinkRule struct {
+	MAC string `json:"mac,omitempty"`
+	// PCIPath is a PCI address such as "0000:03:00.0" (an optional "pci-"
+	// prefix, as used by udev's ID_PATH, is accepted).
+	PCIPath string `json:"pci_path,omitempty"`
+	// RenameFrom selects the link currently called RenameFrom, of any type.
+	// Once it is renamed the rule is satisfied by the link called Name.
+	RenameFrom string `json:"rename_from,omitempty"`
+	Name       string `json:"name"`
+}
+
+// Bond describes a bonding device and its slave interfaces.
+type Bond struct {
+	// Mode is the kernel bonding mode name such as "802.3ad" or "active-backup".
+	Mode string `json:"mode"`
+	// Miimon is the MII link monitoring interval in milliseconds.
+	Miimon int `json:"miimon,omitempty"`
+	// Slaves lists the interfaces enrolled in the bond. When the field is
+	// present, slaves that are not declared are released.
+	Slaves []string `json:"slaves"`
+}
+
+// Dummy describes a dummy device, typically carrying anycast or service
+// addresses that must not depend on a physical link. It has no settings.
+type Dummy struct{}
+
+// Veth describes a virtual ethernet pair. The peer only exists together with
+// Interface, so it is created (and can only be renamed or moved) on creation.
+type Veth struct {
+	Peer string `json:"peer"`
+	// PeerNetns places the peer in a named network namespace (as created by
+	// "ip netns add") instead of the current one.
+	PeerNetns string `json:"peer_netns,omitempty"`
+}
+
+// Tuntap describes a persistent tun (layer 3) or tap (layer 2) device for
+// VPN or VM tooling to attach to. Owner, Group and MultiQueue only take
+// effect when the device is created.
+type Tuntap struct {
+	// Mode is "tun" or "tap".
+	Mode string `json:"mode"`
+	// Owner and Group are user and group names or numeric ids allowed to
+	// attach without CAP_NET_ADMIN. Both default to root.
+	Owner string `json:"owner,omitempty"`
+	Group string `json:"group,omitempty"`
+	// MultiQueue lets the consumer attach several queues for parallel I/O.
+	MultiQueue bool `json:"multi_queue,omitempty"`
+}
+
+// Tunnel describes a point-to-point GRE, IPIP or SIT tunnel over IPv4.
+type Tunnel struct {
+	// Mode is "gre", "ipip" or "sit".
+	Mode   string `json:"mode"`
+	Local  string `json:"local"`
+	Remote string `json:"remote"`
+	// TTL is the outer packet TTL; zero inherits it from the inner packet.
+	TTL int `json:"ttl,omitempty"`
+}
+
+// WireGuard describes a WireGuard device and its peers.
+type WireGuard struct {
+	// PrivateKeyFile names a file holding the base64 private key, as written
+	// by "wg genkey". Keys are never embedded in the configuration itself.
+	PrivateKeyFile string `json:"private_key_file"`
+	// ListenPort is the UDP port to listen on; zero lets the kernel pick one.
+	ListenPort int `json:"listen_port,omitempty"`
+	// Peers lists the remote peers. When the field is present, peers that are
+	// not declared are removed.
+	Peers []WireGuardPeer `json:"peers"`
+}
+
+// WireGuardPeer describes a remote WireGuard peer.
+type WireGuardPeer struct {
+	PublicKey        string `json:"public_key"`
+	PresharedKeyFile string `json:"preshared_key_file,omitempty"`
+	// Endpoint is the peer's "host:port" UDP address.
+	Endpoint   string   `json:"endpoint,omitempty"`
+	AllowedIPs []string `json:"allowed_ips"`
+	// PersistentKeepalive sends keepalives at this interval; zero disables them.
+	PersistentKeepalive Duration `json:"persistent_keepalive,omitempty"`
+}
+
+// Bridge describes a bridge device and its member ports.
+type Bridge struct {
+	// Ports lists the interfaces enslaved to the bridge. When the field is
+	// present, ports that are not declared are released from the bridge.
+	Ports []string `json:"ports"`
+}
+
+// VLAN declares an 802.1Q subinterface created on top of the configured interface.
+type VLAN struct {
+	Name string `json:"name"`
+	ID   int    `json:"id"`
+}
+
+// MACVLAN declares a macvlan sub-interface created on top of the configured
+// interface. Mode is bridge (the default), vepa or private.
+type MACVLAN struct {
+	Name string `json:"name"`
+	Mode string `json:"mode,omitempty"`
+}
+
+// IPVLAN declares an ipvlan sub-interface created on top of the configured
+// interface. Sub-interfaces share the parent's MAC address, which suits
+// switches that limit MACs per port. Mode is l2 (the default), l3 or l3s.
+type IPVLAN struct {
+	Name string `json:"name"`
+	Mode string `json:"mode,omitempty"`
+}
+
+func (r LinkRule) selector() string {
+	switch {
+	case r.MAC != "":
+		return "mac " + r.MAC
+	case r.RenameFrom != "":
+		return r.RenameFrom
+	}
+	return "pci " + r.PCIPath
+}
+
+// Neighbor declares a permanent ARP (IPv4) or NDP (IPv6) entry on the interface.
+type Neighbor struct {
+	IP  string `json:"ip"`
+	MAC string `json:"mac"`
+}
+
+// Route declares a route through the interface. Destination is a CIDR or
+// "default", which takes the family of the gateway or of the profile; without
+// a gateway the destination is directly connected.
+type Route struct {
+	Destination string `json:"destination"`
+	Gateway     string `json:"gateway,omitempty"`
+	// Type is blackhole, unreachable or prohibit for a route that drops
+	// its traffic, which then has no gateway; empty is unicast.
+	Type string `json:"type,omitempty"`
+	// Table is the routing table id; zero is the main table.
+	Table int `json:"table,omitempty"`
+	// Metric is the route priority; lower is preferred. Zero is the
+	// kernel default, which is 1024 for IPv6.
+	Metric int `json:"metric,omitempty"`
+	// Protocol is the rtm_protocol the route is installed with, by number
+	// or name such as static; empty is goeth (245). A route of this
+	// protocol already at the destination is taken over.
+	Protocol string `json:"protocol,omitempty"`
+	// Nexthops spreads the traffic to Destination over several gateways
+	// (ECMP) in place of Gateway. At least one of them must be reached
+	// through Interface.
+	Nexthops []Nexthop `json:"nexthops,omitempty"`
+	// TTL is not supported: routes do not expire, and one that sets a TTL
+	// is rejected rather than kept forever.
+	TTL Duration `json:"ttl,omitempty"`
+}
+
+// Nexthop is one gateway of a multipath route.
+type Nexthop struct {
+	Gateway string `json:"gateway"`
+	// Interface is the link the gateway is reached through; empty is the
+	// interface of the configuration.
+	Interface string `json:"interface,omitempty"`
+	// Weight is the share of the traffic relative to the other next hops,
+	// 1 to 256; zero is 1.
+	Weight int `json:"weight,omitempty"`
+}
+
+// SourceRouting installs, in Table, a default route through the gateway of
+// each family and the prefix of each declared address, and for each of those
+// addresses a rule looking up Table for traffic from it.
+type SourceRouting struct {
+	// Table should be different on every interface.
+	Table int `json:"table"`
+	// Priority is that of the rule of the first address in sorted order;
+	// each further address gets the next one. Zero uses Table.
+	Priority int `json:"priority,omitempty"`
+	// Gateways lists the router of the uplink, at most one per address
+	// family.
+	Gateways []string `json:"gateways"`
+}
+
+// Rule is a policy routing rule. Traffic matching all of From, To and
+// FWMark is looked up in Table; a rule without selectors matches
+// everything of the default family.
+type Rule struct {
+	// Priority orders the rules; lower values are tried first.
+	Priority int `json:"priority"`
+	// From and To match the source and destination address, each a CIDR
+	// or a single address.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// FWMark matches the firewall mark, as "mark" or "mark/mask".
+	FWMark string `json:"fwmark,omitempty"`
+	Table  int    `json:"table"`
+}
+
+func (c Configuration) isEmpty() bool {
+	return len(c.Addresses) == 0 && !c.DHCP4 && !c.DHCP6 && !c.SLAAC && c.MTU == 0 && c.MTUProbe == nil && c.MAC == "" && len(c.Sysctls) == 0 && c.ARP == nil && c.IPv6 == nil && c.Qdisc == nil && len(c.Offloads) == 0 && len(c.Rings) == 0 && len(c.Coalesce) == 0 && len(c.VFs) == 0 && c.XDP == nil && c.State == "" && c.Neighbors == nil && c.Routes == nil && c.Rules == nil && c.SourceRouting == nil && c.VLANs == nil && c.MACVLANs == nil && c.IPVLANs == nil && len(c.Links) == 0 && len(c.kinds()) == 0
+}
+
+// kinds lists the device sections declared for the configured interface.
+func (c Configuration) kinds() []string {
+	var kinds []string
+	if c.Bridge != nil {
+		kinds = append(kinds, "bridge")
+	}
+	if c.Bond != nil {
+		kinds = append(kinds, "bond")
+	}
+	if c.WireGuard != nil {
+		kinds = append(kinds, "wireguard")
+	}
+	if c.Tunnel != nil {
+		kinds = append(kinds, "tunnel")
+	}
+	if c.Veth != nil {
+		kinds = append(kinds, "veth")
+	}
+	if c.Dummy != nil {
+		kinds = append(kinds, "dummy")
+	}
+	if c.Tuntap != nil {
+		kinds = append(kinds, "tuntap")
+	}
+	return kinds
 }
 
 // Executor applies the provided configuration to the environment.
@@ -37,8 +472,11 @@ func (a Applier) Apply(cfg Configuration) error {
 	if cfg.Interface == "" {
 		return errors.New("interface is required")
 	}
-	if len(cfg.Addresses) == 0 {
-		return errors.New("at least one address is required")
+	if kinds := cfg.kinds(); len(kinds) > 1 {
+		return fmt.Errorf("%s cannot be configured as %s at the same time", cfg.Interface, strings.Join(kinds, " and "))
+	}
+	if cfg.isEmpty() {
+		return fmt.Errorf("configuration declares nothing to apply to %s", cfg.Interface)
 	}
 	return a.executor.Apply(cfg)
 }
@@ -87,14 +525,276 @@ func (c ConsoleExecutor) Apply(cfg Configuration) error {
 	if c.Writer == nil {
 		return errors.New("writer is not configured")
 	}
-	_, err := fmt.Fprintf(c.Writer, "Applying configuration to %s\n", cfg.Interface)
+	target := cfg.Interface
+	if cfg.Netns != "" {
+		target += " in netns " + cfg.Netns
+	}
+	_, err := fmt.Fprintf(c.Writer, "Applying configuration to %s\n", target)
 	if err != nil {
 		return err
 	}
+	for _, rule := range cfg.Links {
+		if _, err := fmt.Fprintf(c.Writer, " - name %s for %s\n", rule.Name, rule.selector()); err != nil {
+			return err
+		}
+	}
+	if cfg.MTU != 0 {
+		if _, err := fmt.Fprintf(c.Writer, " - mtu %d\n", cfg.MTU); err != nil {
+			return err
+		}
+	}
+	if cfg.MTUProbe != nil {
+		if _, err := fmt.Fprintf(c.Writer, " - clamp mtu to the path mtu towards %s\n", cfg.MTUProbe.Target); err != nil {
+			return err
+		}
+	}
+	if cfg.MAC != "" {
+		if _, err := fmt.Fprintf(c.Writer, " - mac %s\n", cfg.MAC); err != nil {
+			return err
+		}
+	}
+	for _, name := range sortedKeys(cfg.Sysctls) {
+		if _, err := fmt.Fprintf(c.Writer, " - sysctl %s = %s\n", name, cfg.Sysctls[name]); err != nil {
+			return err
+		}
+	}
+	if cfg.ARP != nil {
+		if _, err := fmt.Fprintf(c.Writer, " - arp %s\n", cfg.ARP); err != nil {
+			return err
+		}
+	}
+	if cfg.IPv6 != nil {
+		if _, err := fmt.Fprintf(c.Writer, " - ipv6 %s\n", cfg.IPv6); err != nil {
+			return err
+		}
+	}
+	if cfg.Qdisc != nil {
+		if _, err := fmt.Fprintf(c.Writer, " - qdisc %s\n", cfg.Qdisc); err != nil {
+			return err
+		}
+	}
+	for _, name := range sortedKeys(cfg.Offloads) {
+		if _, err := fmt.Fprintf(c.Writer, " - offload %s %s\n", name, onOff(cfg.Offloads[name])); err != nil {
+			return err
+		}
+	}
+	for _, ring := range sortedKeys(cfg.Rings) {
+		if _, err := fmt.Fprintf(c.Writer, " - %s ring %d\n", ring, cfg.Rings[ring]); err != nil {
+			return err
+		}
+	}
+	for _, param := range sortedKeys(cfg.Coalesce) {
+		if _, err := fmt.Fprintf(c.Writer, " - coalesce %s %d\n", param, cfg.Coalesce[param]); err != nil {
+			return err
+		}
+	}
+	for _, vf := range cfg.VFs {
+		if _, err := fmt.Fprintf(c.Writer, " - %s\n", vf); err != nil {
+			return err
+		}
+	}
+	if cfg.XDP != nil {
+		if _, err := fmt.Fprintf(c.Writer, " - %s\n", cfg.XDP); err != nil {
+			return err
+		}
+	}
+	if cfg.State != "" {
+		if _, err := fmt.Fprintf(c.Writer, " - set link %s\n", cfg.State); err != nil {
+			return err
+		}
+	}
+	if cfg.WaitCarrier > 0 {
+		if _, err := fmt.Fprintf(c.Writer, " - wait up to %s for carrier\n", time.Duration(cfg.WaitCarrier)); err != nil {
+			return err
+		}
+	}
 	for _, addr := range cfg.Addresses {
 		if _, err := fmt.Fprintf(c.Writer, " - %s\n", addr); err != nil {
 			return err
 		}
 	}
+	if cfg.DHCP4 {
+		if _, err := fmt.Fprintln(c.Writer, " - obtain an address with dhcpv4"); err != nil {
+			return err
+		}
+	}
+	if cfg.DHCP6 {
+		if _, err := fmt.Fprintln(c.Writer, " - obtain an address with dhcpv6"); err != nil {
+			return err
+		}
+	}
+	if cfg.SLAAC {
+		if _, err := fmt.Fprintln(c.Writer, " - configure addresses from router advertisements"); err != nil {
+			return err
+		}
+	}
+	if cfg.SLAAC || cfg.DHCP6 {
+		if _, err := fmt.Fprintf(c.Writer, " - wait up to %s for a global ipv6 address\n", cfg.acquireTimeout()); err != nil {
+			return err
+		}
+	}
+	if cfg.DAD != nil {
+		if _, err := fmt.Fprintf(c.Writer, " - wait up to %s for duplicate address detection (%s on failure)\n",
+			cfg.DAD.timeout(), cfg.DAD.onFailure()); err != nil {
+			return err
+		}
+	}
+	if cfg.Announce != nil {
+		if _, err := fmt.Fprintf(c.Writer, " - %s\n", cfg.Announce); err != nil {
+			return err
+		}
+	}
+	for _, neigh := range cfg.Neighbors {
+		if _, err := fmt.Fprintf(c.Writer, " - neighbor %s lladdr %s\n", neigh.IP, neigh.MAC); err != nil {
+			return err
+		}
+	}
+	for _, route := range cfg.Routes {
+		via := ""
+		if route.Gateway != "" {
+			via = " via " + route.Gateway
+		}
+		for _, hop := range route.Nexthops {
+			via += " nexthop via " + hop.Gateway
+			if hop.Interface != "" {
+				via += " dev " + hop.Interface
+			}
+			if hop.Weight != 0 {
+				via += fmt.Sprintf(" weight %d", hop.Weight)
+			}
+		}
+		if route.Table != 0 {
+			via += fmt.Sprintf(" table %d", route.Table)
+		}
+		if route.Metric != 0 {
+			via += fmt.Sprintf(" metric %d", route.Metric)
+		}
+		if route.Protocol != "" {
+			via += " proto " + route.Protocol
+		}
+		typ := ""
+		if route.Type != "" {
+			typ = route.Type + " "
+		}
+		if _, err := fmt.Fprintf(c.Writer, " - route %s%s%s\n", typ, route.Destination, via); err != nil {
+			return err
+		}
+	}
+	for _, rule := range cfg.Rules {
+		if _, err := fmt.Fprintf(c.Writer, " - rule %s\n", rule); err != nil {
+			return err
+		}
+	}
+	if sr := cfg.SourceRouting; sr != nil {
+		if _, err := fmt.Fprintf(c.Writer, " - route traffic from each address through table %d via %s\n", sr.Table, strings.Join(sr.Gateways, ", ")); err != nil {
+			return err
+		}
+	}
+	if cfg.Bridge != nil {
+		if _, err := fmt.Fprintf(c.Writer, " - bridge ports: %s\n", joinOrNone(cfg.Bridge.Ports)); err != nil {
+			return err
+		}
+	}
+	if cfg.Bond != nil {
+		if _, err := fmt.Fprintf(c.Writer, " - bond mode %s, slaves: %s\n", cfg.Bond.Mode, joinOrNone(cfg.Bond.Slaves)); err != nil {
+			return err
+		}
+	}
+	if cfg.WireGuard != nil {
+		if _, err := fmt.Fprintf(c.Writer, " - wireguard listen port %s, key from %s\n", listenPortOrAuto(cfg.WireGuard.ListenPort), cfg.WireGuard.PrivateKeyFile); err != nil {
+			return err
+		}
+		for _, peer := range cfg.WireGuard.Peers {
+			if _, err := fmt.Fprintf(c.Writer, " - wireguard peer %s allowed ips: %s\n", peer.PublicKey, joinOrNone(peer.AllowedIPs)); err != nil {
+				return err
+			}
+		}
+	}
+	if cfg.Dummy != nil {
+		if _, err := fmt.Fprintln(c.Writer, " - dummy device"); err != nil {
+			return err
+		}
+	}
+	if tuntap := cfg.Tuntap; tuntap != nil {
+		queues := "single-queue"
+		if tuntap.MultiQueue {
+			queues = "multi-queue"
+		}
+		if _, err := fmt.Fprintf(c.Writer, " - %s device (%s, owner %s, group %s)\n", tuntap.Mode, queues, rootIfEmpty(tuntap.Owner), rootIfEmpty(tuntap.Group)); err != nil {
+			return err
+		}
+	}
+	if veth := cfg.Veth; veth != nil {
+		peer := veth.Peer
+		if veth.PeerNetns != "" {
+			peer += " in netns " + veth.PeerNetns
+		}
+		if _, err := fmt.Fprintf(c.Writer, " - veth peer %s\n", peer); err != nil {
+			return err
+		}
+	}
+	if tunnel := cfg.Tunnel; tunnel != nil {
+		if _, err := fmt.Fprintf(c.Writer, " - %s tunnel %s -> %s, ttl %s\n", tunnel.Mode, tunnel.Local, tunnel.Remote, ttlOrInherit(tunnel.TTL)); err != nil {
+			return err
+		}
+	}
+	for _, vlan := range cfg.VLANs {
+		if _, err := fmt.Fprintf(c.Writer, " - vlan %s (id %d)\n", vlan.Name, vlan.ID); err != nil {
+			return err
+		}
+	}
+	for _, macvlan := range cfg.MACVLANs {
+		mode := macvlan.Mode
+		if mode == "" {
+			mode = defaultMACVLANMode
+		}
+		if _, err := fmt.Fprintf(c.Writer, " - macvlan %s (mode %s)\n", macvlan.Name, mode); err != nil {
+			return err
+		}
+	}
+	for _, ipvlan := range cfg.IPVLANs {
+		mode := ipvlan.Mode
+		if mode == "" {
+			mode = defaultIPVLANMode
+		}
+		if _, err := fmt.Fprintf(c.Writer, " - ipvlan %s (mode %s)\n", ipvlan.Name, mode); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+func rootIfEmpty(value string) string {
+	if value == "" {
+		return "root"
+	}
+	return value
+}
+
+func listenPortOrAuto(port int) string {
+	if port == 0 {
+		return "auto"
+	}
+	return strconv.Itoa(port)
+}
+
+func ttlOrInherit(ttl int) string {
+	if ttl == 0 {
+		return "inherit"
+	}
+	return strconv.Itoa(ttl)
+}
+
+func joinOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"errors"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -18,7 +19,7 @@ func (m *mockExecutor) Apply(cfg Configuration) error {
 
 func TestApplierApplyValidates(t *testing.T) {
 	applier := NewApplier(&mockExecutor{})
-	err := applier.Apply(Configuration{Interface: "", Addresses: []string{"10.0.0.1/24"}})
+	err := applier.Apply(Configuration{Interface: "", Addresses: []Address{{CIDR: "10.0.0.1/24"}}})
 	if err == nil {
 		t.Fatal("expected validation error when interface is missing")
 	}
@@ -32,14 +33,14 @@ func TestApplierApplyValidates(t *testing.T) {
 func TestApplierDelegatesToExecutor(t *testing.T) {
 	exec := &mockExecutor{}
 	applier := NewApplier(exec)
-	cfg := Configuration{Interface: "eth0", Addresses: []string{"10.0.0.2/24"}}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "10.0.0.2/24"}}}
 	if err := applier.Apply(cfg); err != nil {
 		t.Fatalf("Apply() error = %v", err)
 	}
 	if exec.cfg.Interface != cfg.Interface {
 		t.Fatalf("expected interface %s, got %s", cfg.Interface, exec.cfg.Interface)
 	}
-	if len(exec.cfg.Addresses) != len(cfg.Addresses) || exec.cfg.Addresses[0] != cfg.Addresses[0] {
+	if !reflect.DeepEqual(exec.cfg.Addresses, cfg.Addresses) {
 		t.Fatalf("expected addresses %v, got %v", cfg.Addresses, exec.cfg.Addresses)
 	}
 }
@@ -69,7 +70,7 @@ func TestLoaderRequiresPath(t *testing.T) {
 func TestConsoleExecutorWrites(t *testing.T) {
 	var buf strings.Builder
 	exec := ConsoleExecutor{Writer: &buf}
-	cfg := Configuration{Interface: "eth0", Addresses: []string{"10.0.0.4/24", "192.168.1.2/24"}}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "10.0.0.4/24"}, {CIDR: "192.168.1.2/24"}}}
 	if err := exec.Apply(cfg); err != nil {
 		t.Fatalf("Apply() error = %v", err)
 	}
@@ -82,6 +83,25 @@ func TestConsoleExecutorWrites(t *testing.T) {
 	}
 }
 
+func TestConsoleExecutorNamesNetns(t *testing.T) {
+	var buf strings.Builder
+	cfg := Configuration{Interface: "eth0", Netns: "blue", Addresses: []Address{{CIDR: "10.0.0.4/24"}}}
+	if err := (ConsoleExecutor{Writer: &buf}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "Applying configuration to eth0 in netns blue") {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestApplierAcceptsNeighborOnlyConfiguration(t *testing.T) {
+	applier := NewApplier(&mockExecutor{})
+	cfg := Configuration{Interface: "eth0", Neighbors: []Neighbor{{IP: "192.0.2.1", MAC: "02:00:00:00:00:01"}}}
+	if err := applier.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+}
+
 func TestConsoleExecutorRequiresWriter(t *testing.T) {
 	exec := ConsoleExecutor{}
 	if err := exec.Apply(Configuration{}); err == nil {
@@ -91,7 +111,7 @@ func TestConsoleExecutorRequiresWriter(t *testing.T) {
 
 func TestApplierRequiresExecutor(t *testing.T) {
 	var applier Applier
-	if err := applier.Apply(Configuration{Interface: "eth0", Addresses: []string{"10.0.0.1/24"}}); err == nil {
+	if err := applier.Apply(Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "10.0.0.1/24"}}}); err == nil {
 		t.Fatal("expected error when executor is missing")
 	}
 }
@@ -113,3 +133,27 @@ func TestLoaderInvalidJSON(t *testing.T) {
 		t.Fatal("expected parse error")
 	}
 }
+
+func TestApplierRejectsMultipleKinds(t *testing.T) {
+	applier := NewApplier(&mockExecutor{})
+	cfg := Configuration{Interface: "br0", Bridge: &Bridge{}, Bond: &Bond{Mode: "active-backup"}}
+	if err := applier.Apply(cfg); err == nil {
+		t.Fatal("expected error when several device kinds are declared")
+	}
+}
+
+func TestConsoleExecutorDescribesWireGuard(t *testing.T) {
+	var buf strings.Builder
+	cfg := Configuration{Interface: "wg0", WireGuard: &WireGuard{
+		PrivateKeyFile: "/etc/goeth/wg0.key",
+		Peers:          []WireGuardPeer{{PublicKey: "peer-key", AllowedIPs: []string{"10.0.0.2/32"}}},
+	}}
+	if err := (ConsoleExecutor{Writer: &buf}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	output := buf.String()
+	if !strings.Contains(output, "wireguard listen port auto, key from /etc/goeth/wg0.key") ||
+		!strings.Contains(output, "wireguard peer peer-key allowed ips: 10.0.0.2/32") {
+		t.Fatalf("unexpected output: %s", output)
+	}
+}
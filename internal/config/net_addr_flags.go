@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// addressFlag is a netlink address flag that may be declared on an address.
+type addressFlag struct {
+	name string
+	bit  int
+	// ipv6Only flags are rejected on IPv4 addresses, where the kernel
+	// ignores them.
+	ipv6Only bool
+}
+
+// addressFlags lists the declarable flags in the order they are reported.
+var addressFlags = []addressFlag{
+	{name: "noprefixroute", bit: unix.IFA_F_NOPREFIXROUTE},
+	{name: "nodad", bit: unix.IFA_F_NODAD, ipv6Only: true},
+	{name: "home", bit: unix.IFA_F_HOMEADDRESS, ipv6Only: true},
+}
+
+// lastingFlags are the flags an existing address is compared on. nodad only
+// matters while an address is added.
+const lastingFlags = unix.IFA_F_NOPREFIXROUTE | unix.IFA_F_HOMEADDRESS
+
+// parseAddressFlags converts the declared flag names into netlink bits.
+func parseAddressFlags(entry Address, addr *netlink.Addr) (int, error) {
+	bits := 0
+	for _, name := range entry.Flags {
+		flag, ok := lookupAddressFlag(name)
+		if !ok {
+			return 0, fmt.Errorf("address %s: unknown flag %q, want one of %s", entry.CIDR, name, addressFlagNames(^0))
+		}
+		if flag.ipv6Only && addr.IP.To4() != nil {
+			return 0, fmt.Errorf("address %s: flag %s applies to IPv6 addresses only", entry.CIDR, name)
+		}
+		bits |= flag.bit
+	}
+	return bits, nil
+}
+
+func lookupAddressFlag(name string) (addressFlag, bool) {
+	for _, flag := range addressFlags {
+		if flag.name == name {
+			return flag, true
+		}
+	}
+	return addressFlag{}, false
+}
+
+// addressFlagNames lists the declarable flags set in bits, or "none".
+func addressFlagNames(bits int) string {
+	var names []string
+	for _, flag := range addressFlags {
+		if bits&flag.bit != 0 {
+			names = append(names, flag.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+// checkAddressFlags reports an existing address whose lasting flags differ
+// from the declared ones. The kernel does not change the flags of an IPv4
+// address in place, and removing it to add it again would interrupt
+// traffic, so without UpdateFlags the address is left as it is.
+func (n NetlinkExecutor) checkAddressFlags(key string, have, want *netlink.Addr) {
+	if have.Flags&lastingFlags == want.Flags&lastingFlags {
+		return
+	}
+	n.report("address %s has flags %s, want %s; set update_flags to change them",
+		key, addressFlagNames(have.Flags&lastingFlags), addressFlagNames(want.Flags&lastingFlags))
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestNetlinkExecutorSetsAddressFlags(t *testing.T) {
+	provider := &mockNetlinkProvider{}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{
+		{CIDR: "192.0.2.100/32", Flags: []string{"noprefixroute"}},
+		{CIDR: "2001:db8::10/64", Flags: []string{"nodad", "home"}},
+		{CIDR: "192.0.2.10/24"},
+	}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := map[string]int{
+		"192.0.2.100/32":  unix.IFA_F_NOPREFIXROUTE,
+		"2001:db8::10/64": unix.IFA_F_NODAD | unix.IFA_F_HOMEADDRESS,
+		"192.0.2.10/24":   0,
+	}
+	if len(provider.addedAddrs) != len(want) {
+		t.Fatalf("expected %d added addresses, got %v", len(want), provider.added)
+	}
+	for _, addr := range provider.addedAddrs {
+		if flags := want[addr.IPNet.String()]; addr.Flags != flags {
+			t.Fatalf("flags of %s = %#x, want %#x", addr.IPNet, addr.Flags, flags)
+		}
+	}
+}
+
+func TestNetlinkExecutorValidatesAddressFlags(t *testing.T) {
+	tests := []struct {
+		addr Address
+		want string
+	}{
+		{Address{CIDR: "192.0.2.10/24", Flags: []string{"noprefix"}}, `unknown flag "noprefix", want one of noprefixroute, nodad, home`},
+		{Address{CIDR: "192.0.2.10/24", Flags: []string{"nodad"}}, "flag nodad applies to IPv6 addresses only"},
+	}
+	for _, tt := range tests {
+		provider := &mockNetlinkProvider{}
+		exec := NetlinkExecutor{Provider: provider}
+		err := exec.Apply(Configuration{Interface: "eth0", Addresses: []Address{tt.addr}})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("Apply(%v) error = %v, want %q", tt.addr, err, tt.want)
+		}
+		if len(provider.added) != 0 {
+			t.Fatalf("expected no changes, got %v", provider.added)
+		}
+	}
+}
+
+func TestNetlinkExecutorReportsAddressFlagMismatch(t *testing.T) {
+	existing, err := netlink.ParseAddr("192.0.2.100/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing.Flags = unix.IFA_F_PERMANENT
+	provider := &mockNetlinkProvider{lists: map[int][]netlink.Addr{netlink.FAMILY_V4: {*existing}}}
+	var notes noteCollector
+	exec := NetlinkExecutor{Provider: provider, Reporter: &notes}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "192.0.2.100/32", Flags: []string{"noprefixroute"}}}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.added) != 0 || len(provider.removed) != 0 {
+		t.Fatalf("expected the address to be left alone, got added %v removed %v", provider.added, provider.removed)
+	}
+	want := "address 192.0.2.100/32 has flags none, want noprefixroute; set update_flags to change them"
+	if len(notes) != 1 || notes[0] != want {
+		t.Fatalf("notes = %q, want %q", notes, want)
+	}
+
+	notes = nil
+	provider.lists[netlink.FAMILY_V4][0].Flags |= unix.IFA_F_NOPREFIXROUTE
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(notes) != 0 {
+		t.Fatalf("expected no notes once the flags match, got %q", notes)
+	}
+}
+
+func TestAddressFlagsRoundTrip(t *testing.T) {
+	var addr Address
+	if err := json.Unmarshal([]byte(`{"address": "192.0.2.100/32", "flags": ["noprefixroute"]}`), &addr); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if got := addr.String(); got != "192.0.2.100/32 (noprefixroute)" {
+		t.Fatalf("String() = %q", got)
+	}
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// maxAddressLabel is the longest label the kernel keeps, that of an
+// interface name.
+const maxAddressLabel = unix.IFNAMSIZ - 1
+
+// parseAddressLabel returns the declared label of an IPv4 address; the
+// kernel labels IPv6 addresses with nothing. Like the aliases of ifconfig,
+// a label begins with the name of the interface, such as eth0:web.
+func parseAddressLabel(entry Address, addr *netlink.Addr, iface string) (string, error) {
+	if entry.Label == "" {
+		return "", nil
+	}
+	if addr.IP.To4() == nil {
+		return "", fmt.Errorf("address %s: labels apply to IPv4 addresses only", entry.CIDR)
+	}
+	if !strings.HasPrefix(entry.Label, iface) {
+		return "", fmt.Errorf("address %s: label %q must begin with the interface name %s", entry.CIDR, entry.Label, iface)
+	}
+	if len(entry.Label) > maxAddressLabel {
+		return "", fmt.Errorf("address %s: label %q is longer than %d characters", entry.CIDR, entry.Label, maxAddressLabel)
+	}
+	return entry.Label, nil
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func labeledAddr(t *testing.T, cidr, label string) netlink.Addr {
+	t.Helper()
+	addr := scopedAddr(t, cidr, unix.RT_SCOPE_UNIVERSE)
+	addr.Label = label
+	return addr
+}
+
+func TestNetlinkExecutorRecreatesAddressWithOtherLabel(t *testing.T) {
+	provider := &mockNetlinkProvider{lists: map[int][]netlink.Addr{netlink.FAMILY_V4: {
+		labeledAddr(t, "192.0.2.10/24", "eth0"),
+		labeledAddr(t, "192.0.2.11/24", "eth0:web"),
+	}}}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{
+		{CIDR: "192.0.2.10/24", Label: "eth0:api"},
+		{CIDR: "192.0.2.11/24", Label: "eth0:web"},
+	}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"192.0.2.10/24 eth0"}; !reflect.DeepEqual(provider.removed, want) {
+		t.Fatalf("removed %v, want %v", provider.removed, want)
+	}
+	if want := []string{"192.0.2.10/24 eth0:api"}; !reflect.DeepEqual(provider.added, want) {
+		t.Fatalf("added %v, want %v", provider.added, want)
+	}
+}
+
+func TestNetlinkExecutorUpdatesLabeledAddressInPlace(t *testing.T) {
+	provider := &mockNetlinkProvider{lists: map[int][]netlink.Addr{netlink.FAMILY_V4: {
+		labeledAddr(t, "192.0.2.10/24", "eth0:web"),
+		labeledAddr(t, "192.0.2.11/24", "eth0:api"),
+	}}}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{
+		{CIDR: "192.0.2.10/24", Label: "eth0:web", PreferredLifetime: lifetimeOf(0)},
+		// Without a declared label the address keeps its own.
+		{CIDR: "192.0.2.11/24", PreferredLifetime: lifetimeOf(0)},
+	}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"192.0.2.10/24 eth0:web", "192.0.2.11/24 eth0:api"}; !reflect.DeepEqual(provider.addrReplaced, want) || len(provider.removed) != 0 {
+		t.Fatalf("replaced %v, removed %v; want both updated in place with their labels", provider.addrReplaced, provider.removed)
+	}
+}
+
+func TestNetlinkExecutorKeepsUndeclaredLabelWhenRecreating(t *testing.T) {
+	provider := &mockNetlinkProvider{lists: map[int][]netlink.Addr{netlink.FAMILY_V4: {
+		labeledAddr(t, "192.0.2.10/24", "eth0:web"),
+	}}}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "192.0.2.10/24", Scope: "host"}}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"192.0.2.10/24 eth0:web"}; !reflect.DeepEqual(provider.added, want) {
+		t.Fatalf("added %v, want %v", provider.added, want)
+	}
+}
+
+func TestNetlinkExecutorValidatesAddressLabel(t *testing.T) {
+	tests := []struct {
+		addr Address
+		want string
+	}{
+		{Address{CIDR: "2001:db8::10/64", Label: "eth0:web"}, "labels apply to IPv4 addresses only"},
+		{Address{CIDR: "192.0.2.10/24", Label: "web"}, `label "web" must begin with the interface name eth0`},
+		{Address{CIDR: "192.0.2.10/24", Label: "eth0:webservers1"}, `label "eth0:webservers1" is longer than 15 characters`},
+	}
+	for _, tt := range tests {
+		provider := &mockNetlinkProvider{}
+		err := NetlinkExecutor{Provider: provider}.Apply(Configuration{Interface: "eth0", Addresses: []Address{tt.addr}})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("Apply(%v) error = %v, want %q", tt.addr, err, tt.want)
+		}
+		if len(provider.added) != 0 {
+			t.Fatalf("expected no changes, got %v", provider.added)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+// lifetimeForever is the kernel's INFINITY_LIFE_TIME, reported for
+// addresses that never expire.
+const lifetimeForever = math.MaxUint32
+
+// parseLifetimes returns the valid and preferred lifetimes of entry in
+// seconds, both 0 for an address that never expires. A ttl sets both.
+func parseLifetimes(entry Address) (valid, preferred int, err error) {
+	if entry.TTL > 0 && (entry.ValidLifetime != nil || entry.PreferredLifetime != nil) {
+		return 0, 0, fmt.Errorf("address %s: ttl cannot be combined with valid_lft or preferred_lft", entry.CIDR)
+	}
+	if lifetime := lifetimeSeconds(entry.TTL); lifetime > 0 {
+		return lifetime, lifetime, nil
+	}
+	if entry.ValidLifetime == nil && entry.PreferredLifetime == nil {
+		return 0, 0, nil
+	}
+	valid = lifetimeForever
+	if entry.ValidLifetime != nil {
+		if valid, err = finiteLifetime(entry, "valid_lft", *entry.ValidLifetime); err != nil {
+			return 0, 0, err
+		}
+		if valid == 0 {
+			return 0, 0, fmt.Errorf("address %s: valid_lft must be positive", entry.CIDR)
+		}
+	}
+	preferred = valid
+	if entry.PreferredLifetime != nil {
+		if preferred, err = finiteLifetime(entry, "preferred_lft", *entry.PreferredLifetime); err != nil {
+			return 0, 0, err
+		}
+		if preferred > valid {
+			return 0, 0, fmt.Errorf("address %s: preferred_lft %s exceeds valid_lft %s", entry.CIDR,
+				time.Duration(*entry.PreferredLifetime), time.Duration(*entry.ValidLifetime))
+		}
+	}
+	return valid, preferred, nil
+}
+
+// finiteLifetime converts a declared lifetime, which must be shorter than
+// the kernel's forever.
+func finiteLifetime(entry Address, field string, d Duration) (int, error) {
+	seconds := lifetimeSeconds(d)
+	if seconds >= lifetimeForever {
+		return 0, fmt.Errorf("address %s: %s %s is too long; leave it out to keep the address forever", entry.CIDR, field, time.Duration(d))
+	}
+	return seconds, nil
+}
+
+// lifetimesMatch tells whether the remaining lifetimes of have satisfy those
+// wanted. A finite lifetime counts down, so any remaining time up to the
+// wanted one matches; re-applying a configuration does not extend it.
+func lifetimesMatch(have, want *netlink.Addr) bool {
+	haveValid, havePreferred := lifetimes(have)
+	wantValid, wantPreferred := lifetimes(want)
+	return lifetimeMatches(haveValid, wantValid) && lifetimeMatches(havePreferred, wantPreferred)
+}
+
+func lifetimeMatches(have, want int) bool {
+	if want == lifetimeForever || have == lifetimeForever {
+		return have == want
+	}
+	return have <= want
+}
+
+// lifetimes returns the lifetimes of addr, treating the 0/0 of addresses
+// added without lifetimes as forever.
+func lifetimes(addr *netlink.Addr) (valid, preferred int) {
+	if addr.ValidLft == 0 && addr.PreferedLft == 0 {
+		return lifetimeForever, lifetimeForever
+	}
+	return addr.ValidLft, addr.PreferedLft
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+func lifetimeOf(d time.Duration) *Duration {
+	lifetime := Duration(d)
+	return &lifetime
+}
+
+func TestNetlinkExecutorSetsAddressLifetimes(t *testing.T) {
+	provider := &mockNetlinkProvider{}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{
+		{CIDR: "2001:db8:1::10/64", ValidLifetime: lifetimeOf(time.Hour), PreferredLifetime: lifetimeOf(0)},
+		{CIDR: "2001:db8:2::10/64", PreferredLifetime: lifetimeOf(0)},
+		{CIDR: "2001:db8:3::10/64", ValidLifetime: lifetimeOf(time.Hour)},
+	}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := map[string][2]int{
+		"2001:db8:1::10/64": {3600, 0},
+		"2001:db8:2::10/64": {lifetimeForever, 0},
+		"2001:db8:3::10/64": {3600, 3600},
+	}
+	for _, addr := range provider.addedAddrs {
+		if got := [2]int{addr.ValidLft, addr.PreferedLft}; got != want[addr.IPNet.String()] {
+			t.Fatalf("lifetimes of %s = %v, want %v", addr.IPNet, got, want[addr.IPNet.String()])
+		}
+	}
+}
+
+func TestNetlinkExecutorValidatesAddressLifetimes(t *testing.T) {
+	tests := []struct {
+		addr Address
+		want string
+	}{
+		{Address{CIDR: "192.0.2.10/24", TTL: Duration(time.Hour), PreferredLifetime: lifetimeOf(0)}, "ttl cannot be combined"},
+		{Address{CIDR: "192.0.2.10/24", ValidLifetime: lifetimeOf(0)}, "valid_lft must be positive"},
+		{Address{CIDR: "192.0.2.10/24", ValidLifetime: lifetimeOf(time.Minute), PreferredLifetime: lifetimeOf(time.Hour)}, "preferred_lft 1h0m0s exceeds valid_lft 1m0s"},
+		{Address{CIDR: "192.0.2.10/24", PreferredLifetime: lifetimeOf(200 * 365 * 24 * time.Hour)}, "is too long"},
+	}
+	for _, tt := range tests {
+		provider := &mockNetlinkProvider{}
+		err := NetlinkExecutor{Provider: provider}.Apply(Configuration{Interface: "eth0", Addresses: []Address{tt.addr}})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("Apply(%v) error = %v, want %q", tt.addr, err, tt.want)
+		}
+	}
+}
+
+func TestNetlinkExecutorComparesAddressLifetimes(t *testing.T) {
+	existing := func(cidr string, valid, preferred int) netlink.Addr {
+		addr, err := netlink.ParseAddr(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr.ValidLft, addr.PreferedLft = valid, preferred
+		return *addr
+	}
+	provider := &mockNetlinkProvider{lists: map[int][]netlink.Addr{netlink.FAMILY_V6: {
+		existing("2001:db8:1::10/64", lifetimeForever, lifetimeForever),
+		existing("2001:db8:2::10/64", 3500, 3500),
+		existing("2001:db8:3::10/64", lifetimeForever, 0),
+		existing("2001:db8:4::10/64", lifetimeForever, lifetimeForever),
+	}}}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{
+		{CIDR: "2001:db8:1::10/64", PreferredLifetime: lifetimeOf(0)},
+		{CIDR: "2001:db8:2::10/64", TTL: Duration(time.Hour)},
+		{CIDR: "2001:db8:3::10/64"},
+		{CIDR: "2001:db8:4::10/64"},
+	}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{"2001:db8:1::10/64", "2001:db8:3::10/64"}
+	if strings.Join(provider.addrReplaced, " ") != strings.Join(want, " ") {
+		t.Fatalf("replaced %v, want %v", provider.addrReplaced, want)
+	}
+	if len(provider.added) != 0 || len(provider.removed) != 0 {
+		t.Fatalf("expected updates in place, got added %v removed %v", provider.added, provider.removed)
+	}
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/vishvananda/netlink"
+)
+
+// parseAddressPeer returns the remote end of a point-to-point address. The
+// prefix length of the address applies to the peer, as with ip's peer
+// keyword.
+func parseAddressPeer(entry Address, addr *netlink.Addr) (*net.IPNet, error) {
+	if entry.Peer == "" {
+		return nil, nil
+	}
+	ip := net.ParseIP(entry.Peer)
+	if ip == nil {
+		return nil, fmt.Errorf("address %s: peer %q is not an IP address", entry.CIDR, entry.Peer)
+	}
+	if (ip.To4() == nil) != (addr.IP.To4() == nil) {
+		return nil, fmt.Errorf("address %s: peer %s is not of the same family", entry.CIDR, entry.Peer)
+	}
+	return &net.IPNet{IP: ip, Mask: addr.Mask}, nil
+}
+
+// addressPeer returns the peer of addr, or nil when it has none. The kernel
+// reports the local address as peer of an address without one.
+func addressPeer(addr *netlink.Addr) net.IP {
+	if addr.Peer == nil || addr.Peer.IP.Equal(addr.IP) {
+		return nil
+	}
+	return addr.Peer.IP
+}
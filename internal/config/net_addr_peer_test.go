@@ -0,0 +1,71 @@
+package config
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestNetlinkExecutorSetsAddressPeer(t *testing.T) {
+	provider := &mockNetlinkProvider{}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "gre1", Addresses: []Address{{CIDR: "10.255.0.1/32", Peer: "10.255.0.2"}}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.addedAddrs) != 1 {
+		t.Fatalf("expected one added address, got %v", provider.added)
+	}
+	peer := provider.addedAddrs[0].Peer
+	if peer == nil || peer.String() != "10.255.0.2/32" {
+		t.Fatalf("peer = %v, want 10.255.0.2/32", peer)
+	}
+}
+
+func TestNetlinkExecutorReplacesAddressWithOtherPeer(t *testing.T) {
+	moved, err := netlink.ParseAddr("10.255.0.1/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved.Peer = &net.IPNet{IP: net.ParseIP("10.255.0.9"), Mask: moved.Mask}
+	kept, err := netlink.ParseAddr("10.255.1.1/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept.Peer = &net.IPNet{IP: net.ParseIP("10.255.1.2"), Mask: kept.Mask}
+	provider := &mockNetlinkProvider{lists: map[int][]netlink.Addr{netlink.FAMILY_V4: {*moved, *kept}}}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "gre1", Addresses: []Address{
+		{CIDR: "10.255.0.1/32", Peer: "10.255.0.2"},
+		{CIDR: "10.255.1.1/32", Peer: "10.255.1.2"},
+	}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"10.255.0.1/32"}; !reflect.DeepEqual(provider.removed, want) || len(provider.added) != 1 {
+		t.Fatalf("expected 10.255.0.1/32 to be replaced alone, got removed %v added %v", provider.removed, provider.added)
+	}
+}
+
+func TestNetlinkExecutorValidatesAddressPeer(t *testing.T) {
+	tests := []struct {
+		addr Address
+		want string
+	}{
+		{Address{CIDR: "10.255.0.1/32", Peer: "10.255.0.2/32"}, `peer "10.255.0.2/32" is not an IP address`},
+		{Address{CIDR: "10.255.0.1/32", Peer: "2001:db8::2"}, "peer 2001:db8::2 is not of the same family"},
+	}
+	for _, tt := range tests {
+		provider := &mockNetlinkProvider{}
+		err := NetlinkExecutor{Provider: provider}.Apply(Configuration{Interface: "gre1", Addresses: []Address{tt.addr}})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("Apply(%v) error = %v, want %q", tt.addr, err, tt.want)
+		}
+		if len(provider.added) != 0 {
+			t.Fatalf("expected no changes, got %v", provider.added)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/vishvananda/netlink"
+)
+
+// addrOp is a change to one address. Changes run in the order of the
+// constants: those that never leave the interface without an address first,
+// removals last.
+type addrOp int
+
+const (
+	// addrUpdate changes lifetimes or IPv6 flags in place (RTM_NEWADDR with
+	// NLM_F_REPLACE).
+	addrUpdate addrOp = iota
+	// addrAdd adds a missing address.
+	addrAdd
+	// addrRecreate removes an address and adds it back with attributes the
+	// kernel cannot change in place: its scope, peer, label or IPv4 flags.
+	addrRecreate
+	// addrRemove removes an undeclared address.
+	addrRemove
+)
+
+// addrStep is one planned address change.
+type addrStep struct {
+	op   addrOp
+	key  string
+	have *netlink.Addr
+	want *netlink.Addr
+}
+
+// planAddresses computes every change needed to turn the current addresses
+// into the desired ones before any of them is made, at most one round trip
+// per address (two for a recreate). Lasting flags that differ are updated
+// when updateFlags is set: in place for IPv6, by recreating the address for
+// IPv4. Otherwise they are reported and left as they are.
+func (n NetlinkExecutor) planAddresses(p plan, current map[string]*netlink.Addr, updateFlags bool) []addrStep {
+	var steps []addrStep
+	for _, key := range sortedKeys(p.desired) {
+		want := p.desired[key]
+		have, ok := current[key]
+		if !ok {
+			steps = append(steps, addrStep{op: addrAdd, key: key, want: want})
+			continue
+		}
+		if want.Label == "" {
+			// An address without a declared label keeps the one it has.
+			kept := *want
+			kept.Label = have.Label
+			want = &kept
+		}
+		if (p.scoped[key] && have.Scope != want.Scope) || !addressPeer(have).Equal(addressPeer(want)) || have.Label != want.Label {
+			steps = append(steps, addrStep{op: addrRecreate, key: key, have: have, want: want})
+			continue
+		}
+		flagsMatch := have.Flags&lastingFlags == want.Flags&lastingFlags
+		if !flagsMatch && updateFlags && want.IP.To4() != nil {
+			steps = append(steps, addrStep{op: addrRecreate, key: key, have: have, want: want})
+			continue
+		}
+		update := *want
+		if lifetimesMatch(have, want) {
+			if flagsMatch || !updateFlags {
+				n.checkAddressFlags(key, have, want)
+				continue
+			}
+			update.ValidLft, update.PreferedLft = have.ValidLft, have.PreferedLft
+		} else if !updateFlags {
+			n.checkAddressFlags(key, have, want)
+			update.Flags = want.Flags&^lastingFlags | have.Flags&lastingFlags
+		}
+		steps = append(steps, addrStep{op: addrUpdate, key: key, have: have, want: &update})
+	}
+	for _, key := range sortedKeys(current) {
+		if _, ok := p.desired[key]; !ok && p.owns(current[key]) {
+			steps = append(steps, addrStep{op: addrRemove, key: key, have: current[key]})
+		}
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].op < steps[j].op })
+	return steps
+}
+
+// applyAddresses makes the planned changes in order.
+func (n NetlinkExecutor) applyAddresses(link netlink.Link, steps []addrStep) error {
+	for _, step := range steps {
+		switch step.op {
+		case addrUpdate:
+			if err := n.Provider.AddrReplace(link, step.want); err != nil {
+				return fmt.Errorf("update address %s: %w", step.key, err)
+			}
+		case addrAdd:
+			if err := n.Provider.AddrAdd(link, step.want); err != nil {
+				return fmt.Errorf("add address %s: %w", step.key, err)
+			}
+		case addrRecreate:
+			if err := n.replaceAddress(link, step.key, step.have, step.want); err != nil {
+				return err
+			}
+		case addrRemove:
+			if err := n.Provider.AddrDel(link, step.have); err != nil {
+				return fmt.Errorf("remove address %s: %w", step.key, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// orderedProvider records address changes in the order they are made.
+type orderedProvider struct {
+	*mockNetlinkProvider
+	ops []string
+}
+
+func (o *orderedProvider) AddrAdd(link netlink.Link, addr *netlink.Addr) error {
+	o.ops = append(o.ops, "add "+addr.IPNet.String())
+	return o.mockNetlinkProvider.AddrAdd(link, addr)
+}
+
+func (o *orderedProvider) AddrReplace(link netlink.Link, addr *netlink.Addr) error {
+	o.ops = append(o.ops, "update "+addr.IPNet.String())
+	return o.mockNetlinkProvider.AddrReplace(link, addr)
+}
+
+func (o *orderedProvider) AddrDel(link netlink.Link, addr *netlink.Addr) error {
+	o.ops = append(o.ops, "remove "+addr.IPNet.String())
+	return o.mockNetlinkProvider.AddrDel(link, addr)
+}
+
+func v4Addr(t *testing.T, cidr string) netlink.Addr {
+	t.Helper()
+	addr, err := netlink.ParseAddr(cidr)
+	if err != nil {
+		t.Fatalf("ParseAddr(%q) error = %v", cidr, err)
+	}
+	return *addr
+}
+
+func TestNetlinkExecutorOrdersAddressChanges(t *testing.T) {
+	peered := v4Addr(t, "10.255.0.1/32")
+	peered.Peer = &net.IPNet{IP: net.ParseIP("10.255.0.2").To4(), Mask: net.CIDRMask(32, 32)}
+	deprecated := v6Addr(t, "2001:db8:1::10/64", 0)
+	deprecated.ValidLft, deprecated.PreferedLft = lifetimeForever, 0
+	provider := &orderedProvider{mockNetlinkProvider: &mockNetlinkProvider{lists: map[int][]netlink.Addr{
+		netlink.FAMILY_V4: {v4Addr(t, "192.0.2.10/24"), peered},
+		netlink.FAMILY_V6: {deprecated},
+	}}}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{
+		{CIDR: "192.0.2.20/24"},
+		{CIDR: "10.255.0.1/32", Peer: "10.255.0.3"},
+		{CIDR: "2001:db8:1::10/64"},
+	}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{
+		"update 2001:db8:1::10/64",
+		"add 192.0.2.20/24",
+		"remove 10.255.0.1/32", "add 10.255.0.1/32",
+		"remove 192.0.2.10/24",
+	}
+	if !reflect.DeepEqual(provider.ops, want) {
+		t.Fatalf("address changes = %v, want %v", provider.ops, want)
+	}
+}
+
+func TestNetlinkExecutorUpdatesAddressFlags(t *testing.T) {
+	temporary := v6Addr(t, "2001:db8::10/64", 0)
+	temporary.ValidLft, temporary.PreferedLft = 3500, 3500
+	provider := &orderedProvider{mockNetlinkProvider: &mockNetlinkProvider{lists: map[int][]netlink.Addr{
+		netlink.FAMILY_V4: {v4Addr(t, "192.0.2.100/32")},
+		netlink.FAMILY_V6: {temporary},
+	}}}
+	var notes noteCollector
+	cfg := Configuration{Interface: "eth0", UpdateFlags: true, Addresses: []Address{
+		{CIDR: "192.0.2.100/32", Flags: []string{"noprefixroute"}},
+		{CIDR: "2001:db8::10/64", Flags: []string{"noprefixroute"}, TTL: Duration(time.Hour)},
+	}}
+	if err := (NetlinkExecutor{Provider: provider, Reporter: &notes}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{"update 2001:db8::10/64", "remove 192.0.2.100/32", "add 192.0.2.100/32"}
+	if !reflect.DeepEqual(provider.ops, want) {
+		t.Fatalf("address changes = %v, want %v", provider.ops, want)
+	}
+	updated := provider.addedAddrs[0]
+	if updated.Flags != unix.IFA_F_NOPREFIXROUTE || updated.ValidLft != 3500 || updated.PreferedLft != 3500 {
+		t.Fatalf("updated %s with flags %#x and lifetimes %d/%d, want noprefixroute keeping 3500/3500",
+			updated.IPNet, updated.Flags, updated.ValidLft, updated.PreferedLft)
+	}
+	if len(notes) != 0 {
+		t.Fatalf("expected no notes, got %v", notes)
+	}
+}
+
+func TestNetlinkExecutorKeepsAddressFlagsOnLifetimeUpdate(t *testing.T) {
+	provider := &mockNetlinkProvider{lists: map[int][]netlink.Addr{
+		netlink.FAMILY_V6: {v6Addr(t, "2001:db8::10/64", unix.IFA_F_HOMEADDRESS)},
+	}}
+	var notes noteCollector
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "2001:db8::10/64", PreferredLifetime: lifetimeOf(0)}}}
+	if err := (NetlinkExecutor{Provider: provider, Reporter: &notes}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.addrReplaced) != 1 || provider.addedAddrs[0].Flags != unix.IFA_F_HOMEADDRESS {
+		t.Fatalf("expected a lifetime update keeping the home flag, got %v %v", provider.addrReplaced, provider.addedAddrs)
+	}
+	want := noteCollector{"address 2001:db8::10/64 has flags home, want none; set update_flags to change them"}
+	if !reflect.DeepEqual(notes, want) {
+		t.Fatalf("notes = %v, want %v", notes, want)
+	}
+}
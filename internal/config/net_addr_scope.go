@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// addressScopes maps the declarable scope names to their netlink values.
+var addressScopes = map[string]int{
+	"global": unix.RT_SCOPE_UNIVERSE,
+	"link":   unix.RT_SCOPE_LINK,
+	"host":   unix.RT_SCOPE_HOST,
+}
+
+// parseAddressScope converts the declared scope name. IPv6 addresses take
+// their scope from the prefix and the kernel ignores a requested one, so a
+// scope is only accepted on IPv4 addresses.
+func parseAddressScope(entry Address, addr *netlink.Addr) (int, error) {
+	if entry.Scope == "" {
+		return unix.RT_SCOPE_UNIVERSE, nil
+	}
+	scope, ok := addressScopes[entry.Scope]
+	if !ok {
+		return 0, fmt.Errorf("address %s: scope must be global, link or host, got %q", entry.CIDR, entry.Scope)
+	}
+	if addr.IP.To4() == nil {
+		return 0, fmt.Errorf("address %s: scope applies to IPv4 addresses only; IPv6 addresses take theirs from the prefix", entry.CIDR)
+	}
+	return scope, nil
+}
+
+// scopedAddresses returns the keys of the addresses that declare a scope.
+// Only those are compared on scope, so an address such as 127.0.0.1/8,
+// which the kernel gives host scope, is not replaced for lack of one.
+func scopedAddresses(raw []Address) map[string]bool {
+	scoped := make(map[string]bool)
+	for _, entry := range raw {
+		if entry.Scope == "" {
+			continue
+		}
+		if addr, err := netlink.ParseAddr(entry.CIDR); err == nil {
+			scoped[addr.IPNet.String()] = true
+		}
+	}
+	return scoped
+}
+
+// replaceAddress removes have and adds want in its place. The kernel does not
+// change the scope, peer or label of an existing address: RTM_NEWADDR with
+// NLM_F_REPLACE only updates its lifetimes and, for IPv6, its flags, which
+// planAddresses therefore changes in place.
+func (n NetlinkExecutor) replaceAddress(link netlink.Link, key string, have, want *netlink.Addr) error {
+	if err := n.Provider.AddrDel(link, have); err != nil {
+		return fmt.Errorf("replace address %s: %w", key, err)
+	}
+	if err := n.Provider.AddrAdd(link, want); err != nil {
+		return fmt.Errorf("replace address %s: %w", key, err)
+	}
+	return nil
+}
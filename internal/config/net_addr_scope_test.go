@@ -0,0 +1,92 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func scopedAddr(t *testing.T, cidr string, scope int) netlink.Addr {
+	t.Helper()
+	addr, err := netlink.ParseAddr(cidr)
+	if err != nil {
+		t.Fatalf("ParseAddr(%q) error = %v", cidr, err)
+	}
+	addr.Scope = scope
+	return *addr
+}
+
+func TestNetlinkExecutorSetsAddressScope(t *testing.T) {
+	provider := &mockNetlinkProvider{}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{
+		{CIDR: "169.254.10.1/16", Scope: "link"},
+		{CIDR: "192.0.2.10/24"},
+	}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := map[string]int{"169.254.10.1/16": unix.RT_SCOPE_LINK, "192.0.2.10/24": unix.RT_SCOPE_UNIVERSE}
+	for _, addr := range provider.addedAddrs {
+		if addr.Scope != want[addr.IPNet.String()] {
+			t.Fatalf("scope of %s = %d, want %d", addr.IPNet, addr.Scope, want[addr.IPNet.String()])
+		}
+	}
+}
+
+func TestNetlinkExecutorReplacesAddressWithOtherScope(t *testing.T) {
+	provider := &mockNetlinkProvider{lists: map[int][]netlink.Addr{netlink.FAMILY_V4: {
+		scopedAddr(t, "192.0.2.10/24", unix.RT_SCOPE_UNIVERSE),
+		scopedAddr(t, "127.0.0.2/8", unix.RT_SCOPE_HOST),
+	}}}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{
+		{CIDR: "192.0.2.10/24", Scope: "host"},
+		{CIDR: "127.0.0.2/8"},
+	}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"192.0.2.10/24"}; !reflect.DeepEqual(provider.removed, want) || len(provider.added) != 1 {
+		t.Fatalf("expected 192.0.2.10/24 to be replaced alone, got removed %v added %v", provider.removed, provider.added)
+	}
+	if provider.addedAddrs[0].Scope != unix.RT_SCOPE_HOST {
+		t.Fatalf("expected host scope, got %d", provider.addedAddrs[0].Scope)
+	}
+}
+
+func TestNetlinkExecutorUpdatesScopedAddressLifetimeInPlace(t *testing.T) {
+	provider := &mockNetlinkProvider{lists: map[int][]netlink.Addr{netlink.FAMILY_V4: {
+		scopedAddr(t, "169.254.10.1/16", unix.RT_SCOPE_LINK),
+	}}}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "169.254.10.1/16", Scope: "link", PreferredLifetime: lifetimeOf(0)}}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"169.254.10.1/16"}; !reflect.DeepEqual(provider.addrReplaced, want) || len(provider.removed) != 0 {
+		t.Fatalf("replaced %v, removed %v; want the address updated in place", provider.addrReplaced, provider.removed)
+	}
+}
+
+func TestNetlinkExecutorValidatesAddressScope(t *testing.T) {
+	tests := []struct {
+		addr Address
+		want string
+	}{
+		{Address{CIDR: "192.0.2.10/24", Scope: "site"}, `scope must be global, link or host, got "site"`},
+		{Address{CIDR: "2001:db8::10/64", Scope: "link"}, "scope applies to IPv4 addresses only"},
+	}
+	for _, tt := range tests {
+		provider := &mockNetlinkProvider{}
+		err := NetlinkExecutor{Provider: provider}.Apply(Configuration{Interface: "eth0", Addresses: []Address{tt.addr}})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("Apply(%v) error = %v, want %q", tt.addr, err, tt.want)
+		}
+		if len(provider.added) != 0 {
+			t.Fatalf("expected no changes, got %v", provider.added)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	defaultAnnounceCount    = 3
+	defaultAnnounceInterval = time.Second
+)
+
+// AnnounceProvider is implemented by providers that can announce addresses
+// to the neighbors of a link. It is optional; configurations with an
+// announce section require it.
+type AnnounceProvider interface {
+	// AnnounceAddresses sends count gratuitous ARPs or unsolicited Neighbor
+	// Advertisements for each of ips, interval apart.
+	AnnounceAddresses(link netlink.Link, ips []net.IP, count int, interval time.Duration) error
+}
+
+// String describes a for the console.
+func (a Announce) String() string {
+	return fmt.Sprintf("announce added addresses to neighbors %d times, %s apart", a.count(), a.interval())
+}
+
+func (a Announce) count() int {
+	if a.Count == 0 {
+		return defaultAnnounceCount
+	}
+	return a.Count
+}
+
+func (a Announce) interval() time.Duration {
+	if a.Interval == 0 {
+		return defaultAnnounceInterval
+	}
+	return time.Duration(a.Interval)
+}
+
+func (n NetlinkExecutor) validateAnnounce(cfg Configuration) error {
+	if cfg.Announce == nil {
+		return nil
+	}
+	if _, ok := n.Provider.(AnnounceProvider); !ok {
+		return errors.New("netlink provider cannot announce addresses")
+	}
+	if cfg.Announce.Count < 0 {
+		return fmt.Errorf("announce for %s: count %d is negative", cfg.Interface, cfg.Announce.Count)
+	}
+	if cfg.Announce.Interval < 0 {
+		return fmt.Errorf("announce for %s: interval %s is negative", cfg.Interface, time.Duration(cfg.Announce.Interval))
+	}
+	return nil
+}
+
+// announceAddresses announces the addresses that steps added or recreated.
+// Host scoped addresses are never seen by neighbors, and IPv6 addresses
+// that are still tentative must not be advertised (RFC 4862 section 5.4),
+// so both are left out; tentative ones are reported.
+func (n NetlinkExecutor) announceAddresses(cfg Configuration, link netlink.Link, steps []addrStep) error {
+	if cfg.Announce == nil {
+		return nil
+	}
+	var added []*netlink.Addr
+	v6 := false
+	for _, step := range steps {
+		if (step.op == addrAdd || step.op == addrRecreate) && step.want.Scope != unix.RT_SCOPE_HOST {
+			added = append(added, step.want)
+			v6 = v6 || step.want.IP.To4() == nil
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	unusable := make(map[string]bool)
+	if v6 {
+		addrs, err := n.Provider.AddrList(link, netlink.FAMILY_V6)
+		if err != nil {
+			return fmt.Errorf("list addresses for family %d: %w", netlink.FAMILY_V6, err)
+		}
+		for _, addr := range addrs {
+			if addr.Flags&(unix.IFA_F_TENTATIVE|unix.IFA_F_DADFAILED) != 0 {
+				unusable[addr.IPNet.String()] = true
+			}
+		}
+	}
+	var ips []net.IP
+	for _, addr := range added {
+		if key := addr.IPNet.String(); unusable[key] {
+			n.report("address %s on %s is tentative and was not announced", key, cfg.Interface)
+			continue
+		}
+		ips = append(ips, addr.IP)
+	}
+	if len(ips) == 0 {
+		return nil
+	}
+	provider := n.Provider.(AnnounceProvider)
+	if err := provider.AnnounceAddresses(link, ips, cfg.Announce.count(), cfg.Announce.interval()); err != nil {
+		return fmt.Errorf("announce addresses of %s: %w", cfg.Interface, err)
+	}
+	return nil
+}
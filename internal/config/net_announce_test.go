@@ -0,0 +1,113 @@
+package config
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// announceProvider lists the addresses it adds, IPv6 ones as tentative
+// unless they skip duplicate address detection, and records what is
+// announced.
+type announceProvider struct {
+	*mockNetlinkProvider
+	announced []string
+	count     int
+	interval  time.Duration
+}
+
+func (a *announceProvider) AddrAdd(link netlink.Link, addr *netlink.Addr) error {
+	if err := a.mockNetlinkProvider.AddrAdd(link, addr); err != nil {
+		return err
+	}
+	listed := *addr
+	family := netlink.FAMILY_V4
+	if addr.IP.To4() == nil {
+		family = netlink.FAMILY_V6
+		if addr.Flags&unix.IFA_F_NODAD == 0 {
+			listed.Flags |= unix.IFA_F_TENTATIVE
+		}
+	}
+	a.lists[family] = append(a.lists[family], listed)
+	return nil
+}
+
+func (a *announceProvider) AnnounceAddresses(link netlink.Link, ips []net.IP, count int, interval time.Duration) error {
+	for _, ip := range ips {
+		a.announced = append(a.announced, ip.String())
+	}
+	a.count, a.interval = count, interval
+	return nil
+}
+
+func newAnnounceProvider(t *testing.T, present ...string) *announceProvider {
+	t.Helper()
+	lists := map[int][]netlink.Addr{}
+	for _, cidr := range present {
+		addr, err := netlink.ParseAddr(cidr)
+		if err != nil {
+			t.Fatalf("ParseAddr(%q) error = %v", cidr, err)
+		}
+		lists[addrFamily(addr)] = append(lists[addrFamily(addr)], *addr)
+	}
+	return &announceProvider{mockNetlinkProvider: &mockNetlinkProvider{link: &fakeLink{netlink.LinkAttrs{Name: "eth0"}}, lists: lists}}
+}
+
+func TestNetlinkExecutorAnnouncesAddedAddresses(t *testing.T) {
+	provider := newAnnounceProvider(t, "192.0.2.10/24")
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{
+		{CIDR: "192.0.2.10/24"},
+		{CIDR: "192.0.2.20/24"},
+		{CIDR: "192.0.2.30/24", Scope: "host"},
+		{CIDR: "2001:db8::10/64", Flags: []string{"nodad"}},
+	}, Announce: &Announce{}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"192.0.2.20", "2001:db8::10"}; !reflect.DeepEqual(provider.announced, want) {
+		t.Fatalf("announced %v, want only the added addresses in reach of neighbors %v", provider.announced, want)
+	}
+	if provider.count != defaultAnnounceCount || provider.interval != defaultAnnounceInterval {
+		t.Fatalf("announced %d times %s apart, want the defaults", provider.count, provider.interval)
+	}
+}
+
+func TestNetlinkExecutorDoesNotAnnounceTentativeAddresses(t *testing.T) {
+	provider := newAnnounceProvider(t)
+	var notes noteCollector
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "2001:db8::10/64"}}, Announce: &Announce{Count: 1}}
+	if err := (NetlinkExecutor{Provider: provider, Reporter: &notes}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.announced) != 0 {
+		t.Fatalf("announced %v while still tentative", provider.announced)
+	}
+	if len(notes) != 1 || !strings.Contains(notes[0], "2001:db8::10/64 on eth0 is tentative") {
+		t.Fatalf("unexpected notes: %v", notes)
+	}
+}
+
+func TestNetlinkExecutorValidatesAnnounce(t *testing.T) {
+	provider := newAnnounceProvider(t)
+	tests := []struct {
+		cfg      Configuration
+		provider NetlinkProvider
+		want     string
+	}{
+		{Configuration{Announce: &Announce{}}, provider.mockNetlinkProvider, "cannot announce addresses"},
+		{Configuration{Announce: &Announce{Count: -1}}, provider, "count -1 is negative"},
+		{Configuration{Announce: &Announce{Interval: Duration(-time.Second)}}, provider, "interval -1s is negative"},
+	}
+	for _, tt := range tests {
+		tt.cfg.Interface = "eth0"
+		err := NetlinkExecutor{Provider: tt.provider}.Apply(tt.cfg)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("Apply(%+v) error = %v, want %q", tt.cfg, err, tt.want)
+		}
+	}
+}
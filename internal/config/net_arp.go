@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ARP settings are sysctls of net.ipv4.conf.<interface>.
+const (
+	sysctlProxyARP    = "proxy_arp"
+	sysctlARPIgnore   = "arp_ignore"
+	sysctlARPAnnounce = "arp_announce"
+)
+
+// Values the kernel accepts for arp_ignore and arp_announce (see
+// ip-sysctl.rst).
+var (
+	arpIgnoreModes   = []int{0, 1, 2, 3, 8}
+	arpAnnounceModes = []int{0, 1, 2}
+)
+
+// String lists the ARP settings that are set.
+func (a ARP) String() string {
+	var parts []string
+	if a.ProxyARP != nil {
+		parts = append(parts, "proxy_arp "+onOff(*a.ProxyARP))
+	}
+	if a.Ignore != nil {
+		parts = append(parts, "arp_ignore "+strconv.Itoa(*a.Ignore))
+	}
+	if a.Announce != nil {
+		parts = append(parts, "arp_announce "+strconv.Itoa(*a.Announce))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// arpSysctls converts the arp section to the sysctls that implement it, by
+// sysctl(8) name.
+func arpSysctls(cfg Configuration) (map[string]string, error) {
+	section := cfg.ARP
+	if section == nil {
+		return nil, nil
+	}
+	sysctls := make(map[string]string)
+	prefix := "net.ipv4.conf." + SysctlName(cfg.Interface) + "."
+	if section.ProxyARP != nil {
+		value := "0"
+		if *section.ProxyARP {
+			value = "1"
+		}
+		sysctls[prefix+sysctlProxyARP] = value
+	}
+	if section.Ignore != nil {
+		if !slices.Contains(arpIgnoreModes, *section.Ignore) {
+			return nil, fmt.Errorf("arp for %s: ignore %d is not one of %s", cfg.Interface, *section.Ignore, joinInts(arpIgnoreModes))
+		}
+		sysctls[prefix+sysctlARPIgnore] = strconv.Itoa(*section.Ignore)
+	}
+	if section.Announce != nil {
+		if !slices.Contains(arpAnnounceModes, *section.Announce) {
+			return nil, fmt.Errorf("arp for %s: announce %d is not one of %s", cfg.Interface, *section.Announce, joinInts(arpAnnounceModes))
+		}
+		sysctls[prefix+sysctlARPAnnounce] = strconv.Itoa(*section.Announce)
+	}
+	return sysctls, nil
+}
+
+// reportARPOverrides notes ARP settings of net.ipv4.conf.all that take
+// precedence over those of the interface: the kernel enables proxy ARP when
+// either is set and uses the higher arp_ignore and arp_announce.
+func (n NetlinkExecutor) reportARPOverrides(cfg Configuration) {
+	section := cfg.ARP
+	if section == nil {
+		return
+	}
+	provider := n.Provider.(SysctlProvider)
+	check := func(name string, want int) {
+		raw, err := provider.Sysctl("net/ipv4/conf/all/" + name)
+		if err != nil {
+			return
+		}
+		all, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || all <= want {
+			return
+		}
+		n.report("net.ipv4.conf.all.%s is %d, which takes precedence over %d on %s", name, all, want, cfg.Interface)
+	}
+	if section.ProxyARP != nil && !*section.ProxyARP {
+		check(sysctlProxyARP, 0)
+	}
+	if section.Ignore != nil {
+		check(sysctlARPIgnore, *section.Ignore)
+	}
+	if section.Announce != nil {
+		check(sysctlARPAnnounce, *section.Announce)
+	}
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = strconv.Itoa(value)
+	}
+	return strings.Join(parts, ", ")
+}
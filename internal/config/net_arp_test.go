@@ -0,0 +1,56 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestNetlinkExecutorSetsARP(t *testing.T) {
+	on, ignore, announce := true, 1, 2
+	provider := &sysctlProvider{
+		mockNetlinkProvider: &mockNetlinkProvider{link: &fakeLink{netlink.LinkAttrs{Name: "eth0", Index: 2}}},
+		values: map[string]string{
+			"net/ipv4/conf/eth0/proxy_arp":    "0",
+			"net/ipv4/conf/eth0/arp_ignore":   "0",
+			"net/ipv4/conf/eth0/arp_announce": "2",
+			"net/ipv4/conf/all/arp_ignore":    "2",
+			"net/ipv4/conf/all/arp_announce":  "0",
+		},
+	}
+	var notes noteCollector
+	cfg := Configuration{Interface: "eth0", ARP: &ARP{ProxyARP: &on, Ignore: &ignore, Announce: &announce}}
+	if err := (NetlinkExecutor{Provider: provider, Reporter: &notes}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{"net/ipv4/conf/eth0/arp_ignore=1", "net/ipv4/conf/eth0/proxy_arp=1"}
+	if !reflect.DeepEqual(provider.set, want) {
+		t.Fatalf("set %v, want %v", provider.set, want)
+	}
+	wantNotes := noteCollector{"net.ipv4.conf.all.arp_ignore is 2, which takes precedence over 1 on eth0"}
+	if !reflect.DeepEqual(notes, wantNotes) {
+		t.Fatalf("notes = %v, want %v", notes, wantNotes)
+	}
+}
+
+func TestNetlinkExecutorValidatesARP(t *testing.T) {
+	provider := &sysctlProvider{mockNetlinkProvider: &mockNetlinkProvider{}, values: map[string]string{}}
+	four, one := 4, 1
+	tests := []struct {
+		cfg  Configuration
+		want string
+	}{
+		{Configuration{ARP: &ARP{Ignore: &four}}, "ignore 4 is not one of 0, 1, 2, 3, 8"},
+		{Configuration{ARP: &ARP{Announce: &four}}, "announce 4 is not one of 0, 1, 2"},
+		{Configuration{ARP: &ARP{Ignore: &one}, Sysctls: map[string]string{"net.ipv4.conf.eth0.arp_ignore": "2"}}, "also set by the arp section"},
+	}
+	for _, tt := range tests {
+		tt.cfg.Interface = "eth0"
+		err := NetlinkExecutor{Provider: provider}.Apply(tt.cfg)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("Apply(%s) error = %v, want %q", tt.cfg.ARP, err, tt.want)
+		}
+	}
+}
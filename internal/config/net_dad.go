@@ -0,0 +1,85 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// defaultDADTimeout covers the kernel defaults of one probe after a
+	// random delay of up to a second, with room for a slow link to come up.
+	defaultDADTimeout = 5 * time.Second
+	// dadPollInterval is how often address flags are checked while waiting.
+	dadPollInterval = 100 * time.Millisecond
+
+	dadFail = "fail"
+	dadWarn = "warn"
+)
+
+func validateDAD(dad *DAD) error {
+	if dad == nil {
+		return nil
+	}
+	switch dad.OnFailure {
+	case "", dadFail, dadWarn:
+		return nil
+	}
+	return fmt.Errorf("dad: on_failure must be fail or warn, got %q", dad.OnFailure)
+}
+
+// waitDAD polls the desired IPv6 addresses of link until none is tentative
+// any more or the timeout passes. Addresses that failed duplicate address
+// detection, or are still tentative at the deadline, fail the apply or are
+// reported, as dad asks. Addresses already present are checked as well, so a
+// duplicate left behind by an earlier run is not missed.
+func (n NetlinkExecutor) waitDAD(link netlink.Link, dad *DAD, desired map[string]*netlink.Addr) error {
+	if dad == nil {
+		return nil
+	}
+	name := link.Attrs().Name
+	timeout := dad.timeout()
+	var problems []string
+	for waited := time.Duration(0); ; waited += dadPollInterval {
+		addrs, err := n.Provider.AddrList(link, netlink.FAMILY_V6)
+		if err != nil {
+			return fmt.Errorf("list addresses for family %d: %w", netlink.FAMILY_V6, err)
+		}
+		problems = problems[:0]
+		tentative := false
+		for _, addr := range addrs {
+			key := addr.IPNet.String()
+			if _, ok := desired[key]; !ok {
+				continue
+			}
+			switch {
+			case addr.Flags&unix.IFA_F_DADFAILED != 0:
+				problems = append(problems, fmt.Sprintf("address %s on %s failed duplicate address detection", key, name))
+			case addr.Flags&unix.IFA_F_TENTATIVE != 0:
+				tentative = true
+				problems = append(problems, fmt.Sprintf("address %s on %s is still tentative after %s", key, name, timeout))
+			}
+		}
+		if !tentative || waited >= timeout {
+			break
+		}
+		n.sleep(dadPollInterval)
+	}
+	if dad.onFailure() == dadWarn {
+		for _, problem := range problems {
+			n.report("%s", problem)
+		}
+		return nil
+	}
+	if len(problems) > 0 {
+		errs := make([]error, len(problems))
+		for i, problem := range problems {
+			errs[i] = errors.New(problem)
+		}
+		return errors.Join(errs...)
+	}
+	return nil
+}
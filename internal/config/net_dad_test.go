@@ -0,0 +1,84 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func v6Addr(t *testing.T, cidr string, flags int) netlink.Addr {
+	t.Helper()
+	addr, err := netlink.ParseAddr(cidr)
+	if err != nil {
+		t.Fatalf("ParseAddr(%q) error = %v", cidr, err)
+	}
+	addr.Flags = flags
+	return *addr
+}
+
+func TestNetlinkExecutorWaitsForDAD(t *testing.T) {
+	provider := &mockNetlinkProvider{link: &fakeLink{netlink.LinkAttrs{Name: "eth0"}}, lists: map[int][]netlink.Addr{
+		netlink.FAMILY_V6: {v6Addr(t, "2001:db8::10/64", unix.IFA_F_TENTATIVE)},
+	}}
+	polls := 0
+	exec := NetlinkExecutor{Provider: provider, Sleep: func(time.Duration) {
+		polls++
+		provider.lists[netlink.FAMILY_V6][0].Flags = 0
+	}}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "2001:db8::10/64"}}, DAD: &DAD{}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if polls != 1 {
+		t.Fatalf("expected one poll while the address was tentative, got %d", polls)
+	}
+}
+
+func TestNetlinkExecutorFailsOnDADFailure(t *testing.T) {
+	provider := &mockNetlinkProvider{link: &fakeLink{netlink.LinkAttrs{Name: "eth0"}}, lists: map[int][]netlink.Addr{
+		netlink.FAMILY_V6: {v6Addr(t, "2001:db8::10/64", unix.IFA_F_TENTATIVE|unix.IFA_F_DADFAILED)},
+	}}
+	exec := NetlinkExecutor{Provider: provider, Sleep: func(time.Duration) { t.Fatal("a failed address is final and needs no waiting") }}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "2001:db8::10/64"}}, DAD: &DAD{}}
+	err := exec.Apply(cfg)
+	if err == nil || !strings.Contains(err.Error(), "2001:db8::10/64 on eth0 failed duplicate address detection") {
+		t.Fatalf("expected a DAD failure, got %v", err)
+	}
+}
+
+func TestNetlinkExecutorWarnsOnTentativeTimeout(t *testing.T) {
+	provider := &mockNetlinkProvider{link: &fakeLink{netlink.LinkAttrs{Name: "eth0"}}, lists: map[int][]netlink.Addr{
+		netlink.FAMILY_V6: {v6Addr(t, "2001:db8::10/64", unix.IFA_F_TENTATIVE)},
+	}}
+	var notes noteCollector
+	polls := 0
+	exec := NetlinkExecutor{Provider: provider, Reporter: &notes, Sleep: func(time.Duration) { polls++ }}
+	dad := &DAD{Timeout: Duration(time.Second), OnFailure: dadWarn}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "2001:db8::10/64"}}, DAD: dad}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := int(time.Second / dadPollInterval); polls != want {
+		t.Fatalf("expected %d polls before giving up, got %d", want, polls)
+	}
+	if len(notes) != 1 || !strings.Contains(notes[0], "still tentative after 1s") {
+		t.Fatalf("unexpected notes: %v", notes)
+	}
+}
+
+func TestNetlinkExecutorRejectsUnknownDADPolicy(t *testing.T) {
+	exec := NetlinkExecutor{Provider: &mockNetlinkProvider{}}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "2001:db8::10/64"}}, DAD: &DAD{OnFailure: "ignore"}}
+	if err := exec.Apply(cfg); err == nil {
+		t.Fatal("expected error for an unknown on_failure value")
+	}
+}
+
+type noteCollector []string
+
+func (c *noteCollector) Report(note string) {
+	*c = append(*c, note)
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/user/goeth/internal/dhcp4"
+)
+
+// maxLeaseLifetime caps the lifetimes of a leased address below
+// lifetimeForever, so that even an infinite lease is recognized as leased.
+const maxLeaseLifetime = lifetimeForever - 1
+
+// DHCP4Provider is implemented by providers that can obtain a DHCPv4 lease
+// for a link. It is optional; configurations with dhcp4 require it.
+// AcquireLease assigns the leased address, with the lease time as its valid
+// lifetime and the renewal time as its preferred lifetime, and with route
+// replaces the default route through the router of the lease. The lease is
+// empty when the provider only records the request.
+type DHCP4Provider interface {
+	AcquireLease(link netlink.Link, requested net.IP, route bool) (dhcp4.Lease, error)
+}
+
+// validateDHCP4 checks that a lease can be obtained for cfg.
+func (n NetlinkExecutor) validateDHCP4(cfg Configuration) error {
+	if !cfg.DHCP4 {
+		return nil
+	}
+	if _, ok := n.Provider.(DHCP4Provider); !ok {
+		return errors.New("netlink provider cannot run a DHCPv4 client")
+	}
+	if cfg.State == stateDown {
+		return fmt.Errorf("dhcp4 for %s: the link must not be set down", cfg.Interface)
+	}
+	if cfg.IPv6Only() {
+		return fmt.Errorf("profile %s does not allow dhcp4", cfg.Profile)
+	}
+	return nil
+}
+
+// reconcileDHCP4 obtains a lease unless the interface has a leased address
+// that is not yet due for renewal. Leased addresses are those of IPv4 with a
+// finite lifetime that are not declared; the one found is asked for again.
+func (n NetlinkExecutor) reconcileDHCP4(cfg Configuration, link netlink.Link, p plan, current map[string]*netlink.Addr) error {
+	if !cfg.DHCP4 {
+		return nil
+	}
+	if !willBeUp(cfg, link) {
+		return fmt.Errorf("dhcp4 on %s: the link is down; set state to up", cfg.Interface)
+	}
+	var requested net.IP
+	for _, key := range sortedKeys(current) {
+		addr := current[key]
+		if _, declared := p.desired[key]; declared || !leased(addr) {
+			continue
+		}
+		if addr.PreferedLft > 0 {
+			return nil
+		}
+		requested = addr.IP
+	}
+	if _, err := n.Provider.(DHCP4Provider).AcquireLease(link, requested, !declaresDefaultRoute(p.routes)); err != nil {
+		return fmt.Errorf("dhcp4 on %s: %w", cfg.Interface, err)
+	}
+	return nil
+}
+
+// willBeUp reports whether link is up once the state of cfg is applied.
+func willBeUp(cfg Configuration, link netlink.Link) bool {
+	return cfg.State == stateUp || link.Attrs().Flags&net.FlagUp != 0
+}
+
+// leased reports whether addr looks like an address leased by DHCPv4.
+func leased(addr *netlink.Addr) bool {
+	return addrFamily(addr) == netlink.FAMILY_V4 && addr.ValidLft > 0 && addr.ValidLft != lifetimeForever
+}
+
+func declaresDefaultRoute(routes map[string]*netlink.Route) bool {
+	for _, route := range routes {
+		if ones, _ := route.Dst.Mask.Size(); route.Family == netlink.FAMILY_V4 && ones == 0 && mainTable(route.Table) {
+			return true
+		}
+	}
+	return false
+}
+
+// leaseAddr is the address assigning lease.
+func leaseAddr(lease dhcp4.Lease) *netlink.Addr {
+	seconds := func(d time.Duration) int {
+		return int(min(int64(d/time.Second), maxLeaseLifetime))
+	}
+	address := lease.Address
+	return &netlink.Addr{IPNet: &address, ValidLft: seconds(lease.Duration), PreferedLft: seconds(lease.Renewal)}
+}
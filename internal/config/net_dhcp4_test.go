@@ -0,0 +1,102 @@
+package config
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/user/goeth/internal/dhcp4"
+)
+
+// dhcp4Provider records lease requests as "requested route".
+type dhcp4Provider struct {
+	*mockNetlinkProvider
+	requests []string
+}
+
+func (d *dhcp4Provider) AcquireLease(link netlink.Link, requested net.IP, route bool) (dhcp4.Lease, error) {
+	d.requests = append(d.requests, requested.String()+" "+onOff(route))
+	return dhcp4.Lease{}, nil
+}
+
+func leasedAddr(t *testing.T, cidr string, valid, preferred int) netlink.Addr {
+	addr := v4Addr(t, cidr)
+	addr.ValidLft, addr.PreferedLft = valid, preferred
+	return addr
+}
+
+func TestNetlinkExecutorRenewsLease(t *testing.T) {
+	provider := &dhcp4Provider{mockNetlinkProvider: &mockNetlinkProvider{lists: map[int][]netlink.Addr{
+		netlink.FAMILY_V4: {v4Addr(t, "192.0.2.10/24"), leasedAddr(t, "192.0.2.50/24", 1800, 0)},
+	}}}
+	cfg := Configuration{Interface: "eth0", State: stateUp, DHCP4: true, Addresses: []Address{{CIDR: "192.0.2.20/24"}}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"192.0.2.50 on"}; !reflect.DeepEqual(provider.requests, want) {
+		t.Fatalf("lease requests = %v, want %v", provider.requests, want)
+	}
+	if want := []string{"192.0.2.10/24"}; !reflect.DeepEqual(provider.removed, want) {
+		t.Fatalf("removed %v, want only the undeclared static address", provider.removed)
+	}
+}
+
+func TestNetlinkExecutorKeepsLeaseUntilRenewal(t *testing.T) {
+	provider := &dhcp4Provider{mockNetlinkProvider: &mockNetlinkProvider{lists: map[int][]netlink.Addr{
+		netlink.FAMILY_V4: {leasedAddr(t, "192.0.2.50/24", 3000, 1200)},
+	}}}
+	cfg := Configuration{Interface: "eth0", State: stateUp, DHCP4: true}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.requests) != 0 || len(provider.removed) != 0 {
+		t.Fatalf("requests %v, removed %v; want the lease left alone", provider.requests, provider.removed)
+	}
+}
+
+func TestNetlinkExecutorLeavesDeclaredDefaultRoute(t *testing.T) {
+	provider := &dhcp4Provider{mockNetlinkProvider: &mockNetlinkProvider{}}
+	cfg := Configuration{Interface: "eth0", State: stateUp, DHCP4: true, Routes: []Route{{Destination: "default", Gateway: "192.0.2.1"}}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"<nil> off"}; !reflect.DeepEqual(provider.requests, want) {
+		t.Fatalf("lease requests = %v, want %v", provider.requests, want)
+	}
+}
+
+func TestNetlinkExecutorValidatesDHCP4(t *testing.T) {
+	provider := &dhcp4Provider{mockNetlinkProvider: &mockNetlinkProvider{}}
+	tests := []struct {
+		cfg      Configuration
+		provider NetlinkProvider
+		want     string
+	}{
+		{Configuration{DHCP4: true}, provider.mockNetlinkProvider, "cannot run a DHCPv4 client"},
+		{Configuration{DHCP4: true, State: stateDown}, provider, "must not be set down"},
+		{Configuration{DHCP4: true, Profile: ProfileIPv6Only}, provider, "does not allow dhcp4"},
+	}
+	for _, tt := range tests {
+		tt.cfg.Interface = "eth0"
+		err := NetlinkExecutor{Provider: tt.provider}.Apply(tt.cfg)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("Apply(%+v) error = %v, want %q", tt.cfg, err, tt.want)
+		}
+	}
+}
+
+func TestLeaseAddrLifetimes(t *testing.T) {
+	lease := dhcp4.Lease{
+		Address:  net.IPNet{IP: net.ParseIP("192.0.2.50").To4(), Mask: net.CIDRMask(24, 32)},
+		Duration: 1 << 33 * time.Second,
+		Renewal:  time.Hour,
+	}
+	addr := leaseAddr(lease)
+	if addr.ValidLft != maxLeaseLifetime || addr.PreferedLft != 3600 || !leased(addr) {
+		t.Fatalf("lifetimes %d/%d, want an infinite lease capped below forever", addr.ValidLft, addr.PreferedLft)
+	}
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/user/goeth/internal/dhcp6"
+)
+
+const (
+	// defaultAcquireTimeout leaves room for a router advertisement, which
+	// routers send every few minutes unless solicited, or for DHCPv6
+	// retransmissions while the link-local address is tentative.
+	defaultAcquireTimeout = 10 * time.Second
+	// acquirePollInterval is how often addresses are checked while waiting.
+	acquirePollInterval = 100 * time.Millisecond
+)
+
+// DHCP6Provider is implemented by providers that can obtain a DHCPv6 lease
+// for a link. It is optional; configurations with dhcp6 require it.
+// AcquireLease6 assigns the leased address as a /128 with the server's
+// valid lifetime and, as its preferred lifetime, the renewal time (T1) when
+// that is shorter. The lease is empty when the provider only records the
+// request.
+type DHCP6Provider interface {
+	AcquireLease6(link netlink.Link, requested net.IP) (dhcp6.Lease, error)
+}
+
+// AddressWaiter is implemented by providers that can wait until a link has
+// a usable global IPv6 address from router advertisements or DHCPv6. It is
+// optional; configurations with slaac or dhcp6 require it.
+type AddressWaiter interface {
+	WaitGlobalIPv6(link netlink.Link, timeout time.Duration) (*netlink.Addr, error)
+}
+
+func (c Configuration) acquireTimeout() time.Duration {
+	if c.AcquireTimeout == 0 {
+		return defaultAcquireTimeout
+	}
+	return time.Duration(c.AcquireTimeout)
+}
+
+// validateIPv6Acquisition checks that the slaac and dhcp6 modes can be used.
+func (n NetlinkExecutor) validateIPv6Acquisition(cfg Configuration) error {
+	if !cfg.SLAAC && !cfg.DHCP6 {
+		return nil
+	}
+	if cfg.DHCP6 {
+		if _, ok := n.Provider.(DHCP6Provider); !ok {
+			return errors.New("netlink provider cannot run a DHCPv6 client")
+		}
+	}
+	if _, ok := n.Provider.(AddressWaiter); !ok {
+		return errors.New("netlink provider cannot wait for IPv6 addresses")
+	}
+	if cfg.State == stateDown {
+		return fmt.Errorf("%s for %s: the link must not be set down", cfg.ipv6Modes(), cfg.Interface)
+	}
+	return nil
+}
+
+// ipv6Modes names the ways cfg obtains IPv6 addresses, for messages.
+func (c Configuration) ipv6Modes() string {
+	switch {
+	case c.SLAAC && c.DHCP6:
+		return "slaac and dhcp6"
+	case c.SLAAC:
+		return "slaac"
+	}
+	return "dhcp6"
+}
+
+// reconcileIPv6Acquisition runs dhcp6 and then waits for a global IPv6
+// address.
+func (n NetlinkExecutor) reconcileIPv6Acquisition(cfg Configuration, link netlink.Link, p plan, current map[string]*netlink.Addr) error {
+	if !cfg.SLAAC && !cfg.DHCP6 {
+		return nil
+	}
+	if !willBeUp(cfg, link) {
+		return fmt.Errorf("%s on %s: the link is down; set state to up", cfg.ipv6Modes(), cfg.Interface)
+	}
+	if cfg.DHCP6 {
+		if err := n.reconcileDHCP6(cfg, link, p, current); err != nil {
+			return err
+		}
+	}
+	if _, err := n.Provider.(AddressWaiter).WaitGlobalIPv6(link, cfg.acquireTimeout()); err != nil {
+		return fmt.Errorf("%s on %s: %w", cfg.ipv6Modes(), cfg.Interface, err)
+	}
+	return nil
+}
+
+// reconcileDHCP6 obtains a lease unless the interface has a leased address
+// that is not yet due for renewal.
+func (n NetlinkExecutor) reconcileDHCP6(cfg Configuration, link netlink.Link, p plan, current map[string]*netlink.Addr) error {
+	var requested net.IP
+	for _, key := range sortedKeys(current) {
+		addr := current[key]
+		if _, declared := p.desired[key]; declared || !leased6(addr) {
+			continue
+		}
+		if addr.PreferedLft > 0 {
+			return nil
+		}
+		requested = addr.IP
+	}
+	if _, err := n.Provider.(DHCP6Provider).AcquireLease6(link, requested); err != nil {
+		return fmt.Errorf("dhcp6 on %s: %w", cfg.Interface, err)
+	}
+	return nil
+}
+
+// leased6 reports whether addr looks like an address leased by DHCPv6: a
+// global /128 that expires.
+func leased6(addr *netlink.Addr) bool {
+	ones, bits := addr.Mask.Size()
+	return bits == 8*net.IPv6len && ones == bits && !addr.IP.IsLinkLocalUnicast() &&
+		addr.ValidLft > 0 && addr.ValidLft != lifetimeForever
+}
+
+// lease6Addr is the address assigning lease.
+func lease6Addr(lease dhcp6.Lease) *netlink.Addr {
+	seconds := func(d time.Duration) int {
+		return int(min(int64(d/time.Second), maxLeaseLifetime))
+	}
+	preferred := lease.Preferred
+	if lease.Renewal > 0 && lease.Renewal < preferred {
+		preferred = lease.Renewal
+	}
+	ipnet := &net.IPNet{IP: lease.Address, Mask: net.CIDRMask(8*net.IPv6len, 8*net.IPv6len)}
+	return &netlink.Addr{IPNet: ipnet, ValidLft: seconds(lease.Valid), PreferedLft: seconds(preferred)}
+}
+
+// acquiredIPv6 returns the first usable global IPv6 address that expires,
+// as those from router advertisements and DHCPv6 do, or nil.
+func acquiredIPv6(addrs []netlink.Addr) *netlink.Addr {
+	for i := range addrs {
+		addr := &addrs[i]
+		if addr.IP.To4() != nil || !addr.IP.IsGlobalUnicast() || addr.Flags&(unix.IFA_F_TENTATIVE|unix.IFA_F_DADFAILED) != 0 {
+			continue
+		}
+		if addr.ValidLft > 0 && addr.ValidLft != lifetimeForever && addr.PreferedLft > 0 {
+			return addr
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/user/goeth/internal/dhcp6"
+)
+
+// acquireProvider records DHCPv6 lease requests by the address asked for
+// and the waits for a global IPv6 address.
+type acquireProvider struct {
+	*sysctlProvider
+	requests []string
+	waits    []time.Duration
+	waitErr  error
+}
+
+func (a *acquireProvider) AcquireLease6(link netlink.Link, requested net.IP) (dhcp6.Lease, error) {
+	a.requests = append(a.requests, requested.String())
+	return dhcp6.Lease{}, nil
+}
+
+func (a *acquireProvider) WaitGlobalIPv6(link netlink.Link, timeout time.Duration) (*netlink.Addr, error) {
+	a.waits = append(a.waits, timeout)
+	return nil, a.waitErr
+}
+
+func newAcquireProvider(lists map[int][]netlink.Addr, values map[string]string) *acquireProvider {
+	return &acquireProvider{sysctlProvider: &sysctlProvider{
+		mockNetlinkProvider: &mockNetlinkProvider{link: &fakeLink{netlink.LinkAttrs{Name: "eth0", Index: 2}}, lists: lists},
+		values:              values,
+	}}
+}
+
+func expiring(addr netlink.Addr, valid, preferred int) netlink.Addr {
+	addr.ValidLft, addr.PreferedLft = valid, preferred
+	return addr
+}
+
+func TestNetlinkExecutorSetsUpSLAAC(t *testing.T) {
+	provider := newAcquireProvider(map[int][]netlink.Addr{netlink.FAMILY_V6: {
+		v6Addr(t, "2001:db8::10/64", 0),
+		expiring(v6Addr(t, "2001:db8::5054:ff:fe12:3456/64", 0), 2592000, 604800),
+	}}, map[string]string{
+		"net/ipv6/conf/eth0/accept_ra": "0",
+		"net/ipv6/conf/eth0/autoconf":  "1",
+	})
+	cfg := Configuration{Interface: "eth0", State: stateUp, SLAAC: true, Addresses: []Address{{CIDR: "2001:db8::10/64"}}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"net/ipv6/conf/eth0/accept_ra=1"}; !reflect.DeepEqual(provider.set, want) {
+		t.Fatalf("set %v, want %v", provider.set, want)
+	}
+	if want := []time.Duration{defaultAcquireTimeout}; !reflect.DeepEqual(provider.waits, want) {
+		t.Fatalf("waits = %v, want %v", provider.waits, want)
+	}
+	if len(provider.removed) != 0 || len(provider.requests) != 0 {
+		t.Fatalf("removed %v, requested %v; want the autoconfigured address kept", provider.removed, provider.requests)
+	}
+}
+
+func TestNetlinkExecutorRenewsLease6(t *testing.T) {
+	provider := newAcquireProvider(map[int][]netlink.Addr{netlink.FAMILY_V6: {
+		expiring(v6Addr(t, "2001:db8::50/128", 0), 1800, 0),
+	}}, map[string]string{})
+	off := false
+	cfg := Configuration{Interface: "eth0", State: stateUp, DHCP6: true, IPv6: &IPv6{Autoconf: &off}, AcquireTimeout: Duration(time.Second)}
+	provider.values["net/ipv6/conf/eth0/autoconf"] = "0"
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"2001:db8::50"}; !reflect.DeepEqual(provider.requests, want) {
+		t.Fatalf("requests = %v, want %v", provider.requests, want)
+	}
+	if len(provider.removed) != 0 {
+		t.Fatalf("removed %v, want the leased address kept with autoconf off", provider.removed)
+	}
+	if want := []time.Duration{time.Second}; !reflect.DeepEqual(provider.waits, want) {
+		t.Fatalf("waits = %v, want %v", provider.waits, want)
+	}
+}
+
+func TestNetlinkExecutorKeepsLease6UntilRenewal(t *testing.T) {
+	provider := newAcquireProvider(map[int][]netlink.Addr{netlink.FAMILY_V6: {
+		expiring(v6Addr(t, "2001:db8::50/128", 0), 3000, 1200),
+	}}, map[string]string{})
+	if err := (NetlinkExecutor{Provider: provider}).Apply(Configuration{Interface: "eth0", State: stateUp, DHCP6: true}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.requests) != 0 {
+		t.Fatalf("requests = %v, want the lease left alone", provider.requests)
+	}
+}
+
+func TestNetlinkExecutorFailsWithoutGlobalIPv6(t *testing.T) {
+	provider := newAcquireProvider(nil, map[string]string{
+		"net/ipv6/conf/eth0/accept_ra": "1",
+		"net/ipv6/conf/eth0/autoconf":  "1",
+	})
+	provider.waitErr = errors.New("no global IPv6 address after 10s")
+	err := NetlinkExecutor{Provider: provider}.Apply(Configuration{Interface: "eth0", State: stateUp, SLAAC: true})
+	if err == nil || err.Error() != "slaac on eth0: no global IPv6 address after 10s" {
+		t.Fatalf("Apply() error = %v", err)
+	}
+}
+
+func TestNetlinkExecutorValidatesIPv6Acquisition(t *testing.T) {
+	provider := newAcquireProvider(nil, map[string]string{})
+	off := false
+	tests := []struct {
+		cfg      Configuration
+		provider NetlinkProvider
+		want     string
+	}{
+		{Configuration{SLAAC: true}, provider.sysctlProvider, "cannot wait for IPv6 addresses"},
+		{Configuration{DHCP6: true}, provider.mockNetlinkProvider, "cannot run a DHCPv6 client"},
+		{Configuration{SLAAC: true, DHCP6: true, State: stateDown}, provider, "slaac and dhcp6 for eth0: the link must not be set down"},
+		{Configuration{SLAAC: true, IPv6: &IPv6{Autoconf: &off}}, provider, "the ipv6 section turns autoconfiguration off"},
+		{Configuration{SLAAC: true, Sysctls: map[string]string{"net.ipv6.conf.eth0.accept_ra": "0"}}, provider, "sysctl net.ipv6.conf.eth0.accept_ra turns autoconfiguration off"},
+	}
+	for _, tt := range tests {
+		tt.cfg.Interface = "eth0"
+		err := NetlinkExecutor{Provider: tt.provider}.Apply(tt.cfg)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("Apply(%+v) error = %v, want %q", tt.cfg, err, tt.want)
+		}
+	}
+}
+
+func TestAcquiredIPv6(t *testing.T) {
+	addrs := []netlink.Addr{
+		expiring(v6Addr(t, "fe80::1/64", 0), 3600, 3600),
+		v6Addr(t, "2001:db8::10/64", 0),
+		expiring(v6Addr(t, "2001:db8::20/64", unix.IFA_F_TENTATIVE), 3600, 3600),
+		expiring(v6Addr(t, "2001:db8::30/64", 0), 3600, 0),
+		expiring(v6Addr(t, "2001:db8::40/64", 0), 3600, 1800),
+	}
+	if got := acquiredIPv6(addrs); got == nil || got.IPNet.String() != "2001:db8::40/64" {
+		t.Fatalf("acquiredIPv6() = %v, want 2001:db8::40/64", got)
+	}
+	if got := acquiredIPv6(addrs[:4]); got != nil {
+		t.Fatalf("acquiredIPv6() = %v, want none", got)
+	}
+}
+
+func TestLease6AddrLifetimes(t *testing.T) {
+	lease := dhcp6.Lease{Address: net.ParseIP("2001:db8::50"), Preferred: time.Hour, Valid: 2 * time.Hour, Renewal: 30 * time.Minute}
+	addr := lease6Addr(lease)
+	if addr.IPNet.String() != "2001:db8::50/128" || addr.ValidLft != 7200 || addr.PreferedLft != 1800 || !leased6(addr) {
+		t.Fatalf("address %s with lifetimes %d/%d, want 2001:db8::50/128 for 7200/1800", addr.IPNet, addr.ValidLft, addr.PreferedLft)
+	}
+}
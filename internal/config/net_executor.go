@@ -1,10 +1,31 @@
 package config
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+
+	"github.com/user/goeth/internal/announce"
+	"github.com/user/goeth/internal/bpf"
+	"github.com/user/goeth/internal/dhcp4"
+	"github.com/user/goeth/internal/dhcp6"
+	"github.com/user/goeth/internal/ethtool"
+	"github.com/user/goeth/internal/namespace"
+	"github.com/user/goeth/internal/netlinkproc"
+	"github.com/user/goeth/internal/netmanager"
+	"github.com/user/goeth/internal/pmtu"
+	"github.com/user/goeth/internal/wireguard"
 )
 
 // NetlinkProvider exposes the subset of netlink APIs needed by the executor.
@@ -13,11 +34,50 @@ type NetlinkProvider interface {
 	AddrList(link netlink.Link, family int) ([]netlink.Addr, error)
 	AddrAdd(link netlink.Link, addr *netlink.Addr) error
 	AddrDel(link netlink.Link, addr *netlink.Addr) error
+	AddrReplace(link netlink.Link, addr *netlink.Addr) error
+	NeighList(linkIndex, family int) ([]netlink.Neigh, error)
+	NeighSet(neigh *netlink.Neigh) error
+	NeighDel(neigh *netlink.Neigh) error
+	RouteList(link netlink.Link, family int) ([]netlink.Route, error)
+	RouteAdd(route *netlink.Route) error
+	RouteReplace(route *netlink.Route) error
+	RouteDel(route *netlink.Route) error
+	LinkList() ([]netlink.Link, error)
+	LinkAdd(link netlink.Link) error
+	LinkDel(link netlink.Link) error
+	LinkModify(link netlink.Link) error
+	LinkSetMaster(link, master netlink.Link) error
+	LinkSetNoMaster(link netlink.Link) error
+	LinkSetUp(link netlink.Link) error
+	LinkSetDown(link netlink.Link) error
+	LinkSetName(link netlink.Link, name string) error
+	LinkSetMTU(link netlink.Link, mtu int) error
+	LinkSetHardwareAddr(link netlink.Link, hw net.HardwareAddr) error
+}
+
+// ErrLinkNotFound is returned by NetlinkProvider.LinkByName when no link has the requested name.
+var ErrLinkNotFound = errors.New("link not found")
+
+// Reporter is implemented by providers that collect notes about state the
+// executor deliberately leaves unchanged, such as routes owned by another
+// protocol.
+type Reporter interface {
+	Report(note string)
 }
 
 // NetlinkExecutor applies configurations using a NetlinkProvider.
 type NetlinkExecutor struct {
 	Provider NetlinkProvider
+	// Reporter receives notes about the apply; when nil they go to Provider
+	// if it implements Reporter.
+	Reporter Reporter
+	// Sleep overrides waiting between polls (used in tests).
+	Sleep func(time.Duration)
+	// Retry sets how transient netlink errors are retried; nil uses
+	// DefaultRetry.
+	Retry *Retry
+	// Context cancels waiting to retry; nil is never cancelled.
+	Context context.Context
 }
 
 // NewNetlinkExecutor creates an executor backed by provider.
@@ -26,37 +86,237 @@ func NewNetlinkExecutor(provider NetlinkProvider) NetlinkExecutor {
 }
 
 // Apply ensures the provided configuration is reflected on the interface.
+// Addresses that are already present keep their remaining lifetime. When the
+// provider implements ChangeWatcher, changes other processes make to the
+// interface while it is applied are detected and reconciled again. Transient
+// netlink errors are retried as Retry says.
 func (n NetlinkExecutor) Apply(cfg Configuration) error {
 	if n.Provider == nil {
 		return errors.New("netlink provider is not configured")
 	}
-	link, err := n.Provider.LinkByName(cfg.Interface)
+	p, err := n.prepare(cfg)
 	if err != nil {
-		return fmt.Errorf("lookup interface %q: %w", cfg.Interface, err)
+		return err
+	}
+	return n.retry(cfg, func() error {
+		watcher, ok := n.Provider.(ChangeWatcher)
+		if !ok {
+			return n.reconcile(cfg, p)
+		}
+		return n.guardedReconcile(cfg, p, watcher)
+	})
+}
+
+// plan holds a configuration that passed validation, parsed into the values
+// the reconcile steps work with.
+type plan struct {
+	desired     map[string]*netlink.Addr
+	families    []int
+	slaacOff    bool
+	dhcp4       bool
+	dhcp6       bool
+	scoped      map[string]bool
+	neighbors   map[string]*netlink.Neigh
+	routes      map[string]*netlink.Route
+	mainRoutes  bool
+	hopDevices  map[string][]string
+	policyRules map[string]*netlink.Rule
+	tables      map[int]bool
+	vlans       map[string]netlink.Link
+	macvlans    map[string]netlink.Link
+	ipvlans     map[string]netlink.Link
+	rules       []linkRule
+	tunnel      *wireguard.Device
+	probeTarget net.IP
+	mac         net.HardwareAddr
+	sysctls     []sysctl
+	qdisc       *qdiscSpec
+	vfs         []vfSpec
+}
+
+// prepare validates cfg before anything is changed.
+func (n NetlinkExecutor) prepare(cfg Configuration) (plan, error) {
+	var p plan
+	var err error
+	if p.desired, p.families, err = parseDesiredAddresses(cfg.Addresses, cfg.Interface); err != nil {
+		return p, err
+	}
+	p.scoped = scopedAddresses(cfg.Addresses)
+	p.slaacOff = cfg.IPv6.slaacOff()
+	p.dhcp4 = cfg.DHCP4
+	p.dhcp6 = cfg.DHCP6
+	if p.neighbors, err = parseDesiredNeighbors(cfg.Neighbors); err != nil {
+		return p, err
 	}
-	desired, families, err := parseDesiredAddresses(cfg.Addresses)
+	routes, rules := cfg.Routes, cfg.Rules
+	sourceRoutes, sourceRules, err := sourceRouting(cfg.SourceRouting, p.desired)
 	if err != nil {
+		return p, err
+	}
+	if cfg.SourceRouting != nil {
+		// An empty list still removes undeclared routes, so only merge
+		// when there is something to merge.
+		routes, rules = slices.Concat(routes, sourceRoutes), slices.Concat(rules, sourceRules)
+	}
+	if p.routes, p.hopDevices, err = parseDesiredRoutes(routes, cfg.defaultFamily(), cfg.Interface); err != nil {
+		return p, err
+	}
+	p.mainRoutes = cfg.Routes != nil
+	if p.policyRules, err = parseDesiredRules(rules, cfg.defaultFamily()); err != nil {
+		return p, err
+	}
+	p.tables = routeTables(p.routes, p.policyRules)
+	if p.vlans, err = parseDesiredVLANs(cfg.VLANs); err != nil {
+		return p, err
+	}
+	if p.macvlans, err = parseDesiredMACVLANs(cfg.MACVLANs); err != nil {
+		return p, err
+	}
+	if p.ipvlans, err = parseDesiredIPVLANs(cfg.IPVLANs); err != nil {
+		return p, err
+	}
+	if err := validateLink(cfg); err != nil {
+		return p, err
+	}
+	if err := validateDAD(cfg.DAD); err != nil {
+		return p, err
+	}
+	if err := n.validateAnnounce(cfg); err != nil {
+		return p, err
+	}
+	if err := validateState(cfg); err != nil {
+		return p, err
+	}
+	if err := validateProfile(cfg, p); err != nil {
+		return p, err
+	}
+	if p.rules, err = parseLinkRules(cfg.Links); err != nil {
+		return p, err
+	}
+	if p.tunnel, err = n.parseWireGuard(cfg); err != nil {
+		return p, err
+	}
+	if p.probeTarget, err = n.validateMTU(cfg); err != nil {
+		return p, err
+	}
+	if p.mac, err = parseMAC(cfg); err != nil {
+		return p, err
+	}
+	if p.sysctls, err = n.parseSysctls(cfg); err != nil {
+		return p, err
+	}
+	if p.qdisc, err = n.parseQdisc(cfg); err != nil {
+		return p, err
+	}
+	if err := n.validateOffloads(cfg); err != nil {
+		return p, err
+	}
+	if err := n.validateRings(cfg); err != nil {
+		return p, err
+	}
+	if p.vfs, err = n.parseVFs(cfg); err != nil {
+		return p, err
+	}
+	if err := n.validateXDP(cfg); err != nil {
+		return p, err
+	}
+	if err := n.validateDHCP4(cfg); err != nil {
+		return p, err
+	}
+	if err := n.validateIPv6Acquisition(cfg); err != nil {
+		return p, err
+	}
+	if err := n.validatePolicy(p); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// reconcile makes the changes needed to bring the interface in line with p.
+func (n NetlinkExecutor) reconcile(cfg Configuration, p plan) error {
+	if err := n.applyLinkRules(p.rules); err != nil {
 		return err
 	}
-	current, err := n.collectCurrent(link, families)
+	link, err := n.ensureLink(cfg)
 	if err != nil {
 		return err
 	}
-	for key, addr := range desired {
-		if _, ok := current[key]; ok {
-			continue
-		}
-		if err := n.Provider.AddrAdd(link, addr); err != nil {
-			return fmt.Errorf("add address %s: %w", key, err)
+	if p.tunnel != nil {
+		if err := n.Provider.(WireGuardProvider).ConfigureWireGuard(cfg.Interface, *p.tunnel); err != nil {
+			return fmt.Errorf("configure wireguard %s: %w", cfg.Interface, err)
 		}
 	}
-	for key, addr := range current {
-		if _, ok := desired[key]; ok {
-			continue
-		}
-		if err := n.Provider.AddrDel(link, addr); err != nil {
-			return fmt.Errorf("remove address %s: %w", key, err)
-		}
+	if err := n.reconcileMTU(cfg, link, p.probeTarget); err != nil {
+		return err
+	}
+	if err := n.reconcileMAC(cfg, link, p.mac); err != nil {
+		return err
+	}
+	if err := n.reconcileSysctls(p.sysctls); err != nil {
+		return err
+	}
+	n.reportARPOverrides(cfg)
+	if err := n.reconcileQdisc(cfg, link, p.qdisc); err != nil {
+		return err
+	}
+	if err := n.reconcileOffloads(cfg); err != nil {
+		return err
+	}
+	if err := n.reconcileRings(cfg); err != nil {
+		return err
+	}
+	if err := n.reconcileVFs(cfg, link, p.vfs); err != nil {
+		return err
+	}
+	if err := n.reconcileXDP(cfg, link); err != nil {
+		return err
+	}
+	if err := n.reconcileState(cfg, link); err != nil {
+		return err
+	}
+	current, err := n.collectCurrent(link, p.listedFamilies())
+	if err != nil {
+		return err
+	}
+	steps := n.planAddresses(p, current, cfg.UpdateFlags)
+	if err := n.applyAddresses(link, steps); err != nil {
+		return err
+	}
+	if err := n.waitDAD(link, cfg.DAD, p.desired); err != nil {
+		return err
+	}
+	if err := n.announceAddresses(cfg, link, steps); err != nil {
+		return err
+	}
+	if err := n.reconcileDHCP4(cfg, link, p, current); err != nil {
+		return err
+	}
+	if err := n.reconcileIPv6Acquisition(cfg, link, p, current); err != nil {
+		return err
+	}
+	if err := n.reconcileNeighbors(link, p.neighbors); err != nil {
+		return err
+	}
+	if err := n.reconcileRoutes(link, p); err != nil {
+		return err
+	}
+	if err := n.reconcileRules(p.policyRules, p.tables); err != nil {
+		return err
+	}
+	if err := n.reconcileVLANs(link, p.vlans); err != nil {
+		return err
+	}
+	if err := n.reconcileMACVLANs(link, p.macvlans); err != nil {
+		return err
+	}
+	if err := n.reconcileIPVLANs(link, p.ipvlans); err != nil {
+		return err
+	}
+	switch {
+	case cfg.Bridge != nil:
+		return n.reconcilePorts(link, cfg.Bridge.Ports)
+	case cfg.Bond != nil:
+		return n.reconcilePorts(link, cfg.Bond.Slaves)
 	}
 	return nil
 }
@@ -76,16 +336,33 @@ func (n NetlinkExecutor) collectCurrent(link netlink.Link, families []int) (map[
 	return current, nil
 }
 
-func parseDesiredAddresses(raw []string) (map[string]*netlink.Addr, []int, error) {
+func parseDesiredAddresses(raw []Address, iface string) (map[string]*netlink.Addr, []int, error) {
 	desired := make(map[string]*netlink.Addr, len(raw))
 	familySet := make(map[int]struct{})
 	var families []int
-	for _, addrStr := range raw {
-		addr, err := netlink.ParseAddr(addrStr)
+	for _, entry := range raw {
+		addr, err := netlink.ParseAddr(entry.CIDR)
 		if err != nil {
-			return nil, nil, fmt.Errorf("parse address %q: %w", addrStr, err)
+			return nil, nil, fmt.Errorf("parse address %q: %w", entry.CIDR, err)
+		}
+		flags, err := parseAddressFlags(entry, addr)
+		if err != nil {
+			return nil, nil, err
+		}
+		addr.Flags = flags
+		if addr.Scope, err = parseAddressScope(entry, addr); err != nil {
+			return nil, nil, err
+		}
+		if addr.Peer, err = parseAddressPeer(entry, addr); err != nil {
+			return nil, nil, err
+		}
+		if addr.Label, err = parseAddressLabel(entry, addr, iface); err != nil {
+			return nil, nil, err
 		}
-		desired[addr.String()] = addr
+		if addr.ValidLft, addr.PreferedLft, err = parseLifetimes(entry); err != nil {
+			return nil, nil, err
+		}
+		desired[addr.IPNet.String()] = addr
 		fam := addrFamily(addr)
 		if _, ok := familySet[fam]; !ok {
 			familySet[fam] = struct{}{}
@@ -95,6 +372,46 @@ func parseDesiredAddresses(raw []string) (map[string]*netlink.Addr, []int, error
 	return desired, families, nil
 }
 
+// sortedKeys returns the keys of m in a stable order so that operations are
+// issued (and reported) deterministically.
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (n NetlinkExecutor) sleep(d time.Duration) {
+	if n.Sleep != nil {
+		n.Sleep(d)
+		return
+	}
+	time.Sleep(d)
+}
+
+// report passes a note to Reporter, or to the provider when it implements
+// Reporter.
+func (n NetlinkExecutor) report(format string, args ...interface{}) {
+	if n.Reporter != nil {
+		n.Reporter.Report(fmt.Sprintf(format, args...))
+		return
+	}
+	if reporter, ok := n.Provider.(Reporter); ok {
+		reporter.Report(fmt.Sprintf(format, args...))
+	}
+}
+
+// lifetimeSeconds converts a TTL into the whole seconds expected by netlink,
+// rounding up so that sub-second TTLs do not turn into "forever".
+func lifetimeSeconds(ttl Duration) int {
+	if ttl <= 0 {
+		return 0
+	}
+	return int((time.Duration(ttl) + time.Second - 1) / time.Second)
+}
+
 func addrFamily(addr *netlink.Addr) int {
 	if addr.IP.To4() != nil {
 		return netlink.FAMILY_V4
@@ -102,25 +419,589 @@ func addrFamily(addr *netlink.Addr) int {
 	return netlink.FAMILY_V6
 }
 
-// NetlinkAPI uses github.com/vishvananda/netlink to make changes.
-type NetlinkAPI struct{}
+// NetlinkAPI uses github.com/vishvananda/netlink to make changes. The zero
+// value works in the network namespace of the calling process.
+type NetlinkAPI struct {
+	handle *netlink.Handle
+	netns  netns.NsHandle
+}
+
+// NewNetlinkAPIAt returns a NetlinkAPI that works inside ns. The namespace
+// handle must stay open for as long as the API is used.
+func NewNetlinkAPIAt(ns netns.NsHandle) (NetlinkAPI, error) {
+	handle, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		return NetlinkAPI{}, fmt.Errorf("open netlink handle: %w", err)
+	}
+	return NetlinkAPI{handle: handle, netns: ns}, nil
+}
+
+func (n NetlinkAPI) nl() *netlink.Handle {
+	if n.handle == nil {
+		return &netlink.Handle{}
+	}
+	return n.handle
+}
 
-// LinkByName retrieves a link by name.
-func (NetlinkAPI) LinkByName(name string) (netlink.Link, error) {
-	return netlink.LinkByName(name)
+// do runs fn inside the namespace of n, for calls that open their own
+// sockets or devices rather than going through the netlink handle.
+func (n NetlinkAPI) do(fn func() error) error {
+	if n.handle == nil {
+		return fn()
+	}
+	return namespace.Do(n.netns, fn)
+}
+
+// LinkByName retrieves a link by name, reporting ErrLinkNotFound when it does not exist.
+func (n NetlinkAPI) LinkByName(name string) (netlink.Link, error) {
+	link, err := n.nl().LinkByName(name)
+	var notFound netlink.LinkNotFoundError
+	if errors.As(err, &notFound) {
+		return nil, fmt.Errorf("%w: %s", ErrLinkNotFound, name)
+	}
+	return link, err
 }
 
 // AddrList returns the addresses for the link/family.
-func (NetlinkAPI) AddrList(link netlink.Link, family int) ([]netlink.Addr, error) {
-	return netlink.AddrList(link, family)
+func (n NetlinkAPI) AddrList(link netlink.Link, family int) ([]netlink.Addr, error) {
+	return n.nl().AddrList(link, family)
 }
 
 // AddrAdd adds an address to the link.
-func (NetlinkAPI) AddrAdd(link netlink.Link, addr *netlink.Addr) error {
-	return netlink.AddrAdd(link, addr)
+func (n NetlinkAPI) AddrAdd(link netlink.Link, addr *netlink.Addr) error {
+	return n.nl().AddrAdd(link, addr)
 }
 
 // AddrDel removes an address from the link.
-func (NetlinkAPI) AddrDel(link netlink.Link, addr *netlink.Addr) error {
-	return netlink.AddrDel(link, addr)
+func (n NetlinkAPI) AddrDel(link netlink.Link, addr *netlink.Addr) error {
+	return n.nl().AddrDel(link, addr)
+}
+
+// AddrReplace updates an address of the link in place, such as its lifetimes.
+func (n NetlinkAPI) AddrReplace(link netlink.Link, addr *netlink.Addr) error {
+	return n.nl().AddrReplace(link, addr)
+}
+
+// NeighList returns the neighbor entries for the link index/family.
+func (n NetlinkAPI) NeighList(linkIndex, family int) ([]netlink.Neigh, error) {
+	return n.nl().NeighList(linkIndex, family)
+}
+
+// NeighSet adds a neighbor entry or replaces the one for its IP, including
+// one the kernel learned (NLM_F_REPLACE).
+func (n NetlinkAPI) NeighSet(neigh *netlink.Neigh) error {
+	return n.nl().NeighSet(neigh)
+}
+
+// NeighDel removes a neighbor entry.
+func (n NetlinkAPI) NeighDel(neigh *netlink.Neigh) error {
+	return n.nl().NeighDel(neigh)
+}
+
+// RouteList returns the main-table routes through the link for the family.
+func (n NetlinkAPI) RouteList(link netlink.Link, family int) ([]netlink.Route, error) {
+	return n.RouteListTable(link, family, unix.RT_TABLE_MAIN)
+}
+
+// RouteListTable returns the routes through the link in a routing table,
+// or every route in it when link is nil. A multipath route is through the
+// links of all its next hops and has its LinkIndex set to the link of the
+// first. Routes of a special type such as blackhole are through no link and
+// listed for every link.
+func (n NetlinkAPI) RouteListTable(link netlink.Link, family, table int) ([]netlink.Route, error) {
+	routes, err := n.nl().RouteListFiltered(family, &netlink.Route{Table: table}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return nil, err
+	}
+	if link == nil {
+		return routes, nil
+	}
+	return routesThrough(routes, link.Attrs().Index), nil
+}
+
+// routesThrough returns the routes through the link with index.
+func routesThrough(routes []netlink.Route, index int) []netlink.Route {
+	var through []netlink.Route
+	for _, route := range routes {
+		switch {
+		case special(route):
+			route.LinkIndex = index
+		case route.LinkIndex == 0 && len(route.MultiPath) > 0:
+			route.LinkIndex = route.MultiPath[0].LinkIndex
+		}
+		if route.LinkIndex == index || slices.ContainsFunc(route.MultiPath, func(hop *netlink.NexthopInfo) bool { return hop.LinkIndex == index }) {
+			through = append(through, route)
+		}
+	}
+	return through
+}
+
+// multipath clears the output link of a multipath route, which the kernel
+// takes from its next hops instead, and of a special route, which has none.
+func multipath(route *netlink.Route) *netlink.Route {
+	if len(route.MultiPath) == 0 && !special(*route) {
+		return route
+	}
+	copied := *route
+	copied.LinkIndex = 0
+	return &copied
+}
+
+// RuleList returns the policy routing rules of the family.
+func (n NetlinkAPI) RuleList(family int) ([]netlink.Rule, error) {
+	return n.nl().RuleList(family)
+}
+
+// RuleAdd adds a policy routing rule.
+func (n NetlinkAPI) RuleAdd(rule *netlink.Rule) error {
+	return n.nl().RuleAdd(rule)
+}
+
+// RuleDel removes a policy routing rule.
+func (n NetlinkAPI) RuleDel(rule *netlink.Rule) error {
+	return n.nl().RuleDel(rule)
+}
+
+// RouteAdd adds a route.
+func (n NetlinkAPI) RouteAdd(route *netlink.Route) error {
+	return n.nl().RouteAdd(multipath(route))
+}
+
+// RouteReplace adds a route or replaces the one with the same destination.
+func (n NetlinkAPI) RouteReplace(route *netlink.Route) error {
+	return n.nl().RouteReplace(multipath(route))
+}
+
+// RouteDel removes a route.
+func (n NetlinkAPI) RouteDel(route *netlink.Route) error {
+	return n.nl().RouteDel(multipath(route))
+}
+
+// LinkList returns all links.
+func (n NetlinkAPI) LinkList() ([]netlink.Link, error) {
+	return n.nl().LinkList()
+}
+
+// LinkAdd creates a link. A veth peer namespace given as NamedNetns is opened
+// for the duration of the call.
+func (n NetlinkAPI) LinkAdd(link netlink.Link) error {
+	if veth, ok := link.(*netlink.Veth); ok {
+		if name, ok := veth.PeerNamespace.(NamedNetns); ok {
+			handle, err := namespace.Open(string(name))
+			if err != nil {
+				return err
+			}
+			defer handle.Close()
+			resolved := *veth
+			resolved.PeerNamespace = netlink.NsFd(handle)
+			return n.nl().LinkAdd(&resolved)
+		}
+	}
+	tuntap, ok := link.(*netlink.Tuntap)
+	if !ok {
+		return n.nl().LinkAdd(link)
+	}
+	// Tun/tap devices are created through /dev/net/tun, which attaches them
+	// to the namespace of the calling thread.
+	if err := n.do(func() error { return n.nl().LinkAdd(link) }); err != nil {
+		return err
+	}
+	// A persistent tun/tap device outlives the queues opened to create it;
+	// leaving them open would keep the device busy for its real consumer.
+	for _, fd := range tuntap.Fds {
+		fd.Close()
+	}
+	return nil
+}
+
+// LinkDel deletes a link.
+func (n NetlinkAPI) LinkDel(link netlink.Link) error {
+	return n.nl().LinkDel(link)
+}
+
+// LinkModify changes the attributes of an existing link.
+func (n NetlinkAPI) LinkModify(link netlink.Link) error {
+	return n.nl().LinkModify(link)
+}
+
+// LinkSetMaster enslaves link to master.
+func (n NetlinkAPI) LinkSetMaster(link, master netlink.Link) error {
+	return n.nl().LinkSetMaster(link, master)
+}
+
+// LinkSetNoMaster releases link from its master.
+func (n NetlinkAPI) LinkSetNoMaster(link netlink.Link) error {
+	return n.nl().LinkSetNoMaster(link)
+}
+
+// LinkSetUp brings the link up.
+func (n NetlinkAPI) LinkSetUp(link netlink.Link) error {
+	return n.nl().LinkSetUp(link)
+}
+
+// LinkSetDown brings the link down.
+func (n NetlinkAPI) LinkSetDown(link netlink.Link) error {
+	return n.nl().LinkSetDown(link)
+}
+
+// LinkSetName renames the link.
+func (n NetlinkAPI) LinkSetName(link netlink.Link, name string) error {
+	return n.nl().LinkSetName(link, name)
+}
+
+// ConfigureWireGuard sets the keys, listen port and peers of a WireGuard device.
+func (n NetlinkAPI) ConfigureWireGuard(name string, device wireguard.Device) error {
+	return n.do(func() error { return wireguard.Client{}.Configure(name, device) })
+}
+
+// LinkSetMTU changes the MTU of the link.
+func (n NetlinkAPI) LinkSetMTU(link netlink.Link, mtu int) error {
+	return n.nl().LinkSetMTU(link, mtu)
+}
+
+// changeBuffer bounds the notifications queued while an apply runs; more
+// are dropped.
+const changeBuffer = 256
+
+// WatchChanges subscribes to link and address notifications in the
+// namespace of n. Link notifications name the requesting process when the
+// kernel reports its port id and the process can be found.
+func (n NetlinkAPI) WatchChanges(done <-chan struct{}) (<-chan Change, error) {
+	var ns *netns.NsHandle
+	if n.handle != nil {
+		ns = &n.netns
+	}
+	links := make(chan netlink.LinkUpdate)
+	if err := netlink.LinkSubscribeWithOptions(links, done, netlink.LinkSubscribeOptions{Namespace: ns}); err != nil {
+		return nil, fmt.Errorf("subscribe to link updates: %w", err)
+	}
+	addrs := make(chan netlink.AddrUpdate)
+	if err := netlink.AddrSubscribeWithOptions(addrs, done, netlink.AddrSubscribeOptions{Namespace: ns}); err != nil {
+		return nil, fmt.Errorf("subscribe to address updates: %w", err)
+	}
+	out := make(chan Change, changeBuffer)
+	go func() {
+		defer close(out)
+		owners := map[uint32]netlinkproc.Process{}
+		for links != nil || addrs != nil {
+			var change Change
+			select {
+			case <-done:
+				return
+			case update, ok := <-links:
+				if !ok {
+					links = nil
+					continue
+				}
+				change = n.linkChange(update, owners)
+			case update, ok := <-addrs:
+				if !ok {
+					addrs = nil
+					continue
+				}
+				change = Change{LinkIndex: update.LinkIndex}
+			}
+			select {
+			case out <- change:
+			default:
+			}
+		}
+	}()
+	return out, nil
+}
+
+// networkManagers are the command names of daemons that configure links and
+// addresses on their own.
+var networkManagers = []string{"NetworkManager", "systemd-network", "dhclient", "dhcpcd", "connmand", "wickedd"}
+
+// NetworkManagers lists the running network management daemons.
+func (n NetlinkAPI) NetworkManagers() []string {
+	running, err := netlinkproc.Running(networkManagers...)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(running))
+	for _, process := range running {
+		names = append(names, process.String())
+	}
+	return names
+}
+
+// Managers returns the running network managers that control the named
+// interface. Their state files describe the namespace of the host, so links
+// in other namespaces and links that do not exist yet have none.
+func (n NetlinkAPI) Managers(name string) ([]netmanager.Manager, error) {
+	if n.handle != nil {
+		return nil, nil
+	}
+	link, err := n.LinkByName(name)
+	if errors.Is(err, ErrLinkNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return netmanager.Managing(link.Attrs().Index, name)
+}
+
+// linkChange attributes a link notification to the process holding the port
+// that sent the request, caching lookups in owners.
+func (n NetlinkAPI) linkChange(update netlink.LinkUpdate, owners map[uint32]netlinkproc.Process) Change {
+	change := Change{LinkIndex: int(update.Index)}
+	port := update.Header.Pid
+	if port == 0 {
+		return change
+	}
+	owner, ok := owners[port]
+	if !ok {
+		err := n.do(func() (err error) {
+			owner, err = netlinkproc.Owner(port)
+			return err
+		})
+		if err != nil {
+			return change
+		}
+		owners[port] = owner
+	}
+	change.Process = owner.String()
+	change.Self = owner.PID == os.Getpid()
+	return change
+}
+
+// LinkSetHardwareAddr changes the MAC address of the link.
+func (n NetlinkAPI) LinkSetHardwareAddr(link netlink.Link, hw net.HardwareAddr) error {
+	return n.nl().LinkSetHardwareAddr(link, hw)
+}
+
+// ProbePathMTU measures the path MTU towards target through device.
+func (n NetlinkAPI) ProbePathMTU(device string, target net.IP, max int, timeout time.Duration) (int, error) {
+	var mtu int
+	err := n.do(func() (err error) {
+		mtu, err = pmtu.Prober{Timeout: timeout}.Probe(device, target, max)
+		return err
+	})
+	return mtu, err
+}
+
+// AcquireLease obtains a DHCPv4 lease for link and assigns it.
+func (n NetlinkAPI) AcquireLease(link netlink.Link, requested net.IP, route bool) (dhcp4.Lease, error) {
+	var lease dhcp4.Lease
+	err := n.do(func() (err error) {
+		lease, err = dhcp4.Client{}.Acquire(link.Attrs().Name, link.Attrs().HardwareAddr, requested)
+		return err
+	})
+	if err != nil {
+		return lease, err
+	}
+	if err := n.nl().AddrReplace(link, leaseAddr(lease)); err != nil {
+		return lease, fmt.Errorf("assign leased %s: %w", &lease.Address, err)
+	}
+	if !route || lease.Router == nil {
+		return lease, nil
+	}
+	dst := &net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 8*net.IPv4len)}
+	if err := n.nl().RouteReplace(&netlink.Route{LinkIndex: link.Attrs().Index, Dst: dst, Gw: lease.Router, Protocol: unix.RTPROT_DHCP}); err != nil {
+		return lease, fmt.Errorf("route through %s: %w", lease.Router, err)
+	}
+	return lease, nil
+}
+
+// AcquireLease6 obtains a DHCPv6 lease for link and assigns it.
+func (n NetlinkAPI) AcquireLease6(link netlink.Link, requested net.IP) (dhcp6.Lease, error) {
+	var lease dhcp6.Lease
+	err := n.do(func() (err error) {
+		attrs := link.Attrs()
+		lease, err = dhcp6.Client{}.Acquire(attrs.Name, attrs.Index, attrs.HardwareAddr, requested)
+		return err
+	})
+	if err != nil {
+		return lease, err
+	}
+	if err := n.nl().AddrReplace(link, lease6Addr(lease)); err != nil {
+		return lease, fmt.Errorf("assign leased %s: %w", lease.Address, err)
+	}
+	return lease, nil
+}
+
+// WaitGlobalIPv6 polls the addresses of link until one acquired from router
+// advertisements or DHCPv6 is usable.
+func (n NetlinkAPI) WaitGlobalIPv6(link netlink.Link, timeout time.Duration) (*netlink.Addr, error) {
+	for waited := time.Duration(0); ; waited += acquirePollInterval {
+		addrs, err := n.nl().AddrList(link, netlink.FAMILY_V6)
+		if err != nil {
+			return nil, fmt.Errorf("list addresses for family %d: %w", netlink.FAMILY_V6, err)
+		}
+		if addr := acquiredIPv6(addrs); addr != nil {
+			return addr, nil
+		}
+		if waited >= timeout {
+			return nil, fmt.Errorf("no global IPv6 address after %s", timeout)
+		}
+		time.Sleep(acquirePollInterval)
+	}
+}
+
+// BusAddress returns the PCI address backing the link, read from sysfs. The
+// sysfs mounted for the process describes its own network namespace, so
+// links in another namespace are not found there.
+func (n NetlinkAPI) BusAddress(name string) (string, error) {
+	return sysfsBusAddress(sysClassNet, name)
+}
+
+// procSys is where the kernel exposes sysctls.
+const procSys = "/proc/sys"
+
+// Sysctl reads a kernel parameter by its path below /proc/sys, such as
+// net/ipv4/conf/eth0/rp_filter. Network parameters are read in the namespace
+// of n.
+func (n NetlinkAPI) Sysctl(path string) (string, error) {
+	var value string
+	err := n.do(func() error {
+		data, err := os.ReadFile(filepath.Join(procSys, filepath.Clean("/"+path)))
+		value = strings.TrimSpace(string(data))
+		return err
+	})
+	return value, err
+}
+
+// SetSysctl writes a kernel parameter by its path below /proc/sys, in the
+// namespace of n like Sysctl.
+func (n NetlinkAPI) SetSysctl(path, value string) error {
+	return n.do(func() error {
+		return os.WriteFile(filepath.Join(procSys, filepath.Clean("/"+path)), []byte(value), 0)
+	})
+}
+
+// Offloads reads the offload features of the named link.
+func (n NetlinkAPI) Offloads(name string) (ethtool.Features, error) {
+	var features ethtool.Features
+	err := n.do(func() (err error) {
+		features, err = ethtool.GetFeatures(name)
+		return err
+	})
+	return features, err
+}
+
+// DriverInfo reads the driver and bus information of the named link.
+func (n NetlinkAPI) DriverInfo(name string) (ethtool.DriverInfo, error) {
+	var info ethtool.DriverInfo
+	err := n.do(func() (err error) {
+		info, err = ethtool.GetDriverInfo(name)
+		return err
+	})
+	return info, err
+}
+
+// SetOffloads turns offload features of the named link on or off.
+func (n NetlinkAPI) SetOffloads(name string, offloads map[string]bool) error {
+	return n.do(func() error { return ethtool.SetFeatures(name, offloads) })
+}
+
+// Rings reads the ring sizes of the named link and their maxima.
+func (n NetlinkAPI) Rings(name string) (current, max map[string]uint32, err error) {
+	err = n.do(func() (err error) {
+		current, max, err = ethtool.GetRings(name)
+		return err
+	})
+	return current, max, err
+}
+
+// SetRings resizes rings of the named link.
+func (n NetlinkAPI) SetRings(name string, rings map[string]uint32) error {
+	return n.do(func() error { return ethtool.SetRings(name, rings) })
+}
+
+// Coalesce reads the interrupt coalescing parameters of the named link.
+func (n NetlinkAPI) Coalesce(name string) (map[string]uint32, error) {
+	var params map[string]uint32
+	err := n.do(func() (err error) {
+		params, err = ethtool.GetCoalesce(name)
+		return err
+	})
+	return params, err
+}
+
+// SetCoalesce changes interrupt coalescing parameters of the named link.
+func (n NetlinkAPI) SetCoalesce(name string, params map[string]uint32) error {
+	return n.do(func() error { return ethtool.SetCoalesce(name, params) })
+}
+
+// LinkSetVfHardwareAddr sets the MAC address of a virtual function.
+func (n NetlinkAPI) LinkSetVfHardwareAddr(link netlink.Link, vf int, hwaddr net.HardwareAddr) error {
+	return n.nl().LinkSetVfHardwareAddr(link, vf, hwaddr)
+}
+
+// LinkSetVfVlanQos sets the VLAN and priority of a virtual function.
+func (n NetlinkAPI) LinkSetVfVlanQos(link netlink.Link, vf, vlan, qos int) error {
+	return n.nl().LinkSetVfVlanQos(link, vf, vlan, qos)
+}
+
+// LinkSetVfSpoofchk turns spoof checking of a virtual function on or off.
+func (n NetlinkAPI) LinkSetVfSpoofchk(link netlink.Link, vf int, check bool) error {
+	return n.nl().LinkSetVfSpoofchk(link, vf, check)
+}
+
+// LinkSetVfTrust turns trust of a virtual function on or off.
+func (n NetlinkAPI) LinkSetVfTrust(link netlink.Link, vf int, state bool) error {
+	return n.nl().LinkSetVfTrust(link, vf, state)
+}
+
+// LinkSetVfRate sets the transmit rate bounds of a virtual function in
+// Mbit/s.
+func (n NetlinkAPI) LinkSetVfRate(link netlink.Link, vf, minRate, maxRate int) error {
+	return n.nl().LinkSetVfRate(link, vf, minRate, maxRate)
+}
+
+// PinnedProgramID returns the id of the BPF program pinned at path.
+func (n NetlinkAPI) PinnedProgramID(path string) (uint32, error) {
+	prog, err := bpf.OpenPinned(path)
+	if err != nil {
+		return 0, err
+	}
+	defer prog.Close()
+	return prog.ID, nil
+}
+
+// AttachXDP attaches the XDP program pinned at path to the link, replacing
+// the one attached in the same mode.
+func (n NetlinkAPI) AttachXDP(link netlink.Link, pinned string, flags int) error {
+	prog, err := bpf.OpenPinned(pinned)
+	if err != nil {
+		return err
+	}
+	defer prog.Close()
+	return n.do(func() error { return netlink.LinkSetXdpFdWithFlags(link, prog.FD, flags) })
+}
+
+// DetachXDP detaches the XDP program attached to the link in the mode of
+// flags.
+func (n NetlinkAPI) DetachXDP(link netlink.Link, flags int) error {
+	return n.do(func() error { return netlink.LinkSetXdpFdWithFlags(link, noProgram, flags) })
+}
+
+// AnnounceAddresses announces ips to the neighbors of the link.
+func (n NetlinkAPI) AnnounceAddresses(link netlink.Link, ips []net.IP, count int, interval time.Duration) error {
+	return n.do(func() error {
+		return announce.Announcer{Count: count, Interval: interval}.Announce(link.Attrs().Name, ips)
+	})
+}
+
+// QdiscList returns the queueing disciplines of the link.
+func (n NetlinkAPI) QdiscList(link netlink.Link) ([]netlink.Qdisc, error) {
+	return n.nl().QdiscList(link)
+}
+
+// QdiscReplace adds a qdisc or replaces the one at the same parent.
+func (n NetlinkAPI) QdiscReplace(qdisc netlink.Qdisc) error {
+	return n.nl().QdiscReplace(qdisc)
+}
+
+// LargeReceiveOffload reports whether LRO is enabled on the named link.
+func (n NetlinkAPI) LargeReceiveOffload(name string) (bool, error) {
+	var enabled bool
+	err := n.do(func() (err error) {
+		enabled, err = ethtool.LRO(name)
+		return err
+	})
+	return enabled, err
 }
@@ -2,7 +2,10 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/vishvananda/netlink"
 )
@@ -22,14 +25,48 @@ type mockNetlinkProvider struct {
 	addErr error
 	delErr error
 
-	added   []string
-	removed []string
+	added        []string
+	addedAddrs   []netlink.Addr
+	addrReplaced []string
+	removed      []string
+
+	neighs       map[int][]netlink.Neigh
+	neighSet     []string
+	neighRemoved []string
+	neighSetErr  error
+
+	routes        map[int][]netlink.Route
+	routeAdded    []string
+	routeReplaced []string
+	routeRemoved  []string
+
+	links       []netlink.Link
+	linkAdded   []netlink.Link
+	linkDeleted []string
+	linkAddErr  error
+	modified    []netlink.Link
+
+	byName   map[string]netlink.Link
+	attached []string
+	released []string
+	states   []string
+	renamed  []string
+	mtus     []string
+	macs     []string
+	macErr   error
 }
 
 func (m *mockNetlinkProvider) LinkByName(name string) (netlink.Link, error) {
 	if m.linkErr != nil {
 		return nil, m.linkErr
 	}
+	if m.byName != nil {
+		link, ok := m.byName[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrLinkNotFound, name)
+		}
+		return link, nil
+	}
 	if m.link == nil {
 		return &fakeLink{}, nil
 	}
@@ -53,6 +90,13 @@ func (m *mockNetlinkProvider) AddrAdd(link netlink.Link, addr *netlink.Addr) err
 		return m.addErr
 	}
 	m.added = append(m.added, addr.String())
+	m.addedAddrs = append(m.addedAddrs, *addr)
+	return nil
+}
+
+func (m *mockNetlinkProvider) AddrReplace(link netlink.Link, addr *netlink.Addr) error {
+	m.addrReplaced = append(m.addrReplaced, addr.String())
+	m.addedAddrs = append(m.addedAddrs, *addr)
 	return nil
 }
 
@@ -64,6 +108,108 @@ func (m *mockNetlinkProvider) AddrDel(link netlink.Link, addr *netlink.Addr) err
 	return nil
 }
 
+func (m *mockNetlinkProvider) NeighList(linkIndex, family int) ([]netlink.Neigh, error) {
+	return m.neighs[family], nil
+}
+
+func (m *mockNetlinkProvider) NeighSet(neigh *netlink.Neigh) error {
+	if m.neighSetErr != nil {
+		return m.neighSetErr
+	}
+	m.neighSet = append(m.neighSet, neigh.String())
+	return nil
+}
+
+func (m *mockNetlinkProvider) NeighDel(neigh *netlink.Neigh) error {
+	m.neighRemoved = append(m.neighRemoved, neigh.String())
+	return nil
+}
+
+func (m *mockNetlinkProvider) RouteList(link netlink.Link, family int) ([]netlink.Route, error) {
+	return m.routes[family], nil
+}
+
+func (m *mockNetlinkProvider) RouteAdd(route *netlink.Route) error {
+	m.routeAdded = append(m.routeAdded, routeKey(*route))
+	return nil
+}
+
+func (m *mockNetlinkProvider) RouteReplace(route *netlink.Route) error {
+	m.routeReplaced = append(m.routeReplaced, routeKey(*route))
+	return nil
+}
+
+func (m *mockNetlinkProvider) RouteDel(route *netlink.Route) error {
+	m.routeRemoved = append(m.routeRemoved, routeKey(*route))
+	return nil
+}
+
+func (m *mockNetlinkProvider) LinkList() ([]netlink.Link, error) {
+	return m.links, nil
+}
+
+func (m *mockNetlinkProvider) LinkAdd(link netlink.Link) error {
+	if m.linkAddErr != nil {
+		return m.linkAddErr
+	}
+	m.linkAdded = append(m.linkAdded, link)
+	if m.byName != nil {
+		link.Attrs().Index = 100 + len(m.linkAdded)
+		m.byName[link.Attrs().Name] = link
+	}
+	return nil
+}
+
+func (m *mockNetlinkProvider) LinkDel(link netlink.Link) error {
+	m.linkDeleted = append(m.linkDeleted, link.Attrs().Name)
+	return nil
+}
+
+func (m *mockNetlinkProvider) LinkModify(link netlink.Link) error {
+	m.modified = append(m.modified, link)
+	return nil
+}
+
+func (m *mockNetlinkProvider) LinkSetMaster(link, master netlink.Link) error {
+	m.attached = append(m.attached, link.Attrs().Name+">"+master.Attrs().Name)
+	return nil
+}
+
+func (m *mockNetlinkProvider) LinkSetNoMaster(link netlink.Link) error {
+	m.released = append(m.released, link.Attrs().Name)
+	return nil
+}
+
+func (m *mockNetlinkProvider) LinkSetUp(link netlink.Link) error {
+	m.states = append(m.states, link.Attrs().Name+":up")
+	return nil
+}
+
+func (m *mockNetlinkProvider) LinkSetDown(link netlink.Link) error {
+	m.states = append(m.states, link.Attrs().Name+":down")
+	return nil
+}
+
+func (m *mockNetlinkProvider) LinkSetName(link netlink.Link, name string) error {
+	m.renamed = append(m.renamed, link.Attrs().Name+">"+name)
+	return nil
+}
+
+func (m *mockNetlinkProvider) LinkSetMTU(link netlink.Link, mtu int) error {
+	m.mtus = append(m.mtus, fmt.Sprintf("%s:%d", link.Attrs().Name, mtu))
+	return nil
+}
+
+func (m *mockNetlinkProvider) LinkSetHardwareAddr(link netlink.Link, hw net.HardwareAddr) error {
+	if m.macErr != nil {
+		err := m.macErr
+		m.macErr = nil
+		return err
+	}
+	m.macs = append(m.macs, link.Attrs().Name+":"+hw.String())
+	return nil
+}
+
 func TestNetlinkExecutorApplyAddsAndRemoves(t *testing.T) {
 	provider := &mockNetlinkProvider{
 		lists: map[int][]netlink.Addr{
@@ -77,7 +223,7 @@ func TestNetlinkExecutorApplyAddsAndRemoves(t *testing.T) {
 		},
 	}
 	exec := NetlinkExecutor{Provider: provider}
-	cfg := Configuration{Interface: "eth0", Addresses: []string{"192.0.2.10/24", "192.0.2.20/24", "2001:db8::10/64"}}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "192.0.2.10/24"}, {CIDR: "192.0.2.20/24"}, {CIDR: "2001:db8::10/64"}}}
 	if err := exec.Apply(cfg); err != nil {
 		t.Fatalf("Apply() error = %v", err)
 	}
@@ -94,7 +240,7 @@ func TestNetlinkExecutorApplyAddsAndRemoves(t *testing.T) {
 func TestNetlinkExecutorApplyPropagatesLinkError(t *testing.T) {
 	provider := &mockNetlinkProvider{linkErr: errors.New("boom")}
 	exec := NetlinkExecutor{Provider: provider}
-	cfg := Configuration{Interface: "eth0", Addresses: []string{"192.0.2.1/24"}}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "192.0.2.1/24"}}}
 	if err := exec.Apply(cfg); err == nil {
 		t.Fatal("expected error when link lookup fails")
 	}
@@ -102,7 +248,7 @@ func TestNetlinkExecutorApplyPropagatesLinkError(t *testing.T) {
 
 func TestNetlinkExecutorApplyValidatesAddresses(t *testing.T) {
 	exec := NetlinkExecutor{Provider: &mockNetlinkProvider{}}
-	cfg := Configuration{Interface: "eth0", Addresses: []string{"not-an-ip"}}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "not-an-ip"}}}
 	if err := exec.Apply(cfg); err == nil {
 		t.Fatal("expected parse error")
 	}
@@ -111,7 +257,7 @@ func TestNetlinkExecutorApplyValidatesAddresses(t *testing.T) {
 func TestNetlinkExecutorAddError(t *testing.T) {
 	provider := &mockNetlinkProvider{addErr: errors.New("add-failed")}
 	exec := NetlinkExecutor{Provider: provider}
-	cfg := Configuration{Interface: "eth0", Addresses: []string{"192.0.2.1/24"}}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "192.0.2.1/24"}}}
 	if err := exec.Apply(cfg); err == nil {
 		t.Fatal("expected add error")
 	}
@@ -125,7 +271,7 @@ func TestNetlinkExecutorRemoveError(t *testing.T) {
 		delErr: errors.New("del-failed"),
 	}
 	exec := NetlinkExecutor{Provider: provider}
-	cfg := Configuration{Interface: "eth0", Addresses: []string{"192.0.2.10/24"}}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "192.0.2.10/24"}}}
 	if err := exec.Apply(cfg); err == nil {
 		t.Fatal("expected delete error")
 	}
@@ -136,12 +282,50 @@ func TestNetlinkExecutorListError(t *testing.T) {
 		listErr: map[int]error{netlink.FAMILY_V4: errors.New("boom")},
 	}
 	exec := NetlinkExecutor{Provider: provider}
-	cfg := Configuration{Interface: "eth0", Addresses: []string{"192.0.2.10/24"}}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "192.0.2.10/24"}}}
 	if err := exec.Apply(cfg); err == nil {
 		t.Fatal("expected list error")
 	}
 }
 
+func TestNetlinkExecutorAppliesTTL(t *testing.T) {
+	provider := &mockNetlinkProvider{}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{
+		{CIDR: "192.0.2.10/24", TTL: Duration(90 * time.Second)},
+		{CIDR: "192.0.2.20/24"},
+	}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.addedAddrs) != 2 {
+		t.Fatalf("expected 2 added addresses, got %v", provider.added)
+	}
+	for _, addr := range provider.addedAddrs {
+		switch addr.IPNet.String() {
+		case "192.0.2.10/24":
+			if addr.ValidLft != 90 || addr.PreferedLft != 90 {
+				t.Fatalf("unexpected lifetimes for temporary address: %#v", addr)
+			}
+		case "192.0.2.20/24":
+			if addr.ValidLft != 0 || addr.PreferedLft != 0 {
+				t.Fatalf("expected permanent address, got %#v", addr)
+			}
+		default:
+			t.Fatalf("unexpected address %s", addr.IPNet)
+		}
+	}
+}
+
+func TestLifetimeSecondsRoundsUp(t *testing.T) {
+	if got := lifetimeSeconds(Duration(1500 * time.Millisecond)); got != 2 {
+		t.Fatalf("lifetimeSeconds() = %d, want 2", got)
+	}
+	if got := lifetimeSeconds(0); got != 0 {
+		t.Fatalf("lifetimeSeconds(0) = %d, want 0", got)
+	}
+}
+
 func mustAddr(t *testing.T, cidr string) netlink.Addr {
 	t.Helper()
 	addr, err := netlink.ParseAddr(cidr)
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/user/goeth/internal/netmanager"
+)
+
+// ChangeWatcher is implemented by providers that report link and address
+// changes as they happen. It is optional; with it, Apply notices other
+// processes, such as a network manager fighting back, changing the interface
+// underneath it.
+type ChangeWatcher interface {
+	// WatchChanges delivers changes until done is closed.
+	WatchChanges(done <-chan struct{}) (<-chan Change, error)
+	// NetworkManagers names running processes known to manage interfaces,
+	// the likely culprits when the kernel does not name a sender.
+	NetworkManagers() []string
+}
+
+// ManagerDetector is implemented by providers that can tell whether a network
+// manager such as NetworkManager controls an interface. Applying a
+// configuration to such an interface starts a fight that neither side wins.
+type ManagerDetector interface {
+	Managers(name string) ([]netmanager.Manager, error)
+}
+
+// Change is a notification about a link or one of its addresses.
+type Change struct {
+	LinkIndex int
+	// Process names the process whose request caused the change, such as
+	// "NetworkManager (pid 812)". It is empty when the kernel made the change
+	// or the sender is unknown; address notifications never name a sender.
+	Process string
+	// Self is set for changes this process requested.
+	Self bool
+}
+
+// ErrExternalChange is returned when another process keeps undoing what
+// Apply does.
+var ErrExternalChange = errors.New("another process keeps changing the interface")
+
+const (
+	// changeSettle is how long Apply waits for reactions to its changes
+	// before checking whether they were undone.
+	changeSettle = 200 * time.Millisecond
+	// changeRetries is how often Apply reconciles again after the interface
+	// was changed underneath it.
+	changeRetries = 2
+)
+
+// guardedReconcile reconciles while watching for changes to the interface.
+// When changes other than its own arrived and the interface no longer
+// matches p, it reconciles again, and gives up after changeRetries attempts.
+func (n NetlinkExecutor) guardedReconcile(cfg Configuration, p plan, watcher ChangeWatcher) error {
+	done := make(chan struct{})
+	defer close(done)
+	changes, err := watcher.WatchChanges(done)
+	if err != nil {
+		return fmt.Errorf("watch changes to %s: %w", cfg.Interface, err)
+	}
+	for attempt := 0; ; attempt++ {
+		if err := n.reconcile(cfg, p); err != nil {
+			return err
+		}
+		n.sleep(changeSettle)
+		received := drainChanges(changes)
+		link, err := n.Provider.LinkByName(cfg.Interface)
+		var drift []string
+		switch {
+		case errors.Is(err, ErrLinkNotFound):
+			drift = []string{"the link was deleted"}
+		case err != nil:
+			return fmt.Errorf("lookup interface %q: %w", cfg.Interface, err)
+		default:
+			received = changesTo(received, link.Attrs().Index)
+			if len(received) == 0 {
+				return nil
+			}
+			if drift, err = n.drift(cfg, p, link); err != nil {
+				return err
+			}
+		}
+		if len(drift) == 0 {
+			return nil
+		}
+		by := ""
+		if processes := senders(received); len(processes) > 0 {
+			by = " (" + strings.Join(processes, ", ") + ")"
+		} else if managers := watcher.NetworkManagers(); len(managers) > 0 {
+			by = " (possibly " + strings.Join(managers, ", ") + ")"
+		}
+		if attempt == changeRetries {
+			return fmt.Errorf("%s: %w%s: %s", cfg.Interface, ErrExternalChange, by, strings.Join(drift, "; "))
+		}
+		n.report("%s was changed by another process%s while it was applied (%s); applying again", cfg.Interface, by, strings.Join(drift, "; "))
+	}
+}
+
+// drainChanges returns the changes received so far without waiting.
+func drainChanges(changes <-chan Change) []Change {
+	var received []Change
+	for {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				return received
+			}
+			received = append(received, change)
+		default:
+			return received
+		}
+	}
+}
+
+// changesTo keeps the changes to the link with the given index that this
+// process did not request.
+func changesTo(changes []Change, index int) []Change {
+	var kept []Change
+	for _, change := range changes {
+		if change.LinkIndex == index && !change.Self {
+			kept = append(kept, change)
+		}
+	}
+	return kept
+}
+
+func senders(changes []Change) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, change := range changes {
+		if change.Process != "" && !seen[change.Process] {
+			seen[change.Process] = true
+			names = append(names, change.Process)
+		}
+	}
+	return names
+}
+
+// drift describes how link differs from the MTU, MAC, state and addresses
+// in p. Routes, neighbors and stacked links are not compared.
+func (n NetlinkExecutor) drift(cfg Configuration, p plan, link netlink.Link) ([]string, error) {
+	attrs := link.Attrs()
+	var drift []string
+	if cfg.MTU != 0 && cfg.MTUProbe == nil && attrs.MTU != cfg.MTU {
+		drift = append(drift, fmt.Sprintf("mtu is %d, want %d", attrs.MTU, cfg.MTU))
+	}
+	mac := p.mac
+	if cfg.MAC == macPermanent {
+		mac = attrs.PermHWAddr
+	}
+	if mac != nil && attrs.HardwareAddr.String() != mac.String() {
+		drift = append(drift, fmt.Sprintf("mac is %s, want %s", attrs.HardwareAddr, mac))
+	}
+	up := attrs.Flags&net.FlagUp != 0
+	switch {
+	case cfg.State == stateUp && !up:
+		drift = append(drift, "link is down")
+	case cfg.State == stateDown && up:
+		drift = append(drift, "link is up")
+	}
+	current, err := n.collectCurrent(link, p.listedFamilies())
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range sortedKeys(p.desired) {
+		if _, ok := current[key]; !ok {
+			drift = append(drift, fmt.Sprintf("address %s was removed", key))
+		}
+	}
+	for _, key := range sortedKeys(current) {
+		if _, ok := p.desired[key]; !ok && p.owns(current[key]) {
+			drift = append(drift, fmt.Sprintf("address %s was added", key))
+		}
+	}
+	return drift, nil
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+// fightingProvider removes the first addresses added to it again, the way a
+// network manager undoes changes it did not make, and announces each change
+// like the kernel would.
+type fightingProvider struct {
+	*mockNetlinkProvider
+	changes  chan Change
+	fights   int
+	culprit  string
+	managers []string
+}
+
+func newFightingProvider(fights int, culprit string) *fightingProvider {
+	return &fightingProvider{
+		mockNetlinkProvider: &mockNetlinkProvider{
+			link:  &fakeLink{netlink.LinkAttrs{Name: "eth0", Index: 2}},
+			lists: map[int][]netlink.Addr{},
+		},
+		changes: make(chan Change, 16),
+		fights:  fights,
+		culprit: culprit,
+	}
+}
+
+func (f *fightingProvider) WatchChanges(done <-chan struct{}) (<-chan Change, error) {
+	return f.changes, nil
+}
+
+func (f *fightingProvider) NetworkManagers() []string { return f.managers }
+
+func (f *fightingProvider) AddrAdd(link netlink.Link, addr *netlink.Addr) error {
+	if err := f.mockNetlinkProvider.AddrAdd(link, addr); err != nil {
+		return err
+	}
+	f.changes <- Change{LinkIndex: 2, Self: true}
+	if f.fights > 0 {
+		f.fights--
+		f.changes <- Change{LinkIndex: 2, Process: f.culprit}
+		return nil
+	}
+	f.lists[netlink.FAMILY_V4] = append(f.lists[netlink.FAMILY_V4], *addr)
+	return nil
+}
+
+func guardedConfig() Configuration {
+	return Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "192.0.2.10/24"}}}
+}
+
+func TestNetlinkExecutorReappliesAfterExternalChange(t *testing.T) {
+	provider := newFightingProvider(1, "NetworkManager (pid 812)")
+	var notes noteCollector
+	exec := NetlinkExecutor{Provider: provider, Reporter: &notes, Sleep: func(time.Duration) {}}
+	if err := exec.Apply(guardedConfig()); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.added) != 2 {
+		t.Fatalf("expected the address to be added twice, got %v", provider.added)
+	}
+	want := "eth0 was changed by another process (NetworkManager (pid 812)) while it was applied (address 192.0.2.10/24 was removed); applying again"
+	if len(notes) != 1 || notes[0] != want {
+		t.Fatalf("notes = %q, want %q", notes, want)
+	}
+}
+
+func TestNetlinkExecutorGivesUpOnPersistentExternalChange(t *testing.T) {
+	provider := newFightingProvider(changeRetries+1, "NetworkManager (pid 812)")
+	exec := NetlinkExecutor{Provider: provider, Sleep: func(time.Duration) {}}
+	err := exec.Apply(guardedConfig())
+	if !errors.Is(err, ErrExternalChange) {
+		t.Fatalf("expected ErrExternalChange, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "(NetworkManager (pid 812)): address 192.0.2.10/24 was removed") {
+		t.Fatalf("expected the process and the change in %q", err)
+	}
+	if len(provider.added) != changeRetries+1 {
+		t.Fatalf("expected %d attempts, got %v", changeRetries+1, provider.added)
+	}
+}
+
+func TestNetlinkExecutorSuspectsNetworkManagers(t *testing.T) {
+	provider := newFightingProvider(changeRetries+1, "")
+	provider.managers = []string{"systemd-network (pid 431)"}
+	exec := NetlinkExecutor{Provider: provider, Sleep: func(time.Duration) {}}
+	err := exec.Apply(guardedConfig())
+	if err == nil || !strings.Contains(err.Error(), "(possibly systemd-network (pid 431))") {
+		t.Fatalf("expected the running network manager to be suspected, got %v", err)
+	}
+}
+
+func TestNetlinkExecutorIgnoresOwnAndUnrelatedChanges(t *testing.T) {
+	provider := newFightingProvider(0, "")
+	provider.changes <- Change{LinkIndex: 3, Process: "NetworkManager (pid 812)"}
+	var notes noteCollector
+	exec := NetlinkExecutor{Provider: provider, Reporter: &notes, Sleep: func(time.Duration) {}}
+	cfg := Configuration{Interface: "eth0", MTU: 1500}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.mtus) != 1 || len(notes) != 0 {
+		t.Fatalf("expected a single attempt, got mtus %v and notes %q", provider.mtus, notes)
+	}
+}
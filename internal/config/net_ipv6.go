@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+// IPv6 settings are sysctls of net.ipv6.conf.<interface>.
+const (
+	sysctlAcceptRA    = "accept_ra"
+	sysctlAutoconf    = "autoconf"
+	sysctlUseTempaddr = "use_tempaddr"
+)
+
+// Values the kernel accepts for accept_ra and use_tempaddr (see
+// ip-sysctl.rst).
+var (
+	acceptRAModes    = []int{0, 1, 2}
+	useTempaddrModes = []int{0, 1, 2}
+)
+
+// String lists the IPv6 settings that are set.
+func (v IPv6) String() string {
+	var parts []string
+	if v.AcceptRA != nil {
+		parts = append(parts, "accept_ra "+strconv.Itoa(*v.AcceptRA))
+	}
+	if v.Autoconf != nil {
+		parts = append(parts, "autoconf "+onOff(*v.Autoconf))
+	}
+	if v.UseTempaddr != nil {
+		parts = append(parts, "use_tempaddr "+strconv.Itoa(*v.UseTempaddr))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// slaacOff reports whether the ipv6 section pins off address
+// autoconfiguration from router advertisements.
+func (v *IPv6) slaacOff() bool {
+	return v != nil && ((v.AcceptRA != nil && *v.AcceptRA == 0) || (v.Autoconf != nil && !*v.Autoconf))
+}
+
+// ipv6Sysctls converts the ipv6 section to the sysctls that implement it, by
+// sysctl(8) name.
+func ipv6Sysctls(cfg Configuration) (map[string]string, error) {
+	section := cfg.IPv6
+	if section == nil {
+		return nil, nil
+	}
+	sysctls := make(map[string]string)
+	prefix := "net.ipv6.conf." + SysctlName(cfg.Interface) + "."
+	if section.AcceptRA != nil {
+		if !slices.Contains(acceptRAModes, *section.AcceptRA) {
+			return nil, fmt.Errorf("ipv6 for %s: accept_ra %d is not one of %s", cfg.Interface, *section.AcceptRA, joinInts(acceptRAModes))
+		}
+		sysctls[prefix+sysctlAcceptRA] = strconv.Itoa(*section.AcceptRA)
+	}
+	if section.Autoconf != nil {
+		value := "0"
+		if *section.Autoconf {
+			value = "1"
+		}
+		sysctls[prefix+sysctlAutoconf] = value
+	}
+	if section.UseTempaddr != nil {
+		if !slices.Contains(useTempaddrModes, *section.UseTempaddr) {
+			return nil, fmt.Errorf("ipv6 for %s: use_tempaddr %d is not one of %s", cfg.Interface, *section.UseTempaddr, joinInts(useTempaddrModes))
+		}
+		sysctls[prefix+sysctlUseTempaddr] = strconv.Itoa(*section.UseTempaddr)
+	}
+	return sysctls, nil
+}
+
+// slaacSysctls turns on the accept_ra and autoconf sysctls for slaac, unless
+// the ipv6 or sysctls sections set them.
+func slaacSysctls(cfg Configuration) (map[string]string, error) {
+	if !cfg.SLAAC {
+		return nil, nil
+	}
+	if cfg.IPv6.slaacOff() {
+		return nil, fmt.Errorf("slaac for %s: the ipv6 section turns autoconfiguration off", cfg.Interface)
+	}
+	sysctls := make(map[string]string)
+	prefix := "net.ipv6.conf." + SysctlName(cfg.Interface) + "."
+	for name, set := range map[string]bool{
+		sysctlAcceptRA: cfg.IPv6 != nil && cfg.IPv6.AcceptRA != nil,
+		sysctlAutoconf: cfg.IPv6 != nil && cfg.IPv6.Autoconf != nil,
+	} {
+		if value, ok := cfg.Sysctls[prefix+name]; ok {
+			if strings.TrimSpace(value) == "0" {
+				return nil, fmt.Errorf("slaac for %s: sysctl %s turns autoconfiguration off", cfg.Interface, prefix+name)
+			}
+			continue
+		}
+		if !set {
+			sysctls[prefix+name] = "1"
+		}
+	}
+	return sysctls, nil
+}
+
+// listedFamilies are the address families whose addresses are compared
+// with p: the declared ones, IPv6 when autoconfigured addresses are to be
+// removed and the family of a lease that is to be found.
+func (p plan) listedFamilies() []int {
+	families := slices.Clone(p.families)
+	if p.dhcp4 && !slices.Contains(families, netlink.FAMILY_V4) {
+		families = append(families, netlink.FAMILY_V4)
+	}
+	if (p.slaacOff || p.dhcp6) && !slices.Contains(families, netlink.FAMILY_V6) {
+		families = append(families, netlink.FAMILY_V6)
+	}
+	return families
+}
+
+// owns reports whether an undeclared address belongs to goeth and is to be
+// removed. IPv6 link-local addresses and those that expire, which the kernel
+// configures from router advertisements, are left alone unless the ipv6
+// section turns autoconfiguration off, and so are leased addresses with
+// dhcp4 and dhcp6; other addresses only belong to goeth in the families it declares
+// addresses of.
+func (p plan) owns(addr *netlink.Addr) bool {
+	v6 := addrFamily(addr) == netlink.FAMILY_V6
+	switch {
+	case v6 && addr.IP.IsLinkLocalUnicast():
+		return false
+	case p.dhcp6 && leased6(addr):
+		return false
+	case v6 && addr.ValidLft > 0 && addr.ValidLft != lifetimeForever:
+		return p.slaacOff
+	case p.dhcp4 && leased(addr):
+		return false
+	}
+	return slices.Contains(p.families, addrFamily(addr))
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+// slaacAddrs returns an address list with a static, an autoconfigured and a
+// link-local address.
+func slaacAddrs(t *testing.T) map[int][]netlink.Addr {
+	t.Helper()
+	autoconf := v6Addr(t, "2001:db8::5054:ff:fe12:3456/64", 0)
+	autoconf.ValidLft, autoconf.PreferedLft = 2592000, 604800
+	return map[int][]netlink.Addr{netlink.FAMILY_V6: {
+		v6Addr(t, "2001:db8::10/64", 0),
+		autoconf,
+		v6Addr(t, "fe80::5054:ff:fe12:3456/64", 0),
+	}}
+}
+
+func TestNetlinkExecutorKeepsAutoconfiguredAddresses(t *testing.T) {
+	provider := &orderedProvider{mockNetlinkProvider: &mockNetlinkProvider{lists: slaacAddrs(t)}}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "2001:db8::20/64"}}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{"add 2001:db8::20/64", "remove 2001:db8::10/64"}
+	if !reflect.DeepEqual(provider.ops, want) {
+		t.Fatalf("address changes = %v, want %v", provider.ops, want)
+	}
+}
+
+func TestNetlinkExecutorRemovesAutoconfiguredAddressesWithSLAACOff(t *testing.T) {
+	off := false
+	provider := &sysctlProvider{
+		mockNetlinkProvider: &mockNetlinkProvider{link: &fakeLink{netlink.LinkAttrs{Name: "eth0", Index: 2}}, lists: slaacAddrs(t)},
+		values: map[string]string{
+			"net/ipv6/conf/eth0/autoconf":     "1",
+			"net/ipv6/conf/eth0/use_tempaddr": "0",
+		},
+	}
+	// Without declared IPv6 addresses only the autoconfigured one is goeth's.
+	cfg := Configuration{Interface: "eth0", IPv6: &IPv6{Autoconf: &off}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"net/ipv6/conf/eth0/autoconf=0"}; !reflect.DeepEqual(provider.set, want) {
+		t.Fatalf("set %v, want %v", provider.set, want)
+	}
+	if want := []string{"2001:db8::5054:ff:fe12:3456/64"}; !reflect.DeepEqual(provider.removed, want) {
+		t.Fatalf("removed %v, want %v", provider.removed, want)
+	}
+}
+
+func TestNetlinkExecutorValidatesIPv6(t *testing.T) {
+	provider := &sysctlProvider{mockNetlinkProvider: &mockNetlinkProvider{}, values: map[string]string{}}
+	three, zero := 3, 0
+	tests := []struct {
+		cfg  Configuration
+		want string
+	}{
+		{Configuration{IPv6: &IPv6{AcceptRA: &three}}, "accept_ra 3 is not one of 0, 1, 2"},
+		{Configuration{IPv6: &IPv6{UseTempaddr: &three}}, "use_tempaddr 3 is not one of 0, 1, 2"},
+		{Configuration{IPv6: &IPv6{AcceptRA: &zero}, Sysctls: map[string]string{"net.ipv6.conf.eth0.accept_ra": "0"}}, "also set by the ipv6 section"},
+	}
+	for _, tt := range tests {
+		tt.cfg.Interface = "eth0"
+		err := NetlinkExecutor{Provider: provider}.Apply(tt.cfg)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("Apply(%s) error = %v, want %q", tt.cfg.IPv6, err, tt.want)
+		}
+	}
+}
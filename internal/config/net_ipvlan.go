@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/vishvananda/netlink"
+)
+
+// defaultIPVLANMode shares the parent's MAC at layer 2, the kernel's own default.
+const defaultIPVLANMode = "l2"
+
+var ipvlanModes = map[string]netlink.IPVlanMode{
+	"l2":  netlink.IPVLAN_MODE_L2,
+	"l3":  netlink.IPVLAN_MODE_L3,
+	"l3s": netlink.IPVLAN_MODE_L3S,
+}
+
+// reconcileIPVLANs creates declared ipvlan sub-interfaces on parent and
+// deletes undeclared ones. A nil desired map leaves them untouched.
+func (n NetlinkExecutor) reconcileIPVLANs(parent netlink.Link, desired map[string]netlink.Link) error {
+	return n.reconcileChildren(parent, "ipvlan", desired, func(have, want netlink.Link) bool {
+		ipvlan, ok := have.(*netlink.IPVlan)
+		return ok && ipvlan.Mode == want.(*netlink.IPVlan).Mode
+	})
+}
+
+func parseDesiredIPVLANs(raw []IPVLAN) (map[string]netlink.Link, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	desired := make(map[string]netlink.Link, len(raw))
+	for _, entry := range raw {
+		if err := validateInterfaceName(entry.Name); err != nil {
+			return nil, fmt.Errorf("ipvlan: %w", err)
+		}
+		mode, err := ipvlanMode(entry.Mode)
+		if err != nil {
+			return nil, fmt.Errorf("ipvlan %s: %w", entry.Name, err)
+		}
+		if _, dup := desired[entry.Name]; dup {
+			return nil, fmt.Errorf("ipvlan %s is declared more than once", entry.Name)
+		}
+		desired[entry.Name] = &netlink.IPVlan{
+			LinkAttrs: netlink.LinkAttrs{Name: entry.Name},
+			Mode:      mode,
+		}
+	}
+	return desired, nil
+}
+
+func ipvlanMode(name string) (netlink.IPVlanMode, error) {
+	if name == "" {
+		name = defaultIPVLANMode
+	}
+	mode, ok := ipvlanModes[name]
+	if !ok {
+		return 0, fmt.Errorf("unsupported mode %q (want l2, l3 or l3s)", name)
+	}
+	return mode, nil
+}
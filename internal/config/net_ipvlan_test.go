@@ -0,0 +1,48 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestNetlinkExecutorReconcilesIPVLANs(t *testing.T) {
+	parent := &fakeLink{netlink.LinkAttrs{Name: "eth0", Index: 2}}
+	provider := &mockNetlinkProvider{
+		link: parent,
+		links: []netlink.Link{
+			parent,
+			&netlink.IPVlan{LinkAttrs: netlink.LinkAttrs{Name: "ipv0", ParentIndex: 2}, Mode: netlink.IPVLAN_MODE_L2},
+			&netlink.IPVlan{LinkAttrs: netlink.LinkAttrs{Name: "ipv1", ParentIndex: 2}, Mode: netlink.IPVLAN_MODE_L2},
+			&netlink.Macvlan{LinkAttrs: netlink.LinkAttrs{Name: "mv0", ParentIndex: 2}, Mode: netlink.MACVLAN_MODE_BRIDGE},
+		},
+	}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "eth0", IPVLANs: []IPVLAN{{Name: "ipv0"}, {Name: "ipv1", Mode: "l3"}}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.linkDeleted) != 1 || provider.linkDeleted[0] != "ipv1" {
+		t.Fatalf("unexpected deleted links: %v", provider.linkDeleted)
+	}
+	if len(provider.linkAdded) != 1 {
+		t.Fatalf("expected 1 created ipvlan, got %d", len(provider.linkAdded))
+	}
+	ipvlan, ok := provider.linkAdded[0].(*netlink.IPVlan)
+	if !ok || ipvlan.Name != "ipv1" || ipvlan.ParentIndex != 2 || ipvlan.Mode != netlink.IPVLAN_MODE_L3 {
+		t.Fatalf("unexpected created link %#v", provider.linkAdded[0])
+	}
+}
+
+func TestParseDesiredIPVLANsValidates(t *testing.T) {
+	cases := map[string][]IPVLAN{
+		"missing name": {{Mode: "l2"}},
+		"bad mode":     {{Name: "ipv0", Mode: "bridge"}},
+		"duplicate":    {{Name: "ipv0"}, {Name: "ipv0", Mode: "l3"}},
+	}
+	for name, ipvlans := range cases {
+		if _, err := parseDesiredIPVLANs(ipvlans); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// maxInterfaceNameLen mirrors IFNAMSIZ minus the trailing NUL byte.
+	maxInterfaceNameLen = 15
+	minVLANID           = 1
+	maxVLANID           = 4094
+)
+
+// ensureLink looks up the configured interface, creating it first when the
+// configuration declares a device kind (such as a bridge) and it is missing.
+// The kernel creates links down; a created one is brought up unless its
+// state is declared down.
+func (n NetlinkExecutor) ensureLink(cfg Configuration) (netlink.Link, error) {
+	link, err := n.Provider.LinkByName(cfg.Interface)
+	want := desiredLink(cfg)
+	if err != nil {
+		if want == nil || !errors.Is(err, ErrLinkNotFound) {
+			return nil, fmt.Errorf("lookup interface %q: %w", cfg.Interface, err)
+		}
+		if err := n.Provider.LinkAdd(want); err != nil {
+			return nil, fmt.Errorf("create %s %s: %w", want.Type(), cfg.Interface, err)
+		}
+		if cfg.State == stateDown {
+			return n.lookupLink(cfg.Interface)
+		}
+		return n.createdUp(cfg.Interface)
+	}
+	if want != nil && link.Type() != want.Type() {
+		return nil, fmt.Errorf("interface %s is a %s, not a %s", cfg.Interface, link.Type(), want.Type())
+	}
+	if err := checkLink(cfg, link); err != nil {
+		return nil, err
+	}
+	if cfg.Tunnel != nil {
+		if err := n.reconcileTunnel(link, want); err != nil {
+			return nil, err
+		}
+	}
+	return link, nil
+}
+
+// lookupLink looks up the link called name.
+func (n NetlinkExecutor) lookupLink(name string) (netlink.Link, error) {
+	link, err := n.Provider.LinkByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("lookup interface %q: %w", name, err)
+	}
+	return link, nil
+}
+
+// createdUp brings up the link called name that was just created and
+// returns it as it is then.
+func (n NetlinkExecutor) createdUp(name string) (netlink.Link, error) {
+	link, err := n.lookupLink(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := n.Provider.LinkSetUp(link); err != nil {
+		return nil, fmt.Errorf("set %s up: %w", name, err)
+	}
+	return n.lookupLink(name)
+}
+
+// validateLink checks the device sections before anything is created.
+func validateLink(cfg Configuration) error {
+	if cfg.Bond != nil && cfg.Bond.Mode != "" && netlink.StringToBondMode(cfg.Bond.Mode) == netlink.BOND_MODE_UNKNOWN {
+		return fmt.Errorf("bond %s: unknown mode %q", cfg.Interface, cfg.Bond.Mode)
+	}
+	if cfg.Bond != nil && cfg.Bond.Miimon < 0 {
+		return fmt.Errorf("bond %s: miimon must not be negative", cfg.Interface)
+	}
+	if err := validateVeth(cfg); err != nil {
+		return err
+	}
+	if err := validateTuntap(cfg); err != nil {
+		return err
+	}
+	return validateTunnel(cfg)
+}
+
+// NamedNetns refers to a network namespace by its name under /var/run/netns.
+// It is used as netlink.Veth.PeerNamespace and resolved by the provider.
+type NamedNetns string
+
+func validateVeth(cfg Configuration) error {
+	if cfg.Veth == nil {
+		return nil
+	}
+	if err := validateInterfaceName(cfg.Veth.Peer); err != nil {
+		return fmt.Errorf("veth %s: peer: %w", cfg.Interface, err)
+	}
+	if cfg.Veth.Peer == cfg.Interface && cfg.Veth.PeerNetns == "" {
+		return fmt.Errorf("veth %s: peer must have a different name", cfg.Interface)
+	}
+	if strings.ContainsRune(cfg.Veth.PeerNetns, '/') {
+		return fmt.Errorf("veth %s: invalid netns name %q", cfg.Interface, cfg.Veth.PeerNetns)
+	}
+	return nil
+}
+
+// desiredLink returns the device the configuration asks for, or nil when the
+// configured interface is expected to exist already.
+func desiredLink(cfg Configuration) netlink.Link {
+	attrs := netlink.LinkAttrs{Name: cfg.Interface}
+	switch {
+	case cfg.Bridge != nil:
+		return &netlink.Bridge{LinkAttrs: attrs}
+	case cfg.Bond != nil:
+		bond := netlink.NewLinkBond(attrs)
+		if cfg.Bond.Mode != "" {
+			bond.Mode = netlink.StringToBondMode(cfg.Bond.Mode)
+		}
+		if cfg.Bond.Miimon > 0 {
+			bond.Miimon = cfg.Bond.Miimon
+		}
+		return bond
+	case cfg.WireGuard != nil:
+		return &netlink.Wireguard{LinkAttrs: attrs}
+	case cfg.Tunnel != nil:
+		return desiredTunnel(attrs, cfg.Tunnel)
+	case cfg.Dummy != nil:
+		return &netlink.Dummy{LinkAttrs: attrs}
+	case cfg.Veth != nil:
+		veth := &netlink.Veth{LinkAttrs: attrs, PeerName: cfg.Veth.Peer}
+		if cfg.Veth.PeerNetns != "" {
+			veth.PeerNamespace = NamedNetns(cfg.Veth.PeerNetns)
+		}
+		return veth
+	case cfg.Tuntap != nil:
+		return desiredTuntap(attrs, cfg.Tuntap)
+	}
+	return nil
+}
+
+// checkLink reports settings of an existing device that cannot be changed in place.
+func checkLink(cfg Configuration, link netlink.Link) error {
+	if bond, ok := link.(*netlink.Bond); ok && cfg.Bond != nil && cfg.Bond.Mode != "" {
+		if want := netlink.StringToBondMode(cfg.Bond.Mode); bond.Mode != want {
+			return fmt.Errorf("bond %s runs in mode %s, want %s; recreate the bond to change it", cfg.Interface, bond.Mode, want)
+		}
+	}
+	if tuntap, ok := link.(*netlink.Tuntap); ok && cfg.Tuntap != nil {
+		return checkTuntap(cfg, tuntap)
+	}
+	return nil
+}
+
+// reconcilePorts enslaves the declared ports to master and releases any
+// other link currently attached to it. A nil ports list leaves membership alone.
+// Bond slaves are taken down while they are enslaved, as the kernel requires.
+func (n NetlinkExecutor) reconcilePorts(master netlink.Link, ports []string) error {
+	_, isBond := master.(*netlink.Bond)
+	if ports == nil {
+		return nil
+	}
+	desired := make(map[string]struct{}, len(ports))
+	for _, name := range ports {
+		if name == master.Attrs().Name {
+			return fmt.Errorf("%s cannot be a port of itself", name)
+		}
+		desired[name] = struct{}{}
+	}
+	links, err := n.Provider.LinkList()
+	if err != nil {
+		return fmt.Errorf("list links: %w", err)
+	}
+	for _, link := range links {
+		if link.Attrs().MasterIndex != master.Attrs().Index {
+			continue
+		}
+		if _, ok := desired[link.Attrs().Name]; ok {
+			continue
+		}
+		if err := n.Provider.LinkSetNoMaster(link); err != nil {
+			return fmt.Errorf("release %s from %s: %w", link.Attrs().Name, master.Attrs().Name, err)
+		}
+	}
+	for _, name := range ports {
+		port, err := n.Provider.LinkByName(name)
+		if err != nil {
+			return fmt.Errorf("lookup port %q: %w", name, err)
+		}
+		if port.Attrs().MasterIndex == master.Attrs().Index {
+			continue
+		}
+		if isBond {
+			if err := n.Provider.LinkSetDown(port); err != nil {
+				return fmt.Errorf("set %s down: %w", name, err)
+			}
+		}
+		if err := n.Provider.LinkSetMaster(port, master); err != nil {
+			return fmt.Errorf("attach %s to %s: %w", name, master.Attrs().Name, err)
+		}
+		if isBond {
+			if err := n.Provider.LinkSetUp(port); err != nil {
+				return fmt.Errorf("set %s up: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// reconcileVLANs creates declared VLANs on parent and deletes undeclared ones.
+// A nil desired map leaves the parent's VLANs untouched.
+func (n NetlinkExecutor) reconcileVLANs(parent netlink.Link, desired map[string]netlink.Link) error {
+	return n.reconcileChildren(parent, "vlan", desired, func(have, want netlink.Link) bool {
+		vlan, ok := have.(*netlink.Vlan)
+		return ok && vlan.VlanId == want.(*netlink.Vlan).VlanId
+	})
+}
+
+// reconcileChildren creates the desired links of kind on parent, bringing
+// them up, and deletes the other links of that kind stacked on it. Existing
+// links for which same reports false are deleted and recreated. A nil
+// desired map leaves the parent's links of that kind untouched.
+func (n NetlinkExecutor) reconcileChildren(parent netlink.Link, kind string, desired map[string]netlink.Link, same func(have, want netlink.Link) bool) error {
+	if desired == nil {
+		return nil
+	}
+	links, err := n.Provider.LinkList()
+	if err != nil {
+		return fmt.Errorf("list links: %w", err)
+	}
+	current := make(map[string]netlink.Link)
+	for _, link := range links {
+		if link.Type() != kind || link.Attrs().ParentIndex != parent.Attrs().Index {
+			continue
+		}
+		current[link.Attrs().Name] = link
+	}
+	for _, name := range sortedKeys(current) {
+		have := current[name]
+		if want, ok := desired[name]; ok && same(have, want) {
+			continue
+		}
+		if err := n.Provider.LinkDel(have); err != nil {
+			return fmt.Errorf("delete %s %s: %w", kind, name, err)
+		}
+		delete(current, name)
+	}
+	for _, name := range sortedKeys(desired) {
+		want := desired[name]
+		if _, ok := current[name]; ok {
+			continue
+		}
+		want.Attrs().ParentIndex = parent.Attrs().Index
+		if err := n.Provider.LinkAdd(want); err != nil {
+			return fmt.Errorf("create %s %s: %w", kind, name, err)
+		}
+		if _, err := n.createdUp(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseDesiredVLANs(raw []VLAN) (map[string]netlink.Link, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	desired := make(map[string]netlink.Link, len(raw))
+	for _, entry := range raw {
+		if err := validateInterfaceName(entry.Name); err != nil {
+			return nil, fmt.Errorf("vlan %d: %w", entry.ID, err)
+		}
+		if entry.ID < minVLANID || entry.ID > maxVLANID {
+			return nil, fmt.Errorf("vlan %s: id %d is outside %d-%d", entry.Name, entry.ID, minVLANID, maxVLANID)
+		}
+		if _, dup := desired[entry.Name]; dup {
+			return nil, fmt.Errorf("vlan %s is declared more than once", entry.Name)
+		}
+		for _, name := range sortedKeys(desired) {
+			if desired[name].(*netlink.Vlan).VlanId == entry.ID {
+				return nil, fmt.Errorf("vlan %s: id %d is already taken by vlan %s", entry.Name, entry.ID, name)
+			}
+		}
+		desired[entry.Name] = &netlink.Vlan{
+			LinkAttrs: netlink.LinkAttrs{Name: entry.Name},
+			VlanId:    entry.ID,
+		}
+	}
+	return desired, nil
+}
+
+func validateInterfaceName(name string) error {
+	if name == "" {
+		return errors.New("interface name is required")
+	}
+	if len(name) > maxInterfaceNameLen {
+		return fmt.Errorf("interface name %q exceeds %d characters", name, maxInterfaceNameLen)
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestNetlinkExecutorReconcilesVLANs(t *testing.T) {
+	parent := &fakeLink{netlink.LinkAttrs{Name: "eth0", Index: 2}}
+	provider := &mockNetlinkProvider{
+		link: parent,
+		links: []netlink.Link{
+			parent,
+			&netlink.Vlan{LinkAttrs: netlink.LinkAttrs{Name: "eth0.100", ParentIndex: 2}, VlanId: 100},
+			&netlink.Vlan{LinkAttrs: netlink.LinkAttrs{Name: "eth0.200", ParentIndex: 2}, VlanId: 200},
+			&netlink.Vlan{LinkAttrs: netlink.LinkAttrs{Name: "eth0.300", ParentIndex: 2}, VlanId: 301},
+			&netlink.Vlan{LinkAttrs: netlink.LinkAttrs{Name: "eth1.200", ParentIndex: 3}, VlanId: 200},
+		},
+	}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "eth0", VLANs: []VLAN{
+		{Name: "eth0.100", ID: 100},
+		{Name: "eth0.300", ID: 300},
+		{Name: "eth0.400", ID: 400},
+	}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.linkDeleted) != 2 || !contains(provider.linkDeleted, "eth0.200") ||
+		!contains(provider.linkDeleted, "eth0.300") {
+		t.Fatalf("unexpected deleted links: %v", provider.linkDeleted)
+	}
+	if len(provider.linkAdded) != 2 {
+		t.Fatalf("expected 2 created vlans, got %d", len(provider.linkAdded))
+	}
+	for _, link := range provider.linkAdded {
+		vlan, ok := link.(*netlink.Vlan)
+		if !ok {
+			t.Fatalf("expected vlan link, got %T", link)
+		}
+		if vlan.ParentIndex != 2 {
+			t.Fatalf("vlan %s created on parent %d", vlan.Name, vlan.ParentIndex)
+		}
+		if (vlan.Name == "eth0.300" && vlan.VlanId != 300) || (vlan.Name == "eth0.400" && vlan.VlanId != 400) {
+			t.Fatalf("unexpected vlan %s id %d", vlan.Name, vlan.VlanId)
+		}
+	}
+}
+
+func TestNetlinkExecutorLeavesVLANsWithoutSection(t *testing.T) {
+	provider := &mockNetlinkProvider{
+		links: []netlink.Link{&netlink.Vlan{LinkAttrs: netlink.LinkAttrs{Name: "eth0.100"}, VlanId: 100}},
+	}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "192.0.2.10/24"}}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.linkDeleted) != 0 {
+		t.Fatalf("expected vlans to be left alone, deleted %v", provider.linkDeleted)
+	}
+}
+
+func TestParseDesiredVLANsValidates(t *testing.T) {
+	cases := map[string][]VLAN{
+		"missing name": {{ID: 10}},
+		"id too low":   {{Name: "eth0.0", ID: 0}},
+		"id too high":  {{Name: "eth0.4095", ID: 4095}},
+		"long name":    {{Name: "averyveryverylongname", ID: 10}},
+		"duplicate":    {{Name: "eth0.10", ID: 10}, {Name: "eth0.10", ID: 11}},
+		"same id":      {{Name: "eth0.10", ID: 10}, {Name: "vlan10", ID: 10}},
+	}
+	for name, vlans := range cases {
+		if _, err := parseDesiredVLANs(vlans); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestNetlinkExecutorLeavesCreatedLinkDownWhenDeclared(t *testing.T) {
+	provider := &mockNetlinkProvider{byName: map[string]netlink.Link{}}
+	cfg := Configuration{Interface: "br0", Bridge: &Bridge{}, State: stateDown}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.linkAdded) != 1 || len(provider.states) != 0 {
+		t.Fatalf("created %v and set states %v, want br0 created and left down", provider.linkAdded, provider.states)
+	}
+}
+
+func TestNetlinkExecutorVLANCreateError(t *testing.T) {
+	provider := &mockNetlinkProvider{linkAddErr: errors.New("add-failed")}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "eth0", VLANs: []VLAN{{Name: "eth0.10", ID: 10}}}
+	if err := exec.Apply(cfg); err == nil {
+		t.Fatal("expected vlan create error")
+	}
+}
+
+func TestNetlinkExecutorCreatesBridgeAndReconcilesPorts(t *testing.T) {
+	eth1 := &fakeLink{netlink.LinkAttrs{Name: "eth1", Index: 3}}
+	eth2 := &fakeLink{netlink.LinkAttrs{Name: "eth2", Index: 4}}
+	provider := &mockNetlinkProvider{
+		byName: map[string]netlink.Link{"eth1": eth1, "eth2": eth2},
+	}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "br0", Bridge: &Bridge{Ports: []string{"eth1", "eth2"}}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.linkAdded) != 1 || provider.linkAdded[0].Type() != "bridge" {
+		t.Fatalf("expected bridge to be created, got %v", provider.linkAdded)
+	}
+	if len(provider.attached) != 2 || !contains(provider.attached, "eth1>br0") || !contains(provider.attached, "eth2>br0") {
+		t.Fatalf("unexpected attached ports: %v", provider.attached)
+	}
+}
+
+func TestNetlinkExecutorReleasesUndeclaredPorts(t *testing.T) {
+	bridge := &netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: "br0", Index: 10}}
+	eth1 := &fakeLink{netlink.LinkAttrs{Name: "eth1", Index: 3, MasterIndex: 10}}
+	eth2 := &fakeLink{netlink.LinkAttrs{Name: "eth2", Index: 4, MasterIndex: 10}}
+	provider := &mockNetlinkProvider{
+		byName: map[string]netlink.Link{"br0": bridge, "eth1": eth1, "eth2": eth2},
+		links:  []netlink.Link{bridge, eth1, eth2},
+	}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "br0", Bridge: &Bridge{Ports: []string{"eth1"}}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.linkAdded) != 0 {
+		t.Fatalf("expected existing bridge to be reused, created %v", provider.linkAdded)
+	}
+	if len(provider.attached) != 0 {
+		t.Fatalf("expected eth1 to stay attached without changes, got %v", provider.attached)
+	}
+	if len(provider.released) != 1 || provider.released[0] != "eth2" {
+		t.Fatalf("unexpected released ports: %v", provider.released)
+	}
+}
+
+func TestNetlinkExecutorRejectsKindMismatch(t *testing.T) {
+	provider := &mockNetlinkProvider{
+		byName: map[string]netlink.Link{"br0": &fakeLink{netlink.LinkAttrs{Name: "br0"}}},
+	}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "br0", Bridge: &Bridge{}}
+	if err := exec.Apply(cfg); err == nil {
+		t.Fatal("expected error when existing link is not a bridge")
+	}
+}
+
+func TestNetlinkExecutorMissingLinkWithoutKind(t *testing.T) {
+	provider := &mockNetlinkProvider{byName: map[string]netlink.Link{}}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "eth9", Addresses: []Address{{CIDR: "192.0.2.10/24"}}}
+	if err := exec.Apply(cfg); err == nil {
+		t.Fatal("expected lookup error for missing interface")
+	}
+	if len(provider.linkAdded) != 0 {
+		t.Fatalf("expected nothing to be created, got %v", provider.linkAdded)
+	}
+}
+
+func TestNetlinkExecutorCreatesBondAndEnrollsSlaves(t *testing.T) {
+	eth0 := &fakeLink{netlink.LinkAttrs{Name: "eth0", Index: 2}}
+	provider := &mockNetlinkProvider{byName: map[string]netlink.Link{"eth0": eth0}}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "bond0", Bond: &Bond{Mode: "802.3ad", Miimon: 100, Slaves: []string{"eth0"}}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.linkAdded) != 1 {
+		t.Fatalf("expected bond to be created, got %v", provider.linkAdded)
+	}
+	bond, ok := provider.linkAdded[0].(*netlink.Bond)
+	if !ok || bond.Mode != netlink.BOND_MODE_802_3AD || bond.Miimon != 100 {
+		t.Fatalf("unexpected bond: %#v", provider.linkAdded[0])
+	}
+	if len(provider.attached) != 1 || provider.attached[0] != "eth0>bond0" {
+		t.Fatalf("unexpected attached slaves: %v", provider.attached)
+	}
+	if want := []string{"bond0:up", "eth0:down", "eth0:up"}; !reflect.DeepEqual(provider.states, want) {
+		t.Fatalf("states = %v, want the new bond up and the slave cycled down/up around enslaving", provider.states)
+	}
+}
+
+func TestNetlinkExecutorRejectsBondModeMismatch(t *testing.T) {
+	bond := netlink.NewLinkBond(netlink.LinkAttrs{Name: "bond0", Index: 5})
+	bond.Mode = netlink.BOND_MODE_ACTIVE_BACKUP
+	provider := &mockNetlinkProvider{byName: map[string]netlink.Link{"bond0": bond}}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "bond0", Bond: &Bond{Mode: "802.3ad"}}
+	if err := exec.Apply(cfg); err == nil {
+		t.Fatal("expected error when the existing bond mode differs")
+	}
+}
+
+func TestNetlinkExecutorRejectsUnknownBondMode(t *testing.T) {
+	provider := &mockNetlinkProvider{byName: map[string]netlink.Link{}}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "bond0", Bond: &Bond{Mode: "fastest"}}
+	if err := exec.Apply(cfg); err == nil {
+		t.Fatal("expected error for unknown bond mode")
+	}
+	if len(provider.linkAdded) != 0 {
+		t.Fatalf("expected nothing to be created, got %v", provider.linkAdded)
+	}
+}
+
+func TestNetlinkExecutorCreatesVethPair(t *testing.T) {
+	provider := &mockNetlinkProvider{byName: map[string]netlink.Link{}}
+	cfg := Configuration{Interface: "vethA", Veth: &Veth{Peer: "vethB", PeerNetns: "ns1"}}
+	if err := NewNetlinkExecutor(provider).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.linkAdded) != 1 {
+		t.Fatalf("expected one link to be created, got %v", provider.linkAdded)
+	}
+	veth, ok := provider.linkAdded[0].(*netlink.Veth)
+	if !ok || veth.PeerName != "vethB" || veth.PeerNamespace != NamedNetns("ns1") {
+		t.Fatalf("unexpected link: %#v", provider.linkAdded[0])
+	}
+}
+
+func TestNetlinkExecutorValidatesVeth(t *testing.T) {
+	tests := map[string]Veth{
+		"missing peer": {},
+		"same name":    {Peer: "vethA"},
+		"long peer":    {Peer: "a-very-long-peer-name"},
+		"bad netns":    {Peer: "vethB", PeerNetns: "../ns"},
+	}
+	for name, veth := range tests {
+		veth := veth
+		provider := &mockNetlinkProvider{byName: map[string]netlink.Link{}}
+		if err := NewNetlinkExecutor(provider).Apply(Configuration{Interface: "vethA", Veth: &veth}); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+		if len(provider.linkAdded) != 0 {
+			t.Fatalf("%s: nothing must be created when validation fails", name)
+		}
+	}
+}
+
+func TestNetlinkExecutorCreatesDummy(t *testing.T) {
+	provider := &mockNetlinkProvider{byName: map[string]netlink.Link{}}
+	cfg := Configuration{Interface: "anycast0", Addresses: []Address{{CIDR: "203.0.113.53/32"}}, Dummy: &Dummy{}}
+	if err := NewNetlinkExecutor(provider).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.linkAdded) != 1 || provider.linkAdded[0].Type() != "dummy" {
+		t.Fatalf("expected dummy link to be created, got %v", provider.linkAdded)
+	}
+	if !contains(provider.added, "203.0.113.53/32") {
+		t.Fatalf("expected service address on the dummy, got %v", provider.added)
+	}
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// macPermanent restores the address the device was made with.
+const macPermanent = "permanent"
+
+// Bits of the first octet of a MAC address (IEEE 802).
+const (
+	macMulticast = 0x01
+	macLocal     = 0x02
+)
+
+// parseMAC validates the mac setting. It returns nil when the setting is
+// absent or asks for the permanent address.
+func parseMAC(cfg Configuration) (net.HardwareAddr, error) {
+	if cfg.MAC == "" || cfg.MAC == macPermanent {
+		return nil, nil
+	}
+	hw, err := net.ParseMAC(cfg.MAC)
+	if err != nil || len(hw) != 6 {
+		return nil, fmt.Errorf("mac for %s: %q is not an Ethernet address", cfg.Interface, cfg.MAC)
+	}
+	if hw[0]&macMulticast != 0 {
+		return nil, fmt.Errorf("mac for %s: %s is a multicast address", cfg.Interface, hw)
+	}
+	if hw[0]&macLocal == 0 {
+		return nil, fmt.Errorf("mac for %s: %s is not locally administered; set bit 0x02 of the first octet, as in 02:00:00:00:00:01", cfg.Interface, hw)
+	}
+	return hw, nil
+}
+
+// reconcileMAC sets the hardware address of link. Drivers that cannot change
+// it while running refuse with EBUSY; the link is then brought down for the
+// change and back up after.
+func (n NetlinkExecutor) reconcileMAC(cfg Configuration, link netlink.Link, want net.HardwareAddr) error {
+	if cfg.MAC == macPermanent {
+		want = link.Attrs().PermHWAddr
+		if len(want) == 0 {
+			return fmt.Errorf("%s reports no permanent mac address", cfg.Interface)
+		}
+	}
+	if want == nil || link.Attrs().HardwareAddr.String() == want.String() {
+		return nil
+	}
+	err := n.Provider.LinkSetHardwareAddr(link, want)
+	if errors.Is(err, unix.EBUSY) && link.Attrs().Flags&net.FlagUp != 0 {
+		if err := n.Provider.LinkSetDown(link); err != nil {
+			return fmt.Errorf("set %s down: %w", cfg.Interface, err)
+		}
+		err = n.Provider.LinkSetHardwareAddr(link, want)
+		if upErr := n.Provider.LinkSetUp(link); err == nil && upErr != nil {
+			return fmt.Errorf("set %s up: %w", cfg.Interface, upErr)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("set %s mac %s: %w", cfg.Interface, want, err)
+	}
+	link.Attrs().HardwareAddr = want
+	return nil
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func macLink(current, permanent string, flags net.Flags) *fakeLink {
+	hw, _ := net.ParseMAC(current)
+	perm, _ := net.ParseMAC(permanent)
+	return &fakeLink{netlink.LinkAttrs{Name: "eth0", HardwareAddr: hw, PermHWAddr: perm, Flags: flags}}
+}
+
+func TestNetlinkExecutorSetsMAC(t *testing.T) {
+	provider := &mockNetlinkProvider{link: macLink("52:54:00:12:34:56", "", 0)}
+	cfg := Configuration{Interface: "eth0", MAC: "02:00:00:AA:BB:CC"}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got := strings.Join(provider.macs, ","); got != "eth0:02:00:00:aa:bb:cc" {
+		t.Fatalf("unexpected mac changes %q", got)
+	}
+
+	provider.macs = nil
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("second Apply() error = %v", err)
+	}
+	if len(provider.macs) != 0 {
+		t.Fatalf("expected no change when the mac is already set, got %v", provider.macs)
+	}
+}
+
+func TestNetlinkExecutorRestoresPermanentMAC(t *testing.T) {
+	provider := &mockNetlinkProvider{link: macLink("02:00:00:aa:bb:cc", "52:54:00:12:34:56", 0)}
+	cfg := Configuration{Interface: "eth0", MAC: macPermanent}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got := strings.Join(provider.macs, ","); got != "eth0:52:54:00:12:34:56" {
+		t.Fatalf("unexpected mac changes %q", got)
+	}
+
+	provider = &mockNetlinkProvider{link: macLink("02:00:00:aa:bb:cc", "", 0)}
+	err := (NetlinkExecutor{Provider: provider}).Apply(cfg)
+	if err == nil || !strings.Contains(err.Error(), "no permanent mac address") {
+		t.Fatalf("expected a missing permanent address error, got %v", err)
+	}
+}
+
+func TestNetlinkExecutorSetsMACWhileDown(t *testing.T) {
+	provider := &mockNetlinkProvider{link: macLink("52:54:00:12:34:56", "", net.FlagUp), macErr: unix.EBUSY}
+	cfg := Configuration{Interface: "eth0", MAC: "02:00:00:aa:bb:cc"}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got := strings.Join(provider.states, ","); got != "eth0:down,eth0:up" {
+		t.Fatalf("expected the link to be cycled, got %q", got)
+	}
+	if got := strings.Join(provider.macs, ","); got != "eth0:02:00:00:aa:bb:cc" {
+		t.Fatalf("unexpected mac changes %q", got)
+	}
+}
+
+func TestNetlinkExecutorRejectsInvalidMAC(t *testing.T) {
+	for _, mac := range []string{"not-a-mac", "02:00:00:00:00:00:00:01", "03:00:00:00:00:01", "00:16:3e:12:34:56"} {
+		provider := &mockNetlinkProvider{link: macLink("52:54:00:12:34:56", "", 0)}
+		if err := (NetlinkExecutor{Provider: provider}).Apply(Configuration{Interface: "eth0", MAC: mac}); err == nil {
+			t.Fatalf("%s: expected error", mac)
+		}
+		if len(provider.macs) != 0 {
+			t.Fatalf("%s: expected no changes, got %v", mac, provider.macs)
+		}
+	}
+}
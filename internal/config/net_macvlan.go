@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/vishvananda/netlink"
+)
+
+// defaultMACVLANMode lets sub-interfaces on the same parent reach each other,
+// which is what container attachments usually expect.
+const defaultMACVLANMode = "bridge"
+
+var macvlanModes = map[string]netlink.MacvlanMode{
+	"bridge":  netlink.MACVLAN_MODE_BRIDGE,
+	"vepa":    netlink.MACVLAN_MODE_VEPA,
+	"private": netlink.MACVLAN_MODE_PRIVATE,
+}
+
+// reconcileMACVLANs creates declared macvlan sub-interfaces on parent and
+// deletes undeclared ones. A nil desired map leaves them untouched.
+func (n NetlinkExecutor) reconcileMACVLANs(parent netlink.Link, desired map[string]netlink.Link) error {
+	return n.reconcileChildren(parent, "macvlan", desired, func(have, want netlink.Link) bool {
+		macvlan, ok := have.(*netlink.Macvlan)
+		return ok && macvlan.Mode == want.(*netlink.Macvlan).Mode
+	})
+}
+
+func parseDesiredMACVLANs(raw []MACVLAN) (map[string]netlink.Link, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	desired := make(map[string]netlink.Link, len(raw))
+	for _, entry := range raw {
+		if err := validateInterfaceName(entry.Name); err != nil {
+			return nil, fmt.Errorf("macvlan: %w", err)
+		}
+		mode, err := macvlanMode(entry.Mode)
+		if err != nil {
+			return nil, fmt.Errorf("macvlan %s: %w", entry.Name, err)
+		}
+		if _, dup := desired[entry.Name]; dup {
+			return nil, fmt.Errorf("macvlan %s is declared more than once", entry.Name)
+		}
+		desired[entry.Name] = &netlink.Macvlan{
+			LinkAttrs: netlink.LinkAttrs{Name: entry.Name},
+			Mode:      mode,
+		}
+	}
+	return desired, nil
+}
+
+func macvlanMode(name string) (netlink.MacvlanMode, error) {
+	if name == "" {
+		name = defaultMACVLANMode
+	}
+	mode, ok := macvlanModes[name]
+	if !ok {
+		return 0, fmt.Errorf("unsupported mode %q (want bridge, vepa or private)", name)
+	}
+	return mode, nil
+}
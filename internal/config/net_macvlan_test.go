@@ -0,0 +1,64 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestNetlinkExecutorReconcilesMACVLANs(t *testing.T) {
+	parent := &fakeLink{netlink.LinkAttrs{Name: "eth0", Index: 2}}
+	provider := &mockNetlinkProvider{
+		link: parent,
+		links: []netlink.Link{
+			parent,
+			&netlink.Macvlan{LinkAttrs: netlink.LinkAttrs{Name: "mv0", ParentIndex: 2}, Mode: netlink.MACVLAN_MODE_BRIDGE},
+			&netlink.Macvlan{LinkAttrs: netlink.LinkAttrs{Name: "mv1", ParentIndex: 2}, Mode: netlink.MACVLAN_MODE_VEPA},
+			&netlink.Macvlan{LinkAttrs: netlink.LinkAttrs{Name: "mv9", ParentIndex: 2}, Mode: netlink.MACVLAN_MODE_BRIDGE},
+			&netlink.Macvlan{LinkAttrs: netlink.LinkAttrs{Name: "mv5", ParentIndex: 3}, Mode: netlink.MACVLAN_MODE_BRIDGE},
+			&netlink.Vlan{LinkAttrs: netlink.LinkAttrs{Name: "eth0.100", ParentIndex: 2}, VlanId: 100},
+		},
+	}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "eth0", MACVLANs: []MACVLAN{
+		{Name: "mv0"},
+		{Name: "mv1", Mode: "private"},
+		{Name: "mv2", Mode: "vepa"},
+	}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.linkDeleted) != 2 || !contains(provider.linkDeleted, "mv1") || !contains(provider.linkDeleted, "mv9") {
+		t.Fatalf("unexpected deleted links: %v", provider.linkDeleted)
+	}
+	if len(provider.linkAdded) != 2 {
+		t.Fatalf("expected 2 created macvlans, got %d", len(provider.linkAdded))
+	}
+	want := map[string]netlink.MacvlanMode{"mv1": netlink.MACVLAN_MODE_PRIVATE, "mv2": netlink.MACVLAN_MODE_VEPA}
+	for _, link := range provider.linkAdded {
+		macvlan, ok := link.(*netlink.Macvlan)
+		if !ok {
+			t.Fatalf("expected macvlan link, got %T", link)
+		}
+		if macvlan.ParentIndex != 2 || macvlan.Mode != want[macvlan.Name] {
+			t.Fatalf("unexpected macvlan %s on parent %d mode %d", macvlan.Name, macvlan.ParentIndex, macvlan.Mode)
+		}
+	}
+}
+
+func TestParseDesiredMACVLANsValidates(t *testing.T) {
+	cases := map[string][]MACVLAN{
+		"missing name": {{Mode: "bridge"}},
+		"bad mode":     {{Name: "mv0", Mode: "passthru"}},
+		"duplicate":    {{Name: "mv0"}, {Name: "mv0", Mode: "vepa"}},
+	}
+	for name, macvlans := range cases {
+		if _, err := parseDesiredMACVLANs(macvlans); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+	desired, err := parseDesiredMACVLANs([]MACVLAN{{Name: "mv0"}})
+	if err != nil || desired["mv0"].(*netlink.Macvlan).Mode != netlink.MACVLAN_MODE_BRIDGE {
+		t.Fatalf("expected bridge mode by default, got %v, %v", desired, err)
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/user/goeth/internal/pmtu"
+)
+
+// maxMTU is the largest MTU expressible in an IPv4 total-length field.
+const maxMTU = 65535
+
+// PathMTUProber is implemented by providers that can measure the path MTU
+// towards a host through a specific device. It is optional; configurations
+// with an mtu_probe section require it.
+type PathMTUProber interface {
+	ProbePathMTU(device string, target net.IP, max int, timeout time.Duration) (int, error)
+}
+
+// validateMTU checks the mtu settings before anything is changed and returns
+// the parsed probe target, if any.
+func (n NetlinkExecutor) validateMTU(cfg Configuration) (net.IP, error) {
+	if cfg.MTU != 0 && (cfg.MTU < pmtu.MinMTU || cfg.MTU > maxMTU) {
+		return nil, fmt.Errorf("mtu %d for %s is outside %d-%d", cfg.MTU, cfg.Interface, pmtu.MinMTU, maxMTU)
+	}
+	if cfg.MTUProbe == nil {
+		return nil, nil
+	}
+	if _, ok := n.Provider.(PathMTUProber); !ok {
+		return nil, errors.New("netlink provider cannot probe path mtu")
+	}
+	target := net.ParseIP(cfg.MTUProbe.Target)
+	if target == nil || target.To4() == nil {
+		return nil, fmt.Errorf("mtu_probe for %s: target must be an IPv4 address, got %q", cfg.Interface, cfg.MTUProbe.Target)
+	}
+	return target, nil
+}
+
+// reconcileMTU sets the configured MTU and, when probing is enabled, lowers it
+// to the measured path MTU. The configured MTU (or the current one when none
+// is configured) is the ceiling of the probe, so the interface briefly runs
+// at the ceiling while the path is measured. The kernel derives from the MTU
+// only the MSS of the connections it originates, so a lowered MTU is
+// reported with the firewall rule that clamps forwarded connections too.
+func (n NetlinkExecutor) reconcileMTU(cfg Configuration, link netlink.Link, target net.IP) error {
+	want := cfg.MTU
+	if want == 0 {
+		want = link.Attrs().MTU
+	}
+	if err := n.setMTU(link, want); err != nil {
+		return err
+	}
+	if target == nil {
+		return nil
+	}
+	probed, err := n.Provider.(PathMTUProber).ProbePathMTU(cfg.Interface, target, want, time.Duration(cfg.MTUProbe.Timeout))
+	if err != nil {
+		return fmt.Errorf("probe path mtu from %s to %s: %w", cfg.Interface, target, err)
+	}
+	if probed >= want {
+		return nil
+	}
+	if err := n.setMTU(link, probed); err != nil {
+		return err
+	}
+	n.report("%s mtu clamped to %d for local connections; clamp the MSS of forwarded ones with a firewall rule such as "+
+		"'nft add rule inet filter forward oifname %s tcp flags syn tcp option maxseg size set rt mtu'", cfg.Interface, probed, cfg.Interface)
+	return nil
+}
+
+func (n NetlinkExecutor) setMTU(link netlink.Link, mtu int) error {
+	if mtu == 0 || link.Attrs().MTU == mtu {
+		return nil
+	}
+	if err := n.Provider.LinkSetMTU(link, mtu); err != nil {
+		return fmt.Errorf("set %s mtu %d: %w", link.Attrs().Name, mtu, err)
+	}
+	link.Attrs().MTU = mtu
+	return nil
+}
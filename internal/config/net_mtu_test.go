@@ -0,0 +1,134 @@
+package config
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+type probeMockProvider struct {
+	*mockNetlinkProvider
+	pathMTU  int
+	probeErr error
+	ceilings []int
+}
+
+func (p *probeMockProvider) ProbePathMTU(device string, target net.IP, max int, timeout time.Duration) (int, error) {
+	p.ceilings = append(p.ceilings, max)
+	if p.probeErr != nil {
+		return 0, p.probeErr
+	}
+	if p.pathMTU < max {
+		return p.pathMTU, nil
+	}
+	return max, nil
+}
+
+func mtuLink(mtu int) *mockNetlinkProvider {
+	return &mockNetlinkProvider{link: &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "ppp0", Index: 3, MTU: mtu}}}
+}
+
+func TestNetlinkExecutorSetsMTU(t *testing.T) {
+	provider := mtuLink(1500)
+	if err := NewNetlinkExecutor(provider).Apply(Configuration{Interface: "ppp0", MTU: 1492}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if !reflect.DeepEqual(provider.mtus, []string{"ppp0:1492"}) {
+		t.Fatalf("unexpected mtu changes: %v", provider.mtus)
+	}
+}
+
+func TestNetlinkExecutorLeavesMatchingMTU(t *testing.T) {
+	provider := mtuLink(1492)
+	if err := NewNetlinkExecutor(provider).Apply(Configuration{Interface: "ppp0", MTU: 1492}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.mtus) != 0 {
+		t.Fatalf("expected no mtu changes, got %v", provider.mtus)
+	}
+}
+
+func TestNetlinkExecutorClampsToProbedMTU(t *testing.T) {
+	provider := &probeMockProvider{mockNetlinkProvider: mtuLink(1500), pathMTU: 1472}
+	cfg := Configuration{Interface: "ppp0", MTUProbe: &MTUProbe{Target: "198.51.100.1"}}
+	if err := NewNetlinkExecutor(provider).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if !reflect.DeepEqual(provider.ceilings, []int{1500}) {
+		t.Fatalf("expected probe from the current mtu, got %v", provider.ceilings)
+	}
+	if !reflect.DeepEqual(provider.mtus, []string{"ppp0:1472"}) {
+		t.Fatalf("unexpected mtu changes: %v", provider.mtus)
+	}
+}
+
+func TestNetlinkExecutorLeavesForwardedMSSToTheFirewall(t *testing.T) {
+	tests := []struct {
+		name    string
+		pathMTU int
+		want    []string
+	}{
+		{"clamped", 1472, []string{"ppp0 mtu clamped to 1472 for local connections; clamp the MSS of forwarded ones with a firewall rule such as " +
+			"'nft add rule inet filter forward oifname ppp0 tcp flags syn tcp option maxseg size set rt mtu'"}},
+		{"not clamped", 9000, nil},
+	}
+	for _, tt := range tests {
+		provider := &probeMockProvider{mockNetlinkProvider: mtuLink(1500), pathMTU: tt.pathMTU}
+		var notes noteCollector
+		cfg := Configuration{Interface: "ppp0", MTUProbe: &MTUProbe{Target: "198.51.100.1"}}
+		if err := (NetlinkExecutor{Provider: provider, Reporter: &notes}).Apply(cfg); err != nil {
+			t.Fatalf("%s: Apply() error = %v", tt.name, err)
+		}
+		if !reflect.DeepEqual([]string(notes), tt.want) {
+			t.Errorf("%s: notes = %q, want %q", tt.name, notes, tt.want)
+		}
+	}
+}
+
+func TestNetlinkExecutorProbesFromConfiguredCeiling(t *testing.T) {
+	provider := &probeMockProvider{mockNetlinkProvider: mtuLink(1400), pathMTU: 9000}
+	cfg := Configuration{Interface: "ppp0", MTU: 1500, MTUProbe: &MTUProbe{Target: "198.51.100.1"}}
+	if err := NewNetlinkExecutor(provider).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if !reflect.DeepEqual(provider.ceilings, []int{1500}) || !reflect.DeepEqual(provider.mtus, []string{"ppp0:1500"}) {
+		t.Fatalf("unexpected probe %v or mtu changes %v", provider.ceilings, provider.mtus)
+	}
+}
+
+func TestNetlinkExecutorPropagatesProbeError(t *testing.T) {
+	provider := &probeMockProvider{mockNetlinkProvider: mtuLink(1500), probeErr: errors.New("no reply")}
+	cfg := Configuration{Interface: "ppp0", MTUProbe: &MTUProbe{Target: "198.51.100.1"}}
+	if err := NewNetlinkExecutor(provider).Apply(cfg); err == nil {
+		t.Fatal("expected probe error")
+	}
+}
+
+func TestNetlinkExecutorValidatesMTU(t *testing.T) {
+	tests := map[string]Configuration{
+		"too small":      {Interface: "ppp0", MTU: 100},
+		"too large":      {Interface: "ppp0", MTU: 70000},
+		"ipv6 target":    {Interface: "ppp0", MTUProbe: &MTUProbe{Target: "2001:db8::1"}},
+		"missing target": {Interface: "ppp0", MTUProbe: &MTUProbe{}},
+	}
+	for name, cfg := range tests {
+		provider := &probeMockProvider{mockNetlinkProvider: mtuLink(1500)}
+		if err := NewNetlinkExecutor(provider).Apply(cfg); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+		if len(provider.mtus) != 0 || len(provider.ceilings) != 0 {
+			t.Fatalf("%s: nothing must be changed when validation fails", name)
+		}
+	}
+}
+
+func TestNetlinkExecutorProbeRequiresProber(t *testing.T) {
+	cfg := Configuration{Interface: "ppp0", MTUProbe: &MTUProbe{Target: "198.51.100.1"}}
+	if err := NewNetlinkExecutor(mtuLink(1500)).Apply(cfg); err == nil {
+		t.Fatal("expected error for provider without probing support")
+	}
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/vishvananda/netlink"
+)
+
+// sourceRouting generates the routes and rules of s for the addresses in
+// desired: a default route through the gateway of each family and the
+// prefix of each address in s.Table, and a rule sending traffic from each
+// address to it. Link-local addresses and those of a family without a
+// gateway get neither.
+func sourceRouting(s *SourceRouting, desired map[string]*netlink.Addr) ([]Route, []Rule, error) {
+	if s == nil {
+		return nil, nil, nil
+	}
+	if err := validateTable(s.Table); err != nil {
+		return nil, nil, fmt.Errorf("source_routing: %w", err)
+	}
+	if len(s.Gateways) == 0 {
+		return nil, nil, fmt.Errorf("source_routing: at least one gateway is required")
+	}
+	gateways := make(map[int]bool)
+	var routes []Route
+	for _, raw := range s.Gateways {
+		gw := net.ParseIP(raw)
+		if gw == nil {
+			return nil, nil, fmt.Errorf("source_routing: invalid gateway %q", raw)
+		}
+		if gateways[ipFamily(gw)] {
+			return nil, nil, fmt.Errorf("source_routing: gateway %s is the second of its address family", raw)
+		}
+		gateways[ipFamily(gw)] = true
+		routes = append(routes, Route{Destination: defaultDestination, Gateway: raw, Table: s.Table})
+	}
+	priority := s.Priority
+	if priority == 0 {
+		priority = s.Table
+	}
+	var rules []Rule
+	prefixes := make(map[string]bool)
+	for _, key := range sortedKeys(desired) {
+		addr := desired[key]
+		if !gateways[addrFamily(addr)] || addr.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		prefix := &net.IPNet{IP: addr.IP.Mask(addr.Mask), Mask: addr.Mask}
+		if ones, bits := prefix.Mask.Size(); ones < bits && !prefixes[prefix.String()] {
+			prefixes[prefix.String()] = true
+			routes = append(routes, Route{Destination: prefix.String(), Table: s.Table})
+		}
+		rules = append(rules, Rule{Priority: priority, From: addr.IP.String(), Table: s.Table})
+		priority++
+	}
+	return routes, rules, nil
+}
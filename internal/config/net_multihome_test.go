@@ -0,0 +1,53 @@
+package config
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestNetlinkExecutorGeneratesSourceRouting(t *testing.T) {
+	_, main, _ := net.ParseCIDR("203.0.113.0/24")
+	provider := &ruleProvider{mockNetlinkProvider: &mockNetlinkProvider{routes: map[int][]netlink.Route{
+		netlink.FAMILY_V4: {{Family: netlink.FAMILY_V4, Dst: main, Protocol: RouteProtocol}},
+	}}}
+	cfg := Configuration{
+		Interface:     "eth1",
+		Addresses:     []Address{{CIDR: "198.51.100.11/24"}, {CIDR: "198.51.100.10/24"}, {CIDR: "2001:db8::10/64"}},
+		SourceRouting: &SourceRouting{Table: 101, Gateways: []string{"198.51.100.1"}},
+	}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"0.0.0.0/0 table 101", "198.51.100.0/24 table 101"}; !reflect.DeepEqual(provider.routeAdded, want) {
+		t.Fatalf("added routes %v, want %v", provider.routeAdded, want)
+	}
+	if len(provider.routeRemoved) != 0 {
+		t.Fatalf("removed routes %v, want the main table left alone without routes", provider.routeRemoved)
+	}
+	want := []string{"101: from 198.51.100.10 lookup 101", "102: from 198.51.100.11 lookup 101"}
+	if !reflect.DeepEqual(provider.ruleAdded, want) {
+		t.Fatalf("added rules %v, want %v", provider.ruleAdded, want)
+	}
+}
+
+func TestSourceRoutingRejects(t *testing.T) {
+	tests := []struct {
+		source SourceRouting
+		want   string
+	}{
+		{SourceRouting{Gateways: []string{"192.0.2.1"}}, "table 0 is not between"},
+		{SourceRouting{Table: 101}, "at least one gateway"},
+		{SourceRouting{Table: 101, Gateways: []string{"gateway"}}, `invalid gateway "gateway"`},
+		{SourceRouting{Table: 101, Gateways: []string{"192.0.2.1", "192.0.2.2"}}, "the second of its address family"},
+	}
+	for _, tt := range tests {
+		_, _, err := sourceRouting(&tt.source, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("sourceRouting(%+v) error = %v, want %q", tt.source, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+// reconcileNeighbors makes the permanent neighbor entries on link match
+// desired. Dynamic entries learned by the kernel are never removed, but one
+// for a desired IP is replaced by the permanent entry, as adding it would
+// fail with EEXIST. A nil desired map leaves the neighbor table alone
+// entirely.
+func (n NetlinkExecutor) reconcileNeighbors(link netlink.Link, desired map[string]*netlink.Neigh) error {
+	if desired == nil {
+		return nil
+	}
+	current := make(map[string]netlink.Neigh)
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		list, err := n.Provider.NeighList(link.Attrs().Index, family)
+		if err != nil {
+			return fmt.Errorf("list neighbors for family %d: %w", family, err)
+		}
+		for _, neigh := range list {
+			if neigh.State&netlink.NUD_PERMANENT == 0 {
+				continue
+			}
+			current[neigh.IP.String()] = neigh
+		}
+	}
+	for _, key := range sortedKeys(desired) {
+		want := desired[key]
+		want.LinkIndex = link.Attrs().Index
+		if have, ok := current[key]; ok && strings.EqualFold(have.HardwareAddr.String(), want.HardwareAddr.String()) {
+			continue
+		}
+		if err := n.Provider.NeighSet(want); err != nil {
+			return fmt.Errorf("set neighbor %s: %w", key, err)
+		}
+	}
+	for _, key := range sortedKeys(current) {
+		have := current[key]
+		if _, ok := desired[key]; ok {
+			continue
+		}
+		if err := n.Provider.NeighDel(&have); err != nil {
+			return fmt.Errorf("remove neighbor %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func parseDesiredNeighbors(raw []Neighbor) (map[string]*netlink.Neigh, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	desired := make(map[string]*netlink.Neigh, len(raw))
+	for _, entry := range raw {
+		ip := net.ParseIP(entry.IP)
+		if ip == nil {
+			return nil, fmt.Errorf("parse neighbor ip %q: invalid address", entry.IP)
+		}
+		mac, err := net.ParseMAC(entry.MAC)
+		if err != nil {
+			return nil, fmt.Errorf("parse neighbor mac %q: %w", entry.MAC, err)
+		}
+		family := netlink.FAMILY_V6
+		if ip.To4() != nil {
+			family = netlink.FAMILY_V4
+		}
+		desired[ip.String()] = &netlink.Neigh{
+			Family:       family,
+			State:        netlink.NUD_PERMANENT,
+			IP:           ip,
+			HardwareAddr: mac,
+		}
+	}
+	return desired, nil
+}
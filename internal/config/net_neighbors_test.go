@@ -0,0 +1,133 @@
+package config
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestNetlinkExecutorReconcilesNeighbors(t *testing.T) {
+	provider := &mockNetlinkProvider{
+		neighs: map[int][]netlink.Neigh{
+			netlink.FAMILY_V4: {
+				mustNeigh(t, "192.0.2.1", "02:00:00:00:00:01", netlink.NUD_PERMANENT),
+				mustNeigh(t, "192.0.2.2", "02:00:00:00:00:02", netlink.NUD_PERMANENT),
+				mustNeigh(t, "192.0.2.3", "02:00:00:00:00:03", netlink.NUD_PERMANENT),
+				mustNeigh(t, "192.0.2.9", "02:00:00:00:00:09", netlink.NUD_REACHABLE),
+			},
+		},
+	}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "eth0", Neighbors: []Neighbor{
+		{IP: "192.0.2.1", MAC: "02:00:00:00:00:01"},
+		{IP: "192.0.2.2", MAC: "02:00:00:00:00:22"},
+		{IP: "2001:db8::1", MAC: "02:00:00:00:00:61"},
+	}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.neighSet) != 2 || !contains(provider.neighSet, "192.0.2.2 02:00:00:00:00:22") ||
+		!contains(provider.neighSet, "2001:db8::1 02:00:00:00:00:61") {
+		t.Fatalf("unexpected added neighbors: %v", provider.neighSet)
+	}
+	if len(provider.neighRemoved) != 1 || !contains(provider.neighRemoved, "192.0.2.3 02:00:00:00:00:03") {
+		t.Fatalf("unexpected removed neighbors: %v", provider.neighRemoved)
+	}
+}
+
+func TestNetlinkExecutorReplacesDynamicNeighbors(t *testing.T) {
+	provider := &mockNetlinkProvider{
+		neighs: map[int][]netlink.Neigh{
+			netlink.FAMILY_V4: {
+				mustNeigh(t, "192.0.2.1", "02:00:5e:00:53:01", netlink.NUD_REACHABLE),
+				mustNeigh(t, "192.0.2.2", "02:00:5e:00:53:02", netlink.NUD_STALE),
+			},
+		},
+	}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "eth0", Neighbors: []Neighbor{
+		{IP: "192.0.2.1", MAC: "02:00:5e:00:53:01"},
+		{IP: "192.0.2.2", MAC: "02:00:5e:00:53:22"},
+	}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{"192.0.2.1 02:00:5e:00:53:01", "192.0.2.2 02:00:5e:00:53:22"}
+	if !reflect.DeepEqual(provider.neighSet, want) || len(provider.neighRemoved) != 0 {
+		t.Fatalf("set %v, removed %v; want the learned entries made permanent in place", provider.neighSet, provider.neighRemoved)
+	}
+}
+
+func TestApplierClearsNeighborsWithAnEmptyList(t *testing.T) {
+	loader := NewLoaderWithReader(func(string) ([]byte, error) {
+		return []byte(`{"interface": "eth0", "neighbors": []}`), nil
+	})
+	cfg, err := loader.Load("neighbors.json")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	provider := &mockNetlinkProvider{
+		neighs: map[int][]netlink.Neigh{
+			netlink.FAMILY_V4: {mustNeigh(t, "192.0.2.1", "02:00:5e:00:53:01", netlink.NUD_PERMANENT)},
+		},
+	}
+	if err := NewApplier(NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"192.0.2.1 02:00:5e:00:53:01"}; !reflect.DeepEqual(provider.neighRemoved, want) {
+		t.Fatalf("removed %v, want %v", provider.neighRemoved, want)
+	}
+}
+
+func TestNetlinkExecutorLeavesNeighborsWithoutSection(t *testing.T) {
+	provider := &mockNetlinkProvider{
+		neighs: map[int][]netlink.Neigh{
+			netlink.FAMILY_V4: {mustNeigh(t, "192.0.2.1", "02:00:00:00:00:01", netlink.NUD_PERMANENT)},
+		},
+	}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "192.0.2.10/24"}}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.neighRemoved) != 0 {
+		t.Fatalf("expected neighbors to be left alone, removed %v", provider.neighRemoved)
+	}
+}
+
+func TestNetlinkExecutorValidatesNeighborsBeforeChanges(t *testing.T) {
+	provider := &mockNetlinkProvider{}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{
+		Interface: "eth0",
+		Addresses: []Address{{CIDR: "192.0.2.10/24"}},
+		Neighbors: []Neighbor{{IP: "192.0.2.1", MAC: "not-a-mac"}},
+	}
+	if err := exec.Apply(cfg); err == nil {
+		t.Fatal("expected parse error")
+	}
+	if len(provider.added) != 0 {
+		t.Fatalf("expected no changes before validation, added %v", provider.added)
+	}
+}
+
+func TestNetlinkExecutorNeighborAddError(t *testing.T) {
+	provider := &mockNetlinkProvider{neighSetErr: errors.New("add-failed")}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "eth0", Neighbors: []Neighbor{{IP: "192.0.2.1", MAC: "02:00:00:00:00:01"}}}
+	if err := exec.Apply(cfg); err == nil {
+		t.Fatal("expected neighbor add error")
+	}
+}
+
+func mustNeigh(t *testing.T, ip, mac string, state int) netlink.Neigh {
+	t.Helper()
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		t.Fatalf("ParseMAC(%s) error = %v", mac, err)
+	}
+	return netlink.Neigh{IP: net.ParseIP(ip), HardwareAddr: hw, State: state}
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/user/goeth/internal/ethtool"
+)
+
+// OffloadProvider is implemented by providers that can read and toggle the
+// offload features of a device. It is optional; configurations with
+// offloads require it. Offloads returns nil features when the provider
+// cannot read them; every declared offload is then set.
+type OffloadProvider interface {
+	Offloads(name string) (ethtool.Features, error)
+	SetOffloads(name string, offloads map[string]bool) error
+}
+
+// validateOffloads checks that every offload is an ethtool(8) short name,
+// such as gro, or looks like a kernel feature name, such as rx-gro. Whether
+// the device has it is only known once it is read.
+func (n NetlinkExecutor) validateOffloads(cfg Configuration) error {
+	if len(cfg.Offloads) == 0 {
+		return nil
+	}
+	if _, ok := n.Provider.(OffloadProvider); !ok {
+		return errors.New("netlink provider cannot set offloads")
+	}
+	for _, name := range sortedKeys(cfg.Offloads) {
+		if slices.Contains(ethtool.Aliases(), name) || featureName(name) {
+			continue
+		}
+		return fmt.Errorf("offload %s for %s: want one of %s or a feature name as listed by ethtool -k, such as rx-gro",
+			name, cfg.Interface, strings.Join(ethtool.Aliases(), ", "))
+	}
+	return nil
+}
+
+func featureName(name string) bool {
+	return strings.Contains(name, "-") && strings.Trim(name, "abcdefghijklmnopqrstuvwxyz0123456789-") == ""
+}
+
+// reconcileOffloads sets the offloads whose state differs.
+func (n NetlinkExecutor) reconcileOffloads(cfg Configuration) error {
+	if len(cfg.Offloads) == 0 {
+		return nil
+	}
+	provider := n.Provider.(OffloadProvider)
+	features, err := provider.Offloads(cfg.Interface)
+	if err != nil {
+		return fmt.Errorf("read offloads of %s: %w", cfg.Interface, err)
+	}
+	changes := make(map[string]bool)
+	for _, name := range sortedKeys(cfg.Offloads) {
+		on := cfg.Offloads[name]
+		if features == nil {
+			changes[name] = on
+			continue
+		}
+		differs, err := features.Differs(name, on)
+		if err != nil {
+			return fmt.Errorf("offload %s for %s: %w", name, cfg.Interface, err)
+		}
+		if differs {
+			changes[name] = on
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	if err := provider.SetOffloads(cfg.Interface, changes); err != nil {
+		return fmt.Errorf("set offloads of %s: %w", cfg.Interface, err)
+	}
+	return nil
+}
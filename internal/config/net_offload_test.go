@@ -0,0 +1,72 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/user/goeth/internal/ethtool"
+)
+
+// offloadProvider keeps the features of the interface in memory.
+type offloadProvider struct {
+	*mockNetlinkProvider
+	features ethtool.Features
+	set      []map[string]bool
+}
+
+func (o *offloadProvider) Offloads(name string) (ethtool.Features, error) {
+	return o.features, nil
+}
+
+func (o *offloadProvider) SetOffloads(name string, offloads map[string]bool) error {
+	for offload, on := range offloads {
+		o.features.Set(offload, on)
+	}
+	o.set = append(o.set, offloads)
+	return nil
+}
+
+func TestNetlinkExecutorSetsOffloads(t *testing.T) {
+	provider := &offloadProvider{mockNetlinkProvider: &mockNetlinkProvider{}, features: ethtool.Features{
+		"rx-gro":                  {Active: true, Changeable: true},
+		"tx-generic-segmentation": {Active: true, Changeable: true},
+		"tx-tcp-segmentation":     {Active: false, Changeable: true},
+		"rx-checksum":             {Active: true},
+	}}
+	cfg := Configuration{Interface: "eth0", Offloads: map[string]bool{"gro": false, "gso": false, "tso": false, "rx": true}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []map[string]bool{{"gro": false, "gso": false}}
+	if !reflect.DeepEqual(provider.set, want) {
+		t.Fatalf("set %v, want %v", provider.set, want)
+	}
+
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil || len(provider.set) != 1 {
+		t.Fatalf("second Apply() = %v, set %v; want no change", err, provider.set)
+	}
+
+	cfg.Offloads = map[string]bool{"rx": false}
+	err := NetlinkExecutor{Provider: provider}.Apply(cfg)
+	if err == nil || !strings.Contains(err.Error(), "offload rx for eth0: rx is fixed on") {
+		t.Fatalf("expected a fixed feature error, got %v", err)
+	}
+}
+
+func TestNetlinkExecutorValidatesOffloads(t *testing.T) {
+	provider := &offloadProvider{mockNetlinkProvider: &mockNetlinkProvider{}, features: ethtool.Features{}}
+	for _, name := range []string{"GRO", "generic receive offload", "rx_gro"} {
+		cfg := Configuration{Interface: "eth0", Offloads: map[string]bool{name: false}}
+		err := NetlinkExecutor{Provider: provider}.Apply(cfg)
+		if err == nil || !strings.Contains(err.Error(), "want one of") {
+			t.Fatalf("Apply(%s) error = %v, want a name error", name, err)
+		}
+	}
+
+	cfg := Configuration{Interface: "eth0", Offloads: map[string]bool{"gro": false}}
+	err := NetlinkExecutor{Provider: &mockNetlinkProvider{}}.Apply(cfg)
+	if err == nil || !strings.Contains(err.Error(), "cannot set offloads") {
+		t.Fatalf("expected an unsupported provider error, got %v", err)
+	}
+}
@@ -0,0 +1,266 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// fullMark is the fwmark mask the kernel assumes when a rule gives none.
+const fullMark = math.MaxUint32
+
+// RuleProvider is implemented by providers that can manage policy routing
+// rules and list routes outside the main table. It is optional;
+// configurations with rules or with routes in another table require it.
+type RuleProvider interface {
+	RuleList(family int) ([]netlink.Rule, error)
+	RuleAdd(rule *netlink.Rule) error
+	RuleDel(rule *netlink.Rule) error
+	RouteListTable(link netlink.Link, family, table int) ([]netlink.Route, error)
+}
+
+// String describes r in the order ip-rule(8) takes its arguments.
+func (r Rule) String() string {
+	parts := []string{fmt.Sprintf("priority %d", r.Priority)}
+	if r.From != "" {
+		parts = append(parts, "from "+r.From)
+	}
+	if r.To != "" {
+		parts = append(parts, "to "+r.To)
+	}
+	if r.FWMark != "" {
+		parts = append(parts, "fwmark "+r.FWMark)
+	}
+	return strings.Join(append(parts, fmt.Sprintf("table %d", r.Table)), " ")
+}
+
+// validatePolicy checks that the rules and tables of p can be managed.
+func (n NetlinkExecutor) validatePolicy(p plan) error {
+	if p.policyRules == nil && len(p.tables) == 0 {
+		return nil
+	}
+	if _, ok := n.Provider.(RuleProvider); !ok {
+		return errors.New("netlink provider cannot manage policy rules or routing tables")
+	}
+	return nil
+}
+
+// parseDesiredRules parses the rules of a configuration, keyed by
+// ruleKey. The family of a rule without selectors is defaultFamily.
+func parseDesiredRules(raw []Rule, defaultFamily int) (map[string]*netlink.Rule, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	desired := make(map[string]*netlink.Rule, len(raw))
+	for _, entry := range raw {
+		if entry.Priority <= 0 || entry.Priority > math.MaxUint32 {
+			return nil, fmt.Errorf("rule priority %d is not between 1 and %d", entry.Priority, uint32(math.MaxUint32))
+		}
+		if err := validateTable(entry.Table); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", entry.Priority, err)
+		}
+		rule := netlink.NewRule()
+		rule.Priority = entry.Priority
+		rule.Table = entry.Table
+		rule.Protocol = uint8(RouteProtocol)
+		rule.Family = defaultFamily
+		var err error
+		if rule.Src, err = parseSelector(entry.From); err != nil {
+			return nil, fmt.Errorf("rule %d: from: %w", entry.Priority, err)
+		}
+		if rule.Dst, err = parseSelector(entry.To); err != nil {
+			return nil, fmt.Errorf("rule %d: to: %w", entry.Priority, err)
+		}
+		if rule.Src != nil && rule.Dst != nil && (rule.Src.IP.To4() != nil) != (rule.Dst.IP.To4() != nil) {
+			return nil, fmt.Errorf("rule %d: from and to are of different address families", entry.Priority)
+		}
+		for _, selector := range []*net.IPNet{rule.Src, rule.Dst} {
+			if selector != nil {
+				rule.Family = ipFamily(selector.IP)
+			}
+		}
+		if entry.FWMark != "" {
+			mark, mask, err := parseFWMark(entry.FWMark)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: %w", entry.Priority, err)
+			}
+			rule.Mark, rule.Mask = mark, &mask
+		}
+		key := ruleKey(*rule)
+		if _, dup := desired[key]; dup {
+			return nil, fmt.Errorf("rule %s is declared more than once", key)
+		}
+		desired[key] = rule
+	}
+	return desired, nil
+}
+
+// validateTable checks a routing table id given in a configuration.
+func validateTable(table int) error {
+	if table <= 0 || table > math.MaxUint32 || table == unix.RT_TABLE_LOCAL {
+		return fmt.Errorf("table %d is not between 1 and %d, or is the local table", table, uint32(math.MaxUint32))
+	}
+	return nil
+}
+
+// parseSelector parses a from or to selector, a CIDR or a single address.
+// Empty matches all addresses.
+func parseSelector(raw string) (*net.IPNet, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	if ip := net.ParseIP(raw); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, selector, err := net.ParseCIDR(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address or prefix %q", raw)
+	}
+	return selector, nil
+}
+
+// parseFWMark parses "mark" or "mark/mask", each in decimal or 0x hex.
+func parseFWMark(raw string) (mark, mask uint32, err error) {
+	rawMark, rawMask, masked := strings.Cut(raw, "/")
+	value, err := strconv.ParseUint(rawMark, 0, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid fwmark %q", raw)
+	}
+	mask = fullMark
+	if masked {
+		m, err := strconv.ParseUint(rawMask, 0, 32)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid fwmark mask %q", raw)
+		}
+		mask = uint32(m)
+	}
+	return uint32(value), mask, nil
+}
+
+func ipFamily(ip net.IP) int {
+	if ip.To4() != nil {
+		return netlink.FAMILY_V4
+	}
+	return netlink.FAMILY_V6
+}
+
+// ruleKey identifies a rule by what it matches and where it leads, in the
+// form ip-rule(8) prints it.
+func ruleKey(rule netlink.Rule) string {
+	selector := func(n *net.IPNet) string {
+		if n == nil {
+			return "all"
+		}
+		if ones, bits := n.Mask.Size(); ones == bits {
+			return n.IP.String()
+		}
+		return n.String()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d: from %s", rule.Priority, selector(rule.Src))
+	if rule.Dst != nil {
+		fmt.Fprintf(&b, " to %s", selector(rule.Dst))
+	}
+	mask := uint32(fullMark)
+	if rule.Mask != nil {
+		mask = *rule.Mask
+	}
+	if rule.Mark != 0 || rule.Mask != nil && mask != 0 {
+		fmt.Fprintf(&b, " fwmark %#x", rule.Mark)
+		if mask != fullMark {
+			fmt.Fprintf(&b, "/%#x", mask)
+		}
+	}
+	fmt.Fprintf(&b, " lookup %d", rule.Table)
+	if rule.Family == netlink.FAMILY_V6 {
+		b.WriteString(" (ipv6)")
+	}
+	return b.String()
+}
+
+// DescribeRule describes a rule the way ip-rule(8) lists it.
+func DescribeRule(rule netlink.Rule) string {
+	return ruleKey(rule)
+}
+
+// routeTables lists the tables other than main that the routes and rules
+// of a configuration use. goeth only removes its own rules and routes from
+// these tables, so that configurations of different interfaces can share a
+// host as long as each uses tables of its own.
+func routeTables(routes map[string]*netlink.Route, rules map[string]*netlink.Rule) map[int]bool {
+	tables := make(map[int]bool)
+	for _, route := range routes {
+		if !mainTable(route.Table) {
+			tables[route.Table] = true
+		}
+	}
+	for _, rule := range rules {
+		if !mainTable(rule.Table) {
+			tables[rule.Table] = true
+		}
+	}
+	return tables
+}
+
+func mainTable(table int) bool {
+	return table == 0 || table == unix.RT_TABLE_MAIN
+}
+
+// reconcileRules adds the desired rules that are missing and removes the
+// undeclared goeth rules that lead to one of tables or to a table of a
+// desired rule. A rule another owner installed satisfies a desired rule it
+// matches. A nil desired map leaves the rules alone entirely.
+func (n NetlinkExecutor) reconcileRules(desired map[string]*netlink.Rule, tables map[int]bool) error {
+	if desired == nil {
+		return nil
+	}
+	tables = maps.Clone(tables)
+	for _, rule := range desired {
+		tables[rule.Table] = true
+	}
+	provider := n.Provider.(RuleProvider)
+	present := make(map[string]bool)
+	owned := make(map[string]netlink.Rule)
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		list, err := provider.RuleList(family)
+		if err != nil {
+			return fmt.Errorf("list rules for family %d: %w", family, err)
+		}
+		for _, rule := range list {
+			key := ruleKey(rule)
+			present[key] = true
+			if rule.Protocol == uint8(RouteProtocol) && tables[rule.Table] {
+				owned[key] = rule
+			}
+		}
+	}
+	for _, key := range sortedKeys(desired) {
+		if present[key] {
+			continue
+		}
+		if err := provider.RuleAdd(desired[key]); err != nil {
+			return fmt.Errorf("add rule %s: %w", key, err)
+		}
+	}
+	for _, key := range sortedKeys(owned) {
+		if _, ok := desired[key]; ok {
+			continue
+		}
+		have := owned[key]
+		if err := provider.RuleDel(&have); err != nil {
+			return fmt.Errorf("remove rule %s: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// ruleProvider lists rules and table routes and records the rules added and
+// removed by their key.
+type ruleProvider struct {
+	*mockNetlinkProvider
+	rules       map[int][]netlink.Rule
+	tableRoutes map[int][]netlink.Route
+	ruleAdded   []string
+	ruleRemoved []string
+}
+
+func (r *ruleProvider) RuleList(family int) ([]netlink.Rule, error) {
+	return r.rules[family], nil
+}
+
+func (r *ruleProvider) RuleAdd(rule *netlink.Rule) error {
+	r.ruleAdded = append(r.ruleAdded, ruleKey(*rule))
+	return nil
+}
+
+func (r *ruleProvider) RuleDel(rule *netlink.Rule) error {
+	r.ruleRemoved = append(r.ruleRemoved, ruleKey(*rule))
+	return nil
+}
+
+func (r *ruleProvider) RouteListTable(link netlink.Link, family, table int) ([]netlink.Route, error) {
+	var routes []netlink.Route
+	for _, route := range r.tableRoutes[table] {
+		if route.Family == family {
+			routes = append(routes, route)
+		}
+	}
+	return routes, nil
+}
+
+func fromRule(t *testing.T, priority int, from string, table int, proto uint8) netlink.Rule {
+	t.Helper()
+	rule := *netlink.NewRule()
+	rule.Family, rule.Priority, rule.Table, rule.Protocol = netlink.FAMILY_V4, priority, table, proto
+	_, rule.Src, _ = net.ParseCIDR(from)
+	return rule
+}
+
+func TestNetlinkExecutorReconcilesRules(t *testing.T) {
+	provider := &ruleProvider{mockNetlinkProvider: &mockNetlinkProvider{}, rules: map[int][]netlink.Rule{
+		netlink.FAMILY_V4: {
+			fromRule(t, 1000, "192.0.2.10/32", 100, unix.RTPROT_BOOT),
+			fromRule(t, 1001, "192.0.2.0/24", 100, uint8(RouteProtocol)),
+			fromRule(t, 1002, "198.51.100.10/32", 200, uint8(RouteProtocol)),
+		},
+	}}
+	cfg := Configuration{Interface: "eth0", Rules: []Rule{
+		{Priority: 1000, From: "192.0.2.10", Table: 100},
+		{Priority: 1100, FWMark: "0x10/0xff", Table: 100},
+	}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"1100: from all fwmark 0x10/0xff lookup 100"}; !reflect.DeepEqual(provider.ruleAdded, want) {
+		t.Fatalf("added rules %v, want %v", provider.ruleAdded, want)
+	}
+	if want := []string{"1001: from 192.0.2.0/24 lookup 100"}; !reflect.DeepEqual(provider.ruleRemoved, want) {
+		t.Fatalf("removed rules %v, want only the undeclared goeth rule of table 100", provider.ruleRemoved)
+	}
+}
+
+func TestNetlinkExecutorReconcilesTableRoutes(t *testing.T) {
+	_, stale, _ := net.ParseCIDR("198.51.100.0/24")
+	provider := &ruleProvider{mockNetlinkProvider: &mockNetlinkProvider{}, tableRoutes: map[int][]netlink.Route{
+		100: {{Family: netlink.FAMILY_V4, Dst: stale, Table: 100, Protocol: RouteProtocol}},
+	}}
+	cfg := Configuration{Interface: "eth0", Routes: []Route{
+		{Destination: "default", Gateway: "192.0.2.1", Table: 100},
+		{Destination: "192.0.2.0/24"},
+	}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"0.0.0.0/0 table 100", "192.0.2.0/24"}; !reflect.DeepEqual(provider.routeAdded, want) {
+		t.Fatalf("added routes %v, want %v", provider.routeAdded, want)
+	}
+	if want := []string{"198.51.100.0/24 table 100"}; !reflect.DeepEqual(provider.routeRemoved, want) {
+		t.Fatalf("removed routes %v, want %v", provider.routeRemoved, want)
+	}
+}
+
+func TestNetlinkExecutorValidatesRules(t *testing.T) {
+	provider := &ruleProvider{mockNetlinkProvider: &mockNetlinkProvider{}}
+	tests := []struct {
+		cfg      Configuration
+		provider NetlinkProvider
+		want     string
+	}{
+		{Configuration{Rules: []Rule{{Priority: 100, Table: 100}}}, provider.mockNetlinkProvider, "cannot manage policy rules"},
+		{Configuration{Routes: []Route{{Destination: "default", Table: 100}}}, provider.mockNetlinkProvider, "cannot manage policy rules"},
+		{Configuration{Rules: []Rule{{Table: 100}}}, provider, "priority 0 is not between"},
+		{Configuration{Rules: []Rule{{Priority: 100, Table: unix.RT_TABLE_LOCAL}}}, provider, "or is the local table"},
+		{Configuration{Rules: []Rule{{Priority: 100, FWMark: "mark", Table: 100}}}, provider, `invalid fwmark "mark"`},
+		{Configuration{Rules: []Rule{{Priority: 100, From: "192.0.2.10", To: "2001:db8::/32", Table: 100}}}, provider, "different address families"},
+		{Configuration{Rules: []Rule{{Priority: 100, To: "192.0.2.0/33", Table: 100}}}, provider, `to: invalid address or prefix "192.0.2.0/33"`},
+		{Configuration{Rules: []Rule{{Priority: 100, From: "192.0.2.10", Table: 100}, {Priority: 100, From: "192.0.2.10/32", Table: 100}}}, provider, "declared more than once"},
+		{Configuration{Profile: ProfileIPv6Only, Rules: []Rule{{Priority: 100, From: "192.0.2.10", Table: 100}}}, provider, "does not allow IPv4 rule"},
+	}
+	for _, tt := range tests {
+		tt.cfg.Interface = "eth0"
+		err := NetlinkExecutor{Provider: tt.provider}.Apply(tt.cfg)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("Apply(%+v) error = %v, want %q", tt.cfg, err, tt.want)
+		}
+	}
+}
+
+func TestRuleKeyMatchesKernelListing(t *testing.T) {
+	desired, err := parseDesiredRules([]Rule{
+		{Priority: 200, From: "2001:db8::/64", Table: 200},
+		{Priority: 300, FWMark: "7", Table: 300},
+	}, netlink.FAMILY_V4)
+	if err != nil {
+		t.Fatalf("parseDesiredRules() error = %v", err)
+	}
+	// The kernel lists a full fwmark mask, and a rule without a mark
+	// without either.
+	full := uint32(fullMark)
+	_, src, _ := net.ParseCIDR("2001:db8::/64")
+	listed := []netlink.Rule{
+		{Priority: 200, Family: netlink.FAMILY_V6, Src: src, Table: 200},
+		{Priority: 300, Family: netlink.FAMILY_V4, Mark: 7, Mask: &full, Table: 300},
+	}
+	for _, rule := range listed {
+		if _, ok := desired[ruleKey(rule)]; !ok {
+			t.Fatalf("listed rule %q is not among %v", ruleKey(rule), sortedKeys(desired))
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// ProfileDualStack is the default profile.
+	ProfileDualStack = "dual-stack"
+	// ProfileIPv6Only is for hosts without IPv4 connectivity, which reach
+	// IPv4 destinations through NAT64 if at all.
+	ProfileIPv6Only = "ipv6-only"
+)
+
+// IPv6Only reports whether c uses the ipv6-only profile.
+func (c Configuration) IPv6Only() bool {
+	return c.Profile == ProfileIPv6Only
+}
+
+// defaultFamily is the family of a default route declared without a gateway.
+func (c Configuration) defaultFamily() int {
+	if c.IPv6Only() {
+		return netlink.FAMILY_V6
+	}
+	return netlink.FAMILY_V4
+}
+
+// Warnings lists what c probably gets wrong without being invalid.
+func (c Configuration) Warnings() []string {
+	if !c.IPv6Only() {
+		return nil
+	}
+	for _, entry := range c.Addresses {
+		if ip, _, err := net.ParseCIDR(entry.CIDR); err == nil && ip.To4() == nil {
+			return nil
+		}
+	}
+	return []string{fmt.Sprintf("%s uses the ipv6-only profile but declares no IPv6 address; it depends on router advertisements to get one", c.Interface)}
+}
+
+// validateProfile rejects the parts of cfg its profile does not allow.
+func validateProfile(cfg Configuration, p plan) error {
+	switch cfg.Profile {
+	case "", ProfileDualStack:
+		return nil
+	case ProfileIPv6Only:
+	default:
+		return fmt.Errorf("profile must be %s or %s, got %q", ProfileDualStack, ProfileIPv6Only, cfg.Profile)
+	}
+	for _, key := range sortedKeys(p.desired) {
+		if p.desired[key].IP.To4() != nil {
+			return fmt.Errorf("profile %s does not allow IPv4 address %s", cfg.Profile, key)
+		}
+	}
+	for _, key := range sortedKeys(p.routes) {
+		if p.routes[key].Family == netlink.FAMILY_V4 {
+			return fmt.Errorf("profile %s does not allow IPv4 route %s", cfg.Profile, key)
+		}
+	}
+	for _, key := range sortedKeys(p.policyRules) {
+		if p.policyRules[key].Family == netlink.FAMILY_V4 {
+			return fmt.Errorf("profile %s does not allow IPv4 rule %s", cfg.Profile, key)
+		}
+	}
+	for _, key := range sortedKeys(p.neighbors) {
+		if p.neighbors[key].Family == netlink.FAMILY_V4 {
+			return fmt.Errorf("profile %s does not allow IPv4 neighbor %s", cfg.Profile, key)
+		}
+	}
+	if cfg.Tunnel != nil {
+		return fmt.Errorf("profile %s does not allow %s tunnels, which run over IPv4", cfg.Profile, cfg.Tunnel.Mode)
+	}
+	if cfg.MTUProbe != nil {
+		return fmt.Errorf("profile %s does not allow mtu_probe, which only probes IPv4 paths", cfg.Profile)
+	}
+	return nil
+}
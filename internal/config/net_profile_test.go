@@ -0,0 +1,67 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestNetlinkExecutorIPv6OnlyDefaultRoute(t *testing.T) {
+	provider := &mockNetlinkProvider{link: &fakeLink{netlink.LinkAttrs{Name: "eth0", Index: 2}}}
+	cfg := Configuration{
+		Interface: "eth0",
+		Profile:   ProfileIPv6Only,
+		Addresses: []Address{{CIDR: "2001:db8::10/64"}},
+		Routes:    []Route{{Destination: "default"}},
+	}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got := strings.Join(provider.routeAdded, ","); got != "::/0" {
+		t.Fatalf("expected an IPv6 default route, added %q", got)
+	}
+}
+
+func TestNetlinkExecutorIPv6OnlyRejectsIPv4(t *testing.T) {
+	cases := map[string]Configuration{
+		"address":   {Addresses: []Address{{CIDR: "2001:db8::10/64"}, {CIDR: "192.0.2.10/24"}}},
+		"route":     {Routes: []Route{{Destination: "198.51.100.0/24"}}},
+		"neighbor":  {Neighbors: []Neighbor{{IP: "192.0.2.1", MAC: "02:00:00:00:00:01"}}},
+		"tunnel":    {Tunnel: &Tunnel{Mode: "gre", Local: "192.0.2.10", Remote: "198.51.100.1"}},
+		"mtu_probe": {MTUProbe: &MTUProbe{Target: "192.0.2.1"}},
+	}
+	for name, cfg := range cases {
+		cfg.Interface = "eth0"
+		cfg.Profile = ProfileIPv6Only
+		provider := &mockNetlinkProvider{link: &fakeLink{netlink.LinkAttrs{Name: "eth0", Index: 2}}}
+		err := (NetlinkExecutor{Provider: provider}).Apply(cfg)
+		if err == nil || !strings.Contains(err.Error(), "profile ipv6-only does not allow") {
+			t.Fatalf("%s: expected a profile error, got %v", name, err)
+		}
+		if len(provider.addedAddrs)+len(provider.routeAdded)+len(provider.neighSet) != 0 {
+			t.Fatalf("%s: expected nothing to change", name)
+		}
+	}
+
+	cfg := Configuration{Interface: "eth0", Profile: "v6", MTU: 1500}
+	err := (NetlinkExecutor{Provider: &mockNetlinkProvider{link: &fakeLink{netlink.LinkAttrs{Name: "eth0"}}}}).Apply(cfg)
+	if err == nil || !strings.Contains(err.Error(), "profile must be") {
+		t.Fatalf("expected an unknown profile error, got %v", err)
+	}
+}
+
+func TestConfigurationWarnings(t *testing.T) {
+	cfg := Configuration{Interface: "eth0", Profile: ProfileIPv6Only, Addresses: []Address{{CIDR: "fd00::10/64"}}}
+	if got := cfg.Warnings(); len(got) != 0 {
+		t.Fatalf("expected no warnings, got %v", got)
+	}
+	cfg.Addresses = nil
+	if got := cfg.Warnings(); len(got) != 1 || !strings.Contains(got[0], "declares no IPv6 address") {
+		t.Fatalf("expected a missing IPv6 warning, got %v", got)
+	}
+	cfg.Profile = ""
+	if got := cfg.Warnings(); len(got) != 0 {
+		t.Fatalf("expected no warnings for dual-stack, got %v", got)
+	}
+}
@@ -0,0 +1,268 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// Queueing disciplines that can be set as the root qdisc of an interface.
+const (
+	qdiscFQCodel = "fq_codel"
+	qdiscTBF     = "tbf"
+)
+
+const (
+	// defaultTBFLatency bounds how long packets may wait for tokens, as
+	// tc(8) uses it to size the queue.
+	defaultTBFLatency = 50 * time.Millisecond
+	// tbfBurstsPerSecond sizes the default burst to what the rate sends in
+	// 10ms.
+	tbfBurstsPerSecond = 100
+	// ethernetHeaderLen is added to the MTU for the smallest usable burst:
+	// tbf drops packets larger than its bucket.
+	ethernetHeaderLen = 14
+	usecPerSecond     = 1e6
+)
+
+// QdiscProvider is implemented by providers that can list and replace the
+// queueing disciplines of links. It is optional; configurations with a qdisc
+// section require it.
+type QdiscProvider interface {
+	QdiscList(link netlink.Link) ([]netlink.Qdisc, error)
+	QdiscReplace(qdisc netlink.Qdisc) error
+}
+
+// rateUnits are the rate suffixes tc(8) accepts, in bytes per second.
+var rateUnits = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"tbit", 1e12 / 8}, {"gbit", 1e9 / 8}, {"mbit", 1e6 / 8}, {"kbit", 1e3 / 8}, {"bit", 1.0 / 8},
+	{"tbps", 1e12}, {"gbps", 1e9}, {"mbps", 1e6}, {"kbps", 1e3}, {"bps", 1},
+}
+
+// sizeUnits are the size suffixes tc(8) accepts, in bytes.
+var sizeUnits = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"gbit", 1 << 27}, {"mbit", 1 << 17}, {"kbit", 1 << 7},
+	{"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10}, {"b", 1},
+}
+
+// qdiscSpec is a validated qdisc section. The tbf burst is left zero when it
+// defaults to a value derived from the link MTU.
+type qdiscSpec struct {
+	kind    string
+	rate    uint64
+	burst   uint32
+	latency time.Duration
+	limit   uint32
+}
+
+// parseQdisc validates the qdisc section. It returns nil when the section is
+// absent.
+func (n NetlinkExecutor) parseQdisc(cfg Configuration) (*qdiscSpec, error) {
+	section := cfg.Qdisc
+	if section == nil {
+		return nil, nil
+	}
+	if _, ok := n.Provider.(QdiscProvider); !ok {
+		return nil, errors.New("netlink provider cannot set qdiscs")
+	}
+	spec := &qdiscSpec{kind: section.Type}
+	switch section.Type {
+	case qdiscTBF:
+		if section.Rate == "" {
+			return nil, fmt.Errorf("qdisc for %s: tbf requires a rate", cfg.Interface)
+		}
+		if section.Limit != 0 {
+			return nil, fmt.Errorf("qdisc for %s: limit applies to fq_codel; tbf sizes its queue by latency", cfg.Interface)
+		}
+		rate, err := parseRate(section.Rate)
+		if err != nil {
+			return nil, fmt.Errorf("qdisc for %s: rate: %w", cfg.Interface, err)
+		}
+		spec.rate = rate
+		if section.Burst != "" {
+			burst, err := parseSize(section.Burst)
+			if err != nil {
+				return nil, fmt.Errorf("qdisc for %s: burst: %w", cfg.Interface, err)
+			}
+			spec.burst = burst
+		}
+		spec.latency = time.Duration(section.Latency)
+		if spec.latency == 0 {
+			spec.latency = defaultTBFLatency
+		}
+	case qdiscFQCodel:
+		if section.Rate != "" || section.Burst != "" || section.Latency != 0 {
+			return nil, fmt.Errorf("qdisc for %s: rate, burst and latency apply to tbf", cfg.Interface)
+		}
+		if section.Limit < 0 || section.Limit > math.MaxUint32 {
+			return nil, fmt.Errorf("qdisc for %s: limit %d is out of range", cfg.Interface, section.Limit)
+		}
+		spec.limit = uint32(section.Limit)
+	default:
+		return nil, fmt.Errorf("qdisc for %s: unknown type %q, want %s or %s", cfg.Interface, section.Type, qdiscFQCodel, qdiscTBF)
+	}
+	return spec, nil
+}
+
+// qdisc builds the root qdisc of link described by s. It has no handle, so
+// that like "tc qdisc replace root" a root qdisc of another type is replaced
+// by a new one and one of the same type is changed in place.
+func (s qdiscSpec) qdisc(link netlink.Link) netlink.Qdisc {
+	attrs := netlink.QdiscAttrs{LinkIndex: link.Attrs().Index, Parent: netlink.HANDLE_ROOT}
+	if s.kind == qdiscFQCodel {
+		fqCodel := netlink.NewFqCodel(attrs)
+		fqCodel.Limit = s.limit
+		return fqCodel
+	}
+	burst := s.burst
+	if burst == 0 {
+		burst = uint32(max(s.rate/tbfBurstsPerSecond, uint64(link.Attrs().MTU+ethernetHeaderLen)))
+	}
+	return &netlink.Tbf{
+		QdiscAttrs: attrs,
+		Rate:       s.rate,
+		Buffer:     netlink.Xmittime(s.rate, burst),
+		Limit:      uint32(float64(s.rate)*s.latency.Seconds()) + burst,
+	}
+}
+
+// reconcileQdisc replaces the root qdisc of link unless it already matches
+// spec. Without a qdisc section the root qdisc is left as it is.
+func (n NetlinkExecutor) reconcileQdisc(cfg Configuration, link netlink.Link, spec *qdiscSpec) error {
+	if spec == nil {
+		return nil
+	}
+	provider := n.Provider.(QdiscProvider)
+	want := spec.qdisc(link)
+	qdiscs, err := provider.QdiscList(link)
+	if err != nil {
+		return fmt.Errorf("list qdiscs of %s: %w", cfg.Interface, err)
+	}
+	for _, have := range qdiscs {
+		if have.Attrs().Parent == netlink.HANDLE_ROOT && sameQdisc(have, want) {
+			return nil
+		}
+	}
+	err = provider.QdiscReplace(want)
+	if errors.Is(err, unix.ENOENT) {
+		return fmt.Errorf("set qdisc of %s to %s: %w (the kernel does not provide %s)", cfg.Interface, DescribeQdisc(want), err, want.Type())
+	}
+	if err != nil {
+		return fmt.Errorf("set qdisc of %s to %s: %w", cfg.Interface, DescribeQdisc(want), err)
+	}
+	return nil
+}
+
+func sameQdisc(have, want netlink.Qdisc) bool {
+	switch want := want.(type) {
+	case *netlink.Tbf:
+		have, ok := have.(*netlink.Tbf)
+		return ok && have.Rate == want.Rate && have.Buffer == want.Buffer && have.Limit == want.Limit
+	case *netlink.FqCodel:
+		have, ok := have.(*netlink.FqCodel)
+		return ok && have.ECN == want.ECN && (want.Limit == 0 || have.Limit == want.Limit)
+	}
+	return false
+}
+
+// DescribeQdisc formats a qdisc the way tc(8) takes its parameters, such as
+// "tbf rate 10mbit burst 12500b limit 75000b".
+func DescribeQdisc(qdisc netlink.Qdisc) string {
+	switch qdisc := qdisc.(type) {
+	case *netlink.Tbf:
+		description := "tbf rate " + formatRate(qdisc.Rate)
+		if tick := netlink.TickInUsec(); tick > 0 {
+			burst := math.Round(float64(qdisc.Rate) * float64(qdisc.Buffer) / tick / usecPerSecond)
+			description += fmt.Sprintf(" burst %.0fb", burst)
+		}
+		return description + fmt.Sprintf(" limit %db", qdisc.Limit)
+	case *netlink.FqCodel:
+		if qdisc.Limit > 0 {
+			return fmt.Sprintf("fq_codel limit %d", qdisc.Limit)
+		}
+	}
+	return qdisc.Type()
+}
+
+// String formats the declared qdisc with the parameters that are set.
+func (q Qdisc) String() string {
+	parts := []string{q.Type}
+	if q.Rate != "" {
+		parts = append(parts, "rate "+q.Rate)
+	}
+	if q.Burst != "" {
+		parts = append(parts, "burst "+q.Burst)
+	}
+	if q.Latency != 0 {
+		parts = append(parts, "latency "+time.Duration(q.Latency).String())
+	}
+	if q.Limit != 0 {
+		parts = append(parts, "limit "+strconv.Itoa(q.Limit))
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseRate parses a rate such as "10mbit" into bytes per second. A plain
+// number is in bits per second, as with tc(8).
+func parseRate(raw string) (uint64, error) {
+	value, unit := raw, 1.0/8
+	lower := strings.ToLower(raw)
+	for _, u := range rateUnits {
+		if strings.HasSuffix(lower, u.suffix) {
+			value, unit = raw[:len(raw)-len(u.suffix)], u.bytes
+			break
+		}
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	rate := number * unit
+	if err != nil || rate < 1 || rate > math.MaxUint64 {
+		return 0, fmt.Errorf("%q is not a rate such as 10mbit", raw)
+	}
+	return uint64(rate), nil
+}
+
+// parseSize parses a size such as "32kb" into bytes. A plain number is in
+// bytes.
+func parseSize(raw string) (uint32, error) {
+	value, unit := raw, 1.0
+	lower := strings.ToLower(raw)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(lower, u.suffix) {
+			value, unit = raw[:len(raw)-len(u.suffix)], u.bytes
+			break
+		}
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	size := number * unit
+	if err != nil || size < 1 || size > math.MaxUint32 {
+		return 0, fmt.Errorf("%q is not a size such as 32kb", raw)
+	}
+	return uint32(size), nil
+}
+
+// formatRate formats bytes per second in the largest bit unit that divides
+// it evenly.
+func formatRate(rate uint64) string {
+	bits := rate * 8
+	for _, unit := range []struct {
+		suffix string
+		bits   uint64
+	}{{"gbit", 1e9}, {"mbit", 1e6}, {"kbit", 1e3}} {
+		if bits >= unit.bits && bits%unit.bits == 0 {
+			return fmt.Sprintf("%d%s", bits/unit.bits, unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%dbit", bits)
+}
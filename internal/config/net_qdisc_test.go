@@ -0,0 +1,116 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+// qdiscProvider keeps the root qdisc of the link in memory.
+type qdiscProvider struct {
+	*mockNetlinkProvider
+	root     netlink.Qdisc
+	replaced []netlink.Qdisc
+}
+
+func (q *qdiscProvider) QdiscList(link netlink.Link) ([]netlink.Qdisc, error) {
+	if q.root == nil {
+		return nil, nil
+	}
+	return []netlink.Qdisc{q.root}, nil
+}
+
+func (q *qdiscProvider) QdiscReplace(qdisc netlink.Qdisc) error {
+	q.root = qdisc
+	q.replaced = append(q.replaced, qdisc)
+	return nil
+}
+
+func TestNetlinkExecutorSetsTBF(t *testing.T) {
+	provider := &qdiscProvider{mockNetlinkProvider: &mockNetlinkProvider{link: &fakeLink{netlink.LinkAttrs{Name: "eth0", Index: 2, MTU: 1500}}}}
+	cfg := Configuration{Interface: "eth0", Qdisc: &Qdisc{Type: "tbf", Rate: "10mbit"}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.replaced) != 1 {
+		t.Fatalf("replaced %v, want one tbf", provider.replaced)
+	}
+	tbf, ok := provider.replaced[0].(*netlink.Tbf)
+	if !ok || tbf.Rate != 1250000 || tbf.Limit != 62500+12500 || tbf.Buffer != netlink.Xmittime(1250000, 12500) {
+		t.Fatalf("set %+v, want 10mbit with a 12500 byte burst for 50ms", provider.replaced[0])
+	}
+	if tbf.LinkIndex != 2 || tbf.Parent != netlink.HANDLE_ROOT {
+		t.Fatalf("set qdisc on %d parent %#x, want the root of link 2", tbf.LinkIndex, tbf.Parent)
+	}
+
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil || len(provider.replaced) != 1 {
+		t.Fatalf("second Apply() = %v, replaced %d times; want no change", err, len(provider.replaced))
+	}
+
+	cfg.Qdisc = &Qdisc{Type: "fq_codel", Limit: 1000}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if fqCodel, ok := provider.root.(*netlink.FqCodel); !ok || fqCodel.Limit != 1000 {
+		t.Fatalf("root qdisc = %+v, want fq_codel limit 1000", provider.root)
+	}
+}
+
+func TestNetlinkExecutorTBFBurstCoversFrames(t *testing.T) {
+	provider := &qdiscProvider{mockNetlinkProvider: &mockNetlinkProvider{link: &fakeLink{netlink.LinkAttrs{Name: "eth0", MTU: 9000}}}}
+	cfg := Configuration{Interface: "eth0", Qdisc: &Qdisc{Type: "tbf", Rate: "1mbit", Latency: Duration(100 * time.Millisecond)}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if tbf := provider.root.(*netlink.Tbf); tbf.Limit != 12500+9014 {
+		t.Fatalf("limit = %d, want 100ms at 1mbit plus a 9014 byte burst", tbf.Limit)
+	}
+}
+
+func TestNetlinkExecutorValidatesQdisc(t *testing.T) {
+	provider := &qdiscProvider{mockNetlinkProvider: &mockNetlinkProvider{}}
+	tests := []struct {
+		qdisc Qdisc
+		want  string
+	}{
+		{Qdisc{Type: "htb"}, `unknown type "htb"`},
+		{Qdisc{Type: "tbf"}, "tbf requires a rate"},
+		{Qdisc{Type: "tbf", Rate: "fast"}, `"fast" is not a rate`},
+		{Qdisc{Type: "tbf", Rate: "10mbit", Burst: "0kb"}, `"0kb" is not a size`},
+		{Qdisc{Type: "tbf", Rate: "10mbit", Limit: 100}, "limit applies to fq_codel"},
+		{Qdisc{Type: "fq_codel", Rate: "10mbit"}, "apply to tbf"},
+	}
+	for _, tt := range tests {
+		qdisc := tt.qdisc
+		err := NetlinkExecutor{Provider: provider}.Apply(Configuration{Interface: "eth0", Qdisc: &qdisc})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("Apply(%s) error = %v, want %q", qdisc, err, tt.want)
+		}
+	}
+
+	cfg := Configuration{Interface: "eth0", Qdisc: &Qdisc{Type: "fq_codel"}}
+	err := NetlinkExecutor{Provider: &mockNetlinkProvider{}}.Apply(cfg)
+	if err == nil || !strings.Contains(err.Error(), "cannot set qdiscs") {
+		t.Fatalf("expected an unsupported provider error, got %v", err)
+	}
+}
+
+func TestParseRateAndSize(t *testing.T) {
+	rates := map[string]uint64{"10mbit": 1250000, "1Gbit": 125000000, "512kbit": 64000, "8000": 1000, "2mbps": 2000000}
+	for raw, want := range rates {
+		if got, err := parseRate(raw); err != nil || got != want {
+			t.Fatalf("parseRate(%q) = %d, %v; want %d", raw, got, err, want)
+		}
+	}
+	sizes := map[string]uint32{"32kb": 32768, "1500": 1500, "1500b": 1500, "1mb": 1 << 20, "8kbit": 1024}
+	for raw, want := range sizes {
+		if got, err := parseSize(raw); err != nil || got != want {
+			t.Fatalf("parseSize(%q) = %d, %v; want %d", raw, got, err, want)
+		}
+	}
+	if got := formatRate(1250000); got != "10mbit" {
+		t.Fatalf("formatRate(1250000) = %q, want 10mbit", got)
+	}
+}
@@ -0,0 +1,201 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+// sysClassNet is where the kernel exposes network devices in sysfs.
+const sysClassNet = "/sys/class/net"
+
+var pciAddressPattern = regexp.MustCompile(`^[0-9a-f]{4}:[0-9a-f]{2}:[0-9a-f]{2}\.[0-7]$`)
+
+// BusAddressProvider is implemented by providers that can resolve the PCI
+// address backing a link. It is optional; LinkRule entries using pci_path
+// require it.
+type BusAddressProvider interface {
+	BusAddress(name string) (string, error)
+}
+
+type linkRule struct {
+	mac        net.HardwareAddr
+	pciPath    string
+	renameFrom string
+	name       string
+}
+
+func parseLinkRules(raw []LinkRule) ([]linkRule, error) {
+	rules := make([]linkRule, 0, len(raw))
+	seen := make(map[string]struct{}, len(raw))
+	for _, entry := range raw {
+		if err := validateInterfaceName(entry.Name); err != nil {
+			return nil, fmt.Errorf("link rule: %w", err)
+		}
+		if _, dup := seen[entry.Name]; dup {
+			return nil, fmt.Errorf("link rule for %s is declared more than once", entry.Name)
+		}
+		seen[entry.Name] = struct{}{}
+		selectors := 0
+		for _, selector := range []string{entry.MAC, entry.PCIPath, entry.RenameFrom} {
+			if selector != "" {
+				selectors++
+			}
+		}
+		if selectors != 1 {
+			return nil, fmt.Errorf("link rule for %s must set exactly one of mac, pci_path or rename_from", entry.Name)
+		}
+		if entry.RenameFrom != "" {
+			if err := validateInterfaceName(entry.RenameFrom); err != nil {
+				return nil, fmt.Errorf("link rule for %s: %w", entry.Name, err)
+			}
+		}
+		rule := linkRule{name: entry.Name, renameFrom: entry.RenameFrom, pciPath: strings.ToLower(strings.TrimPrefix(entry.PCIPath, "pci-"))}
+		if entry.MAC != "" {
+			mac, err := net.ParseMAC(entry.MAC)
+			if err != nil {
+				return nil, fmt.Errorf("link rule for %s: %w", entry.Name, err)
+			}
+			rule.mac = mac
+		}
+		if entry.PCIPath != "" && !pciAddressPattern.MatchString(rule.pciPath) {
+			return nil, fmt.Errorf("link rule for %s: invalid pci_path %q", entry.Name, entry.PCIPath)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// applyLinkRules renames the interfaces selected by rules.
+func (n NetlinkExecutor) applyLinkRules(rules []linkRule) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	links, err := n.Provider.LinkList()
+	if err != nil {
+		return fmt.Errorf("list links: %w", err)
+	}
+	for _, rule := range rules {
+		link, err := n.matchRule(rule, links)
+		if err != nil {
+			return err
+		}
+		if link.Attrs().Name == rule.name {
+			continue
+		}
+		for _, other := range links {
+			if other.Attrs().Name == rule.name {
+				return fmt.Errorf("cannot rename %s to %s: the name is already taken", link.Attrs().Name, rule.name)
+			}
+		}
+		if err := n.renameLink(link, rule.name); err != nil {
+			return err
+		}
+		link.Attrs().Name = rule.name
+	}
+	return nil
+}
+
+func (n NetlinkExecutor) matchRule(rule linkRule, links []netlink.Link) (netlink.Link, error) {
+	if rule.renameFrom != "" {
+		return matchName(rule, links)
+	}
+	var matches []netlink.Link
+	for _, link := range links {
+		if link.Type() != "device" {
+			continue
+		}
+		if rule.mac != nil {
+			mac := link.Attrs().PermHWAddr
+			if len(mac) == 0 {
+				mac = link.Attrs().HardwareAddr
+			}
+			if strings.EqualFold(mac.String(), rule.mac.String()) {
+				matches = append(matches, link)
+			}
+			continue
+		}
+		resolver, ok := n.Provider.(BusAddressProvider)
+		if !ok {
+			return nil, fmt.Errorf("link rule for %s: pci_path lookups are not supported by the provider", rule.name)
+		}
+		addr, err := resolver.BusAddress(link.Attrs().Name)
+		if err != nil {
+			continue
+		}
+		if addr == rule.pciPath {
+			matches = append(matches, link)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("link rule for %s: no physical interface matches", rule.name)
+	case 1:
+		return matches[0], nil
+	}
+	return nil, fmt.Errorf("link rule for %s: %d interfaces match", rule.name, len(matches))
+}
+
+// matchName selects the link called rule.renameFrom, or the one already
+// called rule.name when an earlier run renamed it.
+func matchName(rule linkRule, links []netlink.Link) (netlink.Link, error) {
+	var renamed netlink.Link
+	for _, link := range links {
+		switch link.Attrs().Name {
+		case rule.renameFrom:
+			return link, nil
+		case rule.name:
+			renamed = link
+		}
+	}
+	if renamed == nil {
+		return nil, fmt.Errorf("link rule for %s: no interface is called %s", rule.name, rule.renameFrom)
+	}
+	return renamed, nil
+}
+
+// renameLink gives link a new name. The kernel refuses to rename running
+// links, so a link that is up is brought down for the rename and back up after.
+func (n NetlinkExecutor) renameLink(link netlink.Link, name string) error {
+	old := link.Attrs().Name
+	wasUp := link.Attrs().Flags&net.FlagUp != 0
+	if wasUp {
+		if err := n.Provider.LinkSetDown(link); err != nil {
+			return fmt.Errorf("set %s down: %w", old, err)
+		}
+	}
+	if err := n.Provider.LinkSetName(link, name); err != nil {
+		return fmt.Errorf("rename %s to %s: %w", old, name, err)
+	}
+	if wasUp {
+		link.Attrs().Name = name
+		if err := n.Provider.LinkSetUp(link); err != nil {
+			return fmt.Errorf("set %s up: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// sysfsBusAddress resolves the PCI address of the device backing name by
+// walking its sysfs device path (below root) from the device towards the root.
+func sysfsBusAddress(root, name string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(filepath.Join(root, name, "device"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("%s has no backing device", name)
+		}
+		return "", err
+	}
+	for dir := resolved; dir != "/" && dir != "."; dir = filepath.Dir(dir) {
+		if base := filepath.Base(dir); pciAddressPattern.MatchString(base) {
+			return base, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not backed by a PCI device", name)
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+type busMockProvider struct {
+	*mockNetlinkProvider
+	bus map[string]string
+}
+
+func (b busMockProvider) BusAddress(name string) (string, error) {
+	if addr, ok := b.bus[name]; ok {
+		return addr, nil
+	}
+	return "", os.ErrNotExist
+}
+
+func TestNetlinkExecutorRenamesByMAC(t *testing.T) {
+	mac, _ := net.ParseMAC("02:00:00:00:00:01")
+	enp := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "enp3s0", Index: 2, HardwareAddr: mac, Flags: net.FlagUp}}
+	bond := &netlink.Bond{LinkAttrs: netlink.LinkAttrs{Name: "bond0", Index: 3, HardwareAddr: mac}}
+	provider := &mockNetlinkProvider{links: []netlink.Link{enp, bond}}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "uplink0", Links: []LinkRule{{MAC: "02:00:00:00:00:01", Name: "uplink0"}}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.renamed) != 1 || provider.renamed[0] != "enp3s0>uplink0" {
+		t.Fatalf("unexpected renames: %v", provider.renamed)
+	}
+	if len(provider.states) != 2 || provider.states[0] != "enp3s0:down" || provider.states[1] != "uplink0:up" {
+		t.Fatalf("expected the running link to be cycled around the rename, got %v", provider.states)
+	}
+}
+
+func TestNetlinkExecutorRenamesByPCIPath(t *testing.T) {
+	eth0 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 2}}
+	eth1 := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth1", Index: 3}}
+	provider := busMockProvider{
+		mockNetlinkProvider: &mockNetlinkProvider{links: []netlink.Link{eth0, eth1}},
+		bus:                 map[string]string{"eth0": "0000:03:00.0", "eth1": "0000:03:00.1"},
+	}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "lan1", Links: []LinkRule{{PCIPath: "pci-0000:03:00.1", Name: "lan1"}}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.renamed) != 1 || provider.renamed[0] != "eth1>lan1" {
+		t.Fatalf("unexpected renames: %v", provider.renamed)
+	}
+	if len(provider.states) != 0 {
+		t.Fatalf("expected a down link to be renamed without state changes, got %v", provider.states)
+	}
+}
+
+func TestNetlinkExecutorSkipsRuleAlreadySatisfied(t *testing.T) {
+	mac, _ := net.ParseMAC("02:00:00:00:00:01")
+	provider := &mockNetlinkProvider{links: []netlink.Link{
+		&netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "uplink0", HardwareAddr: mac}},
+	}}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "uplink0", Links: []LinkRule{{MAC: "02:00:00:00:00:01", Name: "uplink0"}}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.renamed) != 0 {
+		t.Fatalf("expected no renames, got %v", provider.renamed)
+	}
+}
+
+func TestNetlinkExecutorRenamesByName(t *testing.T) {
+	veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "veth7", Index: 4, Flags: net.FlagUp}}
+	provider := &mockNetlinkProvider{links: []netlink.Link{veth}}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "uplink0", Links: []LinkRule{{RenameFrom: "veth7", Name: "uplink0"}}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.renamed) != 1 || provider.renamed[0] != "veth7>uplink0" {
+		t.Fatalf("unexpected renames: %v", provider.renamed)
+	}
+	if len(provider.states) != 2 || provider.states[0] != "veth7:down" || provider.states[1] != "uplink0:up" {
+		t.Fatalf("expected the running link to be cycled around the rename, got %v", provider.states)
+	}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("second Apply() error = %v", err)
+	}
+	if len(provider.renamed) != 1 {
+		t.Fatalf("expected the second run to find the renamed link, got %v", provider.renamed)
+	}
+}
+
+func TestNetlinkExecutorRuleErrors(t *testing.T) {
+	mac, _ := net.ParseMAC("02:00:00:00:00:01")
+	links := []netlink.Link{
+		&netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0", HardwareAddr: mac}},
+		&netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "uplink0"}},
+	}
+	cases := map[string][]LinkRule{
+		"no match":        {{MAC: "02:00:00:00:00:99", Name: "lan0"}},
+		"name taken":      {{MAC: "02:00:00:00:00:01", Name: "uplink0"}},
+		"no selector":     {{Name: "lan0"}},
+		"two selectors":   {{MAC: "02:00:00:00:00:01", PCIPath: "0000:03:00.0", Name: "lan0"}},
+		"bad pci path":    {{PCIPath: "03:00.0", Name: "lan0"}},
+		"pci unsupported": {{PCIPath: "0000:03:00.0", Name: "lan0"}},
+		"no rename match": {{RenameFrom: "eth9", Name: "lan0"}},
+		"rename taken":    {{RenameFrom: "eth0", Name: "uplink0"}},
+		"mac and rename":  {{MAC: "02:00:00:00:00:01", RenameFrom: "eth0", Name: "lan0"}},
+	}
+	for name, rules := range cases {
+		provider := &mockNetlinkProvider{links: links}
+		exec := NetlinkExecutor{Provider: provider}
+		if err := exec.Apply(Configuration{Interface: "lan0", Links: rules}); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+		if len(provider.renamed) != 0 {
+			t.Fatalf("%s: expected no renames, got %v", name, provider.renamed)
+		}
+	}
+}
+
+func TestSysfsBusAddress(t *testing.T) {
+	root := t.TempDir()
+	device := filepath.Join(root, "devices", "pci0000:00", "0000:00:03.0", "virtio3")
+	if err := os.MkdirAll(device, 0o755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	netDir := filepath.Join(root, "class", "net", "eth0")
+	if err := os.MkdirAll(netDir, 0o755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if err := os.Symlink(device, filepath.Join(netDir, "device")); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+	got, err := sysfsBusAddress(filepath.Join(root, "class", "net"), "eth0")
+	if err != nil {
+		t.Fatalf("sysfsBusAddress() error = %v", err)
+	}
+	if got != "0000:00:03.0" {
+		t.Fatalf("sysfsBusAddress() = %q, want 0000:00:03.0", got)
+	}
+	if _, err := sysfsBusAddress(filepath.Join(root, "class", "net"), "lo"); err == nil {
+		t.Fatal("expected error for a link without a backing device")
+	}
+}
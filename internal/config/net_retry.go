@@ -0,0 +1,76 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/user/goeth/internal/backoff"
+)
+
+// Retry controls how Apply retries after a transient netlink error, such as
+// the kernel running out of socket buffers while links churn. A retry
+// reconciles from the current state again, so what was changed before the
+// error is not changed twice.
+type Retry struct {
+	// Attempts is how often Apply retries; zero fails on the first error.
+	Attempts int
+	backoff.Policy
+}
+
+// DefaultRetry is used when NetlinkExecutor.Retry is nil.
+var DefaultRetry = Retry{Attempts: 3, Policy: backoff.Policy{Backoff: 100 * time.Millisecond, MaxBackoff: 2 * time.Second}}
+
+// transientErrors are the errnos the kernel returns for requests that may
+// succeed when sent again.
+var transientErrors = []error{unix.EBUSY, unix.ENOBUFS, unix.EAGAIN}
+
+func transient(err error) bool {
+	for _, target := range transientErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// retry runs apply until it succeeds, fails with an error that is not
+// transient, or runs out of attempts. Cancelling Context stops the waiting
+// between attempts.
+func (n NetlinkExecutor) retry(cfg Configuration, apply func() error) error {
+	policy := DefaultRetry
+	if n.Retry != nil {
+		policy = *n.Retry
+	}
+	ctx := n.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	wait := policy.Backoff
+	for attempt := 1; ; attempt++ {
+		err := apply()
+		if err == nil || attempt > policy.Attempts || !transient(err) {
+			return err
+		}
+		n.report("applying to %s failed: %v; retrying in %s (%d of %d)", cfg.Interface, err, wait, attempt, policy.Attempts)
+		if waitErr := n.wait(ctx, wait); waitErr != nil {
+			return fmt.Errorf("%w (gave up retrying: %w)", err, waitErr)
+		}
+		wait = policy.Next(wait)
+	}
+}
+
+// wait sleeps for d, through Sleep when that is set, or until ctx is done.
+func (n NetlinkExecutor) wait(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if n.Sleep != nil {
+		n.Sleep(d)
+		return ctx.Err()
+	}
+	return backoff.Wait(ctx, d)
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/user/goeth/internal/backoff"
+)
+
+// flakyProvider fails the first failures address additions with err.
+type flakyProvider struct {
+	*mockNetlinkProvider
+	failures int
+	err      error
+}
+
+func (f *flakyProvider) AddrAdd(link netlink.Link, addr *netlink.Addr) error {
+	if f.failures > 0 {
+		f.failures--
+		return f.err
+	}
+	return f.mockNetlinkProvider.AddrAdd(link, addr)
+}
+
+func TestNetlinkExecutorRetriesTransientErrors(t *testing.T) {
+	provider := &flakyProvider{mockNetlinkProvider: &mockNetlinkProvider{}, failures: 2, err: unix.ENOBUFS}
+	var waits []time.Duration
+	var notes noteCollector
+	exec := NetlinkExecutor{Provider: provider, Reporter: &notes, Sleep: func(d time.Duration) { waits = append(waits, d) }}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "192.0.2.10/24"}}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if !reflect.DeepEqual(provider.added, []string{"192.0.2.10/24"}) {
+		t.Fatalf("added %v, want the address once", provider.added)
+	}
+	if want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}; !reflect.DeepEqual(waits, want) {
+		t.Fatalf("waited %v, want %v", waits, want)
+	}
+	if len(notes) != 2 || !strings.Contains(notes[0], "add address 192.0.2.10/24: no buffer space available; retrying in 100ms (1 of 3)") {
+		t.Fatalf("unexpected notes %v", notes)
+	}
+}
+
+func TestNetlinkExecutorGivesUpRetrying(t *testing.T) {
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "192.0.2.10/24"}}}
+	provider := &flakyProvider{mockNetlinkProvider: &mockNetlinkProvider{}, failures: 5, err: unix.EAGAIN}
+	exec := NetlinkExecutor{
+		Provider: provider,
+		Reporter: &noteCollector{},
+		Sleep:    func(time.Duration) {},
+		Retry:    &Retry{Attempts: 2, Policy: backoff.Policy{Backoff: time.Second, MaxBackoff: time.Second}},
+	}
+	if err := exec.Apply(cfg); !errors.Is(err, unix.EAGAIN) || provider.failures != 2 {
+		t.Fatalf("Apply() error = %v after %d failures, want EAGAIN after 3", err, 5-provider.failures)
+	}
+
+	provider = &flakyProvider{mockNetlinkProvider: &mockNetlinkProvider{}, failures: 1, err: unix.EEXIST}
+	exec.Provider = provider
+	if err := exec.Apply(cfg); !errors.Is(err, unix.EEXIST) || len(provider.added) != 0 {
+		t.Fatalf("Apply() error = %v, want EEXIST without a retry", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	provider = &flakyProvider{mockNetlinkProvider: &mockNetlinkProvider{}, failures: 1, err: unix.EBUSY}
+	exec.Provider = provider
+	exec.Context = ctx
+	exec.Sleep = func(time.Duration) { cancel() }
+	err := exec.Apply(cfg)
+	if !errors.Is(err, unix.EBUSY) || !errors.Is(err, context.Canceled) || len(provider.added) != 0 {
+		t.Fatalf("Apply() error = %v, want EBUSY and a cancelled retry", err)
+	}
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/user/goeth/internal/ethtool"
+)
+
+// RingProvider is implemented by providers that can read and resize the
+// rings of a device. It is optional; configurations with rings require it.
+// Rings returns nil sizes when the provider cannot read them; every
+// declared ring is then set.
+type RingProvider interface {
+	Rings(name string) (current, max map[string]uint32, err error)
+	SetRings(name string, rings map[string]uint32) error
+}
+
+// CoalesceProvider is implemented by providers that can read and change the
+// interrupt coalescing of a device. It is optional; configurations with
+// coalesce require it. Like Rings, Coalesce returns nil when the provider
+// cannot read the parameters.
+type CoalesceProvider interface {
+	Coalesce(name string) (map[string]uint32, error)
+	SetCoalesce(name string, params map[string]uint32) error
+}
+
+// validateRings checks the rings and coalesce sections against the
+// parameter names of ethtool(8) -G and -C.
+func (n NetlinkExecutor) validateRings(cfg Configuration) error {
+	if len(cfg.Rings) > 0 {
+		if _, ok := n.Provider.(RingProvider); !ok {
+			return errors.New("netlink provider cannot set rings")
+		}
+	}
+	for _, ring := range sortedKeys(cfg.Rings) {
+		if !slices.Contains(ethtool.RingNames(), ring) {
+			return fmt.Errorf("ring %s for %s: want one of %s", ring, cfg.Interface, strings.Join(ethtool.RingNames(), ", "))
+		}
+		if size := cfg.Rings[ring]; size <= 0 || size > math.MaxUint32 {
+			return fmt.Errorf("ring %s for %s: size %d is out of range", ring, cfg.Interface, size)
+		}
+	}
+	if len(cfg.Coalesce) > 0 {
+		if _, ok := n.Provider.(CoalesceProvider); !ok {
+			return errors.New("netlink provider cannot set coalesce parameters")
+		}
+	}
+	for _, param := range sortedKeys(cfg.Coalesce) {
+		if !slices.Contains(ethtool.CoalesceNames(), param) {
+			return fmt.Errorf("coalesce %s for %s: want a parameter of ethtool -C, such as rx-usecs or adaptive-rx", param, cfg.Interface)
+		}
+		value := cfg.Coalesce[param]
+		if value < 0 || value > math.MaxUint32 || (strings.HasPrefix(param, "adaptive-") && value > 1) {
+			return fmt.Errorf("coalesce %s for %s: value %d is out of range", param, cfg.Interface, value)
+		}
+	}
+	return nil
+}
+
+// reconcileRings resizes the rings and changes the coalescing parameters
+// whose current value differs. Rings are checked against the maxima the
+// device reports.
+func (n NetlinkExecutor) reconcileRings(cfg Configuration) error {
+	if len(cfg.Rings) > 0 {
+		provider := n.Provider.(RingProvider)
+		current, max, err := provider.Rings(cfg.Interface)
+		if err != nil {
+			return fmt.Errorf("read rings of %s: %w", cfg.Interface, err)
+		}
+		for _, ring := range sortedKeys(cfg.Rings) {
+			if size := uint32(cfg.Rings[ring]); max != nil && size > max[ring] {
+				if max[ring] == 0 {
+					return fmt.Errorf("ring %s for %s: the device has no such ring", ring, cfg.Interface)
+				}
+				return fmt.Errorf("ring %s for %s: size %d exceeds the maximum %d", ring, cfg.Interface, size, max[ring])
+			}
+		}
+		if changes := differing(current, cfg.Rings); len(changes) > 0 {
+			if err := provider.SetRings(cfg.Interface, changes); err != nil {
+				return fmt.Errorf("set rings of %s: %w", cfg.Interface, err)
+			}
+		}
+	}
+	if len(cfg.Coalesce) > 0 {
+		provider := n.Provider.(CoalesceProvider)
+		current, err := provider.Coalesce(cfg.Interface)
+		if err != nil {
+			return fmt.Errorf("read coalesce parameters of %s: %w", cfg.Interface, err)
+		}
+		if changes := differing(current, cfg.Coalesce); len(changes) > 0 {
+			if err := provider.SetCoalesce(cfg.Interface, changes); err != nil {
+				return fmt.Errorf("set coalesce parameters of %s: %w", cfg.Interface, err)
+			}
+		}
+	}
+	return nil
+}
+
+// differing returns the declared values that differ from current, or all of
+// them when current is unknown.
+func differing(current map[string]uint32, declared map[string]int) map[string]uint32 {
+	changes := make(map[string]uint32)
+	for name, value := range declared {
+		if have, ok := current[name]; current == nil || !ok || have != uint32(value) {
+			changes[name] = uint32(value)
+		}
+	}
+	return changes
+}
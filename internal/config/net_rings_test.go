@@ -0,0 +1,94 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ringProvider keeps ring sizes and coalescing parameters in memory.
+type ringProvider struct {
+	*mockNetlinkProvider
+	rings, max, coalesce map[string]uint32
+	set                  []map[string]uint32
+}
+
+func (r *ringProvider) Rings(name string) (map[string]uint32, map[string]uint32, error) {
+	return r.rings, r.max, nil
+}
+
+func (r *ringProvider) SetRings(name string, rings map[string]uint32) error {
+	for ring, size := range rings {
+		r.rings[ring] = size
+	}
+	r.set = append(r.set, rings)
+	return nil
+}
+
+func (r *ringProvider) Coalesce(name string) (map[string]uint32, error) {
+	return r.coalesce, nil
+}
+
+func (r *ringProvider) SetCoalesce(name string, params map[string]uint32) error {
+	for param, value := range params {
+		r.coalesce[param] = value
+	}
+	r.set = append(r.set, params)
+	return nil
+}
+
+func newRingProvider() *ringProvider {
+	return &ringProvider{
+		mockNetlinkProvider: &mockNetlinkProvider{},
+		rings:               map[string]uint32{"rx": 512, "rx-mini": 0, "rx-jumbo": 0, "tx": 512},
+		max:                 map[string]uint32{"rx": 4096, "rx-mini": 0, "rx-jumbo": 0, "tx": 4096},
+		coalesce:            map[string]uint32{"rx-usecs": 3, "tx-usecs": 0, "adaptive-rx": 1},
+	}
+}
+
+func TestNetlinkExecutorSetsRingsAndCoalesce(t *testing.T) {
+	provider := newRingProvider()
+	cfg := Configuration{
+		Interface: "eth0",
+		Rings:     map[string]int{"rx": 4096, "tx": 512},
+		Coalesce:  map[string]int{"rx-usecs": 50, "tx-usecs": 0, "adaptive-rx": 0},
+	}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []map[string]uint32{{"rx": 4096}, {"rx-usecs": 50, "adaptive-rx": 0}}
+	if !reflect.DeepEqual(provider.set, want) {
+		t.Fatalf("set %v, want %v", provider.set, want)
+	}
+
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil || len(provider.set) != 2 {
+		t.Fatalf("second Apply() = %v, set %v; want no change", err, provider.set)
+	}
+}
+
+func TestNetlinkExecutorChecksRings(t *testing.T) {
+	tests := []struct {
+		cfg  Configuration
+		want string
+	}{
+		{Configuration{Rings: map[string]int{"rx": 8192}}, "size 8192 exceeds the maximum 4096"},
+		{Configuration{Rings: map[string]int{"rx-mini": 64}}, "the device has no such ring"},
+		{Configuration{Rings: map[string]int{"rx": 0}}, "size 0 is out of range"},
+		{Configuration{Rings: map[string]int{"rx-queue": 64}}, "want one of rx, rx-mini, rx-jumbo, tx"},
+		{Configuration{Coalesce: map[string]int{"rx_usecs": 64}}, "want a parameter of ethtool -C"},
+		{Configuration{Coalesce: map[string]int{"adaptive-tx": 2}}, "value 2 is out of range"},
+	}
+	for _, tt := range tests {
+		tt.cfg.Interface = "eth0"
+		err := NetlinkExecutor{Provider: newRingProvider()}.Apply(tt.cfg)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("Apply(%v %v) error = %v, want %q", tt.cfg.Rings, tt.cfg.Coalesce, err, tt.want)
+		}
+	}
+
+	cfg := Configuration{Interface: "eth0", Coalesce: map[string]int{"rx-usecs": 50}}
+	err := NetlinkExecutor{Provider: &mockNetlinkProvider{}}.Apply(cfg)
+	if err == nil || !strings.Contains(err.Error(), "cannot set coalesce parameters") {
+		t.Fatalf("expected an unsupported provider error, got %v", err)
+	}
+}
@@ -0,0 +1,448 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"net"
+	"slices"
+	"strconv"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// RouteProtocol is the rtm_protocol value goeth installs its routes with.
+// Only routes carrying it are ever replaced or deleted, so routes owned by
+// routing daemons such as FRR or bird, or by systemd-networkd, are left alone.
+// The value is unassigned; name it in /etc/iproute2/rt_protos.d to have
+// `ip route` print "proto goeth".
+const RouteProtocol netlink.RouteProtocol = 245
+
+// defaultDestination is accepted in place of 0.0.0.0/0 or ::/0.
+const defaultDestination = "default"
+
+const (
+	// maxNexthopWeight is the largest weight of a next hop.
+	maxNexthopWeight = 256
+	// ipv6DefaultMetric is the metric of IPv6 routes added without one
+	// (IP6_RT_PRIO_USER).
+	ipv6DefaultMetric = 1024
+	// maxRouteProtocol is the largest rtm_protocol value.
+	maxRouteProtocol = math.MaxUint8
+)
+
+// reconcileRoutes makes the goeth-owned routes through link in the tables
+// of p, and when it declares routes in the main table, match p.routes.
+// Routes of any other protocol are never modified; those that are not
+// created by the kernel itself are reported. Without routes the routing
+// tables are left alone entirely.
+func (n NetlinkExecutor) reconcileRoutes(link netlink.Link, p plan) error {
+	desired := p.routes
+	if desired == nil {
+		return nil
+	}
+	listed := slices.Sorted(maps.Keys(p.tables))
+	if p.mainRoutes {
+		listed = append([]int{unix.RT_TABLE_MAIN}, listed...)
+	}
+	// Special routes have no link: a configuration owns those of its own
+	// tables and, in main, only while it declares special routes there.
+	specialTables := make(map[int]bool)
+	maps.Copy(specialTables, p.tables)
+	for _, route := range desired {
+		if special(*route) {
+			specialTables[tableOf(*route)] = true
+		}
+	}
+	owned := make(map[string]netlink.Route)
+	foreign := make(map[string]netlink.Route)
+	for _, table := range listed {
+		for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+			list, err := n.listRoutes(link, family, table)
+			if err != nil {
+				return fmt.Errorf("list routes for family %d: %w", family, err)
+			}
+			for _, route := range list {
+				key := routeKey(route)
+				want, declared := desired[key]
+				if special(route) && !declared && !specialTables[tableOf(route)] {
+					continue
+				}
+				if !declared && len(route.MultiPath) > 0 && route.LinkIndex != link.Attrs().Index {
+					// Pruned only through the link of its first next
+					// hop, so the configurations of its other links
+					// leave it alone.
+					continue
+				}
+				if route.Protocol == RouteProtocol || declared && route.Protocol == want.Protocol {
+					owned[key] = route
+				} else {
+					foreign[key] = route
+				}
+			}
+		}
+	}
+	name := link.Attrs().Name
+	for _, key := range sortedKeys(desired) {
+		want := desired[key]
+		want.LinkIndex = link.Attrs().Index
+		if err := n.resolveNexthops(link, key, want, p.hopDevices[key]); err != nil {
+			return err
+		}
+		if have, ok := owned[key]; ok {
+			if err := n.updateRoute(key, have, want); err != nil {
+				return err
+			}
+			continue
+		}
+		if have, ok := foreign[key]; ok {
+			n.report("route %s on %s is owned by proto %s; not replaced", key, name, have.Protocol)
+			continue
+		}
+		if err := n.Provider.RouteAdd(want); err != nil {
+			return fmt.Errorf("add route %s: %w", key, err)
+		}
+	}
+	for _, key := range sortedKeys(owned) {
+		have := owned[key]
+		if _, ok := desired[key]; ok {
+			continue
+		}
+		if err := n.Provider.RouteDel(&have); err != nil {
+			return fmt.Errorf("remove route %s: %w", key, err)
+		}
+	}
+	for _, key := range sortedKeys(foreign) {
+		have := foreign[key]
+		if _, ok := desired[key]; ok || have.Protocol == unix.RTPROT_KERNEL {
+			continue
+		}
+		n.report("foreign route %s on %s (proto %s) left in place", key, name, have.Protocol)
+	}
+	return nil
+}
+
+// updateRoute brings the owned route have in line with want. The kernel
+// tells routes to the same destination apart by metric, so a new metric is
+// added as a route of its own before the old one is removed.
+func (n NetlinkExecutor) updateRoute(key string, have netlink.Route, want *netlink.Route) error {
+	if metric(have) != metric(*want) {
+		if err := n.Provider.RouteAdd(want); err != nil {
+			return fmt.Errorf("add route %s with metric %d: %w", key, metric(*want), err)
+		}
+		if err := n.Provider.RouteDel(&have); err != nil {
+			return fmt.Errorf("remove route %s with metric %d: %w", key, metric(have), err)
+		}
+		return nil
+	}
+	if sameNexthops(have, *want) && have.Protocol == want.Protocol && routeType(have) == routeType(*want) {
+		return nil
+	}
+	if err := n.Provider.RouteReplace(want); err != nil {
+		return fmt.Errorf("replace route %s: %w", key, err)
+	}
+	return nil
+}
+
+// metric is the priority of route, as the kernel fills it in for IPv6
+// routes added without one.
+func metric(route netlink.Route) int {
+	if route.Priority == 0 && route.Family == netlink.FAMILY_V6 {
+		return ipv6DefaultMetric
+	}
+	return route.Priority
+}
+
+// routeTypes names the route types a configuration can declare, as
+// ip-route(8) spells them.
+var routeTypes = map[string]int{
+	"unicast":     unix.RTN_UNICAST,
+	"blackhole":   unix.RTN_BLACKHOLE,
+	"unreachable": unix.RTN_UNREACHABLE,
+	"prohibit":    unix.RTN_PROHIBIT,
+}
+
+// ParseRouteType parses the type of a declared route. Empty is unicast.
+func ParseRouteType(name string) (int, error) {
+	if name == "" {
+		return unix.RTN_UNICAST, nil
+	}
+	typ, ok := routeTypes[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown route type %q", name)
+	}
+	return typ, nil
+}
+
+// RouteTypeName names a route type the way ParseRouteType takes it, and is
+// empty for unicast routes.
+func RouteTypeName(typ int) string {
+	if typ == unix.RTN_UNSPEC || typ == unix.RTN_UNICAST {
+		return ""
+	}
+	for name, candidate := range routeTypes {
+		if candidate == typ {
+			return name
+		}
+	}
+	return strconv.Itoa(typ)
+}
+
+// routeType is the type of route, where the zero value is unicast.
+func routeType(route netlink.Route) int {
+	if route.Type == unix.RTN_UNSPEC {
+		return unix.RTN_UNICAST
+	}
+	return route.Type
+}
+
+// special reports whether route drops its traffic rather than forwarding
+// it, such as a blackhole route.
+func special(route netlink.Route) bool {
+	return routeType(route) != unix.RTN_UNICAST
+}
+
+// tableOf is the table of route, with main as RT_TABLE_MAIN.
+func tableOf(route netlink.Route) int {
+	if mainTable(route.Table) {
+		return unix.RT_TABLE_MAIN
+	}
+	return route.Table
+}
+
+// parseRouteProtocol parses the protocol of a declared route, by number or
+// by the name ip-route(8) prints. Empty is RouteProtocol.
+func parseRouteProtocol(raw string) (netlink.RouteProtocol, error) {
+	if raw == "" {
+		return RouteProtocol, nil
+	}
+	proto := netlink.RouteProtocol(maxRouteProtocol + 1)
+	if value, err := strconv.ParseUint(raw, 10, 8); err == nil {
+		proto = netlink.RouteProtocol(value)
+	} else if raw == "goeth" {
+		proto = RouteProtocol
+	} else {
+		for candidate := netlink.RouteProtocol(0); candidate <= maxRouteProtocol; candidate++ {
+			if candidate.String() == raw {
+				proto = candidate
+				break
+			}
+		}
+	}
+	switch {
+	case proto > maxRouteProtocol:
+		return 0, fmt.Errorf("unknown route protocol %q", raw)
+	case proto <= unix.RTPROT_KERNEL:
+		return 0, fmt.Errorf("route protocol %s is reserved for the kernel", raw)
+	}
+	return proto, nil
+}
+
+// parseDesiredRoutes parses the routes through iface. It also returns, by
+// route key, the interface of each next hop of multipath routes, empty for
+// iface.
+func parseDesiredRoutes(raw []Route, defaultFamily int, iface string) (map[string]*netlink.Route, map[string][]string, error) {
+	if raw == nil {
+		return nil, nil, nil
+	}
+	desired := make(map[string]*netlink.Route, len(raw))
+	devices := make(map[string][]string)
+	for _, entry := range raw {
+		if entry.TTL != 0 {
+			return nil, nil, fmt.Errorf("route %s: ttl is not supported for routes, only for addresses", entry.Destination)
+		}
+		typ, err := ParseRouteType(entry.Type)
+		if err != nil {
+			return nil, nil, fmt.Errorf("route %s: %w", entry.Destination, err)
+		}
+		if typ != unix.RTN_UNICAST && (entry.Gateway != "" || len(entry.Nexthops) > 0) {
+			return nil, nil, fmt.Errorf("route %s: a %s route cannot have a gateway or next hops", entry.Destination, entry.Type)
+		}
+		var gw net.IP
+		if entry.Gateway != "" {
+			if gw = net.ParseIP(entry.Gateway); gw == nil {
+				return nil, nil, fmt.Errorf("route %s: invalid gateway %q", entry.Destination, entry.Gateway)
+			}
+			if len(entry.Nexthops) > 0 {
+				return nil, nil, fmt.Errorf("route %s: gateway and nexthops cannot both be set", entry.Destination)
+			}
+		}
+		hops, hopDevices, err := parseNexthops(entry, iface)
+		if err != nil {
+			return nil, nil, err
+		}
+		gateways := []net.IP{gw}
+		if len(hops) > 0 {
+			gateways = gateways[:0]
+			for _, hop := range hops {
+				gateways = append(gateways, hop.Gw)
+			}
+		}
+		dst, err := parseDestination(entry.Destination, gateways[0], defaultFamily)
+		if err != nil {
+			return nil, nil, err
+		}
+		family := netlink.FAMILY_V6
+		if dst.IP.To4() != nil {
+			family = netlink.FAMILY_V4
+		}
+		for _, gw := range gateways {
+			if gw != nil && (gw.To4() != nil) != (family == netlink.FAMILY_V4) {
+				return nil, nil, fmt.Errorf("route %s: gateway %s is from another address family", dst, gw)
+			}
+		}
+		if entry.Table != 0 {
+			if err := validateTable(entry.Table); err != nil {
+				return nil, nil, fmt.Errorf("route %s: %w", dst, err)
+			}
+		}
+		if entry.Metric < 0 || entry.Metric > math.MaxUint32 {
+			return nil, nil, fmt.Errorf("route %s: metric %d is not between 0 and %d", dst, entry.Metric, uint32(math.MaxUint32))
+		}
+		proto, err := parseRouteProtocol(entry.Protocol)
+		if err != nil {
+			return nil, nil, fmt.Errorf("route %s: %w", dst, err)
+		}
+		route := &netlink.Route{Family: family, Dst: dst, Gw: gw, MultiPath: hops, Priority: entry.Metric, Protocol: proto, Type: typ}
+		if !mainTable(entry.Table) {
+			route.Table = entry.Table
+		}
+		if gateways[0] == nil && !special(*route) {
+			route.Scope = netlink.SCOPE_LINK
+		}
+		key := routeKey(*route)
+		if _, dup := desired[key]; dup {
+			return nil, nil, fmt.Errorf("route %s is declared more than once", key)
+		}
+		desired[key] = route
+		if len(hops) > 0 {
+			devices[key] = hopDevices
+		}
+	}
+	return desired, devices, nil
+}
+
+// parseNexthops parses the next hops of a multipath route through iface.
+// Those through iface come first, as the kernel takes the output link of
+// the route from the first one. The interface of each, empty for iface, is
+// returned alongside.
+func parseNexthops(entry Route, iface string) ([]*netlink.NexthopInfo, []string, error) {
+	if len(entry.Nexthops) == 0 {
+		return nil, nil, nil
+	}
+	var local, other []*netlink.NexthopInfo
+	var otherDevices []string
+	seen := make(map[string]bool)
+	for _, raw := range entry.Nexthops {
+		gw := net.ParseIP(raw.Gateway)
+		if gw == nil {
+			return nil, nil, fmt.Errorf("route %s: invalid next hop gateway %q", entry.Destination, raw.Gateway)
+		}
+		weight := raw.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		if weight < 1 || weight > maxNexthopWeight {
+			return nil, nil, fmt.Errorf("route %s: next hop %s: weight %d is not between 1 and %d", entry.Destination, gw, raw.Weight, maxNexthopWeight)
+		}
+		device := raw.Interface
+		if device == iface {
+			device = ""
+		}
+		id := gw.String() + " " + device
+		if seen[id] {
+			return nil, nil, fmt.Errorf("route %s: next hop %s is declared more than once", entry.Destination, gw)
+		}
+		seen[id] = true
+		// rtnh_hops holds the weight minus one.
+		hop := &netlink.NexthopInfo{Gw: gw, Hops: weight - 1}
+		if device == "" {
+			local = append(local, hop)
+		} else {
+			other = append(other, hop)
+			otherDevices = append(otherDevices, device)
+		}
+	}
+	if len(local) == 0 {
+		return nil, nil, fmt.Errorf("route %s: no next hop is through %s", entry.Destination, iface)
+	}
+	return append(local, other...), append(make([]string, len(local)), otherDevices...), nil
+}
+
+// resolveNexthops sets the link of each next hop of want, looking up the
+// interfaces in devices by name.
+func (n NetlinkExecutor) resolveNexthops(link netlink.Link, key string, want *netlink.Route, devices []string) error {
+	for i, hop := range want.MultiPath {
+		hop.LinkIndex = link.Attrs().Index
+		if devices[i] == "" {
+			continue
+		}
+		dev, err := n.Provider.LinkByName(devices[i])
+		if err != nil {
+			return fmt.Errorf("route %s: next hop %s: %w", key, hop.Gw, err)
+		}
+		hop.LinkIndex = dev.Attrs().Index
+	}
+	return nil
+}
+
+// sameNexthops reports whether have forwards like want: through the same
+// gateway, or through the same next hops with the same weights in any
+// order.
+func sameNexthops(have, want netlink.Route) bool {
+	if len(want.MultiPath) == 0 {
+		return len(have.MultiPath) == 0 && have.Gw.Equal(want.Gw)
+	}
+	return slices.Equal(nexthopSet(have), nexthopSet(want))
+}
+
+func nexthopSet(route netlink.Route) []string {
+	var set []string
+	for _, hop := range route.MultiPath {
+		set = append(set, fmt.Sprintf("%s@%d*%d", hop.Gw, hop.LinkIndex, hop.Hops))
+	}
+	slices.Sort(set)
+	return set
+}
+
+// parseDestination parses a CIDR or "default". The family of a default
+// destination follows the gateway and is defaultFamily without one.
+func parseDestination(raw string, gw net.IP, defaultFamily int) (*net.IPNet, error) {
+	if raw == defaultDestination {
+		if gw != nil && gw.To4() == nil || gw == nil && defaultFamily == netlink.FAMILY_V6 {
+			return &net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 8*net.IPv6len)}, nil
+		}
+		return &net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 8*net.IPv4len)}, nil
+	}
+	_, dst, err := net.ParseCIDR(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse route destination %q: %w", raw, err)
+	}
+	return dst, nil
+}
+
+// routeKey identifies a route by destination and, outside the main table,
+// by table. The kernel reports default routes without a destination, so
+// those are keyed by family instead.
+func routeKey(route netlink.Route) string {
+	key := "0.0.0.0/0"
+	switch {
+	case route.Dst != nil:
+		key = route.Dst.String()
+	case route.Family == netlink.FAMILY_V6:
+		key = "::/0"
+	}
+	if !mainTable(route.Table) {
+		key += fmt.Sprintf(" table %d", route.Table)
+	}
+	return key
+}
+
+// listRoutes lists the routes through link in table.
+func (n NetlinkExecutor) listRoutes(link netlink.Link, family, table int) ([]netlink.Route, error) {
+	if mainTable(table) {
+		return n.Provider.RouteList(link, family)
+	}
+	return n.Provider.(RuleProvider).RouteListTable(link, family, table)
+}
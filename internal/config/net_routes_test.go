@@ -0,0 +1,335 @@
+package config
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+type reportMockProvider struct {
+	*mockNetlinkProvider
+	notes []string
+}
+
+func (r *reportMockProvider) Report(note string) {
+	r.notes = append(r.notes, note)
+}
+
+func route(t *testing.T, dst, gw string, proto netlink.RouteProtocol) netlink.Route {
+	t.Helper()
+	r := netlink.Route{Family: netlink.FAMILY_V4, Protocol: proto, Gw: net.ParseIP(gw)}
+	if dst != "" {
+		_, ipnet, err := net.ParseCIDR(dst)
+		if err != nil {
+			t.Fatalf("ParseCIDR(%s) error = %v", dst, err)
+		}
+		r.Dst = ipnet
+	}
+	return r
+}
+
+func TestNetlinkExecutorReconcilesOwnedRoutesOnly(t *testing.T) {
+	provider := &reportMockProvider{mockNetlinkProvider: &mockNetlinkProvider{
+		link: &fakeLink{netlink.LinkAttrs{Name: "eth0", Index: 2}},
+		routes: map[int][]netlink.Route{netlink.FAMILY_V4: {
+			route(t, "", "192.0.2.1", RouteProtocol),
+			route(t, "198.51.100.0/24", "192.0.2.1", RouteProtocol),
+			route(t, "203.0.113.0/24", "192.0.2.1", RouteProtocol),
+			route(t, "10.0.0.0/8", "192.0.2.254", unix.RTPROT_BGP),
+			route(t, "10.1.0.0/16", "192.0.2.253", unix.RTPROT_BGP),
+			route(t, "192.0.2.0/24", "", unix.RTPROT_KERNEL),
+		}},
+	}}
+	exec := NetlinkExecutor{Provider: provider}
+	cfg := Configuration{Interface: "eth0", Routes: []Route{
+		{Destination: "default", Gateway: "192.0.2.1"},
+		{Destination: "198.51.100.0/24", Gateway: "192.0.2.2"},
+		{Destination: "10.0.0.0/8", Gateway: "192.0.2.1"},
+		{Destination: "172.16.0.0/12", Gateway: "192.0.2.1"},
+	}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if !reflect.DeepEqual(provider.routeAdded, []string{"172.16.0.0/12"}) {
+		t.Fatalf("unexpected added routes: %v", provider.routeAdded)
+	}
+	if !reflect.DeepEqual(provider.routeReplaced, []string{"198.51.100.0/24"}) {
+		t.Fatalf("unexpected replaced routes: %v", provider.routeReplaced)
+	}
+	if !reflect.DeepEqual(provider.routeRemoved, []string{"203.0.113.0/24"}) {
+		t.Fatalf("foreign routes must never be removed, got %v", provider.routeRemoved)
+	}
+	wantNotes := []string{
+		"route 10.0.0.0/8 on eth0 is owned by proto bgp; not replaced",
+		"foreign route 10.1.0.0/16 on eth0 (proto bgp) left in place",
+	}
+	if !reflect.DeepEqual(provider.notes, wantNotes) {
+		t.Fatalf("notes = %#v, want %#v", provider.notes, wantNotes)
+	}
+}
+
+func TestNetlinkExecutorLeavesRoutesWithoutSection(t *testing.T) {
+	provider := &mockNetlinkProvider{
+		routes: map[int][]netlink.Route{netlink.FAMILY_V4: {route(t, "203.0.113.0/24", "192.0.2.1", RouteProtocol)}},
+	}
+	cfg := Configuration{Interface: "eth0", Addresses: []Address{{CIDR: "192.0.2.10/24"}}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.routeRemoved) != 0 {
+		t.Fatalf("expected routes to be left alone, removed %v", provider.routeRemoved)
+	}
+}
+
+func TestNetlinkExecutorEmptyRoutesRemovesOwned(t *testing.T) {
+	provider := &mockNetlinkProvider{
+		routes: map[int][]netlink.Route{netlink.FAMILY_V4: {route(t, "203.0.113.0/24", "192.0.2.1", RouteProtocol)}},
+	}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(Configuration{Interface: "eth0", Routes: []Route{}}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"203.0.113.0/24"}; !reflect.DeepEqual(provider.routeRemoved, want) {
+		t.Fatalf("removed %v, want %v", provider.routeRemoved, want)
+	}
+}
+
+func TestParseDesiredRoutes(t *testing.T) {
+	desired, _, err := parseDesiredRoutes([]Route{
+		{Destination: "default", Gateway: "2001:db8::1"},
+		{Destination: "192.0.2.128/25"},
+	}, netlink.FAMILY_V4, "eth0")
+	if err != nil {
+		t.Fatalf("parseDesiredRoutes() error = %v", err)
+	}
+	if v6 := desired["::/0"]; v6 == nil || v6.Family != netlink.FAMILY_V6 || v6.Protocol != RouteProtocol {
+		t.Fatalf("unexpected default route %#v", v6)
+	}
+	if link := desired["192.0.2.128/25"]; link == nil || link.Scope != netlink.SCOPE_LINK {
+		t.Fatalf("expected link scope without gateway, got %#v", link)
+	}
+	cases := map[string][]Route{
+		"bad destination": {{Destination: "192.0.2.0"}},
+		"bad gateway":     {{Destination: "default", Gateway: "gw"}},
+		"mixed families":  {{Destination: "192.0.2.0/24", Gateway: "2001:db8::1"}},
+		"duplicate":       {{Destination: "default", Gateway: "192.0.2.1"}, {Destination: "0.0.0.0/0"}},
+		"ttl":             {{Destination: "198.51.100.0/24", Gateway: "192.0.2.1", TTL: Duration(time.Hour)}},
+	}
+	for name, routes := range cases {
+		if _, _, err := parseDesiredRoutes(routes, netlink.FAMILY_V4, "eth0"); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+}
+
+// multipathProvider keeps the routes it is asked to replace.
+type multipathProvider struct {
+	*mockNetlinkProvider
+	replaced []*netlink.Route
+}
+
+func (m *multipathProvider) RouteReplace(route *netlink.Route) error {
+	m.replaced = append(m.replaced, route)
+	return nil
+}
+
+func TestNetlinkExecutorProgramsMultipathRoute(t *testing.T) {
+	eth0 := &fakeLink{netlink.LinkAttrs{Name: "eth0", Index: 2}}
+	eth1 := &fakeLink{netlink.LinkAttrs{Name: "eth1", Index: 3}}
+	provider := &multipathProvider{mockNetlinkProvider: &mockNetlinkProvider{
+		byName: map[string]netlink.Link{"eth0": eth0, "eth1": eth1},
+		routes: map[int][]netlink.Route{netlink.FAMILY_V4: {route(t, "", "192.0.2.1", RouteProtocol)}},
+	}}
+	cfg := Configuration{Interface: "eth0", Routes: []Route{{Destination: "default", Nexthops: []Nexthop{
+		{Gateway: "198.51.100.1", Interface: "eth1", Weight: 3},
+		{Gateway: "192.0.2.1"},
+	}}}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.replaced) != 1 {
+		t.Fatalf("replaced %d routes, want the single-path default route replaced", len(provider.replaced))
+	}
+	want := []string{"192.0.2.1@2*0", "198.51.100.1@3*2"}
+	if got := provider.replaced[0]; got.Gw != nil || got.MultiPath[0].LinkIndex != eth0.Index || !reflect.DeepEqual(nexthopSet(*got), want) {
+		t.Fatalf("replaced with %v, want next hops %v with the one on eth0 first", got.MultiPath, want)
+	}
+
+	// The kernel may list the next hops in another order.
+	listed := route(t, "", "", RouteProtocol)
+	listed.MultiPath = []*netlink.NexthopInfo{{LinkIndex: 3, Gw: net.ParseIP("198.51.100.1"), Hops: 2}, {LinkIndex: 2, Gw: net.ParseIP("192.0.2.1")}}
+	provider.routes[netlink.FAMILY_V4] = []netlink.Route{listed}
+	provider.replaced = nil
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.replaced) != 0 || len(provider.routeAdded) != 0 || len(provider.routeRemoved) != 0 {
+		t.Fatalf("replaced %v, added %v, removed %v; want the multipath route left alone", provider.replaced, provider.routeAdded, provider.routeRemoved)
+	}
+}
+
+func TestRoutesThroughListsEveryNexthopLink(t *testing.T) {
+	single := route(t, "203.0.113.0/24", "192.0.2.1", RouteProtocol)
+	single.LinkIndex = 2
+	blackhole := route(t, "198.51.100.0/24", "", RouteProtocol)
+	blackhole.Type = unix.RTN_BLACKHOLE
+	multi := route(t, "", "", RouteProtocol)
+	multi.MultiPath = []*netlink.NexthopInfo{{LinkIndex: 3, Gw: net.ParseIP("198.51.100.1")}, {LinkIndex: 2, Gw: net.ParseIP("192.0.2.1")}}
+	routes := []netlink.Route{single, blackhole, multi}
+
+	for _, tc := range []struct {
+		index int
+		want  []string
+	}{
+		{2, []string{"203.0.113.0/24", "198.51.100.0/24", "0.0.0.0/0"}},
+		{3, []string{"198.51.100.0/24", "0.0.0.0/0"}},
+		{4, []string{"198.51.100.0/24"}},
+	} {
+		var got []string
+		for _, r := range routesThrough(routes, tc.index) {
+			got = append(got, routeKey(r))
+			if len(r.MultiPath) > 0 && r.LinkIndex != 3 {
+				t.Errorf("link %d: multipath route has link %d, want that of its first next hop", tc.index, r.LinkIndex)
+			}
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("routesThrough(%d) = %v, want %v", tc.index, got, tc.want)
+		}
+	}
+}
+
+func TestNetlinkAPIRouteListWithoutLink(t *testing.T) {
+	if _, err := (NetlinkAPI{}).RouteList(nil, netlink.FAMILY_ALL); err != nil {
+		t.Fatalf("RouteList(nil) error = %v", err)
+	}
+}
+
+func TestNetlinkExecutorLeavesMultipathRouteToItsFirstLink(t *testing.T) {
+	listed := route(t, "", "", RouteProtocol)
+	listed.LinkIndex = 3
+	listed.MultiPath = []*netlink.NexthopInfo{{LinkIndex: 3, Gw: net.ParseIP("198.51.100.1")}, {LinkIndex: 2, Gw: net.ParseIP("192.0.2.1")}}
+	provider := &mockNetlinkProvider{
+		link:   &fakeLink{netlink.LinkAttrs{Name: "eth0", Index: 2}},
+		routes: map[int][]netlink.Route{netlink.FAMILY_V4: {listed}},
+	}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(Configuration{Interface: "eth0", Routes: []Route{}}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.routeRemoved) != 0 {
+		t.Fatalf("removed %v, want the route left to the link of its first next hop", provider.routeRemoved)
+	}
+}
+
+func TestParseNexthopsRejects(t *testing.T) {
+	cases := map[string]Route{
+		"gateway and nexthops": {Destination: "default", Gateway: "192.0.2.1", Nexthops: []Nexthop{{Gateway: "192.0.2.2"}}},
+		"no local next hop":    {Destination: "default", Nexthops: []Nexthop{{Gateway: "192.0.2.2", Interface: "eth1"}}},
+		"weight":               {Destination: "default", Nexthops: []Nexthop{{Gateway: "192.0.2.2", Weight: 257}}},
+		"duplicate":            {Destination: "default", Nexthops: []Nexthop{{Gateway: "192.0.2.2"}, {Gateway: "192.0.2.2", Interface: "eth0"}}},
+		"mixed families":       {Destination: "default", Nexthops: []Nexthop{{Gateway: "192.0.2.2"}, {Gateway: "2001:db8::1"}}},
+		"bad gateway":          {Destination: "default", Nexthops: []Nexthop{{Gateway: "gw"}}},
+	}
+	for name, entry := range cases {
+		if _, _, err := parseDesiredRoutes([]Route{entry}, netlink.FAMILY_V4, "eth0"); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestNetlinkExecutorUpdatesRouteMetric(t *testing.T) {
+	metered := route(t, "203.0.113.0/24", "192.0.2.1", RouteProtocol)
+	metered.Priority = 100
+	provider := &mockNetlinkProvider{routes: map[int][]netlink.Route{netlink.FAMILY_V4: {
+		metered,
+		route(t, "198.51.100.0/24", "192.0.2.1", unix.RTPROT_STATIC),
+	}}}
+	cfg := Configuration{Interface: "eth0", Routes: []Route{
+		{Destination: "203.0.113.0/24", Gateway: "192.0.2.1", Metric: 200},
+		{Destination: "198.51.100.0/24", Gateway: "192.0.2.1", Protocol: "static"},
+	}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"203.0.113.0/24"}; !reflect.DeepEqual(provider.routeAdded, want) || !reflect.DeepEqual(provider.routeRemoved, want) {
+		t.Fatalf("added %v, removed %v; want the new metric added before the old one is removed", provider.routeAdded, provider.routeRemoved)
+	}
+	if len(provider.routeReplaced) != 0 {
+		t.Fatalf("replaced %v, want the static route of the declared protocol left alone", provider.routeReplaced)
+	}
+}
+
+func TestMetricDefaultsForIPv6(t *testing.T) {
+	if got := metric(netlink.Route{Family: netlink.FAMILY_V6}); got != ipv6DefaultMetric {
+		t.Fatalf("metric() = %d, want %d", got, ipv6DefaultMetric)
+	}
+	if got := metric(netlink.Route{Family: netlink.FAMILY_V4}); got != 0 {
+		t.Fatalf("metric() = %d, want 0", got)
+	}
+}
+
+func TestParseRouteProtocol(t *testing.T) {
+	for raw, want := range map[string]netlink.RouteProtocol{"": RouteProtocol, "goeth": RouteProtocol, "static": unix.RTPROT_STATIC, "boot": unix.RTPROT_BOOT, "186": unix.RTPROT_BGP} {
+		if got, err := parseRouteProtocol(raw); err != nil || got != want {
+			t.Fatalf("parseRouteProtocol(%q) = %v, %v; want %v", raw, got, err, want)
+		}
+	}
+	for _, raw := range []string{"kernel", "2", "256", "quagga"} {
+		if _, err := parseRouteProtocol(raw); err == nil {
+			t.Fatalf("parseRouteProtocol(%q) expected an error", raw)
+		}
+	}
+}
+
+func TestNetlinkExecutorReconcilesSpecialRoutes(t *testing.T) {
+	blackhole := func(dst string) netlink.Route {
+		r := route(t, dst, "", RouteProtocol)
+		r.Type = unix.RTN_BLACKHOLE
+		return r
+	}
+	listed := map[int][]netlink.Route{netlink.FAMILY_V4: {
+		blackhole("203.0.113.0/24"),
+		blackhole("198.51.100.0/24"),
+		route(t, "192.0.2.128/25", "192.0.2.1", RouteProtocol),
+	}}
+	provider := &mockNetlinkProvider{routes: listed}
+	cfg := Configuration{Interface: "eth0", Routes: []Route{{Destination: "192.0.2.128/25", Type: "unreachable"}}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"192.0.2.128/25"}; !reflect.DeepEqual(provider.routeReplaced, want) {
+		t.Fatalf("replaced %v, want %v", provider.routeReplaced, want)
+	}
+	if want := []string{"198.51.100.0/24", "203.0.113.0/24"}; !reflect.DeepEqual(provider.routeRemoved, want) {
+		t.Fatalf("removed %v, want the undeclared blackhole routes", provider.routeRemoved)
+	}
+
+	provider = &mockNetlinkProvider{routes: listed}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(Configuration{Interface: "eth0", Routes: []Route{}}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"192.0.2.128/25"}; !reflect.DeepEqual(provider.routeRemoved, want) {
+		t.Fatalf("removed %v, want blackhole routes left to the configuration declaring them", provider.routeRemoved)
+	}
+}
+
+func TestParseDesiredRoutesSpecialType(t *testing.T) {
+	desired, _, err := parseDesiredRoutes([]Route{{Destination: "203.0.113.0/24", Type: "blackhole"}}, netlink.FAMILY_V4, "eth0")
+	if err != nil {
+		t.Fatalf("parseDesiredRoutes() error = %v", err)
+	}
+	if got := desired["203.0.113.0/24"]; got == nil || got.Type != unix.RTN_BLACKHOLE || got.Scope != netlink.SCOPE_UNIVERSE {
+		t.Fatalf("desired = %+v, want a blackhole route of universe scope", got)
+	}
+	for name, entry := range map[string]Route{
+		"unknown type": {Destination: "203.0.113.0/24", Type: "drop"},
+		"gateway":      {Destination: "203.0.113.0/24", Type: "prohibit", Gateway: "192.0.2.1"},
+		"nexthops":     {Destination: "203.0.113.0/24", Type: "blackhole", Nexthops: []Nexthop{{Gateway: "192.0.2.1"}}},
+	} {
+		if _, _, err := parseDesiredRoutes([]Route{entry}, netlink.FAMILY_V4, "eth0"); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	stateUp   = "up"
+	stateDown = "down"
+
+	// carrierPollInterval is how often the carrier is checked while waiting.
+	carrierPollInterval = 100 * time.Millisecond
+)
+
+func validateState(cfg Configuration) error {
+	switch cfg.State {
+	case "", stateUp, stateDown:
+	default:
+		return fmt.Errorf("%s: state must be up or down, got %q", cfg.Interface, cfg.State)
+	}
+	if cfg.WaitCarrier > 0 && cfg.State != stateUp {
+		return fmt.Errorf("%s: wait_carrier requires state up", cfg.Interface)
+	}
+	return nil
+}
+
+// reconcileState brings link up or down as declared and, once it is up,
+// waits for the carrier when asked to.
+func (n NetlinkExecutor) reconcileState(cfg Configuration, link netlink.Link) error {
+	up := link.Attrs().Flags&net.FlagUp != 0
+	switch cfg.State {
+	case stateUp:
+		if !up {
+			if err := n.Provider.LinkSetUp(link); err != nil {
+				return fmt.Errorf("set %s up: %w", cfg.Interface, err)
+			}
+		}
+		return n.waitCarrier(cfg.Interface, time.Duration(cfg.WaitCarrier))
+	case stateDown:
+		if up {
+			if err := n.Provider.LinkSetDown(link); err != nil {
+				return fmt.Errorf("set %s down: %w", cfg.Interface, err)
+			}
+		}
+	}
+	return nil
+}
+
+// waitCarrier polls name until the kernel reports a lower layer that is up.
+func (n NetlinkExecutor) waitCarrier(name string, timeout time.Duration) error {
+	if timeout <= 0 {
+		return nil
+	}
+	for waited := time.Duration(0); ; waited += carrierPollInterval {
+		link, err := n.Provider.LinkByName(name)
+		if err != nil {
+			return fmt.Errorf("lookup interface %q: %w", name, err)
+		}
+		if link.Attrs().RawFlags&unix.IFF_LOWER_UP != 0 {
+			return nil
+		}
+		if waited >= timeout {
+			return fmt.Errorf("no carrier on %s after %s", name, timeout)
+		}
+		n.sleep(carrierPollInterval)
+	}
+}
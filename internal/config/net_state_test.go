@@ -0,0 +1,79 @@
+package config
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestNetlinkExecutorSetsState(t *testing.T) {
+	cases := []struct {
+		state string
+		flags net.Flags
+		want  []string
+	}{
+		{stateUp, 0, []string{"eth0:up"}},
+		{stateUp, net.FlagUp, nil},
+		{stateDown, net.FlagUp, []string{"eth0:down"}},
+		{stateDown, 0, nil},
+	}
+	for _, tc := range cases {
+		provider := &mockNetlinkProvider{link: &fakeLink{netlink.LinkAttrs{Name: "eth0", Flags: tc.flags}}}
+		exec := NetlinkExecutor{Provider: provider}
+		if err := exec.Apply(Configuration{Interface: "eth0", State: tc.state}); err != nil {
+			t.Fatalf("%s from %v: Apply() error = %v", tc.state, tc.flags, err)
+		}
+		if strings.Join(provider.states, ",") != strings.Join(tc.want, ",") {
+			t.Fatalf("%s from %v: got %v, want %v", tc.state, tc.flags, provider.states, tc.want)
+		}
+	}
+}
+
+func TestNetlinkExecutorWaitsForCarrier(t *testing.T) {
+	link := &fakeLink{netlink.LinkAttrs{Name: "eth0"}}
+	provider := &mockNetlinkProvider{link: link}
+	polls := 0
+	exec := NetlinkExecutor{Provider: provider, Sleep: func(time.Duration) {
+		if polls++; polls == 3 {
+			link.RawFlags |= unix.IFF_LOWER_UP
+		}
+	}}
+	cfg := Configuration{Interface: "eth0", State: stateUp, WaitCarrier: Duration(time.Second)}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if polls != 3 {
+		t.Fatalf("expected to stop polling once the carrier appeared, got %d polls", polls)
+	}
+}
+
+func TestNetlinkExecutorCarrierTimeout(t *testing.T) {
+	provider := &mockNetlinkProvider{link: &fakeLink{netlink.LinkAttrs{Name: "eth0"}}}
+	exec := NetlinkExecutor{Provider: provider, Sleep: func(time.Duration) {}}
+	cfg := Configuration{Interface: "eth0", State: stateUp, WaitCarrier: Duration(time.Second)}
+	err := exec.Apply(cfg)
+	if err == nil || !strings.Contains(err.Error(), "no carrier on eth0 after 1s") {
+		t.Fatalf("expected a carrier timeout, got %v", err)
+	}
+}
+
+func TestNetlinkExecutorValidatesState(t *testing.T) {
+	cases := map[string]Configuration{
+		"unknown state":         {Interface: "eth0", State: "dormant"},
+		"carrier without up":    {Interface: "eth0", State: stateDown, WaitCarrier: Duration(time.Second)},
+		"carrier without state": {Interface: "eth0", MTU: 1500, WaitCarrier: Duration(time.Second)},
+	}
+	for name, cfg := range cases {
+		provider := &mockNetlinkProvider{}
+		if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+		if len(provider.states) != 0 {
+			t.Fatalf("%s: expected no state changes, got %v", name, provider.states)
+		}
+	}
+}
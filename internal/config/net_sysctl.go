@@ -0,0 +1,136 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// SysctlProvider is implemented by providers that can read and write kernel
+// parameters by their path below /proc/sys. It is optional; configurations
+// with sysctls require it.
+type SysctlProvider interface {
+	Sysctl(path string) (string, error)
+	SetSysctl(path, value string) error
+}
+
+// sysctlSections are the per-interface sysctl directories; each holds one
+// directory per interface.
+var sysctlSections = []string{"net/ipv4/conf", "net/ipv6/conf", "net/ipv4/neigh", "net/ipv6/neigh"}
+
+type sysctl struct {
+	name  string
+	path  string
+	value string
+}
+
+// SysctlName converts a path below /proc/sys to the dotted name sysctl(8)
+// uses, in which the dots of interface names become slashes.
+func SysctlName(path string) string {
+	return swapDotsAndSlashes(path)
+}
+
+// sysctlPath converts a dotted sysctl name to its path below /proc/sys.
+func sysctlPath(name string) string {
+	return swapDotsAndSlashes(name)
+}
+
+func swapDotsAndSlashes(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.':
+			return '/'
+		case '/':
+			return '.'
+		}
+		return r
+	}, s)
+}
+
+// sysctlSettings are the configuration sections implemented by sysctls.
+var sysctlSettings = []struct {
+	name    string
+	sysctls func(Configuration) (map[string]string, error)
+}{
+	{"arp", arpSysctls},
+	{"ipv6", ipv6Sysctls},
+	{"slaac", slaacSysctls},
+}
+
+// parseSysctls validates the sysctls section and adds the sysctls of the
+// sysctlSettings sections. Every name must be a setting of the configured interface, such as
+// net.ipv6.conf.eth0.accept_ra.
+func (n NetlinkExecutor) parseSysctls(cfg Configuration) ([]sysctl, error) {
+	declared := maps.Clone(cfg.Sysctls)
+	for _, section := range sysctlSettings {
+		settings, err := section.sysctls(cfg)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range sortedKeys(settings) {
+			if _, dup := declared[name]; dup {
+				return nil, fmt.Errorf("sysctl %s: also set by the %s section", name, section.name)
+			}
+			if declared == nil {
+				declared = make(map[string]string, len(settings))
+			}
+			declared[name] = settings[name]
+		}
+	}
+	if len(declared) == 0 {
+		return nil, nil
+	}
+	if _, ok := n.Provider.(SysctlProvider); !ok {
+		return nil, errors.New("netlink provider cannot set sysctls")
+	}
+	sysctls := make([]sysctl, 0, len(declared))
+	for _, name := range sortedKeys(declared) {
+		path := sysctlPath(name)
+		if !interfaceSysctl(path, cfg.Interface) {
+			return nil, fmt.Errorf("sysctl %s: not a setting of %s, want net.ipv4.conf.%[3]s.<name>, net.ipv6.conf.%[3]s.<name> or the same below neigh",
+				name, cfg.Interface, SysctlName(cfg.Interface))
+		}
+		value := declared[name]
+		if strings.TrimSpace(value) == "" || strings.ContainsAny(value, "\n") {
+			return nil, fmt.Errorf("sysctl %s: value must be a single non-empty line, got %q", name, value)
+		}
+		sysctls = append(sysctls, sysctl{name: name, path: path, value: value})
+	}
+	return sysctls, nil
+}
+
+// interfaceSysctl reports whether path names a parameter directly in the
+// directory of iface in one of the sysctlSections.
+func interfaceSysctl(path, iface string) bool {
+	for _, section := range sysctlSections {
+		param, ok := strings.CutPrefix(path, section+"/"+iface+"/")
+		if ok && param != "" && !strings.Contains(param, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileSysctls writes the sysctls whose current value differs. Values
+// are compared field by field, as multi-value parameters are printed with
+// tabs.
+func (n NetlinkExecutor) reconcileSysctls(sysctls []sysctl) error {
+	if len(sysctls) == 0 {
+		return nil
+	}
+	provider := n.Provider.(SysctlProvider)
+	for _, s := range sysctls {
+		have, err := provider.Sysctl(s.path)
+		if err != nil {
+			return fmt.Errorf("read sysctl %s: %w", s.name, err)
+		}
+		if strings.Join(strings.Fields(have), " ") == strings.Join(strings.Fields(s.value), " ") {
+			continue
+		}
+		if err := provider.SetSysctl(s.path, s.value); err != nil {
+			return fmt.Errorf("set sysctl %s: %w", s.name, err)
+		}
+	}
+	return nil
+}
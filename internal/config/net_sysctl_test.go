@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+// sysctlProvider keeps sysctls in memory, by path.
+type sysctlProvider struct {
+	*mockNetlinkProvider
+	values map[string]string
+	set    []string
+}
+
+func (s *sysctlProvider) Sysctl(path string) (string, error) {
+	value, ok := s.values[path]
+	if !ok {
+		return "", fmt.Errorf("open %s: %w", path, fs.ErrNotExist)
+	}
+	return value, nil
+}
+
+func (s *sysctlProvider) SetSysctl(path, value string) error {
+	s.values[path] = value
+	s.set = append(s.set, path+"="+value)
+	return nil
+}
+
+func TestNetlinkExecutorSetsSysctls(t *testing.T) {
+	provider := &sysctlProvider{
+		mockNetlinkProvider: &mockNetlinkProvider{link: &fakeLink{netlink.LinkAttrs{Name: "eth0.100", Index: 3}}},
+		values: map[string]string{
+			"net/ipv6/conf/eth0.100/accept_ra":      "1",
+			"net/ipv4/conf/eth0.100/forwarding":     "1",
+			"net/ipv4/conf/eth0.100/rp_filter":      "1",
+			"net/ipv4/neigh/eth0.100/gc_stale_time": "60",
+		},
+	}
+	cfg := Configuration{Interface: "eth0.100", Sysctls: map[string]string{
+		"net.ipv6.conf.eth0/100.accept_ra":      "0",
+		"net.ipv4.conf.eth0/100.forwarding":     "1",
+		"net.ipv4.conf.eth0/100.rp_filter":      "2",
+		"net.ipv4.neigh.eth0/100.gc_stale_time": " 60 ",
+	}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{"net/ipv4/conf/eth0.100/rp_filter=2", "net/ipv6/conf/eth0.100/accept_ra=0"}
+	if !reflect.DeepEqual(provider.set, want) {
+		t.Fatalf("set %v, want %v", provider.set, want)
+	}
+
+	provider.set = nil
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil || len(provider.set) != 0 {
+		t.Fatalf("second Apply() = %v, set %v; want no writes", err, provider.set)
+	}
+}
+
+func TestNetlinkExecutorValidatesSysctls(t *testing.T) {
+	provider := &sysctlProvider{mockNetlinkProvider: &mockNetlinkProvider{}, values: map[string]string{}}
+	tests := []struct {
+		name, value, want string
+	}{
+		{"net.ipv4.ip_forward", "1", "not a setting of eth0"},
+		{"net.ipv4.conf.eth1.forwarding", "1", "not a setting of eth0"},
+		{"net.ipv4.conf.eth0", "1", "not a setting of eth0"},
+		{"net.ipv6.conf.eth0.forwarding", "", "value must be a single non-empty line"},
+		{"net.ipv6.conf.eth0.mtu", "1500", "read sysctl net.ipv6.conf.eth0.mtu"},
+	}
+	for _, tt := range tests {
+		cfg := Configuration{Interface: "eth0", Sysctls: map[string]string{tt.name: tt.value}}
+		err := NetlinkExecutor{Provider: provider}.Apply(cfg)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("Apply(%s=%q) error = %v, want %q", tt.name, tt.value, err, tt.want)
+		}
+	}
+
+	cfg := Configuration{Interface: "eth0", Sysctls: map[string]string{"net.ipv6.conf.eth0.forwarding": "1"}}
+	err := NetlinkExecutor{Provider: &mockNetlinkProvider{}}.Apply(cfg)
+	if err == nil || !strings.Contains(err.Error(), "cannot set sysctls") {
+		t.Fatalf("expected an unsupported provider error, got %v", err)
+	}
+}
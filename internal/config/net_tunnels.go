@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	maxTunnelTTL = 255
+	// pmtuDiscover keeps path MTU discovery enabled; the kernel rejects a
+	// fixed TTL on tunnels without it.
+	pmtuDiscover = 1
+)
+
+// validateTunnel checks the tunnel section before anything is created.
+func validateTunnel(cfg Configuration) error {
+	tunnel := cfg.Tunnel
+	if tunnel == nil {
+		return nil
+	}
+	switch tunnel.Mode {
+	case "gre", "ipip", "sit":
+	default:
+		return fmt.Errorf("tunnel %s: unknown mode %q (want gre, ipip or sit)", cfg.Interface, tunnel.Mode)
+	}
+	endpoints := []struct{ field, value string }{{"local", tunnel.Local}, {"remote", tunnel.Remote}}
+	for _, endpoint := range endpoints {
+		ip := net.ParseIP(endpoint.value)
+		if ip == nil || ip.To4() == nil {
+			return fmt.Errorf("tunnel %s: %s must be an IPv4 address, got %q", cfg.Interface, endpoint.field, endpoint.value)
+		}
+	}
+	if tunnel.TTL < 0 || tunnel.TTL > maxTunnelTTL {
+		return fmt.Errorf("tunnel %s: ttl %d is outside 0-%d", cfg.Interface, tunnel.TTL, maxTunnelTTL)
+	}
+	return nil
+}
+
+// desiredTunnel builds the netlink device for a validated tunnel section.
+func desiredTunnel(attrs netlink.LinkAttrs, tunnel *Tunnel) netlink.Link {
+	local := net.ParseIP(tunnel.Local).To4()
+	remote := net.ParseIP(tunnel.Remote).To4()
+	ttl := uint8(tunnel.TTL)
+	switch tunnel.Mode {
+	case "ipip":
+		return &netlink.Iptun{LinkAttrs: attrs, Local: local, Remote: remote, Ttl: ttl, PMtuDisc: pmtuDiscover}
+	case "sit":
+		return &netlink.Sittun{LinkAttrs: attrs, Local: local, Remote: remote, Ttl: ttl, PMtuDisc: pmtuDiscover}
+	}
+	return &netlink.Gretun{LinkAttrs: attrs, Local: local, Remote: remote, Ttl: ttl, PMtuDisc: pmtuDiscover}
+}
+
+// reconcileTunnel updates the endpoints and TTL of an existing tunnel in place.
+func (n NetlinkExecutor) reconcileTunnel(link, want netlink.Link) error {
+	haveLocal, haveRemote, haveTTL := tunnelParams(link)
+	wantLocal, wantRemote, wantTTL := tunnelParams(want)
+	if haveLocal.Equal(wantLocal) && haveRemote.Equal(wantRemote) && haveTTL == wantTTL {
+		return nil
+	}
+	want.Attrs().Index = link.Attrs().Index
+	if err := n.Provider.LinkModify(want); err != nil {
+		return fmt.Errorf("update %s tunnel %s: %w", want.Type(), want.Attrs().Name, err)
+	}
+	return nil
+}
+
+func tunnelParams(link netlink.Link) (local, remote net.IP, ttl uint8) {
+	switch tun := link.(type) {
+	case *netlink.Gretun:
+		return tun.Local, tun.Remote, tun.Ttl
+	case *netlink.Iptun:
+		return tun.Local, tun.Remote, tun.Ttl
+	case *netlink.Sittun:
+		return tun.Local, tun.Remote, tun.Ttl
+	}
+	return nil, nil, 0
+}
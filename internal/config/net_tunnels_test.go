@@ -0,0 +1,87 @@
+package config
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestNetlinkExecutorCreatesTunnels(t *testing.T) {
+	for mode, kind := range map[string]string{"gre": "gre", "ipip": "ipip", "sit": "sit"} {
+		provider := &mockNetlinkProvider{byName: map[string]netlink.Link{}}
+		cfg := Configuration{Interface: "tun0", Tunnel: &Tunnel{Mode: mode, Local: "192.0.2.1", Remote: "198.51.100.1", TTL: 64}}
+		if err := NewNetlinkExecutor(provider).Apply(cfg); err != nil {
+			t.Fatalf("%s: Apply() error = %v", mode, err)
+		}
+		if len(provider.linkAdded) != 1 || provider.linkAdded[0].Type() != kind {
+			t.Fatalf("%s: unexpected links created: %v", mode, provider.linkAdded)
+		}
+		local, remote, ttl := tunnelParams(provider.linkAdded[0])
+		if local.String() != "192.0.2.1" || remote.String() != "198.51.100.1" || ttl != 64 {
+			t.Fatalf("%s: unexpected tunnel parameters %s %s %d", mode, local, remote, ttl)
+		}
+	}
+}
+
+func TestNetlinkExecutorUpdatesTunnelEndpoints(t *testing.T) {
+	existing := &netlink.Gretun{
+		LinkAttrs: netlink.LinkAttrs{Name: "gre1", Index: 7},
+		Local:     net.ParseIP("192.0.2.1"),
+		Remote:    net.ParseIP("198.51.100.1"),
+	}
+	provider := &mockNetlinkProvider{byName: map[string]netlink.Link{"gre1": existing}}
+	cfg := Configuration{Interface: "gre1", Tunnel: &Tunnel{Mode: "gre", Local: "192.0.2.1", Remote: "198.51.100.9"}}
+	if err := NewNetlinkExecutor(provider).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.linkAdded) != 0 || len(provider.modified) != 1 {
+		t.Fatalf("expected an in-place update, added=%v modified=%v", provider.linkAdded, provider.modified)
+	}
+	if got := provider.modified[0]; got.Attrs().Index != 7 || got.(*netlink.Gretun).Remote.String() != "198.51.100.9" {
+		t.Fatalf("unexpected modification: %#v", got)
+	}
+}
+
+func TestNetlinkExecutorLeavesMatchingTunnelAlone(t *testing.T) {
+	existing := &netlink.Iptun{
+		LinkAttrs: netlink.LinkAttrs{Name: "ipip1", Index: 7},
+		Local:     net.ParseIP("192.0.2.1").To4(),
+		Remote:    net.ParseIP("198.51.100.1").To4(),
+		Ttl:       32,
+	}
+	provider := &mockNetlinkProvider{byName: map[string]netlink.Link{"ipip1": existing}}
+	cfg := Configuration{Interface: "ipip1", Tunnel: &Tunnel{Mode: "ipip", Local: "192.0.2.1", Remote: "198.51.100.1", TTL: 32}}
+	if err := NewNetlinkExecutor(provider).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.modified) != 0 {
+		t.Fatalf("expected no changes, got %v", provider.modified)
+	}
+}
+
+func TestNetlinkExecutorRejectsTunnelModeMismatch(t *testing.T) {
+	existing := &netlink.Iptun{LinkAttrs: netlink.LinkAttrs{Name: "tun0", Index: 7}}
+	provider := &mockNetlinkProvider{byName: map[string]netlink.Link{"tun0": existing}}
+	cfg := Configuration{Interface: "tun0", Tunnel: &Tunnel{Mode: "sit", Local: "192.0.2.1", Remote: "198.51.100.1"}}
+	err := NewNetlinkExecutor(provider).Apply(cfg)
+	if err == nil || !strings.Contains(err.Error(), "not a sit") {
+		t.Fatalf("expected kind mismatch error, got %v", err)
+	}
+}
+
+func TestValidateTunnel(t *testing.T) {
+	tests := map[string]Tunnel{
+		"unknown mode":  {Mode: "vxlan", Local: "192.0.2.1", Remote: "198.51.100.1"},
+		"missing local": {Mode: "gre", Remote: "198.51.100.1"},
+		"ipv6 remote":   {Mode: "ipip", Local: "192.0.2.1", Remote: "2001:db8::1"},
+		"ttl too large": {Mode: "sit", Local: "192.0.2.1", Remote: "198.51.100.1", TTL: 300},
+	}
+	for name, tunnel := range tests {
+		tunnel := tunnel
+		if err := validateTunnel(Configuration{Interface: "tun0", Tunnel: &tunnel}); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+}
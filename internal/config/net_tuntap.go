@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+
+	"github.com/vishvananda/netlink"
+)
+
+// rootID is used when no owner or group is declared. The kernel rejects an
+// unset (-1) owner here, and root ownership is equivalent: only root and
+// processes with CAP_NET_ADMIN may attach.
+const rootID = 0
+
+func validateTuntap(cfg Configuration) error {
+	t := cfg.Tuntap
+	if t == nil {
+		return nil
+	}
+	if _, ok := netlink.StringToTuntapModeMap[t.Mode]; !ok {
+		return fmt.Errorf("tuntap %s: mode must be tun or tap, got %q", cfg.Interface, t.Mode)
+	}
+	if _, err := resolveID(t.Owner, lookupUser); err != nil {
+		return fmt.Errorf("tuntap %s: owner: %w", cfg.Interface, err)
+	}
+	if _, err := resolveID(t.Group, lookupGroup); err != nil {
+		return fmt.Errorf("tuntap %s: group: %w", cfg.Interface, err)
+	}
+	return nil
+}
+
+// desiredTuntap builds a persistent device; validateTuntap has already
+// checked that owner and group resolve.
+func desiredTuntap(attrs netlink.LinkAttrs, t *Tuntap) *netlink.Tuntap {
+	owner, _ := resolveID(t.Owner, lookupUser)
+	group, _ := resolveID(t.Group, lookupGroup)
+	tuntap := &netlink.Tuntap{
+		LinkAttrs: attrs,
+		Mode:      netlink.StringToTuntapModeMap[t.Mode],
+		Flags:     netlink.TUNTAP_DEFAULTS | netlink.TUNTAP_NO_PI,
+		Owner:     owner,
+		Group:     group,
+	}
+	if t.MultiQueue {
+		tuntap.Flags = netlink.TUNTAP_MULTI_QUEUE_DEFAULTS
+	}
+	return tuntap
+}
+
+// checkTuntap reports settings that only take effect when a device is created.
+func checkTuntap(cfg Configuration, tuntap *netlink.Tuntap) error {
+	want := desiredTuntap(tuntap.LinkAttrs, cfg.Tuntap)
+	switch {
+	case tuntap.Mode != want.Mode:
+		return fmt.Errorf("tuntap %s is a %s device, want %s; recreate it to change the mode", cfg.Interface, tuntap.Mode, want.Mode)
+	case cfg.Tuntap.Owner != "" && tuntap.Owner != want.Owner:
+		return fmt.Errorf("tuntap %s is owned by uid %d, want %d; recreate it to change the owner", cfg.Interface, tuntap.Owner, want.Owner)
+	case cfg.Tuntap.Group != "" && tuntap.Group != want.Group:
+		return fmt.Errorf("tuntap %s belongs to gid %d, want %d; recreate it to change the group", cfg.Interface, tuntap.Group, want.Group)
+	}
+	return nil
+}
+
+// resolveID accepts a numeric id or a name looked up with lookup. An empty
+// value resolves to rootID.
+func resolveID(value string, lookup func(string) (string, error)) (uint32, error) {
+	if value == "" {
+		return rootID, nil
+	}
+	raw := value
+	if _, err := strconv.ParseUint(raw, 10, 32); err != nil {
+		if raw, err = lookup(value); err != nil {
+			return 0, err
+		}
+	}
+	id, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid id %q", value)
+	}
+	return uint32(id), nil
+}
+
+func lookupUser(name string) (string, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return "", err
+	}
+	return u.Uid, nil
+}
+
+func lookupGroup(name string) (string, error) {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return "", err
+	}
+	return g.Gid, nil
+}
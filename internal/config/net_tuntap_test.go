@@ -0,0 +1,58 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestNetlinkExecutorCreatesTuntap(t *testing.T) {
+	provider := &mockNetlinkProvider{byName: map[string]netlink.Link{}}
+	cfg := Configuration{Interface: "tap0", Tuntap: &Tuntap{Mode: "tap", Owner: "1000", Group: "1001", MultiQueue: true}}
+	if err := NewNetlinkExecutor(provider).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.linkAdded) != 1 {
+		t.Fatalf("expected tap to be created, got %v", provider.linkAdded)
+	}
+	tap, ok := provider.linkAdded[0].(*netlink.Tuntap)
+	if !ok {
+		t.Fatalf("expected tuntap link, got %T", provider.linkAdded[0])
+	}
+	if tap.Mode != netlink.TUNTAP_MODE_TAP || tap.Owner != 1000 || tap.Group != 1001 ||
+		tap.Flags&netlink.TUNTAP_MULTI_QUEUE == 0 || tap.NonPersist {
+		t.Fatalf("unexpected tap %#v", tap)
+	}
+}
+
+func TestNetlinkExecutorRejectsTuntapModeChange(t *testing.T) {
+	tun := &netlink.Tuntap{LinkAttrs: netlink.LinkAttrs{Name: "tun0", Index: 4}, Mode: netlink.TUNTAP_MODE_TUN}
+	provider := &mockNetlinkProvider{link: tun}
+	exec := NetlinkExecutor{Provider: provider}
+	if err := exec.Apply(Configuration{Interface: "tun0", Tuntap: &Tuntap{Mode: "tun"}}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if err := exec.Apply(Configuration{Interface: "tun0", Tuntap: &Tuntap{Mode: "tap"}}); err == nil {
+		t.Fatal("expected mode mismatch error")
+	}
+	if err := exec.Apply(Configuration{Interface: "tun0", Tuntap: &Tuntap{Mode: "tun", Owner: "1000"}}); err == nil {
+		t.Fatal("expected owner mismatch error")
+	}
+}
+
+func TestValidateTuntap(t *testing.T) {
+	cases := map[string]*Tuntap{
+		"missing mode": {},
+		"bad mode":     {Mode: "tape"},
+		"bad owner":    {Mode: "tun", Owner: "no-such-user-goeth"},
+		"bad group":    {Mode: "tun", Group: "no-such-group-goeth"},
+	}
+	for name, tuntap := range cases {
+		if err := validateTuntap(Configuration{Interface: "tun0", Tuntap: tuntap}); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+	if id, err := resolveID("", lookupUser); err != nil || id != rootID {
+		t.Fatalf("resolveID(\"\") = %d, %v", id, err)
+	}
+}
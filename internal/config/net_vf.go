@@ -0,0 +1,151 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// maxVFVlanQoS is the largest 802.1p priority of VF VLAN tagging.
+	maxVFVlanQoS = 7
+	// kernelTrue is how the kernel reports a VF flag that is on.
+	kernelTrue = 1
+	// macLen is the length of an Ethernet address.
+	macLen = 6
+)
+
+// VFProvider is implemented by providers that can set the attributes of
+// SR-IOV virtual functions through their physical function. It is
+// optional; configurations with vfs require it. The current attributes are
+// read from the Vfs of the physical function's link attributes.
+type VFProvider interface {
+	LinkSetVfHardwareAddr(link netlink.Link, vf int, hwaddr net.HardwareAddr) error
+	LinkSetVfVlanQos(link netlink.Link, vf, vlan, qos int) error
+	LinkSetVfSpoofchk(link netlink.Link, vf int, check bool) error
+	LinkSetVfTrust(link netlink.Link, vf int, state bool) error
+	LinkSetVfRate(link netlink.Link, vf, minRate, maxRate int) error
+}
+
+// String describes v the way ip-link(8) takes its vf arguments.
+func (v VF) String() string {
+	parts := []string{fmt.Sprintf("vf %d", v.ID)}
+	if v.MAC != "" {
+		parts = append(parts, "mac "+v.MAC)
+	}
+	if v.VLAN != 0 || v.QoS != 0 {
+		parts = append(parts, fmt.Sprintf("vlan %d qos %d", v.VLAN, v.QoS))
+	}
+	if v.SpoofCheck != nil {
+		parts = append(parts, "spoofchk "+onOff(*v.SpoofCheck))
+	}
+	if v.Trust != nil {
+		parts = append(parts, "trust "+onOff(*v.Trust))
+	}
+	if v.MinRate != 0 || v.MaxRate != 0 {
+		parts = append(parts, fmt.Sprintf("min_tx_rate %d max_tx_rate %d", v.MinRate, v.MaxRate))
+	}
+	return strings.Join(parts, " ")
+}
+
+// vfSpec is a virtual function with its MAC address parsed.
+type vfSpec struct {
+	VF
+	mac net.HardwareAddr
+}
+
+// parseVFs checks the virtual functions declared for a physical function.
+// Whether the device has them is only known once its link is read.
+func (n NetlinkExecutor) parseVFs(cfg Configuration) ([]vfSpec, error) {
+	if len(cfg.VFs) == 0 {
+		return nil, nil
+	}
+	if _, ok := n.Provider.(VFProvider); !ok {
+		return nil, errors.New("netlink provider cannot configure virtual functions")
+	}
+	specs := make([]vfSpec, 0, len(cfg.VFs))
+	seen := make(map[int]bool)
+	for _, vf := range cfg.VFs {
+		if vf.ID < 0 {
+			return nil, fmt.Errorf("vf %d of %s: id must not be negative", vf.ID, cfg.Interface)
+		}
+		if seen[vf.ID] {
+			return nil, fmt.Errorf("vf %d of %s is declared more than once", vf.ID, cfg.Interface)
+		}
+		seen[vf.ID] = true
+		spec := vfSpec{VF: vf}
+		if vf.MAC != "" {
+			mac, err := net.ParseMAC(vf.MAC)
+			if err != nil || len(mac) != macLen {
+				return nil, fmt.Errorf("vf %d of %s: invalid mac %q", vf.ID, cfg.Interface, vf.MAC)
+			}
+			spec.mac = mac
+		}
+		if vf.VLAN < 0 || vf.VLAN > maxVLANID {
+			return nil, fmt.Errorf("vf %d of %s: vlan %d is not between 0 and %d", vf.ID, cfg.Interface, vf.VLAN, maxVLANID)
+		}
+		if vf.QoS < 0 || vf.QoS > maxVFVlanQoS {
+			return nil, fmt.Errorf("vf %d of %s: qos %d is not between 0 and %d", vf.ID, cfg.Interface, vf.QoS, maxVFVlanQoS)
+		}
+		if vf.QoS != 0 && vf.VLAN == 0 {
+			return nil, fmt.Errorf("vf %d of %s: qos requires a vlan", vf.ID, cfg.Interface)
+		}
+		if vf.MinRate < 0 || vf.MaxRate < 0 {
+			return nil, fmt.Errorf("vf %d of %s: rates must not be negative", vf.ID, cfg.Interface)
+		}
+		if vf.MaxRate != 0 && vf.MinRate > vf.MaxRate {
+			return nil, fmt.Errorf("vf %d of %s: min_rate %d exceeds max_rate %d", vf.ID, cfg.Interface, vf.MinRate, vf.MaxRate)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// reconcileVFs sets the attributes of each declared virtual function that
+// differ from what the physical function reports. An unset MAC, spoof check
+// or trust is left alone; the VLAN and rates are always set, zero meaning
+// untagged and unlimited.
+func (n NetlinkExecutor) reconcileVFs(cfg Configuration, link netlink.Link, specs []vfSpec) error {
+	if len(specs) == 0 {
+		return nil
+	}
+	provider := n.Provider.(VFProvider)
+	vfs := link.Attrs().Vfs
+	for _, spec := range specs {
+		i := slices.IndexFunc(vfs, func(vf netlink.VfInfo) bool { return vf.ID == spec.ID })
+		if i < 0 {
+			return fmt.Errorf("vf %d of %s: the device has %d virtual functions", spec.ID, cfg.Interface, len(vfs))
+		}
+		have := vfs[i]
+		if spec.mac != nil && have.Mac.String() != spec.mac.String() {
+			if err := provider.LinkSetVfHardwareAddr(link, spec.ID, spec.mac); err != nil {
+				return fmt.Errorf("set mac of vf %d of %s: %w", spec.ID, cfg.Interface, err)
+			}
+		}
+		if have.Vlan != spec.VLAN || have.Qos != spec.QoS {
+			if err := provider.LinkSetVfVlanQos(link, spec.ID, spec.VLAN, spec.QoS); err != nil {
+				return fmt.Errorf("set vlan of vf %d of %s: %w", spec.ID, cfg.Interface, err)
+			}
+		}
+		if spec.SpoofCheck != nil && have.Spoofchk != *spec.SpoofCheck {
+			if err := provider.LinkSetVfSpoofchk(link, spec.ID, *spec.SpoofCheck); err != nil {
+				return fmt.Errorf("set spoof checking of vf %d of %s: %w", spec.ID, cfg.Interface, err)
+			}
+		}
+		if spec.Trust != nil && (have.Trust == kernelTrue) != *spec.Trust {
+			if err := provider.LinkSetVfTrust(link, spec.ID, *spec.Trust); err != nil {
+				return fmt.Errorf("set trust of vf %d of %s: %w", spec.ID, cfg.Interface, err)
+			}
+		}
+		if int(have.MinTxRate) != spec.MinRate || int(have.MaxTxRate) != spec.MaxRate {
+			if err := provider.LinkSetVfRate(link, spec.ID, spec.MinRate, spec.MaxRate); err != nil {
+				return fmt.Errorf("set rate of vf %d of %s: %w", spec.ID, cfg.Interface, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+// vfProvider records the virtual function attributes set, as ip-link(8)
+// arguments.
+type vfProvider struct {
+	*mockNetlinkProvider
+	set []string
+}
+
+func (v *vfProvider) LinkSetVfHardwareAddr(link netlink.Link, vf int, hwaddr net.HardwareAddr) error {
+	v.set = append(v.set, VF{ID: vf, MAC: hwaddr.String()}.String())
+	return nil
+}
+
+func (v *vfProvider) LinkSetVfVlanQos(link netlink.Link, vf, vlan, qos int) error {
+	v.set = append(v.set, fmt.Sprintf("vf %d vlan %d qos %d", vf, vlan, qos))
+	return nil
+}
+
+func (v *vfProvider) LinkSetVfSpoofchk(link netlink.Link, vf int, check bool) error {
+	v.set = append(v.set, VF{ID: vf, SpoofCheck: &check}.String())
+	return nil
+}
+
+func (v *vfProvider) LinkSetVfTrust(link netlink.Link, vf int, state bool) error {
+	v.set = append(v.set, VF{ID: vf, Trust: &state}.String())
+	return nil
+}
+
+func (v *vfProvider) LinkSetVfRate(link netlink.Link, vf, minRate, maxRate int) error {
+	v.set = append(v.set, VF{ID: vf, MinRate: minRate, MaxRate: maxRate}.String())
+	return nil
+}
+
+func newVFProvider(vfs ...netlink.VfInfo) *vfProvider {
+	return &vfProvider{mockNetlinkProvider: &mockNetlinkProvider{
+		link: &fakeLink{netlink.LinkAttrs{Name: "eth0", Index: 2, Vfs: vfs}},
+	}}
+}
+
+func TestNetlinkExecutorSetsVFs(t *testing.T) {
+	mac, _ := net.ParseMAC("02:00:00:00:00:01")
+	provider := newVFProvider(
+		netlink.VfInfo{ID: 0, Mac: mac, Vlan: 100, Spoofchk: true},
+		netlink.VfInfo{ID: 1, Mac: make(net.HardwareAddr, macLen), Vlan: 200, Spoofchk: true, MaxTxRate: 1000},
+	)
+	on, off := true, false
+	cfg := Configuration{Interface: "eth0", VFs: []VF{
+		{ID: 0, MAC: "02:00:00:00:00:01", VLAN: 100, SpoofCheck: &on},
+		{ID: 1, MAC: "02:00:00:00:00:02", SpoofCheck: &off, Trust: &on, MinRate: 100, MaxRate: 1000},
+	}}
+	if err := (NetlinkExecutor{Provider: provider}).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{
+		"vf 1 mac 02:00:00:00:00:02",
+		"vf 1 vlan 0 qos 0",
+		"vf 1 spoofchk off",
+		"vf 1 trust on",
+		"vf 1 min_tx_rate 100 max_tx_rate 1000",
+	}
+	if !reflect.DeepEqual(provider.set, want) {
+		t.Fatalf("set %#v, want %#v; vf 0 is already configured", provider.set, want)
+	}
+}
+
+func TestNetlinkExecutorValidatesVFs(t *testing.T) {
+	provider := newVFProvider(netlink.VfInfo{ID: 0})
+	tests := []struct {
+		cfg      Configuration
+		provider NetlinkProvider
+		want     string
+	}{
+		{Configuration{VFs: []VF{{ID: 0}}}, provider.mockNetlinkProvider, "cannot configure virtual functions"},
+		{Configuration{VFs: []VF{{ID: 0}, {ID: 0}}}, provider, "declared more than once"},
+		{Configuration{VFs: []VF{{ID: 0, MAC: "02:00:00:00:00"}}}, provider, "invalid mac"},
+		{Configuration{VFs: []VF{{ID: 0, VLAN: 4095}}}, provider, "vlan 4095 is not between"},
+		{Configuration{VFs: []VF{{ID: 0, QoS: 3}}}, provider, "qos requires a vlan"},
+		{Configuration{VFs: []VF{{ID: 0, MinRate: 200, MaxRate: 100}}}, provider, "min_rate 200 exceeds max_rate 100"},
+		{Configuration{VFs: []VF{{ID: 4}}}, provider, "vf 4 of eth0: the device has 1 virtual functions"},
+	}
+	for _, tt := range tests {
+		tt.cfg.Interface = "eth0"
+		err := NetlinkExecutor{Provider: tt.provider}.Apply(tt.cfg)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("Apply(%+v) error = %v, want %q", tt.cfg, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/user/goeth/internal/wireguard"
+)
+
+const (
+	maxListenPort = 65535
+	// maxKeepalive is the largest interval the kernel accepts (a u16 of seconds).
+	maxKeepalive = 65535 * time.Second
+)
+
+// WireGuardProvider is implemented by providers that can configure WireGuard
+// devices. It is optional; configurations with a wireguard section require it.
+type WireGuardProvider interface {
+	ConfigureWireGuard(name string, device wireguard.Device) error
+}
+
+// parseWireGuard validates the wireguard section and loads the referenced
+// keys. It returns nil when the section is absent.
+func (n NetlinkExecutor) parseWireGuard(cfg Configuration) (*wireguard.Device, error) {
+	if cfg.WireGuard == nil {
+		return nil, nil
+	}
+	if _, ok := n.Provider.(WireGuardProvider); !ok {
+		return nil, errors.New("netlink provider cannot configure wireguard devices")
+	}
+	section := cfg.WireGuard
+	if section.PrivateKeyFile == "" {
+		return nil, fmt.Errorf("wireguard %s: private_key_file is required", cfg.Interface)
+	}
+	if section.ListenPort < 0 || section.ListenPort > maxListenPort {
+		return nil, fmt.Errorf("wireguard %s: listen_port %d is outside 0-%d", cfg.Interface, section.ListenPort, maxListenPort)
+	}
+	key, err := readKeyFile(section.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("wireguard %s: private key: %w", cfg.Interface, err)
+	}
+	dev := &wireguard.Device{PrivateKey: key, ListenPort: section.ListenPort}
+	if section.Peers == nil {
+		return dev, nil
+	}
+	dev.Peers = make([]wireguard.Peer, 0, len(section.Peers))
+	seen := make(map[wireguard.Key]struct{}, len(section.Peers))
+	for _, entry := range section.Peers {
+		peer, err := parseWireGuardPeer(entry)
+		if err != nil {
+			return nil, fmt.Errorf("wireguard %s: peer %s: %w", cfg.Interface, entry.PublicKey, err)
+		}
+		if _, dup := seen[peer.PublicKey]; dup {
+			return nil, fmt.Errorf("wireguard %s: peer %s is declared more than once", cfg.Interface, entry.PublicKey)
+		}
+		seen[peer.PublicKey] = struct{}{}
+		dev.Peers = append(dev.Peers, peer)
+	}
+	return dev, nil
+}
+
+func parseWireGuardPeer(entry WireGuardPeer) (wireguard.Peer, error) {
+	var peer wireguard.Peer
+	key, err := wireguard.ParseKey(entry.PublicKey)
+	if err != nil {
+		return peer, fmt.Errorf("public key: %w", err)
+	}
+	peer.PublicKey = key
+	if entry.PresharedKeyFile != "" {
+		psk, err := readKeyFile(entry.PresharedKeyFile)
+		if err != nil {
+			return peer, fmt.Errorf("preshared key: %w", err)
+		}
+		peer.PresharedKey = &psk
+	}
+	if entry.Endpoint != "" {
+		endpoint, err := net.ResolveUDPAddr("udp", entry.Endpoint)
+		if err != nil {
+			return peer, fmt.Errorf("endpoint: %w", err)
+		}
+		peer.Endpoint = endpoint
+	}
+	if time.Duration(entry.PersistentKeepalive) > maxKeepalive {
+		return peer, fmt.Errorf("persistent_keepalive exceeds %s", maxKeepalive)
+	}
+	peer.PersistentKeepalive = time.Duration(lifetimeSeconds(entry.PersistentKeepalive)) * time.Second
+	for _, raw := range entry.AllowedIPs {
+		_, ipnet, err := net.ParseCIDR(raw)
+		if err != nil {
+			return peer, fmt.Errorf("allowed ip %q: %w", raw, err)
+		}
+		peer.AllowedIPs = append(peer.AllowedIPs, *ipnet)
+	}
+	return peer, nil
+}
+
+func readKeyFile(path string) (wireguard.Key, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return wireguard.Key{}, err
+	}
+	return wireguard.ParseKey(strings.TrimSpace(string(raw)))
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/user/goeth/internal/wireguard"
+)
+
+const (
+	testPrivateKey = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+	testPeerKey    = "AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI="
+)
+
+type wgMockProvider struct {
+	*mockNetlinkProvider
+	configured map[string]wireguard.Device
+}
+
+func (w wgMockProvider) ConfigureWireGuard(name string, device wireguard.Device) error {
+	w.configured[name] = device
+	return nil
+}
+
+func writeKeyFile(t *testing.T, key string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "private.key")
+	if err := os.WriteFile(path, []byte(key+"\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
+
+func TestNetlinkExecutorCreatesWireGuard(t *testing.T) {
+	provider := wgMockProvider{
+		mockNetlinkProvider: &mockNetlinkProvider{byName: map[string]netlink.Link{}},
+		configured:          map[string]wireguard.Device{},
+	}
+	cfg := Configuration{Interface: "wg0", Addresses: []Address{{CIDR: "10.0.0.1/24"}}, WireGuard: &WireGuard{
+		PrivateKeyFile: writeKeyFile(t, testPrivateKey),
+		ListenPort:     51820,
+		Peers: []WireGuardPeer{{
+			PublicKey:           testPeerKey,
+			Endpoint:            "192.0.2.1:51820",
+			AllowedIPs:          []string{"10.0.0.2/32", "2001:db8::/64"},
+			PersistentKeepalive: Duration(25 * time.Second),
+		}},
+	}}
+	if err := NewNetlinkExecutor(provider).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.linkAdded) != 1 || provider.linkAdded[0].Type() != "wireguard" {
+		t.Fatalf("expected wireguard link to be created, got %v", provider.linkAdded)
+	}
+	dev, ok := provider.configured["wg0"]
+	if !ok {
+		t.Fatal("expected wg0 to be configured")
+	}
+	if dev.PrivateKey.String() != testPrivateKey || dev.ListenPort != 51820 {
+		t.Fatalf("unexpected device settings: %+v", dev)
+	}
+	if len(dev.Peers) != 1 {
+		t.Fatalf("expected one peer, got %+v", dev.Peers)
+	}
+	peer := dev.Peers[0]
+	if peer.PublicKey.String() != testPeerKey || peer.Endpoint.String() != "192.0.2.1:51820" ||
+		peer.PersistentKeepalive != 25*time.Second || len(peer.AllowedIPs) != 2 {
+		t.Fatalf("unexpected peer: %+v", peer)
+	}
+	if !contains(provider.added, "10.0.0.1/24") {
+		t.Fatalf("expected tunnel address to be added, got %v", provider.added)
+	}
+}
+
+func TestNetlinkExecutorWireGuardRequiresProvider(t *testing.T) {
+	provider := &mockNetlinkProvider{byName: map[string]netlink.Link{}}
+	cfg := Configuration{Interface: "wg0", WireGuard: &WireGuard{PrivateKeyFile: writeKeyFile(t, testPrivateKey)}}
+	if err := NewNetlinkExecutor(provider).Apply(cfg); err == nil {
+		t.Fatal("expected error for provider without wireguard support")
+	}
+	if len(provider.linkAdded) != 0 {
+		t.Fatal("nothing must be created when validation fails")
+	}
+}
+
+func TestNetlinkExecutorWireGuardValidation(t *testing.T) {
+	keyFile := writeKeyFile(t, testPrivateKey)
+	tests := map[string]WireGuard{
+		"missing key file": {},
+		"unreadable key":   {PrivateKeyFile: filepath.Join(t.TempDir(), "absent.key")},
+		"bad private key":  {PrivateKeyFile: writeKeyFile(t, "c2hvcnQ=")},
+		"bad port":         {PrivateKeyFile: keyFile, ListenPort: 70000},
+		"bad peer key":     {PrivateKeyFile: keyFile, Peers: []WireGuardPeer{{PublicKey: "nope"}}},
+		"bad allowed ip":   {PrivateKeyFile: keyFile, Peers: []WireGuardPeer{{PublicKey: testPeerKey, AllowedIPs: []string{"10.0.0.2"}}}},
+		"duplicate peer":   {PrivateKeyFile: keyFile, Peers: []WireGuardPeer{{PublicKey: testPeerKey}, {PublicKey: testPeerKey}}},
+		"long keepalive":   {PrivateKeyFile: keyFile, Peers: []WireGuardPeer{{PublicKey: testPeerKey, PersistentKeepalive: Duration(24 * time.Hour)}}},
+	}
+	for name, section := range tests {
+		section := section
+		t.Run(name, func(t *testing.T) {
+			provider := wgMockProvider{
+				mockNetlinkProvider: &mockNetlinkProvider{byName: map[string]netlink.Link{}},
+				configured:          map[string]wireguard.Device{},
+			}
+			if err := NewNetlinkExecutor(provider).Apply(Configuration{Interface: "wg0", WireGuard: &section}); err == nil {
+				t.Fatal("expected validation error")
+			}
+			if len(provider.linkAdded) != 0 || len(provider.configured) != 0 {
+				t.Fatal("nothing must be changed when validation fails")
+			}
+		})
+	}
+}
+
+func TestNetlinkExecutorWireGuardWithoutPeersLeavesThemAlone(t *testing.T) {
+	provider := wgMockProvider{
+		mockNetlinkProvider: &mockNetlinkProvider{byName: map[string]netlink.Link{
+			"wg0": &netlink.Wireguard{LinkAttrs: netlink.LinkAttrs{Name: "wg0", Index: 4}},
+		}},
+		configured: map[string]wireguard.Device{},
+	}
+	cfg := Configuration{Interface: "wg0", WireGuard: &WireGuard{PrivateKeyFile: writeKeyFile(t, testPrivateKey)}}
+	if err := NewNetlinkExecutor(provider).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(provider.linkAdded) != 0 {
+		t.Fatalf("existing device must be reused, got %v", provider.linkAdded)
+	}
+	if dev := provider.configured["wg0"]; dev.Peers != nil {
+		t.Fatalf("peers must be left untouched, got %+v", dev.Peers)
+	}
+}
+
+func TestNetlinkExecutorRejectsNonWireGuardLink(t *testing.T) {
+	provider := wgMockProvider{
+		mockNetlinkProvider: &mockNetlinkProvider{byName: map[string]netlink.Link{
+			"wg0": &netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: "wg0", Index: 4}},
+		}},
+		configured: map[string]wireguard.Device{},
+	}
+	cfg := Configuration{Interface: "wg0", WireGuard: &WireGuard{PrivateKeyFile: writeKeyFile(t, testPrivateKey)}}
+	err := NewNetlinkExecutor(provider).Apply(cfg)
+	if err == nil || !strings.Contains(err.Error(), "not a wireguard") {
+		t.Fatalf("expected kind mismatch error, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// XDP attach modes a configuration can ask for.
+const (
+	xdpNative = "native"
+	xdpSKB    = "skb"
+	// xdpOffload is reported for programs offloaded to the NIC, which
+	// goeth does not attach itself.
+	xdpOffload = "offload"
+	// noProgram is the program fd that detaches XDP.
+	noProgram = -1
+)
+
+// xdpModes are the attach modes by name, with the IFLA_XDP_ATTACHED value
+// links report and the flag that attaches in that mode.
+var xdpModes = map[string]struct {
+	attached uint32
+	flag     int
+}{
+	xdpNative:  {nl.XDP_ATTACHED_DRV, unix.XDP_FLAGS_DRV_MODE},
+	xdpSKB:     {nl.XDP_ATTACHED_SKB, unix.XDP_FLAGS_SKB_MODE},
+	xdpOffload: {nl.XDP_ATTACHED_HW, unix.XDP_FLAGS_HW_MODE},
+}
+
+// XDPProvider is implemented by providers that can attach XDP programs
+// pinned in bpffs to links. It is optional; configurations with an xdp
+// section require it. The attached program is read from the Xdp of the
+// link attributes.
+type XDPProvider interface {
+	// PinnedProgramID returns the id of the program pinned at path, or 0
+	// when it cannot be known, in which case the program is attached.
+	PinnedProgramID(path string) (uint32, error)
+	AttachXDP(link netlink.Link, pinned string, flags int) error
+	DetachXDP(link netlink.Link, flags int) error
+}
+
+// XDPModeName names an IFLA_XDP_ATTACHED mode, such as native. It is empty
+// when no program is attached.
+func XDPModeName(attached uint32) string {
+	for name, mode := range xdpModes {
+		if mode.attached == attached {
+			return name
+		}
+	}
+	return ""
+}
+
+// XDPAttachMode reverses XDPModeName.
+func XDPAttachMode(name string) uint32 {
+	if mode, ok := xdpModes[name]; ok {
+		return mode.attached
+	}
+	return nl.XDP_ATTACHED_NONE
+}
+
+// String describes x for the console.
+func (x XDP) String() string {
+	if x.Pinned == "" {
+		return "detach xdp program"
+	}
+	return fmt.Sprintf("xdp program %s (%s)", x.Pinned, x.mode())
+}
+
+func (x XDP) mode() string {
+	if x.Mode == "" {
+		return xdpNative
+	}
+	return x.Mode
+}
+
+// validateXDP checks the xdp section of cfg. Whether a program is pinned at
+// the path is only known once it is opened.
+func (n NetlinkExecutor) validateXDP(cfg Configuration) error {
+	if cfg.XDP == nil {
+		return nil
+	}
+	if _, ok := n.Provider.(XDPProvider); !ok {
+		return errors.New("netlink provider cannot attach xdp programs")
+	}
+	if mode := cfg.XDP.mode(); !slices.Contains([]string{xdpNative, xdpSKB}, mode) {
+		return fmt.Errorf("xdp for %s: mode %q is not native or skb", cfg.Interface, mode)
+	}
+	if pinned := cfg.XDP.Pinned; pinned != "" && !filepath.IsAbs(pinned) {
+		return fmt.Errorf("xdp for %s: pinned path %q is not absolute", cfg.Interface, pinned)
+	}
+	return nil
+}
+
+// reconcileXDP attaches the pinned program unless the link already runs it
+// in the declared mode, or detaches what is attached when no program is
+// declared. A program attached in another mode is detached first, as the
+// kernel does not run native and generic XDP side by side.
+func (n NetlinkExecutor) reconcileXDP(cfg Configuration, link netlink.Link) error {
+	if cfg.XDP == nil {
+		return nil
+	}
+	provider := n.Provider.(XDPProvider)
+	var haveID uint32
+	haveMode := ""
+	if xdp := link.Attrs().Xdp; xdp != nil && xdp.ProgId != 0 {
+		haveID, haveMode = xdp.ProgId, XDPModeName(xdp.AttachMode)
+	}
+	detach := func() error {
+		if err := provider.DetachXDP(link, xdpModes[haveMode].flag); err != nil {
+			return fmt.Errorf("detach xdp program %d from %s: %w", haveID, cfg.Interface, err)
+		}
+		return nil
+	}
+	if cfg.XDP.Pinned == "" {
+		if haveID == 0 {
+			return nil
+		}
+		return detach()
+	}
+	want := cfg.XDP.mode()
+	id, err := provider.PinnedProgramID(cfg.XDP.Pinned)
+	if err != nil {
+		return fmt.Errorf("xdp for %s: %w", cfg.Interface, err)
+	}
+	if id != 0 && id == haveID && haveMode == want {
+		return nil
+	}
+	if haveID != 0 && haveMode != want {
+		if err := detach(); err != nil {
+			return err
+		}
+	}
+	if err := provider.AttachXDP(link, cfg.XDP.Pinned, xdpModes[want].flag); err != nil {
+		return fmt.Errorf("attach %s to %s: %w", cfg.XDP, cfg.Interface, err)
+	}
+	return nil
+}
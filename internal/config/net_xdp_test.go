@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// xdpProvider serves pinned program ids by path and records attaching and
+// detaching.
+type xdpProvider struct {
+	*mockNetlinkProvider
+	pinned  map[string]uint32
+	changes []string
+}
+
+func (x *xdpProvider) PinnedProgramID(path string) (uint32, error) {
+	id, ok := x.pinned[path]
+	if !ok {
+		return 0, unix.ENOENT
+	}
+	return id, nil
+}
+
+func (x *xdpProvider) AttachXDP(link netlink.Link, pinned string, flags int) error {
+	x.changes = append(x.changes, fmt.Sprintf("attach %s flags %d", pinned, flags))
+	return nil
+}
+
+func (x *xdpProvider) DetachXDP(link netlink.Link, flags int) error {
+	x.changes = append(x.changes, fmt.Sprintf("detach flags %d", flags))
+	return nil
+}
+
+func newXDPProvider(attached *netlink.LinkXdp) *xdpProvider {
+	return &xdpProvider{
+		mockNetlinkProvider: &mockNetlinkProvider{link: &fakeLink{netlink.LinkAttrs{Name: "eth0", Index: 2, Xdp: attached}}},
+		pinned:              map[string]uint32{"/sys/fs/bpf/ddos": 42},
+	}
+}
+
+func TestNetlinkExecutorAttachesXDP(t *testing.T) {
+	native := &netlink.LinkXdp{Attached: true, ProgId: 42, AttachMode: nl.XDP_ATTACHED_DRV}
+	generic := &netlink.LinkXdp{Attached: true, ProgId: 7, AttachMode: nl.XDP_ATTACHED_SKB}
+	tests := []struct {
+		attached *netlink.LinkXdp
+		xdp      XDP
+		want     []string
+	}{
+		{nil, XDP{Pinned: "/sys/fs/bpf/ddos"}, []string{"attach /sys/fs/bpf/ddos flags 4"}},
+		{native, XDP{Pinned: "/sys/fs/bpf/ddos"}, nil},
+		{native, XDP{Pinned: "/sys/fs/bpf/ddos", Mode: "skb"}, []string{"detach flags 4", "attach /sys/fs/bpf/ddos flags 2"}},
+		{generic, XDP{Pinned: "/sys/fs/bpf/ddos", Mode: "skb"}, []string{"attach /sys/fs/bpf/ddos flags 2"}},
+		{generic, XDP{}, []string{"detach flags 2"}},
+		{nil, XDP{}, nil},
+	}
+	for _, tt := range tests {
+		provider := newXDPProvider(tt.attached)
+		if err := (NetlinkExecutor{Provider: provider}).Apply(Configuration{Interface: "eth0", XDP: &tt.xdp}); err != nil {
+			t.Fatalf("Apply(%+v) error = %v", tt.xdp, err)
+		}
+		if !reflect.DeepEqual(provider.changes, tt.want) {
+			t.Fatalf("Apply(%+v) with %+v made %v, want %v", tt.xdp, tt.attached, provider.changes, tt.want)
+		}
+	}
+}
+
+func TestNetlinkExecutorValidatesXDP(t *testing.T) {
+	provider := newXDPProvider(nil)
+	tests := []struct {
+		cfg      Configuration
+		provider NetlinkProvider
+		want     string
+	}{
+		{Configuration{XDP: &XDP{Pinned: "/sys/fs/bpf/ddos"}}, provider.mockNetlinkProvider, "cannot attach xdp programs"},
+		{Configuration{XDP: &XDP{Pinned: "/sys/fs/bpf/ddos", Mode: "offload"}}, provider, `mode "offload" is not native or skb`},
+		{Configuration{XDP: &XDP{Pinned: "ddos"}}, provider, "is not absolute"},
+		{Configuration{XDP: &XDP{Pinned: "/sys/fs/bpf/missing"}}, provider, "xdp for eth0: no such file or directory"},
+	}
+	for _, tt := range tests {
+		tt.cfg.Interface = "eth0"
+		err := NetlinkExecutor{Provider: tt.provider}.Apply(tt.cfg)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("Apply(%+v) error = %v, want %q", tt.cfg, err, tt.want)
+		}
+	}
+}
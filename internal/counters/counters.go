@@ -0,0 +1,189 @@
+// Package counters samples interface statistics and pushes them to
+// time-series databases, for deployments where nothing scrapes goeth.
+package counters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+// Counter is one named interface counter.
+type Counter struct {
+	Name  string
+	Value uint64
+}
+
+// Sample holds the counters of one interface.
+type Sample struct {
+	Interface string
+	Counters  []Counter
+}
+
+// Source lists links together with their statistics.
+type Source interface {
+	LinkList() ([]netlink.Link, error)
+}
+
+// Exporter pushes one round of samples taken at the given time.
+type Exporter interface {
+	Export(at time.Time, samples []Sample) error
+}
+
+// Collect samples every link of source, ordered by name. Links the kernel
+// reports no statistics for are left out.
+func Collect(source Source) ([]Sample, error) {
+	links, err := source.LinkList()
+	if err != nil {
+		return nil, fmt.Errorf("list links: %w", err)
+	}
+	samples := make([]Sample, 0, len(links))
+	for _, link := range links {
+		attrs := link.Attrs()
+		if attrs.Statistics == nil {
+			continue
+		}
+		samples = append(samples, Sample{Interface: attrs.Name, Counters: counters(attrs.Statistics)})
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Interface < samples[j].Interface })
+	return samples, nil
+}
+
+// Names of the counters of a Sample.
+const (
+	RxBytes   = "rx_bytes"
+	TxBytes   = "tx_bytes"
+	RxPackets = "rx_packets"
+	TxPackets = "tx_packets"
+	RxErrors  = "rx_errors"
+	TxErrors  = "tx_errors"
+	RxDropped = "rx_dropped"
+	TxDropped = "tx_dropped"
+)
+
+func counters(stats *netlink.LinkStatistics) []Counter {
+	return []Counter{
+		{RxBytes, stats.RxBytes},
+		{TxBytes, stats.TxBytes},
+		{RxPackets, stats.RxPackets},
+		{TxPackets, stats.TxPackets},
+		{RxErrors, stats.RxErrors},
+		{TxErrors, stats.TxErrors},
+		{RxDropped, stats.RxDropped},
+		{TxDropped, stats.TxDropped},
+	}
+}
+
+// Value returns the counter called name, or zero when s has none.
+func (s Sample) Value(name string) uint64 {
+	for _, counter := range s.Counters {
+		if counter.Name == name {
+			return counter.Value
+		}
+	}
+	return 0
+}
+
+// Sub returns what was counted between prev and s. It reports false when a
+// counter went back, as happens when a link is recreated or its driver
+// resets the counters, since the difference is then meaningless.
+func (s Sample) Sub(prev Sample) (Sample, bool) {
+	delta := Sample{Interface: s.Interface, Counters: make([]Counter, len(s.Counters))}
+	for i, counter := range s.Counters {
+		before := prev.Value(counter.Name)
+		if counter.Value < before {
+			return Sample{}, false
+		}
+		delta.Counters[i] = Counter{Name: counter.Name, Value: counter.Value - before}
+	}
+	return delta, true
+}
+
+// Idle reports whether every counter of s is zero.
+func (s Sample) Idle() bool {
+	for _, counter := range s.Counters {
+		if counter.Value != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Pusher samples Source every Interval and hands the samples to each exporter.
+type Pusher struct {
+	Source    Source
+	Exporters []Exporter
+	// Interval controls how frequently samples are pushed.
+	Interval time.Duration
+	// Errors receives exporter failures. A collector that is down only
+	// loses the samples taken meanwhile, so pushing carries on.
+	Errors io.Writer
+	// Now overrides the time source (used in tests).
+	Now func() time.Time
+}
+
+// Run pushes a first round immediately and then one per interval until the
+// context is cancelled or sampling fails.
+func (p Pusher) Run(ctx context.Context) error {
+	if p.Interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		exportErr, err := p.round()
+		if err != nil {
+			return err
+		}
+		if exportErr != nil && p.Errors != nil {
+			fmt.Fprintln(p.Errors, exportErr)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Push takes one round of samples and exports it, failing if any exporter
+// fails.
+func (p Pusher) Push() error {
+	exportErr, err := p.round()
+	if err != nil {
+		return err
+	}
+	return exportErr
+}
+
+// round samples once and hands the samples to every exporter. Exporter
+// failures are joined into exportErr; err reports that nothing was sampled.
+func (p Pusher) round() (exportErr, err error) {
+	if p.Source == nil {
+		return nil, errors.New("counter source is not configured")
+	}
+	if len(p.Exporters) == 0 {
+		return nil, errors.New("no exporters are configured")
+	}
+	now := time.Now
+	if p.Now != nil {
+		now = p.Now
+	}
+	at := now()
+	samples, err := Collect(p.Source)
+	if err != nil {
+		return nil, err
+	}
+	var failures []error
+	for _, exporter := range p.Exporters {
+		if err := exporter.Export(at, samples); err != nil {
+			failures = append(failures, err)
+		}
+	}
+	return errors.Join(failures...), nil
+}
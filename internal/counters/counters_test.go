@@ -0,0 +1,127 @@
+package counters
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+type stubSource struct {
+	links []netlink.Link
+	err   error
+}
+
+func (s stubSource) LinkList() ([]netlink.Link, error) {
+	return s.links, s.err
+}
+
+type recordingExporter struct {
+	err     error
+	samples [][]Sample
+}
+
+func (r *recordingExporter) Export(at time.Time, samples []Sample) error {
+	r.samples = append(r.samples, samples)
+	return r.err
+}
+
+func link(name string, stats *netlink.LinkStatistics) netlink.Link {
+	return &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: name, Statistics: stats}}
+}
+
+func TestCollectOrdersByNameAndSkipsLinksWithoutStatistics(t *testing.T) {
+	source := stubSource{links: []netlink.Link{
+		link("eth1", &netlink.LinkStatistics{RxBytes: 10}),
+		link("dummy0", nil),
+		link("eth0", &netlink.LinkStatistics{RxBytes: 20, TxDropped: 3}),
+	}}
+	samples, err := Collect(source)
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(samples) != 2 || samples[0].Interface != "eth0" || samples[1].Interface != "eth1" {
+		t.Fatalf("unexpected samples: %#v", samples)
+	}
+	got := samples[0].Counters
+	if got[0] != (Counter{"rx_bytes", 20}) || got[len(got)-1] != (Counter{"tx_dropped", 3}) {
+		t.Fatalf("unexpected counters: %#v", got)
+	}
+}
+
+func TestSampleSub(t *testing.T) {
+	prev := Sample{Interface: "eth0", Counters: counters(&netlink.LinkStatistics{RxBytes: 1000, TxBytes: 500, RxErrors: 1})}
+	curr := Sample{Interface: "eth0", Counters: counters(&netlink.LinkStatistics{RxBytes: 4000, TxBytes: 500, RxErrors: 3})}
+	delta, ok := curr.Sub(prev)
+	if !ok || delta.Value(RxBytes) != 3000 || delta.Value(TxBytes) != 0 || delta.Value(RxErrors) != 2 {
+		t.Fatalf("Sub() = %#v, %v", delta, ok)
+	}
+	if _, ok := prev.Sub(curr); ok {
+		t.Fatal("counters that went back must not be subtracted")
+	}
+	if delta.Idle() || !(Sample{Counters: counters(&netlink.LinkStatistics{})}).Idle() {
+		t.Fatal("only samples with zero counters are idle")
+	}
+}
+
+func TestPushTriesEveryExporter(t *testing.T) {
+	failing := &recordingExporter{err: errors.New("influx write: connection refused")}
+	working := &recordingExporter{}
+	pusher := Pusher{
+		Source:    stubSource{links: []netlink.Link{link("eth0", &netlink.LinkStatistics{})}},
+		Exporters: []Exporter{failing, working},
+	}
+	if err := pusher.Push(); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("expected the exporter failure, got %v", err)
+	}
+	if len(working.samples) != 1 {
+		t.Fatalf("expected the second exporter to be used, got %d pushes", len(working.samples))
+	}
+}
+
+func TestRunReportsExporterFailuresAndContinues(t *testing.T) {
+	failing := &recordingExporter{err: errors.New("influx write: connection refused")}
+	var errs strings.Builder
+	ctx, cancel := context.WithCancel(context.Background())
+	rounds := 0
+	pusher := Pusher{
+		Source:    stubSource{links: []netlink.Link{link("eth0", &netlink.LinkStatistics{})}},
+		Exporters: []Exporter{failing},
+		Interval:  time.Millisecond,
+		Errors:    &errs,
+		Now: func() time.Time {
+			if rounds++; rounds == 2 {
+				cancel()
+			}
+			return sampleTime
+		},
+	}
+	if err := pusher.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run() error = %v, want context.Canceled", err)
+	}
+	if len(failing.samples) < 2 {
+		t.Fatalf("expected pushing to continue after a failure, got %d rounds", len(failing.samples))
+	}
+	if !strings.Contains(errs.String(), "influx write: connection refused") {
+		t.Fatalf("unexpected error output: %q", errs.String())
+	}
+}
+
+func TestPushFailsWhenSamplingFails(t *testing.T) {
+	pusher := Pusher{
+		Source:    stubSource{err: errors.New("netlink unavailable")},
+		Exporters: []Exporter{&recordingExporter{}},
+	}
+	if err := pusher.Push(); err == nil {
+		t.Fatal("expected sampling error")
+	}
+}
+
+func TestRunRequiresPositiveInterval(t *testing.T) {
+	if err := (Pusher{}).Run(context.Background()); err == nil {
+		t.Fatal("expected error for zero interval")
+	}
+}
@@ -0,0 +1,75 @@
+package counters
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// defaultGraphiteTimeout bounds connecting to and writing to the server.
+const defaultGraphiteTimeout = 5 * time.Second
+
+// graphiteNodeEscaper keeps interface names such as eth0.100 a single node
+// of the metric path.
+var graphiteNodeEscaper = strings.NewReplacer(".", "_", " ", "_")
+
+// Graphite pushes samples to a carbon server in the plaintext protocol.
+type Graphite struct {
+	// Address is the host:port of the plaintext listener, usually port 2003.
+	Address string
+	// Prefix is prepended to every metric path.
+	Prefix string
+	// Timeout defaults to defaultGraphiteTimeout.
+	Timeout time.Duration
+}
+
+// Export writes the samples over a fresh TCP connection.
+func (g Graphite) Export(at time.Time, samples []Sample) error {
+	timeout := g.Timeout
+	if timeout <= 0 {
+		timeout = defaultGraphiteTimeout
+	}
+	conn, err := net.DialTimeout("tcp", g.Address, timeout)
+	if err != nil {
+		return fmt.Errorf("graphite connect: %w", err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return fmt.Errorf("graphite write: %w", err)
+	}
+	buffered := bufio.NewWriter(conn)
+	if err := WriteGraphite(buffered, g.Prefix, at, samples); err != nil {
+		return fmt.Errorf("graphite write: %w", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("graphite write: %w", err)
+	}
+	return nil
+}
+
+// WriteGraphite formats samples as "prefix.interface.counter value time"
+// lines with second timestamps.
+func WriteGraphite(w io.Writer, prefix string, at time.Time, samples []Sample) error {
+	prefix = strings.TrimSuffix(prefix, ".")
+	if prefix != "" {
+		prefix += "."
+	}
+	for _, sample := range samples {
+		node := graphiteNodeEscaper.Replace(sample.Interface)
+		for _, counter := range sample.Counters {
+			if _, err := fmt.Fprintf(w, "%s%s.%s %d %d\n", prefix, node, counter.Name, counter.Value, at.Unix()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GraphiteNode makes name usable as a single node of a metric path, as used
+// for the host name in the default prefix.
+func GraphiteNode(name string) string {
+	return graphiteNodeEscaper.Replace(name)
+}
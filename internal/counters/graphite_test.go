@@ -0,0 +1,59 @@
+package counters
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestWriteGraphiteFormatsPlaintext(t *testing.T) {
+	var buf strings.Builder
+	samples := []Sample{{Interface: "eth0.100", Counters: []Counter{{"rx_bytes", 10}, {"tx_bytes", 20}}}}
+	if err := WriteGraphite(&buf, "goeth.edge-1.", sampleTime, samples); err != nil {
+		t.Fatalf("WriteGraphite() error = %v", err)
+	}
+	want := "goeth.edge-1.eth0_100.rx_bytes 10 1700000000\ngoeth.edge-1.eth0_100.tx_bytes 20 1700000000\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestGraphiteExportWritesToServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- ""
+			return
+		}
+		defer conn.Close()
+		raw, _ := io.ReadAll(conn)
+		received <- string(raw)
+	}()
+	graphite := Graphite{Address: listener.Addr().String(), Prefix: "lab"}
+	samples := []Sample{{Interface: "eth0", Counters: []Counter{{"rx_bytes", 7}}}}
+	if err := graphite.Export(sampleTime, samples); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if got := <-received; got != "lab.eth0.rx_bytes 7 1700000000\n" {
+		t.Fatalf("unexpected payload: %q", got)
+	}
+}
+
+func TestGraphiteExportReportsUnreachableServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	if err := (Graphite{Address: address}).Export(sampleTime, nil); err == nil {
+		t.Fatal("expected connection error")
+	}
+}
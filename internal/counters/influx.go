@@ -0,0 +1,87 @@
+package counters
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// influxMeasurement names the measurement every sample is written to.
+const influxMeasurement = "goeth_interface"
+
+// defaultInfluxTimeout bounds each write when Influx.Client is nil, so an
+// unresponsive database cannot stall the export loop.
+const defaultInfluxTimeout = 10 * time.Second
+
+// influxTagEscaper escapes the characters that delimit tags in line protocol.
+var influxTagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// Influx pushes samples to an InfluxDB write endpoint in line protocol.
+type Influx struct {
+	// URL is the write endpoint including its query, such as
+	// http://db:8086/write?db=net (1.x) or
+	// http://db:8086/api/v2/write?org=lab&bucket=net (2.x).
+	URL string
+	// Token is sent as an InfluxDB 2.x API token when set.
+	Token string
+	// Host is recorded in the host tag when set.
+	Host string
+	// Client defaults to one with a timeout of defaultInfluxTimeout.
+	Client *http.Client
+}
+
+// Export writes the samples with a single request.
+func (i Influx) Export(at time.Time, samples []Sample) error {
+	var body bytes.Buffer
+	if err := WriteInflux(&body, i.Host, at, samples); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, i.URL, &body)
+	if err != nil {
+		return fmt.Errorf("influx write: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if i.Token != "" {
+		req.Header.Set("Authorization", "Token "+i.Token)
+	}
+	resp, err := i.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("influx write: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("influx write: %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+func (i Influx) client() *http.Client {
+	if i.Client != nil {
+		return i.Client
+	}
+	return &http.Client{Timeout: defaultInfluxTimeout}
+}
+
+// WriteInflux formats samples as line protocol with nanosecond timestamps,
+// one line per interface.
+func WriteInflux(w io.Writer, host string, at time.Time, samples []Sample) error {
+	tags := ""
+	if host != "" {
+		tags = ",host=" + influxTagEscaper.Replace(host)
+	}
+	for _, sample := range samples {
+		fields := make([]string, len(sample.Counters))
+		for i, counter := range sample.Counters {
+			fields[i] = fmt.Sprintf("%s=%di", counter.Name, counter.Value)
+		}
+		if _, err := fmt.Fprintf(w, "%s%s,interface=%s %s %d\n", influxMeasurement, tags,
+			influxTagEscaper.Replace(sample.Interface), strings.Join(fields, ","), at.UnixNano()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package counters
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var sampleTime = time.Unix(1700000000, 5)
+
+func TestWriteInfluxFormatsLineProtocol(t *testing.T) {
+	var buf strings.Builder
+	samples := []Sample{{Interface: "eth 0", Counters: []Counter{{"rx_bytes", 10}, {"tx_bytes", 20}}}}
+	if err := WriteInflux(&buf, "edge-1", sampleTime, samples); err != nil {
+		t.Fatalf("WriteInflux() error = %v", err)
+	}
+	want := "goeth_interface,host=edge-1,interface=eth\\ 0 rx_bytes=10i,tx_bytes=20i 1700000000000000005\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestInfluxExportPostsLinesWithToken(t *testing.T) {
+	var body, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		body, auth = string(raw), r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	influx := Influx{URL: server.URL + "/api/v2/write?org=lab&bucket=net", Token: "example-token"}
+	samples := []Sample{{Interface: "eth0", Counters: []Counter{{"rx_bytes", 1}}}}
+	if err := influx.Export(sampleTime, samples); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if !strings.HasPrefix(body, "goeth_interface,interface=eth0 rx_bytes=1i ") {
+		t.Fatalf("unexpected body: %q", body)
+	}
+	if auth != "Token example-token" {
+		t.Fatalf("unexpected authorization header: %q", auth)
+	}
+}
+
+func TestInfluxExportReportsRejectedWrites(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+	err := Influx{URL: server.URL + "/write?db=missing"}.Export(sampleTime, nil)
+	if err == nil || !strings.Contains(err.Error(), "database not found") {
+		t.Fatalf("expected rejected write error, got %v", err)
+	}
+}
+
+func TestInfluxExportTimesOutByDefault(t *testing.T) {
+	if got := (Influx{}).client().Timeout; got != defaultInfluxTimeout {
+		t.Fatalf("default client timeout = %v, want %v", got, defaultInfluxTimeout)
+	}
+	custom := &http.Client{}
+	if got := (Influx{Client: custom}).client(); got != custom {
+		t.Fatal("Export does not use the configured client")
+	}
+}
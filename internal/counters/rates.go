@@ -0,0 +1,50 @@
+package counters
+
+import (
+	"fmt"
+	"time"
+)
+
+// bitRateUnits are the units of BitRate, each a thousand times the last.
+var bitRateUnits = []string{"bit/s", "kbit/s", "Mbit/s", "Gbit/s", "Tbit/s"}
+
+// bitRateStep is the factor between two units of bitRateUnits.
+const bitRateStep = 1000
+
+// BitRate formats bytes transferred over elapsed as a bit rate in the
+// largest unit that keeps the value at or above one, such as "1.20 Mbit/s".
+func BitRate(bytes uint64, elapsed time.Duration) string {
+	rate := float64(bytes) * 8 / elapsed.Seconds()
+	unit := 0
+	for rate >= bitRateStep && unit < len(bitRateUnits)-1 {
+		rate /= bitRateStep
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%.0f %s", rate, bitRateUnits[unit])
+	}
+	return fmt.Sprintf("%.2f %s", rate, bitRateUnits[unit])
+}
+
+// byteSizeUnits are the units of ByteSize, each a thousand times the last.
+var byteSizeUnits = []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"}
+
+// ByteSize formats a byte count, such as a counter total, in the largest
+// unit that keeps the value at or above one, such as "1.20 MB".
+func ByteSize(bytes uint64) string {
+	size := float64(bytes)
+	unit := 0
+	for size >= bitRateStep && unit < len(byteSizeUnits)-1 {
+		size /= bitRateStep
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d %s", bytes, byteSizeUnits[unit])
+	}
+	return fmt.Sprintf("%.2f %s", size, byteSizeUnits[unit])
+}
+
+// PacketRate formats packets counted over elapsed as packets per second.
+func PacketRate(packets uint64, elapsed time.Duration) string {
+	return fmt.Sprintf("%.1f", float64(packets)/elapsed.Seconds())
+}
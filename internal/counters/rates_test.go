@@ -0,0 +1,45 @@
+package counters
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRates(t *testing.T) {
+	tests := []struct {
+		bytes uint64
+		want  string
+	}{
+		{0, "0 bit/s"},
+		{100, "160 bit/s"},
+		{1500, "2.40 kbit/s"},
+		{750_000, "1.20 Mbit/s"},
+		{625_000_000_000, "1.00 Tbit/s"},
+		{625_000_000_000_000, "1000.00 Tbit/s"},
+	}
+	for _, tt := range tests {
+		if got := BitRate(tt.bytes, 5*time.Second); got != tt.want {
+			t.Errorf("BitRate(%d, 5s) = %q, want %q", tt.bytes, got, tt.want)
+		}
+	}
+	if got := PacketRate(3, 2*time.Second); got != "1.5" {
+		t.Errorf("PacketRate(3, 2s) = %q, want 1.5", got)
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	tests := []struct {
+		bytes uint64
+		want  string
+	}{
+		{0, "0 B"},
+		{999, "999 B"},
+		{1_200_000, "1.20 MB"},
+		{18_446_744_073_709_551_615, "18.45 EB"},
+	}
+	for _, tt := range tests {
+		if got := ByteSize(tt.bytes); got != tt.want {
+			t.Errorf("ByteSize(%d) = %q, want %q", tt.bytes, got, tt.want)
+		}
+	}
+}
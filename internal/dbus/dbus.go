@@ -0,0 +1,222 @@
+// Package dbus emits D-Bus signals, speaking just enough of the wire
+// protocol for goeth to announce monitor events on the system bus without a
+// D-Bus library.
+package dbus
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/user/goeth/internal/queue"
+)
+
+// SystemBus is the socket of the system bus.
+const SystemBus = "/run/dbus/system_bus_socket"
+
+// Message types and header fields of the wire protocol.
+const (
+	typeMethodCall = 1
+	typeSignal     = 4
+
+	fieldPath        = 1
+	fieldInterface   = 2
+	fieldMember      = 3
+	fieldDestination = 6
+	fieldSignature   = 8
+
+	protocolVersion = 1
+)
+
+// Signal is a D-Bus signal whose arguments are all strings.
+type Signal struct {
+	Path      string
+	Interface string
+	Member    string
+	Args      []string
+}
+
+// message is a D-Bus message being encoded. Values are aligned relative to
+// the start of the message, as the protocol requires.
+type message struct {
+	buf []byte
+}
+
+func (m *message) align(n int) {
+	for len(m.buf)%n != 0 {
+		m.buf = append(m.buf, 0)
+	}
+}
+
+func (m *message) byte(b byte) {
+	m.buf = append(m.buf, b)
+}
+
+func (m *message) uint32(v uint32) {
+	m.align(4)
+	m.buf = binary.LittleEndian.AppendUint32(m.buf, v)
+}
+
+// string encodes a STRING or OBJECT_PATH.
+func (m *message) string(s string) {
+	m.uint32(uint32(len(s)))
+	m.buf = append(append(m.buf, s...), 0)
+}
+
+func (m *message) signature(s string) {
+	m.byte(byte(len(s)))
+	m.buf = append(append(m.buf, s...), 0)
+}
+
+// field encodes a header field: its code and a variant of the given type.
+func (m *message) field(code byte, typ, value string) {
+	m.align(8)
+	m.byte(code)
+	m.signature(typ)
+	if typ == "g" {
+		m.signature(value)
+	} else {
+		m.string(value)
+	}
+}
+
+// encode builds a message of type typ with the header fields and string
+// arguments given.
+func encode(typ byte, serial uint32, fields func(*message), args []string) []byte {
+	var body message
+	for _, arg := range args {
+		body.string(arg)
+	}
+	m := &message{}
+	m.byte('l')
+	m.byte(typ)
+	m.byte(0)
+	m.byte(protocolVersion)
+	m.uint32(uint32(len(body.buf)))
+	m.uint32(serial)
+	m.uint32(0) // header fields length, filled in below
+	start := len(m.buf)
+	fields(m)
+	if len(args) > 0 {
+		m.field(fieldSignature, "g", strings.Repeat("s", len(args)))
+	}
+	binary.LittleEndian.PutUint32(m.buf[start-4:], uint32(len(m.buf)-start))
+	m.align(8)
+	return append(m.buf, body.buf...)
+}
+
+// Encode returns the wire form of signal with the given serial number.
+func Encode(serial uint32, signal Signal) []byte {
+	return encode(typeSignal, serial, func(m *message) {
+		m.field(fieldPath, "o", signal.Path)
+		m.field(fieldInterface, "s", signal.Interface)
+		m.field(fieldMember, "s", signal.Member)
+	}, signal.Args)
+}
+
+// hello is the call every connection makes first to join the bus.
+func hello(serial uint32) []byte {
+	return encode(typeMethodCall, serial, func(m *message) {
+		m.field(fieldPath, "o", "/org/freedesktop/DBus")
+		m.field(fieldInterface, "s", "org.freedesktop.DBus")
+		m.field(fieldMember, "s", "Hello")
+		m.field(fieldDestination, "s", "org.freedesktop.DBus")
+	}, nil)
+}
+
+// Conn is a connection to a bus that emits signals.
+type Conn struct {
+	conn   net.Conn
+	serial uint32
+}
+
+// Dial connects to the bus listening on the unix socket at path,
+// authenticating as the user running goeth.
+func Dial(path string) (*Conn, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("dbus: %w", err)
+	}
+	c := &Conn{conn: conn}
+	if err := c.authenticate(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("dbus: %w", err)
+	}
+	if err := c.write(hello(c.next())); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("dbus: %w", err)
+	}
+	// Replies and the NameAcquired signal are of no interest, but must be
+	// read so that the bus never blocks on the connection.
+	go io.Copy(io.Discard, conn)
+	return c, nil
+}
+
+// authenticate passes the SASL EXTERNAL exchange that precedes messages.
+func (c *Conn) authenticate() error {
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := io.WriteString(c.conn, "\x00AUTH EXTERNAL "+uid+"\r\n"); err != nil {
+		return err
+	}
+	line, err := bufio.NewReader(c.conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("authentication rejected: %s", strings.TrimSpace(line))
+	}
+	_, err = io.WriteString(c.conn, "BEGIN\r\n")
+	return err
+}
+
+func (c *Conn) next() uint32 {
+	c.serial++
+	return c.serial
+}
+
+func (c *Conn) write(msg []byte) error {
+	_, err := c.conn.Write(msg)
+	return err
+}
+
+// Emit sends signal. Conn is not safe for concurrent use; Emitter queues
+// signals for it.
+func (c *Conn) Emit(signal Signal) error {
+	if err := c.write(Encode(c.next(), signal)); err != nil {
+		return fmt.Errorf("dbus: %w", err)
+	}
+	return nil
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Emitter emits queued signals in the background.
+type Emitter struct {
+	*queue.Queue[Signal]
+	conn *Conn
+}
+
+// NewEmitter returns an Emitter for conn holding up to size signals while the
+// bus is slow.
+func NewEmitter(conn *Conn, size int) *Emitter {
+	return &Emitter{Queue: queue.New[Signal]("dbus", size), conn: conn}
+}
+
+// Run emits queued signals until ctx is cancelled, then closes the
+// connection.
+func (e *Emitter) Run(ctx context.Context) error {
+	defer e.conn.Close()
+	return e.Queue.Run(ctx, func(_ context.Context, signal Signal) error {
+		return e.conn.Emit(signal)
+	})
+}
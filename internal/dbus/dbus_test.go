@@ -0,0 +1,155 @@
+package dbus
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// decoded is a message as the tests read it back.
+type decoded struct {
+	typ    byte
+	serial uint32
+	fields map[byte]string
+	args   []string
+}
+
+// decode reads one message whose header values and arguments are strings,
+// object paths or signatures.
+func decode(t *testing.T, r io.Reader) decoded {
+	t.Helper()
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		t.Fatalf("read header: %v", err)
+	}
+	if fixed[0] != 'l' || fixed[3] != protocolVersion {
+		t.Fatalf("unexpected header % x", fixed)
+	}
+	bodyLen := binary.LittleEndian.Uint32(fixed[4:])
+	fieldsLen := binary.LittleEndian.Uint32(fixed[12:])
+	rest := make([]byte, (int(fieldsLen)+7)/8*8+int(bodyLen))
+	if _, err := io.ReadFull(r, rest); err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+	msg := append(fixed, rest...)
+	pos := 16
+	align := func(n int) { pos = (pos + n - 1) / n * n }
+	str := func(sig byte) string {
+		var n int
+		if sig == 'g' {
+			n = int(msg[pos])
+			pos++
+		} else {
+			align(4)
+			n = int(binary.LittleEndian.Uint32(msg[pos:]))
+			pos += 4
+		}
+		s := string(msg[pos : pos+n])
+		pos += n + 1
+		return s
+	}
+	d := decoded{typ: fixed[1], serial: binary.LittleEndian.Uint32(fixed[8:]), fields: make(map[byte]string)}
+	for pos < 16+int(fieldsLen) {
+		align(8)
+		code := msg[pos]
+		pos++
+		sig := str('g')
+		d.fields[code] = str(sig[0])
+	}
+	align(8)
+	for range len(d.fields[fieldSignature]) {
+		d.args = append(d.args, str('s'))
+	}
+	if pos != len(msg) {
+		t.Fatalf("decoded %d of %d bytes", pos, len(msg))
+	}
+	return d
+}
+
+func TestEncodeSignal(t *testing.T) {
+	signal := Signal{Path: "/org/goeth/Monitor", Interface: "org.goeth.Monitor1", Member: "Changed", Args: []string{"link", "", "eth0 link went down"}}
+	got := decode(t, strings.NewReader(string(Encode(7, signal))))
+	want := decoded{typ: typeSignal, serial: 7, fields: map[byte]string{
+		fieldPath:      "/org/goeth/Monitor",
+		fieldInterface: "org.goeth.Monitor1",
+		fieldMember:    "Changed",
+		fieldSignature: "sss",
+	}, args: []string{"link", "", "eth0 link went down"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Encode() decodes to %+v, want %+v", got, want)
+	}
+}
+
+func TestDialAuthenticatesAndEmits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bus")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan []decoded, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		if line, _ := r.ReadString('\n'); !strings.HasPrefix(line, "\x00AUTH EXTERNAL ") {
+			received <- nil
+			return
+		}
+		io.WriteString(conn, "OK 0123456789abcdef0123456789abcdef\r\n")
+		if line, _ := r.ReadString('\n'); line != "BEGIN\r\n" {
+			received <- nil
+			return
+		}
+		received <- []decoded{decode(t, r), decode(t, r)}
+	}()
+	conn, err := Dial(path)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	emitter := NewEmitter(conn, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go emitter.Run(ctx)
+	emitter.Send(Signal{Path: "/org/goeth/Monitor", Interface: "org.goeth.Monitor1", Member: "Changed", Args: []string{"eth0"}})
+	messages := <-received
+	if len(messages) != 2 {
+		t.Fatal("the bus did not see the SASL exchange")
+	}
+	if messages[0].typ != typeMethodCall || messages[0].fields[fieldMember] != "Hello" {
+		t.Fatalf("first message = %+v, want the Hello call", messages[0])
+	}
+	if messages[1].typ != typeSignal || messages[1].serial != 2 || !reflect.DeepEqual(messages[1].args, []string{"eth0"}) {
+		t.Fatalf("second message = %+v, want the signal", messages[1])
+	}
+}
+
+func TestDialReportsRejection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bus")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		bufio.NewReader(conn).ReadString('\n')
+		io.WriteString(conn, "REJECTED DBUS_COOKIE_SHA1\r\n")
+	}()
+	if _, err := Dial(path); err == nil || !strings.Contains(err.Error(), "REJECTED") {
+		t.Fatalf("expected a rejection, got %v", err)
+	}
+}
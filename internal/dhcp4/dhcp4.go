@@ -0,0 +1,318 @@
+// Package dhcp4 is a minimal DHCPv4 client (RFC 2131). It obtains a lease
+// with the DISCOVER, OFFER, REQUEST, ACK exchange over a UDP socket bound to
+// a device, asking servers to broadcast their replies so that no address is
+// needed beforehand. It does not keep running to renew the lease; callers
+// acquire it again, asking for the same address, when it is due.
+package dhcp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// DefaultTimeout is used when Client.Timeout is zero.
+	DefaultTimeout = 2 * time.Second
+
+	serverPort = 67
+	clientPort = 68
+	// attempts is how many times a message is sent before giving up on a
+	// reply to it.
+	attempts = 3
+
+	opRequest     = 1
+	opReply       = 2
+	htypeEthernet = 1
+	flagBroadcast = 0x8000
+	magicCookie   = 0x63825363
+	// headerLen is the fixed BOOTP header including the magic cookie.
+	headerLen = 240
+	// minMessageLen is the smallest BOOTP message relays must forward.
+	minMessageLen = 300
+)
+
+// Options of RFC 2132 used by the client.
+const (
+	optPad          = 0
+	optSubnetMask   = 1
+	optRouter       = 3
+	optRequestedIP  = 50
+	optLeaseTime    = 51
+	optMessageType  = 53
+	optServerID     = 54
+	optParamRequest = 55
+	optRenewalTime  = 58
+	optEnd          = 255
+)
+
+// DHCP message types.
+const (
+	msgDiscover = 1
+	msgOffer    = 2
+	msgRequest  = 3
+	msgAck      = 5
+	msgNak      = 6
+)
+
+var errTimeout = errors.New("timed out")
+
+// Lease is an address granted by a server.
+type Lease struct {
+	Address net.IPNet
+	// Router is the first router the server announced, if any.
+	Router net.IP
+	Server net.IP
+	// Duration is how long the address may be used; Renewal (T1) is when
+	// it should be renewed, half of Duration unless the server says
+	// otherwise.
+	Duration time.Duration
+	Renewal  time.Duration
+}
+
+// Client acquires leases through a specific network device.
+type Client struct {
+	// Timeout bounds the wait for each reply; zero means DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Acquire obtains a lease on device for the hardware address hw. A
+// requested address, such as the one of an earlier lease, is asked for
+// first; servers may offer another one.
+func (c Client) Acquire(device string, hw net.HardwareAddr, requested net.IP) (Lease, error) {
+	if len(hw) == 0 || len(hw) > 16 {
+		return Lease{}, fmt.Errorf("dhcp on %s: hardware address %q cannot be used", device, hw)
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	conn, err := openSocket(device, timeout)
+	if err != nil {
+		return Lease{}, err
+	}
+	defer unix.Close(conn.fd)
+	return exchange(conn, hw, rand.Uint32(), requested)
+}
+
+// transport carries messages to and from servers. receive returns
+// errTimeout when nothing arrives in time.
+type transport interface {
+	send(msg []byte) error
+	receive(buf []byte) (int, error)
+}
+
+type socket struct{ fd int }
+
+func openSocket(device string, timeout time.Duration) (socket, error) {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, unix.IPPROTO_UDP)
+	if err != nil {
+		return socket{}, fmt.Errorf("open dhcp socket: %w", err)
+	}
+	tv := unix.NsecToTimeval(timeout.Nanoseconds())
+	for _, step := range []func() error{
+		func() error { return unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_REUSEADDR, 1) },
+		func() error { return unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_BROADCAST, 1) },
+		func() error { return unix.BindToDevice(fd, device) },
+		func() error { return unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv) },
+		func() error { return unix.Bind(fd, &unix.SockaddrInet4{Port: clientPort}) },
+	} {
+		if err := step(); err != nil {
+			unix.Close(fd)
+			return socket{}, fmt.Errorf("configure dhcp socket on %s: %w", device, err)
+		}
+	}
+	return socket{fd: fd}, nil
+}
+
+func (s socket) send(msg []byte) error {
+	return unix.Sendto(s.fd, msg, 0, &unix.SockaddrInet4{Port: serverPort, Addr: [4]byte{255, 255, 255, 255}})
+}
+
+func (s socket) receive(buf []byte) (int, error) {
+	for {
+		n, _, err := unix.Recvfrom(s.fd, buf, 0)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if errors.Is(err, unix.EAGAIN) {
+			return 0, errTimeout
+		}
+		return n, err
+	}
+}
+
+// exchange runs DISCOVER, OFFER, REQUEST, ACK with transaction id xid.
+func exchange(t transport, hw net.HardwareAddr, xid uint32, requested net.IP) (Lease, error) {
+	discover := message{op: opRequest, xid: xid, flags: flagBroadcast, chaddr: hw, options: map[byte][]byte{
+		optMessageType:  {msgDiscover},
+		optParamRequest: {optSubnetMask, optRouter, optLeaseTime, optServerID, optRenewalTime},
+	}}
+	if ip := requested.To4(); ip != nil {
+		discover.options[optRequestedIP] = ip
+	}
+	offer, err := roundTrip(t, discover, msgOffer)
+	if err != nil {
+		return Lease{}, err
+	}
+	if offer == nil {
+		return Lease{}, errors.New("no DHCPOFFER from any server")
+	}
+	server := offer.options[optServerID]
+	if len(server) != net.IPv4len {
+		return Lease{}, errors.New("DHCPOFFER without a server identifier")
+	}
+	request := message{op: opRequest, xid: xid, flags: flagBroadcast, chaddr: hw, options: map[byte][]byte{
+		optMessageType:  {msgRequest},
+		optRequestedIP:  offer.yiaddr.To4(),
+		optServerID:     server,
+		optParamRequest: discover.options[optParamRequest],
+	}}
+	ack, err := roundTrip(t, request, msgAck)
+	if err != nil {
+		return Lease{}, err
+	}
+	if ack == nil {
+		return Lease{}, fmt.Errorf("no DHCPACK from %s", net.IP(server))
+	}
+	if ack.messageType() == msgNak {
+		return Lease{}, fmt.Errorf("%s refused to lease %s", net.IP(server), offer.yiaddr)
+	}
+	return ack.lease()
+}
+
+// roundTrip sends msg until a reply of type want, or a DHCPNAK, arrives for
+// the same transaction. It returns nil when none does.
+func roundTrip(t transport, msg message, want byte) (*message, error) {
+	buf := make([]byte, 1500)
+	for i := 0; i < attempts; i++ {
+		if err := t.send(msg.marshal()); err != nil {
+			return nil, fmt.Errorf("send dhcp message: %w", err)
+		}
+		for {
+			n, err := t.receive(buf)
+			if errors.Is(err, errTimeout) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("receive dhcp message: %w", err)
+			}
+			reply, err := parse(buf[:n])
+			if err != nil || reply.op != opReply || reply.xid != msg.xid || reply.chaddr.String() != msg.chaddr.String() {
+				continue
+			}
+			if kind := reply.messageType(); kind == want || kind == msgNak {
+				return &reply, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// message is a BOOTP message with DHCP options.
+type message struct {
+	op      byte
+	xid     uint32
+	flags   uint16
+	yiaddr  net.IP
+	chaddr  net.HardwareAddr
+	options map[byte][]byte
+}
+
+func (m message) messageType() byte {
+	if kind := m.options[optMessageType]; len(kind) == 1 {
+		return kind[0]
+	}
+	return 0
+}
+
+func (m message) marshal() []byte {
+	b := make([]byte, headerLen, minMessageLen)
+	b[0] = m.op
+	b[1] = htypeEthernet
+	b[2] = byte(len(m.chaddr))
+	binary.BigEndian.PutUint32(b[4:8], m.xid)
+	binary.BigEndian.PutUint16(b[10:12], m.flags)
+	if ip := m.yiaddr.To4(); ip != nil {
+		copy(b[16:20], ip)
+	}
+	copy(b[28:44], m.chaddr)
+	binary.BigEndian.PutUint32(b[236:240], magicCookie)
+	// Options go out in a fixed order so messages are reproducible.
+	for code := 1; code < optEnd; code++ {
+		if value, ok := m.options[byte(code)]; ok {
+			b = append(b, byte(code), byte(len(value)))
+			b = append(b, value...)
+		}
+	}
+	b = append(b, optEnd)
+	for len(b) < minMessageLen {
+		b = append(b, optPad)
+	}
+	return b
+}
+
+func parse(b []byte) (message, error) {
+	if len(b) < headerLen || binary.BigEndian.Uint32(b[236:240]) != magicCookie {
+		return message{}, errors.New("not a dhcp message")
+	}
+	hlen := min(int(b[2]), 16)
+	m := message{
+		op:      b[0],
+		xid:     binary.BigEndian.Uint32(b[4:8]),
+		flags:   binary.BigEndian.Uint16(b[10:12]),
+		yiaddr:  net.IP(append([]byte(nil), b[16:20]...)),
+		chaddr:  net.HardwareAddr(append([]byte(nil), b[28:28+hlen]...)),
+		options: make(map[byte][]byte),
+	}
+	for rest := b[headerLen:]; len(rest) > 0; {
+		code := rest[0]
+		if code == optEnd {
+			break
+		}
+		if code == optPad {
+			rest = rest[1:]
+			continue
+		}
+		if len(rest) < 2 || len(rest) < 2+int(rest[1]) {
+			return message{}, errors.New("truncated dhcp option")
+		}
+		m.options[code] = append(m.options[code], rest[2:2+int(rest[1])]...)
+		rest = rest[2+int(rest[1]):]
+	}
+	return m, nil
+}
+
+// lease reads the lease granted by an ACK.
+func (m message) lease() (Lease, error) {
+	ip := m.yiaddr.To4()
+	if ip == nil || ip.IsUnspecified() {
+		return Lease{}, errors.New("DHCPACK without an address")
+	}
+	mask := net.IPMask(m.options[optSubnetMask])
+	if len(mask) != net.IPv4len {
+		mask = ip.DefaultMask()
+	}
+	seconds := m.options[optLeaseTime]
+	if len(seconds) != 4 {
+		return Lease{}, errors.New("DHCPACK without a lease time")
+	}
+	lease := Lease{
+		Address:  net.IPNet{IP: ip, Mask: mask},
+		Server:   net.IP(m.options[optServerID]),
+		Duration: time.Duration(binary.BigEndian.Uint32(seconds)) * time.Second,
+	}
+	lease.Renewal = lease.Duration / 2
+	if renewal := m.options[optRenewalTime]; len(renewal) == 4 {
+		lease.Renewal = time.Duration(binary.BigEndian.Uint32(renewal)) * time.Second
+	}
+	if routers := m.options[optRouter]; len(routers) >= net.IPv4len {
+		lease.Router = net.IP(routers[:net.IPv4len])
+	}
+	return lease, nil
+}
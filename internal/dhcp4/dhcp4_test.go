@@ -0,0 +1,134 @@
+package dhcp4
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer answers like a server leasing 192.0.2.50/24, or refuses
+// requests when nak is set. Replies queue up until they are received.
+type fakeServer struct {
+	t       *testing.T
+	sent    []message
+	replies [][]byte
+	nak     bool
+	silent  bool
+}
+
+func (f *fakeServer) send(b []byte) error {
+	msg, err := parse(b)
+	if err != nil {
+		f.t.Fatalf("client sent an invalid message: %v", err)
+	}
+	if len(b) < minMessageLen {
+		f.t.Fatalf("client sent %d bytes, want at least %d", len(b), minMessageLen)
+	}
+	f.sent = append(f.sent, msg)
+	if f.silent {
+		return nil
+	}
+	reply := message{op: opReply, xid: msg.xid, chaddr: msg.chaddr, yiaddr: net.ParseIP("192.0.2.50"), options: map[byte][]byte{
+		optServerID:   net.ParseIP("192.0.2.1").To4(),
+		optSubnetMask: net.CIDRMask(24, 32),
+		optRouter:     net.ParseIP("192.0.2.1").To4(),
+		optLeaseTime:  binary.BigEndian.AppendUint32(nil, 3600),
+	}}
+	// A reply to someone else comes first and must be ignored.
+	other := reply
+	other.xid++
+	f.replies = append(f.replies, other.marshal())
+	switch {
+	case msg.messageType() == msgDiscover:
+		reply.options[optMessageType] = []byte{msgOffer}
+	case f.nak:
+		reply.options = map[byte][]byte{optMessageType: {msgNak}}
+	default:
+		reply.options[optMessageType] = []byte{msgAck}
+	}
+	f.replies = append(f.replies, reply.marshal())
+	return nil
+}
+
+func (f *fakeServer) receive(buf []byte) (int, error) {
+	if len(f.replies) == 0 {
+		return 0, errTimeout
+	}
+	n := copy(buf, f.replies[0])
+	f.replies = f.replies[1:]
+	return n, nil
+}
+
+var hw = net.HardwareAddr{0x02, 0x00, 0x5e, 0x00, 0x53, 0x01}
+
+func TestExchangeAcquiresLease(t *testing.T) {
+	server := &fakeServer{t: t}
+	lease, err := exchange(server, hw, 42, net.ParseIP("192.0.2.40"))
+	if err != nil {
+		t.Fatalf("exchange() error = %v", err)
+	}
+	if lease.Address.String() != "192.0.2.50/24" || !lease.Router.Equal(net.ParseIP("192.0.2.1")) ||
+		lease.Duration != time.Hour || lease.Renewal != 30*time.Minute {
+		t.Fatalf("lease = %+v, want 192.0.2.50/24 via 192.0.2.1 for 1h renewing after 30m", lease)
+	}
+	if len(server.sent) != 2 {
+		t.Fatalf("client sent %d messages, want a discover and a request", len(server.sent))
+	}
+	discover, request := server.sent[0], server.sent[1]
+	if discover.messageType() != msgDiscover || !net.IP(discover.options[optRequestedIP]).Equal(net.ParseIP("192.0.2.40")) {
+		t.Fatalf("discover = %+v, want one asking for 192.0.2.40", discover)
+	}
+	if request.messageType() != msgRequest || !net.IP(request.options[optRequestedIP]).Equal(net.ParseIP("192.0.2.50")) ||
+		!net.IP(request.options[optServerID]).Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("request = %+v, want one for the offered 192.0.2.50 from 192.0.2.1", request)
+	}
+	if discover.flags != flagBroadcast || discover.chaddr.String() != hw.String() {
+		t.Fatalf("discover flags %#x chaddr %s, want broadcast replies to %s", discover.flags, discover.chaddr, hw)
+	}
+}
+
+func TestExchangeReportsRefusal(t *testing.T) {
+	_, err := exchange(&fakeServer{t: t, nak: true}, hw, 42, nil)
+	if err == nil || !strings.Contains(err.Error(), "192.0.2.1 refused to lease 192.0.2.50") {
+		t.Fatalf("exchange() error = %v, want a refusal", err)
+	}
+}
+
+func TestExchangeRetriesWithoutServer(t *testing.T) {
+	server := &fakeServer{t: t, silent: true}
+	_, err := exchange(server, hw, 42, nil)
+	if err == nil || !strings.Contains(err.Error(), "no DHCPOFFER") {
+		t.Fatalf("exchange() error = %v, want no offer", err)
+	}
+	if len(server.sent) != attempts {
+		t.Fatalf("client sent %d discovers, want %d", len(server.sent), attempts)
+	}
+}
+
+func TestLeaseUsesRenewalTime(t *testing.T) {
+	ack := message{yiaddr: net.ParseIP("192.0.2.50"), options: map[byte][]byte{
+		optLeaseTime:   binary.BigEndian.AppendUint32(nil, 3600),
+		optRenewalTime: binary.BigEndian.AppendUint32(nil, 600),
+	}}
+	lease, err := ack.lease()
+	if err != nil {
+		t.Fatalf("lease() error = %v", err)
+	}
+	if lease.Renewal != 10*time.Minute || lease.Address.String() != "192.0.2.50/24" {
+		t.Fatalf("lease = %+v, want renewal after 10m and the class C mask", lease)
+	}
+	delete(ack.options, optLeaseTime)
+	if _, err := ack.lease(); err == nil {
+		t.Fatal("expected an error for an ack without lease time")
+	}
+}
+
+func TestParseRejectsTruncatedOptions(t *testing.T) {
+	b := message{op: opReply, chaddr: hw}.marshal()
+	b = append(b[:headerLen], optLeaseTime, 4, 0)
+	if _, err := parse(b); err == nil {
+		t.Fatal("expected an error for a truncated option")
+	}
+}
@@ -0,0 +1,339 @@
+// Package dhcp6 is a minimal DHCPv6 client (RFC 8415) for a single
+// non-temporary address. It obtains the address with the SOLICIT, ADVERTISE,
+// REQUEST, REPLY exchange from the link-local address of a device. Like
+// package dhcp4 it does not keep running; callers acquire the address again
+// when it is due for renewal.
+package dhcp6
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// DefaultTimeout is used when Client.Timeout is zero.
+	DefaultTimeout = 2 * time.Second
+
+	clientPort = 546
+	serverPort = 547
+	// attempts is how many times a message is sent before giving up on a
+	// reply to it.
+	attempts = 3
+
+	duidLL       = 3
+	hwEthernet   = 1
+	statusOK     = 0
+	headerLen    = 4
+	optHeaderLen = 4
+	iaNALen      = 12
+	iaAddrLen    = 24
+)
+
+// Message types.
+const (
+	msgSolicit   = 1
+	msgAdvertise = 2
+	msgRequest   = 3
+	msgReply     = 7
+)
+
+// Options used by the client.
+const (
+	optClientID    = 1
+	optServerID    = 2
+	optIANA        = 3
+	optIAAddr      = 5
+	optElapsedTime = 8
+	optStatusCode  = 13
+)
+
+// allServers is All_DHCP_Relay_Agents_and_Servers.
+var allServers = net.ParseIP("ff02::1:2")
+
+var errTimeout = errors.New("timed out")
+
+// Lease is an address granted by a server.
+type Lease struct {
+	Address net.IP
+	// Preferred and Valid are the lifetimes of the address; Renewal (T1) is
+	// when it should be renewed, half of Preferred unless the server says
+	// otherwise.
+	Preferred time.Duration
+	Valid     time.Duration
+	Renewal   time.Duration
+}
+
+// Client acquires leases through a specific network device.
+type Client struct {
+	// Timeout bounds the wait for each reply; zero means DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Acquire obtains an address on the device with the given name and index
+// for the hardware address hw, which also identifies the client. A
+// requested address, such as the one of an earlier lease, is asked for
+// first. Messages sent while the link-local address is still tentative
+// count as lost.
+func (c Client) Acquire(device string, index int, hw net.HardwareAddr, requested net.IP) (Lease, error) {
+	if len(hw) == 0 {
+		return Lease{}, fmt.Errorf("dhcpv6 on %s: the device has no hardware address", device)
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	conn, err := openSocket(device, index, timeout)
+	if err != nil {
+		return Lease{}, err
+	}
+	defer unix.Close(conn.fd)
+	duid := append([]byte{0, duidLL, 0, hwEthernet}, hw...)
+	return exchange(conn, duid, uint32(index), rand.Uint32()&0xffffff, requested)
+}
+
+// transport carries messages to and from servers. receive returns
+// errTimeout when nothing arrives in time.
+type transport interface {
+	send(msg []byte) error
+	receive(buf []byte) (int, error)
+}
+
+type socket struct {
+	fd    int
+	index int
+}
+
+func openSocket(device string, index int, timeout time.Duration) (socket, error) {
+	fd, err := unix.Socket(unix.AF_INET6, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, unix.IPPROTO_UDP)
+	if err != nil {
+		return socket{}, fmt.Errorf("open dhcpv6 socket: %w", err)
+	}
+	tv := unix.NsecToTimeval(timeout.Nanoseconds())
+	for _, step := range []func() error{
+		func() error { return unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_REUSEADDR, 1) },
+		func() error { return unix.BindToDevice(fd, device) },
+		func() error { return unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv) },
+		func() error { return unix.Bind(fd, &unix.SockaddrInet6{Port: clientPort}) },
+	} {
+		if err := step(); err != nil {
+			unix.Close(fd)
+			return socket{}, fmt.Errorf("configure dhcpv6 socket on %s: %w", device, err)
+		}
+	}
+	return socket{fd: fd, index: index}, nil
+}
+
+func (s socket) send(msg []byte) error {
+	to := &unix.SockaddrInet6{Port: serverPort, ZoneId: uint32(s.index)}
+	copy(to.Addr[:], allServers)
+	err := unix.Sendto(s.fd, msg, 0, to)
+	if errors.Is(err, unix.EADDRNOTAVAIL) {
+		// The link-local address is still tentative.
+		return nil
+	}
+	return err
+}
+
+func (s socket) receive(buf []byte) (int, error) {
+	for {
+		n, _, err := unix.Recvfrom(s.fd, buf, 0)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if errors.Is(err, unix.EAGAIN) {
+			return 0, errTimeout
+		}
+		return n, err
+	}
+}
+
+// exchange runs SOLICIT, ADVERTISE, REQUEST, REPLY with transaction id xid
+// for the identity association iaid.
+func exchange(t transport, duid []byte, iaid, xid uint32, requested net.IP) (Lease, error) {
+	solicit := message{kind: msgSolicit, xid: xid, options: []option{
+		{optClientID, duid},
+		{optElapsedTime, []byte{0, 0}},
+		{optIANA, iaNA(iaid, requested)},
+	}}
+	advertise, err := roundTrip(t, solicit, msgAdvertise)
+	if err != nil {
+		return Lease{}, err
+	}
+	if advertise == nil {
+		return Lease{}, errors.New("no ADVERTISE from any server")
+	}
+	server := advertise.find(optServerID)
+	if server == nil {
+		return Lease{}, errors.New("ADVERTISE without a server identifier")
+	}
+	offered, err := advertise.lease()
+	if err != nil {
+		return Lease{}, err
+	}
+	request := message{kind: msgRequest, xid: xid, options: []option{
+		{optClientID, duid},
+		{optServerID, server},
+		{optElapsedTime, []byte{0, 0}},
+		{optIANA, iaNA(iaid, offered.Address)},
+	}}
+	reply, err := roundTrip(t, request, msgReply)
+	if err != nil {
+		return Lease{}, err
+	}
+	if reply == nil {
+		return Lease{}, errors.New("no REPLY to the REQUEST")
+	}
+	return reply.lease()
+}
+
+// iaNA builds an IA_NA option, asking for address if it is set.
+func iaNA(iaid uint32, address net.IP) []byte {
+	b := binary.BigEndian.AppendUint32(nil, iaid)
+	b = append(b, make([]byte, 8)...)
+	if ip := address.To16(); ip != nil && ip.To4() == nil {
+		addr := append(append([]byte(nil), ip...), make([]byte, 8)...)
+		b = append(b, option{optIAAddr, addr}.marshal()...)
+	}
+	return b
+}
+
+// roundTrip sends msg until a reply of type want arrives for the same
+// transaction. It returns nil when none does.
+func roundTrip(t transport, msg message, want byte) (*message, error) {
+	buf := make([]byte, 1500)
+	for i := 0; i < attempts; i++ {
+		if err := t.send(msg.marshal()); err != nil {
+			return nil, fmt.Errorf("send dhcpv6 message: %w", err)
+		}
+		for {
+			n, err := t.receive(buf)
+			if errors.Is(err, errTimeout) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("receive dhcpv6 message: %w", err)
+			}
+			reply, err := parse(buf[:n])
+			if err == nil && reply.kind == want && reply.xid == msg.xid {
+				return &reply, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+type option struct {
+	code  uint16
+	value []byte
+}
+
+func (o option) marshal() []byte {
+	b := binary.BigEndian.AppendUint16(nil, o.code)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(o.value)))
+	return append(b, o.value...)
+}
+
+func parseOptions(b []byte) ([]option, error) {
+	var options []option
+	for len(b) > 0 {
+		if len(b) < optHeaderLen {
+			return nil, errors.New("truncated dhcpv6 option")
+		}
+		code, length := binary.BigEndian.Uint16(b), int(binary.BigEndian.Uint16(b[2:]))
+		if len(b) < optHeaderLen+length {
+			return nil, errors.New("truncated dhcpv6 option")
+		}
+		options = append(options, option{code, b[optHeaderLen : optHeaderLen+length]})
+		b = b[optHeaderLen+length:]
+	}
+	return options, nil
+}
+
+func find(options []option, code uint16) []byte {
+	for _, o := range options {
+		if o.code == code {
+			return o.value
+		}
+	}
+	return nil
+}
+
+// status returns the message of a non-success status code option, if any.
+func status(options []option) error {
+	value := find(options, optStatusCode)
+	if len(value) < 2 || binary.BigEndian.Uint16(value) == statusOK {
+		return nil
+	}
+	return fmt.Errorf("server status %d: %s", binary.BigEndian.Uint16(value), value[2:])
+}
+
+// message is a client/server message.
+type message struct {
+	kind    byte
+	xid     uint32
+	options []option
+}
+
+func (m message) find(code uint16) []byte {
+	return find(m.options, code)
+}
+
+func (m message) marshal() []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(m.kind)<<24|m.xid&0xffffff)
+	for _, o := range m.options {
+		b = append(b, o.marshal()...)
+	}
+	return b
+}
+
+func parse(b []byte) (message, error) {
+	if len(b) < headerLen {
+		return message{}, errors.New("not a dhcpv6 message")
+	}
+	options, err := parseOptions(b[headerLen:])
+	if err != nil {
+		return message{}, err
+	}
+	return message{kind: b[0], xid: binary.BigEndian.Uint32(b) & 0xffffff, options: options}, nil
+}
+
+// lease reads the address of the IA_NA of an ADVERTISE or REPLY.
+func (m message) lease() (Lease, error) {
+	if err := status(m.options); err != nil {
+		return Lease{}, err
+	}
+	ia := m.find(optIANA)
+	if len(ia) < iaNALen {
+		return Lease{}, errors.New("no address in the server's reply")
+	}
+	options, err := parseOptions(ia[iaNALen:])
+	if err != nil {
+		return Lease{}, err
+	}
+	if err := status(options); err != nil {
+		return Lease{}, err
+	}
+	addr := find(options, optIAAddr)
+	if len(addr) < iaAddrLen {
+		return Lease{}, errors.New("no address in the server's reply")
+	}
+	seconds := func(b []byte) time.Duration {
+		return time.Duration(binary.BigEndian.Uint32(b)) * time.Second
+	}
+	lease := Lease{
+		Address:   net.IP(append([]byte(nil), addr[:net.IPv6len]...)),
+		Preferred: seconds(addr[16:20]),
+		Valid:     seconds(addr[20:24]),
+		Renewal:   seconds(ia[4:8]),
+	}
+	if lease.Renewal == 0 {
+		lease.Renewal = lease.Preferred / 2
+	}
+	return lease, nil
+}
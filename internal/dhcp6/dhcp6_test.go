@@ -0,0 +1,113 @@
+package dhcp6
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer leases 2001:db8::50 with lifetimes of an hour and two, or
+// answers requests with status NoAddrsAvail when full is set.
+type fakeServer struct {
+	t       *testing.T
+	sent    []message
+	replies [][]byte
+	full    bool
+}
+
+func addrOption(ip string, preferred, valid uint32) option {
+	value := append([]byte(nil), net.ParseIP(ip).To16()...)
+	value = binary.BigEndian.AppendUint32(value, preferred)
+	value = binary.BigEndian.AppendUint32(value, valid)
+	return option{optIAAddr, value}
+}
+
+func (f *fakeServer) send(b []byte) error {
+	msg, err := parse(b)
+	if err != nil {
+		f.t.Fatalf("client sent an invalid message: %v", err)
+	}
+	f.sent = append(f.sent, msg)
+	ia := binary.BigEndian.AppendUint32(nil, 7)
+	ia = binary.BigEndian.AppendUint32(ia, 0)
+	ia = binary.BigEndian.AppendUint32(ia, 0)
+	if f.full && msg.kind == msgRequest {
+		ia = append(ia, option{optStatusCode, append([]byte{0, 2}, "no addresses"...)}.marshal()...)
+	} else {
+		ia = append(ia, addrOption("2001:db8::50", 3600, 7200).marshal()...)
+	}
+	reply := message{kind: msgAdvertise, xid: msg.xid, options: []option{{optServerID, []byte{0, 3, 0, 1, 2, 0, 0x5e, 0, 0x53, 0xff}}, {optIANA, ia}}}
+	if msg.kind == msgRequest {
+		reply.kind = msgReply
+	}
+	// A reply of another transaction comes first and must be ignored.
+	other := reply
+	other.xid++
+	f.replies = append(f.replies, other.marshal(), reply.marshal())
+	return nil
+}
+
+func (f *fakeServer) receive(buf []byte) (int, error) {
+	if len(f.replies) == 0 {
+		return 0, errTimeout
+	}
+	n := copy(buf, f.replies[0])
+	f.replies = f.replies[1:]
+	return n, nil
+}
+
+var duid = []byte{0, duidLL, 0, hwEthernet, 0x02, 0x00, 0x5e, 0x00, 0x53, 0x01}
+
+func TestExchangeAcquiresLease(t *testing.T) {
+	server := &fakeServer{t: t}
+	lease, err := exchange(server, duid, 7, 0x123456, net.ParseIP("2001:db8::40"))
+	if err != nil {
+		t.Fatalf("exchange() error = %v", err)
+	}
+	if !lease.Address.Equal(net.ParseIP("2001:db8::50")) || lease.Preferred != time.Hour ||
+		lease.Valid != 2*time.Hour || lease.Renewal != 30*time.Minute {
+		t.Fatalf("lease = %+v, want 2001:db8::50 for 1h/2h renewing after 30m", lease)
+	}
+	if len(server.sent) != 2 {
+		t.Fatalf("client sent %d messages, want a solicit and a request", len(server.sent))
+	}
+	solicit, request := server.sent[0], server.sent[1]
+	if solicit.kind != msgSolicit || !strings.Contains(string(solicit.find(optIANA)), string(net.ParseIP("2001:db8::40"))) {
+		t.Fatalf("solicit = %+v, want one asking for 2001:db8::40", solicit)
+	}
+	if request.kind != msgRequest || request.find(optServerID) == nil || string(request.find(optClientID)) != string(duid) {
+		t.Fatalf("request = %+v, want one naming the server and the client", request)
+	}
+}
+
+func TestExchangeReportsStatus(t *testing.T) {
+	_, err := exchange(&fakeServer{t: t, full: true}, duid, 7, 1, nil)
+	if err == nil || !strings.Contains(err.Error(), "server status 2: no addresses") {
+		t.Fatalf("exchange() error = %v, want the server's status", err)
+	}
+}
+
+func TestExchangeWithoutServer(t *testing.T) {
+	silent := &silentTransport{}
+	if _, err := exchange(silent, duid, 7, 1, nil); err == nil || !strings.Contains(err.Error(), "no ADVERTISE") {
+		t.Fatalf("exchange() error = %v, want no advertise", err)
+	}
+	if silent.sent != attempts {
+		t.Fatalf("client sent %d solicits, want %d", silent.sent, attempts)
+	}
+}
+
+type silentTransport struct{ sent int }
+
+func (s *silentTransport) send([]byte) error { s.sent++; return nil }
+
+func (s *silentTransport) receive([]byte) (int, error) { return 0, errTimeout }
+
+func TestParseRejectsTruncatedOptions(t *testing.T) {
+	b := message{kind: msgReply, xid: 1, options: []option{{optServerID, []byte{1, 2, 3}}}}.marshal()
+	if _, err := parse(b[:len(b)-1]); err == nil {
+		t.Fatal("expected an error for a truncated option")
+	}
+}
@@ -0,0 +1,471 @@
+// Package doctor inspects a host for network misconfigurations that are easy
+// to miss and hard to debug, and suggests a fix for each one.
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/user/goeth/internal/config"
+	"github.com/user/goeth/internal/i18n"
+)
+
+// Host is what the checks read; config.NetlinkAPI implements it.
+type Host interface {
+	LinkList() ([]netlink.Link, error)
+	RouteList(link netlink.Link, family int) ([]netlink.Route, error)
+	// Sysctl reads a kernel parameter by its path below /proc/sys.
+	Sysctl(path string) (string, error)
+	LargeReceiveOffload(name string) (bool, error)
+}
+
+// Finding is a problem found by a check and how to fix it.
+type Finding struct {
+	Check   string
+	Problem string
+	Fix     string
+}
+
+// Doctor runs the checks against Host.
+type Doctor struct {
+	Host Host
+	// Config adds the checks that compare a configuration with the host:
+	// the kernel modules it needs and the routes it adds.
+	Config *config.Configuration
+	// Module reports whether a kernel module is available; nil uses
+	// ModuleLoaded.
+	Module func(name string) (bool, error)
+	// LookupIP resolves names for NAT64 discovery, in the namespace of the
+	// process; nil uses net.LookupIP.
+	LookupIP func(host string) ([]net.IP, error)
+	// Messages localizes the findings; the zero value prints English.
+	Messages i18n.Printer
+}
+
+// Value of rp_filter for strict reverse path filtering (RFC 3704).
+const rpFilterStrict = 1
+
+// mainTable is the id of the main routing table (RT_TABLE_MAIN).
+const mainTable = 254
+
+// Run runs every check and returns the findings in a stable order. A check
+// that cannot read what it needs is reported in the error while the others
+// still run.
+func (d Doctor) Run() ([]Finding, error) {
+	links, err := d.Host.LinkList()
+	if err != nil {
+		return nil, fmt.Errorf("list links: %w", err)
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Attrs().Index < links[j].Attrs().Index })
+	checks := []struct {
+		name string
+		run  func([]netlink.Link) ([]Finding, error)
+	}{
+		{"modules", d.checkModules},
+		{"rp_filter", d.checkRPFilter},
+		{"duplicate-mac", d.checkDuplicateMACs},
+		{"bridge-mtu", d.checkBridgeMTU},
+		{"vxlan-offload", d.checkVXLANOffload},
+		{"ipv6-only", d.checkIPv6Only},
+	}
+	var findings []Finding
+	var errs []error
+	for _, check := range checks {
+		found, err := check.run(links)
+		for i := range found {
+			found[i].Check = check.name
+		}
+		findings = append(findings, found...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("check %s: %w", check.name, err))
+		}
+	}
+	return findings, errors.Join(errs...)
+}
+
+func (d Doctor) checkModules([]netlink.Link) ([]Finding, error) {
+	if d.Config == nil {
+		return nil, nil
+	}
+	loaded := d.Module
+	if loaded == nil {
+		loaded = ModuleLoaded
+	}
+	var findings []Finding
+	for _, req := range requiredModules(*d.Config) {
+		ok, err := loaded(req.module)
+		if err != nil {
+			return findings, err
+		}
+		if !ok {
+			findings = append(findings, Finding{
+				Problem: d.Messages.Sprintf("kernel module %s, needed for %s, is not loaded", req.module, req.purpose),
+				Fix:     d.Messages.Sprintf("run modprobe %s and list it in /etc/modules-load.d; containers cannot load modules on demand", req.module),
+			})
+		}
+	}
+	return findings, nil
+}
+
+type moduleRequirement struct {
+	module  string
+	purpose string
+}
+
+// tunnelModules maps tunnel modes to the modules implementing them.
+var tunnelModules = map[string]string{"gre": "ip_gre", "ipip": "ipip", "sit": "sit"}
+
+func requiredModules(cfg config.Configuration) []moduleRequirement {
+	var reqs []moduleRequirement
+	add := func(module, purpose string) {
+		reqs = append(reqs, moduleRequirement{module, purpose})
+	}
+	switch {
+	case cfg.Bridge != nil:
+		add("bridge", "bridge "+cfg.Interface)
+	case cfg.Bond != nil:
+		add("bonding", "bond "+cfg.Interface)
+	case cfg.WireGuard != nil:
+		add("wireguard", "wireguard "+cfg.Interface)
+	case cfg.Veth != nil:
+		add("veth", "veth "+cfg.Interface)
+	case cfg.Dummy != nil:
+		add("dummy", "dummy "+cfg.Interface)
+	case cfg.Tuntap != nil:
+		add("tun", "tuntap "+cfg.Interface)
+	case cfg.Tunnel != nil:
+		if module, ok := tunnelModules[cfg.Tunnel.Mode]; ok {
+			add(module, cfg.Tunnel.Mode+" tunnel "+cfg.Interface)
+		}
+	}
+	if len(cfg.VLANs) > 0 {
+		add("8021q", "vlans on "+cfg.Interface)
+	}
+	if len(cfg.MACVLANs) > 0 {
+		add("macvlan", "macvlans on "+cfg.Interface)
+	}
+	if len(cfg.IPVLANs) > 0 {
+		add("ipvlan", "ipvlans on "+cfg.Interface)
+	}
+	return reqs
+}
+
+// checkRPFilter finds strict reverse path filtering on links that hold one of
+// several default routes, where replies to traffic sent over one uplink
+// arrive over another and are dropped.
+func (d Doctor) checkRPFilter(links []netlink.Link) ([]Finding, error) {
+	uplinks, err := d.defaultRouteLinks(links)
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	flagged := map[string]bool{}
+	if len(uplinks) > 1 {
+		for _, name := range uplinks {
+			strict, err := d.strictRPFilter(name)
+			if err != nil {
+				return findings, err
+			}
+			if strict {
+				flagged[name] = true
+				findings = append(findings, Finding{
+					Problem: d.Messages.Sprintf("strict reverse path filtering on %s drops replies that arrive over another default route (default routes via %s)", name, strings.Join(uplinks, ", ")),
+					Fix:     d.rpFilterFix(name),
+				})
+			}
+		}
+	}
+	if d.Config == nil || flagged[d.Config.Interface] || !addsDefaultRoute(*d.Config) {
+		return findings, nil
+	}
+	var others []string
+	for _, name := range uplinks {
+		if name != d.Config.Interface {
+			others = append(others, name)
+		}
+	}
+	if len(others) == 0 {
+		return findings, nil
+	}
+	strict, err := d.strictRPFilter(d.Config.Interface)
+	if err != nil {
+		return findings, err
+	}
+	if strict {
+		findings = append(findings, Finding{
+			Problem: d.Messages.Sprintf("the configuration adds a default route via %s next to the one via %s, but reverse path filtering on %s is strict", d.Config.Interface, strings.Join(others, ", "), d.Config.Interface),
+			Fix:     d.rpFilterFix(d.Config.Interface),
+		})
+	}
+	return findings, nil
+}
+
+func (d Doctor) rpFilterFix(name string) string {
+	return d.Messages.Sprintf("switch to loose mode with sysctl -w net/ipv4/conf/%s/rp_filter=2, and net/ipv4/conf/all/rp_filter=2 if that is 1", name)
+}
+
+// defaultRouteLinks returns the names of the links holding an IPv4 default
+// route in the main table, in link order.
+func (d Doctor) defaultRouteLinks(links []netlink.Link) ([]string, error) {
+	return d.routeLinks(links, netlink.FAMILY_V4)
+}
+
+// routeLinks returns the names of the links holding a default route of
+// family in the main table, in link order.
+func (d Doctor) routeLinks(links []netlink.Link, family int) ([]string, error) {
+	routes, err := d.Host.RouteList(nil, family)
+	if err != nil {
+		return nil, fmt.Errorf("list routes: %w", err)
+	}
+	holds := map[int]bool{}
+	for _, route := range routes {
+		if route.Dst != nil && !isDefault(route.Dst) {
+			continue
+		}
+		holds[route.LinkIndex] = true
+		for _, hop := range route.MultiPath {
+			holds[hop.LinkIndex] = true
+		}
+	}
+	var names []string
+	for _, link := range links {
+		if holds[link.Attrs().Index] {
+			names = append(names, link.Attrs().Name)
+		}
+	}
+	return names, nil
+}
+
+func isDefault(dst *net.IPNet) bool {
+	ones, _ := dst.Mask.Size()
+	return ones == 0
+}
+
+// addsDefaultRoute reports whether cfg declares an IPv4 unicast default
+// route in the main table. Default routes in other tables are reached through policy
+// rules, which reverse path filtering follows as well.
+func addsDefaultRoute(cfg config.Configuration) bool {
+	for _, route := range cfg.Routes {
+		if route.Table != 0 && route.Table != mainTable || route.Type != "" && route.Type != "unicast" {
+			continue
+		}
+		switch route.Destination {
+		case "0.0.0.0/0":
+			return true
+		case "default":
+			gateway := route.Gateway
+			if len(route.Nexthops) > 0 {
+				gateway = route.Nexthops[0].Gateway
+			}
+			if gw := net.ParseIP(gateway); gw == nil && !cfg.IPv6Only() || gw != nil && gw.To4() != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// strictRPFilter reports whether the effective rp_filter of the link, the
+// larger of its own and the "all" value, is strict. A link that does not
+// exist yet only has the "all" value.
+func (d Doctor) strictRPFilter(name string) (bool, error) {
+	mode := 0
+	for _, conf := range []string{"all", name} {
+		raw, err := d.Host.Sysctl("net/ipv4/conf/" + conf + "/rp_filter")
+		if err != nil {
+			if conf == name && errors.Is(err, fs.ErrNotExist) {
+				break
+			}
+			return false, err
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return false, fmt.Errorf("rp_filter of %s: invalid value %q", conf, raw)
+		}
+		mode = max(mode, value)
+	}
+	return mode == rpFilterStrict, nil
+}
+
+// checkDuplicateMACs finds Ethernet addresses shared by unrelated links.
+// VLANs and other stacked links share the address of the link below them,
+// bond members that of the bond and a bridge that of a port, so those pairs
+// are not reported.
+func (d Doctor) checkDuplicateMACs(links []netlink.Link) ([]Finding, error) {
+	stacks := newStacks(links)
+	owners := map[string][]netlink.Link{}
+	var macs []string
+	for _, link := range links {
+		attrs := link.Attrs()
+		if !isEthernetMAC(attrs.HardwareAddr) || attrs.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		mac := attrs.HardwareAddr.String()
+		if owners[mac] == nil {
+			macs = append(macs, mac)
+		}
+		owners[mac] = append(owners[mac], link)
+	}
+	sort.Strings(macs)
+	var findings []Finding
+	for _, mac := range macs {
+		if !stacks.unrelated(owners[mac]) {
+			continue
+		}
+		var names []string
+		for _, link := range owners[mac] {
+			names = append(names, link.Attrs().Name)
+		}
+		findings = append(findings, Finding{
+			Problem: d.Messages.Sprintf("MAC address %s is used by %s", mac, strings.Join(names, ", ")),
+			Fix:     d.Messages.Sprintf("give all but one of them a unique address, e.g. with goeth link set-mac; cloned VMs and containers often inherit the same one"),
+		})
+	}
+	return findings, nil
+}
+
+// stacks relates links by what they are stacked on.
+type stacks map[int]netlink.Link
+
+func newStacks(links []netlink.Link) stacks {
+	s := stacks{}
+	for _, link := range links {
+		s[link.Attrs().Index] = link
+	}
+	return s
+}
+
+// base follows the parents of a stacked link down to the link at the bottom.
+// The parent of a veth is its peer, which is not stacked below it.
+func (s stacks) base(link netlink.Link) netlink.Link {
+	for seen := 0; seen < len(s); seen++ {
+		if _, ok := link.(*netlink.Veth); ok {
+			break
+		}
+		parent, ok := s[link.Attrs().ParentIndex]
+		if !ok || parent == link {
+			break
+		}
+		link = parent
+	}
+	return link
+}
+
+// related reports whether two links may legitimately share an address.
+func (s stacks) related(a, b netlink.Link) bool {
+	a, b = s.base(a), s.base(b)
+	ma, mb := a.Attrs().MasterIndex, b.Attrs().MasterIndex
+	if a == b || ma == b.Attrs().Index || mb == a.Attrs().Index {
+		return true
+	}
+	_, bond := s[ma].(*netlink.Bond)
+	return ma != 0 && ma == mb && bond
+}
+
+func (s stacks) unrelated(links []netlink.Link) bool {
+	for i := range links {
+		for j := i + 1; j < len(links); j++ {
+			if !s.related(links[i], links[j]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isEthernetMAC skips the 4- and 16-byte addresses of IP tunnels and
+// unassigned all-zero addresses.
+func isEthernetMAC(mac net.HardwareAddr) bool {
+	if len(mac) != 6 {
+		return false
+	}
+	for _, b := range mac {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkBridgeMTU finds bridges whose ports disagree on the MTU. The bridge
+// forwards at the smallest one, so larger frames from the other ports are
+// dropped silently.
+func (d Doctor) checkBridgeMTU(links []netlink.Link) ([]Finding, error) {
+	var findings []Finding
+	for _, bridge := range links {
+		if _, ok := bridge.(*netlink.Bridge); !ok {
+			continue
+		}
+		var ports []string
+		mtus := map[int]bool{}
+		smallest := 0
+		for _, port := range links {
+			attrs := port.Attrs()
+			if attrs.MasterIndex != bridge.Attrs().Index {
+				continue
+			}
+			ports = append(ports, fmt.Sprintf("%s %d", attrs.Name, attrs.MTU))
+			mtus[attrs.MTU] = true
+			if smallest == 0 || attrs.MTU < smallest {
+				smallest = attrs.MTU
+			}
+		}
+		if len(mtus) < 2 {
+			continue
+		}
+		findings = append(findings, Finding{
+			Problem: d.Messages.Sprintf("ports of bridge %s have different MTUs (%s); frames larger than %d are dropped", bridge.Attrs().Name, strings.Join(ports, ", "), smallest),
+			Fix:     d.Messages.Sprintf("set the same MTU on every port, e.g. goeth link set-mtu --interface <port> --mtu <mtu>"),
+		})
+	}
+	return findings, nil
+}
+
+// checkVXLANOffload finds large receive offload on the underlay of VXLAN
+// devices. LRO merges the encapsulated frames into oversized packets that
+// are dropped or mangled once decapsulated and forwarded. A VXLAN without a
+// bound device is assumed to use the default route links.
+func (d Doctor) checkVXLANOffload(links []netlink.Link) ([]Finding, error) {
+	byIndex := map[int]string{}
+	for _, link := range links {
+		byIndex[link.Attrs().Index] = link.Attrs().Name
+	}
+	var uplinks []string
+	var findings []Finding
+	for _, link := range links {
+		vxlan, ok := link.(*netlink.Vxlan)
+		if !ok {
+			continue
+		}
+		underlays := []string{byIndex[vxlan.VtepDevIndex]}
+		if vxlan.VtepDevIndex == 0 || underlays[0] == "" {
+			if uplinks == nil {
+				var err error
+				if uplinks, err = d.defaultRouteLinks(links); err != nil {
+					return findings, err
+				}
+			}
+			underlays = uplinks
+		}
+		for _, underlay := range underlays {
+			lro, err := d.Host.LargeReceiveOffload(underlay)
+			if err != nil {
+				return findings, err
+			}
+			if lro {
+				findings = append(findings, Finding{
+					Problem: d.Messages.Sprintf("large receive offload is enabled on %s, the underlay of vxlan %s", underlay, vxlan.Name),
+					Fix:     d.Messages.Sprintf("disable it with ethtool -K %s lro off", underlay),
+				})
+			}
+		}
+	}
+	return findings, nil
+}
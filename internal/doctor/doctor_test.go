@@ -0,0 +1,271 @@
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/user/goeth/internal/config"
+)
+
+type mockHost struct {
+	links   []netlink.Link
+	routes  []netlink.Route
+	routes6 []netlink.Route
+	sysctls map[string]string
+	lro     map[string]bool
+}
+
+func (m mockHost) LinkList() ([]netlink.Link, error) { return m.links, nil }
+
+func (m mockHost) RouteList(link netlink.Link, family int) ([]netlink.Route, error) {
+	if family == netlink.FAMILY_V6 {
+		return m.routes6, nil
+	}
+	return m.routes, nil
+}
+
+func (m mockHost) Sysctl(path string) (string, error) {
+	value, ok := m.sysctls[path]
+	if !ok {
+		return "", fmt.Errorf("open %s: %w", path, fs.ErrNotExist)
+	}
+	return value, nil
+}
+
+func (m mockHost) LargeReceiveOffload(name string) (bool, error) {
+	return m.lro[name], nil
+}
+
+func device(index int, name, mac string, mtu int) netlink.LinkAttrs {
+	hw, _ := net.ParseMAC(mac)
+	return netlink.LinkAttrs{Index: index, Name: name, HardwareAddr: hw, MTU: mtu}
+}
+
+func defaultRoute(index int) netlink.Route {
+	return netlink.Route{LinkIndex: index, Gw: net.ParseIP("192.0.2.1")}
+}
+
+func checks(findings []Finding) []string {
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.Check+": "+f.Problem)
+	}
+	return got
+}
+
+func TestDoctorHealthyHost(t *testing.T) {
+	host := mockHost{
+		links: []netlink.Link{
+			&netlink.Device{LinkAttrs: device(1, "lo", "", 65536)},
+			&netlink.Device{LinkAttrs: device(2, "eth0", "02:00:00:00:00:01", 1500)},
+		},
+		routes:  []netlink.Route{defaultRoute(2)},
+		sysctls: map[string]string{"net/ipv4/conf/all/rp_filter": "1", "net/ipv4/conf/eth0/rp_filter": "1"},
+	}
+	findings, err := Doctor{Host: host}.Run()
+	if err != nil || len(findings) != 0 {
+		t.Fatalf("Run() = %v, %v; want no findings", checks(findings), err)
+	}
+}
+
+func TestDoctorStrictRPFilterOnSeveralUplinks(t *testing.T) {
+	host := mockHost{
+		links: []netlink.Link{
+			&netlink.Device{LinkAttrs: device(2, "eth0", "02:00:00:00:00:01", 1500)},
+			&netlink.Device{LinkAttrs: device(3, "eth1", "02:00:00:00:00:02", 1500)},
+		},
+		routes: []netlink.Route{defaultRoute(2), {MultiPath: []*netlink.NexthopInfo{{LinkIndex: 3}}}},
+		sysctls: map[string]string{
+			"net/ipv4/conf/all/rp_filter":  "0",
+			"net/ipv4/conf/eth0/rp_filter": "2",
+			"net/ipv4/conf/eth1/rp_filter": "1",
+		},
+	}
+	findings, err := Doctor{Host: host}.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := []string{"rp_filter: strict reverse path filtering on eth1 drops replies that arrive over another default route (default routes via eth0, eth1)"}
+	if got := checks(findings); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Run() = %q, want %q", got, want)
+	}
+	if !strings.Contains(findings[0].Fix, "net/ipv4/conf/eth1/rp_filter=2") {
+		t.Fatalf("unexpected fix %q", findings[0].Fix)
+	}
+}
+
+func TestDoctorStrictRPFilterAgainstConfig(t *testing.T) {
+	host := mockHost{
+		links:   []netlink.Link{&netlink.Device{LinkAttrs: device(2, "eth0", "02:00:00:00:00:01", 1500)}},
+		routes:  []netlink.Route{defaultRoute(2)},
+		sysctls: map[string]string{"net/ipv4/conf/all/rp_filter": "1"},
+	}
+	cfg := &config.Configuration{Interface: "wwan0", Routes: []config.Route{{Destination: "default", Gateway: "198.51.100.1"}}}
+	findings, err := Doctor{Host: host, Config: cfg}.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := []string{"rp_filter: the configuration adds a default route via wwan0 next to the one via eth0, but reverse path filtering on wwan0 is strict"}
+	if got := checks(findings); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Run() = %q, want %q", got, want)
+	}
+
+	cfg.Routes = []config.Route{{Destination: "default", Gateway: "2001:db8::1"}}
+	if findings, err := (Doctor{Host: host, Config: cfg}).Run(); err != nil || len(findings) != 0 {
+		t.Fatalf("IPv6 default route: Run() = %v, %v; want no findings", checks(findings), err)
+	}
+}
+
+func TestDoctorDuplicateMACs(t *testing.T) {
+	vlan := &netlink.Vlan{LinkAttrs: device(4, "eth0.100", "02:00:00:00:00:01", 1500), VlanId: 100}
+	vlan.ParentIndex = 2
+	slave0 := &netlink.Device{LinkAttrs: device(5, "eth2", "02:00:00:00:00:05", 1500)}
+	slave1 := &netlink.Device{LinkAttrs: device(6, "eth3", "02:00:00:00:00:05", 1500)}
+	bond := &netlink.Bond{LinkAttrs: device(7, "bond0", "02:00:00:00:00:05", 1500)}
+	slave0.MasterIndex, slave1.MasterIndex = 7, 7
+	qinq := &netlink.Vlan{LinkAttrs: device(12, "eth0.100.200", "02:00:00:00:00:01", 1500), VlanId: 200}
+	qinq.ParentIndex = 4
+	// A bridge takes the address of a port, but a veth names its peer as
+	// parent without being stacked on it.
+	veth := func(index int, name, mac string, master, peer int) netlink.Link {
+		attrs := device(index, name, mac, 1500)
+		attrs.MasterIndex, attrs.ParentIndex = master, peer
+		return &netlink.Veth{LinkAttrs: attrs}
+	}
+	host := mockHost{
+		links: []netlink.Link{
+			&netlink.Device{LinkAttrs: device(1, "lo", "00:00:00:00:00:00", 65536)},
+			&netlink.Device{LinkAttrs: device(2, "eth0", "02:00:00:00:00:01", 1500)},
+			&netlink.Device{LinkAttrs: device(3, "eth1", "02:00:00:00:00:01", 1500)},
+			vlan, slave0, slave1, bond,
+			&netlink.Device{LinkAttrs: device(8, "dummy0", "00:00:00:00:00:00", 1500)},
+			&netlink.Bridge{LinkAttrs: device(9, "br0", "02:00:00:00:00:0a", 1500)},
+			veth(10, "veth0", "02:00:00:00:00:0a", 9, 11),
+			veth(11, "veth1", "02:00:00:00:00:0a", 0, 10),
+			qinq,
+		},
+	}
+	findings, err := Doctor{Host: host}.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := []string{
+		"duplicate-mac: MAC address 02:00:00:00:00:01 is used by eth0, eth1, eth0.100, eth0.100.200",
+		"duplicate-mac: MAC address 02:00:00:00:00:0a is used by br0, veth0, veth1",
+	}
+	if got := checks(findings); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Run() = %q, want %q", got, want)
+	}
+
+	var related []netlink.Link
+	for _, link := range host.links {
+		if name := link.Attrs().Name; name != "eth1" && name != "veth1" {
+			related = append(related, link)
+		}
+	}
+	host.links = related
+	if findings, err := (Doctor{Host: host}).Run(); err != nil || len(findings) != 0 {
+		t.Fatalf("stacked links only: Run() = %q, %v; want no findings", checks(findings), err)
+	}
+}
+
+func TestDoctorBridgeMTU(t *testing.T) {
+	port := func(index int, name string, mtu int) netlink.Link {
+		attrs := device(index, name, fmt.Sprintf("02:00:00:00:00:%02x", index), mtu)
+		attrs.MasterIndex = 1
+		return &netlink.Device{LinkAttrs: attrs}
+	}
+	host := mockHost{
+		links: []netlink.Link{
+			&netlink.Bridge{LinkAttrs: device(1, "br0", "02:00:00:00:00:01", 1500)},
+			port(2, "eth0", 9000),
+			port(3, "eth1", 1500),
+			&netlink.Bridge{LinkAttrs: device(4, "br1", "02:00:00:00:00:04", 1500)},
+		},
+	}
+	findings, err := Doctor{Host: host}.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := []string{"bridge-mtu: ports of bridge br0 have different MTUs (eth0 9000, eth1 1500); frames larger than 1500 are dropped"}
+	if got := checks(findings); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Run() = %q, want %q", got, want)
+	}
+}
+
+func TestDoctorVXLANOffload(t *testing.T) {
+	host := mockHost{
+		links: []netlink.Link{
+			&netlink.Device{LinkAttrs: device(2, "eth0", "02:00:00:00:00:01", 1500)},
+			&netlink.Device{LinkAttrs: device(3, "eth1", "02:00:00:00:00:02", 1500)},
+			&netlink.Vxlan{LinkAttrs: device(4, "vxlan10", "02:00:00:00:00:04", 1450), VxlanId: 10, VtepDevIndex: 3},
+			&netlink.Vxlan{LinkAttrs: device(5, "vxlan20", "02:00:00:00:00:05", 1450), VxlanId: 20},
+		},
+		routes:  []netlink.Route{defaultRoute(2)},
+		sysctls: map[string]string{"net/ipv4/conf/all/rp_filter": "0"},
+		lro:     map[string]bool{"eth0": true, "eth1": true},
+	}
+	findings, err := Doctor{Host: host}.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := []string{
+		"vxlan-offload: large receive offload is enabled on eth1, the underlay of vxlan vxlan10",
+		"vxlan-offload: large receive offload is enabled on eth0, the underlay of vxlan vxlan20",
+	}
+	if got := checks(findings); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Run() = %q, want %q", got, want)
+	}
+}
+
+func TestDoctorModules(t *testing.T) {
+	cfg := &config.Configuration{
+		Interface: "br0",
+		Bridge:    &config.Bridge{},
+		VLANs:     []config.VLAN{{Name: "br0.10", ID: 10}},
+	}
+	var asked []string
+	loaded := func(name string) (bool, error) {
+		asked = append(asked, name)
+		return name == "bridge", nil
+	}
+	findings, err := Doctor{Host: mockHost{}, Config: cfg, Module: loaded}.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if want := []string{"bridge", "8021q"}; !reflect.DeepEqual(asked, want) {
+		t.Fatalf("asked for %v, want %v", asked, want)
+	}
+	want := []string{"modules: kernel module 8021q, needed for vlans on br0, is not loaded"}
+	if got := checks(findings); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Run() = %q, want %q", got, want)
+	}
+}
+
+type failingSysctl struct{ mockHost }
+
+func (failingSysctl) Sysctl(string) (string, error) { return "", errors.New("permission denied") }
+
+func TestDoctorKeepsCheckingAfterAFailure(t *testing.T) {
+	host := failingSysctl{mockHost{
+		links: []netlink.Link{
+			&netlink.Device{LinkAttrs: device(2, "eth0", "02:00:00:00:00:01", 1500)},
+			&netlink.Device{LinkAttrs: device(3, "eth1", "02:00:00:00:00:01", 1500)},
+		},
+		routes: []netlink.Route{defaultRoute(2), defaultRoute(3)},
+	}}
+	findings, err := Doctor{Host: host}.Run()
+	if err == nil || !strings.Contains(err.Error(), "check rp_filter: permission denied") {
+		t.Fatalf("expected the rp_filter check to fail, got %v", err)
+	}
+	if len(findings) != 1 || findings[0].Check != "duplicate-mac" {
+		t.Fatalf("expected the remaining checks to run, got %q", checks(findings))
+	}
+}
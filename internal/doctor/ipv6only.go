@@ -0,0 +1,160 @@
+package doctor
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+// nat64Discovery is the well-known name DNS64 resolvers synthesize AAAA
+// records for (RFC 7050).
+const nat64Discovery = "ipv4only.arpa"
+
+var (
+	// nat64WellKnown is the well-known NAT64 prefix (RFC 6052).
+	nat64WellKnown = mustCIDR("64:ff9b::/96")
+	// nat64Addresses are the A records of ipv4only.arpa.
+	nat64Addresses = []net.IP{net.IPv4(192, 0, 0, 170), net.IPv4(192, 0, 0, 171)}
+	// nat64Lengths are the prefix lengths RFC 6052 can embed IPv4 in,
+	// longest first.
+	nat64Lengths = []int{96, 64, 56, 48, 40, 32}
+	// clatPrefixes match the devices CLATs create: clatd's "clat" and
+	// Android's "v4-<uplink>".
+	clatPrefixes = []string{"clat", "v4-"}
+)
+
+// nat64U is the octet of an IPv6 address RFC 6052 keeps zero, which
+// embedded IPv4 addresses skip.
+const nat64U = 8
+
+func mustCIDR(raw string) *net.IPNet {
+	_, cidr, err := net.ParseCIDR(raw)
+	if err != nil {
+		panic(err)
+	}
+	return cidr
+}
+
+// checkIPv6Only looks for a way to reach IPv4 destinations on a host without
+// IPv4 connectivity: NAT64 for applications using names and a CLAT (464XLAT)
+// for those using IPv4 addresses or sockets. It runs on hosts with an IPv6
+// default route but no IPv4 one outside a CLAT, and for configurations with
+// the ipv6-only profile.
+func (d Doctor) checkIPv6Only(links []netlink.Link) ([]Finding, error) {
+	ipv6Only, err := d.ipv6Only(links)
+	if err != nil || !ipv6Only {
+		return nil, err
+	}
+	if hasCLAT(links) {
+		return nil, nil
+	}
+	prefix, err := d.nat64Prefix()
+	if err != nil {
+		return nil, err
+	}
+	if prefix == nil {
+		return []Finding{{
+			Problem: d.Messages.Sprintf("the host is IPv6-only and no NAT64 prefix was found, so IPv4 destinations are unreachable"),
+			Fix:     d.Messages.Sprintf("use a DNS64 resolver backed by a NAT64 gateway, or route %s to one", nat64WellKnown),
+		}}, nil
+	}
+	return []Finding{{
+		Problem: d.Messages.Sprintf("NAT64 prefix %s is available but no CLAT is running, so applications using IPv4 addresses fail", prefix),
+		Fix:     d.Messages.Sprintf("run a CLAT such as clatd to provide 464XLAT (RFC 6877)"),
+	}}, nil
+}
+
+// ipv6Only reports whether the host (or the configuration) is IPv6-only.
+func (d Doctor) ipv6Only(links []netlink.Link) (bool, error) {
+	if d.Config != nil && d.Config.IPv6Only() {
+		return true, nil
+	}
+	uplinks, err := d.routeLinks(links, netlink.FAMILY_V6)
+	if err != nil || len(uplinks) == 0 {
+		return false, err
+	}
+	if uplinks, err = d.defaultRouteLinks(links); err != nil {
+		return false, err
+	}
+	for _, name := range uplinks {
+		if !isCLAT(name) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func hasCLAT(links []netlink.Link) bool {
+	for _, link := range links {
+		if isCLAT(link.Attrs().Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func isCLAT(name string) bool {
+	for _, prefix := range clatPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// nat64Prefix returns a route to the well-known NAT64 prefix or the prefix
+// discovered through DNS64, nil when there is neither.
+func (d Doctor) nat64Prefix() (*net.IPNet, error) {
+	routes, err := d.Host.RouteList(nil, netlink.FAMILY_V6)
+	if err != nil {
+		return nil, err
+	}
+	for _, route := range routes {
+		if route.Dst != nil && route.Dst.String() == nat64WellKnown.String() {
+			return nat64WellKnown, nil
+		}
+	}
+	lookup := d.LookupIP
+	if lookup == nil {
+		lookup = net.LookupIP
+	}
+	ips, err := lookup(nat64Discovery)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if prefix := synthesizedPrefix(ip); prefix != nil {
+			return prefix, nil
+		}
+	}
+	return nil, nil
+}
+
+// synthesizedPrefix returns the NAT64 prefix ip was synthesized with when
+// it embeds one of the addresses of ipv4only.arpa.
+func synthesizedPrefix(ip net.IP) *net.IPNet {
+	if ip.To4() != nil || len(ip) != net.IPv6len {
+		return nil
+	}
+	for _, length := range nat64Lengths {
+		embedded := make(net.IP, 0, net.IPv4len)
+		for i := length / 8; len(embedded) < net.IPv4len; i++ {
+			if i != nat64U {
+				embedded = append(embedded, ip[i])
+			}
+		}
+		for _, known := range nat64Addresses {
+			if known.Equal(embedded) {
+				mask := net.CIDRMask(length, 8*net.IPv6len)
+				return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+			}
+		}
+	}
+	return nil
+}
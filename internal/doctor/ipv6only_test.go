@@ -0,0 +1,107 @@
+package doctor
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/user/goeth/internal/config"
+)
+
+func ipv6OnlyHost(links ...netlink.Link) mockHost {
+	return mockHost{
+		links:   append([]netlink.Link{&netlink.Device{LinkAttrs: device(2, "eth0", "02:00:00:00:00:01", 1500)}}, links...),
+		routes6: []netlink.Route{{LinkIndex: 2, Gw: net.ParseIP("fe80::1")}},
+	}
+}
+
+func lookupAnswers(ips ...string) func(string) ([]net.IP, error) {
+	return func(host string) ([]net.IP, error) {
+		if host != nat64Discovery {
+			return nil, errors.New("unexpected lookup " + host)
+		}
+		if len(ips) == 0 {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		var answers []net.IP
+		for _, ip := range ips {
+			answers = append(answers, net.ParseIP(ip))
+		}
+		return answers, nil
+	}
+}
+
+func TestDoctorIPv6OnlyWithoutNAT64(t *testing.T) {
+	findings, err := Doctor{Host: ipv6OnlyHost(), LookupIP: lookupAnswers()}.Run()
+	want := []string{"ipv6-only: the host is IPv6-only and no NAT64 prefix was found, so IPv4 destinations are unreachable"}
+	if err != nil || !reflect.DeepEqual(checks(findings), want) {
+		t.Fatalf("Run() = %v, %v; want %v", checks(findings), err, want)
+	}
+}
+
+func TestDoctorIPv6OnlyWithoutCLAT(t *testing.T) {
+	findings, err := Doctor{Host: ipv6OnlyHost(), LookupIP: lookupAnswers("192.0.0.170", "2001:db8:64::c000:aa")}.Run()
+	want := []string{"ipv6-only: NAT64 prefix 2001:db8:64::/96 is available but no CLAT is running, so applications using IPv4 addresses fail"}
+	if err != nil || !reflect.DeepEqual(checks(findings), want) {
+		t.Fatalf("Run() = %v, %v; want %v", checks(findings), err, want)
+	}
+
+	host := ipv6OnlyHost()
+	host.routes6 = append(host.routes6, netlink.Route{LinkIndex: 2, Dst: nat64WellKnown, Gw: net.ParseIP("fe80::1")})
+	findings, err = Doctor{Host: host, LookupIP: lookupAnswers()}.Run()
+	want = []string{"ipv6-only: NAT64 prefix 64:ff9b::/96 is available but no CLAT is running, so applications using IPv4 addresses fail"}
+	if err != nil || !reflect.DeepEqual(checks(findings), want) {
+		t.Fatalf("Run() with a NAT64 route = %v, %v; want %v", checks(findings), err, want)
+	}
+}
+
+func TestDoctorIPv6OnlyWithCLAT(t *testing.T) {
+	host := ipv6OnlyHost(&netlink.Tuntap{LinkAttrs: device(3, "clat", "", 1260)})
+	host.routes = []netlink.Route{{LinkIndex: 3}}
+	findings, err := Doctor{Host: host, LookupIP: lookupAnswers("64:ff9b::c000:aa")}.Run()
+	if err != nil || len(findings) != 0 {
+		t.Fatalf("Run() = %v, %v; want no findings", checks(findings), err)
+	}
+}
+
+func TestDoctorIPv6OnlyLookupFailure(t *testing.T) {
+	lookup := func(string) ([]net.IP, error) { return nil, errors.New("dial udp: network is unreachable") }
+	_, err := Doctor{Host: ipv6OnlyHost(), LookupIP: lookup}.Run()
+	if err == nil || err.Error() != "check ipv6-only: dial udp: network is unreachable" {
+		t.Fatalf("expected the lookup error, got %v", err)
+	}
+}
+
+func TestDoctorIPv6OnlyProfile(t *testing.T) {
+	host := mockHost{links: []netlink.Link{&netlink.Device{LinkAttrs: device(2, "eth0", "02:00:00:00:00:01", 1500)}}}
+	cfg := config.Configuration{Interface: "eth0", Profile: config.ProfileIPv6Only, Routes: []config.Route{{Destination: "default"}}}
+	module := func(string) (bool, error) { return true, nil }
+	findings, err := Doctor{Host: host, Config: &cfg, Module: module, LookupIP: lookupAnswers()}.Run()
+	want := []string{"ipv6-only: the host is IPv6-only and no NAT64 prefix was found, so IPv4 destinations are unreachable"}
+	if err != nil || !reflect.DeepEqual(checks(findings), want) {
+		t.Fatalf("Run() = %v, %v; want %v", checks(findings), err, want)
+	}
+}
+
+func TestSynthesizedPrefix(t *testing.T) {
+	cases := map[string]string{
+		"64:ff9b::c000:ab":             "64:ff9b::/96",
+		"2001:db8:122:344:c0:0:aa00:0": "2001:db8:122:344::/64",
+		"2001:db8:c000:aa::":           "2001:db8::/32",
+		"2001:db8:1c0:0:aa::":          "2001:db8:100::/40",
+		"2001:db8::1":                  "",
+		"192.0.0.170":                  "",
+	}
+	for raw, want := range cases {
+		got := ""
+		if prefix := synthesizedPrefix(net.ParseIP(raw)); prefix != nil {
+			got = prefix.String()
+		}
+		if got != want {
+			t.Fatalf("synthesizedPrefix(%s) = %q, want %q", raw, got, want)
+		}
+	}
+}
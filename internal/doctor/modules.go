@@ -0,0 +1,57 @@
+package doctor
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// sysModule lists loaded modules and built-in ones that have parameters.
+	sysModule = "/sys/module"
+	// libModules holds modules.builtin, which lists every built-in module.
+	libModules = "/lib/modules"
+)
+
+// ModuleLoaded reports whether the named kernel module is loaded or built
+// into the running kernel.
+func ModuleLoaded(name string) (bool, error) {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return false, err
+	}
+	release := unix.ByteSliceToString(uts.Release[:])
+	return moduleLoaded(sysModule, filepath.Join(libModules, release, "modules.builtin"), name)
+}
+
+// moduleLoaded looks for name below sysDir and in the builtin list. Module
+// names treat '-' and '_' alike.
+func moduleLoaded(sysDir, builtin, name string) (bool, error) {
+	name = strings.ReplaceAll(name, "-", "_")
+	if _, err := os.Stat(filepath.Join(sysDir, name)); err == nil {
+		return true, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	file, err := os.Open(builtin)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		module := strings.TrimSuffix(filepath.Base(scanner.Text()), ".ko")
+		if strings.ReplaceAll(module, "-", "_") == name {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
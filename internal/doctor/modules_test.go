@@ -0,0 +1,34 @@
+package doctor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestModuleLoaded(t *testing.T) {
+	dir := t.TempDir()
+	sysDir := filepath.Join(dir, "module")
+	if err := os.MkdirAll(filepath.Join(sysDir, "ip_gre"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	builtin := filepath.Join(dir, "modules.builtin")
+	if err := os.WriteFile(builtin, []byte("kernel/net/bridge/bridge.ko\nkernel/net/8021q/8021q.ko\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{"ip_gre": true, "ip-gre": true, "bridge": true, "8021q": true, "bonding": false}
+	for name, want := range cases {
+		got, err := moduleLoaded(sysDir, builtin, name)
+		if err != nil || got != want {
+			t.Fatalf("moduleLoaded(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+}
+
+func TestModuleLoadedWithoutBuiltinList(t *testing.T) {
+	dir := t.TempDir()
+	got, err := moduleLoaded(dir, filepath.Join(dir, "missing"), "bonding")
+	if err != nil || got {
+		t.Fatalf("moduleLoaded() = %v, %v; want false, nil", got, err)
+	}
+}
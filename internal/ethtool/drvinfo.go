@@ -0,0 +1,58 @@
+package ethtool
+
+import (
+	"slices"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// drvinfoLen is the length of the strings of struct ethtool_drvinfo.
+const drvinfoLen = 32
+
+// drvinfo is struct ethtool_drvinfo.
+type drvinfo struct {
+	cmd         uint32
+	driver      [drvinfoLen]byte
+	version     [drvinfoLen]byte
+	fwVersion   [drvinfoLen]byte
+	busInfo     [drvinfoLen]byte
+	eromVersion [drvinfoLen]byte
+	_           [12]byte
+	_           [5]uint32
+}
+
+// DriverInfo identifies the driver and hardware behind a device, as
+// ethtool(8) -i shows them.
+type DriverInfo struct {
+	Driver string
+	// Version is the version of the driver, often the kernel's.
+	Version         string
+	FirmwareVersion string
+	// BusInfo locates the device on its bus, such as the PCI address
+	// "0000:03:00.0" or a USB port; it is empty for virtual devices.
+	BusInfo string
+}
+
+// GetDriverInfo reads the driver information of the named device. Devices
+// without a driver that tells, such as the loopback, fail with EOPNOTSUPP.
+func GetDriverInfo(name string) (DriverInfo, error) {
+	d := drvinfo{cmd: unix.ETHTOOL_GDRVINFO}
+	if _, err := ioctl(name, unsafe.Pointer(&d)); err != nil {
+		return DriverInfo{}, err
+	}
+	return DriverInfo{
+		Driver:          cString(d.driver[:]),
+		Version:         cString(d.version[:]),
+		FirmwareVersion: cString(d.fwVersion[:]),
+		BusInfo:         cString(d.busInfo[:]),
+	}, nil
+}
+
+// cString returns the NUL-terminated string at the start of b.
+func cString(b []byte) string {
+	if end := slices.Index(b, 0); end >= 0 {
+		b = b[:end]
+	}
+	return string(b)
+}
@@ -0,0 +1,27 @@
+package ethtool
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestDrvinfoMatchesKernelLayout(t *testing.T) {
+	if size := unsafe.Sizeof(drvinfo{}); size != 196 {
+		t.Fatalf("drvinfo is %d bytes, want 196", size)
+	}
+}
+
+func TestGetDriverInfoMissingDevice(t *testing.T) {
+	if _, err := GetDriverInfo("goeth-missing0"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestCString(t *testing.T) {
+	if got := cString([]byte("e1000e\x00\x00junk")); got != "e1000e" {
+		t.Fatalf("cString() = %q, want e1000e", got)
+	}
+	if got := cString([]byte("full")); got != "full" {
+		t.Fatalf("cString() = %q, want full", got)
+	}
+}
@@ -0,0 +1,78 @@
+// Package ethtool reads and changes device offload settings through the
+// SIOCETHTOOL ioctl, so neither the ethtool binary nor its netlink family is
+// needed.
+package ethtool
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Values from linux/ethtool.h that golang.org/x/sys/unix does not export.
+const (
+	cmdGetFlags = 0x00000025 // ETHTOOL_GFLAGS
+	flagLRO     = 1 << 15    // ETH_FLAG_LRO
+)
+
+// ifreqSize is the size of struct ifreq: the name and a union whose largest
+// member, struct ifmap, is 24 bytes on 64-bit platforms.
+const ifreqSize = unix.IFNAMSIZ + 24
+
+// value is struct ethtool_value.
+type value struct {
+	cmd  uint32
+	data uint32
+}
+
+// ifreq is struct ifreq with the union used as ifr_data.
+type ifreq struct {
+	name [unix.IFNAMSIZ]byte
+	data unsafe.Pointer
+	_    [ifreqSize - unix.IFNAMSIZ - unsafe.Sizeof(uintptr(0))]byte
+}
+
+// LRO reports whether large receive offload is enabled on the named device.
+// Devices without offload support report false.
+func LRO(name string) (bool, error) {
+	flags, err := getFlags(name)
+	if err != nil {
+		return false, err
+	}
+	return flags&flagLRO != 0, nil
+}
+
+func getFlags(name string) (uint32, error) {
+	v := value{cmd: cmdGetFlags}
+	_, err := ioctl(name, unsafe.Pointer(&v))
+	if errors.Is(err, unix.EOPNOTSUPP) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return v.data, nil
+}
+
+// ioctl sends the ethtool command in data for the named device and returns
+// what the kernel returned.
+func ioctl(name string, data unsafe.Pointer) (uintptr, error) {
+	if len(name) >= unix.IFNAMSIZ {
+		return 0, fmt.Errorf("ethtool %s: name too long", name)
+	}
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return 0, fmt.Errorf("ethtool %s: %w", name, err)
+	}
+	defer unix.Close(fd)
+	var req ifreq
+	copy(req.name[:], name)
+	req.data = data
+	ret, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), unix.SIOCETHTOOL, uintptr(unsafe.Pointer(&req)))
+	if errno != 0 {
+		return 0, fmt.Errorf("ethtool %s: %w", name, errno)
+	}
+	return ret, nil
+}
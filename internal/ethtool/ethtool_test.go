@@ -0,0 +1,30 @@
+package ethtool
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestIfreqMatchesKernelLayout(t *testing.T) {
+	if size := unsafe.Sizeof(ifreq{}); size != ifreqSize {
+		t.Fatalf("ifreq is %d bytes, want %d", size, ifreqSize)
+	}
+}
+
+func TestLROLoopback(t *testing.T) {
+	if lro, err := LRO("lo"); err != nil || lro {
+		t.Fatalf("LRO(lo) = %v, %v; want false, nil", lro, err)
+	}
+}
+
+func TestLROMissingDevice(t *testing.T) {
+	if _, err := LRO("goeth-missing0"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestLRONameTooLong(t *testing.T) {
+	if _, err := LRO("a-name-longer-than-ifnamsiz"); err == nil {
+		t.Fatal("expected error")
+	}
+}
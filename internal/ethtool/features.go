@@ -0,0 +1,270 @@
+package ethtool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Values from linux/ethtool.h that golang.org/x/sys/unix does not export.
+const (
+	stringSetFeatures = 4  // ETH_SS_FEATURES
+	stringLen         = 32 // ETH_GSTRING_LEN
+)
+
+// Sizes of the fixed parts of the ethtool structures used below.
+const (
+	ssetInfoSize     = 20 // struct ethtool_sset_info with one data word
+	gstringsSize     = 12 // struct ethtool_gstrings
+	featuresSize     = 8  // struct ethtool_gfeatures and ethtool_sfeatures
+	getBlockSize     = 16 // struct ethtool_get_features_block
+	setBlockSize     = 8  // struct ethtool_set_features_block
+	featuresPerBlock = 32
+)
+
+// aliases expands the short names ethtool(8) -K takes to the kernel's
+// feature names.
+var aliases = map[string][]string{
+	"rx": {"rx-checksum"},
+	"tx": {"tx-checksum-ipv4", "tx-checksum-ip-generic", "tx-checksum-ipv6", "tx-checksum-fcoe-crc", "tx-checksum-sctp"},
+	"sg": {"tx-scatter-gather", "tx-scatter-gather-fraglist"},
+	"tso": {
+		"tx-tcp-segmentation", "tx-tcp-ecn-segmentation", "tx-tcp-mangleid-segmentation", "tx-tcp6-segmentation",
+	},
+	"gso":    {"tx-generic-segmentation"},
+	"gro":    {"rx-gro"},
+	"lro":    {"rx-lro"},
+	"rxvlan": {"rx-vlan-hw-parse"},
+	"txvlan": {"tx-vlan-hw-insert"},
+	"rxhash": {"rx-hashing"},
+	"ntuple": {"rx-ntuple-filter"},
+}
+
+// Aliases lists the short feature names, such as "gro" and "tso".
+func Aliases() []string {
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Feature is the state of one device feature.
+type Feature struct {
+	Active bool
+	// Changeable is false for features the driver fixes on or off.
+	Changeable bool
+}
+
+// Features are the features of a device by their kernel name, such as
+// "rx-gro".
+type Features map[string]Feature
+
+// members returns the features of f that name, a short or a kernel name,
+// stands for.
+func (f Features) members(name string) []string {
+	names, ok := aliases[name]
+	if !ok {
+		names = []string{name}
+	}
+	var present []string
+	for _, feature := range names {
+		if _, ok := f[feature]; ok {
+			present = append(present, feature)
+		}
+	}
+	return present
+}
+
+// Differs reports whether turning name on or off would change the device.
+// Features of a short name that the driver fixes are skipped, as ethtool(8)
+// does; it is an error when the device has none of them, or when all that
+// differ are fixed.
+func (f Features) Differs(name string, on bool) (bool, error) {
+	_, changes, err := f.changes(name, on)
+	return len(changes) > 0, err
+}
+
+// Set records turning name on or off in f, as the kernel would for the
+// features that can be changed.
+func (f Features) Set(name string, on bool) {
+	for _, feature := range f.members(name) {
+		if state := f[feature]; state.Changeable {
+			state.Active = on
+			f[feature] = state
+		}
+	}
+}
+
+// changes returns the members of name and those that differ from on and can
+// be changed.
+func (f Features) changes(name string, on bool) ([]string, []string, error) {
+	members := f.members(name)
+	if len(members) == 0 {
+		return nil, nil, fmt.Errorf("no feature %s", name)
+	}
+	var changes []string
+	fixed := false
+	for _, feature := range members {
+		state := f[feature]
+		switch {
+		case state.Active == on:
+		case state.Changeable:
+			changes = append(changes, feature)
+		default:
+			fixed = true
+		}
+	}
+	if fixed && len(changes) == 0 {
+		return members, nil, fmt.Errorf("%s is fixed %s", name, onOff(!on))
+	}
+	return members, changes, nil
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// GetFeatures reads the features of the named device.
+func GetFeatures(name string) (Features, error) {
+	names, err := featureNames(name)
+	if err != nil {
+		return nil, err
+	}
+	blocks := blockCount(len(names))
+	buf := make([]byte, featuresSize+blocks*getBlockSize)
+	binary.NativeEndian.PutUint32(buf[0:], unix.ETHTOOL_GFEATURES)
+	binary.NativeEndian.PutUint32(buf[4:], uint32(blocks))
+	if _, err := ioctl(name, unsafe.Pointer(&buf[0])); err != nil {
+		return nil, err
+	}
+	features := make(Features, len(names))
+	for i, feature := range names {
+		if feature == "" {
+			continue
+		}
+		block := buf[featuresSize+i/featuresPerBlock*getBlockSize:]
+		bit := uint32(1) << (i % featuresPerBlock)
+		available := binary.NativeEndian.Uint32(block[0:])
+		active := binary.NativeEndian.Uint32(block[8:])
+		neverChanged := binary.NativeEndian.Uint32(block[12:])
+		features[feature] = Feature{
+			Active:     active&bit != 0,
+			Changeable: available&bit != 0 && neverChanged&bit == 0,
+		}
+	}
+	return features, nil
+}
+
+// SetFeatures turns features of the named device on or off, by short or
+// kernel names. It fails when the kernel does not make every change, such as
+// when a feature depends on one that is off.
+func SetFeatures(name string, want map[string]bool) error {
+	names, err := featureNames(name)
+	if err != nil {
+		return err
+	}
+	current, err := GetFeatures(name)
+	if err != nil {
+		return err
+	}
+	blocks := blockCount(len(names))
+	buf := make([]byte, featuresSize+blocks*setBlockSize)
+	binary.NativeEndian.PutUint32(buf[0:], unix.ETHTOOL_SFEATURES)
+	binary.NativeEndian.PutUint32(buf[4:], uint32(blocks))
+	requested := make(map[string]bool)
+	for _, feature := range sortedKeys(want) {
+		_, changes, err := current.changes(feature, want[feature])
+		if err != nil {
+			return fmt.Errorf("ethtool %s: %w", name, err)
+		}
+		for _, change := range changes {
+			requested[change] = want[feature]
+			i := slices.Index(names, change)
+			block := buf[featuresSize+i/featuresPerBlock*setBlockSize:]
+			bit := uint32(1) << (i % featuresPerBlock)
+			binary.NativeEndian.PutUint32(block[0:], binary.NativeEndian.Uint32(block[0:])|bit)
+			if want[feature] {
+				binary.NativeEndian.PutUint32(block[4:], binary.NativeEndian.Uint32(block[4:])|bit)
+			}
+		}
+	}
+	if len(requested) == 0 {
+		return nil
+	}
+	ret, err := ioctl(name, unsafe.Pointer(&buf[0]))
+	if err != nil {
+		return err
+	}
+	if ret&(unix.ETHTOOL_F_WISH|unix.ETHTOOL_F_UNSUPPORTED) == 0 {
+		return nil
+	}
+	after, err := GetFeatures(name)
+	if err != nil {
+		return err
+	}
+	var refused []string
+	for _, feature := range sortedKeys(requested) {
+		if after[feature].Active != requested[feature] {
+			refused = append(refused, feature+" "+onOff(requested[feature]))
+		}
+	}
+	if len(refused) == 0 {
+		return nil
+	}
+	return fmt.Errorf("ethtool %s: the driver refused %s", name, strings.Join(refused, ", "))
+}
+
+// featureNames returns the kernel's names of the features of the device,
+// indexed by their bit.
+func featureNames(name string) ([]string, error) {
+	info := make([]byte, ssetInfoSize)
+	binary.NativeEndian.PutUint32(info[0:], unix.ETHTOOL_GSSET_INFO)
+	binary.NativeEndian.PutUint64(info[8:], 1<<stringSetFeatures)
+	if _, err := ioctl(name, unsafe.Pointer(&info[0])); err != nil {
+		return nil, err
+	}
+	if binary.NativeEndian.Uint64(info[8:]) == 0 {
+		return nil, fmt.Errorf("ethtool %s: %w", name, errors.ErrUnsupported)
+	}
+	count := int(binary.NativeEndian.Uint32(info[16:]))
+	buf := make([]byte, gstringsSize+count*stringLen)
+	binary.NativeEndian.PutUint32(buf[0:], unix.ETHTOOL_GSTRINGS)
+	binary.NativeEndian.PutUint32(buf[4:], stringSetFeatures)
+	binary.NativeEndian.PutUint32(buf[8:], uint32(count))
+	if _, err := ioctl(name, unsafe.Pointer(&buf[0])); err != nil {
+		return nil, err
+	}
+	names := make([]string, count)
+	for i := range names {
+		raw := buf[gstringsSize+i*stringLen:][:stringLen]
+		if end := slices.Index(raw, 0); end >= 0 {
+			raw = raw[:end]
+		}
+		names[i] = string(raw)
+	}
+	return names, nil
+}
+
+func blockCount(features int) int {
+	return (features + featuresPerBlock - 1) / featuresPerBlock
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
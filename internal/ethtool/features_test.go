@@ -0,0 +1,61 @@
+package ethtool
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFeaturesLoopback(t *testing.T) {
+	features, err := GetFeatures("lo")
+	if err != nil {
+		t.Fatalf("GetFeatures(lo) error = %v", err)
+	}
+	if _, ok := features["rx-gro"]; !ok {
+		t.Fatalf("GetFeatures(lo) = %v, want rx-gro among them", features)
+	}
+}
+
+func TestFeaturesDiffers(t *testing.T) {
+	features := Features{
+		"rx-gro":                 {Active: true, Changeable: true},
+		"rx-lro":                 {Active: false},
+		"tx-checksum-ip-generic": {Active: true, Changeable: true},
+		"tx-checksum-sctp":       {Active: true},
+	}
+	tests := []struct {
+		name string
+		on   bool
+		want bool
+		err  string
+	}{
+		{"gro", false, true, ""},
+		{"rx-gro", true, false, ""},
+		{"tx", false, true, ""},
+		{"lro", false, false, ""},
+		{"lro", true, false, "lro is fixed off"},
+		{"tso", false, false, "no feature tso"},
+	}
+	for _, tt := range tests {
+		got, err := features.Differs(tt.name, tt.on)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Differs(%s, %v) error = %v, want %q", tt.name, tt.on, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Fatalf("Differs(%s, %v) = %v, %v; want %v", tt.name, tt.on, got, err, tt.want)
+		}
+	}
+
+	features.Set("tx", false)
+	if features["tx-checksum-ip-generic"].Active || !features["tx-checksum-sctp"].Active {
+		t.Fatalf("Set(tx, false) = %v, want only the changeable checksum off", features)
+	}
+}
+
+func TestFeaturesMissingDevice(t *testing.T) {
+	if _, err := GetFeatures("goeth-missing0"); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,82 @@
+package ethtool
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Values from linux/ethtool.h that golang.org/x/sys/unix does not export.
+const (
+	linkSettingsSize = 48 // struct ethtool_link_settings without the masks
+	speedUnknown     = math.MaxUint32
+	duplexHalf       = 0x00
+	duplexFull       = 0x01
+	// linkModeMasks is how many masks follow struct ethtool_link_settings:
+	// the supported, advertised and partner link modes.
+	linkModeMasks = 3
+)
+
+// Offsets into struct ethtool_link_settings.
+const (
+	speedOffset  = 4
+	duplexOffset = 8
+	nwordsOffset = 15
+)
+
+// Duplex modes of LinkSettings.
+const (
+	DuplexHalf = "half"
+	DuplexFull = "full"
+)
+
+// LinkSettings is what a device negotiated with its link partner.
+type LinkSettings struct {
+	// Speed is in Mb/s, zero when unknown, as while there is no carrier.
+	Speed int
+	// Duplex is DuplexHalf, DuplexFull or empty when unknown.
+	Duplex string
+}
+
+// GetLinkSettings reads the negotiated speed and duplex of the named device.
+// Devices without link settings, such as the loopback, fail with
+// EOPNOTSUPP.
+func GetLinkSettings(name string) (LinkSettings, error) {
+	// The kernel answers a request without room for the link mode masks
+	// with the negated number of words each needs.
+	buf := make([]byte, linkSettingsSize)
+	binary.NativeEndian.PutUint32(buf, unix.ETHTOOL_GLINKSETTINGS)
+	if _, err := ioctl(name, unsafe.Pointer(&buf[0])); err != nil {
+		return LinkSettings{}, err
+	}
+	nwords := -int(int8(buf[nwordsOffset]))
+	if nwords <= 0 {
+		return LinkSettings{}, fmt.Errorf("ethtool %s: link settings handshake failed", name)
+	}
+	buf = make([]byte, linkSettingsSize+linkModeMasks*nwords*4)
+	binary.NativeEndian.PutUint32(buf, unix.ETHTOOL_GLINKSETTINGS)
+	buf[nwordsOffset] = byte(nwords)
+	if _, err := ioctl(name, unsafe.Pointer(&buf[0])); err != nil {
+		return LinkSettings{}, err
+	}
+	return parseLinkSettings(buf), nil
+}
+
+// parseLinkSettings decodes the speed and duplex of struct
+// ethtool_link_settings.
+func parseLinkSettings(b []byte) LinkSettings {
+	var settings LinkSettings
+	if speed := binary.NativeEndian.Uint32(b[speedOffset:]); speed != speedUnknown {
+		settings.Speed = int(speed)
+	}
+	switch b[duplexOffset] {
+	case duplexHalf:
+		settings.Duplex = DuplexHalf
+	case duplexFull:
+		settings.Duplex = DuplexFull
+	}
+	return settings
+}
@@ -0,0 +1,29 @@
+package ethtool
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestParseLinkSettings(t *testing.T) {
+	buf := make([]byte, linkSettingsSize)
+	binary.NativeEndian.PutUint32(buf[speedOffset:], 100)
+	buf[duplexOffset] = duplexHalf
+	if got := parseLinkSettings(buf); got != (LinkSettings{Speed: 100, Duplex: DuplexHalf}) {
+		t.Fatalf("parseLinkSettings() = %+v, want 100 Mb/s half duplex", got)
+	}
+	binary.NativeEndian.PutUint32(buf[speedOffset:], speedUnknown)
+	buf[duplexOffset] = 0xff
+	if got := parseLinkSettings(buf); got != (LinkSettings{}) {
+		t.Fatalf("parseLinkSettings() = %+v, want unknown settings", got)
+	}
+}
+
+func TestLinkSettingsLoopback(t *testing.T) {
+	if _, err := GetLinkSettings("lo"); !errors.Is(err, unix.EOPNOTSUPP) {
+		t.Fatalf("GetLinkSettings(lo) error = %v, want EOPNOTSUPP", err)
+	}
+}
@@ -0,0 +1,122 @@
+package ethtool
+
+import (
+	"fmt"
+	"slices"
+	"unsafe"
+)
+
+// Values from linux/ethtool.h that golang.org/x/sys/unix does not export.
+const (
+	cmdGetCoalesce  = 0x0000000e // ETHTOOL_GCOALESCE
+	cmdSetCoalesce  = 0x0000000f // ETHTOOL_SCOALESCE
+	cmdGetRingParam = 0x00000010 // ETHTOOL_GRINGPARAM
+	cmdSetRingParam = 0x00000011 // ETHTOOL_SRINGPARAM
+)
+
+// ringNames are the ring parameters in the order of struct ethtool_ringparam,
+// named as for ethtool(8) -G.
+var ringNames = []string{"rx", "rx-mini", "rx-jumbo", "tx"}
+
+// coalesceNames are the parameters of struct ethtool_coalesce in order,
+// named as for ethtool(8) -C. The adaptive ones are 0 or 1.
+var coalesceNames = []string{
+	"rx-usecs", "rx-frames", "rx-usecs-irq", "rx-frames-irq",
+	"tx-usecs", "tx-frames", "tx-usecs-irq", "tx-frames-irq",
+	"stats-block-usecs", "adaptive-rx", "adaptive-tx",
+	"pkt-rate-low", "rx-usecs-low", "rx-frames-low", "tx-usecs-low", "tx-frames-low",
+	"pkt-rate-high", "rx-usecs-high", "rx-frames-high", "tx-usecs-high", "tx-frames-high",
+	"sample-interval",
+}
+
+// ringParam is struct ethtool_ringparam: the maxima, then the sizes.
+type ringParam struct {
+	cmd     uint32
+	max     [4]uint32
+	pending [4]uint32
+}
+
+// coalesce is struct ethtool_coalesce.
+type coalesce struct {
+	cmd    uint32
+	params [22]uint32
+}
+
+// RingNames lists the ring parameters, such as "rx".
+func RingNames() []string {
+	return slices.Clone(ringNames)
+}
+
+// CoalesceNames lists the interrupt coalescing parameters, such as
+// "rx-usecs".
+func CoalesceNames() []string {
+	return slices.Clone(coalesceNames)
+}
+
+// GetRings reads the ring sizes of the named device and their maxima. The
+// maximum of a ring the device does not have is 0.
+func GetRings(name string) (current, max map[string]uint32, err error) {
+	r := ringParam{cmd: cmdGetRingParam}
+	if _, err := ioctl(name, unsafe.Pointer(&r)); err != nil {
+		return nil, nil, err
+	}
+	current = make(map[string]uint32, len(ringNames))
+	max = make(map[string]uint32, len(ringNames))
+	for i, ring := range ringNames {
+		current[ring] = r.pending[i]
+		max[ring] = r.max[i]
+	}
+	return current, max, nil
+}
+
+// SetRings changes ring sizes of the named device; rings that are not given
+// keep their size.
+func SetRings(name string, rings map[string]uint32) error {
+	r := ringParam{cmd: cmdGetRingParam}
+	if _, err := ioctl(name, unsafe.Pointer(&r)); err != nil {
+		return err
+	}
+	for ring, size := range rings {
+		i := slices.Index(ringNames, ring)
+		if i < 0 {
+			return fmt.Errorf("ethtool %s: no ring %s", name, ring)
+		}
+		r.pending[i] = size
+	}
+	r.cmd = cmdSetRingParam
+	_, err := ioctl(name, unsafe.Pointer(&r))
+	return err
+}
+
+// GetCoalesce reads the interrupt coalescing parameters of the named device.
+func GetCoalesce(name string) (map[string]uint32, error) {
+	c := coalesce{cmd: cmdGetCoalesce}
+	if _, err := ioctl(name, unsafe.Pointer(&c)); err != nil {
+		return nil, err
+	}
+	params := make(map[string]uint32, len(coalesceNames))
+	for i, param := range coalesceNames {
+		params[param] = c.params[i]
+	}
+	return params, nil
+}
+
+// SetCoalesce changes interrupt coalescing parameters of the named device;
+// parameters that are not given keep their value. Drivers refuse parameters
+// they do not support with EOPNOTSUPP.
+func SetCoalesce(name string, params map[string]uint32) error {
+	c := coalesce{cmd: cmdGetCoalesce}
+	if _, err := ioctl(name, unsafe.Pointer(&c)); err != nil {
+		return err
+	}
+	for param, value := range params {
+		i := slices.Index(coalesceNames, param)
+		if i < 0 {
+			return fmt.Errorf("ethtool %s: no coalescing parameter %s", name, param)
+		}
+		c.params[i] = value
+	}
+	c.cmd = cmdSetCoalesce
+	_, err := ioctl(name, unsafe.Pointer(&c))
+	return err
+}
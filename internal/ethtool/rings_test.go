@@ -0,0 +1,36 @@
+package ethtool
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestRingAndCoalesceMatchKernelLayout(t *testing.T) {
+	if size := unsafe.Sizeof(ringParam{}); size != 36 {
+		t.Fatalf("ringParam is %d bytes, want 36", size)
+	}
+	if size := unsafe.Sizeof(coalesce{}); size != 92 {
+		t.Fatalf("coalesce is %d bytes, want 92", size)
+	}
+	if len(coalesceNames) != len(coalesce{}.params) {
+		t.Fatalf("%d coalesce names for %d parameters", len(coalesceNames), len(coalesce{}.params))
+	}
+}
+
+func TestRingsLoopback(t *testing.T) {
+	if _, _, err := GetRings("lo"); !errors.Is(err, unix.EOPNOTSUPP) {
+		t.Fatalf("GetRings(lo) error = %v, want EOPNOTSUPP", err)
+	}
+	if _, err := GetCoalesce("lo"); !errors.Is(err, unix.EOPNOTSUPP) {
+		t.Fatalf("GetCoalesce(lo) error = %v, want EOPNOTSUPP", err)
+	}
+}
+
+func TestSetRingsMissingDevice(t *testing.T) {
+	if err := SetRings("goeth-missing0", map[string]uint32{"rx": 512}); err == nil {
+		t.Fatal("expected error")
+	}
+}
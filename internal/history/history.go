@@ -0,0 +1,165 @@
+// Package history keeps the most recent monitor events in a ring buffer,
+// persisted to a file, so that what changed lately can be looked up without
+// having had a terminal open.
+package history
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/user/goeth/internal/queue"
+	"github.com/user/goeth/pkg/netevent"
+)
+
+// Ring holds the last events added to it, up to its size.
+type Ring struct {
+	events []netevent.Event
+	next   int
+	full   bool
+}
+
+// NewRing returns a Ring holding up to size events.
+func NewRing(size int) *Ring {
+	return &Ring{events: make([]netevent.Event, max(size, 1))}
+}
+
+// Add appends event, dropping the oldest one when the ring is full.
+func (r *Ring) Add(event netevent.Event) {
+	r.events[r.next] = event
+	r.next = (r.next + 1) % len(r.events)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Events returns the events held, oldest first.
+func (r *Ring) Events() []netevent.Event {
+	if !r.full {
+		return append([]netevent.Event(nil), r.events[:r.next]...)
+	}
+	return append(append([]netevent.Event(nil), r.events[r.next:]...), r.events[:r.next]...)
+}
+
+// Load reads the events of a history file, one JSON document per line. A
+// missing file holds no events.
+func Load(path string) ([]netevent.Event, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	defer file.Close()
+	var events []netevent.Event
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var event netevent.Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("history: %s line %d: %w", path, line, err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	return events, nil
+}
+
+// Save replaces the history file with events. It writes a temporary file
+// beside it and renames it, so a reader never sees a partial history.
+func Save(path string, events []netevent.Event) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	writer := bufio.NewWriter(temp)
+	encoder := json.NewEncoder(writer)
+	for _, event := range events {
+		if err = encoder.Encode(event); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = temp.Chmod(fileMode)
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return fmt.Errorf("history: %w", err)
+	}
+	return nil
+}
+
+// fileMode is the permission of history files.
+const fileMode = 0o644
+
+// Since returns the events at or after t.
+func Since(events []netevent.Event, t time.Time) []netevent.Event {
+	var recent []netevent.Event
+	for _, event := range events {
+		if !event.Time.Before(t) {
+			recent = append(recent, event)
+		}
+	}
+	return recent
+}
+
+// Recorder adds events to a Ring in the background and saves it to Path
+// after each one.
+type Recorder struct {
+	*queue.Queue[netevent.Event]
+	Path string
+	ring *Ring
+}
+
+// NewRecorder returns a Recorder keeping the last size events, starting from
+// those already saved at path. Up to pending events wait while the file is
+// being written.
+func NewRecorder(path string, size, pending int) (*Recorder, error) {
+	saved, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	ring := NewRing(size)
+	for _, event := range saved {
+		ring.Add(event)
+	}
+	return &Recorder{Queue: queue.New[netevent.Event]("history", pending), Path: path, ring: ring}, nil
+}
+
+// Run records queued events until ctx is cancelled. Events queued together
+// are saved with one write.
+func (r *Recorder) Run(ctx context.Context) error {
+	return r.Queue.Run(ctx, func(_ context.Context, event netevent.Event) error {
+		r.ring.Add(event)
+		r.drain()
+		return Save(r.Path, r.ring.Events())
+	})
+}
+
+func (r *Recorder) drain() {
+	for {
+		select {
+		case event := <-r.Pending():
+			r.ring.Add(event)
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package history
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/goeth/pkg/netevent"
+)
+
+func event(minute int) netevent.Event {
+	return netevent.Event{
+		Time:      time.Date(2024, 1, 1, 0, minute, 0, 0, time.UTC),
+		Kind:      netevent.Link,
+		Type:      "interface_updated",
+		Interface: "eth0",
+		Message:   "interface eth0 updated",
+	}
+}
+
+func TestRingKeepsTheLastEvents(t *testing.T) {
+	ring := NewRing(3)
+	if got := ring.Events(); len(got) != 0 {
+		t.Fatalf("Events() = %v, want none", got)
+	}
+	for minute := range 5 {
+		ring.Add(event(minute))
+	}
+	if want := []netevent.Event{event(2), event(3), event(4)}; !reflect.DeepEqual(ring.Events(), want) {
+		t.Fatalf("Events() = %v, want %v", ring.Events(), want)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	if events, err := Load(path); err != nil || events != nil {
+		t.Fatalf("Load() of a missing file = %v, %v", events, err)
+	}
+	events := []netevent.Event{event(1), event(2)}
+	if err := Save(path, events); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded, events) {
+		t.Fatalf("Load() = %v, want %v", loaded, events)
+	}
+	if matches, _ := filepath.Glob(path + ".*"); len(matches) != 0 {
+		t.Fatalf("temporary files left behind: %v", matches)
+	}
+	os.WriteFile(path, []byte("{}\nnot-json\n"), 0o644)
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected a parse error naming line 2, got %v", err)
+	}
+}
+
+func TestSince(t *testing.T) {
+	events := []netevent.Event{event(1), event(2), event(3)}
+	if got := Since(events, event(2).Time); !reflect.DeepEqual(got, events[1:]) {
+		t.Fatalf("Since() = %v, want %v", got, events[1:])
+	}
+}
+
+func TestRecorderContinuesTheSavedHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	if err := Save(path, []netevent.Event{event(1), event(2)}); err != nil {
+		t.Fatal(err)
+	}
+	recorder, err := NewRecorder(path, 2, 4)
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go recorder.Run(ctx)
+	recorder.Send(event(3))
+	want := []netevent.Event{event(2), event(3)}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		loaded, err := Load(path)
+		if err == nil && reflect.DeepEqual(loaded, want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("saved history = %v, %v, want %v", loaded, err, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,96 @@
+// Package i18n localizes human-readable output. Messages are looked up by
+// their English format string, so untranslated messages and the zero Printer
+// print English. Machine-readable output such as JSON is never localized.
+package i18n
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// English is the language of the format strings themselves.
+const English = "en"
+
+// catalogs maps a language to its translations, keyed by English format.
+var catalogs = map[string]map[string]string{
+	"ja": japanese,
+}
+
+// Printer formats messages in one language.
+type Printer struct {
+	lang     string
+	messages map[string]string
+}
+
+// New returns a Printer for lang, which may be a plain language code such as
+// "ja" or a locale such as "ja_JP.UTF-8". It reports false for languages
+// without a catalog.
+func New(lang string) (Printer, bool) {
+	code := Language(lang)
+	if code == English {
+		return Printer{lang: English}, true
+	}
+	messages, ok := catalogs[code]
+	if !ok {
+		return Printer{lang: English}, false
+	}
+	return Printer{lang: code, messages: messages}, true
+}
+
+// Detect picks the language from the first of LC_ALL, LC_MESSAGES and LANG
+// that is set, following the POSIX precedence.
+func Detect(getenv func(string) string) string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := getenv(name); value != "" {
+			return Language(value)
+		}
+	}
+	return English
+}
+
+// Language reduces a locale such as "ja_JP.UTF-8" to its language code.
+// The C and POSIX locales are English.
+func Language(locale string) string {
+	code := locale
+	if i := strings.IndexAny(code, "_.@-"); i >= 0 {
+		code = code[:i]
+	}
+	code = strings.ToLower(code)
+	if code == "" || code == "c" || code == "posix" {
+		return English
+	}
+	return code
+}
+
+// Supported lists the languages that can be selected.
+func Supported() []string {
+	langs := []string{English}
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs[1:])
+	return langs
+}
+
+// Lang returns the language messages are printed in.
+func (p Printer) Lang() string {
+	if p.lang == "" {
+		return English
+	}
+	return p.lang
+}
+
+// Sprintf formats the translation of format.
+func (p Printer) Sprintf(format string, args ...any) string {
+	if translated, ok := p.messages[format]; ok {
+		format = translated
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Fprintf writes the translation of format to w.
+func (p Printer) Fprintf(w io.Writer, format string, args ...any) (int, error) {
+	return io.WriteString(w, p.Sprintf(format, args...))
+}
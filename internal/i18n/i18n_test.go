@@ -0,0 +1,88 @@
+package i18n
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// verbPattern matches fmt verbs with an optional explicit argument index.
+var verbPattern = regexp.MustCompile(`%(\[(\d+)\])?[a-zA-Z]`)
+
+// verbsByArgument maps each argument position to the verb that formats it.
+func verbsByArgument(format string) map[int]string {
+	verbs := make(map[int]string)
+	next := 1
+	for _, match := range verbPattern.FindAllStringSubmatch(format, -1) {
+		arg := next
+		if match[2] != "" {
+			arg, _ = strconv.Atoi(match[2])
+		}
+		verbs[arg] = match[0][len(match[0])-1:]
+		next = arg + 1
+	}
+	return verbs
+}
+
+func TestCatalogsKeepArguments(t *testing.T) {
+	for lang, messages := range catalogs {
+		for english, translated := range messages {
+			want, got := verbsByArgument(english), verbsByArgument(translated)
+			if len(want) != len(got) {
+				t.Fatalf("%s: %q uses %d arguments, translation %q uses %d", lang, english, len(want), translated, len(got))
+			}
+			for arg, verb := range want {
+				if got[arg] != verb {
+					t.Fatalf("%s: argument %d of %q is %%%s, translation %q uses %%%s", lang, arg, english, verb, translated, got[arg])
+				}
+			}
+			if strings.HasSuffix(english, "\n") != strings.HasSuffix(translated, "\n") {
+				t.Fatalf("%s: translation %q of %q changes the line ending", lang, translated, english)
+			}
+		}
+	}
+}
+
+func TestNewAcceptsLocales(t *testing.T) {
+	for _, locale := range []string{"ja", "ja_JP.UTF-8", "JA_jp"} {
+		p, ok := New(locale)
+		if !ok || p.Lang() != "ja" {
+			t.Fatalf("New(%q) = %q, %v; want ja", locale, p.Lang(), ok)
+		}
+	}
+	if p, ok := New("C"); !ok || p.Lang() != English {
+		t.Fatalf("New(C) = %q, %v; want en", p.Lang(), ok)
+	}
+	if p, ok := New("xx_XX"); ok || p.Lang() != English {
+		t.Fatalf("New(xx_XX) = %q, %v; want an English fallback", p.Lang(), ok)
+	}
+}
+
+func TestDetectFollowsPOSIXPrecedence(t *testing.T) {
+	env := map[string]string{"LANG": "en_US.UTF-8", "LC_MESSAGES": "ja_JP.UTF-8"}
+	if got := Detect(func(name string) string { return env[name] }); got != "ja" {
+		t.Fatalf("Detect() = %q, want ja", got)
+	}
+	env["LC_ALL"] = "C"
+	if got := Detect(func(name string) string { return env[name] }); got != English {
+		t.Fatalf("Detect() = %q, want en", got)
+	}
+	if got := Detect(func(string) string { return "" }); got != English {
+		t.Fatalf("Detect() = %q, want en", got)
+	}
+}
+
+func TestSprintfTranslatesAndFallsBack(t *testing.T) {
+	ja, _ := New("ja")
+	if got := ja.Sprintf("Applied %d of %d changes to %s\n", 1, 3, "eth0"); got != "eth0 に 3 件中 1 件の変更を適用しました\n" {
+		t.Fatalf("unexpected translation: %q", got)
+	}
+	if got := ja.Sprintf("untranslated %s", "eth0"); got != "untranslated eth0" {
+		t.Fatalf("expected English fallback, got %q", got)
+	}
+	var zero Printer
+	if got := zero.Sprintf("No changes for %s\n", "eth0"); got != "No changes for eth0\n" {
+		t.Fatalf("zero Printer = %q", got)
+	}
+}
@@ -0,0 +1,120 @@
+package i18n
+
+// japanese translates the messages of the CLI and the monitor. Arguments
+// that change places use explicit indexes such as %[2]d.
+var japanese = map[string]string{
+	// goeth interfaces, addresses and link
+	"No interfaces found\n":              "インターフェースが見つかりません\n",
+	"No addresses for %s\n":              "%s にアドレスはありません\n",
+	"%s %s is present\n":                 "%s %s は作成済みです\n",
+	"veth pair %s <-> %s is present\n":   "veth ペア %s <-> %s は作成済みです\n",
+	"%s is named %s\n":                   "%s は %s という名前になっています\n",
+	"%s is up\n":                         "%s は起動しています\n",
+	"%s is up with carrier\n":            "%s は起動しておりキャリアを検出しています\n",
+	"MAC of %s is %s\n":                  "%s の MAC アドレスは %s です\n",
+	"%s has its permanent MAC address\n": "%s の MAC アドレスを本来のアドレスに戻しました\n",
+	"MTU of %s is %d\n":                  "%s の MTU は %d です\n",
+	"%s is down\n":                       "%s は停止しています\n",
+	"%s runs the XDP program %s\n":       "%s で XDP プログラム %s が動作しています\n",
+	"%s has no XDP program\n":            "%s に XDP プログラムはありません\n",
+
+	// goeth apply-config and simulate
+	"Configuration applied to %s\n": "%s に設定を適用しました\n",
+	"Note: %s\n":                    "注意: %s\n",
+	"Warning: %s is managed by %s, which may undo these changes\n": "警告: %s は %s が管理しているため、この変更が元に戻される可能性があります\n",
+	"Warning: %s\n":                              "警告: %s\n",
+	"No changes for %s\n":                        "%s に変更はありません\n",
+	"Plan for %s:\n":                             "%s の実行計画:\n",
+	"Left unchanged:\n":                          "変更せずに残した項目:\n",
+	"Left unchanged: %s\n":                       "変更せずに残した項目: %s\n",
+	"No changes applied to %s\n":                 "%s に適用した変更はありません\n",
+	"Applied %d of %d changes to %s\n":           "%[3]s に %[2]d 件中 %[1]d 件の変更を適用しました\n",
+	"Apply this change [y,n,a,q,?]? ":            "この変更を適用しますか [y,n,a,q,?]? ",
+	"y - apply this change\n":                    "y - この変更を適用する\n",
+	"n - skip this change\n":                     "n - この変更をスキップする\n",
+	"a - apply this and all remaining changes\n": "a - この変更と残りのすべての変更を適用する\n",
+	"q - skip this and all remaining changes\n":  "q - この変更と残りのすべての変更をスキップする\n",
+
+	// goeth monitor
+	"[%s] monitoring started (interval %s)\n":                     "[%s] 監視を開始しました (間隔 %s)\n",
+	"[%s] monitoring started (following netlink notifications)\n": "[%s] 監視を開始しました (netlink 通知を追跡)\n",
+	"[%s] monitoring stopped after %s\n":                          "[%s] %s 経過したため監視を終了しました\n",
+	"[%s] monitoring stopped after %d changes\n":                  "[%s] %d 件の変更を検出したため監視を終了しました\n",
+	"[%s] could not collect the state, retrying in %s: %v\n":      "[%s] 状態を取得できませんでした。%s 後に再試行します: %v\n",
+	"[%s] collected the state again after %d failures\n":          "[%s] %d 回の失敗の後、状態を再び取得しました\n",
+	"heartbeat: watching 1 interface":                             "ハートビート: 1 個のインターフェースを監視中",
+	"heartbeat: watching %d interfaces":                           "ハートビート: %d 個のインターフェースを監視中",
+	"Warning: %v; polling every %s instead\n":                     "警告: %v。代わりに %s ごとにポーリングします\n",
+	" - filter: %s\n":                                 " - 対象: %s\n",
+	" - events: %s\n":                                 " - イベント: %s\n",
+	" - filter: names matching %s\n":                  " - 対象: %s に一致する名前\n",
+	" - resuming from the state saved at %s\n":        " - %s に保存された状態から再開します\n",
+	" - traffic every %s\n":                           " - トラフィック: %s ごと\n",
+	"No interfaces detected yet\n":                    "インターフェースはまだ検出されていません\n",
+	"Waiting for %s to appear...\n":                   "%s が現れるのを待っています...\n",
+	"Waiting for matching interfaces to appear...\n":  "一致するインターフェースが現れるのを待っています...\n",
+	"   addresses: none\n":                            "   アドレス: なし\n",
+	"   addresses: %s\n":                              "   アドレス: %s\n",
+	"interface %s added (MTU=%d, HW=%s)":              "インターフェース %s が追加されました (MTU=%d, HW=%s)",
+	"interface %s removed":                            "インターフェース %s が削除されました",
+	"interface %s updated: %s":                        "インターフェース %s が更新されました: %s",
+	"%s link went down (%s)":                          "%s のリンクがダウンしました (%s)",
+	"%s link came up after %s down":                   "%s のリンクが %s のダウンの後に復旧しました",
+	"%s link came up":                                 "%s のリンクが復旧しました",
+	"administratively down":                           "管理上のダウン",
+	"no carrier":                                      "キャリアなし",
+	"operational state %s":                            "動作状態 %s",
+	"%s address %s conflicts: also configured on %s":  "%s のアドレス %s が競合しています: %s にも設定されています",
+	"%s address %s conflicts: %s answers for it too":  "%s のアドレス %s が競合しています: %s も応答しています",
+	"%s gateway %s is not answering":                  "%s のゲートウェイ %s が応答しません",
+	"%s gateway %s is answering again":                "%s のゲートウェイ %s が再び応答しています",
+	"%s addresses added: %s":                          "%s にアドレスが追加されました: %s",
+	"%s addresses removed: %s":                        "%s からアドレスが削除されました: %s",
+	"   routes: none\n":                               "   経路: なし\n",
+	"   routes: %s\n":                                 "   経路: %s\n",
+	"%s route added: %s":                              "%s に経路が追加されました: %s",
+	"%s route removed: %s":                            "%s から経路が削除されました: %s",
+	"%s route changed: %s → %s":                       "%s の経路が変更されました: %s → %s",
+	"1 route change":                                  "経路の変更 1 件",
+	"%d route changes":                                "経路の変更 %d 件",
+	"   neighbors: none\n":                            "   近隣: なし\n",
+	"   neighbors: %s\n":                              "   近隣: %s\n",
+	"%s neighbor appeared: %s":                        "%s に近隣エントリが現れました: %s",
+	"%s neighbor %s moved: lladdr %s → %s":            "%s の近隣 %s のリンク層アドレスが変わりました: %s → %s",
+	"%s neighbor %s is stale":                         "%s の近隣 %s が stale になりました",
+	"%s neighbor %s failed":                           "%s の近隣 %s の解決に失敗しました",
+	"%s neighbor %s removed":                          "%s の近隣 %s が削除されました",
+	"%s traffic counters were reset":                  "%s のトラフィックカウンタがリセットされました",
+	"%s rx %s (%s pkt/s), tx %s (%s pkt/s)":           "%s 受信 %s (%s pkt/s)、送信 %s (%s pkt/s)",
+	"%s errors rx +%d tx +%d, drops rx +%d tx +%d":    "%s エラー 受信 +%d 送信 +%d、破棄 受信 +%d 送信 +%d",
+	"1 neighbor change":                               "近隣の変更 1 件",
+	"%d neighbor changes":                             "近隣の変更 %d 件",
+	"[%s] summary for the last %s: no changes\n":      "[%s] 直近 %s のまとめ: 変更なし\n",
+	"[%s] summary for the last %s: %s; busiest: %s\n": "[%s] 直近 %s のまとめ: %s、変更の多いインターフェース: %s\n",
+	", ":                 "、",
+	"1 link change":      "リンクの変更 1 件",
+	"%d link changes":    "リンクの変更 %d 件",
+	"1 address change":   "アドレスの変更 1 件",
+	"%d address changes": "アドレスの変更 %d 件",
+
+	// goeth doctor
+	"kernel module %s, needed for %s, is not loaded":                                                                   "%[2]s に必要なカーネルモジュール %[1]s が読み込まれていません",
+	"run modprobe %s and list it in /etc/modules-load.d; containers cannot load modules on demand":                     "modprobe %s を実行し、/etc/modules-load.d に追記してください。コンテナ内ではモジュールを自動で読み込めません",
+	"strict reverse path filtering on %s drops replies that arrive over another default route (default routes via %s)": "%s の厳格な逆経路フィルタが、別のデフォルトルート経由で届いた応答を破棄します (デフォルトルート: %s)",
+	"the configuration adds a default route via %s next to the one via %s, but reverse path filtering on %s is strict": "設定は %[2]s 経由のデフォルトルートに加えて %[1]s 経由のデフォルトルートを追加しますが、%[3]s の逆経路フィルタが厳格です",
+	"switch to loose mode with sysctl -w net/ipv4/conf/%s/rp_filter=2, and net/ipv4/conf/all/rp_filter=2 if that is 1": "sysctl -w net/ipv4/conf/%s/rp_filter=2 で緩和モードにし、net/ipv4/conf/all/rp_filter が 1 ならそれも 2 にしてください",
+	"MAC address %s is used by %s": "MAC アドレス %s が %s で使われています",
+	"give all but one of them a unique address, e.g. with goeth link set-mac; cloned VMs and containers often inherit the same one": "goeth link set-mac などで 1 つを除いて固有のアドレスを割り当ててください。複製した VM やコンテナは同じアドレスを引き継ぐことがよくあります",
+	"ports of bridge %s have different MTUs (%s); frames larger than %d are dropped":                                                "ブリッジ %s のポートの MTU が異なります (%s)。%d より大きいフレームは破棄されます",
+	"set the same MTU on every port, e.g. goeth link set-mtu --interface <port> --mtu <mtu>":                                        "すべてのポートに同じ MTU を設定してください (例: goeth link set-mtu --interface <port> --mtu <mtu>)",
+	"large receive offload is enabled on %s, the underlay of vxlan %s":                                                              "vxlan %[2]s のアンダーレイ %[1]s で LRO (large receive offload) が有効です",
+	"disable it with ethtool -K %s lro off":                                                                                         "ethtool -K %s lro off で無効にしてください",
+	"the host is IPv6-only and no NAT64 prefix was found, so IPv4 destinations are unreachable":                                     "このホストは IPv6 のみで NAT64 プレフィックスが見つからないため、IPv4 の宛先に到達できません",
+	"use a DNS64 resolver backed by a NAT64 gateway, or route %s to one":                                                            "NAT64 ゲートウェイと組み合わせた DNS64 リゾルバを使うか、%s をゲートウェイへルーティングしてください",
+	"NAT64 prefix %s is available but no CLAT is running, so applications using IPv4 addresses fail":                                "NAT64 プレフィックス %s は利用できますが CLAT が動いていないため、IPv4 アドレスを直接使うアプリケーションは失敗します",
+	"run a CLAT such as clatd to provide 464XLAT (RFC 6877)":                                                                        "clatd などの CLAT を動かして 464XLAT (RFC 6877) を提供してください",
+	"    fix: %s\n":       "    対処: %s\n",
+	"Found 1 problem\n":   "問題が 1 件見つかりました\n",
+	"Found %d problems\n": "問題が %d 件見つかりました\n",
+	"No problems found\n": "問題は見つかりませんでした\n",
+}
@@ -0,0 +1,59 @@
+package interfaces
+
+import (
+	"path"
+	"regexp"
+	"slices"
+)
+
+// Filter selects interfaces by their flags, state and kind; an interface
+// must meet every condition that is set. The zero value selects all.
+type Filter struct {
+	// Up selects interfaces that are administratively up.
+	Up bool
+	// Running selects interfaces that are operationally up, see
+	// Interface.Running.
+	Running bool
+	// NoLoopback leaves out loopback interfaces.
+	NoLoopback bool
+	// Physical selects real NICs, those of KindPhysical.
+	Physical bool
+	// Kinds selects interfaces of any of these kinds when it is not empty.
+	Kinds []string
+	// Names selects interfaces whose name matches any of these shell
+	// patterns, such as en* (see path.Match), when it is not empty;
+	// malformed patterns match nothing.
+	Names []string
+	// NameRegex, when set, selects interfaces whose name it matches.
+	NameRegex *regexp.Regexp
+}
+
+// Match reports whether iface meets every condition of f.
+func (f Filter) Match(iface Interface) bool {
+	switch {
+	case f.Up && !slices.Contains(iface.Flags, "up"):
+		return false
+	case f.Running && !iface.Running():
+		return false
+	case f.NoLoopback && (iface.Kind == KindLoopback || slices.Contains(iface.Flags, "loopback")):
+		return false
+	case f.Physical && iface.Kind != KindPhysical:
+		return false
+	case len(f.Kinds) > 0 && !slices.Contains(f.Kinds, iface.Kind):
+		return false
+	case len(f.Names) > 0 && !slices.ContainsFunc(f.Names, func(pattern string) bool {
+		ok, _ := path.Match(pattern, iface.Name)
+		return ok
+	}):
+		return false
+	case f.NameRegex != nil && !f.NameRegex.MatchString(iface.Name):
+		return false
+	}
+	return true
+}
+
+// zero reports whether f selects all interfaces.
+func (f Filter) zero() bool {
+	return !f.Up && !f.Running && !f.NoLoopback && !f.Physical && len(f.Kinds) == 0 &&
+		len(f.Names) == 0 && f.NameRegex == nil
+}
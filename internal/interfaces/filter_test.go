@@ -0,0 +1,57 @@
+package interfaces
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestFilterMatch(t *testing.T) {
+	running := Interface{Name: "eth0", Kind: KindPhysical, Flags: []string{"up", "running"}, OperState: "up", Carrier: true}
+	unplugged := Interface{Name: "eth1", Kind: KindPhysical, Flags: []string{"up"}, OperState: "lower-layer-down"}
+	loopback := Interface{Name: "lo", Kind: KindLoopback, Flags: []string{"up", "loopback", "running"}, OperState: "unknown", Carrier: true}
+	bridge := Interface{Name: "br0", Kind: "bridge"}
+	tests := []struct {
+		filter Filter
+		want   []string
+	}{
+		{Filter{}, []string{"eth0", "eth1", "lo", "br0"}},
+		{Filter{Up: true}, []string{"eth0", "eth1", "lo"}},
+		{Filter{Running: true}, []string{"eth0", "lo"}},
+		{Filter{Running: true, NoLoopback: true}, []string{"eth0"}},
+		{Filter{Physical: true}, []string{"eth0", "eth1"}},
+		{Filter{Kinds: []string{"bridge", KindLoopback}}, []string{"lo", "br0"}},
+		{Filter{Names: []string{"eth*", "br?"}}, []string{"eth0", "eth1", "br0"}},
+		{Filter{Names: []string{"eth["}}, nil},
+		{Filter{NameRegex: regexp.MustCompile(`^(lo|eth1)$`)}, []string{"eth1", "lo"}},
+		{Filter{Names: []string{"eth*"}, Running: true}, []string{"eth0"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, iface := range []Interface{running, unplugged, loopback, bridge} {
+			if tt.filter.Match(iface) {
+				got = append(got, iface.Name)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v matched %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestListerWhereFiltersAfterRelating(t *testing.T) {
+	l := NewLister(mockProvider{interfaces: []Interface{
+		{Name: "eth0", Index: 2, Kind: KindPhysical},
+		{Name: "eth0.10", Index: 3, Kind: "vlan", ParentIndex: 2},
+	}}).Where(Filter{Kinds: []string{"vlan"}})
+	list, err := l.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(list) != 1 || list[0].Name != "eth0.10" || list[0].Parent != "eth0" {
+		t.Fatalf("List() = %+v, want eth0.10 on eth0", list)
+	}
+	if name, err := l.Resolve("2"); err != nil || name != "eth0" {
+		t.Fatalf("Resolve(2) = %q, %v, want eth0 whatever the filter", name, err)
+	}
+}
@@ -3,16 +3,69 @@ package interfaces
 import (
 	"errors"
 	"net"
+	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+
+	"github.com/user/goeth/internal/ethtool"
 )
 
 // Interface represents the properties of a network interface.
 type Interface struct {
-	Name         string
-	HardwareAddr string
-	MTU          int
-	Flags        []string
+	Name         string   `json:"name"`
+	HardwareAddr string   `json:"hardware_addr"`
+	MTU          int      `json:"mtu"`
+	Flags        []string `json:"flags"`
+	// Index is the ifindex the kernel refers to the interface by, as in
+	// "if2" or "dev 2"; zero when unknown.
+	Index int `json:"index,omitempty"`
+	// Kind tells real NICs from virtual links: KindPhysical, KindLoopback,
+	// or the link type netlink names, such as bridge, vlan, bond, veth,
+	// tun, tap or wireguard. It is empty when unknown.
+	Kind string `json:"kind,omitempty"`
+	// Parent is the link this one is stacked on, such as the link below a
+	// VLAN, or the other end of a veth pair. Master is the bridge or bond
+	// this one is a port of, and Ports are the links that are ports of this
+	// one.
+	Parent string   `json:"parent,omitempty"`
+	Master string   `json:"master,omitempty"`
+	Ports  []string `json:"ports,omitempty"`
+	// ParentIndex and MasterIndex are what providers that only know the
+	// indexes report; Lister.List names the links they refer to.
+	ParentIndex int `json:"-"`
+	MasterIndex int `json:"-"`
+	// OperState is the RFC 2863 operational state, such as up, down,
+	// lower-layer-down or dormant, spelled as ip-link(8) does. It is empty
+	// when the provider cannot tell.
+	OperState string `json:"operstate,omitempty"`
+	// Carrier reports whether the link has a carrier (IFF_LOWER_UP).
+	Carrier bool `json:"carrier,omitempty"`
+	// Speed is the negotiated speed in Mb/s and Duplex is "full" or
+	// "half"; they are unknown, zero and empty, without a carrier or for
+	// virtual links that do not tell.
+	Speed  int    `json:"speed,omitempty"`
+	Duplex string `json:"duplex,omitempty"`
+	// Driver, FirmwareVersion and BusInfo tell which hardware backs the
+	// interface, as ethtool -i does. Providers leave them empty; they are
+	// filled in for listings that ask for details.
+	Driver          string `json:"driver,omitempty"`
+	FirmwareVersion string `json:"firmware_version,omitempty"`
+	BusInfo         string `json:"bus_info,omitempty"`
+}
+
+// Kinds of Interface.Kind that are not a netlink link type.
+const (
+	KindPhysical = "physical"
+	KindLoopback = "loopback"
+)
+
+// Running reports whether the interface is operationally up: it passes
+// traffic, or at least does not say otherwise, as loopback and many virtual
+// links report an unknown state.
+func (i Interface) Running() bool {
+	return i.Carrier && (i.OperState == "up" || i.OperState == "unknown")
 }
 
 // Provider retrieves interface information from the environment.
@@ -23,6 +76,7 @@ type Provider interface {
 // Lister is responsible for listing interfaces using a Provider.
 type Lister struct {
 	provider Provider
+	filter   Filter
 }
 
 // NewLister creates a Lister with the given Provider.
@@ -30,7 +84,14 @@ func NewLister(provider Provider) Lister {
 	return Lister{provider: provider}
 }
 
-// List returns all network interfaces.
+// Where returns a Lister that only lists the interfaces f selects.
+func (l Lister) Where(f Filter) Lister {
+	l.filter = f
+	return l
+}
+
+// List returns the network interfaces, all of them unless the Lister was
+// made by Where.
 func (l Lister) List() ([]Interface, error) {
 	if l.provider == nil {
 		return nil, errors.New("interfaces provider is not configured")
@@ -42,12 +103,91 @@ func (l Lister) List() ([]Interface, error) {
 	sort.SliceStable(interfaces, func(i, j int) bool {
 		return interfaces[i].Name < interfaces[j].Name
 	})
-	return interfaces, nil
+	// Parents and masters are named before filtering, so a VLAN listed
+	// without its physical link still names it.
+	relate(interfaces)
+	if l.filter.zero() {
+		return interfaces, nil
+	}
+	selected := make([]Interface, 0, len(interfaces))
+	for _, iface := range interfaces {
+		if l.filter.Match(iface) {
+			selected = append(selected, iface)
+		}
+	}
+	return selected, nil
+}
+
+// relate names the parent and master of each interface of list that were
+// only given by index and lists the ports of each. Indexes of links that
+// are not in list, as of a veth peer in another namespace, are dropped.
+func relate(list []Interface) {
+	names := make(map[int]string, len(list))
+	for _, iface := range list {
+		if iface.Index > 0 {
+			names[iface.Index] = iface.Name
+		}
+	}
+	ports := make(map[string][]string)
+	for i := range list {
+		iface := &list[i]
+		if iface.Parent == "" && iface.ParentIndex != iface.Index {
+			iface.Parent = names[iface.ParentIndex]
+		}
+		if iface.Master == "" {
+			iface.Master = names[iface.MasterIndex]
+		}
+		if iface.Master != "" {
+			ports[iface.Master] = append(ports[iface.Master], iface.Name)
+		}
+	}
+	for i := range list {
+		if port := ports[list[i].Name]; len(port) > 0 {
+			list[i].Ports = port
+		}
+	}
+}
+
+// Resolve returns the name of the interface that ref names or, when ref is
+// a number that is not an interface name, whose index it is. Other refs are
+// returned as they are, so that an interface that does not exist yet, or one
+// named by a pattern, is left to the caller.
+func (l Lister) Resolve(ref string) (string, error) {
+	index, err := strconv.Atoi(ref)
+	if err != nil || index <= 0 {
+		return ref, nil
+	}
+	// Any interface can be referred to, whatever the filter.
+	list, err := l.Where(Filter{}).List()
+	if err != nil {
+		return "", err
+	}
+	name := ref
+	for _, iface := range list {
+		if iface.Name == ref {
+			return ref, nil
+		}
+		if iface.Index == index {
+			name = iface.Name
+		}
+	}
+	return name, nil
 }
 
-// NetProvider retrieves interface details using the net package.
+// NetProvider retrieves interface details using the net package, and the
+// operational state and carrier from sysfs.
 type NetProvider struct{}
 
+// sysClassNet holds a directory of attributes for each network device.
+const sysClassNet = "/sys/class/net"
+
+// sysfsOperStates are the sysfs spellings of operational states that
+// netlink spells otherwise.
+var sysfsOperStates = map[string]string{
+	"lowerlayerdown": "lower-layer-down",
+	"notpresent":     "not-present",
+}
+
 // ListInterfaces fetches interfaces from the operating system.
 func (NetProvider) ListInterfaces() ([]Interface, error) {
 	list, err := net.Interfaces()
@@ -61,12 +201,104 @@ func (NetProvider) ListInterfaces() ([]Interface, error) {
 		if len(flags) == 1 && flags[0] == "" {
 			flags = nil
 		}
+		dir := filepath.Join(sysClassNet, iface.Name)
+		operState, carrier := sysfsLinkState(dir)
+		kind := sysfsKind(dir)
+		if iface.Flags&net.FlagLoopback != 0 {
+			kind = KindLoopback
+		}
 		results = append(results, Interface{
 			Name:         iface.Name,
+			Index:        iface.Index,
 			HardwareAddr: iface.HardwareAddr.String(),
 			MTU:          iface.MTU,
 			Flags:        flags,
+			Kind:         kind,
+			OperState:    operState,
+			Carrier:      carrier,
+			Master:       sysfsMaster(dir),
+			ParentIndex:  sysfsIndex(filepath.Join(dir, "iflink")),
 		})
 	}
+	ReadLinkSettings(results)
 	return results, nil
 }
+
+// ReadLinkSettings fills in the speed and duplex of each interface through
+// ethtool, in the namespace of the calling thread. Interfaces that cannot
+// tell keep them unknown.
+func ReadLinkSettings(list []Interface) {
+	for i := range list {
+		settings, err := ethtool.GetLinkSettings(list[i].Name)
+		if err != nil {
+			continue
+		}
+		list[i].Speed, list[i].Duplex = settings.Speed, settings.Duplex
+	}
+}
+
+// sysfsKind tells the kind of a device from its sysfs directory: the
+// DEVTYPE its uevent names, as for bridges, VLANs and bonds, or a physical
+// NIC when a bus device backs it. Other virtual devices, such as veth pairs,
+// are of unknown kind.
+func sysfsKind(dir string) string {
+	uevent, err := os.ReadFile(filepath.Join(dir, "uevent"))
+	if err == nil {
+		for _, line := range strings.Split(string(uevent), "\n") {
+			devtype, ok := strings.CutPrefix(line, "DEVTYPE=")
+			if !ok {
+				continue
+			}
+			if devtype == "wlan" || devtype == "wwan" {
+				return KindPhysical
+			}
+			return devtype
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "device")); err == nil {
+		return KindPhysical
+	}
+	return ""
+}
+
+// sysfsMaster returns the name of the bridge or bond a device is a port of,
+// where its master link points.
+func sysfsMaster(dir string) string {
+	target, err := os.Readlink(filepath.Join(dir, "master"))
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+// sysfsIndex reads an interface index from an attribute such as iflink,
+// which names the link below a device, or the device itself when there is
+// none; it is zero when the attribute cannot be read.
+func sysfsIndex(path string) int {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	index, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0
+	}
+	return index
+}
+
+// sysfsLinkState reads the operational state and carrier from the sysfs
+// directory of a device. The state is empty when it cannot be read, as
+// outside Linux; reading the carrier of a link that is down fails, which
+// means none.
+func sysfsLinkState(dir string) (string, bool) {
+	state, err := os.ReadFile(filepath.Join(dir, "operstate"))
+	if err != nil {
+		return "", false
+	}
+	operState := strings.TrimSpace(string(state))
+	if spelled, ok := sysfsOperStates[operState]; ok {
+		operState = spelled
+	}
+	carrier, err := os.ReadFile(filepath.Join(dir, "carrier"))
+	return operState, err == nil && strings.TrimSpace(string(carrier)) == "1"
+}
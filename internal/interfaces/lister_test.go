@@ -2,6 +2,8 @@ package interfaces
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -43,3 +45,95 @@ func TestListerListNoProvider(t *testing.T) {
 		t.Fatal("expected error when provider is missing")
 	}
 }
+
+func TestListerResolveAcceptsIndexes(t *testing.T) {
+	l := NewLister(mockProvider{interfaces: []Interface{{Name: "eth0", Index: 2}, {Name: "3", Index: 4}}})
+	for ref, want := range map[string]string{"2": "eth0", "eth0": "eth0", "3": "3", "7": "7", "eth*": "eth*"} {
+		got, err := l.Resolve(ref)
+		if err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v, want %q", ref, got, err, want)
+		}
+	}
+	if _, err := NewLister(mockProvider{err: errors.New("boom")}).Resolve("2"); err == nil {
+		t.Fatal("Resolve() ignored the provider error")
+	}
+}
+
+func TestListerRelatesByIndex(t *testing.T) {
+	l := NewLister(mockProvider{interfaces: []Interface{
+		{Name: "eth0", Index: 2, ParentIndex: 2},
+		{Name: "eth0.10", Index: 3, ParentIndex: 2, MasterIndex: 4},
+		{Name: "br0", Index: 4},
+		{Name: "veth0", Index: 5, ParentIndex: 9},
+	}})
+	list, err := l.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	got := map[string]Interface{}
+	for _, iface := range list {
+		got[iface.Name] = iface
+	}
+	if got["eth0"].Parent != "" || got["eth0.10"].Parent != "eth0" || got["eth0.10"].Master != "br0" || got["veth0"].Parent != "" {
+		t.Fatalf("List() related %+v", list)
+	}
+	if ports := got["br0"].Ports; len(ports) != 1 || ports[0] != "eth0.10" {
+		t.Fatalf("br0 ports = %v, want [eth0.10]", ports)
+	}
+}
+
+func TestSysfsLinkStateReadsStateAndCarrier(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+	if state, carrier := sysfsLinkState(dir); state != "" || carrier {
+		t.Fatalf("sysfsLinkState() = %q, %v without attributes", state, carrier)
+	}
+	write("operstate", "lowerlayerdown\n")
+	if state, carrier := sysfsLinkState(dir); state != "lower-layer-down" || carrier {
+		t.Fatalf("sysfsLinkState() = %q, %v, want lower-layer-down without carrier", state, carrier)
+	}
+	write("operstate", "up\n")
+	write("carrier", "1\n")
+	if state, carrier := sysfsLinkState(dir); state != "up" || !carrier {
+		t.Fatalf("sysfsLinkState() = %q, %v, want up with carrier", state, carrier)
+	}
+}
+
+func TestSysfsKind(t *testing.T) {
+	root := t.TempDir()
+	device := func(name, uevent string, backed bool) string {
+		t.Helper()
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "uevent"), []byte(uevent), 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		if backed {
+			if err := os.Mkdir(filepath.Join(dir, "device"), 0o755); err != nil {
+				t.Fatalf("Mkdir() error = %v", err)
+			}
+		}
+		return dir
+	}
+	tests := []struct {
+		dir  string
+		want string
+	}{
+		{device("br0", "DEVTYPE=bridge\nINTERFACE=br0\nIFINDEX=4\n", false), "bridge"},
+		{device("wlan0", "DEVTYPE=wlan\nINTERFACE=wlan0\n", true), KindPhysical},
+		{device("eth0", "INTERFACE=eth0\nIFINDEX=2\n", true), KindPhysical},
+		{device("veth0", "INTERFACE=veth0\nIFINDEX=5\n", false), ""},
+	}
+	for _, tt := range tests {
+		if got := sysfsKind(tt.dir); got != tt.want {
+			t.Errorf("sysfsKind(%s) = %q, want %q", filepath.Base(tt.dir), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package interfaces
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+
+	"github.com/user/goeth/internal/namespace"
+)
+
+// NetlinkProvider retrieves interface details through
+// github.com/vishvananda/netlink, and their speed and duplex through
+// ethtool. The zero value reads the network namespace of the calling
+// process.
+type NetlinkProvider struct {
+	handle *netlink.Handle
+	netns  netns.NsHandle
+}
+
+// NewNetlinkProviderAt returns a NetlinkProvider that reads inside ns. The
+// namespace handle must stay open for as long as the provider is used.
+func NewNetlinkProviderAt(ns netns.NsHandle) (NetlinkProvider, error) {
+	handle, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		return NetlinkProvider{}, fmt.Errorf("open netlink handle: %w", err)
+	}
+	return NetlinkProvider{handle: handle, netns: ns}, nil
+}
+
+func (p NetlinkProvider) nl() *netlink.Handle {
+	if p.handle == nil {
+		return &netlink.Handle{}
+	}
+	return p.handle
+}
+
+// ListInterfaces fetches the links of the namespace.
+func (p NetlinkProvider) ListInterfaces() ([]Interface, error) {
+	links, err := p.nl().LinkList()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Interface, 0, len(links))
+	for _, link := range links {
+		results = append(results, Describe(link))
+	}
+	if p.handle == nil {
+		ReadLinkSettings(results)
+		return results, nil
+	}
+	err = namespace.Do(p.netns, func() error {
+		ReadLinkSettings(results)
+		return nil
+	})
+	return results, err
+}
+
+// Describe turns a netlink link into an Interface, with the flags named the
+// way NetProvider names them.
+func Describe(link netlink.Link) Interface {
+	attrs := link.Attrs()
+	netFlags := attrs.Flags
+	// netlink leaves IFF_RUNNING out of Flags, unlike the net package.
+	if attrs.RawFlags&unix.IFF_RUNNING != 0 {
+		netFlags |= net.FlagRunning
+	}
+	flags := strings.Split(netFlags.String(), "|")
+	if len(flags) == 1 && flags[0] == "" {
+		flags = nil
+	}
+	iface := Interface{
+		Name:         attrs.Name,
+		Index:        attrs.Index,
+		HardwareAddr: attrs.HardwareAddr.String(),
+		MTU:          attrs.MTU,
+		Flags:        flags,
+		Kind:         linkKind(link),
+		OperState:    attrs.OperState.String(),
+		Carrier:      attrs.RawFlags&unix.IFF_LOWER_UP != 0,
+		MasterIndex:  attrs.MasterIndex,
+	}
+	// The parent of a link with a netns ID, such as a veth peer moved away,
+	// is an index in another namespace.
+	if attrs.NetNsID < 0 {
+		iface.ParentIndex = attrs.ParentIndex
+	}
+	return iface
+}
+
+// linkKind names the kind of link, as Interface.Kind does.
+func linkKind(link netlink.Link) string {
+	switch link := link.(type) {
+	case *netlink.Device:
+		if link.Attrs().Flags&net.FlagLoopback != 0 {
+			return KindLoopback
+		}
+		return KindPhysical
+	case *netlink.Tuntap:
+		if link.Mode == netlink.TUNTAP_MODE_TAP {
+			return "tap"
+		}
+		return "tun"
+	}
+	return link.Type()
+}
@@ -0,0 +1,63 @@
+package interfaces
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestDescribeReportsOperStateAndCarrier(t *testing.T) {
+	mac, _ := net.ParseMAC("02:00:5e:00:53:01")
+	link := &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{
+		Name: "eth0", HardwareAddr: mac, MTU: 1500,
+		Flags:     net.FlagUp,
+		RawFlags:  unix.IFF_UP | unix.IFF_RUNNING | unix.IFF_LOWER_UP,
+		OperState: netlink.OperUp,
+	}}
+	want := Interface{Name: "eth0", HardwareAddr: "02:00:5e:00:53:01", MTU: 1500, Flags: []string{"up", "running"}, Kind: "dummy", OperState: "up", Carrier: true}
+	if got := Describe(link); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Describe() = %#v, want %#v", got, want)
+	}
+}
+
+func TestLinkKind(t *testing.T) {
+	tests := []struct {
+		link netlink.Link
+		want string
+	}{
+		{&netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0"}}, KindPhysical},
+		{&netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "lo", Flags: net.FlagLoopback}}, KindLoopback},
+		{&netlink.Bridge{}, "bridge"},
+		{&netlink.Vlan{}, "vlan"},
+		{&netlink.Veth{}, "veth"},
+		{&netlink.Tuntap{Mode: netlink.TUNTAP_MODE_TUN}, "tun"},
+		{&netlink.Tuntap{Mode: netlink.TUNTAP_MODE_TAP}, "tap"},
+		{&netlink.Wireguard{}, "wireguard"},
+	}
+	for _, tt := range tests {
+		if got := linkKind(tt.link); got != tt.want {
+			t.Errorf("linkKind(%T) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestInterfaceRunning(t *testing.T) {
+	tests := []struct {
+		iface Interface
+		want  bool
+	}{
+		{Interface{OperState: "up", Carrier: true}, true},
+		{Interface{OperState: "unknown", Carrier: true}, true},
+		{Interface{OperState: "lower-layer-down", Carrier: true}, false},
+		{Interface{OperState: "down"}, false},
+		{Interface{}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.iface.Running(); got != tt.want {
+			t.Errorf("%+v.Running() = %v, want %v", tt.iface, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package interfaces
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Branches of a tree as WriteTree draws them; the plain output of goeth
+// transliterates them to ASCII.
+const (
+	treeBranch = "├── "
+	treeLast   = "└── "
+	treeRail   = "│   "
+	treeGap    = "    "
+)
+
+// WriteTree draws the interfaces of list as a tree of stacked devices:
+// ports below their bridge or bond and links such as VLANs below their
+// parent. Each interface is drawn once, below its master when it has both,
+// and veth pairs stay side by side with their peer named. The list is drawn
+// in its order, as Lister.List sorts it.
+func WriteTree(w io.Writer, list []Interface) error {
+	byName := make(map[string]Interface, len(list))
+	for _, iface := range list {
+		byName[iface.Name] = iface
+	}
+	children := make(map[string][]Interface)
+	var roots []Interface
+	for _, iface := range list {
+		if above := treeParent(iface, byName); above != "" {
+			children[above] = append(children[above], iface)
+			continue
+		}
+		roots = append(roots, iface)
+	}
+	var b strings.Builder
+	drawn := make(map[string]bool, len(list))
+	var draw func(iface Interface, prefix, branch, rail string)
+	draw = func(iface Interface, prefix, branch, rail string) {
+		drawn[iface.Name] = true
+		fmt.Fprintf(&b, "%s%s%s\n", prefix+branch, iface.Name, treeLabel(iface, byName))
+		below := children[iface.Name]
+		for i, child := range below {
+			// Links stacked in a loop, which the kernel refuses, would
+			// otherwise be drawn forever.
+			if drawn[child.Name] {
+				continue
+			}
+			if i == len(below)-1 {
+				draw(child, prefix+rail, treeLast, treeGap)
+			} else {
+				draw(child, prefix+rail, treeBranch, treeRail)
+			}
+		}
+	}
+	for _, iface := range roots {
+		draw(iface, "", "", "")
+	}
+	// Whatever is left sits in a loop without a root; draw it rather than
+	// leave it out.
+	for _, iface := range list {
+		if !drawn[iface.Name] {
+			draw(iface, "", "", "")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// treeParent names the interface iface is drawn below: its master, else the
+// link it is stacked on. Veth peers are not stacked on each other.
+func treeParent(iface Interface, byName map[string]Interface) string {
+	if _, ok := byName[iface.Master]; ok {
+		return iface.Master
+	}
+	if _, ok := byName[iface.Parent]; ok && iface.Kind != "veth" {
+		return iface.Parent
+	}
+	return ""
+}
+
+// treeLabel describes iface after its name: its kind and how it relates to
+// interfaces it is not drawn below.
+func treeLabel(iface Interface, byName map[string]Interface) string {
+	var details []string
+	if iface.Kind != "" {
+		details = append(details, iface.Kind)
+	}
+	if _, known := byName[iface.Master]; iface.Master != "" && !known {
+		details = append(details, "port of "+iface.Master)
+	}
+	switch _, known := byName[iface.Parent]; {
+	case iface.Parent == "":
+	case iface.Kind == "veth":
+		details = append(details, "peer "+iface.Parent)
+	case !known || iface.Master != "":
+		details = append(details, "on "+iface.Parent)
+	}
+	if len(details) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(details, ", ") + ")"
+}
@@ -0,0 +1,45 @@
+package interfaces
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteTreeNestsStackedDevices(t *testing.T) {
+	list := []Interface{
+		{Name: "br0", Kind: "bridge", Ports: []string{"eth0", "veth-b"}},
+		{Name: "br0.10", Kind: "vlan", Parent: "br0"},
+		{Name: "eth0", Kind: KindPhysical, Master: "br0"},
+		{Name: "eth1", Kind: KindPhysical},
+		{Name: "eth1.20", Kind: "vlan", Parent: "eth1"},
+		{Name: "lo", Kind: KindLoopback},
+		{Name: "veth-a", Kind: "veth", Parent: "veth-b"},
+		{Name: "veth-b", Kind: "veth", Parent: "veth-a", Master: "br0"},
+		{Name: "wg0", Kind: "wireguard"},
+	}
+	var b strings.Builder
+	if err := WriteTree(&b, list); err != nil {
+		t.Fatalf("WriteTree() error = %v", err)
+	}
+	want := `br0 (bridge)
+├── br0.10 (vlan)
+├── eth0 (physical)
+└── veth-b (veth, peer veth-a)
+eth1 (physical)
+└── eth1.20 (vlan)
+lo (loopback)
+veth-a (veth, peer veth-b)
+wg0 (wireguard)
+`
+	if b.String() != want {
+		t.Fatalf("WriteTree() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestWriteTreeDrawsLoops(t *testing.T) {
+	var b strings.Builder
+	WriteTree(&b, []Interface{{Name: "a", Parent: "b"}, {Name: "b", Parent: "a"}})
+	if want := "a\n└── b\n"; b.String() != want {
+		t.Fatalf("WriteTree() = %q, want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,167 @@
+// Package logfile writes to a file that rotates itself by size and age and
+// keeps a bounded number of old files, so that a long-running monitor
+// neither fills the disk nor needs a logrotate configuration.
+package logfile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileMode is the permission of new log files.
+const fileMode = 0o644
+
+// sizeUnits are the suffixes ParseSize accepts, in binary multiples.
+var sizeUnits = map[string]int64{"": 1, "K": 1 << 10, "M": 1 << 20, "G": 1 << 30}
+
+// ParseSize reads a size such as 512K, 10M or 1G; a plain number is bytes.
+func ParseSize(s string) (int64, error) {
+	upper := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	unit := ""
+	if n := len(upper); n > 0 && strings.ContainsAny(upper[n-1:], "KMG") {
+		upper, unit = upper[:n-1], upper[n-1:]
+	}
+	n, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (want a number of bytes, or one with a K, M or G suffix)", s)
+	}
+	return n * sizeUnits[unit], nil
+}
+
+// File appends to Path. Before a write would take it past MaxSize, or once
+// it has been written to for longer than MaxAge, it is renamed to Path.1,
+// older files moving up to Path.2 and so on, and a new file is started. Only
+// Keep rotated files are retained.
+type File struct {
+	Path string
+	// MaxSize rotates by size when positive.
+	MaxSize int64
+	// MaxAge rotates by age when positive. Age counts from when goeth
+	// started writing the file, since file systems need not record when a
+	// file was created.
+	MaxAge time.Duration
+	// Keep is how many rotated files are retained; zero keeps none.
+	Keep int
+	// Errors receives rotation failures. Writing then carries on in the
+	// current file, so no output is lost.
+	Errors io.Writer
+	// Now overrides the time source (used in tests).
+	Now func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// Open opens or creates Path for appending.
+func (f *File) Open() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.open()
+}
+
+func (f *File) open() error {
+	file, err := os.OpenFile(f.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, fileMode)
+	if err != nil {
+		return fmt.Errorf("log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("log file: %w", err)
+	}
+	f.file, f.size, f.opened = file, info.Size(), f.now()
+	return nil
+}
+
+// Write appends p, rotating first when due.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	if f.due(int64(len(p))) {
+		if err := f.rotate(); err != nil && f.Errors != nil {
+			fmt.Fprintln(f.Errors, err)
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// due reports whether the file should be rotated before adding n bytes. An
+// empty file is never rotated, so a line longer than MaxSize still lands.
+func (f *File) due(n int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.MaxSize > 0 && f.size+n > f.MaxSize {
+		return true
+	}
+	return f.MaxAge > 0 && f.now().Sub(f.opened) >= f.MaxAge
+}
+
+// rotate shifts the rotated files up, dropping the oldest, and starts a new
+// file. If the current file cannot be renamed it stays in use.
+func (f *File) rotate() error {
+	if err := f.shift(); err != nil {
+		return fmt.Errorf("log file rotation: %w", err)
+	}
+	old := f.file
+	if err := f.open(); err != nil {
+		f.file = old
+		return err
+	}
+	old.Close()
+	return nil
+}
+
+// shift moves Path to Path.1 and each Path.N to Path.N+1, removing what
+// would go beyond Keep.
+func (f *File) shift() error {
+	if f.Keep <= 0 {
+		return os.Remove(f.Path)
+	}
+	if err := os.Remove(f.rotated(f.Keep)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := f.Keep - 1; i >= 1; i-- {
+		if err := os.Rename(f.rotated(i), f.rotated(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(f.Path, f.rotated(1))
+}
+
+func (f *File) rotated(i int) string {
+	return fmt.Sprintf("%s.%d", f.Path, i)
+}
+
+// Close closes the current file.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *File) now() time.Time {
+	if f.Now == nil {
+		return time.Now()
+	}
+	return f.Now()
+}
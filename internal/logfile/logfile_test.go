@@ -0,0 +1,94 @@
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{"0": 0, "1500": 1500, "512K": 512 << 10, "10M": 10 << 20, "1g": 1 << 30, "10MB": 10 << 20}
+	for in, want := range tests {
+		if got, err := ParseSize(in); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "ten", "-1M", "5T"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("ParseSize(%q) should fail", in)
+		}
+	}
+}
+
+func TestFileRotatesBySizeAndKeepsSome(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goeth.log")
+	file := &File{Path: path, MaxSize: 10, Keep: 2}
+	defer file.Close()
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if got := read(t, path); got != "fourth\n" {
+		t.Fatalf("current file = %q", got)
+	}
+	if got := read(t, path+".1"); got != "third\n" {
+		t.Fatalf("first rotated file = %q", got)
+	}
+	if got := read(t, path+".2"); got != "second\n" {
+		t.Fatalf("second rotated file = %q", got)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatal("only Keep rotated files may be retained")
+	}
+}
+
+func TestFileRotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goeth.log")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	file := &File{Path: path, MaxAge: time.Hour, Keep: 1, Now: func() time.Time { return now }}
+	defer file.Close()
+	file.Write([]byte("monday\n"))
+	now = now.Add(59 * time.Minute)
+	file.Write([]byte("still monday\n"))
+	now = now.Add(time.Minute)
+	file.Write([]byte("tuesday\n"))
+	if got := read(t, path+".1"); got != "monday\nstill monday\n" {
+		t.Fatalf("rotated file = %q", got)
+	}
+	if got := read(t, path); got != "tuesday\n" {
+		t.Fatalf("current file = %q", got)
+	}
+}
+
+func TestFileAppendsAndKeepsNothingWhenAsked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goeth.log")
+	if err := os.WriteFile(path, []byte("earlier run\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file := &File{Path: path, MaxSize: 25}
+	defer file.Close()
+	file.Write([]byte("appended\n"))
+	if got := read(t, path); got != "earlier run\nappended\n" {
+		t.Fatalf("an existing file must be appended to, got %q", got)
+	}
+	file.Write([]byte("a line too many\n"))
+	if got := read(t, path); got != "a line too many\n" {
+		t.Fatalf("current file = %q", got)
+	}
+	matches, _ := filepath.Glob(path + ".*")
+	if len(matches) != 0 {
+		t.Fatalf("nothing may be kept, found %s", strings.Join(matches, ", "))
+	}
+}
@@ -0,0 +1,71 @@
+package logsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/user/goeth/internal/queue"
+)
+
+// JournalSocket is where systemd-journald takes native protocol entries.
+const JournalSocket = "/run/systemd/journal/socket"
+
+// Field is one field of a journal entry. Names are upper case letters,
+// digits and underscores, as journald requires.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// EncodeJournal formats an entry in the native journal protocol. Values
+// spanning several lines are sent length-prefixed; empty values are left
+// out.
+func EncodeJournal(fields []Field) []byte {
+	var entry bytes.Buffer
+	for _, field := range fields {
+		if field.Value == "" {
+			continue
+		}
+		if !strings.Contains(field.Value, "\n") {
+			fmt.Fprintf(&entry, "%s=%s\n", field.Name, field.Value)
+			continue
+		}
+		entry.WriteString(field.Name + "\n")
+		binary.Write(&entry, binary.LittleEndian, uint64(len(field.Value)))
+		entry.WriteString(field.Value + "\n")
+	}
+	return entry.Bytes()
+}
+
+// Journal queues entries and sends them to journald in the background. An
+// entry must fit in a single datagram, which monitor events easily do.
+type Journal struct {
+	*queue.Queue[[]Field]
+	conn *net.UnixConn
+}
+
+// DialJournal connects to the journald socket at path, usually
+// JournalSocket, and returns a Journal holding up to size unsent entries.
+func DialJournal(path string, size int) (*Journal, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("journald: %w", err)
+	}
+	return &Journal{Queue: queue.New[[]Field]("journald", size), conn: conn}, nil
+}
+
+// Run sends queued entries until ctx is cancelled, then closes the
+// connection.
+func (j *Journal) Run(ctx context.Context) error {
+	defer j.conn.Close()
+	return j.Queue.Run(ctx, func(_ context.Context, fields []Field) error {
+		if _, err := j.conn.Write(EncodeJournal(fields)); err != nil {
+			return fmt.Errorf("journald: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,43 @@
+package logsink
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEncodeJournal(t *testing.T) {
+	got := EncodeJournal([]Field{{"MESSAGE", "eth0 link went down"}, {"OLD", ""}, {"NEW", "a\nb"}})
+	want := "MESSAGE=eth0 link went down\nNEW\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\n"
+	if string(got) != want {
+		t.Fatalf("EncodeJournal() = %q, want %q", got, want)
+	}
+}
+
+func TestJournalSendsEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+	journal, err := DialJournal(path, 1)
+	if err != nil {
+		t.Fatalf("DialJournal() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go journal.Run(ctx)
+	journal.Send([]Field{{"IFACE", "eth0"}, {"EVENT_TYPE", "link_down"}})
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got := string(buf[:n]); got != "IFACE=eth0\nEVENT_TYPE=link_down\n" {
+		t.Fatalf("unexpected entry %q", got)
+	}
+}
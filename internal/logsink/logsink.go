@@ -0,0 +1,129 @@
+// Package logsink writes lines to syslog, locally or on a remote server, and
+// structured entries to the systemd journal, so that monitor events reach
+// existing log aggregation without wrapping the process.
+package logsink
+
+import (
+	"context"
+	"fmt"
+	"log/syslog"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/user/goeth/internal/queue"
+)
+
+// Tag is the program name messages are logged under.
+const Tag = "goeth"
+
+// defaultPort is the syslog port used when a remote target names none.
+const defaultPort = "514"
+
+// facilities and severities name the parts of a syslog priority as
+// syslog.conf(5) does.
+var (
+	facilities = map[string]syslog.Priority{
+		"kern": syslog.LOG_KERN, "user": syslog.LOG_USER, "mail": syslog.LOG_MAIL, "daemon": syslog.LOG_DAEMON,
+		"auth": syslog.LOG_AUTH, "syslog": syslog.LOG_SYSLOG, "lpr": syslog.LOG_LPR, "news": syslog.LOG_NEWS,
+		"uucp": syslog.LOG_UUCP, "cron": syslog.LOG_CRON, "authpriv": syslog.LOG_AUTHPRIV, "ftp": syslog.LOG_FTP,
+		"local0": syslog.LOG_LOCAL0, "local1": syslog.LOG_LOCAL1, "local2": syslog.LOG_LOCAL2, "local3": syslog.LOG_LOCAL3,
+		"local4": syslog.LOG_LOCAL4, "local5": syslog.LOG_LOCAL5, "local6": syslog.LOG_LOCAL6, "local7": syslog.LOG_LOCAL7,
+	}
+	severities = map[string]syslog.Priority{
+		"emerg": syslog.LOG_EMERG, "alert": syslog.LOG_ALERT, "crit": syslog.LOG_CRIT, "err": syslog.LOG_ERR,
+		"warning": syslog.LOG_WARNING, "notice": syslog.LOG_NOTICE, "info": syslog.LOG_INFO, "debug": syslog.LOG_DEBUG,
+	}
+)
+
+// Priority combines a facility and a severity given by name.
+func Priority(facility, severity string) (syslog.Priority, error) {
+	f, ok := facilities[facility]
+	if !ok {
+		return 0, fmt.Errorf("unknown syslog facility %q (want one of %s)", facility, names(facilities))
+	}
+	s, err := Severity(severity)
+	if err != nil {
+		return 0, err
+	}
+	return f | s, nil
+}
+
+// Severity returns the severity given by name.
+func Severity(name string) (syslog.Priority, error) {
+	s, ok := severities[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown syslog severity %q (want one of %s)", name, names(severities))
+	}
+	return s, nil
+}
+
+func names(m map[string]syslog.Priority) string {
+	list := make([]string, 0, len(m))
+	for name := range m {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
+
+// Sink queues lines and writes them to syslog in the background.
+type Sink struct {
+	*queue.Queue[string]
+	writer *syslog.Writer
+}
+
+// Dial connects to target with priority and returns a Sink holding up to
+// size unwritten lines. target is "local" for the local syslog daemon, or a
+// URL: udp://host[:port] or tcp://host[:port] for a remote server (port 514
+// by default), unix:///path or unixgram:///path for a socket.
+func Dial(target string, priority syslog.Priority, size int) (*Sink, error) {
+	network, addr, err := parseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := syslog.Dial(network, addr, priority, Tag)
+	if err != nil {
+		return nil, fmt.Errorf("syslog %s: %w", target, err)
+	}
+	return &Sink{Queue: queue.New[string]("syslog", size), writer: writer}, nil
+}
+
+func parseTarget(target string) (network, addr string, err error) {
+	if target == "local" {
+		return "", "", nil
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", "", fmt.Errorf("syslog target %q: %w", target, err)
+	}
+	switch u.Scheme {
+	case "udp", "tcp":
+		if u.Host == "" {
+			return "", "", fmt.Errorf("syslog target %q has no host", target)
+		}
+		if u.Port() == "" {
+			return u.Scheme, net.JoinHostPort(u.Hostname(), defaultPort), nil
+		}
+		return u.Scheme, u.Host, nil
+	case "unix", "unixgram":
+		if u.Path == "" {
+			return "", "", fmt.Errorf("syslog target %q has no path", target)
+		}
+		return u.Scheme, u.Path, nil
+	}
+	return "", "", fmt.Errorf("syslog target %q must be local or a udp, tcp, unix or unixgram URL", target)
+}
+
+// Run writes queued lines until ctx is cancelled, then closes the
+// connection.
+func (s *Sink) Run(ctx context.Context) error {
+	defer s.writer.Close()
+	return s.Queue.Run(ctx, func(_ context.Context, line string) error {
+		if _, err := s.writer.Write([]byte(line)); err != nil {
+			return fmt.Errorf("syslog: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,74 @@
+package logsink
+
+import (
+	"context"
+	"log/syslog"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPriority(t *testing.T) {
+	got, err := Priority("daemon", "notice")
+	if err != nil || got != syslog.LOG_DAEMON|syslog.LOG_NOTICE {
+		t.Fatalf("Priority(daemon, notice) = %v, %v", got, err)
+	}
+	if _, err := Priority("daemons", "notice"); err == nil || !strings.Contains(err.Error(), "local7") {
+		t.Fatalf("expected an unknown facility error listing the names, got %v", err)
+	}
+	if _, err := Priority("daemon", "warn"); err == nil {
+		t.Fatal("expected an unknown severity error")
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		target, network, addr string
+	}{
+		{"local", "", ""},
+		{"udp://192.0.2.1", "udp", "192.0.2.1:514"},
+		{"tcp://[2001:db8::1]:6514", "tcp", "[2001:db8::1]:6514"},
+		{"unixgram:///dev/log", "unixgram", "/dev/log"},
+	}
+	for _, tt := range tests {
+		network, addr, err := parseTarget(tt.target)
+		if err != nil || network != tt.network || addr != tt.addr {
+			t.Errorf("parseTarget(%q) = %q, %q, %v, want %q, %q", tt.target, network, addr, err, tt.network, tt.addr)
+		}
+	}
+	for _, target := range []string{"syslog.example.com", "udp://", "http://192.0.2.1"} {
+		if _, _, err := parseTarget(target); err == nil {
+			t.Errorf("parseTarget(%q) should fail", target)
+		}
+	}
+}
+
+func TestSinkWritesWithThePriority(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+	sink, err := Dial("unixgram://"+path, syslog.LOG_LOCAL3|syslog.LOG_WARNING, 1)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sink.Run(ctx)
+	sink.Send("eth0 link went down (no carrier)")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	// local3.warning is 19*8+4.
+	got := string(buf[:n])
+	if !strings.HasPrefix(got, "<156>") || !strings.Contains(got, "goeth[") || !strings.HasSuffix(got, "eth0 link went down (no carrier)\n") {
+		t.Fatalf("unexpected message %q", got)
+	}
+}
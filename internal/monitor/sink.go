@@ -0,0 +1,135 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/user/goeth/pkg/netevent"
+)
+
+// EventSink receives the changes a Watcher reports. Send is called from the
+// monitoring loop and must not block: a sink that delivers slowly queues.
+type EventSink interface {
+	Send(event netevent.Event)
+}
+
+// SinkFunc adapts a function to EventSink.
+type SinkFunc func(event netevent.Event)
+
+// Send calls f.
+func (f SinkFunc) Send(event netevent.Event) {
+	f(event)
+}
+
+// JSONSink writes each change to Writer as a JSON document on a line of its
+// own, for tools that follow the monitor.
+type JSONSink struct {
+	Writer io.Writer
+	// Errors receives write failures.
+	Errors io.Writer
+	mu     sync.Mutex
+}
+
+// Send writes event.
+func (s *JSONSink) Send(event netevent.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := json.NewEncoder(s.Writer).Encode(event); err != nil && s.Errors != nil {
+		fmt.Fprintln(s.Errors, "json events:", err)
+	}
+}
+
+// metricsFileMode is the permission of metrics files.
+const metricsFileMode = 0o644
+
+// MetricsSink counts the changes by interface, kind and type and keeps the
+// counts in Path in the Prometheus text format, for the textfile collector
+// of node_exporter.
+type MetricsSink struct {
+	Path string
+	// Errors receives write failures.
+	Errors  io.Writer
+	mu      sync.Mutex
+	counts  map[metricKey]uint64
+	changed chan struct{}
+}
+
+type metricKey struct {
+	iface, kind, typ string
+}
+
+// NewMetricsSink returns a MetricsSink writing to path.
+func NewMetricsSink(path string) *MetricsSink {
+	return &MetricsSink{Path: path, counts: make(map[metricKey]uint64), changed: make(chan struct{}, 1)}
+}
+
+// Send counts event. The file is written by Run.
+func (s *MetricsSink) Send(event netevent.Event) {
+	s.mu.Lock()
+	s.counts[metricKey{event.Interface, event.Kind, event.Type}]++
+	s.mu.Unlock()
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Run writes the file once, so that it exists before any change, and again
+// after changes until ctx is cancelled, the last time for the changes counted
+// since the previous write.
+func (s *MetricsSink) Run(ctx context.Context) error {
+	for {
+		if err := s.write(); err != nil && s.Errors != nil {
+			fmt.Fprintln(s.Errors, err)
+		}
+		select {
+		case <-ctx.Done():
+			select {
+			case <-s.changed:
+				if err := s.write(); err != nil && s.Errors != nil {
+					fmt.Fprintln(s.Errors, err)
+				}
+			default:
+			}
+			return ctx.Err()
+		case <-s.changed:
+		}
+	}
+}
+
+// WriteMetrics writes the counts in the Prometheus text format.
+func (s *MetricsSink) WriteMetrics(w io.Writer) error {
+	s.mu.Lock()
+	lines := make([]string, 0, len(s.counts))
+	for key, n := range s.counts {
+		lines = append(lines, fmt.Sprintf("goeth_monitor_events_total{interface=%q,kind=%q,type=%q} %d\n", key.iface, key.kind, key.typ, n))
+	}
+	s.mu.Unlock()
+	sort.Strings(lines)
+	_, err := io.WriteString(w, "# HELP goeth_monitor_events_total Changes reported by goeth monitor.\n"+
+		"# TYPE goeth_monitor_events_total counter\n"+strings.Join(lines, ""))
+	return err
+}
+
+// write replaces Path through a temporary file, as the textfile collector
+// requires.
+func (s *MetricsSink) write() error {
+	var buf strings.Builder
+	s.WriteMetrics(&buf)
+	temp := filepath.Join(filepath.Dir(s.Path), "."+filepath.Base(s.Path)+".tmp")
+	if err := os.WriteFile(temp, []byte(buf.String()), metricsFileMode); err != nil {
+		return fmt.Errorf("metrics: %w", err)
+	}
+	if err := os.Rename(temp, s.Path); err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("metrics: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/goeth/pkg/netevent"
+)
+
+func TestJSONSinkWritesOneDocumentPerLine(t *testing.T) {
+	writer := &bytes.Buffer{}
+	sink := &JSONSink{Writer: writer}
+	sink.Send(netevent.Event{Kind: netevent.Link, Type: "link_down", Interface: "eth0"})
+	sink.Send(netevent.Event{Kind: netevent.Address, Type: "address_added", Interface: "eth1", New: "192.0.2.1/24"})
+	lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), writer.String())
+	}
+	var event netevent.Event
+	if err := json.Unmarshal([]byte(lines[1]), &event); err != nil || event.New != "192.0.2.1/24" {
+		t.Fatalf("second line = %q (%v)", lines[1], err)
+	}
+}
+
+func TestMetricsSinkCountsEvents(t *testing.T) {
+	sink := NewMetricsSink("")
+	for _, event := range []netevent.Event{
+		{Kind: netevent.Link, Type: "link_down", Interface: "eth0"},
+		{Kind: netevent.Link, Type: "link_up", Interface: "eth0"},
+		{Kind: netevent.Link, Type: "link_down", Interface: "eth0"},
+	} {
+		sink.Send(event)
+	}
+	writer := &bytes.Buffer{}
+	if err := sink.WriteMetrics(writer); err != nil {
+		t.Fatalf("WriteMetrics() error = %v", err)
+	}
+	want := "# HELP goeth_monitor_events_total Changes reported by goeth monitor.\n" +
+		"# TYPE goeth_monitor_events_total counter\n" +
+		`goeth_monitor_events_total{interface="eth0",kind="link",type="link_down"} 2` + "\n" +
+		`goeth_monitor_events_total{interface="eth0",kind="link",type="link_up"} 1` + "\n"
+	if got := writer.String(); got != want {
+		t.Fatalf("WriteMetrics() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMetricsSinkKeepsTheFileCurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goeth.prom")
+	sink := NewMetricsSink(path)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sink.Run(ctx)
+	sink.Send(netevent.Event{Kind: netevent.Route, Type: "route_added", Interface: "eth0"})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(path)
+		if strings.Contains(string(data), `type="route_added"} 1`) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("metrics file = %q", data)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMetricsSinkWritesTheLastChangesWhenStopped(t *testing.T) {
+	// Run sees the change and the cancellation at once, in either order.
+	for range 20 {
+		path := filepath.Join(t.TempDir(), "goeth.prom")
+		sink := NewMetricsSink(path)
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan struct{})
+		go func() {
+			sink.Run(ctx)
+			close(stopped)
+		}()
+		for {
+			if _, err := os.Stat(path); err == nil {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		sink.Send(netevent.Event{Kind: netevent.Link, Type: "link_down", Interface: "eth0"})
+		cancel()
+		<-stopped
+		data, err := os.ReadFile(path)
+		if err != nil || !strings.Contains(string(data), `type="link_down"} 1`) {
+			t.Fatalf("metrics file = %q, %v; want the change sent before stopping", data, err)
+		}
+	}
+}
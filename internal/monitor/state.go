@@ -0,0 +1,110 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/user/goeth/internal/counters"
+	"github.com/user/goeth/internal/interfaces"
+	"github.com/user/goeth/internal/neighbors"
+	"github.com/user/goeth/internal/routes"
+	"github.com/user/goeth/pkg/netevent"
+)
+
+// stateVersion is the format of saved states; a state of another version is
+// ignored rather than misread.
+const stateVersion = 1
+
+// stateFileMode is the permission of saved states.
+const stateFileMode = 0o644
+
+// savedState is a snapshot as Watcher.StatePath holds it. Traffic counters
+// are left out: rates across a restart would mean nothing.
+type savedState struct {
+	Version    int                             `json:"version"`
+	Saved      time.Time                       `json:"saved"`
+	Interfaces map[string]interfaces.Interface `json:"interfaces"`
+	Addresses  map[string][]string             `json:"addresses"`
+	Routes     map[string][]routes.Route       `json:"routes,omitempty"`
+	Neighbors  map[string][]neighbors.Neighbor `json:"neighbors,omitempty"`
+	DownSince  map[string]time.Time            `json:"down_since,omitempty"`
+}
+
+// loadState returns the snapshot saved at StatePath and when it was saved,
+// or nil when there is none.
+func (w Watcher) loadState() (*snapshot, time.Time, error) {
+	if w.StatePath == "" {
+		return nil, time.Time{}, nil
+	}
+	data, err := os.ReadFile(w.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("monitor state: %w", err)
+	}
+	var state savedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, time.Time{}, fmt.Errorf("monitor state %s: %w", w.StatePath, err)
+	}
+	if state.Version != stateVersion {
+		return nil, time.Time{}, nil
+	}
+	snap := &snapshot{
+		Snapshot: netevent.Snapshot{
+			Interfaces: state.Interfaces,
+			Addresses:  state.Addresses,
+			Routes:     state.Routes,
+			Neighbors:  state.Neighbors,
+		},
+		traffic:   make(map[string]counters.Sample),
+		downSince: state.DownSince,
+	}
+	if snap.downSince == nil {
+		snap.downSince = make(map[string]time.Time)
+	}
+	// The filter may have changed since; what it now leaves out is not
+	// reported as removed.
+	for name := range snap.Interfaces {
+		if !w.watches(name) {
+			delete(snap.Interfaces, name)
+			delete(snap.Addresses, name)
+			delete(snap.Routes, name)
+			delete(snap.Neighbors, name)
+		}
+	}
+	return snap, state.Saved, nil
+}
+
+// saveState writes snap to StatePath, through a temporary file so that a
+// crash never leaves half a state behind.
+func (w Watcher) saveState(snap snapshot) error {
+	if w.StatePath == "" {
+		return nil
+	}
+	data, err := json.Marshal(savedState{
+		Version:    stateVersion,
+		Saved:      w.now(),
+		Interfaces: snap.Interfaces,
+		Addresses:  snap.Addresses,
+		Routes:     snap.Routes,
+		Neighbors:  snap.Neighbors,
+		DownSince:  snap.downSince,
+	})
+	if err != nil {
+		return fmt.Errorf("monitor state: %w", err)
+	}
+	temp := filepath.Join(filepath.Dir(w.StatePath), "."+filepath.Base(w.StatePath)+".tmp")
+	if err := os.WriteFile(temp, data, stateFileMode); err != nil {
+		return fmt.Errorf("monitor state: %w", err)
+	}
+	if err := os.Rename(temp, w.StatePath); err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("monitor state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/goeth/internal/addresses"
+	"github.com/user/goeth/internal/interfaces"
+)
+
+func TestWatcherResumesFromSavedState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monitor.json")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	run := func(list []interfaces.Interface, addrs map[string][]string) string {
+		writer := &bytes.Buffer{}
+		watcher := Watcher{
+			Lister:    interfaces.NewLister(stubInterfaceProvider{interfaces: list}),
+			Viewer:    addresses.NewViewer(stubAddressProvider{addrs: addrs}),
+			Interval:  time.Hour,
+			Writer:    writer,
+			StatePath: path,
+			Now:       func() time.Time { return now },
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := watcher.Run(ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Run() error = %v", err)
+		}
+		return writer.String()
+	}
+	first := run([]interfaces.Interface{{Name: "eth0", MTU: 1500}, {Name: "eth1", MTU: 1500}}, map[string][]string{"eth0": {"192.0.2.1/24"}})
+	if !strings.Contains(first, " - eth0 (MTU=1500") {
+		t.Fatalf("the first run must list the state:\n%s", first)
+	}
+	now = now.Add(time.Hour)
+	second := run([]interfaces.Interface{{Name: "eth0", MTU: 9000}}, map[string][]string{"eth0": {"192.0.2.1/24"}})
+	want := "[2024-01-01T01:00:00Z] monitoring started (interval 1h0m0s)\n" +
+		" - resuming from the state saved at 2024-01-01T00:00:00Z\n" +
+		"[2024-01-01T01:00:00Z] interface eth1 removed\n" +
+		"[2024-01-01T01:00:00Z] interface eth0 updated: MTU 1500→9000\n"
+	if second != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", second, want)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(path), ".monitor.json.tmp")); !os.IsNotExist(err) {
+		t.Fatal("the temporary state file must not be left behind")
+	}
+}
+
+func TestLoadStateIgnoresOtherVersionsAndFilteredInterfaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monitor.json")
+	watcher := Watcher{StatePath: path}
+	if snap, _, err := watcher.loadState(); err != nil || snap != nil {
+		t.Fatalf("loadState() without a file = %v, %v", snap, err)
+	}
+	os.WriteFile(path, []byte(`{"version": 99, "interfaces": {"eth0": {"name": "eth0"}}}`), 0o644)
+	if snap, _, err := watcher.loadState(); err != nil || snap != nil {
+		t.Fatalf("loadState() of another version = %v, %v", snap, err)
+	}
+	os.WriteFile(path, []byte(`{"version": 1, "interfaces": {"eth0": {"name": "eth0"}, "lo": {"name": "lo"}}}`), 0o644)
+	watcher.Interfaces = []string{"eth*"}
+	snap, _, err := watcher.loadState()
+	if err != nil || snap == nil {
+		t.Fatalf("loadState() = %v, %v", snap, err)
+	}
+	if _, ok := snap.Interfaces["lo"]; ok || len(snap.Interfaces) != 1 {
+		t.Fatalf("loadState() kept %v, want only eth0", snap.Interfaces)
+	}
+	os.WriteFile(path, []byte("not-json"), 0o644)
+	if _, _, err := watcher.loadState(); err == nil {
+		t.Fatal("expected a parse error")
+	}
+}
@@ -5,33 +5,171 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
+	"path"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/user/goeth/internal/addresses"
+	"github.com/user/goeth/internal/announce"
+	"github.com/user/goeth/internal/backoff"
+	"github.com/user/goeth/internal/counters"
+	"github.com/user/goeth/internal/i18n"
 	"github.com/user/goeth/internal/interfaces"
+	"github.com/user/goeth/internal/neighbors"
+	"github.com/user/goeth/internal/output"
+	"github.com/user/goeth/internal/routes"
+	"github.com/user/goeth/pkg/netevent"
 )
 
-// Watcher polls the operating system for interface information and reports changes.
+// Watcher polls the operating system for interface information, or follows
+// notifications of changes to it, and reports changes.
 type Watcher struct {
 	// Lister provides the current interface list.
 	Lister interfaces.Lister
 	// Viewer provides the addresses for a given interface.
 	Viewer addresses.Viewer
+	// Routes, when set, provides the routes through a given interface so
+	// that route changes are reported too.
+	Routes *routes.Viewer
+	// Neighbors, when set, provides the ARP and NDP entries of a given
+	// interface, so that entries appearing, becoming stale, failing,
+	// changing their link-layer address or going away are reported too.
+	Neighbors *neighbors.Viewer
+	// NeighborIPs restricts the neighbors reported to these addresses. When
+	// empty all neighbors are reported.
+	NeighborIPs []string
+	// Traffic, when set, provides the interface counters, so that the rates
+	// and the errors and drops of each Interval are reported too. The
+	// counters are read afresh, since no notification follows them.
+	Traffic counters.Source
+	// Prober, when set, probes the IPv4 addresses of the running interfaces
+	// on start and every ProbeEvery, and other hosts answering for one of
+	// them are reported as address conflicts.
+	Prober AddressProber
+	// ProbeEvery is the period of Prober.
+	ProbeEvery time.Duration
+	// Gateways, when set, checks on start and every GatewayEvery that the
+	// IPv4 default gateways of the running interfaces answer, and reports
+	// those that stop or start answering. It takes the gateways from Routes.
+	Gateways GatewayProber
+	// GatewayEvery is the period of Gateways.
+	GatewayEvery time.Duration
 	// Interval controls how frequently the state is refreshed.
 	Interval time.Duration
-	// Interface restricts monitoring to a single interface. When empty all interfaces are monitored.
-	Interface string
+	// Changes, when set, refreshes the state whenever it receives a value
+	// instead of every Interval, so changes are reported as they happen.
+	Changes <-chan struct{}
+	// Interfaces restricts monitoring to the interfaces named, each name
+	// possibly a shell pattern such as eth* (see path.Match). When empty all
+	// interfaces are monitored.
+	Interfaces []string
+	// InterfaceRegex, when set, restricts monitoring to the interfaces whose
+	// name it matches, in addition to Interfaces.
+	InterfaceRegex *regexp.Regexp
 	// Writer receives human-readable change notifications.
 	Writer io.Writer
+	// SummaryEvery prints a rollup of the changes seen in each period. Zero disables it.
+	SummaryEvery time.Duration
+	// SummaryOnly suppresses the per-change lines so that only rollups are printed.
+	SummaryOnly bool
+	// Kinds restricts the changes reported to these kinds, such as
+	// netevent.Link, and the state the other kinds are made of is not
+	// collected at all. When empty all kinds are reported.
+	Kinds []string
+	// Quiet leaves out the header and the state printed on start, so that
+	// only changes are printed.
+	Quiet bool
+	// Messages localizes the output; the zero value prints English.
+	Messages i18n.Printer
+	// Colors paints additions, removals and updates; the zero value prints
+	// them uncolored.
+	Colors output.Colorizer
+	// Duration makes Run return nil after this long when positive.
+	Duration time.Duration
+	// MaxEvents makes Run return nil once this many changes have been
+	// reported when positive. Changes found together are reported together,
+	// so the last batch may take the count past MaxEvents.
+	MaxEvents int
+	// StatePath, when set, is where the state is saved after every refresh.
+	// A state saved there by an earlier run is loaded on start, and the
+	// changes since are reported instead of the whole state.
+	StatePath string
+	// FailOnRemoval makes Run return ErrInterfaceLost as soon as a
+	// monitored interface is removed or loses its carrier.
+	FailOnRemoval bool
+	// HeartbeatEvery, when positive, sends Sinks an event of the kind
+	// netevent.Heartbeat this often, changes or not, so that their consumers
+	// can tell a quiet network from a monitor that stopped. Heartbeats are
+	// not printed and do not count towards MaxEvents.
+	HeartbeatEvery time.Duration
+	// Sinks receive every change as it is reported, whether or not
+	// SummaryOnly holds back the line.
+	Sinks []EventSink
 	// Now overrides the time source (used in tests).
 	Now func() time.Time
 }
 
+// AddressProber finds the other hosts answering for the addresses of some
+// interfaces, keyed by interface name; announce.Prober is one.
+type AddressProber interface {
+	Probe(ips map[string][]net.IP) ([]announce.Claim, error)
+}
+
+// GatewayProber tells whether a gateway answers through an interface;
+// pmtu.Prober pings it and announce.Prober sends it ARP probes.
+type GatewayProber interface {
+	Reachable(name string, gateway net.IP) (bool, error)
+}
+
+// ErrInterfaceLost is returned by Run with FailOnRemoval when a monitored
+// interface goes away or loses its carrier.
+var ErrInterfaceLost = errors.New("monitored interface lost")
+
+// snapshot is a netevent.Snapshot with what the Watcher keeps to report
+// traffic and outages.
 type snapshot struct {
-	interfaces map[string]interfaces.Interface
-	addresses  map[string][]string
+	netevent.Snapshot
+	traffic map[string]counters.Sample
+	// downSince records when each link that went down while being watched
+	// did so, to report for how long it was down once it comes back up.
+	downSince map[string]time.Time
+}
+
+// Events runs the Watcher until ctx is done and delivers each change it
+// reports on the returned channel, which is closed once it stops. Unlike
+// other sinks the channel holds the Watcher up until each change is
+// received. When the Watcher stops on an error, the last event has the kind
+// netevent.Error and the error as its Message. The lines are still written
+// to Writer, or dropped when it is nil.
+func (w Watcher) Events(ctx context.Context) <-chan netevent.Event {
+	out := make(chan netevent.Event)
+	if w.Writer == nil {
+		w.Writer = io.Discard
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	w.Sinks = append(slices.Clip(w.Sinks), SinkFunc(func(event netevent.Event) {
+		select {
+		case out <- event:
+		case <-ctx.Done():
+		}
+	}))
+	go func() {
+		defer close(out)
+		defer cancel()
+		err := w.Run(ctx)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		select {
+		case out <- netevent.Event{Time: w.now(), Kind: netevent.Error, Message: err.Error()}:
+		case <-ctx.Done():
+		}
+	}()
+	return out
 }
 
 // Run starts the monitoring loop until the context is cancelled or an error occurs.
@@ -39,164 +177,950 @@ func (w Watcher) Run(ctx context.Context) error {
 	if w.Writer == nil {
 		return errors.New("writer is required")
 	}
-	if w.Interval <= 0 {
+	for _, kind := range w.Kinds {
+		if !slices.Contains(netevent.Kinds, kind) {
+			return fmt.Errorf("unknown event kind %q, want one of %s", kind, strings.Join(netevent.Kinds, ", "))
+		}
+	}
+	if !w.wants(netevent.Route) {
+		w.Routes = nil
+		w.Gateways = nil
+	}
+	if !w.wants(netevent.Neighbor) {
+		w.Neighbors = nil
+	}
+	if !w.wants(netevent.Traffic) {
+		w.Traffic = nil
+	}
+	if !w.wants(netevent.Address) {
+		w.Prober = nil
+	}
+	if (w.Changes == nil || w.Traffic != nil) && w.Interval <= 0 {
 		return errors.New("interval must be positive")
 	}
+	if w.Duration < 0 || w.MaxEvents < 0 || w.HeartbeatEvery < 0 {
+		return errors.New("run duration, event limit and heartbeat period must not be negative")
+	}
+	if w.SummaryEvery < 0 || (w.SummaryOnly && w.SummaryEvery == 0) {
+		return errors.New("summary-only output requires a positive summary period")
+	}
+	if w.Prober != nil && w.ProbeEvery <= 0 {
+		return errors.New("probe period must be positive")
+	}
+	if w.Gateways != nil && (w.GatewayEvery <= 0 || w.Routes == nil) {
+		return errors.New("gateway checks require routes and a positive period")
+	}
+	for _, pattern := range w.Interfaces {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid interface pattern %q", pattern)
+		}
+	}
+	for _, ip := range w.NeighborIPs {
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid neighbor address %q", ip)
+		}
+	}
+
+	events := 0
+	if w.MaxEvents > 0 {
+		w.Sinks = append([]EventSink{SinkFunc(func(event netevent.Event) {
+			if event.Kind != netevent.Heartbeat {
+				events++
+			}
+		})}, w.Sinks...)
+	}
+	counts := newTally()
 
 	current, err := w.collect()
 	if err != nil {
 		return err
 	}
-	w.printInitial(current)
+	saved, savedAt, err := w.loadState()
+	if err != nil {
+		return err
+	}
+	if saved == nil {
+		if !w.Quiet {
+			w.printInitial(current)
+		}
+		if w.wants(netevent.Address) {
+			w.reportDuplicates(snapshot{}, current, counts)
+		}
+	} else {
+		if !w.Quiet {
+			w.printHeader()
+			w.Messages.Fprintf(w.Writer, " - resuming from the state saved at %s\n", savedAt.Format(time.RFC3339))
+		}
+		w.reportChanges(*saved, current, counts)
+		if w.FailOnRemoval {
+			if err := lost(*saved, current); err != nil {
+				return err
+			}
+		}
+	}
+	if err := w.saveState(current); err != nil {
+		return err
+	}
+	// claims are the conflicts the last probe found, so that each is
+	// reported once.
+	claims := make(map[string]bool)
+	var probes <-chan time.Time
+	if w.Prober != nil {
+		if claims, err = w.probe(current, claims, counts); err != nil {
+			return err
+		}
+		probeTicker := time.NewTicker(w.ProbeEvery)
+		defer probeTicker.Stop()
+		probes = probeTicker.C
+	}
+	// reachable records which gateways answered the last check.
+	reachable := make(map[string]bool)
+	var gatewayChecks <-chan time.Time
+	if w.Gateways != nil {
+		if reachable, err = w.checkGateways(current, reachable, counts); err != nil {
+			return err
+		}
+		gatewayTicker := time.NewTicker(w.GatewayEvery)
+		defer gatewayTicker.Stop()
+		gatewayChecks = gatewayTicker.C
+	}
 
-	ticker := time.NewTicker(w.Interval)
-	defer ticker.Stop()
+	var deadline <-chan time.Time
+	if w.Duration > 0 {
+		timer := time.NewTimer(w.Duration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	var ticks <-chan time.Time
+	if w.Changes == nil || w.Traffic != nil {
+		ticker := time.NewTicker(w.Interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+	var heartbeats <-chan time.Time
+	if w.HeartbeatEvery > 0 {
+		heartbeatTicker := time.NewTicker(w.HeartbeatEvery)
+		defer heartbeatTicker.Stop()
+		heartbeats = heartbeatTicker.C
+	}
+	var summaries <-chan time.Time
+	if w.SummaryEvery > 0 {
+		summaryTicker := time.NewTicker(w.SummaryEvery)
+		defer summaryTicker.Stop()
+		summaries = summaryTicker.C
+	}
+	// measured is the snapshot the traffic of the next interval is measured
+	// from; refreshes on notifications in between do not move it.
+	measured := current
+	// failures counts the refreshes in a row that could not collect the
+	// state and delay is the wait after the latest; until retry fires,
+	// ticks and notifications are let go.
+	failures, delay := 0, time.Duration(0)
+	var retry <-chan time.Time
+	refresh := func(interval bool) error {
+		next, err := w.collect()
+		if err != nil {
+			failures++
+			if failures == 1 {
+				delay = retryPolicy.Backoff
+			} else {
+				delay = retryPolicy.Next(delay)
+			}
+			w.Messages.Fprintf(w.Writer, "[%s] could not collect the state, retrying in %s: %v\n", w.timestamp(), delay, err)
+			retry = time.After(delay)
+			return nil
+		}
+		if failures > 0 {
+			w.Messages.Fprintf(w.Writer, "[%s] collected the state again after %d failures\n", w.timestamp(), failures)
+			failures, retry = 0, nil
+		}
+		w.reportChanges(current, next, counts)
+		if w.FailOnRemoval {
+			if err := lost(current, next); err != nil {
+				return err
+			}
+		}
+		current = next
+		if err := w.saveState(current); err != nil {
+			return err
+		}
+		if interval && w.Traffic != nil {
+			w.reportTraffic(measured, next)
+			measured = next
+		}
+		return nil
+	}
+	// done reports whether MaxEvents has been reached, saying so.
+	done := func() bool {
+		if w.MaxEvents <= 0 || events < w.MaxEvents {
+			return false
+		}
+		w.Messages.Fprintf(w.Writer, "[%s] monitoring stopped after %d changes\n", w.timestamp(), events)
+		return true
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-ticker.C:
-			next, err := w.collect()
-			if err != nil {
+		case <-deadline:
+			w.Messages.Fprintf(w.Writer, "[%s] monitoring stopped after %s\n", w.timestamp(), w.Duration)
+			return nil
+		case <-ticks:
+			if retry != nil {
+				continue
+			}
+			if err := refresh(true); err != nil {
+				return err
+			}
+			if done() {
+				return nil
+			}
+		case _, ok := <-w.Changes:
+			if !ok {
+				return errors.New("change notifications stopped")
+			}
+			if retry != nil {
+				continue
+			}
+			if err := refresh(false); err != nil {
+				return err
+			}
+			if done() {
+				return nil
+			}
+		case <-retry:
+			retry = nil
+			if err := refresh(false); err != nil {
+				return err
+			}
+			if done() {
+				return nil
+			}
+		case <-probes:
+			if claims, err = w.probe(current, claims, counts); err != nil {
+				return err
+			}
+			if done() {
+				return nil
+			}
+		case <-gatewayChecks:
+			if reachable, err = w.checkGateways(current, reachable, counts); err != nil {
 				return err
 			}
-			w.reportChanges(current, next)
-			current = next
+			if done() {
+				return nil
+			}
+		case <-heartbeats:
+			w.heartbeat(current)
+		case <-summaries:
+			w.printSummary(counts)
+			counts = newTally()
 		}
 	}
 }
 
+// retryPolicy spaces out the retries of a refresh that could not collect
+// the state, doubling the delay with each failure in a row. It is a
+// variable so that tests can shorten it.
+var retryPolicy = backoff.Policy{Backoff: time.Second, MaxBackoff: time.Minute}
+
+// busiestShown is how many interfaces a summary names.
+const busiestShown = 3
+
+// tally counts the changes seen during one summary period.
+type tally struct {
+	links     int
+	addresses int
+	routes    int
+	neighbors int
+	perName   map[string]int
+}
+
+func newTally() *tally {
+	return &tally{perName: make(map[string]int)}
+}
+
+func (t *tally) link(name string) {
+	t.links++
+	t.perName[name]++
+}
+
+func (t *tally) address(name string, n int) {
+	t.addresses += n
+	t.perName[name] += n
+}
+
+func (t *tally) route(name string) {
+	t.routes++
+	t.perName[name]++
+}
+
+func (t *tally) neighbor(name string) {
+	t.neighbors++
+	t.perName[name]++
+}
+
+// busiest returns up to limit interface names ordered by change count.
+func (t *tally) busiest(limit int) []string {
+	names := make([]string, 0, len(t.perName))
+	for name := range t.perName {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if t.perName[names[i]] != t.perName[names[j]] {
+			return t.perName[names[i]] > t.perName[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > limit {
+		names = names[:limit]
+	}
+	for i, name := range names {
+		names[i] = fmt.Sprintf("%s (%d)", name, t.perName[name])
+	}
+	return names
+}
+
+func (w Watcher) printSummary(t *tally) {
+	if t.links == 0 && t.addresses == 0 && t.routes == 0 && t.neighbors == 0 {
+		w.Messages.Fprintf(w.Writer, "[%s] summary for the last %s: no changes\n", w.timestamp(), w.SummaryEvery)
+		return
+	}
+	counts := []string{
+		w.plural(t.links, "1 link change", "%d link changes"),
+		w.plural(t.addresses, "1 address change", "%d address changes"),
+	}
+	if w.Routes != nil {
+		counts = append(counts, w.plural(t.routes, "1 route change", "%d route changes"))
+	}
+	if w.Neighbors != nil {
+		counts = append(counts, w.plural(t.neighbors, "1 neighbor change", "%d neighbor changes"))
+	}
+	w.Messages.Fprintf(w.Writer, "[%s] summary for the last %s: %s; busiest: %s\n", w.timestamp(), w.SummaryEvery,
+		strings.Join(counts, w.Messages.Sprintf(", ")), strings.Join(t.busiest(busiestShown), ", "))
+}
+
+func (w Watcher) plural(n int, one, many string) string {
+	if n == 1 {
+		return w.Messages.Sprintf(one)
+	}
+	return w.Messages.Sprintf(many, n)
+}
+
 func (w Watcher) collect() (snapshot, error) {
 	list, err := w.Lister.List()
 	if err != nil {
 		return snapshot{}, err
 	}
 	snap := snapshot{
-		interfaces: make(map[string]interfaces.Interface),
-		addresses:  make(map[string][]string),
+		Snapshot: netevent.Snapshot{
+			Taken:      w.now(),
+			Interfaces: make(map[string]interfaces.Interface),
+			Addresses:  make(map[string][]string),
+			Routes:     make(map[string][]routes.Route),
+			Neighbors:  make(map[string][]neighbors.Neighbor),
+		},
+		traffic:   make(map[string]counters.Sample),
+		downSince: make(map[string]time.Time),
 	}
 	for _, iface := range list {
-		if w.Interface != "" && iface.Name != w.Interface {
+		if !w.watches(iface.Name) {
 			continue
 		}
-		snap.interfaces[iface.Name] = iface
-		addrs, err := w.Viewer.View(iface.Name)
+		snap.Interfaces[iface.Name] = iface
+		if w.wants(netevent.Address) {
+			addrs, err := w.Viewer.View(iface.Name)
+			if err != nil {
+				return snapshot{}, err
+			}
+			sort.Strings(addrs)
+			snap.Addresses[iface.Name] = addrs
+		}
+		if w.Routes != nil {
+			if snap.Routes[iface.Name], err = w.Routes.View(iface.Name); err != nil {
+				return snapshot{}, err
+			}
+		}
+		if w.Neighbors != nil {
+			list, err := w.Neighbors.View(iface.Name)
+			if err != nil {
+				return snapshot{}, err
+			}
+			snap.Neighbors[iface.Name] = w.watchedNeighbors(list)
+		}
+	}
+	if w.Traffic != nil {
+		samples, err := counters.Collect(w.Traffic)
 		if err != nil {
 			return snapshot{}, err
 		}
-		sort.Strings(addrs)
-		snap.addresses[iface.Name] = addrs
+		for _, sample := range samples {
+			if _, ok := snap.Interfaces[sample.Interface]; ok {
+				snap.traffic[sample.Interface] = sample
+			}
+		}
 	}
-	if w.Interface != "" {
-		if _, ok := snap.interfaces[w.Interface]; !ok {
-			snap.addresses[w.Interface] = nil
+	if w.namedInterfaces() {
+		for _, name := range w.Interfaces {
+			if _, ok := snap.Interfaces[name]; !ok {
+				snap.Addresses[name] = nil
+			}
 		}
 	}
 	return snap, nil
 }
 
-func (w Watcher) printInitial(snap snapshot) {
-	fmt.Fprintf(w.Writer, "[%s] monitoring started (interval %s)\n", w.timestamp(), w.Interval)
-	if w.Interface != "" {
-		fmt.Fprintf(w.Writer, " - filter: %s\n", w.Interface)
+// watches reports whether the interface name passes Interfaces and
+// InterfaceRegex.
+func (w Watcher) watches(name string) bool {
+	if len(w.Interfaces) > 0 && !slices.ContainsFunc(w.Interfaces, func(pattern string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}) {
+		return false
+	}
+	return w.InterfaceRegex == nil || w.InterfaceRegex.MatchString(name)
+}
+
+// wants reports whether Kinds lets through changes of kind.
+func (w Watcher) wants(kind string) bool {
+	return len(w.Kinds) == 0 || slices.Contains(w.Kinds, kind)
+}
+
+// namedInterfaces reports whether the filter is a set of exact names.
+func (w Watcher) namedInterfaces() bool {
+	return len(w.Interfaces) > 0 && w.InterfaceRegex == nil && !slices.ContainsFunc(w.Interfaces, func(pattern string) bool {
+		return strings.ContainsAny(pattern, `*?[\`)
+	})
+}
+
+// printHeader says how monitoring started and what it watches.
+func (w Watcher) printHeader() {
+	if w.Changes != nil {
+		w.Messages.Fprintf(w.Writer, "[%s] monitoring started (following netlink notifications)\n", w.timestamp())
+	} else {
+		w.Messages.Fprintf(w.Writer, "[%s] monitoring started (interval %s)\n", w.timestamp(), w.Interval)
+	}
+	if len(w.Interfaces) > 0 {
+		w.Messages.Fprintf(w.Writer, " - filter: %s\n", strings.Join(w.Interfaces, ", "))
 	}
-	if len(snap.interfaces) == 0 {
-		if w.Interface == "" {
-			fmt.Fprintln(w.Writer, "No interfaces detected yet")
-		} else {
-			fmt.Fprintf(w.Writer, "Waiting for %s to appear...\n", w.Interface)
+	if w.InterfaceRegex != nil {
+		w.Messages.Fprintf(w.Writer, " - filter: names matching %s\n", w.InterfaceRegex)
+	}
+	if len(w.Kinds) > 0 {
+		w.Messages.Fprintf(w.Writer, " - events: %s\n", strings.Join(w.Kinds, ", "))
+	}
+	if w.Traffic != nil {
+		w.Messages.Fprintf(w.Writer, " - traffic every %s\n", w.Interval)
+	}
+}
+
+// printInitial prints the header and the whole state.
+func (w Watcher) printInitial(snap snapshot) {
+	w.printHeader()
+	if len(snap.Interfaces) == 0 {
+		switch {
+		case w.namedInterfaces():
+			w.Messages.Fprintf(w.Writer, "Waiting for %s to appear...\n", strings.Join(w.Interfaces, ", "))
+		case len(w.Interfaces) > 0 || w.InterfaceRegex != nil:
+			w.Messages.Fprintf(w.Writer, "Waiting for matching interfaces to appear...\n")
+		default:
+			w.Messages.Fprintf(w.Writer, "No interfaces detected yet\n")
 		}
 		return
 	}
-	names := make([]string, 0, len(snap.interfaces))
-	for name := range snap.interfaces {
+	names := make([]string, 0, len(snap.Interfaces))
+	for name := range snap.Interfaces {
 		names = append(names, name)
 	}
 	sort.Strings(names)
 	for _, name := range names {
-		iface := snap.interfaces[name]
+		iface := snap.Interfaces[name]
 		fmt.Fprintf(w.Writer, " - %s (MTU=%d, HW=%s)\n", iface.Name, iface.MTU, iface.HardwareAddr)
-		addrs := snap.addresses[name]
-		if len(addrs) == 0 {
-			fmt.Fprintf(w.Writer, "   addresses: none\n")
-			continue
+		switch addrs := snap.Addresses[name]; {
+		case !w.wants(netevent.Address):
+		case len(addrs) == 0:
+			w.Messages.Fprintf(w.Writer, "   addresses: none\n")
+		default:
+			w.Messages.Fprintf(w.Writer, "   addresses: %s\n", strings.Join(addrs, ", "))
 		}
-		fmt.Fprintf(w.Writer, "   addresses: %s\n", strings.Join(addrs, ", "))
+		switch list := snap.Routes[name]; {
+		case w.Routes == nil:
+		case len(list) == 0:
+			w.Messages.Fprintf(w.Writer, "   routes: none\n")
+		default:
+			described := make([]string, len(list))
+			for i, route := range list {
+				described[i] = route.String()
+			}
+			w.Messages.Fprintf(w.Writer, "   routes: %s\n", strings.Join(described, ", "))
+		}
+		switch list := snap.Neighbors[name]; {
+		case w.Neighbors == nil:
+		case len(list) == 0:
+			w.Messages.Fprintf(w.Writer, "   neighbors: none\n")
+		default:
+			described := make([]string, len(list))
+			for i, neigh := range list {
+				described[i] = neigh.String()
+			}
+			w.Messages.Fprintf(w.Writer, "   neighbors: %s\n", strings.Join(described, ", "))
+		}
+	}
+}
+
+// watchedNeighbors keeps the neighbors of list that NeighborIPs selects.
+func (w Watcher) watchedNeighbors(list []neighbors.Neighbor) []neighbors.Neighbor {
+	if len(w.NeighborIPs) == 0 {
+		return list
+	}
+	var kept []neighbors.Neighbor
+	for _, neigh := range list {
+		ip := net.ParseIP(neigh.IP)
+		if slices.ContainsFunc(w.NeighborIPs, func(watched string) bool { return net.ParseIP(watched).Equal(ip) }) {
+			kept = append(kept, neigh)
+		}
+	}
+	return kept
+}
+
+// reportChanges reports what changed from prev to curr, of the kinds
+// wanted.
+func (w Watcher) reportChanges(prev, curr snapshot, counts *tally) {
+	if w.wants(netevent.Link) {
+		w.reportLinks(prev, curr, counts)
+	}
+	if w.wants(netevent.Address) {
+		w.reportAddresses(prev, curr, counts)
+	}
+	if w.wants(netevent.Route) {
+		w.reportRoutes(prev, curr, counts)
+	}
+	if w.wants(netevent.Neighbor) {
+		w.reportNeighbors(prev.Neighbors, curr.Neighbors, counts)
 	}
 }
 
-func (w Watcher) reportChanges(prev, curr snapshot) {
-	added, removed, updated := diffInterfaces(prev.interfaces, curr.interfaces)
+// reportLinks reports the interfaces that were added, removed or updated,
+// and the links that went down or came back up.
+func (w Watcher) reportLinks(prev, curr snapshot, counts *tally) {
+	added, removed, updated := netevent.DiffInterfaces(prev.Interfaces, curr.Interfaces)
 	for _, iface := range added {
-		fmt.Fprintf(w.Writer, "[%s] interface %s added (MTU=%d, HW=%s)\n", w.timestamp(), iface.Name, iface.MTU, iface.HardwareAddr)
+		counts.link(iface.Name)
+		w.printChange(netevent.Event{Kind: netevent.Link, Type: "interface_added", Interface: iface.Name, New: linkState(iface)},
+			"interface %s added (MTU=%d, HW=%s)", iface.MTU, iface.HardwareAddr)
 	}
 	for _, iface := range removed {
-		fmt.Fprintf(w.Writer, "[%s] interface %s removed\n", w.timestamp(), iface.Name)
+		counts.link(iface.Name)
+		w.printChange(netevent.Event{Kind: netevent.Link, Type: "interface_removed", Interface: iface.Name, Old: linkState(iface)}, "interface %s removed")
 	}
 	for _, change := range updated {
+		counts.link(change.Name)
 		diffs := describeInterfaceChange(change.Before, change.After)
-		fmt.Fprintf(w.Writer, "[%s] interface %s updated: %s\n", w.timestamp(), change.Name, strings.Join(diffs, ", "))
+		w.printChange(netevent.Event{Kind: netevent.Link, Type: "interface_updated", Interface: change.Name, Old: linkState(change.Before), New: linkState(change.After)},
+			"interface %s updated: %s", strings.Join(diffs, ", "))
 	}
-	for _, change := range diffAddresses(prev.addresses, curr.addresses) {
+	w.reportLinkState(prev, curr)
+}
+
+// reportAddresses reports the addresses added to and removed from each
+// interface.
+func (w Watcher) reportAddresses(prev, curr snapshot, counts *tally) {
+	for _, change := range netevent.DiffAddresses(prev.Addresses, curr.Addresses) {
+		counts.address(change.Name, len(change.Added)+len(change.Removed))
 		if len(change.Added) > 0 {
-			fmt.Fprintf(w.Writer, "[%s] %s addresses added: %s\n", w.timestamp(), change.Name, strings.Join(change.Added, ", "))
+			added := strings.Join(change.Added, ", ")
+			w.printChange(netevent.Event{Kind: netevent.Address, Type: "address_added", Interface: change.Name, New: added}, "%s addresses added: %s", added)
 		}
 		if len(change.Removed) > 0 {
-			fmt.Fprintf(w.Writer, "[%s] %s addresses removed: %s\n", w.timestamp(), change.Name, strings.Join(change.Removed, ", "))
+			removed := strings.Join(change.Removed, ", ")
+			w.printChange(netevent.Event{Kind: netevent.Address, Type: "address_removed", Interface: change.Name, Old: removed}, "%s addresses removed: %s", removed)
 		}
 	}
+	w.reportDuplicates(prev, curr, counts)
 }
 
-func (w Watcher) timestamp() string {
-	now := w.Now
-	if now == nil {
-		now = time.Now
+// reportDuplicates reports the addresses that came to be configured on more
+// than one interface between prev and curr.
+func (w Watcher) reportDuplicates(prev, curr snapshot, counts *tally) {
+	before := duplicateAddresses(prev.Addresses)
+	for _, dup := range duplicateAddresses(curr.Addresses) {
+		if slices.ContainsFunc(before, func(old duplicate) bool { return old.IP == dup.IP && equalStrings(old.Interfaces, dup.Interfaces) }) {
+			continue
+		}
+		name, others := dup.Interfaces[0], strings.Join(dup.Interfaces[1:], ", ")
+		counts.address(name, 1)
+		w.printChange(netevent.Event{Kind: netevent.Address, Type: "address_conflict", Interface: name, New: dup.IP},
+			"%s address %s conflicts: also configured on %s", dup.IP, others)
 	}
-	return now().Format(time.RFC3339)
 }
 
-type interfaceChange struct {
-	Name   string
-	Before interfaces.Interface
-	After  interfaces.Interface
+// checkGateways asks Gateways whether the default gateways of the running
+// interfaces of snap answer, reports those that stopped or started
+// answering since reachable was recorded, and those not answering on first
+// sight, and returns which answered.
+func (w Watcher) checkGateways(snap snapshot, reachable map[string]bool, counts *tally) (map[string]bool, error) {
+	names := make([]string, 0, len(snap.Interfaces))
+	for name, iface := range snap.Interfaces {
+		if iface.Running() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	next := make(map[string]bool)
+	for _, name := range names {
+		for _, gateway := range defaultGateways(snap.Routes[name]) {
+			ok, err := w.Gateways.Reachable(name, gateway)
+			if err != nil {
+				return nil, err
+			}
+			key := name + " " + gateway.String()
+			was, known := reachable[key]
+			next[key] = ok
+			switch {
+			case !ok && (!known || was):
+				counts.route(name)
+				w.printChange(netevent.Event{Kind: netevent.Route, Type: "gateway_unreachable", Interface: name, New: gateway.String()}, "%s gateway %s is not answering", gateway)
+			case ok && known && !was:
+				counts.route(name)
+				w.printChange(netevent.Event{Kind: netevent.Route, Type: "gateway_reachable", Interface: name, New: gateway.String()}, "%s gateway %s is answering again", gateway)
+			}
+		}
+	}
+	return next, nil
+}
+
+// defaultGateways returns the IPv4 gateways of the default routes of list,
+// the next hops of multipath ones included.
+func defaultGateways(list []routes.Route) []net.IP {
+	var gateways []net.IP
+	for _, route := range list {
+		if !strings.HasPrefix(route.Key, "default") {
+			continue
+		}
+		words := strings.Fields(route.Via)
+		for i := 0; i+1 < len(words); i++ {
+			if words[i] != "via" {
+				continue
+			}
+			ip := net.ParseIP(words[i+1]).To4()
+			if ip != nil && !slices.ContainsFunc(gateways, ip.Equal) {
+				gateways = append(gateways, ip)
+			}
+		}
+	}
+	return gateways
+}
+
+// duplicate is an address configured on more than one interface.
+type duplicate struct {
+	IP         string
+	Interfaces []string
+}
+
+// duplicateAddresses returns the addresses of addrs that more than one
+// interface holds, by address. Link-local addresses are meant to repeat
+// across links and are left out.
+func duplicateAddresses(addrs map[string][]string) []duplicate {
+	holders := make(map[string][]string)
+	for name, list := range addrs {
+		for _, addr := range list {
+			ip, _, err := net.ParseCIDR(addr)
+			if err != nil || ip.IsLinkLocalUnicast() {
+				continue
+			}
+			if held := holders[ip.String()]; !slices.Contains(held, name) {
+				holders[ip.String()] = append(held, name)
+			}
+		}
+	}
+	var dups []duplicate
+	for ip, names := range holders {
+		if len(names) > 1 {
+			sort.Strings(names)
+			dups = append(dups, duplicate{IP: ip, Interfaces: names})
+		}
+	}
+	sort.Slice(dups, func(i, j int) bool { return dups[i].IP < dups[j].IP })
+	return dups
+}
+
+// probe asks Prober whether other hosts use the IPv4 addresses of the
+// running interfaces of snap, reports the conflicts that are not among
+// claims yet, and returns the conflicts found.
+func (w Watcher) probe(snap snapshot, claims map[string]bool, counts *tally) (map[string]bool, error) {
+	ips := make(map[string][]net.IP)
+	for name, list := range snap.Addresses {
+		if iface, ok := snap.Interfaces[name]; !ok || !iface.Running() {
+			continue
+		}
+		for _, addr := range list {
+			if ip, _, err := net.ParseCIDR(addr); err == nil && ip.To4() != nil {
+				ips[name] = append(ips[name], ip)
+			}
+		}
+	}
+	if len(ips) == 0 {
+		return map[string]bool{}, nil
+	}
+	found, err := w.Prober.Probe(ips)
+	if err != nil {
+		return nil, err
+	}
+	next := make(map[string]bool, len(found))
+	for _, claim := range found {
+		key := claim.Device + " " + claim.IP.String() + " " + claim.HardwareAddr.String()
+		next[key] = true
+		if claims[key] {
+			continue
+		}
+		counts.address(claim.Device, 1)
+		w.printChange(netevent.Event{Kind: netevent.Address, Type: "address_conflict", Interface: claim.Device, New: claim.IP.String() + " lladdr " + claim.HardwareAddr.String()},
+			"%s address %s conflicts: %s answers for it too", claim.IP, claim.HardwareAddr)
+	}
+	return next, nil
 }
 
-func diffInterfaces(prev, curr map[string]interfaces.Interface) (added, removed []interfaces.Interface, updated []interfaceChange) {
-	for name, iface := range curr {
-		p, ok := prev[name]
+// reportRoutes reports the routes added, removed or changed.
+func (w Watcher) reportRoutes(prev, curr snapshot, counts *tally) {
+	for _, change := range diffRoutes(prev.Routes, curr.Routes) {
+		counts.route(change.Name)
+		switch {
+		case change.Before == nil:
+			w.printChange(netevent.Event{Kind: netevent.Route, Type: "route_added", Interface: change.Name, New: change.After.String()}, "%s route added: %s", change.After)
+		case change.After == nil:
+			w.printChange(netevent.Event{Kind: netevent.Route, Type: "route_removed", Interface: change.Name, Old: change.Before.String()}, "%s route removed: %s", change.Before)
+		default:
+			w.printChange(netevent.Event{Kind: netevent.Route, Type: "route_changed", Interface: change.Name, Old: change.Before.String(), New: change.After.String()},
+				"%s route changed: %s → %s", change.Before, change.After)
+		}
+	}
+}
+
+// downtimePrecision is what the downtime of a link is rounded to.
+const downtimePrecision = time.Millisecond
+
+// reportLinkState reports the links that went down or came back up, which
+// the interface changes only hint at through their flags, and carries
+// forward since when each link that is down has been down. Links seen down
+// from the start have no known downtime.
+func (w Watcher) reportLinkState(prev, curr snapshot) {
+	names := make([]string, 0, len(curr.Interfaces))
+	for name := range curr.Interfaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		iface := curr.Interfaces[name]
+		before, known := prev.Interfaces[name]
+		since, wasDown := prev.downSince[name]
+		switch {
+		case iface.Running():
+			if !known || before.Running() {
+				continue
+			}
+			event := netevent.Event{Kind: netevent.Link, Type: "link_up", Interface: name, Old: before.OperState, New: iface.OperState}
+			if wasDown {
+				w.printChange(event, "%s link came up after %s down", w.now().Sub(since).Round(downtimePrecision))
+			} else {
+				w.printChange(event, "%s link came up")
+			}
+		case known && before.Running():
+			curr.downSince[name] = w.now()
+			w.printChange(netevent.Event{Kind: netevent.Link, Type: "link_down", Interface: name, Old: before.OperState, New: iface.OperState},
+				"%s link went down (%s)", w.downReason(iface))
+		case wasDown:
+			curr.downSince[name] = since
+		}
+	}
+}
+
+// lost returns ErrInterfaceLost for the first interface of prev that is
+// missing from curr or has lost its carrier there.
+func lost(prev, curr snapshot) error {
+	names := make([]string, 0, len(prev.Interfaces))
+	for name := range prev.Interfaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		iface, ok := curr.Interfaces[name]
+		switch {
+		case !ok:
+			return fmt.Errorf("%w: %s was removed", ErrInterfaceLost, name)
+		case prev.Interfaces[name].Carrier && !iface.Carrier:
+			return fmt.Errorf("%w: %s lost its carrier", ErrInterfaceLost, name)
+		}
+	}
+	return nil
+}
+
+// downReason says why iface is not running, most fundamental cause first.
+func (w Watcher) downReason(iface interfaces.Interface) string {
+	switch {
+	case !slices.Contains(iface.Flags, "up"):
+		return w.Messages.Sprintf("administratively down")
+	case !iface.Carrier:
+		return w.Messages.Sprintf("no carrier")
+	}
+	return w.Messages.Sprintf("operational state %s", iface.OperState)
+}
+
+// reportTraffic reports the rates of the interfaces that passed traffic
+// between prev and curr, and the errors and drops they counted. Idle
+// interfaces are left out.
+func (w Watcher) reportTraffic(prev, curr snapshot) {
+	elapsed := curr.Taken.Sub(prev.Taken)
+	if elapsed <= 0 {
+		return
+	}
+	names := make([]string, 0, len(curr.traffic))
+	for name := range curr.traffic {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		before, ok := prev.traffic[name]
+		if !ok {
+			continue
+		}
+		delta, ok := curr.traffic[name].Sub(before)
 		if !ok {
-			added = append(added, iface)
+			w.printChange(netevent.Event{Kind: netevent.Traffic, Type: "counters_reset", Interface: name}, "%s traffic counters were reset")
+			continue
+		}
+		if delta.Idle() {
 			continue
 		}
-		if !sameInterface(p, iface) {
-			updated = append(updated, interfaceChange{Name: name, Before: p, After: iface})
+		w.printLine(output.Unchanged, name, "%s rx %s (%s pkt/s), tx %s (%s pkt/s)",
+			counters.BitRate(delta.Value(counters.RxBytes), elapsed), counters.PacketRate(delta.Value(counters.RxPackets), elapsed),
+			counters.BitRate(delta.Value(counters.TxBytes), elapsed), counters.PacketRate(delta.Value(counters.TxPackets), elapsed))
+		rxErrors, txErrors := delta.Value(counters.RxErrors), delta.Value(counters.TxErrors)
+		rxDropped, txDropped := delta.Value(counters.RxDropped), delta.Value(counters.TxDropped)
+		if rxErrors+txErrors+rxDropped+txDropped > 0 {
+			w.printChange(netevent.Event{Kind: netevent.Traffic, Type: "errors", Interface: name},
+				"%s errors rx +%d tx +%d, drops rx +%d tx +%d", rxErrors, txErrors, rxDropped, txDropped)
 		}
 	}
-	for name, iface := range prev {
+}
+
+// reportNeighbors reports the neighbor entries that appeared, went away,
+// changed their link-layer address, or became stale or failed. Other state
+// changes, such as confirming a stale entry again, are routine and left out.
+func (w Watcher) reportNeighbors(prev, curr map[string][]neighbors.Neighbor, counts *tally) {
+	names := make([]string, 0, len(curr))
+	for name := range curr {
+		names = append(names, name)
+	}
+	for name := range prev {
 		if _, ok := curr[name]; !ok {
-			removed = append(removed, iface)
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		before := make(map[string]neighbors.Neighbor, len(prev[name]))
+		for _, neigh := range prev[name] {
+			before[neigh.IP] = neigh
+		}
+		after := make(map[string]bool, len(curr[name]))
+		for _, neigh := range curr[name] {
+			after[neigh.IP] = true
+			old, ok := before[neigh.IP]
+			if !ok {
+				counts.neighbor(name)
+				w.printChange(netevent.Event{Kind: netevent.Neighbor, Type: "neighbor_appeared", Interface: name, New: neigh.String()}, "%s neighbor appeared: %s", neigh)
+				continue
+			}
+			if old.MAC != "" && neigh.MAC != "" && old.MAC != neigh.MAC {
+				counts.neighbor(name)
+				w.printChange(netevent.Event{Kind: netevent.Neighbor, Type: "neighbor_moved", Interface: name, Old: old.String(), New: neigh.String()},
+					"%s neighbor %s moved: lladdr %s → %s", neigh.IP, old.MAC, neigh.MAC)
+			}
+			switch {
+			case entered(old, neigh, "stale"):
+				counts.neighbor(name)
+				w.printChange(netevent.Event{Kind: netevent.Neighbor, Type: "neighbor_stale", Interface: name, Old: old.String(), New: neigh.String()}, "%s neighbor %s is stale", neigh.IP)
+			case entered(old, neigh, "failed"):
+				counts.neighbor(name)
+				w.printChange(netevent.Event{Kind: netevent.Neighbor, Type: "neighbor_failed", Interface: name, Old: old.String(), New: neigh.String()}, "%s neighbor %s failed", neigh.IP)
+			}
+		}
+		for _, neigh := range prev[name] {
+			if !after[neigh.IP] {
+				counts.neighbor(name)
+				w.printChange(netevent.Event{Kind: netevent.Neighbor, Type: "neighbor_removed", Interface: name, Old: neigh.String()}, "%s neighbor %s removed", neigh.IP)
+			}
 		}
 	}
-	sort.Slice(added, func(i, j int) bool { return added[i].Name < added[j].Name })
-	sort.Slice(removed, func(i, j int) bool { return removed[i].Name < removed[j].Name })
-	sort.Slice(updated, func(i, j int) bool { return updated[i].Name < updated[j].Name })
-	return added, removed, updated
 }
 
-func sameInterface(a, b interfaces.Interface) bool {
-	if a.Name != b.Name || a.HardwareAddr != b.HardwareAddr || a.MTU != b.MTU {
-		return false
+// entered reports whether a neighbor is in state now but was not before.
+func entered(before, after neighbors.Neighbor, state string) bool {
+	has := func(n neighbors.Neighbor) bool { return slices.Contains(strings.Split(n.State, ","), state) }
+	return has(after) && !has(before)
+}
+
+// heartbeat tells Sinks that monitoring goes on, watching snap.
+func (w Watcher) heartbeat(snap snapshot) {
+	event := netevent.Event{
+		Time:    w.now(),
+		Kind:    netevent.Heartbeat,
+		Type:    netevent.Heartbeat,
+		Message: w.plural(len(snap.Interfaces), "heartbeat: watching 1 interface", "heartbeat: watching %d interfaces"),
 	}
-	if len(a.Flags) != len(b.Flags) {
-		return false
+	for _, sink := range w.Sinks {
+		sink.Send(event)
 	}
-	for i := range a.Flags {
-		if a.Flags[i] != b.Flags[i] {
-			return false
-		}
+}
+
+// printChange writes event as a timestamped line unless only summaries are
+// wanted, and sends it to Sinks with its time and message filled in. format
+// starts with the verb for the interface of event, which args leave out.
+func (w Watcher) printChange(event netevent.Event, format string, args ...interface{}) {
+	event.Message = w.printLine(eventChange(event.Type), event.Interface, format, args...)
+	if len(w.Sinks) == 0 {
+		return
 	}
-	return true
+	event.Time = w.now()
+	for _, sink := range w.Sinks {
+		sink.Send(event)
+	}
+}
+
+// printLine writes a timestamped line about the interface name, in the
+// color of change, unless only summaries are wanted, and returns it without
+// the timestamp or color.
+func (w Watcher) printLine(change output.Change, name, format string, args ...interface{}) string {
+	line := w.Messages.Sprintf(format, append([]interface{}{name}, args...)...)
+	if !w.SummaryOnly {
+		fmt.Fprintf(w.Writer, "[%s] %s\n", w.timestamp(), w.Colors.Paint(change, line))
+	}
+	return line
+}
+
+// eventChange tells apart the events that add, remove or update something.
+func eventChange(typ string) output.Change {
+	switch {
+	case strings.HasSuffix(typ, "_added"), typ == "link_up", typ == "neighbor_appeared", typ == "gateway_reachable":
+		return output.Added
+	case strings.HasSuffix(typ, "_removed"), typ == "link_down", typ == "neighbor_failed", typ == "gateway_unreachable":
+		return output.Removed
+	}
+	return output.Updated
+}
+
+func (w Watcher) timestamp() string {
+	return w.now().Format(time.RFC3339)
+}
+
+func (w Watcher) now() time.Time {
+	if w.Now == nil {
+		return time.Now()
+	}
+	return w.Now()
 }
 
 func describeInterfaceChange(before, after interfaces.Interface) []string {
@@ -210,65 +1134,106 @@ func describeInterfaceChange(before, after interfaces.Interface) []string {
 	if !equalStrings(before.Flags, after.Flags) {
 		changes = append(changes, fmt.Sprintf("flags [%s]→[%s]", strings.Join(before.Flags, ","), strings.Join(after.Flags, ",")))
 	}
+	if before.OperState != after.OperState {
+		changes = append(changes, fmt.Sprintf("operstate %s→%s", before.OperState, after.OperState))
+	}
+	if before.Carrier != after.Carrier {
+		changes = append(changes, fmt.Sprintf("carrier %s→%s", onOff(before.Carrier), onOff(after.Carrier)))
+	}
+	if before.Speed != after.Speed {
+		changes = append(changes, fmt.Sprintf("speed %s→%s", speed(before.Speed), speed(after.Speed)))
+	}
+	if before.Duplex != after.Duplex {
+		changes = append(changes, fmt.Sprintf("duplex %s→%s", duplex(before.Duplex), duplex(after.Duplex)))
+	}
 	if len(changes) == 0 {
 		changes = append(changes, "no visible field differences")
 	}
 	return changes
 }
 
-type addressChange struct {
-	Name    string
-	Added   []string
-	Removed []string
+// linkState describes iface for the Old and New of an event.
+func linkState(iface interfaces.Interface) string {
+	state := fmt.Sprintf("MTU=%d HW=%s flags=%s", iface.MTU, iface.HardwareAddr, strings.Join(iface.Flags, ","))
+	if iface.OperState != "" {
+		state += fmt.Sprintf(" operstate=%s carrier=%s", iface.OperState, onOff(iface.Carrier))
+	}
+	if iface.Speed > 0 || iface.Duplex != "" {
+		state += fmt.Sprintf(" speed=%s duplex=%s", speed(iface.Speed), duplex(iface.Duplex))
+	}
+	return state
 }
 
-func diffAddresses(prev, curr map[string][]string) []addressChange {
-	seen := make(map[string]struct{})
-	for name := range prev {
-		seen[name] = struct{}{}
+// speed spells a link speed in Mb/s as ethtool(8) does.
+func speed(mbps int) string {
+	if mbps <= 0 {
+		return "unknown"
 	}
-	for name := range curr {
-		seen[name] = struct{}{}
-	}
-	names := make([]string, 0, len(seen))
-	for name := range seen {
-		names = append(names, name)
+	return fmt.Sprintf("%dMb/s", mbps)
+}
+
+func duplex(mode string) string {
+	if mode == "" {
+		return "unknown"
 	}
-	sort.Strings(names)
+	return mode
+}
 
-	var changes []addressChange
-	for _, name := range names {
-		added, removed := diffStringSets(prev[name], curr[name])
-		if len(added) == 0 && len(removed) == 0 {
-			continue
-		}
-		changes = append(changes, addressChange{Name: name, Added: added, Removed: removed})
+func onOff(on bool) string {
+	if on {
+		return "on"
 	}
-	return changes
+	return "off"
 }
 
-func diffStringSets(old, new []string) (added, removed []string) {
-	oldSet := make(map[string]struct{}, len(old))
-	for _, val := range old {
-		oldSet[val] = struct{}{}
+// routeChange is a route of an interface that was added (no Before),
+// removed (no After) or now leads elsewhere.
+type routeChange struct {
+	Name   string
+	Before *routes.Route
+	After  *routes.Route
+}
+
+func diffRoutes(prev, curr map[string][]routes.Route) []routeChange {
+	names := make(map[string]struct{})
+	for name := range prev {
+		names[name] = struct{}{}
 	}
-	newSet := make(map[string]struct{}, len(new))
-	for _, val := range new {
-		newSet[val] = struct{}{}
+	for name := range curr {
+		names[name] = struct{}{}
 	}
-	for val := range newSet {
-		if _, ok := oldSet[val]; !ok {
-			added = append(added, val)
-		}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
 	}
-	for val := range oldSet {
-		if _, ok := newSet[val]; !ok {
-			removed = append(removed, val)
+	sort.Strings(sorted)
+
+	var changes []routeChange
+	for _, name := range sorted {
+		before := make(map[string]routes.Route, len(prev[name]))
+		for _, route := range prev[name] {
+			before[route.Key] = route
+		}
+		after := make(map[string]routes.Route, len(curr[name]))
+		for _, route := range curr[name] {
+			after[route.Key] = route
+		}
+		for _, route := range curr[name] {
+			old, ok := before[route.Key]
+			switch {
+			case !ok:
+				changes = append(changes, routeChange{Name: name, After: &route})
+			case old.Via != route.Via:
+				changes = append(changes, routeChange{Name: name, Before: &old, After: &route})
+			}
+		}
+		for _, route := range prev[name] {
+			if _, ok := after[route.Key]; !ok {
+				changes = append(changes, routeChange{Name: name, Before: &route})
+			}
 		}
 	}
-	sort.Strings(added)
-	sort.Strings(removed)
-	return added, removed
+	return changes
 }
 
 func equalStrings(a, b []string) bool {
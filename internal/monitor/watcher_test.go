@@ -4,12 +4,28 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/vishvananda/netlink"
+
 	"github.com/user/goeth/internal/addresses"
+	"github.com/user/goeth/internal/announce"
+	"github.com/user/goeth/internal/backoff"
+	"github.com/user/goeth/internal/counters"
+	"github.com/user/goeth/internal/i18n"
 	"github.com/user/goeth/internal/interfaces"
+	"github.com/user/goeth/internal/neighbors"
+	"github.com/user/goeth/internal/output"
+	"github.com/user/goeth/internal/routes"
+	"github.com/user/goeth/pkg/netevent"
 )
 
 type stubInterfaceProvider struct {
@@ -36,66 +52,886 @@ func (s stubAddressProvider) InterfaceAddresses(name string) ([]string, error) {
 	return append([]string(nil), s.addrs[name]...), nil
 }
 
-func TestDiffInterfacesDetectsChanges(t *testing.T) {
-	prev := map[string]interfaces.Interface{
-		"eth0": {Name: "eth0", HardwareAddr: "aa:bb", MTU: 1500},
-		"eth1": {Name: "eth1", HardwareAddr: "cc:dd", MTU: 1500, Flags: []string{"up"}},
+func TestWatcherReportsChanges(t *testing.T) {
+	writer := &bytes.Buffer{}
+	watcher := Watcher{
+		Lister:   interfaces.NewLister(stubInterfaceProvider{interfaces: []interfaces.Interface{{Name: "eth0", HardwareAddr: "aa:bb", MTU: 1500}}}),
+		Viewer:   addresses.NewViewer(stubAddressProvider{addrs: map[string][]string{"eth0": {"192.0.2.1/24"}}}),
+		Interval: time.Millisecond,
+		Writer:   writer,
+		Now: func() time.Time {
+			return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // immediately cancel to avoid ticker loop
+	if err := watcher.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+	out := writer.String()
+	if !strings.Contains(out, "monitoring started") {
+		t.Fatalf("expected initial message, got %q", out)
+	}
+}
+
+// changingInterfaceProvider lists interfaces that can change while a
+// watcher reads them.
+type changingInterfaceProvider struct {
+	mu      sync.Mutex
+	mtu     int
+	removed bool
+	// failures is how many of the next lists fail.
+	failures int
+}
+
+func (c *changingInterfaceProvider) ListInterfaces() ([]interfaces.Interface, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failures > 0 {
+		c.failures--
+		return nil, syscall.EINTR
 	}
-	curr := map[string]interfaces.Interface{
-		"eth0": {Name: "eth0", HardwareAddr: "aa:bb", MTU: 1400},
-		"eth2": {Name: "eth2", HardwareAddr: "ee:ff", MTU: 1500},
+	if c.removed {
+		return nil, nil
 	}
+	return []interfaces.Interface{{Name: "eth0", HardwareAddr: "aa:bb", MTU: c.mtu}}, nil
+}
+
+func (c *changingInterfaceProvider) setMTU(mtu int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mtu = mtu
+}
+
+func (c *changingInterfaceProvider) fail(times int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures = times
+}
 
-	added, removed, updated := diffInterfaces(prev, curr)
-	if len(added) != 1 || added[0].Name != "eth2" {
-		t.Fatalf("expected eth2 to be added, got %#v", added)
+func (c *changingInterfaceProvider) remove() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removed = true
+}
+
+func TestWatcherFollowsChanges(t *testing.T) {
+	writer := &bytes.Buffer{}
+	provider := &changingInterfaceProvider{mtu: 1500}
+	changes := make(chan struct{})
+	watcher := fixedWatcher(writer)
+	watcher.SummaryEvery = 0
+	watcher.Lister = interfaces.NewLister(provider)
+	watcher.Viewer = addresses.NewViewer(stubAddressProvider{})
+	watcher.Changes = changes
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(ctx) }()
+	// The first value is only received once the initial state is printed.
+	changes <- struct{}{}
+	provider.setMTU(9000)
+	changes <- struct{}{}
+	close(changes)
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "notifications stopped") {
+		t.Fatalf("expected Run() to stop with the notifications, got %v", err)
 	}
-	if len(removed) != 1 || removed[0].Name != "eth1" {
-		t.Fatalf("expected eth1 to be removed, got %#v", removed)
+	out := writer.String()
+	if !strings.Contains(out, "monitoring started (following netlink notifications)") {
+		t.Fatalf("expected initial message, got %q", out)
 	}
-	if len(updated) != 1 || updated[0].Name != "eth0" {
-		t.Fatalf("expected eth0 update, got %#v", updated)
+	if !strings.Contains(out, "interface eth0 updated: MTU") {
+		t.Fatalf("expected the change to be reported without an interval, got %q", out)
 	}
 }
 
-func TestDiffAddressesDetectsChanges(t *testing.T) {
-	prev := map[string][]string{
-		"eth0": {"192.0.2.1/24", "2001:db8::1/64"},
-		"eth1": {"192.0.2.2/24"},
+func fixedWatcher(writer *bytes.Buffer) Watcher {
+	return Watcher{
+		Writer:       writer,
+		SummaryEvery: 5 * time.Minute,
+		Now: func() time.Time {
+			return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		},
+	}
+}
+
+func TestWatcherSummarizesChanges(t *testing.T) {
+	writer := &bytes.Buffer{}
+	watcher := fixedWatcher(writer)
+	prev := snapshot{
+		Snapshot: netevent.Snapshot{
+			Interfaces: map[string]interfaces.Interface{"eth0": {Name: "eth0", MTU: 1500}, "eth1": {Name: "eth1", MTU: 1500}},
+			Addresses:  map[string][]string{"eth0": {"192.0.2.1/24"}, "eth1": nil},
+		},
+	}
+	curr := snapshot{
+		Snapshot: netevent.Snapshot{
+			Interfaces: map[string]interfaces.Interface{"eth0": {Name: "eth0", MTU: 1400}, "eth1": {Name: "eth1", MTU: 1500}},
+			Addresses:  map[string][]string{"eth0": {"192.0.2.2/24"}, "eth1": {"198.51.100.1/24"}},
+		},
+	}
+	counts := newTally()
+	watcher.reportChanges(prev, curr, counts)
+	watcher.printSummary(counts)
+	want := "[2024-01-01T00:00:00Z] summary for the last 5m0s: 1 link change, 3 address changes; busiest: eth0 (3), eth1 (1)\n"
+	if !strings.HasSuffix(writer.String(), want) {
+		t.Fatalf("unexpected summary:\n%s", writer.String())
 	}
-	curr := map[string][]string{
-		"eth0": {"192.0.2.1/24", "198.51.100.1/24"},
-		"eth2": {"203.0.113.5/24"},
+	if !strings.Contains(writer.String(), "interface eth0 updated") {
+		t.Fatalf("expected per-change lines as well, got %q", writer.String())
 	}
+}
 
-	changes := diffAddresses(prev, curr)
-	if len(changes) != 3 {
-		t.Fatalf("expected 3 changes, got %d", len(changes))
+func TestWatcherLocalizesMessages(t *testing.T) {
+	writer := &bytes.Buffer{}
+	watcher := fixedWatcher(writer)
+	watcher.Messages, _ = i18n.New("ja")
+	prev := snapshot{Snapshot: netevent.Snapshot{Interfaces: map[string]interfaces.Interface{}, Addresses: map[string][]string{}}}
+	curr := snapshot{
+		Snapshot: netevent.Snapshot{
+			Interfaces: map[string]interfaces.Interface{"eth0": {Name: "eth0", MTU: 1500}},
+			Addresses:  map[string][]string{"eth0": nil},
+		},
+	}
+	counts := newTally()
+	watcher.reportChanges(prev, curr, counts)
+	watcher.printSummary(counts)
+	out := writer.String()
+	if !strings.Contains(out, "インターフェース eth0 が追加されました (MTU=1500, HW=)") {
+		t.Fatalf("expected a localized change line, got %q", out)
 	}
-	first := changes[0]
-	if first.Name != "eth0" || len(first.Added) != 1 || len(first.Removed) != 1 {
-		t.Fatalf("unexpected change for eth0: %#v", first)
+	if !strings.Contains(out, "リンクの変更 1 件、アドレスの変更 0 件") {
+		t.Fatalf("expected a localized summary, got %q", out)
 	}
 }
 
-func TestWatcherReportsChanges(t *testing.T) {
+func TestWatcherSummaryOnlySuppressesChangeLines(t *testing.T) {
 	writer := &bytes.Buffer{}
+	watcher := fixedWatcher(writer)
+	watcher.SummaryOnly = true
+	prev := snapshot{Snapshot: netevent.Snapshot{Interfaces: map[string]interfaces.Interface{}, Addresses: map[string][]string{}}}
+	curr := snapshot{
+		Snapshot: netevent.Snapshot{
+			Interfaces: map[string]interfaces.Interface{"eth0": {Name: "eth0"}},
+			Addresses:  map[string][]string{"eth0": nil},
+		},
+	}
+	counts := newTally()
+	watcher.reportChanges(prev, curr, counts)
+	if writer.Len() != 0 {
+		t.Fatalf("expected no change lines, got %q", writer.String())
+	}
+	watcher.printSummary(counts)
+	watcher.printSummary(newTally())
+	out := writer.String()
+	if !strings.Contains(out, "1 link change, 0 address changes; busiest: eth0 (1)") || !strings.Contains(out, "no changes") {
+		t.Fatalf("unexpected summaries: %q", out)
+	}
+}
+
+func TestWatcherQuietPrintsOnlyChanges(t *testing.T) {
+	writer := &bytes.Buffer{}
+	provider := &changingInterfaceProvider{mtu: 1500}
+	changes := make(chan struct{})
 	watcher := Watcher{
-		Lister:   interfaces.NewLister(stubInterfaceProvider{interfaces: []interfaces.Interface{{Name: "eth0", HardwareAddr: "aa:bb", MTU: 1500}}}),
-		Viewer:   addresses.NewViewer(stubAddressProvider{addrs: map[string][]string{"eth0": {"192.0.2.1/24"}}}),
+		Lister:    interfaces.NewLister(provider),
+		Viewer:    addresses.NewViewer(stubAddressProvider{}),
+		Changes:   changes,
+		Writer:    writer,
+		Quiet:     true,
+		MaxEvents: 1,
+	}
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(context.Background()) }()
+	changes <- struct{}{} // taken once the initial state is collected
+	provider.setMTU(1400)
+	changes <- struct{}{}
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	out := writer.String()
+	if strings.Contains(out, "monitoring started") || strings.Contains(out, "MTU=1500") {
+		t.Fatalf("quiet output must not include the initial state, got %q", out)
+	}
+	if !strings.Contains(out, "interface eth0 updated: MTU 1500→1400") {
+		t.Fatalf("expected the change, got %q", out)
+	}
+}
+
+func TestWatcherCollectsOnlyTheKindsWanted(t *testing.T) {
+	writer := &bytes.Buffer{}
+	watcher := Watcher{
+		Lister: interfaces.NewLister(stubInterfaceProvider{interfaces: []interfaces.Interface{{Name: "eth0", HardwareAddr: "aa:bb", MTU: 1500}}}),
+		// Reading addresses or routes would fail: they must not be read.
+		Viewer:   addresses.NewViewer(stubAddressProvider{err: errors.New("addresses read")}),
+		Routes:   &routes.Viewer{},
 		Interval: time.Millisecond,
+		Kinds:    []string{netevent.Link},
 		Writer:   writer,
-		Now: func() time.Time {
-			return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
-		},
+		Now:      func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) },
 	}
 	ctx, cancel := context.WithCancel(context.Background())
-	cancel() // immediately cancel to avoid ticker loop
+	cancel()
 	if err := watcher.Run(ctx); !errors.Is(err, context.Canceled) {
-		t.Fatalf("expected context canceled, got %v", err)
+		t.Fatalf("Run() error = %v, want context canceled", err)
+	}
+	if out := writer.String(); !strings.Contains(out, " - events: link\n") || strings.Contains(out, "addresses:") || strings.Contains(out, "routes:") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+
+	writer.Reset()
+	watcher = fixedWatcher(writer)
+	watcher.Kinds = []string{netevent.Address}
+	prev := snapshot{Snapshot: netevent.Snapshot{Interfaces: map[string]interfaces.Interface{"eth0": {Name: "eth0", MTU: 1500}}, Addresses: map[string][]string{}}}
+	curr := snapshot{Snapshot: netevent.Snapshot{Interfaces: map[string]interfaces.Interface{"eth0": {Name: "eth0", MTU: 1400}}, Addresses: map[string][]string{"eth0": {"192.0.2.1/24"}}}}
+	watcher.reportChanges(prev, curr, newTally())
+	if want := "[2024-01-01T00:00:00Z] eth0 addresses added: 192.0.2.1/24\n"; writer.String() != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", writer.String(), want)
+	}
+
+	watcher = Watcher{Writer: &bytes.Buffer{}, Interval: time.Second, Kinds: []string{"addr"}}
+	if err := watcher.Run(context.Background()); err == nil || !strings.Contains(err.Error(), `unknown event kind "addr"`) {
+		t.Fatalf("expected an unknown kind error, got %v", err)
+	}
+}
+
+func TestWatcherReportsAddressesOnSeveralInterfaces(t *testing.T) {
+	writer := &bytes.Buffer{}
+	watcher := fixedWatcher(writer)
+	prev := snapshot{Snapshot: netevent.Snapshot{Addresses: map[string][]string{"eth0": {"192.0.2.1/24", "fe80::1/64"}, "eth1": {"fe80::1/64"}}}}
+	curr := snapshot{Snapshot: netevent.Snapshot{Addresses: map[string][]string{"eth0": {"192.0.2.1/24", "fe80::1/64"}, "eth1": {"192.0.2.1/25", "fe80::1/64"}}}}
+	watcher.reportChanges(prev, curr, newTally())
+	want := "[2024-01-01T00:00:00Z] eth1 addresses added: 192.0.2.1/25\n" +
+		"[2024-01-01T00:00:00Z] eth0 address 192.0.2.1 conflicts: also configured on eth1\n"
+	if got := writer.String(); got != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+	writer.Reset()
+	watcher.reportChanges(curr, curr, newTally())
+	if writer.Len() != 0 {
+		t.Fatalf("a known conflict was reported again: %s", writer)
+	}
+}
+
+// stubProber answers every probe with claims, counting the probes.
+type stubProber struct {
+	claims []announce.Claim
+	probed []map[string][]net.IP
+}
+
+func (s *stubProber) Probe(ips map[string][]net.IP) ([]announce.Claim, error) {
+	s.probed = append(s.probed, ips)
+	return s.claims, nil
+}
+
+func TestWatcherReportsProbedConflictsOnce(t *testing.T) {
+	writer := &bytes.Buffer{}
+	running := interfaces.Interface{Name: "eth0", Flags: []string{"up"}, Carrier: true, OperState: "up"}
+	down := interfaces.Interface{Name: "eth1"}
+	prober := &stubProber{claims: []announce.Claim{{Device: "eth0", IP: net.ParseIP("192.0.2.1"), HardwareAddr: net.HardwareAddr{0x02, 0x00, 0x5e, 0x00, 0x53, 0x09}}}}
+	watcher := Watcher{
+		Lister: interfaces.NewLister(stubInterfaceProvider{interfaces: []interfaces.Interface{running, down}}),
+		Viewer: addresses.NewViewer(stubAddressProvider{addrs: map[string][]string{
+			"eth0": {"192.0.2.1/24", "2001:db8::1/64"},
+			"eth1": {"198.51.100.1/24"},
+		}}),
+		Prober:     prober,
+		ProbeEvery: time.Millisecond,
+		Interval:   time.Hour,
+		Quiet:      true,
+		MaxEvents:  1,
+		Writer:     writer,
+		Now:        func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) },
+	}
+	if err := watcher.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := "[2024-01-01T00:00:00Z] eth0 address 192.0.2.1 conflicts: 02:00:5e:00:53:09 answers for it too\n" +
+		"[2024-01-01T00:00:00Z] monitoring stopped after 1 changes\n"
+	if got := writer.String(); got != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+	if len(prober.probed) != 2 || len(prober.probed[0]) != 1 || len(prober.probed[0]["eth0"]) != 1 || !prober.probed[0]["eth0"][0].Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("probed %v, want 192.0.2.1 on eth0 twice", prober.probed)
+	}
+}
+
+// stubGateways answers gateway checks from the answers of each round.
+type stubGateways struct {
+	rounds  [][]bool
+	checked []string
+}
+
+func (s *stubGateways) Reachable(name string, gateway net.IP) (bool, error) {
+	s.checked = append(s.checked, name+" "+gateway.String())
+	round := s.rounds[0]
+	if len(s.rounds) > 1 {
+		s.rounds = s.rounds[1:]
+	}
+	return round[0], nil
+}
+
+func TestWatcherReportsGatewayTransitions(t *testing.T) {
+	writer := &bytes.Buffer{}
+	watcher := fixedWatcher(writer)
+	gateways := &stubGateways{rounds: [][]bool{{true}, {false}, {false}, {true}}}
+	watcher.Gateways = gateways
+	snap := snapshot{
+		Snapshot: netevent.Snapshot{
+			Interfaces: map[string]interfaces.Interface{
+				"eth0": {Name: "eth0", Flags: []string{"up"}, Carrier: true, OperState: "up"},
+				"eth1": {Name: "eth1"},
+			},
+			Routes: map[string][]routes.Route{
+				"eth0": {
+					{Key: "192.0.2.0/24", Via: "proto kernel"},
+					{Key: "default", Via: "via 192.0.2.1 proto dhcp"},
+					{Key: "default (ipv6)", Via: "via fe80::1"},
+				},
+				"eth1": {{Key: "default metric 200", Via: "via 198.51.100.1"}},
+			},
+		},
+	}
+	reachable := map[string]bool{}
+	for range 4 {
+		var err error
+		if reachable, err = watcher.checkGateways(snap, reachable, newTally()); err != nil {
+			t.Fatalf("checkGateways() error = %v", err)
+		}
+	}
+	want := "[2024-01-01T00:00:00Z] eth0 gateway 192.0.2.1 is not answering\n" +
+		"[2024-01-01T00:00:00Z] eth0 gateway 192.0.2.1 is answering again\n"
+	if got := writer.String(); got != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+	if len(gateways.checked) != 4 || gateways.checked[0] != "eth0 192.0.2.1" {
+		t.Fatalf("checked %v, want only the IPv4 gateway of eth0", gateways.checked)
+	}
+}
+
+func TestDefaultGatewaysIncludesNextHops(t *testing.T) {
+	got := defaultGateways([]routes.Route{{Key: "default", Via: "nexthop via 192.0.2.1 nexthop via 192.0.2.2 proto static"}, {Key: "198.51.100.0/24", Via: "via 192.0.2.3"}})
+	if len(got) != 2 || !got[0].Equal(net.ParseIP("192.0.2.1")) || !got[1].Equal(net.ParseIP("192.0.2.2")) {
+		t.Fatalf("defaultGateways() = %v", got)
+	}
+}
+
+func TestWatcherRetriesFailedCollections(t *testing.T) {
+	defer func(policy backoff.Policy) { retryPolicy = policy }(retryPolicy)
+	retryPolicy = backoff.Policy{Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+	writer := &bytes.Buffer{}
+	provider := &changingInterfaceProvider{mtu: 1500}
+	changes := make(chan struct{})
+	watcher := fixedWatcher(writer)
+	watcher.SummaryEvery = 0
+	watcher.Lister = interfaces.NewLister(provider)
+	watcher.Viewer = addresses.NewViewer(stubAddressProvider{})
+	watcher.Changes = changes
+	watcher.Quiet = true
+	watcher.MaxEvents = 1
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(context.Background()) }()
+	changes <- struct{}{} // taken once the initial state is collected
+	provider.fail(3)
+	provider.setMTU(1400)
+	changes <- struct{}{}
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v, want the failures to be retried", err)
 	}
+	want := "[2024-01-01T00:00:00Z] could not collect the state, retrying in 1ms: interrupted system call\n" +
+		"[2024-01-01T00:00:00Z] could not collect the state, retrying in 2ms: interrupted system call\n" +
+		"[2024-01-01T00:00:00Z] could not collect the state, retrying in 2ms: interrupted system call\n" +
+		"[2024-01-01T00:00:00Z] collected the state again after 3 failures\n" +
+		"[2024-01-01T00:00:00Z] interface eth0 updated: MTU 1500→1400\n" +
+		"[2024-01-01T00:00:00Z] monitoring stopped after 1 changes\n"
+	if got := writer.String(); got != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWatcherDeliversEventsOnAChannel(t *testing.T) {
+	provider := &changingInterfaceProvider{mtu: 1500}
+	changes := make(chan struct{})
+	watcher := Watcher{
+		Lister:  interfaces.NewLister(provider),
+		Viewer:  addresses.NewViewer(stubAddressProvider{}),
+		Changes: changes,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := watcher.Events(ctx)
+	changes <- struct{}{} // taken once the initial state is collected
+	provider.setMTU(1400)
+	changes <- struct{}{}
+	event := <-events
+	if event.Kind != netevent.Link || event.Type != "interface_updated" || event.Message != "interface eth0 updated: MTU 1500→1400" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	close(changes)
+	if event := <-events; event.Kind != netevent.Error || !strings.Contains(event.Message, "notifications stopped") {
+		t.Fatalf("expected the error that stopped the watcher, got %+v", event)
+	}
+	if _, ok := <-events; ok {
+		t.Fatal("the channel was not closed once the watcher stopped")
+	}
+}
+
+func TestWatcherSendsHeartbeatsWithoutChanges(t *testing.T) {
+	writer := &bytes.Buffer{}
+	beats := make(chan netevent.Event, 1)
+	watcher := fixedWatcher(writer)
+	watcher.SummaryEvery = 0
+	watcher.Lister = interfaces.NewLister(stubInterfaceProvider{interfaces: []interfaces.Interface{{Name: "eth0"}, {Name: "eth1"}}})
+	watcher.Viewer = addresses.NewViewer(stubAddressProvider{})
+	watcher.Interval = time.Hour
+	watcher.Quiet = true
+	watcher.MaxEvents = 1
+	watcher.HeartbeatEvery = time.Millisecond
+	watcher.Sinks = []EventSink{SinkFunc(func(event netevent.Event) {
+		select {
+		case beats <- event:
+		default:
+		}
+	})}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(ctx) }()
+	for range 3 {
+		if beat := <-beats; beat.Kind != netevent.Heartbeat || beat.Message != "heartbeat: watching 2 interfaces" {
+			t.Fatalf("unexpected heartbeat %+v", beat)
+		}
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run() error = %v; heartbeats must not count as changes", err)
+	}
+	if writer.Len() != 0 {
+		t.Fatalf("heartbeats were printed: %q", writer)
+	}
+}
+
+func TestWatcherSummaryOnlyRequiresPeriod(t *testing.T) {
+	watcher := Watcher{Writer: &bytes.Buffer{}, Interval: time.Second, SummaryOnly: true}
+	if err := watcher.Run(context.Background()); err == nil {
+		t.Fatal("expected error for summary-only without a period")
+	}
+}
+
+func TestTallyBusiestLimitsAndOrders(t *testing.T) {
+	counts := newTally()
+	for _, name := range []string{"c", "a", "b", "b", "d", "d", "d"} {
+		counts.link(name)
+	}
+	got := strings.Join(counts.busiest(3), ", ")
+	if got != "d (3), b (2), a (1)" {
+		t.Fatalf("busiest() = %q", got)
+	}
+}
+
+func TestWatcherReportsRouteChanges(t *testing.T) {
+	writer := &bytes.Buffer{}
+	watcher := fixedWatcher(writer)
+	watcher.Routes = &routes.Viewer{}
+	prev := snapshot{Snapshot: netevent.Snapshot{Routes: map[string][]routes.Route{
+		"eth0": {{Key: "192.0.2.0/24", Via: "proto kernel"}, {Key: "default", Via: "via 192.0.2.1"}},
+	}}}
+	curr := snapshot{Snapshot: netevent.Snapshot{Routes: map[string][]routes.Route{
+		"eth0": {{Key: "198.51.100.0/24", Via: "via 192.0.2.1"}, {Key: "default", Via: "via 192.0.2.254"}},
+	}}}
+	counts := newTally()
+	watcher.reportChanges(prev, curr, counts)
+	watcher.printSummary(counts)
+	want := "[2024-01-01T00:00:00Z] eth0 route added: 198.51.100.0/24 via 192.0.2.1\n" +
+		"[2024-01-01T00:00:00Z] eth0 route changed: default via 192.0.2.1 → default via 192.0.2.254\n" +
+		"[2024-01-01T00:00:00Z] eth0 route removed: 192.0.2.0/24 proto kernel\n" +
+		"[2024-01-01T00:00:00Z] summary for the last 5m0s: 0 link changes, 0 address changes, 3 route changes; busiest: eth0 (3)\n"
+	if got := writer.String(); got != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWatcherReportsNeighborChanges(t *testing.T) {
+	writer := &bytes.Buffer{}
+	watcher := fixedWatcher(writer)
+	watcher.Neighbors = &neighbors.Viewer{}
+	prev := snapshot{Snapshot: netevent.Snapshot{Neighbors: map[string][]neighbors.Neighbor{"eth0": {
+		{IP: "192.0.2.1", MAC: "02:00:5e:00:53:01", State: "reachable"},
+		{IP: "192.0.2.2", MAC: "02:00:5e:00:53:02", State: "reachable"},
+		{IP: "192.0.2.3", MAC: "02:00:5e:00:53:03", State: "stale"},
+		{IP: "192.0.2.4", State: "incomplete"},
+	}}}}
+	curr := snapshot{Snapshot: netevent.Snapshot{Neighbors: map[string][]neighbors.Neighbor{"eth0": {
+		{IP: "192.0.2.1", MAC: "02:00:5e:00:53:09", State: "stale"},
+		{IP: "192.0.2.2", MAC: "02:00:5e:00:53:02", State: "reachable"},
+		{IP: "192.0.2.4", State: "failed"},
+		{IP: "192.0.2.5", MAC: "02:00:5e:00:53:05", State: "delay"},
+	}}}}
+	counts := newTally()
+	watcher.reportChanges(prev, curr, counts)
+	watcher.printSummary(counts)
+	want := "[2024-01-01T00:00:00Z] eth0 neighbor 192.0.2.1 moved: lladdr 02:00:5e:00:53:01 → 02:00:5e:00:53:09\n" +
+		"[2024-01-01T00:00:00Z] eth0 neighbor 192.0.2.1 is stale\n" +
+		"[2024-01-01T00:00:00Z] eth0 neighbor 192.0.2.4 failed\n" +
+		"[2024-01-01T00:00:00Z] eth0 neighbor appeared: 192.0.2.5 lladdr 02:00:5e:00:53:05 delay\n" +
+		"[2024-01-01T00:00:00Z] eth0 neighbor 192.0.2.3 removed\n" +
+		"[2024-01-01T00:00:00Z] summary for the last 5m0s: 0 link changes, 0 address changes, 5 neighbor changes; busiest: eth0 (5)\n"
+	if got := writer.String(); got != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWatcherFiltersNeighbors(t *testing.T) {
+	watcher := Watcher{NeighborIPs: []string{"2001:db8::0001"}}
+	list := []neighbors.Neighbor{{IP: "192.0.2.1"}, {IP: "2001:db8::1"}}
+	if got := watcher.watchedNeighbors(list); len(got) != 1 || got[0].IP != "2001:db8::1" {
+		t.Fatalf("watchedNeighbors() = %v, want only 2001:db8::1", got)
+	}
+	watcher = Watcher{Writer: &bytes.Buffer{}, Interval: time.Second, NeighborIPs: []string{"gateway"}}
+	if err := watcher.Run(context.Background()); err == nil || !strings.Contains(err.Error(), `invalid neighbor address "gateway"`) {
+		t.Fatalf("expected an invalid address error, got %v", err)
+	}
+}
+
+func TestWatcherFiltersInterfacesByPattern(t *testing.T) {
+	provider := stubInterfaceProvider{interfaces: []interfaces.Interface{{Name: "bond0"}, {Name: "eth0"}, {Name: "eth1"}, {Name: "ethx"}, {Name: "lo"}}}
+	tests := []struct {
+		patterns []string
+		regex    string
+		want     []string
+	}{
+		{patterns: []string{"eth0"}, want: []string{"eth0"}},
+		{patterns: []string{"eth0", "lo"}, want: []string{"eth0", "lo"}},
+		{patterns: []string{"eth*"}, want: []string{"eth0", "eth1", "ethx"}},
+		{regex: `^(eth|bond)\d+$`, want: []string{"bond0", "eth0", "eth1"}},
+		{patterns: []string{"eth?"}, regex: `\d$`, want: []string{"eth0", "eth1"}},
+	}
+	for _, tt := range tests {
+		watcher := Watcher{
+			Lister:     interfaces.NewLister(provider),
+			Viewer:     addresses.NewViewer(stubAddressProvider{}),
+			Interfaces: tt.patterns,
+		}
+		if tt.regex != "" {
+			watcher.InterfaceRegex = regexp.MustCompile(tt.regex)
+		}
+		snap, err := watcher.collect()
+		if err != nil {
+			t.Fatalf("collect() error = %v", err)
+		}
+		var got []string
+		for name := range snap.Interfaces {
+			got = append(got, name)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q %q watched %v, want %v", tt.patterns, tt.regex, got, tt.want)
+		}
+	}
+}
+
+func TestWatcherWaitsForMatchingInterfaces(t *testing.T) {
+	writer := &bytes.Buffer{}
+	watcher := fixedWatcher(writer)
+	watcher.Interfaces = []string{"wg*"}
+	watcher.Interval = time.Second
+	watcher.printInitial(snapshot{})
+	if want := "Waiting for matching interfaces to appear...\n"; !strings.HasSuffix(writer.String(), want) {
+		t.Fatalf("unexpected output:\n%s", writer.String())
+	}
+	writer.Reset()
+	watcher.Interfaces = []string{"eth0", "eth1"}
+	watcher.printInitial(snapshot{})
+	if want := "Waiting for eth0, eth1 to appear...\n"; !strings.HasSuffix(writer.String(), want) {
+		t.Fatalf("unexpected output:\n%s", writer.String())
+	}
+	watcher.Interfaces = []string{"eth0", "eth["}
+	if err := watcher.Run(context.Background()); err == nil || !strings.Contains(err.Error(), `invalid interface pattern "eth["`) {
+		t.Fatalf("expected an invalid pattern error, got %v", err)
+	}
+}
+
+func TestWatcherReportsRenegotiation(t *testing.T) {
+	writer := &bytes.Buffer{}
+	watcher := fixedWatcher(writer)
+	watcher.Now = func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+	gigabit := interfaces.Interface{Name: "eth0", OperState: "up", Carrier: true, Speed: 1000, Duplex: "full"}
+	degraded := gigabit
+	degraded.Speed, degraded.Duplex = 100, "half"
+	snap := func(iface interfaces.Interface) snapshot {
+		return snapshot{Snapshot: netevent.Snapshot{Interfaces: map[string]interfaces.Interface{"eth0": iface}}, downSince: make(map[string]time.Time)}
+	}
+	watcher.reportChanges(snap(gigabit), snap(degraded), newTally())
+	want := "[2024-01-01T00:00:00Z] interface eth0 updated: speed 1000Mb/s→100Mb/s, duplex full→half\n"
+	if got := writer.String(); got != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+	if state := linkState(degraded); !strings.HasSuffix(state, " speed=100Mb/s duplex=half") {
+		t.Fatalf("linkState() = %q, want the speed and duplex", state)
+	}
+}
+
+func TestWatcherReportsLinkDownAndUp(t *testing.T) {
+	writer := &bytes.Buffer{}
+	watcher := fixedWatcher(writer)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	watcher.Now = func() time.Time { return now }
+	running := interfaces.Interface{Name: "eth0", Flags: []string{"up", "running"}, OperState: "up", Carrier: true}
+	unplugged := interfaces.Interface{Name: "eth0", Flags: []string{"up"}, OperState: "down"}
+	disabled := interfaces.Interface{Name: "eth0", OperState: "down"}
+	snap := func(iface interfaces.Interface) snapshot {
+		return snapshot{Snapshot: netevent.Snapshot{Interfaces: map[string]interfaces.Interface{"eth0": iface}}, downSince: make(map[string]time.Time)}
+	}
+
+	first, second, third, fourth := snap(running), snap(unplugged), snap(disabled), snap(running)
+	counts := newTally()
+	watcher.reportChanges(first, second, counts)
+	now = now.Add(90 * time.Second)
+	watcher.reportChanges(second, third, counts)
+	now = now.Add(1500 * time.Millisecond)
+	watcher.reportChanges(third, fourth, counts)
+	if _, ok := fourth.downSince["eth0"]; ok {
+		t.Fatal("a link that came back up must not keep its down time")
+	}
+	lines := strings.Split(strings.TrimSpace(writer.String()), "\n")
+	want := []string{
+		"[2024-01-01T00:00:00Z] interface eth0 updated: flags [up,running]→[up], operstate up→down, carrier on→off",
+		"[2024-01-01T00:00:00Z] eth0 link went down (no carrier)",
+		"[2024-01-01T00:01:30Z] interface eth0 updated: flags [up]→[]",
+		"[2024-01-01T00:01:31Z] interface eth0 updated: flags []→[up,running], operstate down→up, carrier off→on",
+		"[2024-01-01T00:01:31Z] eth0 link came up after 1m31.5s down",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+	if counts.links != 3 {
+		t.Fatalf("link changes = %d, want 3: a going down or coming up is counted once", counts.links)
+	}
+
+	writer.Reset()
+	watcher.reportChanges(snap(disabled), snap(running), newTally())
+	if got := writer.String(); !strings.HasSuffix(got, "eth0 link came up\n") {
+		t.Fatalf("a link down from the start comes up without a downtime, got %q", got)
+	}
+	writer.Reset()
+	watcher.reportChanges(snap(running), snap(disabled), newTally())
+	if got := writer.String(); !strings.HasSuffix(got, "eth0 link went down (administratively down)\n") {
+		t.Fatalf("expected an administrative reason, got %q", got)
+	}
+}
+
+func sample(name string, stats netlink.LinkStatistics) counters.Sample {
+	samples, _ := counters.Collect(linkSource{&netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: name, Statistics: &stats}}})
+	return samples[0]
+}
+
+type linkSource []netlink.Link
+
+func (s linkSource) LinkList() ([]netlink.Link, error) {
+	return s, nil
+}
+
+// growingInterfaceProvider lists an interface whose MTU grows with every
+// read, so that each refresh finds one change.
+type growingInterfaceProvider struct {
+	mu  sync.Mutex
+	mtu int
+}
+
+func (g *growingInterfaceProvider) ListInterfaces() ([]interfaces.Interface, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.mtu++
+	return []interfaces.Interface{{Name: "eth0", MTU: g.mtu}}, nil
+}
+
+func TestWatcherStopsAfterMaxEvents(t *testing.T) {
+	writer := &bytes.Buffer{}
+	notified := 0
+	watcher := fixedWatcher(writer)
+	watcher.SummaryEvery = 0
+	watcher.Lister = interfaces.NewLister(&growingInterfaceProvider{mtu: 1500})
+	watcher.Viewer = addresses.NewViewer(stubAddressProvider{})
+	watcher.Interval = time.Millisecond
+	watcher.MaxEvents = 2
+	watcher.Sinks = []EventSink{SinkFunc(func(netevent.Event) { notified++ })}
+	if err := watcher.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if notified != 2 {
+		t.Fatalf("the sink received %d events, want 2", notified)
+	}
+	if want := "[2024-01-01T00:00:00Z] monitoring stopped after 2 changes\n"; !strings.HasSuffix(writer.String(), want) {
+		t.Fatalf("unexpected output:\n%s", writer.String())
+	}
+}
+
+func TestWatcherStopsAfterDuration(t *testing.T) {
+	writer := &bytes.Buffer{}
+	watcher := fixedWatcher(writer)
+	watcher.SummaryEvery = 0
+	watcher.Lister = interfaces.NewLister(stubInterfaceProvider{})
+	watcher.Interval = time.Hour
+	watcher.Duration = 10 * time.Millisecond
+	if err := watcher.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if want := "[2024-01-01T00:00:00Z] monitoring stopped after 10ms\n"; !strings.HasSuffix(writer.String(), want) {
+		t.Fatalf("unexpected output:\n%s", writer.String())
+	}
+}
+
+func TestLostReportsRemovalAndCarrierLoss(t *testing.T) {
+	prev := snapshot{Snapshot: netevent.Snapshot{Interfaces: map[string]interfaces.Interface{"eth0": {Name: "eth0", Carrier: true}, "eth1": {Name: "eth1"}}}}
+	tests := []struct {
+		curr map[string]interfaces.Interface
+		want string
+	}{
+		{map[string]interfaces.Interface{"eth0": {Name: "eth0", Carrier: true}, "eth1": {Name: "eth1"}}, ""},
+		{map[string]interfaces.Interface{"eth0": {Name: "eth0", Carrier: true}}, "monitored interface lost: eth1 was removed"},
+		{map[string]interfaces.Interface{"eth0": {Name: "eth0"}, "eth1": {Name: "eth1"}}, "monitored interface lost: eth0 lost its carrier"},
+	}
+	for _, tt := range tests {
+		err := lost(prev, snapshot{Snapshot: netevent.Snapshot{Interfaces: tt.curr}})
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("lost() = %v, want nil", err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInterfaceLost) || err.Error() != tt.want {
+			t.Errorf("lost() = %v, want %s", err, tt.want)
+		}
+	}
+}
+
+func TestWatcherFailsOnRemoval(t *testing.T) {
+	provider := &changingInterfaceProvider{mtu: 1500}
+	changes := make(chan struct{}, 1)
+	watcher := Watcher{
+		Lister:        interfaces.NewLister(provider),
+		Viewer:        addresses.NewViewer(stubAddressProvider{}),
+		Interfaces:    []string{"eth0"},
+		Changes:       changes,
+		Writer:        &bytes.Buffer{},
+		FailOnRemoval: true,
+	}
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(context.Background()) }()
+	changes <- struct{}{}
+	provider.setMTU(1400)
+	changes <- struct{}{}
+	provider.remove()
+	changes <- struct{}{}
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrInterfaceLost) {
+			t.Fatalf("Run() error = %v, want ErrInterfaceLost", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after the interface was removed")
+	}
+}
+
+func TestWatcherReportsTrafficRates(t *testing.T) {
+	writer := &bytes.Buffer{}
+	watcher := fixedWatcher(writer)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	prev := snapshot{Snapshot: netevent.Snapshot{Taken: start}, traffic: map[string]counters.Sample{
+		"eth0": sample("eth0", netlink.LinkStatistics{RxBytes: 1_000_000, RxPackets: 1000, TxBytes: 10_000, TxPackets: 100}),
+		"eth1": sample("eth1", netlink.LinkStatistics{RxBytes: 500}),
+		"eth2": sample("eth2", netlink.LinkStatistics{RxBytes: 9000}),
+	}}
+	curr := snapshot{Snapshot: netevent.Snapshot{Taken: start.Add(5 * time.Second)}, traffic: map[string]counters.Sample{
+		"eth0": sample("eth0", netlink.LinkStatistics{RxBytes: 1_750_000, RxPackets: 1500, TxBytes: 15_000, TxPackets: 110, RxErrors: 2, TxDropped: 1}),
+		"eth1": sample("eth1", netlink.LinkStatistics{RxBytes: 500}),
+		"eth2": sample("eth2", netlink.LinkStatistics{RxBytes: 100}),
+		"eth3": sample("eth3", netlink.LinkStatistics{RxBytes: 100}),
+	}}
+	watcher.reportTraffic(prev, curr)
+	want := "[2024-01-01T00:00:00Z] eth0 rx 1.20 Mbit/s (100.0 pkt/s), tx 8.00 kbit/s (2.0 pkt/s)\n" +
+		"[2024-01-01T00:00:00Z] eth0 errors rx +2 tx +0, drops rx +0 tx +1\n" +
+		"[2024-01-01T00:00:00Z] eth2 traffic counters were reset\n"
+	if got := writer.String(); got != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWatcherTrafficRequiresInterval(t *testing.T) {
+	changes := make(chan struct{})
+	watcher := Watcher{Writer: &bytes.Buffer{}, Changes: changes, Traffic: linkSource{}}
+	if err := watcher.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "interval must be positive") {
+		t.Fatalf("expected an interval error, got %v", err)
+	}
+}
+
+func TestWatcherNotifiesChangesButNotRates(t *testing.T) {
+	writer := &bytes.Buffer{}
+	watcher := fixedWatcher(writer)
+	watcher.SummaryOnly = true
+	var events []netevent.Event
+	watcher.Sinks = []EventSink{SinkFunc(func(event netevent.Event) { events = append(events, event) })}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	prev := snapshot{
+		Snapshot: netevent.Snapshot{
+			Taken:     start,
+			Addresses: map[string][]string{"eth0": {"192.0.2.10/24"}},
+		},
+		traffic: map[string]counters.Sample{"eth0": sample("eth0", netlink.LinkStatistics{RxBytes: 100})},
+	}
+	curr := snapshot{
+		Snapshot: netevent.Snapshot{
+			Taken:     start.Add(time.Second),
+			Addresses: map[string][]string{"eth0": nil},
+		},
+		traffic: map[string]counters.Sample{"eth0": sample("eth0", netlink.LinkStatistics{RxBytes: 200})},
+	}
+	watcher.reportChanges(prev, curr, newTally())
+	watcher.reportTraffic(prev, curr)
+	want := []netevent.Event{{Time: start, Kind: netevent.Address, Type: "address_removed", Interface: "eth0", Old: "192.0.2.10/24", Message: "eth0 addresses removed: 192.0.2.10/24"}}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %#v, want %#v", events, want)
+	}
+	if writer.Len() != 0 {
+		t.Fatalf("summary-only output must not print changes, got %q", writer.String())
+	}
+}
+
+func TestWatcherColorsChangesButNotEvents(t *testing.T) {
+	writer := &bytes.Buffer{}
+	watcher := fixedWatcher(writer)
+	watcher.Colors = output.Colorizer{Enabled: true}
+	var events []netevent.Event
+	watcher.Sinks = []EventSink{SinkFunc(func(event netevent.Event) { events = append(events, event) })}
+	prev := snapshot{Snapshot: netevent.Snapshot{Addresses: map[string][]string{"eth0": {"192.0.2.10/24"}}}}
+	curr := snapshot{Snapshot: netevent.Snapshot{Addresses: map[string][]string{"eth0": {"192.0.2.20/24"}}}}
+	watcher.reportChanges(prev, curr, newTally())
 	out := writer.String()
-	if !strings.Contains(out, "monitoring started") {
-		t.Fatalf("expected initial message, got %q", out)
+	if !strings.Contains(out, "\x1b[32meth0 addresses added: 192.0.2.20/24\x1b[0m") || !strings.Contains(out, "\x1b[31meth0 addresses removed: 192.0.2.10/24\x1b[0m") {
+		t.Fatalf("expected a green addition and a red removal, got %q", out)
+	}
+	for _, event := range events {
+		if strings.Contains(event.Message, "\x1b") {
+			t.Fatalf("event message %q carries colors", event.Message)
+		}
+	}
+}
+
+func TestEventChangeClassifiesTypes(t *testing.T) {
+	tests := map[string]output.Change{
+		"interface_added":     output.Added,
+		"link_up":             output.Added,
+		"neighbor_appeared":   output.Added,
+		"route_removed":       output.Removed,
+		"link_down":           output.Removed,
+		"neighbor_failed":     output.Removed,
+		"gateway_reachable":   output.Added,
+		"gateway_unreachable": output.Removed,
+		"interface_updated":   output.Updated,
+		"neighbor_stale":      output.Updated,
+	}
+	for typ, want := range tests {
+		if got := eventChange(typ); got != want {
+			t.Errorf("eventChange(%s) = %d, want %d", typ, got, want)
+		}
 	}
 }
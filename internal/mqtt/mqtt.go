@@ -0,0 +1,495 @@
+// Package mqtt publishes messages to an MQTT 3.1.1 broker, speaking just
+// enough of the protocol for goeth to feed home automation and IoT buses
+// without an MQTT library.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/user/goeth/internal/backoff"
+	"github.com/user/goeth/internal/queue"
+)
+
+// Default ports of plain and TLS brokers.
+const (
+	defaultPort    = "1883"
+	defaultTLSPort = "8883"
+)
+
+// Timing of the connection: how long the broker waits for a sign of life
+// before dropping goeth, and how long each exchange may take.
+const (
+	keepAlive   = 60 * time.Second
+	dialTimeout = 10 * time.Second
+	ackTimeout  = 10 * time.Second
+)
+
+// reconnects is how often a message is tried again on a new connection
+// once the broker turns out to be lost, waiting as retryPolicy says before
+// each try. retryPolicy is a variable so that tests can shorten it.
+const reconnects = 3
+
+var retryPolicy = backoff.Policy{Backoff: time.Second, MaxBackoff: 30 * time.Second}
+
+// Control packet types, shifted into the high nibble of the first octet.
+const (
+	packetConnect    = 1 << 4
+	packetConnack    = 2 << 4
+	packetPublish    = 3 << 4
+	packetPuback     = 4 << 4
+	packetPubrec     = 5 << 4
+	packetPubrel     = 6 << 4
+	packetPubcomp    = 7 << 4
+	packetPingreq    = 12 << 4
+	packetPingresp   = 13 << 4
+	packetDisconnect = 14 << 4
+)
+
+// Connect flags and the protocol level of MQTT 3.1.1.
+const (
+	protocolLevel = 4
+	flagClean     = 0x02
+	flagPassword  = 0x40
+	flagUsername  = 0x80
+	// pubrelFlags are the reserved flags PUBREL must carry.
+	pubrelFlags = 0x02
+	dupFlag     = 0x08
+	retainFlag  = 0x01
+	maxQoS      = 2
+)
+
+// connackErrors explains the return codes of a refused connection.
+var connackErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// Options says where messages go and how they are delivered.
+type Options struct {
+	// Broker is mqtt://host[:port], port 1883 by default, or
+	// mqtts://host[:port] for TLS, port 8883 by default.
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// QoS is the delivery guarantee: 0 at most once, 1 at least once or 2
+	// exactly once.
+	QoS byte
+	// Retain asks the broker to keep the last message of each topic for
+	// new subscribers.
+	Retain bool
+	// TLS configures mqtts:// connections; nil verifies the broker against
+	// the system roots.
+	TLS *tls.Config
+}
+
+// Message is published to Topic.
+type Message struct {
+	Topic   string
+	Payload []byte
+}
+
+// Publisher queues messages and publishes them in the background. A lost
+// connection is made again for the next message. With QoS 1 or 2 the
+// broker keeps the session across connections, so that a message cut off
+// by a lost connection is completed on the next one rather than published
+// anew.
+type Publisher struct {
+	*queue.Queue[Message]
+	options Options
+	network string
+	addr    string
+	conn    net.Conn
+	reader  *bufio.Reader
+	id      uint16
+}
+
+// Dial checks options, connects to the broker and returns a Publisher
+// holding up to size unpublished messages.
+func Dial(options Options, size int) (*Publisher, error) {
+	if options.QoS > maxQoS {
+		return nil, fmt.Errorf("mqtt: QoS must be 0, 1 or 2, not %d", options.QoS)
+	}
+	u, err := url.Parse(options.Broker)
+	if err != nil {
+		return nil, fmt.Errorf("mqtt broker %q: %w", options.Broker, err)
+	}
+	p := &Publisher{Queue: queue.New[Message]("mqtt", size), options: options}
+	port := defaultPort
+	switch u.Scheme {
+	case "mqtt":
+		p.network = "tcp"
+	case "mqtts":
+		p.network = "tls"
+		port = defaultTLSPort
+	default:
+		return nil, fmt.Errorf("mqtt broker %q must be an mqtt:// or mqtts:// URL", options.Broker)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("mqtt broker %q has no host", options.Broker)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	p.addr = net.JoinHostPort(u.Hostname(), port)
+	if p.network == "tls" {
+		config := &tls.Config{}
+		if options.TLS != nil {
+			config = options.TLS.Clone()
+		}
+		if config.ServerName == "" {
+			config.ServerName = u.Hostname()
+		}
+		p.options.TLS = config
+	}
+	if p.options.QoS > 0 {
+		// A session left by an earlier run may hold the packet IDs this one
+		// reuses: a clean connection drops it before the one kept.
+		if err := p.connect(true); err != nil {
+			return nil, err
+		}
+		p.disconnect()
+	}
+	if err := p.connect(false); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// LoadTLS returns the TLS configuration that verifies the broker against
+// the CA certificates in caFile, or the system roots when it is empty, and
+// presents the client certificate in certFile and keyFile when given.
+func LoadTLS(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("mqtt: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("mqtt: no certificates in %s", caFile)
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("mqtt: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// Run publishes queued messages until ctx is cancelled, then those still
+// queued, and disconnects. While idle it keeps the connection alive.
+func (p *Publisher) Run(ctx context.Context) error {
+	defer p.disconnect()
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			p.Drain(ctx, p.publish)
+			return ctx.Err()
+		case <-ticker.C:
+			if p.conn == nil {
+				continue
+			}
+			if err := p.ping(); err != nil {
+				p.Report(fmt.Errorf("mqtt: %w", err))
+				p.drop()
+			}
+		case msg := <-p.Pending():
+			if err := p.publish(ctx, msg); err != nil {
+				p.Report(err)
+			}
+			ticker.Reset(keepAlive)
+		}
+	}
+}
+
+// delivery is a message on its way to the broker.
+type delivery struct {
+	msg Message
+	id  uint16
+	// sent is set once PUBLISH went out, so that it goes out again with
+	// DUP set, and received once the broker answered a QoS 2 PUBLISH with
+	// PUBREC, so that only PUBREL goes out again.
+	sent, received bool
+}
+
+// publish delivers msg, connecting again up to reconnects times when the
+// connection turns out to be lost. Cancelling ctx stops the waiting between
+// attempts.
+func (p *Publisher) publish(ctx context.Context, msg Message) error {
+	d := &delivery{msg: msg}
+	if p.options.QoS > 0 {
+		d.id = p.nextID()
+	}
+	wait := retryPolicy.Backoff
+	for attempt := 0; ; attempt++ {
+		err := p.attempt(d)
+		if err == nil || attempt >= reconnects {
+			return err
+		}
+		if waitErr := backoff.Wait(ctx, wait); waitErr != nil {
+			return fmt.Errorf("%w (gave up retrying: %w)", err, waitErr)
+		}
+		wait = retryPolicy.Next(wait)
+	}
+}
+
+// attempt goes on with d over the connection, making one first when there
+// is none.
+func (p *Publisher) attempt(d *delivery) error {
+	if p.conn == nil {
+		if err := p.connect(false); err != nil {
+			return err
+		}
+	}
+	if err := p.deliver(d); err != nil {
+		p.drop()
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	return nil
+}
+
+// connect opens a connection, with a clean session when clean is set or
+// the QoS keeps no state across connections.
+func (p *Publisher) connect(clean bool) error {
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	if p.network == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", p.addr, p.options.TLS)
+	} else {
+		conn, err = dialer.Dial("tcp", p.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	p.conn, p.reader = conn, bufio.NewReader(conn)
+	if err := p.write(connectPacket(p.options, clean || p.options.QoS == 0)); err != nil {
+		p.drop()
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	typ, body, err := p.read()
+	if err == nil && (typ != packetConnack || len(body) != 2) {
+		err = fmt.Errorf("unexpected packet %#x instead of CONNACK", typ)
+	}
+	if err == nil && body[1] != 0 {
+		err = fmt.Errorf("connection refused: %s", connackErrors[body[1]])
+		if connackErrors[body[1]] == "" {
+			err = fmt.Errorf("connection refused with code %d", body[1])
+		}
+	}
+	if err != nil {
+		p.drop()
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	return nil
+}
+
+// drop closes a connection that can no longer be trusted.
+func (p *Publisher) drop() {
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn, p.reader = nil, nil
+	}
+}
+
+func (p *Publisher) disconnect() {
+	if p.conn != nil {
+		p.write([]byte{packetDisconnect, 0})
+		p.drop()
+	}
+}
+
+// nextID returns the next packet ID, which is never zero.
+func (p *Publisher) nextID() uint16 {
+	p.id++
+	if p.id == 0 {
+		p.id++
+	}
+	return p.id
+}
+
+// deliver publishes d, or releases it when the broker already received it,
+// and waits for the acknowledgements its QoS calls for.
+func (p *Publisher) deliver(d *delivery) error {
+	qos := p.options.QoS
+	if !d.received {
+		err := p.write(publishPacket(d.msg, qos, p.options.Retain, d.id, d.sent))
+		d.sent = true
+		if err != nil {
+			return err
+		}
+		switch qos {
+		case 0:
+			return nil
+		case 1:
+			return p.await(packetPuback, d.id)
+		}
+		if err := p.await(packetPubrec, d.id); err != nil {
+			return err
+		}
+		d.received = true
+	}
+	if err := p.write(packet(packetPubrel|pubrelFlags, binary.BigEndian.AppendUint16(nil, d.id))); err != nil {
+		return err
+	}
+	return p.await(packetPubcomp, d.id)
+}
+
+// await reads packets until the acknowledgement of type typ for id.
+func (p *Publisher) await(typ byte, id uint16) error {
+	for {
+		got, body, err := p.read()
+		if err != nil {
+			return err
+		}
+		if got == typ && len(body) >= 2 && binary.BigEndian.Uint16(body) == id {
+			return nil
+		}
+	}
+}
+
+func (p *Publisher) ping() error {
+	if err := p.write([]byte{packetPingreq, 0}); err != nil {
+		return err
+	}
+	for {
+		typ, _, err := p.read()
+		if err != nil || typ == packetPingresp {
+			return err
+		}
+	}
+}
+
+func (p *Publisher) write(b []byte) error {
+	p.conn.SetWriteDeadline(time.Now().Add(ackTimeout))
+	_, err := p.conn.Write(b)
+	return err
+}
+
+// read reads one packet, returning its type without the flags and its
+// body.
+func (p *Publisher) read() (typ byte, body []byte, err error) {
+	p.conn.SetReadDeadline(time.Now().Add(ackTimeout))
+	first, err := p.reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, err := readLength(p.reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	body = make([]byte, n)
+	if _, err := io.ReadFull(p.reader, body); err != nil {
+		return 0, nil, err
+	}
+	return first & 0xf0, body, nil
+}
+
+// readLength decodes the variable length of the remaining packet.
+func readLength(r io.ByteReader) (int, error) {
+	n, shift := 0, 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		n |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return n, nil
+		}
+		if shift += 7; shift > 21 {
+			return 0, errors.New("malformed packet length")
+		}
+	}
+}
+
+// packet frames body as a control packet whose first octet is header.
+func packet(header byte, body []byte) []byte {
+	b := []byte{header}
+	n := len(body)
+	for {
+		digit := byte(n & 0x7f)
+		if n >>= 7; n > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if n == 0 {
+			break
+		}
+	}
+	return append(b, body...)
+}
+
+// appendString appends s prefixed with its length, as MQTT encodes
+// strings.
+func appendString(b []byte, s string) []byte {
+	return append(binary.BigEndian.AppendUint16(b, uint16(len(s))), s...)
+}
+
+func connectPacket(options Options, clean bool) []byte {
+	var flags byte
+	if clean {
+		flags |= flagClean
+	}
+	if options.Username != "" {
+		flags |= flagUsername
+		if options.Password != "" {
+			flags |= flagPassword
+		}
+	}
+	body := appendString(nil, "MQTT")
+	body = append(body, protocolLevel, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(keepAlive/time.Second))
+	body = appendString(body, options.ClientID)
+	if flags&flagUsername != 0 {
+		body = appendString(body, options.Username)
+	}
+	if flags&flagPassword != 0 {
+		body = appendString(body, options.Password)
+	}
+	return packet(packetConnect, body)
+}
+
+// publishPacket frames msg; dup marks a QoS 1 or 2 message sent again.
+func publishPacket(msg Message, qos byte, retain bool, id uint16, dup bool) []byte {
+	header := packetPublish | qos<<1
+	if retain {
+		header |= retainFlag
+	}
+	if dup && qos > 0 {
+		header |= dupFlag
+	}
+	body := appendString(nil, msg.Topic)
+	if qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, id)
+	}
+	return packet(header, append(body, msg.Payload...))
+}
+
+// TopicLevel makes name safe as one level of a topic, replacing the
+// separator and wildcards that would change its meaning.
+func TopicLevel(name string) string {
+	return strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(name)
+}
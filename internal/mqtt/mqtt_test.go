@@ -0,0 +1,375 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/goeth/internal/backoff"
+)
+
+// received is a packet as the fake broker reads it.
+type received struct {
+	header byte
+	body   []byte
+}
+
+// readPacket reads one packet; a zero header means the client hung up.
+func readPacket(r *bufio.Reader) received {
+	header, err := r.ReadByte()
+	if err != nil {
+		return received{}
+	}
+	n, err := readLength(r)
+	if err != nil {
+		return received{}
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return received{}
+	}
+	return received{header: header, body: body}
+}
+
+// str splits an MQTT string off b.
+func str(b []byte) (string, []byte) {
+	n := int(binary.BigEndian.Uint16(b))
+	return string(b[2 : 2+n]), b[2+n:]
+}
+
+// broker accepts connections on l, answering CONNECT with code and
+// acknowledging publications, and passes each packet it reads to got.
+func broker(l net.Listener, code byte, got chan<- received) {
+	serve(l, code, got, 0)
+}
+
+// serve is broker, except that it hangs up instead of answering the first
+// packet of type hangUp, as a broker cut off mid-exchange would.
+func serve(l net.Listener, code byte, got chan<- received, hangUp byte) {
+	var once sync.Once
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			for {
+				p := readPacket(r)
+				if p.header == 0 {
+					return
+				}
+				got <- p
+				hung := false
+				if p.header&0xf0 == hangUp {
+					once.Do(func() { hung = true })
+				}
+				if hung {
+					return
+				}
+				switch p.header & 0xf0 {
+				case packetConnect:
+					conn.Write([]byte{packetConnack, 2, 0, code})
+				case packetPublish:
+					qos := p.header >> 1 & 3
+					_, rest := str(p.body)
+					if qos == 1 {
+						conn.Write(append([]byte{packetPuback, 2}, rest[:2]...))
+					} else if qos == 2 {
+						conn.Write(append([]byte{packetPubrec, 2}, rest[:2]...))
+					}
+				case packetPubrel:
+					conn.Write(append([]byte{packetPubcomp, 2}, p.body...))
+				case packetDisconnect:
+					return
+				}
+			}
+		}()
+	}
+}
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func expect(t *testing.T, got <-chan received, typ byte) received {
+	t.Helper()
+	select {
+	case p := <-got:
+		if p.header&0xf0 != typ {
+			t.Fatalf("got packet %#x, want %#x", p.header, typ)
+		}
+		return p
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no packet %#x arrived", typ)
+	}
+	return received{}
+}
+
+// expectSession expects the clean connection a publisher with QoS 1 or 2
+// hangs up again and the one it keeps, returning the CONNECT of that. The
+// first may be seen hanging up after the second connected.
+func expectSession(t *testing.T, got <-chan received) received {
+	t.Helper()
+	var kept received
+	for range 2 {
+		select {
+		case p := <-got:
+			switch p.header & 0xf0 {
+			case packetConnect:
+				kept = p
+			case packetDisconnect:
+			default:
+				t.Fatalf("got packet %#x, want CONNECT or DISCONNECT", p.header)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no session was connected")
+		}
+	}
+	if kept.header == 0 {
+		t.Fatal("no second CONNECT arrived")
+	}
+	return kept
+}
+
+func TestPublisherPublishesWithEachQoS(t *testing.T) {
+	for qos := byte(0); qos <= maxQoS; qos++ {
+		l := listen(t)
+		got := make(chan received, 8)
+		go broker(l, 0, got)
+		publisher, err := Dial(Options{Broker: "mqtt://" + l.Addr().String(), ClientID: "goeth-edge-1", Username: "goeth", Password: "example-secret", QoS: qos, Retain: true}, 1)
+		if err != nil {
+			t.Fatalf("Dial() error = %v", err)
+		}
+		connect := expect(t, got, packetConnect)
+		name, rest := str(connect.body)
+		if name != "MQTT" || rest[0] != protocolLevel || rest[1] != flagClean|flagUsername|flagPassword {
+			t.Fatalf("unexpected CONNECT % x", connect.body)
+		}
+		clientID, rest := str(rest[4:])
+		user, rest := str(rest)
+		password, _ := str(rest)
+		if clientID != "goeth-edge-1" || user != "goeth" || password != "example-secret" {
+			t.Fatalf("CONNECT carries %q, %q, %q", clientID, user, password)
+		}
+		if qos > 0 {
+			// The clean session only drops what an earlier run left.
+			_, rest := str(expectSession(t, got).body)
+			if rest[1] != flagUsername|flagPassword {
+				t.Fatalf("QoS %d: CONNECT flags %#x, want a session kept across connections", qos, rest[1])
+			}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() { publisher.Run(ctx); close(done) }()
+		publisher.Send(Message{Topic: "goeth/eth0", Payload: []byte(`{"type":"link_down"}`)})
+		publish := expect(t, got, packetPublish)
+		if publish.header>>1&3 != qos || publish.header&retainFlag == 0 {
+			t.Errorf("QoS %d: PUBLISH header %#x", qos, publish.header)
+		}
+		topic, payload := str(publish.body)
+		if qos > 0 {
+			payload = payload[2:]
+		}
+		if topic != "goeth/eth0" || string(payload) != `{"type":"link_down"}` {
+			t.Errorf("QoS %d: published %q to %q", qos, payload, topic)
+		}
+		if qos == 2 {
+			expect(t, got, packetPubrel)
+		}
+		cancel()
+		<-done
+		expect(t, got, packetDisconnect)
+	}
+}
+
+func TestDialReportsRefusal(t *testing.T) {
+	l := listen(t)
+	go broker(l, 4, make(chan received, 8))
+	_, err := Dial(Options{Broker: "mqtt://" + l.Addr().String(), ClientID: "goeth"}, 1)
+	if err == nil || !strings.Contains(err.Error(), "bad user name or password") {
+		t.Fatalf("Dial() error = %v, want the refusal", err)
+	}
+}
+
+func TestDialChecksOptions(t *testing.T) {
+	for _, options := range []Options{
+		{Broker: "tcp://192.0.2.1"},
+		{Broker: "mqtt://"},
+		{Broker: "mqtt://192.0.2.1", QoS: 3},
+	} {
+		if _, err := Dial(options, 1); err == nil {
+			t.Errorf("Dial(%+v) accepted invalid options", options)
+		}
+	}
+}
+
+func TestPublisherReconnects(t *testing.T) {
+	defer func(policy backoff.Policy) { retryPolicy = policy }(retryPolicy)
+	retryPolicy = backoff.Policy{Backoff: time.Millisecond}
+	l := listen(t)
+	got := make(chan received, 8)
+	go broker(l, 0, got)
+	publisher, err := Dial(Options{Broker: "mqtt://" + l.Addr().String(), ClientID: "goeth", QoS: 1}, 1)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	expect(t, got, packetConnect)
+	expectSession(t, got)
+	// The broker restarted: the old connection is gone.
+	publisher.conn.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go publisher.Run(ctx)
+	publisher.Send(Message{Topic: "goeth/eth0", Payload: []byte("{}")})
+	expect(t, got, packetConnect)
+	expect(t, got, packetPublish)
+}
+
+func TestPublisherCompletesCutOffDeliveries(t *testing.T) {
+	defer func(policy backoff.Policy) { retryPolicy = policy }(retryPolicy)
+	retryPolicy = backoff.Policy{Backoff: time.Millisecond}
+	for _, tc := range []struct {
+		name   string
+		qos    byte
+		hangUp byte
+		// again is the packet sent on the new connection.
+		again byte
+	}{
+		{"PUBACK lost", 1, packetPublish, packetPublish},
+		{"PUBREC lost", 2, packetPublish, packetPublish},
+		{"PUBCOMP lost", 2, packetPubrel, packetPubrel},
+	} {
+		l := listen(t)
+		got := make(chan received, 16)
+		go serve(l, 0, got, tc.hangUp)
+		publisher, err := Dial(Options{Broker: "mqtt://" + l.Addr().String(), ClientID: "goeth", QoS: tc.qos}, 1)
+		if err != nil {
+			t.Fatalf("%s: Dial() error = %v", tc.name, err)
+		}
+		expect(t, got, packetConnect)
+		expectSession(t, got)
+		if err := publisher.publish(context.Background(), Message{Topic: "goeth/eth0", Payload: []byte("{}")}); err != nil {
+			t.Fatalf("%s: publish() error = %v", tc.name, err)
+		}
+		first := expect(t, got, packetPublish)
+		if tc.hangUp == packetPubrel {
+			expect(t, got, packetPubrel)
+		}
+		expect(t, got, packetConnect)
+		again := expect(t, got, tc.again)
+		if tc.again == packetPublish {
+			_, firstID := str(first.body)
+			_, againID := str(again.body)
+			if first.header&dupFlag != 0 || again.header&dupFlag == 0 || !bytes.Equal(firstID[:2], againID[:2]) {
+				t.Errorf("%s: sent PUBLISH %#x % x, then %#x % x; want DUP set on the second with the same packet ID", tc.name, first.header, firstID[:2], again.header, againID[:2])
+			}
+		}
+		if tc.qos == 2 && tc.again == packetPublish {
+			expect(t, got, packetPubrel)
+		}
+		publisher.disconnect()
+		expect(t, got, packetDisconnect)
+	}
+}
+
+func TestPacketEncodesLongLengths(t *testing.T) {
+	if got := packet(packetPublish, make([]byte, 200))[:3]; !bytes.Equal(got, []byte{packetPublish, 0xc8, 0x01}) {
+		t.Fatalf("header = % x", got)
+	}
+	if got, err := readLength(bytes.NewReader([]byte{0xc8, 0x01})); err != nil || got != 200 {
+		t.Fatalf("readLength() = %d, %v", got, err)
+	}
+}
+
+func TestTopicLevel(t *testing.T) {
+	if got := TopicLevel("odd+name#1"); got != "odd_name_1" {
+		t.Fatalf("TopicLevel() = %q", got)
+	}
+}
+
+// selfSigned writes a certificate for 127.0.0.1 to dir and returns it with
+// the path of its PEM file.
+func selfSigned(t *testing.T, dir string) (tls.Certificate, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	path := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, path
+}
+
+func TestDialOverTLS(t *testing.T) {
+	cert, caFile := selfSigned(t, t.TempDir())
+	l := tls.NewListener(listen(t), &tls.Config{Certificates: []tls.Certificate{cert}})
+	got := make(chan received, 8)
+	go broker(l, 0, got)
+	config, err := LoadTLS(caFile, "", "")
+	if err != nil {
+		t.Fatalf("LoadTLS() error = %v", err)
+	}
+	publisher, err := Dial(Options{Broker: "mqtts://" + l.Addr().String(), ClientID: "goeth", TLS: config}, 1)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer publisher.disconnect()
+	expect(t, got, packetConnect)
+
+	if _, err := Dial(Options{Broker: "mqtts://" + l.Addr().String(), ClientID: "goeth"}, 1); err == nil {
+		t.Fatal("Dial() trusted a broker outside the system roots")
+	}
+}
+
+func TestLoadTLSErrors(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.pem")
+	os.WriteFile(empty, nil, 0o600)
+	if _, err := LoadTLS(empty, "", ""); err == nil {
+		t.Fatal("LoadTLS() accepted a CA file without certificates")
+	}
+	if _, err := LoadTLS("", filepath.Join(dir, "missing.pem"), filepath.Join(dir, "missing.key")); err == nil {
+		t.Fatal("LoadTLS() accepted a missing client certificate")
+	}
+}
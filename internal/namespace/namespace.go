@@ -0,0 +1,53 @@
+// Package namespace runs code inside named network namespaces.
+package namespace
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+
+	"github.com/vishvananda/netns"
+)
+
+// Open returns a handle to the namespace created by "ip netns add name".
+// The caller closes it.
+func Open(name string) (netns.NsHandle, error) {
+	handle, err := netns.GetFromName(name)
+	if err != nil {
+		return netns.None(), fmt.Errorf("open netns %s: %w", name, err)
+	}
+	return handle, nil
+}
+
+// Do runs fn on an OS thread switched into ns and waits for it. Sockets fn
+// opens stay in ns after Do returns, but goroutines fn starts do not run in
+// ns. A handle that is not open, such as netns.None(), runs fn directly.
+func Do(ns netns.NsHandle, fn func() error) error {
+	if !ns.IsOpen() {
+		return fn()
+	}
+	errs := make(chan error, 1)
+	go func() {
+		// The thread stays locked on every error path below, so the runtime
+		// discards it with the goroutine instead of reusing it in ns.
+		runtime.LockOSThread()
+		origin, err := netns.Get()
+		if err != nil {
+			errs <- fmt.Errorf("get current netns: %w", err)
+			return
+		}
+		defer origin.Close()
+		if err := netns.Set(ns); err != nil {
+			errs <- fmt.Errorf("enter netns: %w", err)
+			return
+		}
+		err = fn()
+		if restoreErr := netns.Set(origin); restoreErr != nil {
+			errs <- errors.Join(err, fmt.Errorf("leave netns: %w", restoreErr))
+			return
+		}
+		runtime.UnlockOSThread()
+		errs <- err
+	}()
+	return <-errs
+}
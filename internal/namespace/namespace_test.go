@@ -0,0 +1,34 @@
+package namespace
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vishvananda/netns"
+)
+
+func TestDoRunsInPlaceWithoutNamespace(t *testing.T) {
+	ran := false
+	if err := Do(netns.None(), func() error {
+		ran = true
+		return nil
+	}); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if !ran {
+		t.Fatal("expected fn to run")
+	}
+}
+
+func TestDoReturnsFnError(t *testing.T) {
+	want := errors.New("boom")
+	if err := Do(netns.None(), func() error { return want }); !errors.Is(err, want) {
+		t.Fatalf("Do() error = %v, want %v", err, want)
+	}
+}
+
+func TestOpenReportsMissingNamespace(t *testing.T) {
+	if _, err := Open("goeth-test-missing"); err == nil {
+		t.Fatal("expected error for a namespace that does not exist")
+	}
+}
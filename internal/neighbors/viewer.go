@@ -0,0 +1,148 @@
+// Package neighbors describes the ARP and NDP entries of an interface, so
+// that they can be listed and compared the way addresses are.
+package neighbors
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+)
+
+// Neighbor is a neighbor table entry in the words of ip-neigh(8).
+type Neighbor struct {
+	IP string
+	// MAC is the link-layer address; empty while it is being resolved or
+	// after resolution failed.
+	MAC string
+	// State is the NUD state, such as reachable, stale or failed.
+	State string
+}
+
+// String describes n as ip-neigh(8) lists it.
+func (n Neighbor) String() string {
+	if n.MAC == "" {
+		return n.IP + " " + n.State
+	}
+	return n.IP + " lladdr " + n.MAC + " " + n.State
+}
+
+// Provider retrieves the neighbors of an interface.
+type Provider interface {
+	InterfaceNeighbors(name string) ([]Neighbor, error)
+}
+
+// Viewer exposes neighbor lookup behavior.
+type Viewer struct {
+	provider Provider
+}
+
+// NewViewer creates a Viewer backed by provider.
+func NewViewer(provider Provider) Viewer {
+	return Viewer{provider: provider}
+}
+
+// View returns the neighbors of the requested interface, sorted by IP.
+func (v Viewer) View(name string) ([]Neighbor, error) {
+	if v.provider == nil {
+		return nil, errors.New("neighbor provider is not configured")
+	}
+	if name == "" {
+		return nil, errors.New("interface name is required")
+	}
+	list, err := v.provider.InterfaceNeighbors(name)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].IP < list[j].IP })
+	return list, nil
+}
+
+// states names the NUD states, in the order ip-neigh(8) prints them.
+var states = []struct {
+	bit  int
+	name string
+}{
+	{unix.NUD_INCOMPLETE, "incomplete"},
+	{unix.NUD_REACHABLE, "reachable"},
+	{unix.NUD_STALE, "stale"},
+	{unix.NUD_DELAY, "delay"},
+	{unix.NUD_PROBE, "probe"},
+	{unix.NUD_FAILED, "failed"},
+	{unix.NUD_NOARP, "noarp"},
+	{unix.NUD_PERMANENT, "permanent"},
+}
+
+// Describe turns a netlink neighbor into a Neighbor.
+func Describe(neigh netlink.Neigh) Neighbor {
+	var names []string
+	for _, state := range states {
+		if neigh.State&state.bit != 0 {
+			names = append(names, state.name)
+		}
+	}
+	if len(names) == 0 {
+		names = append(names, "none")
+	}
+	described := Neighbor{IP: neigh.IP.String(), State: strings.Join(names, ",")}
+	if len(neigh.HardwareAddr) > 0 {
+		described.MAC = neigh.HardwareAddr.String()
+	}
+	return described
+}
+
+// Listed reports whether neigh is an entry Provider implementations list:
+// an IPv4 or IPv6 neighbor that is resolved, rather than one that needs no
+// resolution such as a multicast address.
+func Listed(neigh netlink.Neigh) bool {
+	if neigh.Family != netlink.FAMILY_V4 && neigh.Family != netlink.FAMILY_V6 {
+		return false
+	}
+	return neigh.IP != nil && neigh.State&unix.NUD_NOARP == 0
+}
+
+// NetlinkProvider lists neighbors through github.com/vishvananda/netlink.
+// The zero value reads the network namespace of the calling process.
+type NetlinkProvider struct {
+	handle *netlink.Handle
+}
+
+// NewNetlinkProviderAt returns a NetlinkProvider that reads inside ns. The
+// namespace handle must stay open for as long as the provider is used.
+func NewNetlinkProviderAt(ns netns.NsHandle) (NetlinkProvider, error) {
+	handle, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		return NetlinkProvider{}, fmt.Errorf("open netlink handle: %w", err)
+	}
+	return NetlinkProvider{handle: handle}, nil
+}
+
+func (p NetlinkProvider) nl() *netlink.Handle {
+	if p.handle == nil {
+		return &netlink.Handle{}
+	}
+	return p.handle
+}
+
+// InterfaceNeighbors returns the IPv4 and IPv6 neighbors of the interface.
+func (p NetlinkProvider) InterfaceNeighbors(name string) ([]Neighbor, error) {
+	link, err := p.nl().LinkByName(name)
+	if err != nil {
+		return nil, err
+	}
+	list, err := p.nl().NeighList(link.Attrs().Index, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, err
+	}
+	var described []Neighbor
+	for _, neigh := range list {
+		if Listed(neigh) {
+			described = append(described, Describe(neigh))
+		}
+	}
+	return described, nil
+}
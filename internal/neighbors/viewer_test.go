@@ -0,0 +1,76 @@
+package neighbors
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+type mockProvider struct {
+	neighbors map[string][]Neighbor
+	err       error
+}
+
+func (m mockProvider) InterfaceNeighbors(name string) ([]Neighbor, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return append([]Neighbor(nil), m.neighbors[name]...), nil
+}
+
+func TestViewerViewSortsByIP(t *testing.T) {
+	provider := mockProvider{neighbors: map[string][]Neighbor{"eth0": {{IP: "192.0.2.2", State: "stale"}, {IP: "192.0.2.1", State: "reachable"}}}}
+	got, err := NewViewer(provider).View("eth0")
+	if err != nil {
+		t.Fatalf("View() error = %v", err)
+	}
+	if want := []Neighbor{{IP: "192.0.2.1", State: "reachable"}, {IP: "192.0.2.2", State: "stale"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("View() = %v, want %v", got, want)
+	}
+}
+
+func TestViewerViewErrors(t *testing.T) {
+	if _, err := (Viewer{}).View("eth0"); err == nil {
+		t.Fatal("expected error without provider")
+	}
+	if _, err := NewViewer(mockProvider{}).View(""); err == nil {
+		t.Fatal("expected error without interface name")
+	}
+	boom := errors.New("boom")
+	if _, err := NewViewer(mockProvider{err: boom}).View("eth0"); !errors.Is(err, boom) {
+		t.Fatalf("expected provider error, got %v", err)
+	}
+}
+
+func TestDescribeFollowsIPNeigh(t *testing.T) {
+	mac, _ := net.ParseMAC("02:00:5e:00:53:01")
+	tests := []struct {
+		neigh netlink.Neigh
+		want  string
+	}{
+		{netlink.Neigh{IP: net.ParseIP("192.0.2.1"), HardwareAddr: mac, State: unix.NUD_REACHABLE}, "192.0.2.1 lladdr 02:00:5e:00:53:01 reachable"},
+		{netlink.Neigh{IP: net.ParseIP("2001:db8::1"), State: unix.NUD_FAILED}, "2001:db8::1 failed"},
+		{netlink.Neigh{IP: net.ParseIP("192.0.2.2"), HardwareAddr: mac}, "192.0.2.2 lladdr 02:00:5e:00:53:01 none"},
+	}
+	for _, tt := range tests {
+		if got := Describe(tt.neigh).String(); got != tt.want {
+			t.Errorf("Describe(%v) = %q, want %q", tt.neigh, got, tt.want)
+		}
+	}
+}
+
+func TestListedSkipsEntriesWithoutResolution(t *testing.T) {
+	if !Listed(netlink.Neigh{Family: netlink.FAMILY_V4, IP: net.ParseIP("192.0.2.1"), State: unix.NUD_STALE}) {
+		t.Fatal("a resolved IPv4 neighbor must be listed")
+	}
+	if Listed(netlink.Neigh{Family: netlink.FAMILY_V6, IP: net.ParseIP("ff02::1"), State: unix.NUD_NOARP}) {
+		t.Fatal("noarp entries must not be listed")
+	}
+	if Listed(netlink.Neigh{Family: unix.AF_BRIDGE, State: unix.NUD_PERMANENT}) {
+		t.Fatal("bridge fdb entries must not be listed")
+	}
+}
@@ -0,0 +1,138 @@
+// Package netlinkproc finds the process behind a netlink port id, so a change
+// announced over netlink can be attributed to the program that requested it.
+package netlinkproc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// protocolRoute is NETLINK_ROUTE, the protocol of link and address requests.
+const protocolRoute = 0
+
+// procRoot is where /proc is mounted. The netlink table is read through
+// thread-self so that it describes the network namespace of the caller.
+const (
+	procRoot     = "/proc"
+	netlinkTable = "/proc/thread-self/net/netlink"
+)
+
+// ErrNotFound is returned when no process holds the port, for example because
+// the socket was closed after the request.
+var ErrNotFound = errors.New("no process holds the netlink port")
+
+// Process identifies the holder of a netlink socket.
+type Process struct {
+	PID  int
+	Name string
+}
+
+func (p Process) String() string {
+	return fmt.Sprintf("%s (pid %d)", p.Name, p.PID)
+}
+
+// Owner returns the process holding the rtnetlink socket bound to portID.
+// Sockets of other users are only visible to root.
+func Owner(portID uint32) (Process, error) {
+	return owner(procRoot, netlinkTable, portID)
+}
+
+func owner(root, table string, portID uint32) (Process, error) {
+	inode, err := socketInode(table, portID)
+	if err != nil {
+		return Process{}, err
+	}
+	target := fmt.Sprintf("socket:[%s]", inode)
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return Process{}, err
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		fds, err := os.ReadDir(filepath.Join(root, entry.Name(), "fd"))
+		if err != nil {
+			// The process exited or belongs to another user.
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(root, entry.Name(), "fd", fd.Name()))
+			if err != nil || link != target {
+				continue
+			}
+			comm, err := os.ReadFile(filepath.Join(root, entry.Name(), "comm"))
+			if err != nil {
+				return Process{}, err
+			}
+			return Process{PID: pid, Name: strings.TrimSpace(string(comm))}, nil
+		}
+	}
+	return Process{}, fmt.Errorf("%w %d", ErrNotFound, portID)
+}
+
+// Running returns the processes whose command name is one of names, in pid
+// order. Command names are truncated by the kernel to 15 bytes.
+func Running(names ...string) ([]Process, error) {
+	return running(procRoot, names)
+}
+
+func running(root string, names []string) ([]Process, error) {
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var found []Process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		comm, err := os.ReadFile(filepath.Join(root, entry.Name(), "comm"))
+		if err != nil {
+			continue
+		}
+		if name := strings.TrimSpace(string(comm)); wanted[name] {
+			found = append(found, Process{PID: pid, Name: name})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].PID < found[j].PID })
+	return found, nil
+}
+
+// socketInode looks up the inode of the rtnetlink socket bound to portID in
+// the kernel's netlink table.
+func socketInode(table string, portID uint32) (string, error) {
+	file, err := os.Open(table)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		// sk Eth Pid Groups Rmem Wmem Dump Locks Drops Inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		if fields[1] == strconv.Itoa(protocolRoute) && fields[2] == strconv.FormatUint(uint64(portID), 10) {
+			return fields[9], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%w %d", ErrNotFound, portID)
+}
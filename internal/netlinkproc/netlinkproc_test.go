@@ -0,0 +1,78 @@
+package netlinkproc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const table = `sk               Eth Pid        Groups   Rmem     Wmem     Dump  Locks    Drops    Inode
+000000003ee786d8 0   0          00000000 0        0        0     2        0        4
+0000000030758396 0   812        00000551 0        0        0     2        0        5150
+000000005faf5893 6   812        00000000 0        0        0     2        0        5151
+0000000011111111 0   4294967001 00000000 0        0        0     2        0        6200
+`
+
+func fakeProc(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	tablePath := filepath.Join(dir, "netlink")
+	if err := os.WriteFile(tablePath, []byte(table), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "proc")
+	for pid, comm := range map[string]string{"812": "NetworkManager", "900": "sshd"} {
+		fd := filepath.Join(root, pid, "fd")
+		if err := os.MkdirAll(fd, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, pid, "comm"), []byte(comm+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink("/dev/null", filepath.Join(fd, "0")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("socket:[5150]", filepath.Join(root, "812", "fd", "7")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "self"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return root, tablePath
+}
+
+func TestOwner(t *testing.T) {
+	root, tablePath := fakeProc(t)
+	got, err := owner(root, tablePath, 812)
+	if err != nil {
+		t.Fatalf("owner() error = %v", err)
+	}
+	if want := (Process{PID: 812, Name: "NetworkManager"}); got != want {
+		t.Fatalf("owner() = %v, want %v", got, want)
+	}
+	if got.String() != "NetworkManager (pid 812)" {
+		t.Fatalf("String() = %q", got.String())
+	}
+}
+
+func TestOwnerNotFound(t *testing.T) {
+	root, tablePath := fakeProc(t)
+	for _, port := range []uint32{4242, 4294967001} {
+		if _, err := owner(root, tablePath, port); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("owner(%d) error = %v, want ErrNotFound", port, err)
+		}
+	}
+}
+
+func TestRunning(t *testing.T) {
+	root, _ := fakeProc(t)
+	got, err := running(root, []string{"NetworkManager", "systemd-network"})
+	if err != nil {
+		t.Fatalf("running() error = %v", err)
+	}
+	if want := []Process{{PID: 812, Name: "NetworkManager"}}; len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("running() = %v, want %v", got, want)
+	}
+}
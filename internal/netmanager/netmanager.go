@@ -0,0 +1,137 @@
+// Package netmanager tells whether a network management daemon controls an
+// interface, so goeth does not end up undoing its changes while it undoes
+// goeth's. Both supported daemons publish per-link state files under /run.
+package netmanager
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/user/goeth/internal/netlinkproc"
+)
+
+const runRoot = "/run"
+
+// Manager is a running daemon that controls an interface.
+type Manager struct {
+	Name string
+	PID  int
+	// Release tells how to make the daemon leave the interface alone.
+	Release string
+}
+
+func (m Manager) String() string {
+	return fmt.Sprintf("%s (pid %d)", m.Name, m.PID)
+}
+
+// daemon describes where a network manager records the links it manages.
+type daemon struct {
+	name string
+	// comm is the command name of the running daemon, truncated by the
+	// kernel to 15 bytes.
+	comm string
+	// links is the directory of state files named by interface index.
+	links   string
+	managed func(path string) (bool, error)
+	release func(iface string) string
+}
+
+var daemons = []daemon{
+	{
+		name:    "NetworkManager",
+		comm:    "NetworkManager",
+		links:   "NetworkManager/devices",
+		managed: networkManagerManaged,
+		release: func(iface string) string { return "run nmcli device set " + iface + " managed no" },
+	},
+	{
+		name:    "systemd-networkd",
+		comm:    "systemd-network",
+		links:   "systemd/netif/links",
+		managed: networkdManaged,
+		release: func(string) string { return "set Unmanaged=yes in the [Link] section of its .network file" },
+	},
+}
+
+// networkdStates are the ADMIN_STATE values of links systemd-networkd has
+// taken over; links still pending, left unmanaged or gone are not listed.
+var networkdStates = map[string]bool{"configuring": true, "configured": true, "failed": true}
+
+// Managing returns the running daemons that manage the interface with the
+// given index and name in the network namespace of the host. State files left
+// behind by a daemon that no longer runs are ignored.
+func Managing(index int, iface string) ([]Manager, error) {
+	return managing(runRoot, netlinkproc.Running, index, iface)
+}
+
+func managing(run string, running func(names ...string) ([]netlinkproc.Process, error), index int, iface string) ([]Manager, error) {
+	var found []Manager
+	for _, d := range daemons {
+		ok, err := d.managed(filepath.Join(run, d.links, strconv.Itoa(index)))
+		if err != nil {
+			return nil, fmt.Errorf("%s state of %s: %w", d.name, iface, err)
+		}
+		if !ok {
+			continue
+		}
+		processes, err := running(d.comm)
+		if err != nil {
+			return nil, err
+		}
+		if len(processes) == 0 {
+			continue
+		}
+		found = append(found, Manager{Name: d.name, PID: processes[0].PID, Release: d.release(iface)})
+	}
+	return found, nil
+}
+
+// networkManagerManaged reads the keyfile NetworkManager keeps for a device,
+// whose [device] section holds managed=true while it controls the device.
+func networkManagerManaged(path string) (bool, error) {
+	values, err := readValues(path)
+	if err != nil {
+		return false, err
+	}
+	return values["managed"] == "true", nil
+}
+
+// networkdManaged reads the environment-style file systemd-networkd keeps for
+// a link.
+func networkdManaged(path string) (bool, error) {
+	values, err := readValues(path)
+	if err != nil {
+		return false, err
+	}
+	return networkdStates[values["ADMIN_STATE"]], nil
+}
+
+// readValues reads key=value lines, skipping comments and section headers. A
+// missing file yields no values.
+func readValues(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	values := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return values, scanner.Err()
+}
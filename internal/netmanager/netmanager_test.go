@@ -0,0 +1,90 @@
+package netmanager
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/user/goeth/internal/netlinkproc"
+)
+
+const nmDevice = `# NetworkManager runtime state
+[device]
+managed=true
+perm-hw-address-fake=false
+connection-uuid=9f1c2f8e-3a4b-4c5d-8e6f-0a1b2c3d4e5f
+`
+
+const networkdLink = `# This is private data. Do not parse.
+ADMIN_STATE=configured
+OPER_STATE=routable
+`
+
+func writeState(t *testing.T, run, rel, content string) {
+	t.Helper()
+	path := filepath.Join(run, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func runningOf(processes ...netlinkproc.Process) func(names ...string) ([]netlinkproc.Process, error) {
+	return func(names ...string) ([]netlinkproc.Process, error) {
+		var found []netlinkproc.Process
+		for _, process := range processes {
+			for _, name := range names {
+				if process.Name == name {
+					found = append(found, process)
+				}
+			}
+		}
+		return found, nil
+	}
+}
+
+func TestManaging(t *testing.T) {
+	run := t.TempDir()
+	writeState(t, run, "NetworkManager/devices/2", nmDevice)
+	writeState(t, run, "systemd/netif/links/2", networkdLink)
+	writeState(t, run, "NetworkManager/devices/3", "[device]\nmanaged=false\n")
+	writeState(t, run, "systemd/netif/links/3", "ADMIN_STATE=unmanaged\n")
+	running := runningOf(
+		netlinkproc.Process{PID: 431, Name: "systemd-network"},
+		netlinkproc.Process{PID: 812, Name: "NetworkManager"},
+	)
+
+	got, err := managing(run, running, 2, "eth0")
+	if err != nil {
+		t.Fatalf("managing() error = %v", err)
+	}
+	want := []Manager{
+		{Name: "NetworkManager", PID: 812, Release: "run nmcli device set eth0 managed no"},
+		{Name: "systemd-networkd", PID: 431, Release: "set Unmanaged=yes in the [Link] section of its .network file"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("managing() = %+v, want %+v", got, want)
+	}
+	if got[0].String() != "NetworkManager (pid 812)" {
+		t.Fatalf("String() = %q", got[0].String())
+	}
+
+	for _, index := range []int{3, 4} {
+		if got, err := managing(run, running, index, "eth1"); err != nil || len(got) != 0 {
+			t.Fatalf("managing(%d) = %v, %v; want no managers", index, got, err)
+		}
+	}
+}
+
+func TestManagingIgnoresStaleState(t *testing.T) {
+	run := t.TempDir()
+	writeState(t, run, "NetworkManager/devices/2", nmDevice)
+	writeState(t, run, "systemd/netif/links/2", networkdLink)
+	got, err := managing(run, runningOf(netlinkproc.Process{PID: 900, Name: "sshd"}), 2, "eth0")
+	if err != nil || len(got) != 0 {
+		t.Fatalf("managing() = %v, %v; want no managers", got, err)
+	}
+}
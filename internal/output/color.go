@@ -0,0 +1,62 @@
+package output
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Change says how a line of output changes the state, which decides its
+// color.
+type Change int
+
+// Kinds of Change. Unchanged lines are never colored.
+const (
+	Unchanged Change = iota
+	Added
+	Removed
+	Updated
+)
+
+// changeColors are the SGR codes of each Change: green additions, red
+// removals and yellow updates, as diff tools color them.
+var changeColors = map[Change]string{
+	Added:   "32",
+	Removed: "31",
+	Updated: "33",
+}
+
+// Colorizer colors lines by the change they describe. The zero value
+// leaves them alone, as output that is not read on a terminal wants.
+type Colorizer struct {
+	Enabled bool
+}
+
+// NewColorizer colors output written to w when w is a terminal, unless
+// disabled is set, NO_COLOR is set or TERM is dumb.
+func NewColorizer(w io.Writer, disabled bool) Colorizer {
+	if disabled || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return Colorizer{}
+	}
+	return Colorizer{Enabled: IsTerminal(w)}
+}
+
+// IsTerminal reports whether w is a terminal.
+func IsTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	_, err := unix.IoctlGetTermios(int(file.Fd()), unix.TCGETS)
+	return err == nil
+}
+
+// Paint returns s in the color of change.
+func (c Colorizer) Paint(change Change, s string) string {
+	code, ok := changeColors[change]
+	if !c.Enabled || !ok {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
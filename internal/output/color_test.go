@@ -0,0 +1,62 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestColorizerPaintsChanges(t *testing.T) {
+	c := Colorizer{Enabled: true}
+	tests := []struct {
+		change Change
+		want   string
+	}{
+		{Added, "\x1b[32meth0\x1b[0m"},
+		{Removed, "\x1b[31meth0\x1b[0m"},
+		{Updated, "\x1b[33meth0\x1b[0m"},
+		{Unchanged, "eth0"},
+	}
+	for _, tt := range tests {
+		if got := c.Paint(tt.change, "eth0"); got != tt.want {
+			t.Errorf("Paint(%d) = %q, want %q", tt.change, got, tt.want)
+		}
+	}
+	if got := (Colorizer{}).Paint(Added, "eth0"); got != "eth0" {
+		t.Fatalf("a disabled Colorizer painted %q", got)
+	}
+}
+
+func TestNewColorizerOnlyColorsTerminals(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "xterm")
+	if NewColorizer(&strings.Builder{}, false).Enabled {
+		t.Fatal("colored output that is not a file")
+	}
+	file, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	defer file.Close()
+	if NewColorizer(file, false).Enabled {
+		t.Fatal("colored output redirected to a file")
+	}
+}
+
+func TestNewColorizerHonorsOptOuts(t *testing.T) {
+	// The checks precede terminal detection, so any writer shows them.
+	t.Setenv("NO_COLOR", "1")
+	if NewColorizer(os.Stdout, false).Enabled {
+		t.Fatal("NO_COLOR did not disable colors")
+	}
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "dumb")
+	if NewColorizer(os.Stdout, false).Enabled {
+		t.Fatal("TERM=dumb did not disable colors")
+	}
+	t.Setenv("TERM", "xterm")
+	if NewColorizer(os.Stdout, true).Enabled {
+		t.Fatal("--no-color did not disable colors")
+	}
+}
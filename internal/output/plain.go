@@ -0,0 +1,123 @@
+// Package output adapts command output to the terminal it is written to.
+package output
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+const (
+	tabWidth = 8
+	escape   = 0x1b
+	del      = 0x7f
+)
+
+// transliterations maps the non-ASCII runes goeth prints to ASCII spellings.
+var transliterations = map[rune]string{
+	'→':      "->",
+	'←':      "<-",
+	'↔':      "<->",
+	'…':      "...",
+	'—':      "--",
+	'–':      "-",
+	'‘':      "'",
+	'’':      "'",
+	'“':      `"`,
+	'”':      `"`,
+	'•':      "*",
+	'×':      "x",
+	'✓':      "ok",
+	'✗':      "x",
+	'\u00a0': " ",
+	'├':      "|",
+	'└':      "`",
+	'│':      "|",
+	'─':      "-",
+}
+
+type escapeState int
+
+const (
+	escapeNone escapeState = iota
+	escapeStart
+	escapeCSI
+)
+
+// PlainWriter forwards output to a writer as printable ASCII only, for serial
+// consoles and IPMI SOL sessions that mangle anything else. Known symbols are
+// transliterated, other non-ASCII runes become '?', ANSI escape sequences are
+// dropped and tabs are expanded to spaces so columns survive any terminal.
+type PlainWriter struct {
+	w       io.Writer
+	pending []byte
+	escape  escapeState
+	column  int
+}
+
+// NewPlainWriter wraps w.
+func NewPlainWriter(w io.Writer) *PlainWriter {
+	return &PlainWriter{w: w}
+}
+
+// Write converts p and writes the result. Incomplete UTF-8 sequences at the
+// end of p are held back until the next call.
+func (p *PlainWriter) Write(b []byte) (int, error) {
+	data := append(p.pending, b...)
+	p.pending = nil
+	out := make([]byte, 0, len(data))
+	for len(data) > 0 {
+		if data[0] >= utf8.RuneSelf && !utf8.FullRune(data) {
+			p.pending = append([]byte(nil), data...)
+			break
+		}
+		r, size := utf8.DecodeRune(data)
+		data = data[size:]
+		out = p.appendRune(out, r)
+	}
+	if _, err := p.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (p *PlainWriter) appendRune(out []byte, r rune) []byte {
+	switch p.escape {
+	case escapeStart:
+		p.escape = escapeNone
+		if r == '[' {
+			p.escape = escapeCSI
+		}
+		return out
+	case escapeCSI:
+		if r >= 0x40 && r <= 0x7e {
+			p.escape = escapeNone
+		}
+		return out
+	}
+	switch {
+	case r == escape:
+		p.escape = escapeStart
+		return out
+	case r == '\n':
+		p.column = 0
+		return append(out, '\n')
+	case r == '\t':
+		spaces := tabWidth - p.column%tabWidth
+		for i := 0; i < spaces; i++ {
+			out = append(out, ' ')
+		}
+		p.column += spaces
+		return out
+	case r < ' ' || r == del:
+		return out
+	case r < utf8.RuneSelf:
+		p.column++
+		return append(out, byte(r))
+	}
+	replacement, ok := transliterations[r]
+	if !ok {
+		replacement = "?"
+	}
+	p.column += len(replacement)
+	return append(out, replacement...)
+}
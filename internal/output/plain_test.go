@@ -0,0 +1,73 @@
+package output
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func plain(t *testing.T, chunks ...string) string {
+	t.Helper()
+	var buf strings.Builder
+	w := NewPlainWriter(&buf)
+	for _, chunk := range chunks {
+		n, err := w.Write([]byte(chunk))
+		if err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if n != len(chunk) {
+			t.Fatalf("Write() = %d, want %d", n, len(chunk))
+		}
+	}
+	return buf.String()
+}
+
+func TestPlainWriterTransliterates(t *testing.T) {
+	if got := plain(t, "MTU 1500→9000 … done ✓\n"); got != "MTU 1500->9000 ... done ok\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
+
+func TestPlainWriterKeepsTreesAligned(t *testing.T) {
+	if got := plain(t, "br0\n│   ├── eth0\n└── eth1\n"); got != "br0\n|   |-- eth0\n`-- eth1\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
+
+func TestPlainWriterReplacesUnknownRunes(t *testing.T) {
+	if got := plain(t, "eth0 ünïcode 🚀\n"); got != "eth0 ?n?code ?\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
+
+func TestPlainWriterStripsEscapeSequences(t *testing.T) {
+	if got := plain(t, "\x1b[1;31mdown\x1b[0m\r\n"); got != "down\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
+
+func TestPlainWriterExpandsTabs(t *testing.T) {
+	if got := plain(t, "ab\tc\n\td\n"); got != "ab      c\n        d\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
+
+func TestPlainWriterHandlesSplitRunes(t *testing.T) {
+	arrow := "→"
+	if got := plain(t, "a"+arrow[:1], arrow[1:]+"b\n"); got != "a->b\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+	if got := plain(t, "x\x1b[3", "2my\n"); got != "xy\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("boom") }
+
+func TestPlainWriterPropagatesErrors(t *testing.T) {
+	if _, err := NewPlainWriter(failingWriter{}).Write([]byte("x")); err == nil {
+		t.Fatal("expected write error")
+	}
+}
@@ -0,0 +1,213 @@
+// Package pmtu discovers the usable path MTU towards a host by sending
+// ICMP echo requests with the Don't Fragment bit set and searching for the
+// largest one that is answered. Unlike kernel PMTU discovery it does not rely
+// on "fragmentation needed" errors, which are often filtered on tunneled or
+// PPPoE uplinks. The same echo requests tell whether a host answers at all.
+package pmtu
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/user/goeth/internal/checksum"
+)
+
+const (
+	// DefaultTimeout is used when Prober.Timeout is zero.
+	DefaultTimeout = time.Second
+	// MinMTU is the smallest size probed; every IPv4 path must carry 576 bytes.
+	MinMTU = 576
+	// pingSize is the size of the packets Reachable sends, as ping(8) sends
+	// them.
+	pingSize = 84
+
+	ipv4HeaderLen = 20
+	icmpHeaderLen = 8
+	icmpEchoReq   = 8
+	icmpEchoReply = 0
+	// attempts is how many echo requests are sent per size before treating
+	// it as lost, so a single dropped packet does not lower the result.
+	attempts = 2
+)
+
+// Prober sends probes through a specific network device.
+type Prober struct {
+	// Timeout bounds the wait for each echo reply; zero means DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Probe returns the largest packet size between MinMTU and max that reaches
+// target through device.
+func (p Prober) Probe(device string, target net.IP, max int) (int, error) {
+	dst := target.To4()
+	if dst == nil {
+		return 0, fmt.Errorf("probe target %s is not an IPv4 address", target)
+	}
+	if max < MinMTU {
+		return 0, fmt.Errorf("probe ceiling %d is below the minimum of %d", max, MinMTU)
+	}
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	conn, err := openConn(device, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer unix.Close(conn)
+	addr := &unix.SockaddrInet4{}
+	copy(addr.Addr[:], dst)
+	id := uint16(os.Getpid())
+	seq := uint16(0)
+	return search(MinMTU, max, func(size int) (bool, error) {
+		for i := 0; i < attempts; i++ {
+			seq++
+			ok, err := echo(conn, addr, id, seq, size)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	})
+}
+
+// Reachable reports whether target answers an echo request through device.
+// A host the kernel cannot resolve on the link is unreachable rather than
+// an error.
+func (p Prober) Reachable(device string, target net.IP) (bool, error) {
+	dst := target.To4()
+	if dst == nil {
+		return false, fmt.Errorf("probe target %s is not an IPv4 address", target)
+	}
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	conn, err := openConn(device, timeout)
+	if err != nil {
+		return false, err
+	}
+	defer unix.Close(conn)
+	addr := &unix.SockaddrInet4{}
+	copy(addr.Addr[:], dst)
+	id := uint16(os.Getpid())
+	for seq := uint16(1); seq <= attempts; seq++ {
+		ok, err := echo(conn, addr, id, seq, pingSize)
+		if errors.Is(err, unix.EHOSTUNREACH) || errors.Is(err, unix.ENETUNREACH) {
+			return false, nil
+		}
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+func openConn(device string, timeout time.Duration) (int, error) {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.IPPROTO_ICMP)
+	if err != nil {
+		return -1, fmt.Errorf("open icmp socket: %w", err)
+	}
+	tv := unix.NsecToTimeval(timeout.Nanoseconds())
+	for _, step := range []func() error{
+		func() error { return unix.BindToDevice(fd, device) },
+		func() error {
+			return unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_PROBE)
+		},
+		func() error { return unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv) },
+	} {
+		if err := step(); err != nil {
+			unix.Close(fd)
+			return -1, fmt.Errorf("configure icmp socket on %s: %w", device, err)
+		}
+	}
+	return fd, nil
+}
+
+// echo sends one echo request making an IP packet of size bytes and reports
+// whether the matching reply arrived before the receive timeout.
+func echo(fd int, addr *unix.SockaddrInet4, id, seq uint16, size int) (bool, error) {
+	if err := unix.Sendto(fd, echoRequest(id, seq, size-ipv4HeaderLen), 0, addr); err != nil {
+		if errors.Is(err, unix.EMSGSIZE) {
+			return false, nil
+		}
+		return false, fmt.Errorf("send probe: %w", err)
+	}
+	buf := make([]byte, size+ipv4HeaderLen)
+	for {
+		n, from, err := unix.Recvfrom(fd, buf, 0)
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("receive probe reply: %w", err)
+		}
+		src, ok := from.(*unix.SockaddrInet4)
+		if ok && src.Addr == addr.Addr && isEchoReply(buf[:n], id, seq) {
+			return true, nil
+		}
+	}
+}
+
+// echoRequest builds an ICMP echo request of length bytes.
+func echoRequest(id, seq uint16, length int) []byte {
+	msg := make([]byte, length)
+	msg[0] = icmpEchoReq
+	binary.BigEndian.PutUint16(msg[4:6], id)
+	binary.BigEndian.PutUint16(msg[6:8], seq)
+	binary.BigEndian.PutUint16(msg[2:4], checksum.Internet(msg))
+	return msg
+}
+
+// isEchoReply reports whether packet (including its IP header, as delivered
+// by raw sockets) is the reply to the request id/seq.
+func isEchoReply(packet []byte, id, seq uint16) bool {
+	if len(packet) < ipv4HeaderLen {
+		return false
+	}
+	headerLen := int(packet[0]&0x0f) * 4
+	if len(packet) < headerLen+icmpHeaderLen {
+		return false
+	}
+	msg := packet[headerLen:]
+	return msg[0] == icmpEchoReply &&
+		binary.BigEndian.Uint16(msg[4:6]) == id &&
+		binary.BigEndian.Uint16(msg[6:8]) == seq
+}
+
+// search returns the largest size in [lo, hi] for which fits is true,
+// assuming fits is monotonic. It fails when even lo does not fit.
+func search(lo, hi int, fits func(int) (bool, error)) (int, error) {
+	ok, err := fits(hi)
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		return hi, nil
+	}
+	if ok, err = fits(lo); err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("no reply to %d-byte probes", lo)
+	}
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		ok, err := fits(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
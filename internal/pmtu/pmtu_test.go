@@ -0,0 +1,75 @@
+package pmtu
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/user/goeth/internal/checksum"
+)
+
+func TestSearchFindsLargestFittingSize(t *testing.T) {
+	probes := 0
+	got, err := search(MinMTU, 1500, func(size int) (bool, error) {
+		probes++
+		return size <= 1492, nil
+	})
+	if err != nil {
+		t.Fatalf("search() error = %v", err)
+	}
+	if got != 1492 {
+		t.Fatalf("search() = %d, want 1492", got)
+	}
+	if probes > 12 {
+		t.Fatalf("search() used %d probes, expected a binary search", probes)
+	}
+}
+
+func TestSearchAcceptsCeiling(t *testing.T) {
+	got, err := search(MinMTU, 1500, func(int) (bool, error) { return true, nil })
+	if err != nil || got != 1500 {
+		t.Fatalf("search() = %d, %v; want 1500", got, err)
+	}
+}
+
+func TestSearchFailsWithoutReplies(t *testing.T) {
+	if _, err := search(MinMTU, 1500, func(int) (bool, error) { return false, nil }); err == nil {
+		t.Fatal("expected error when nothing is answered")
+	}
+	boom := errors.New("boom")
+	if _, err := search(MinMTU, 1500, func(int) (bool, error) { return false, boom }); !errors.Is(err, boom) {
+		t.Fatalf("expected probe error to propagate, got %v", err)
+	}
+}
+
+func TestEchoRequestChecksum(t *testing.T) {
+	msg := echoRequest(0x1234, 7, 64)
+	if len(msg) != 64 || msg[0] != icmpEchoReq {
+		t.Fatalf("unexpected echo request: %v", msg)
+	}
+	if checksum.Internet(msg) != 0 {
+		t.Fatal("checksum over a valid message must be zero")
+	}
+}
+
+func TestIsEchoReply(t *testing.T) {
+	reply := make([]byte, ipv4HeaderLen+icmpHeaderLen)
+	reply[0] = 0x45
+	copy(reply[ipv4HeaderLen:], echoRequest(9, 3, icmpHeaderLen))
+	reply[ipv4HeaderLen] = icmpEchoReply
+	if !isEchoReply(reply, 9, 3) {
+		t.Fatal("expected reply to match")
+	}
+	if isEchoReply(reply, 9, 4) || isEchoReply(reply[:10], 9, 3) {
+		t.Fatal("unexpected match")
+	}
+}
+
+func TestProbeValidatesArguments(t *testing.T) {
+	if _, err := (Prober{}).Probe("eth0", net.ParseIP("2001:db8::1"), 1500); err == nil {
+		t.Fatal("expected error for IPv6 target")
+	}
+	if _, err := (Prober{}).Probe("eth0", net.ParseIP("192.0.2.1"), 100); err == nil {
+		t.Fatal("expected error for ceiling below the minimum")
+	}
+}
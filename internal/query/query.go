@@ -0,0 +1,465 @@
+// Package query evaluates a small subset of the jq language against the
+// structured output of goeth, so scripts on minimal systems need no jq.
+//
+// Supported are paths (., .name, .a.b, .["key"], .[2], .[-1], .links[]),
+// pipes and select(cond), where cond is a pipeline optionally compared with
+// ==, !=, <, <=, > or >= to a literal string, number, true, false or null.
+// Unlike jq, select passes its input at most once, when any value produced by
+// cond is true, so select(.flags[] == "up") keeps each matching item once.
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// filter maps one input value to any number of outputs, as in jq.
+type filter func(v any) ([]any, error)
+
+// Query is a parsed expression.
+type Query struct {
+	expr string
+	run  filter
+}
+
+// Parse compiles expr.
+func Parse(expr string) (Query, error) {
+	p := &parser{src: expr}
+	run, err := p.pipeline()
+	if err == nil {
+		p.space()
+		if !p.done() {
+			err = p.errorf("unexpected %s", p.found())
+		}
+	}
+	if err != nil {
+		return Query{}, fmt.Errorf("query %q: %w", expr, err)
+	}
+	return Query{expr: expr, run: run}, nil
+}
+
+// Eval applies the query to the JSON form of v.
+func (q Query) Eval(v any) ([]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	results, err := q.run(doc)
+	if err != nil {
+		return nil, fmt.Errorf("query %q: %w", q.expr, err)
+	}
+	return results, nil
+}
+
+// Print writes one result per line: strings as they are, so they can be used
+// in shell pipelines directly, and everything else as compact JSON.
+func Print(w io.Writer, results []any) error {
+	for _, result := range results {
+		line, ok := result.(string)
+		if !ok {
+			data, err := json.Marshal(result)
+			if err != nil {
+				return err
+			}
+			line = string(data)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type parser struct {
+	src string
+	pos int
+}
+
+func (p *parser) done() bool   { return p.pos >= len(p.src) }
+func (p *parser) rest() string { return p.src[p.pos:] }
+
+func (p *parser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) space() {
+	for !p.done() && strings.IndexByte(" \t\r\n", p.peek()) >= 0 {
+		p.pos++
+	}
+}
+
+// found describes the unparsed input for error messages.
+func (p *parser) found() string {
+	if p.done() {
+		return "end of expression"
+	}
+	return strconv.Quote(p.rest())
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("offset %d: "+format, append([]any{p.pos}, args...)...)
+}
+
+func (p *parser) expect(c byte) error {
+	p.space()
+	if p.peek() != c {
+		return p.errorf("expected %q, got %s", c, p.found())
+	}
+	p.pos++
+	return nil
+}
+
+// pipeline parses term ('|' term)*.
+func (p *parser) pipeline() (filter, error) {
+	run, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.space()
+		if p.peek() != '|' {
+			return run, nil
+		}
+		p.pos++
+		next, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		run = pipe(run, next)
+	}
+}
+
+func pipe(first, second filter) filter {
+	return func(v any) ([]any, error) {
+		inputs, err := first(v)
+		if err != nil {
+			return nil, err
+		}
+		var out []any
+		for _, input := range inputs {
+			results, err := second(input)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, results...)
+		}
+		return out, nil
+	}
+}
+
+func (p *parser) term() (filter, error) {
+	p.space()
+	if p.peek() == '.' {
+		return p.path()
+	}
+	start := p.pos
+	if word := p.ident(); word == "select" {
+		return p.selectTerm()
+	}
+	p.pos = start
+	return nil, p.errorf("expected a path or select, got %s", p.found())
+}
+
+func isIdentByte(c byte, first bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || !first && c >= '0' && c <= '9'
+}
+
+func (p *parser) ident() string {
+	start := p.pos
+	for !p.done() && isIdentByte(p.peek(), p.pos == start) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// path parses '.' followed by field names and bracket suffixes.
+func (p *parser) path() (filter, error) {
+	p.pos++ // '.'
+	var steps []filter
+	if isIdentByte(p.peek(), true) {
+		steps = append(steps, field(p.ident()))
+	}
+	for {
+		switch p.peek() {
+		case '.':
+			p.pos++
+			if !isIdentByte(p.peek(), true) {
+				return nil, p.errorf("expected a field name after '.'")
+			}
+			steps = append(steps, field(p.ident()))
+		case '[':
+			p.pos++
+			step, err := p.bracket()
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+		default:
+			run := filter(func(v any) ([]any, error) { return []any{v}, nil })
+			for _, step := range steps {
+				run = pipe(run, step)
+			}
+			return run, nil
+		}
+	}
+}
+
+// bracket parses what follows '[': ']' iterates, a number indexes an array
+// and a string looks up a key.
+func (p *parser) bracket() (filter, error) {
+	p.space()
+	var step filter
+	switch c := p.peek(); {
+	case c == ']':
+		step = iterate
+	case c == '"':
+		key, err := p.stringLiteral()
+		if err != nil {
+			return nil, err
+		}
+		step = field(key)
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.pos
+		p.pos++
+		for p.peek() >= '0' && p.peek() <= '9' {
+			p.pos++
+		}
+		n, err := strconv.Atoi(p.src[start:p.pos])
+		if err != nil {
+			return nil, p.errorf("invalid index %q", p.src[start:p.pos])
+		}
+		step = index(n)
+	default:
+		return nil, p.errorf("expected ], an index or a quoted key, got %s", p.found())
+	}
+	return step, p.expect(']')
+}
+
+func field(name string) filter {
+	return func(v any) ([]any, error) {
+		switch v := v.(type) {
+		case nil:
+			return []any{nil}, nil
+		case map[string]any:
+			return []any{v[name]}, nil
+		}
+		return nil, fmt.Errorf("cannot look up %q in %s", name, kind(v))
+	}
+}
+
+func index(n int) filter {
+	return func(v any) ([]any, error) {
+		switch v := v.(type) {
+		case nil:
+			return []any{nil}, nil
+		case []any:
+			if n < 0 {
+				n += len(v)
+			}
+			if n < 0 || n >= len(v) {
+				return []any{nil}, nil
+			}
+			return []any{v[n]}, nil
+		}
+		return nil, fmt.Errorf("cannot index %s with %d", kind(v), n)
+	}
+}
+
+func iterate(v any) ([]any, error) {
+	switch v := v.(type) {
+	case []any:
+		return v, nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		out := make([]any, 0, len(keys))
+		for _, key := range keys {
+			out = append(out, v[key])
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("cannot iterate over %s", kind(v))
+}
+
+// comparisons lists the operators of select, two-character ones first.
+var comparisons = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// selectTerm parses the rest of select(cond [op literal]).
+func (p *parser) selectTerm() (filter, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	cond, err := p.pipeline()
+	if err != nil {
+		return nil, err
+	}
+	p.space()
+	op := ""
+	for _, candidate := range comparisons {
+		if strings.HasPrefix(p.rest(), candidate) {
+			op = candidate
+			p.pos += len(candidate)
+			break
+		}
+	}
+	var want any
+	if op != "" {
+		if want, err = p.literal(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	return func(v any) ([]any, error) {
+		values, err := cond(v)
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			ok, err := matches(value, op, want)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return []any{v}, nil
+			}
+		}
+		return nil, nil
+	}, nil
+}
+
+func matches(value any, op string, want any) (bool, error) {
+	switch op {
+	case "":
+		return value != nil && value != false, nil
+	case "==":
+		return reflect.DeepEqual(value, want), nil
+	case "!=":
+		return !reflect.DeepEqual(value, want), nil
+	}
+	var cmp int
+	switch a := value.(type) {
+	case float64:
+		b, ok := want.(float64)
+		if !ok {
+			return false, fmt.Errorf("cannot compare a number with %s", kind(want))
+		}
+		cmp = compare(a, b)
+	case string:
+		b, ok := want.(string)
+		if !ok {
+			return false, fmt.Errorf("cannot compare a string with %s", kind(want))
+		}
+		cmp = strings.Compare(a, b)
+	case nil:
+		// Missing values never satisfy an ordering.
+		return false, nil
+	default:
+		return false, fmt.Errorf("cannot order %s", kind(value))
+	}
+	switch op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	}
+	return cmp >= 0, nil
+}
+
+func compare(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func (p *parser) literal() (any, error) {
+	p.space()
+	switch c := p.peek(); {
+	case c == '"':
+		return p.stringLiteral()
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.pos
+		for !p.done() && strings.IndexByte("+-.eE0123456789", p.peek()) >= 0 {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil || math.IsInf(n, 0) {
+			return nil, p.errorf("invalid number %q", p.src[start:p.pos])
+		}
+		return n, nil
+	}
+	start := p.pos
+	switch word := p.ident(); word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	p.pos = start
+	return nil, p.errorf("expected a string, number, true, false or null, got %s", p.found())
+}
+
+// stringLiteral parses a JSON string starting at the opening quote.
+func (p *parser) stringLiteral() (string, error) {
+	start := p.pos
+	p.pos++
+	for !p.done() && p.peek() != '"' {
+		if p.peek() == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if p.done() {
+		p.pos = start
+		return "", p.errorf("unterminated string")
+	}
+	p.pos++
+	var s string
+	if err := json.Unmarshal([]byte(p.src[start:p.pos]), &s); err != nil {
+		p.pos = start
+		return "", p.errorf("invalid string: %v", err)
+	}
+	return s, nil
+}
+
+// kind names the JSON type of v for error messages.
+func kind(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case float64:
+		return "a number"
+	case string:
+		return "a string"
+	case []any:
+		return "an array"
+	}
+	return "an object"
+}
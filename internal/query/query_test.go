@@ -0,0 +1,114 @@
+package query
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type link struct {
+	Name  string   `json:"name"`
+	MAC   string   `json:"mac"`
+	MTU   int      `json:"mtu"`
+	Flags []string `json:"flags"`
+}
+
+var links = []link{
+	{Name: "eth0", MAC: "02:00:00:00:00:01", MTU: 1500, Flags: []string{"up", "broadcast", "running"}},
+	{Name: "eth1", MAC: "02:00:00:00:00:02", MTU: 9000, Flags: []string{"broadcast"}},
+	{Name: "lo", MTU: 65536, Flags: []string{"up", "loopback"}},
+}
+
+func TestQueryEval(t *testing.T) {
+	cases := map[string][]any{
+		`.[] | .name`:                           {"eth0", "eth1", "lo"},
+		`.[].name`:                              {"eth0", "eth1", "lo"},
+		`.[] | select(.flags[] == "up") | .mac`: {"02:00:00:00:00:01", ""},
+		`.[] | select(.mtu >= 9000) | .name`:    {"eth1", "lo"},
+		`.[] | select(.mtu < 9000) | select(.mac != "") | .name`: {"eth0"},
+		`.[-1].flags[0]`:          {"up"},
+		`.[1]["name"]`:            {"eth1"},
+		`.[5].name`:               {nil},
+		`.[0] | select(.missing)`: nil,
+		`.[0].flags`:              {[]any{"up", "broadcast", "running"}},
+		`.`: {[]any{
+			map[string]any{"name": "eth0", "mac": "02:00:00:00:00:01", "mtu": 1500.0, "flags": []any{"up", "broadcast", "running"}},
+			map[string]any{"name": "eth1", "mac": "02:00:00:00:00:02", "mtu": 9000.0, "flags": []any{"broadcast"}},
+			map[string]any{"name": "lo", "mac": "", "mtu": 65536.0, "flags": []any{"up", "loopback"}},
+		}},
+	}
+	for expr, want := range cases {
+		q, err := Parse(expr)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", expr, err)
+		}
+		got, err := q.Eval(links)
+		if err != nil {
+			t.Fatalf("Eval(%q) error = %v", expr, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Eval(%q) = %#v, want %#v", expr, got, want)
+		}
+	}
+}
+
+func TestQueryIteratesObjectsByKey(t *testing.T) {
+	q, err := Parse(`.[]`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	got, err := q.Eval(map[string]int{"b": 2, "a": 1})
+	if err != nil {
+		t.Fatalf("Eval() error = %v", err)
+	}
+	if want := []any{1.0, 2.0}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Eval() = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		``,
+		`name`,
+		`.[`,
+		`.[x]`,
+		`.a.`,
+		`.a |`,
+		`select(.a ==)`,
+		`select(.a == "x"`,
+		`select(.a == up)`,
+		`.a "b"`,
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Fatalf("Parse(%q): expected error", expr)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for _, expr := range []string{
+		`.name`,
+		`.[0].mtu[]`,
+		`.[0].name[0]`,
+		`.[] | select(.flags > 1)`,
+		`.[] | select(.name > 1)`,
+	} {
+		q, err := Parse(expr)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", expr, err)
+		}
+		if _, err := q.Eval(links); err == nil {
+			t.Fatalf("Eval(%q): expected error", expr)
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Print(&buf, []any{"eth0", 1500.0, nil, []any{"up"}}); err != nil {
+		t.Fatalf("Print() error = %v", err)
+	}
+	if got, want := buf.String(), "eth0\n1500\nnull\n[\"up\"]\n"; got != want {
+		t.Fatalf("Print() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,123 @@
+// Package queue holds what a sink has yet to deliver, so that the sink can
+// deliver in the background and a slow or unreachable destination never
+// holds up the sender.
+package queue
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// DrainTimeout is how long Run goes on delivering what is still queued once
+// its context is done. What is left after it is dropped and reported.
+const DrainTimeout = 5 * time.Second
+
+// Queue holds up to a fixed number of undelivered items. Sinks embed it, so
+// that its Send and Errors are theirs.
+type Queue[T any] struct {
+	// Errors receives failed and dropped deliveries. A failure only loses
+	// that item, so delivery carries on.
+	Errors  io.Writer
+	name    string
+	pending chan T
+}
+
+// New returns a Queue holding up to size items. name, such as "webhook",
+// begins the errors it reports.
+func New[T any](name string, size int) *Queue[T] {
+	return &Queue[T]{name: name, pending: make(chan T, size)}
+}
+
+// Send queues v. When the queue is full v is dropped rather than blocking
+// the caller.
+func (q *Queue[T]) Send(v T) {
+	select {
+	case q.pending <- v:
+	default:
+		q.Report(fmt.Errorf("%s: queue full, dropping an event", q.name))
+	}
+}
+
+// Run hands the queued items to deliver in order until ctx is cancelled,
+// then drains the Queue. Several Runs may share a Queue to deliver items side
+// by side. The errors deliver returns are reported.
+func (q *Queue[T]) Run(ctx context.Context, deliver func(ctx context.Context, v T) error) error {
+	delivering, cancel := drainContext(ctx)
+	defer cancel()
+	for {
+		select {
+		case <-ctx.Done():
+			q.drain(delivering, deliver)
+			return ctx.Err()
+		case v := <-q.pending:
+			if !q.deliver(delivering, deliver, v) {
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// Drain hands deliver what is still queued once ctx is done, so that the
+// last events before stopping are not lost, for at most DrainTimeout. It is
+// for sinks that do not use Run.
+func (q *Queue[T]) Drain(ctx context.Context, deliver func(ctx context.Context, v T) error) {
+	delivering, cancel := drainContext(ctx)
+	defer cancel()
+	q.drain(delivering, deliver)
+}
+
+func (q *Queue[T]) drain(delivering context.Context, deliver func(ctx context.Context, v T) error) {
+	for {
+		select {
+		case v := <-q.pending:
+			if !q.deliver(delivering, deliver, v) {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// deliver hands v to deliver and reports whether delivery may go on, which
+// it may until delivering ends.
+func (q *Queue[T]) deliver(delivering context.Context, deliver func(ctx context.Context, v T) error, v T) bool {
+	err := delivering.Err()
+	if err == nil {
+		err = deliver(delivering, v)
+	}
+	if err != nil && delivering.Err() != nil {
+		q.Report(fmt.Errorf("%s: stopped with %d events undelivered", q.name, len(q.pending)+1))
+		return false
+	}
+	if err != nil {
+		q.Report(err)
+	}
+	return true
+}
+
+// drainContext returns the context deliveries are made in, which ends
+// DrainTimeout after ctx does.
+func drainContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	delivering, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() { time.AfterFunc(DrainTimeout, cancel) })
+	return delivering, func() {
+		stop()
+		cancel()
+	}
+}
+
+// Pending is where the items wait, for a sink that waits on more than its
+// items, such as one keeping an idle connection alive.
+func (q *Queue[T]) Pending() <-chan T {
+	return q.pending
+}
+
+// Report writes err to Errors when that is set.
+func (q *Queue[T]) Report(err error) {
+	if q.Errors != nil {
+		fmt.Fprintln(q.Errors, err)
+	}
+}
@@ -0,0 +1,73 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestQueueDropsWhenFull(t *testing.T) {
+	var errs bytes.Buffer
+	q := New[int]("test", 1)
+	q.Errors = &errs
+	q.Send(1)
+	q.Send(2)
+	if want := "test: queue full, dropping an event\n"; errs.String() != want {
+		t.Fatalf("reported %q, want %q", errs.String(), want)
+	}
+	if got := <-q.Pending(); got != 1 {
+		t.Fatalf("queued %d, want the first item", got)
+	}
+}
+
+func TestQueueRunDeliversInOrderAndReportsFailures(t *testing.T) {
+	var errs bytes.Buffer
+	q := New[int]("test", 3)
+	q.Errors = &errs
+	for i := 1; i <= 3; i++ {
+		q.Send(i)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var delivered []int
+	err := q.Run(ctx, func(_ context.Context, v int) error {
+		delivered = append(delivered, v)
+		if v == 3 {
+			cancel()
+		}
+		if v == 2 {
+			return errors.New("test: refused")
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run() error = %v, want it cancelled", err)
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(delivered, want) {
+		t.Fatalf("delivered %v, want %v", delivered, want)
+	}
+	if want := "test: refused\n"; errs.String() != want {
+		t.Fatalf("reported %q, want %q", errs.String(), want)
+	}
+}
+
+func TestQueueRunDeliversWhatIsQueuedAfterCancellation(t *testing.T) {
+	q := New[int]("test", 3)
+	for i := 1; i <= 3; i++ {
+		q.Send(i)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var delivered []int
+	q.Run(ctx, func(ctx context.Context, v int) error {
+		if ctx.Err() != nil {
+			t.Errorf("item %d delivered with a context already done", v)
+		}
+		delivered = append(delivered, v)
+		return nil
+	})
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(delivered, want) {
+		t.Fatalf("delivered %v, want %v", delivered, want)
+	}
+}
@@ -0,0 +1,136 @@
+// Package readiness waits for an interface to reach a condition, such as
+// having a carrier or an address, so that boot scripts need not poll.
+package readiness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/user/goeth/internal/addresses"
+	"github.com/user/goeth/internal/interfaces"
+)
+
+// ErrTimeout is returned by Wait when Timeout passes first.
+var ErrTimeout = errors.New("timed out")
+
+// Condition is something an interface can be waited for.
+type Condition struct {
+	// Name completes "waiting for eth0 to ...".
+	Name string
+	Met  func(iface interfaces.Interface, addrs []string) bool
+}
+
+// Conditions Waiter knows.
+var (
+	HasCarrier = Condition{Name: "have a carrier", Met: func(iface interfaces.Interface, _ []string) bool {
+		return iface.Carrier
+	}}
+	// HasAddress is met by any address but an IPv6 link-local one, which
+	// the kernel assigns by itself as soon as the link is up.
+	HasAddress = Condition{Name: "have an address", Met: func(_ interfaces.Interface, addrs []string) bool {
+		return anyAddress(addrs, func(ip net.IP) bool { return ip.To4() != nil || !ip.IsLinkLocalUnicast() })
+	}}
+	HasGlobalIPv6 = Condition{Name: "have a global IPv6 address", Met: func(_ interfaces.Interface, addrs []string) bool {
+		return anyAddress(addrs, func(ip net.IP) bool { return ip.To4() == nil && ip.IsGlobalUnicast() })
+	}}
+)
+
+func anyAddress(addrs []string, match func(net.IP) bool) bool {
+	for _, addr := range addrs {
+		if ip, _, err := net.ParseCIDR(addr); err == nil && match(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Waiter checks an interface until it exists and meets every condition.
+type Waiter struct {
+	Lister interfaces.Lister
+	Viewer addresses.Viewer
+	// Interface names the interface waited for.
+	Interface  string
+	Conditions []Condition
+	// Interval is how often the interface is checked.
+	Interval time.Duration
+	// Changes, when set, checks the interface whenever it receives a value
+	// instead of every Interval.
+	Changes <-chan struct{}
+	// Timeout bounds the wait when positive.
+	Timeout time.Duration
+}
+
+// Wait returns nil once the interface meets the conditions, ErrTimeout when
+// Timeout passes first, and the context's error when it is cancelled.
+func (w Waiter) Wait(ctx context.Context) error {
+	if w.Interface == "" {
+		return errors.New("interface name is required")
+	}
+	if w.Changes == nil && w.Interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	if w.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.Timeout)
+		defer cancel()
+	}
+	var ticks <-chan time.Time
+	if w.Changes == nil {
+		ticker := time.NewTicker(w.Interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+	for {
+		pending, err := w.pending()
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("%w after %s waiting for %s to %s", ErrTimeout, w.Timeout, w.Interface, strings.Join(pending, " and "))
+			}
+			return ctx.Err()
+		case <-ticks:
+		case _, ok := <-w.Changes:
+			if !ok {
+				return errors.New("change notifications stopped")
+			}
+		}
+	}
+}
+
+// pending returns the names of the conditions the interface does not meet
+// yet, and "appear" while it does not exist.
+func (w Waiter) pending() ([]string, error) {
+	list, err := w.Lister.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, iface := range list {
+		if iface.Name != w.Interface {
+			continue
+		}
+		var addrs []string
+		if len(w.Conditions) > 0 {
+			if addrs, err = w.Viewer.View(iface.Name); err != nil {
+				return nil, err
+			}
+		}
+		var pending []string
+		for _, condition := range w.Conditions {
+			if !condition.Met(iface, addrs) {
+				pending = append(pending, condition.Name)
+			}
+		}
+		return pending, nil
+	}
+	return []string{"appear"}, nil
+}
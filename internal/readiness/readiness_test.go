@@ -0,0 +1,130 @@
+package readiness
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/goeth/internal/addresses"
+	"github.com/user/goeth/internal/interfaces"
+)
+
+// system is an interface and its addresses that change while a Waiter reads
+// them.
+type system struct {
+	mu    sync.Mutex
+	iface *interfaces.Interface
+	addrs []string
+}
+
+func (s *system) ListInterfaces() ([]interfaces.Interface, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.iface == nil {
+		return nil, nil
+	}
+	return []interfaces.Interface{*s.iface}, nil
+}
+
+func (s *system) InterfaceAddresses(name string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.addrs...), nil
+}
+
+func (s *system) set(iface *interfaces.Interface, addrs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.iface, s.addrs = iface, addrs
+}
+
+func waiter(s *system, conditions ...Condition) Waiter {
+	return Waiter{
+		Lister:     interfaces.NewLister(s),
+		Viewer:     addresses.NewViewer(s),
+		Interface:  "eth0",
+		Conditions: conditions,
+		Interval:   time.Millisecond,
+	}
+}
+
+func TestConditions(t *testing.T) {
+	tests := []struct {
+		condition Condition
+		iface     interfaces.Interface
+		addrs     []string
+		want      bool
+	}{
+		{HasCarrier, interfaces.Interface{Carrier: true}, nil, true},
+		{HasCarrier, interfaces.Interface{}, nil, false},
+		{HasAddress, interfaces.Interface{}, []string{"fe80::1/64"}, false},
+		{HasAddress, interfaces.Interface{}, []string{"fe80::1/64", "192.0.2.1/24"}, true},
+		{HasAddress, interfaces.Interface{}, []string{"2001:db8::1/64"}, true},
+		{HasGlobalIPv6, interfaces.Interface{}, []string{"192.0.2.1/24", "fe80::1/64"}, false},
+		{HasGlobalIPv6, interfaces.Interface{}, []string{"2001:db8::1/64"}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.condition.Met(tt.iface, tt.addrs); got != tt.want {
+			t.Errorf("%s with %v = %v, want %v", tt.condition.Name, tt.addrs, got, tt.want)
+		}
+	}
+}
+
+func TestWaitReturnsOnceConditionsAreMet(t *testing.T) {
+	s := &system{}
+	done := make(chan error, 1)
+	go func() { done <- waiter(s, HasCarrier, HasAddress).Wait(context.Background()) }()
+	s.set(&interfaces.Interface{Name: "eth0", Carrier: true})
+	select {
+	case err := <-done:
+		t.Fatalf("Wait() returned %v before the address was added", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	s.set(&interfaces.Interface{Name: "eth0", Carrier: true}, "192.0.2.1/24")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait() did not return")
+	}
+}
+
+func TestWaitTimesOutNamingWhatIsPending(t *testing.T) {
+	s := &system{iface: &interfaces.Interface{Name: "eth0", Carrier: true}}
+	w := waiter(s, HasCarrier, HasAddress, HasGlobalIPv6)
+	w.Timeout = 10 * time.Millisecond
+	err := w.Wait(context.Background())
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Wait() error = %v, want ErrTimeout", err)
+	}
+	if want := "timed out after 10ms waiting for eth0 to have an address and have a global IPv6 address"; err.Error() != want {
+		t.Fatalf("Wait() error = %q, want %q", err, want)
+	}
+	w = waiter(&system{})
+	w.Timeout = 10 * time.Millisecond
+	if err := w.Wait(context.Background()); err == nil || err.Error() != "timed out after 10ms waiting for eth0 to appear" {
+		t.Fatalf("Wait() error = %v", err)
+	}
+}
+
+func TestWaitFollowsChanges(t *testing.T) {
+	s := &system{}
+	changes := make(chan struct{}, 1)
+	w := waiter(s)
+	w.Interval, w.Changes = 0, changes
+	done := make(chan error, 1)
+	go func() { done <- w.Wait(context.Background()) }()
+	s.set(&interfaces.Interface{Name: "eth0"})
+	changes <- struct{}{}
+	if err := <-done; err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	close(changes)
+	if err := w.Wait(context.Background()); err != nil {
+		t.Fatalf("an interface that is ready needs no notification, got %v", err)
+	}
+}
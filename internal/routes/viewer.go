@@ -0,0 +1,183 @@
+// Package routes describes the routes through an interface, so that they
+// can be listed and compared the way addresses are.
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+)
+
+// Route is a route in the words of ip-route(8).
+type Route struct {
+	// Key identifies the route: its type, destination, table and metric.
+	// Two routes with the same key cannot exist side by side.
+	Key string
+	// Via says where the route leads: its gateways and the protocol that
+	// installed it.
+	Via string
+}
+
+// String describes r as ip-route(8) lists it.
+func (r Route) String() string {
+	if r.Via == "" {
+		return r.Key
+	}
+	return r.Key + " " + r.Via
+}
+
+// Provider retrieves the routes through an interface.
+type Provider interface {
+	InterfaceRoutes(name string) ([]Route, error)
+}
+
+// Viewer exposes route lookup behavior.
+type Viewer struct {
+	provider Provider
+}
+
+// NewViewer creates a Viewer backed by provider.
+func NewViewer(provider Provider) Viewer {
+	return Viewer{provider: provider}
+}
+
+// View returns the routes through the requested interface, sorted by key.
+func (v Viewer) View(name string) ([]Route, error) {
+	if v.provider == nil {
+		return nil, errors.New("route provider is not configured")
+	}
+	if name == "" {
+		return nil, errors.New("interface name is required")
+	}
+	list, err := v.provider.InterfaceRoutes(name)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list, nil
+}
+
+// Describe turns a netlink route into a Route. Routes of the local table
+// are the kernel's own for each address and are left out by Listed.
+func Describe(route netlink.Route) Route {
+	var key strings.Builder
+	if route.Type != 0 && route.Type != unix.RTN_UNICAST {
+		key.WriteString(routeType(route.Type) + " ")
+	}
+	if route.Dst == nil || isDefault(route) {
+		key.WriteString("default")
+		if route.Family == netlink.FAMILY_V6 || route.Dst != nil && route.Dst.IP.To4() == nil {
+			key.WriteString(" (ipv6)")
+		}
+	} else {
+		key.WriteString(route.Dst.String())
+	}
+	if route.Table != 0 && route.Table != unix.RT_TABLE_MAIN {
+		fmt.Fprintf(&key, " table %d", route.Table)
+	}
+	if route.Priority != 0 {
+		fmt.Fprintf(&key, " metric %d", route.Priority)
+	}
+	var via []string
+	if route.Gw != nil {
+		via = append(via, "via "+route.Gw.String())
+	}
+	for _, hop := range route.MultiPath {
+		if hop.Gw != nil {
+			via = append(via, "nexthop via "+hop.Gw.String())
+		}
+	}
+	if route.Protocol != 0 {
+		via = append(via, "proto "+route.Protocol.String())
+	}
+	return Route{Key: key.String(), Via: strings.Join(via, " ")}
+}
+
+func isDefault(route netlink.Route) bool {
+	ones, _ := route.Dst.Mask.Size()
+	return ones == 0
+}
+
+func routeType(typ int) string {
+	switch typ {
+	case unix.RTN_BLACKHOLE:
+		return "blackhole"
+	case unix.RTN_UNREACHABLE:
+		return "unreachable"
+	case unix.RTN_PROHIBIT:
+		return "prohibit"
+	case unix.RTN_LOCAL:
+		return "local"
+	case unix.RTN_BROADCAST:
+		return "broadcast"
+	case unix.RTN_MULTICAST:
+		return "multicast"
+	}
+	return fmt.Sprintf("type %d", typ)
+}
+
+// Listed reports whether route is one Provider implementations list for
+// the link with index: it leaves through the link, directly or as one of
+// its next hops, and is not in the local table.
+func Listed(route netlink.Route, index int) bool {
+	if route.Table == unix.RT_TABLE_LOCAL {
+		return false
+	}
+	if route.LinkIndex == index {
+		return true
+	}
+	for _, hop := range route.MultiPath {
+		if hop.LinkIndex == index {
+			return true
+		}
+	}
+	return false
+}
+
+// NetlinkProvider lists routes through github.com/vishvananda/netlink. The
+// zero value reads the network namespace of the calling process.
+type NetlinkProvider struct {
+	handle *netlink.Handle
+}
+
+// NewNetlinkProviderAt returns a NetlinkProvider that reads inside ns. The
+// namespace handle must stay open for as long as the provider is used.
+func NewNetlinkProviderAt(ns netns.NsHandle) (NetlinkProvider, error) {
+	handle, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		return NetlinkProvider{}, fmt.Errorf("open netlink handle: %w", err)
+	}
+	return NetlinkProvider{handle: handle}, nil
+}
+
+func (p NetlinkProvider) nl() *netlink.Handle {
+	if p.handle == nil {
+		return &netlink.Handle{}
+	}
+	return p.handle
+}
+
+// InterfaceRoutes returns the routes through the interface in every table
+// but the local one.
+func (p NetlinkProvider) InterfaceRoutes(name string) ([]Route, error) {
+	link, err := p.nl().LinkByName(name)
+	if err != nil {
+		return nil, err
+	}
+	list, err := p.nl().RouteListFiltered(netlink.FAMILY_ALL, &netlink.Route{Table: unix.RT_TABLE_UNSPEC}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return nil, err
+	}
+	var described []Route
+	for _, route := range list {
+		if Listed(route, link.Attrs().Index) {
+			described = append(described, Describe(route))
+		}
+	}
+	return described, nil
+}
@@ -0,0 +1,85 @@
+package routes
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+type mockProvider struct {
+	routes map[string][]Route
+	err    error
+}
+
+func (m mockProvider) InterfaceRoutes(name string) ([]Route, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return append([]Route(nil), m.routes[name]...), nil
+}
+
+func cidr(t *testing.T, s string) *net.IPNet {
+	t.Helper()
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatalf("ParseCIDR(%s) error = %v", s, err)
+	}
+	return n
+}
+
+func TestViewerViewSortsByKey(t *testing.T) {
+	provider := mockProvider{routes: map[string][]Route{"eth0": {{Key: "default", Via: "via 192.0.2.1"}, {Key: "192.0.2.0/24"}}}}
+	got, err := NewViewer(provider).View("eth0")
+	if err != nil {
+		t.Fatalf("View() error = %v", err)
+	}
+	if want := []Route{{Key: "192.0.2.0/24"}, {Key: "default", Via: "via 192.0.2.1"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("View() = %v, want %v", got, want)
+	}
+}
+
+func TestViewerViewErrors(t *testing.T) {
+	if _, err := (Viewer{}).View("eth0"); err == nil {
+		t.Fatal("expected error without provider")
+	}
+	if _, err := NewViewer(mockProvider{}).View(""); err == nil {
+		t.Fatal("expected error without interface name")
+	}
+	boom := errors.New("boom")
+	if _, err := NewViewer(mockProvider{err: boom}).View("eth0"); !errors.Is(err, boom) {
+		t.Fatalf("expected provider error, got %v", err)
+	}
+}
+
+func TestDescribeFollowsIPRoute(t *testing.T) {
+	tests := []struct {
+		route netlink.Route
+		want  string
+	}{
+		{netlink.Route{Family: netlink.FAMILY_V4, Gw: net.ParseIP("192.0.2.1"), Protocol: unix.RTPROT_DHCP}, "default via 192.0.2.1 proto dhcp"},
+		{netlink.Route{Family: netlink.FAMILY_V6, Dst: cidr(t, "::/0"), Gw: net.ParseIP("fe80::1"), Priority: 1024}, "default (ipv6) metric 1024 via fe80::1"},
+		{netlink.Route{Dst: cidr(t, "198.51.100.0/24"), Table: 100, Protocol: unix.RTPROT_STATIC}, "198.51.100.0/24 table 100 proto static"},
+		{netlink.Route{Dst: cidr(t, "203.0.113.0/24"), Type: unix.RTN_BLACKHOLE}, "blackhole 203.0.113.0/24"},
+		{netlink.Route{Dst: cidr(t, "198.51.100.0/24"), MultiPath: []*netlink.NexthopInfo{{Gw: net.ParseIP("192.0.2.1")}, {Gw: net.ParseIP("192.0.2.2")}}},
+			"198.51.100.0/24 nexthop via 192.0.2.1 nexthop via 192.0.2.2"},
+	}
+	for _, tt := range tests {
+		if got := Describe(tt.route).String(); got != tt.want {
+			t.Errorf("Describe(%v) = %q, want %q", tt.route, got, tt.want)
+		}
+	}
+}
+
+func TestListedMatchesNextHopsButNotTheLocalTable(t *testing.T) {
+	multipath := netlink.Route{MultiPath: []*netlink.NexthopInfo{{LinkIndex: 2}, {LinkIndex: 3}}}
+	if !Listed(multipath, 3) || Listed(multipath, 4) {
+		t.Fatal("a multipath route is listed for each of its next hops only")
+	}
+	if Listed(netlink.Route{LinkIndex: 2, Table: unix.RT_TABLE_LOCAL}, 2) {
+		t.Fatal("local table routes must not be listed")
+	}
+}
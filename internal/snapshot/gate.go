@@ -0,0 +1,514 @@
+package snapshot
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/user/goeth/internal/config"
+	"github.com/user/goeth/internal/dhcp4"
+	"github.com/user/goeth/internal/dhcp6"
+	"github.com/user/goeth/internal/ethtool"
+	"github.com/user/goeth/internal/wireguard"
+)
+
+// Gate is a config.NetlinkProvider that lets a caller pick individual steps
+// of a plan. Reads go to Live; every change is first described by a
+// Simulator working on a captured state and only reaches Live when Decide
+// approves the plan steps it produced. Running the same configuration
+// through two gates over the same state yields the same steps in the same
+// order, so steps reviewed with one gate can be matched up in the next.
+type Gate struct {
+	Live   config.NetlinkProvider
+	Decide func(steps []string) (bool, error)
+	sim    *Simulator
+}
+
+var _ config.ChangeWatcher = (*Gate)(nil)
+
+// NewGate creates a Gate describing changes against state.
+func NewGate(live config.NetlinkProvider, state State, decide func(steps []string) (bool, error)) *Gate {
+	return &Gate{Live: live, Decide: decide, sim: NewSimulator(state)}
+}
+
+// Notes returns what the executor reported leaving unchanged.
+func (g *Gate) Notes() []string {
+	return g.sim.Notes()
+}
+
+// Report records a note; it implements config.Reporter.
+func (g *Gate) Report(note string) {
+	g.sim.Report(note)
+}
+
+// change describes a change with the simulator and applies it to Live when
+// approved. Changes the simulator records no step for are applied as is.
+func (g *Gate) change(describe func() error, apply func() error) error {
+	before := len(g.sim.plan)
+	if err := describe(); err != nil {
+		return err
+	}
+	steps := append([]string(nil), g.sim.plan[before:]...)
+	if len(steps) > 0 {
+		ok, err := g.Decide(steps)
+		if err != nil || !ok {
+			return err
+		}
+	}
+	return apply()
+}
+
+// simIndex maps a link index of Live to the simulated link of the same name,
+// as links created during an apply get different indices in each.
+func (g *Gate) simIndex(index int) int {
+	links, err := g.Live.LinkList()
+	if err != nil {
+		return index
+	}
+	for _, link := range links {
+		if link.Attrs().Index != index {
+			continue
+		}
+		if entry := g.sim.find(link.Attrs().Name); entry != nil {
+			return entry.Index
+		}
+	}
+	return index
+}
+
+// LinkByName reads from Live.
+func (g *Gate) LinkByName(name string) (netlink.Link, error) {
+	return g.Live.LinkByName(name)
+}
+
+// LinkList reads from Live.
+func (g *Gate) LinkList() ([]netlink.Link, error) {
+	return g.Live.LinkList()
+}
+
+// AddrList reads from Live.
+func (g *Gate) AddrList(link netlink.Link, family int) ([]netlink.Addr, error) {
+	return g.Live.AddrList(link, family)
+}
+
+// NeighList reads from Live.
+func (g *Gate) NeighList(linkIndex, family int) ([]netlink.Neigh, error) {
+	return g.Live.NeighList(linkIndex, family)
+}
+
+// RouteList reads from Live.
+func (g *Gate) RouteList(link netlink.Link, family int) ([]netlink.Route, error) {
+	return g.Live.RouteList(link, family)
+}
+
+// AddrAdd adds an address once approved.
+func (g *Gate) AddrAdd(link netlink.Link, addr *netlink.Addr) error {
+	return g.change(func() error { return g.sim.AddrAdd(link, addr) }, func() error { return g.Live.AddrAdd(link, addr) })
+}
+
+// AddrDel removes an address once approved.
+func (g *Gate) AddrDel(link netlink.Link, addr *netlink.Addr) error {
+	return g.change(func() error { return g.sim.AddrDel(link, addr) }, func() error { return g.Live.AddrDel(link, addr) })
+}
+
+// AddrReplace updates an address once approved.
+func (g *Gate) AddrReplace(link netlink.Link, addr *netlink.Addr) error {
+	return g.change(func() error { return g.sim.AddrReplace(link, addr) }, func() error { return g.Live.AddrReplace(link, addr) })
+}
+
+// NeighSet adds or replaces a neighbor entry once approved.
+func (g *Gate) NeighSet(neigh *netlink.Neigh) error {
+	return g.change(func() error {
+		simulated := *neigh
+		simulated.LinkIndex = g.simIndex(neigh.LinkIndex)
+		return g.sim.NeighSet(&simulated)
+	}, func() error { return g.Live.NeighSet(neigh) })
+}
+
+// NeighDel removes a neighbor entry once approved.
+func (g *Gate) NeighDel(neigh *netlink.Neigh) error {
+	return g.change(func() error {
+		simulated := *neigh
+		simulated.LinkIndex = g.simIndex(neigh.LinkIndex)
+		return g.sim.NeighDel(&simulated)
+	}, func() error { return g.Live.NeighDel(neigh) })
+}
+
+// RouteAdd adds a route once approved.
+func (g *Gate) RouteAdd(route *netlink.Route) error {
+	return g.change(func() error { return g.sim.RouteAdd(g.simRoute(route)) }, func() error { return g.Live.RouteAdd(route) })
+}
+
+// RouteReplace replaces a route once approved.
+func (g *Gate) RouteReplace(route *netlink.Route) error {
+	return g.change(func() error { return g.sim.RouteReplace(g.simRoute(route)) }, func() error { return g.Live.RouteReplace(route) })
+}
+
+// RouteDel removes a route once approved.
+func (g *Gate) RouteDel(route *netlink.Route) error {
+	return g.change(func() error { return g.sim.RouteDel(g.simRoute(route)) }, func() error { return g.Live.RouteDel(route) })
+}
+
+func (g *Gate) simRoute(route *netlink.Route) *netlink.Route {
+	simulated := *route
+	simulated.LinkIndex = g.simIndex(route.LinkIndex)
+	return &simulated
+}
+
+// LinkAdd creates a link once approved.
+func (g *Gate) LinkAdd(link netlink.Link) error {
+	return g.change(func() error {
+		attrs := link.Attrs()
+		live := attrs.ParentIndex
+		if live != 0 {
+			attrs.ParentIndex = g.simIndex(live)
+			defer func() { attrs.ParentIndex = live }()
+		}
+		return g.sim.LinkAdd(link)
+	}, func() error { return g.Live.LinkAdd(link) })
+}
+
+// LinkDel deletes a link once approved.
+func (g *Gate) LinkDel(link netlink.Link) error {
+	return g.change(func() error { return g.sim.LinkDel(link) }, func() error { return g.Live.LinkDel(link) })
+}
+
+// LinkModify updates a link once approved.
+func (g *Gate) LinkModify(link netlink.Link) error {
+	return g.change(func() error { return g.sim.LinkModify(link) }, func() error { return g.Live.LinkModify(link) })
+}
+
+// LinkSetMaster enslaves link to master once approved.
+func (g *Gate) LinkSetMaster(link, master netlink.Link) error {
+	return g.change(func() error { return g.sim.LinkSetMaster(link, master) }, func() error { return g.Live.LinkSetMaster(link, master) })
+}
+
+// LinkSetNoMaster releases link from its master once approved.
+func (g *Gate) LinkSetNoMaster(link netlink.Link) error {
+	return g.change(func() error { return g.sim.LinkSetNoMaster(link) }, func() error { return g.Live.LinkSetNoMaster(link) })
+}
+
+// LinkSetUp brings link up once approved.
+func (g *Gate) LinkSetUp(link netlink.Link) error {
+	return g.change(func() error { return g.sim.LinkSetUp(link) }, func() error { return g.Live.LinkSetUp(link) })
+}
+
+// LinkSetDown brings link down once approved.
+func (g *Gate) LinkSetDown(link netlink.Link) error {
+	return g.change(func() error { return g.sim.LinkSetDown(link) }, func() error { return g.Live.LinkSetDown(link) })
+}
+
+// LinkSetName renames link once approved.
+func (g *Gate) LinkSetName(link netlink.Link, name string) error {
+	return g.change(func() error { return g.sim.LinkSetName(link, name) }, func() error { return g.Live.LinkSetName(link, name) })
+}
+
+// LinkSetMTU changes the MTU of link once approved.
+func (g *Gate) LinkSetMTU(link netlink.Link, mtu int) error {
+	return g.change(func() error { return g.sim.LinkSetMTU(link, mtu) }, func() error { return g.Live.LinkSetMTU(link, mtu) })
+}
+
+// LinkSetHardwareAddr changes the MAC address of link once approved.
+func (g *Gate) LinkSetHardwareAddr(link netlink.Link, hw net.HardwareAddr) error {
+	return g.change(func() error { return g.sim.LinkSetHardwareAddr(link, hw) }, func() error { return g.Live.LinkSetHardwareAddr(link, hw) })
+}
+
+// LinkSetVfHardwareAddr changes the MAC address of a virtual function once
+// approved.
+func (g *Gate) LinkSetVfHardwareAddr(link netlink.Link, vf int, hwaddr net.HardwareAddr) error {
+	return g.vf(func() error { return g.sim.LinkSetVfHardwareAddr(link, vf, hwaddr) }, func(live config.VFProvider) error {
+		return live.LinkSetVfHardwareAddr(link, vf, hwaddr)
+	})
+}
+
+// LinkSetVfVlanQos changes the VLAN of a virtual function once approved.
+func (g *Gate) LinkSetVfVlanQos(link netlink.Link, vf, vlan, qos int) error {
+	return g.vf(func() error { return g.sim.LinkSetVfVlanQos(link, vf, vlan, qos) }, func(live config.VFProvider) error {
+		return live.LinkSetVfVlanQos(link, vf, vlan, qos)
+	})
+}
+
+// LinkSetVfSpoofchk changes spoof checking of a virtual function once
+// approved.
+func (g *Gate) LinkSetVfSpoofchk(link netlink.Link, vf int, check bool) error {
+	return g.vf(func() error { return g.sim.LinkSetVfSpoofchk(link, vf, check) }, func(live config.VFProvider) error {
+		return live.LinkSetVfSpoofchk(link, vf, check)
+	})
+}
+
+// LinkSetVfTrust changes trust of a virtual function once approved.
+func (g *Gate) LinkSetVfTrust(link netlink.Link, vf int, state bool) error {
+	return g.vf(func() error { return g.sim.LinkSetVfTrust(link, vf, state) }, func(live config.VFProvider) error {
+		return live.LinkSetVfTrust(link, vf, state)
+	})
+}
+
+// LinkSetVfRate changes the rate bounds of a virtual function once approved.
+func (g *Gate) LinkSetVfRate(link netlink.Link, vf, minRate, maxRate int) error {
+	return g.vf(func() error { return g.sim.LinkSetVfRate(link, vf, minRate, maxRate) }, func(live config.VFProvider) error {
+		return live.LinkSetVfRate(link, vf, minRate, maxRate)
+	})
+}
+
+func (g *Gate) vf(sim func() error, apply func(config.VFProvider) error) error {
+	live, ok := g.Live.(config.VFProvider)
+	if !ok {
+		return errors.New("provider cannot configure virtual functions")
+	}
+	return g.change(sim, func() error { return apply(live) })
+}
+
+// PinnedProgramID reads from Live.
+func (g *Gate) PinnedProgramID(path string) (uint32, error) {
+	live, ok := g.Live.(config.XDPProvider)
+	if !ok {
+		return 0, errors.New("provider cannot attach xdp programs")
+	}
+	return live.PinnedProgramID(path)
+}
+
+// AttachXDP attaches an XDP program once approved.
+func (g *Gate) AttachXDP(link netlink.Link, pinned string, flags int) error {
+	live, ok := g.Live.(config.XDPProvider)
+	if !ok {
+		return errors.New("provider cannot attach xdp programs")
+	}
+	return g.change(func() error { return g.sim.AttachXDP(link, pinned, flags) }, func() error { return live.AttachXDP(link, pinned, flags) })
+}
+
+// DetachXDP detaches an XDP program once approved.
+func (g *Gate) DetachXDP(link netlink.Link, flags int) error {
+	live, ok := g.Live.(config.XDPProvider)
+	if !ok {
+		return errors.New("provider cannot attach xdp programs")
+	}
+	return g.change(func() error { return g.sim.DetachXDP(link, flags) }, func() error { return live.DetachXDP(link, flags) })
+}
+
+// ConfigureWireGuard configures a WireGuard device once approved. The device
+// and its peers form a single step set that is approved as a whole.
+func (g *Gate) ConfigureWireGuard(name string, device wireguard.Device) error {
+	live, ok := g.Live.(config.WireGuardProvider)
+	if !ok {
+		return errors.New("provider cannot configure wireguard devices")
+	}
+	return g.change(func() error { return g.sim.ConfigureWireGuard(name, device) }, func() error { return live.ConfigureWireGuard(name, device) })
+}
+
+// AnnounceAddresses announces addresses with Live once approved.
+func (g *Gate) AnnounceAddresses(link netlink.Link, ips []net.IP, count int, interval time.Duration) error {
+	live, ok := g.Live.(config.AnnounceProvider)
+	if !ok {
+		return errors.New("provider cannot announce addresses")
+	}
+	return g.change(func() error { return g.sim.AnnounceAddresses(link, ips, count, interval) }, func() error { return live.AnnounceAddresses(link, ips, count, interval) })
+}
+
+// ProbePathMTU measures the path with Live; probing changes nothing.
+func (g *Gate) ProbePathMTU(device string, target net.IP, max int, timeout time.Duration) (int, error) {
+	prober, ok := g.Live.(config.PathMTUProber)
+	if !ok {
+		return 0, errors.New("provider cannot probe the path mtu")
+	}
+	return prober.ProbePathMTU(device, target, max, timeout)
+}
+
+// AcquireLease obtains a lease with Live once approved.
+func (g *Gate) AcquireLease(link netlink.Link, requested net.IP, route bool) (dhcp4.Lease, error) {
+	live, ok := g.Live.(config.DHCP4Provider)
+	if !ok {
+		return dhcp4.Lease{}, errors.New("provider cannot run a dhcpv4 client")
+	}
+	var lease dhcp4.Lease
+	err := g.change(func() error {
+		_, err := g.sim.AcquireLease(link, requested, route)
+		return err
+	}, func() (err error) {
+		lease, err = live.AcquireLease(link, requested, route)
+		return err
+	})
+	return lease, err
+}
+
+// AcquireLease6 obtains a DHCPv6 lease with Live once approved.
+func (g *Gate) AcquireLease6(link netlink.Link, requested net.IP) (dhcp6.Lease, error) {
+	live, ok := g.Live.(config.DHCP6Provider)
+	if !ok {
+		return dhcp6.Lease{}, errors.New("provider cannot run a dhcpv6 client")
+	}
+	var lease dhcp6.Lease
+	err := g.change(func() error {
+		_, err := g.sim.AcquireLease6(link, requested)
+		return err
+	}, func() (err error) {
+		lease, err = live.AcquireLease6(link, requested)
+		return err
+	})
+	return lease, err
+}
+
+// WaitGlobalIPv6 waits with Live; waiting changes nothing.
+func (g *Gate) WaitGlobalIPv6(link netlink.Link, timeout time.Duration) (*netlink.Addr, error) {
+	live, ok := g.Live.(config.AddressWaiter)
+	if !ok {
+		return nil, errors.New("provider cannot wait for ipv6 addresses")
+	}
+	return live.WaitGlobalIPv6(link, timeout)
+}
+
+// Sysctl reads from Live.
+func (g *Gate) Sysctl(path string) (string, error) {
+	live, ok := g.Live.(config.SysctlProvider)
+	if !ok {
+		return "", errors.New("provider cannot read sysctls")
+	}
+	return live.Sysctl(path)
+}
+
+// SetSysctl writes a sysctl once approved.
+func (g *Gate) SetSysctl(path, value string) error {
+	live, ok := g.Live.(config.SysctlProvider)
+	if !ok {
+		return errors.New("provider cannot set sysctls")
+	}
+	return g.change(func() error { return g.sim.SetSysctl(path, value) }, func() error { return live.SetSysctl(path, value) })
+}
+
+// QdiscList reads from Live.
+func (g *Gate) QdiscList(link netlink.Link) ([]netlink.Qdisc, error) {
+	live, ok := g.Live.(config.QdiscProvider)
+	if !ok {
+		return nil, errors.New("provider cannot list qdiscs")
+	}
+	return live.QdiscList(link)
+}
+
+// QdiscReplace sets a qdisc once approved.
+func (g *Gate) QdiscReplace(qdisc netlink.Qdisc) error {
+	live, ok := g.Live.(config.QdiscProvider)
+	if !ok {
+		return errors.New("provider cannot set qdiscs")
+	}
+	return g.change(func() error { return g.sim.QdiscReplace(qdisc) }, func() error { return live.QdiscReplace(qdisc) })
+}
+
+// RuleList reads from Live.
+func (g *Gate) RuleList(family int) ([]netlink.Rule, error) {
+	live, ok := g.Live.(config.RuleProvider)
+	if !ok {
+		return nil, errors.New("provider cannot list rules")
+	}
+	return live.RuleList(family)
+}
+
+// RuleAdd adds a policy rule once approved.
+func (g *Gate) RuleAdd(rule *netlink.Rule) error {
+	live, ok := g.Live.(config.RuleProvider)
+	if !ok {
+		return errors.New("provider cannot add rules")
+	}
+	return g.change(func() error { return g.sim.RuleAdd(rule) }, func() error { return live.RuleAdd(rule) })
+}
+
+// RuleDel removes a policy rule once approved.
+func (g *Gate) RuleDel(rule *netlink.Rule) error {
+	live, ok := g.Live.(config.RuleProvider)
+	if !ok {
+		return errors.New("provider cannot remove rules")
+	}
+	return g.change(func() error { return g.sim.RuleDel(rule) }, func() error { return live.RuleDel(rule) })
+}
+
+// RouteListTable reads from Live.
+func (g *Gate) RouteListTable(link netlink.Link, family, table int) ([]netlink.Route, error) {
+	live, ok := g.Live.(config.RuleProvider)
+	if !ok {
+		return nil, errors.New("provider cannot list routing tables")
+	}
+	return live.RouteListTable(link, family, table)
+}
+
+// Offloads reads from Live.
+func (g *Gate) Offloads(name string) (ethtool.Features, error) {
+	live, ok := g.Live.(config.OffloadProvider)
+	if !ok {
+		return nil, errors.New("provider cannot read offloads")
+	}
+	return live.Offloads(name)
+}
+
+// SetOffloads toggles offload features once approved.
+func (g *Gate) SetOffloads(name string, offloads map[string]bool) error {
+	live, ok := g.Live.(config.OffloadProvider)
+	if !ok {
+		return errors.New("provider cannot set offloads")
+	}
+	return g.change(func() error { return g.sim.SetOffloads(name, offloads) }, func() error { return live.SetOffloads(name, offloads) })
+}
+
+// Rings reads from Live.
+func (g *Gate) Rings(name string) (current, max map[string]uint32, err error) {
+	live, ok := g.Live.(config.RingProvider)
+	if !ok {
+		return nil, nil, errors.New("provider cannot read rings")
+	}
+	return live.Rings(name)
+}
+
+// SetRings resizes rings once approved.
+func (g *Gate) SetRings(name string, rings map[string]uint32) error {
+	live, ok := g.Live.(config.RingProvider)
+	if !ok {
+		return errors.New("provider cannot set rings")
+	}
+	return g.change(func() error { return g.sim.SetRings(name, rings) }, func() error { return live.SetRings(name, rings) })
+}
+
+// Coalesce reads from Live.
+func (g *Gate) Coalesce(name string) (map[string]uint32, error) {
+	live, ok := g.Live.(config.CoalesceProvider)
+	if !ok {
+		return nil, errors.New("provider cannot read coalesce parameters")
+	}
+	return live.Coalesce(name)
+}
+
+// SetCoalesce changes coalescing parameters once approved.
+func (g *Gate) SetCoalesce(name string, params map[string]uint32) error {
+	live, ok := g.Live.(config.CoalesceProvider)
+	if !ok {
+		return errors.New("provider cannot set coalesce parameters")
+	}
+	return g.change(func() error { return g.sim.SetCoalesce(name, params) }, func() error { return live.SetCoalesce(name, params) })
+}
+
+// BusAddress resolves the PCI address with Live.
+func (g *Gate) BusAddress(name string) (string, error) {
+	resolver, ok := g.Live.(config.BusAddressProvider)
+	if !ok {
+		return "", errors.New("provider cannot resolve bus addresses")
+	}
+	return resolver.BusAddress(name)
+}
+
+// WatchChanges watches Live. When Live cannot watch, no change is reported.
+func (g *Gate) WatchChanges(done <-chan struct{}) (<-chan config.Change, error) {
+	live, ok := g.Live.(config.ChangeWatcher)
+	if !ok {
+		none := make(chan config.Change)
+		close(none)
+		return none, nil
+	}
+	return live.WatchChanges(done)
+}
+
+// NetworkManagers asks Live.
+func (g *Gate) NetworkManagers() []string {
+	live, ok := g.Live.(config.ChangeWatcher)
+	if !ok {
+		return nil
+	}
+	return live.NetworkManagers()
+}
@@ -0,0 +1,95 @@
+package snapshot
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/user/goeth/internal/config"
+)
+
+func TestGateAppliesOnlyApprovedSteps(t *testing.T) {
+	state := State{Links: []Link{
+		{Name: "eth0", Index: 2, Kind: "device", Addresses: []string{"192.0.2.5/24"}},
+		{Name: "eth1", Index: 3, Kind: "device"},
+	}}
+	cfg := config.Configuration{
+		Interface: "br0",
+		Addresses: []config.Address{{CIDR: "192.0.2.10/24"}},
+		Bridge:    &config.Bridge{Ports: []string{"eth1"}},
+	}
+	live := NewSimulator(state)
+	var seen [][]string
+	gate := NewGate(live, state, func(steps []string) (bool, error) {
+		seen = append(seen, steps)
+		return steps[0] != "add address 192.0.2.10/24 to br0", nil
+	})
+	if err := config.NewNetlinkExecutor(gate).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	wantSeen := [][]string{{"create bridge br0"}, {"set br0 up"}, {"add address 192.0.2.10/24 to br0"}, {"attach eth1 to br0"}}
+	if !reflect.DeepEqual(seen, wantSeen) {
+		t.Fatalf("decided steps = %#v, want %#v", seen, wantSeen)
+	}
+	if got, want := live.Plan(), []string{"create bridge br0", "set br0 up", "attach eth1 to br0"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("live plan = %#v, want %#v", got, want)
+	}
+}
+
+func TestGatePropagatesDecideErrors(t *testing.T) {
+	state := State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device"}}}
+	live := NewSimulator(state)
+	gate := NewGate(live, state, func([]string) (bool, error) { return false, errors.New("plan changed") })
+	cfg := config.Configuration{Interface: "eth0", Addresses: []config.Address{{CIDR: "192.0.2.10/24"}}}
+	if err := config.NewNetlinkExecutor(gate).Apply(cfg); err == nil {
+		t.Fatal("expected decide error")
+	}
+	if len(live.Plan()) != 0 {
+		t.Fatalf("nothing may reach live after an error, got %v", live.Plan())
+	}
+}
+
+// watchingSimulator is a Simulator that can watch for changes.
+type watchingSimulator struct {
+	*Simulator
+	watched bool
+}
+
+func (w *watchingSimulator) WatchChanges(done <-chan struct{}) (<-chan config.Change, error) {
+	w.watched = true
+	changes := make(chan config.Change)
+	close(changes)
+	return changes, nil
+}
+
+func (w *watchingSimulator) NetworkManagers() []string {
+	return []string{"NetworkManager[42]"}
+}
+
+func TestGateWatchesChangesWithLive(t *testing.T) {
+	state := State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device"}}}
+	live := &watchingSimulator{Simulator: NewSimulator(state)}
+	gate := NewGate(live, state, func([]string) (bool, error) { return true, nil })
+	exec := config.NewNetlinkExecutor(gate)
+	exec.Sleep = func(time.Duration) {}
+	cfg := config.Configuration{Interface: "eth0", Addresses: []config.Address{{CIDR: "192.0.2.10/24"}}}
+	if err := exec.Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if !live.watched {
+		t.Error("the gate applied without watching Live for changes made by others")
+	}
+	if got, want := gate.NetworkManagers(), []string{"NetworkManager[42]"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NetworkManagers() = %v, want %v", got, want)
+	}
+
+	gate = NewGate(NewSimulator(state), state, func([]string) (bool, error) { return true, nil })
+	changes, err := gate.WatchChanges(nil)
+	if err != nil {
+		t.Fatalf("WatchChanges() error = %v", err)
+	}
+	if _, ok := <-changes; ok {
+		t.Error("a Live that cannot watch reported a change")
+	}
+}
@@ -0,0 +1,1274 @@
+package snapshot
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/user/goeth/internal/config"
+	"github.com/user/goeth/internal/dhcp4"
+	"github.com/user/goeth/internal/dhcp6"
+	"github.com/user/goeth/internal/ethtool"
+	"github.com/user/goeth/internal/wireguard"
+)
+
+// Simulator implements config.NetlinkProvider on top of a captured State.
+// Mutating calls update the in-memory state and are recorded as the plan
+// instead of touching the machine.
+type Simulator struct {
+	state State
+	plan  []string
+	notes []string
+	// sysctls holds the values set during the simulation; sysctls are not
+	// part of the captured state.
+	sysctls map[string]string
+	source  config.SysctlProvider
+	// qdiscs holds the root qdiscs set during the simulation, by link
+	// index; like sysctls they are not captured.
+	qdiscs      map[int]netlink.Qdisc
+	qdiscSource config.QdiscProvider
+	// offloads holds the offloads set during the simulation, by link name.
+	offloads      map[string]map[string]bool
+	offloadSource config.OffloadProvider
+	// rings and coalesce hold the values set during the simulation, by link
+	// name.
+	rings          map[string]map[string]uint32
+	ringSource     config.RingProvider
+	coalesce       map[string]map[string]uint32
+	coalesceSource config.CoalesceProvider
+	// rules holds the policy rules and tableRoutes the routes outside the
+	// main table, by link index and table. Neither is captured; both are
+	// read from policySource on first use and then updated by the
+	// simulation.
+	rules        []netlink.Rule
+	rulesRead    bool
+	tableRoutes  map[tableKey][]netlink.Route
+	policySource config.RuleProvider
+	// programSource opens pinned XDP programs; offline they are unknown.
+	programSource config.XDPProvider
+}
+
+type tableKey struct{ link, table int }
+
+// NewSimulator creates a Simulator working on a copy of state.
+func NewSimulator(state State) *Simulator {
+	links := make([]Link, len(state.Links))
+	for i, link := range state.Links {
+		link.Addresses = append([]string(nil), link.Addresses...)
+		link.Scopes = maps.Clone(link.Scopes)
+		link.Peers = maps.Clone(link.Peers)
+		link.Labels = maps.Clone(link.Labels)
+		link.Lifetimes = maps.Clone(link.Lifetimes)
+		link.Flags = maps.Clone(link.Flags)
+		link.Neighbors = append([]Neighbor(nil), link.Neighbors...)
+		link.Routes = append([]Route(nil), link.Routes...)
+		link.VFs = append([]VF(nil), link.VFs...)
+		if link.XDP != nil {
+			xdp := *link.XDP
+			link.XDP = &xdp
+		}
+		links[i] = link
+	}
+	return &Simulator{state: State{Links: links}}
+}
+
+// Plan returns the operations recorded so far, in execution order.
+func (s *Simulator) Plan() []string {
+	return append([]string(nil), s.plan...)
+}
+
+// Notes returns what the executor reported leaving unchanged, such as
+// routes owned by other protocols.
+func (s *Simulator) Notes() []string {
+	return append([]string(nil), s.notes...)
+}
+
+// Report records a note; it implements config.Reporter.
+func (s *Simulator) Report(note string) {
+	s.notes = append(s.notes, note)
+}
+
+func (s *Simulator) record(format string, args ...interface{}) {
+	s.plan = append(s.plan, fmt.Sprintf(format, args...))
+}
+
+func (s *Simulator) find(name string) *Link {
+	for i := range s.state.Links {
+		if s.state.Links[i].Name == name {
+			return &s.state.Links[i]
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) findIndex(index int) *Link {
+	for i := range s.state.Links {
+		if s.state.Links[i].Index == index {
+			return &s.state.Links[i]
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) toNetlink(link *Link) netlink.Link {
+	attrs := netlink.LinkAttrs{Name: link.Name, Index: link.Index, MTU: link.MTU}
+	if link.Up {
+		// A state file records no carrier, so an up link is assumed to have
+		// one and carrier waits pass.
+		attrs.Flags = net.FlagUp
+		attrs.RawFlags = unix.IFF_UP | unix.IFF_LOWER_UP
+	}
+	if hw, err := net.ParseMAC(link.HardwareAddr); err == nil {
+		attrs.HardwareAddr = hw
+	}
+	if hw, err := net.ParseMAC(link.PermanentAddr); err == nil {
+		attrs.PermHWAddr = hw
+	}
+	if link.XDP != nil {
+		attrs.Xdp = &netlink.LinkXdp{Attached: true, ProgId: link.XDP.ProgramID, AttachMode: config.XDPAttachMode(link.XDP.Mode)}
+	}
+	for _, vf := range link.VFs {
+		info := netlink.VfInfo{ID: vf.ID, Vlan: vf.VLAN, Qos: vf.QoS, Spoofchk: vf.SpoofCheck, MinTxRate: uint32(vf.MinRate), MaxTxRate: uint32(vf.MaxRate)}
+		info.Mac, _ = net.ParseMAC(vf.MAC)
+		if vf.Trust {
+			info.Trust = vfTrusted
+		}
+		attrs.Vfs = append(attrs.Vfs, info)
+	}
+	if master := s.find(link.Master); master != nil {
+		attrs.MasterIndex = master.Index
+	}
+	if parent := s.find(link.Parent); parent != nil {
+		attrs.ParentIndex = parent.Index
+	}
+	switch link.Kind {
+	case "vlan":
+		return &netlink.Vlan{LinkAttrs: attrs, VlanId: link.VlanID}
+	case "bridge":
+		return &netlink.Bridge{LinkAttrs: attrs}
+	case "bond":
+		bond := netlink.NewLinkBond(attrs)
+		bond.Mode = netlink.StringToBondMode(link.BondMode)
+		return bond
+	case "macvlan":
+		return &netlink.Macvlan{LinkAttrs: attrs, Mode: macvlanMode(link.MACVLANMode)}
+	case "ipvlan":
+		return &netlink.IPVlan{LinkAttrs: attrs, Mode: ipvlanMode(link.IPVLANMode)}
+	case "tuntap":
+		return &netlink.Tuntap{LinkAttrs: attrs, Mode: netlink.StringToTuntapModeMap[link.TuntapMode]}
+	case "gre":
+		return &netlink.Gretun{LinkAttrs: attrs, Local: net.ParseIP(link.TunnelLocal), Remote: net.ParseIP(link.TunnelRemote), Ttl: uint8(link.TunnelTTL)}
+	case "ipip":
+		return &netlink.Iptun{LinkAttrs: attrs, Local: net.ParseIP(link.TunnelLocal), Remote: net.ParseIP(link.TunnelRemote), Ttl: uint8(link.TunnelTTL)}
+	case "sit":
+		return &netlink.Sittun{LinkAttrs: attrs, Local: net.ParseIP(link.TunnelLocal), Remote: net.ParseIP(link.TunnelRemote), Ttl: uint8(link.TunnelTTL)}
+	}
+	return &netlink.GenericLink{LinkAttrs: attrs, LinkType: link.Kind}
+}
+
+// LinkByName returns the captured link, or config.ErrLinkNotFound.
+func (s *Simulator) LinkByName(name string) (netlink.Link, error) {
+	link := s.find(name)
+	if link == nil {
+		return nil, fmt.Errorf("%w: %s", config.ErrLinkNotFound, name)
+	}
+	return s.toNetlink(link), nil
+}
+
+// LinkList returns every captured link.
+func (s *Simulator) LinkList() ([]netlink.Link, error) {
+	links := make([]netlink.Link, 0, len(s.state.Links))
+	for i := range s.state.Links {
+		links = append(links, s.toNetlink(&s.state.Links[i]))
+	}
+	return links, nil
+}
+
+// AddrList returns the captured addresses of link filtered by family.
+func (s *Simulator) AddrList(link netlink.Link, family int) ([]netlink.Addr, error) {
+	entry := s.find(link.Attrs().Name)
+	if entry == nil {
+		return nil, fmt.Errorf("%w: %s", config.ErrLinkNotFound, link.Attrs().Name)
+	}
+	var addrs []netlink.Addr
+	for _, raw := range entry.Addresses {
+		addr, err := netlink.ParseAddr(raw)
+		if err != nil {
+			return nil, fmt.Errorf("parse captured address %q: %w", raw, err)
+		}
+		if !matchesFamily(addr.IP, family) {
+			continue
+		}
+		addr.LinkIndex = entry.Index
+		entry.restoreAddress(addr)
+		addrs = append(addrs, *addr)
+	}
+	return addrs, nil
+}
+
+// AddrAdd records an address addition.
+func (s *Simulator) AddrAdd(link netlink.Link, addr *netlink.Addr) error {
+	entry := s.find(link.Attrs().Name)
+	if entry == nil {
+		return fmt.Errorf("%w: %s", config.ErrLinkNotFound, link.Attrs().Name)
+	}
+	entry.Addresses = append(entry.Addresses, addr.IPNet.String())
+	entry.recordAddress(addr)
+	var with []string
+	if peer := entry.Peers[addr.IPNet.String()]; peer != "" {
+		with = append(with, "peer "+peer)
+	}
+	if name, ok := scopeNames[addr.Scope]; ok {
+		with = append(with, "scope "+name)
+	}
+	if label := entry.Labels[addr.IPNet.String()]; label != "" {
+		with = append(with, "label "+label)
+	}
+	if flags := entry.Flags[addr.IPNet.String()]; len(flags) > 0 {
+		with = append(with, "flags "+strings.Join(flags, ", "))
+	}
+	if len(with) > 0 {
+		s.record("add address %s to %s with %s", addr.IPNet, entry.Name, strings.Join(with, " and "))
+		return nil
+	}
+	s.record("add address %s to %s", addr.IPNet, entry.Name)
+	return nil
+}
+
+// AddrReplace records updating the flags or lifetimes of an address.
+func (s *Simulator) AddrReplace(link netlink.Link, addr *netlink.Addr) error {
+	entry := s.find(link.Attrs().Name)
+	if entry == nil {
+		return fmt.Errorf("%w: %s", config.ErrLinkNotFound, link.Attrs().Name)
+	}
+	cidr := addr.IPNet.String()
+	before := slices.Clone(entry.Flags[cidr])
+	beforeLifetime, recorded := entry.Lifetimes[cidr]
+	entry.recordAddress(addr)
+	if flags := entry.Flags[cidr]; !slices.Equal(flags, before) {
+		names := "none"
+		if len(flags) > 0 {
+			names = strings.Join(flags, ", ")
+		}
+		s.record("set flags of address %s on %s to %s", addr.IPNet, entry.Name, names)
+	}
+	if lifetime, ok := entry.Lifetimes[cidr]; ok == recorded && lifetime == beforeLifetime {
+		return nil
+	}
+	valid, preferred := addr.ValidLft, addr.PreferedLft
+	if valid == 0 && preferred == 0 {
+		valid, preferred = lifetimeForever, lifetimeForever
+	}
+	s.record("set lifetimes of address %s on %s to valid %s, preferred %s", addr.IPNet, entry.Name,
+		lifetimeString(valid), lifetimeString(preferred))
+	return nil
+}
+
+func lifetimeString(seconds int) string {
+	if seconds == lifetimeForever {
+		return "forever"
+	}
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+// AddrDel records an address removal.
+func (s *Simulator) AddrDel(link netlink.Link, addr *netlink.Addr) error {
+	entry := s.find(link.Attrs().Name)
+	if entry == nil {
+		return fmt.Errorf("%w: %s", config.ErrLinkNotFound, link.Attrs().Name)
+	}
+	entry.Addresses = removeCIDR(entry.Addresses, addr.IPNet.String())
+	entry.forgetAddress(addr.IPNet.String())
+	s.record("remove address %s from %s", addr.IPNet, entry.Name)
+	return nil
+}
+
+// NeighList returns the captured permanent neighbors of the link.
+func (s *Simulator) NeighList(linkIndex, family int) ([]netlink.Neigh, error) {
+	entry := s.findIndex(linkIndex)
+	if entry == nil {
+		return nil, nil
+	}
+	var neighs []netlink.Neigh
+	for _, raw := range entry.Neighbors {
+		ip := net.ParseIP(raw.IP)
+		if ip == nil || !matchesFamily(ip, family) {
+			continue
+		}
+		mac, err := net.ParseMAC(raw.MAC)
+		if err != nil {
+			return nil, fmt.Errorf("parse captured neighbor mac %q: %w", raw.MAC, err)
+		}
+		neighs = append(neighs, netlink.Neigh{LinkIndex: linkIndex, IP: ip, HardwareAddr: mac, State: netlink.NUD_PERMANENT})
+	}
+	return neighs, nil
+}
+
+// NeighSet records a neighbor addition, or the replacement of the entry for
+// the same IP.
+func (s *Simulator) NeighSet(neigh *netlink.Neigh) error {
+	entry := s.findIndex(neigh.LinkIndex)
+	if entry == nil {
+		return fmt.Errorf("%w: index %d", config.ErrLinkNotFound, neigh.LinkIndex)
+	}
+	set := Neighbor{IP: neigh.IP.String(), MAC: neigh.HardwareAddr.String()}
+	for i, n := range entry.Neighbors {
+		if n.IP == set.IP {
+			entry.Neighbors[i] = set
+			s.record("replace neighbor %s lladdr %s on %s", neigh.IP, neigh.HardwareAddr, entry.Name)
+			return nil
+		}
+	}
+	entry.Neighbors = append(entry.Neighbors, set)
+	s.record("add neighbor %s lladdr %s on %s", neigh.IP, neigh.HardwareAddr, entry.Name)
+	return nil
+}
+
+// NeighDel records a neighbor removal.
+func (s *Simulator) NeighDel(neigh *netlink.Neigh) error {
+	entry := s.findIndex(neigh.LinkIndex)
+	if entry == nil {
+		return fmt.Errorf("%w: index %d", config.ErrLinkNotFound, neigh.LinkIndex)
+	}
+	kept := entry.Neighbors[:0]
+	for _, n := range entry.Neighbors {
+		if n.IP != neigh.IP.String() {
+			kept = append(kept, n)
+		}
+	}
+	entry.Neighbors = kept
+	s.record("remove neighbor %s from %s", neigh.IP, entry.Name)
+	return nil
+}
+
+// RouteList returns the captured routes through link filtered by family.
+func (s *Simulator) RouteList(link netlink.Link, family int) ([]netlink.Route, error) {
+	entry := s.find(link.Attrs().Name)
+	if entry == nil {
+		return nil, fmt.Errorf("%w: %s", config.ErrLinkNotFound, link.Attrs().Name)
+	}
+	var routes []netlink.Route
+	for _, raw := range entry.Routes {
+		_, dst, err := net.ParseCIDR(raw.Destination)
+		if err != nil {
+			return nil, fmt.Errorf("parse captured route %q: %w", raw.Destination, err)
+		}
+		if !matchesFamily(dst.IP, family) {
+			continue
+		}
+		routeFamily := netlink.FAMILY_V6
+		if dst.IP.To4() != nil {
+			routeFamily = netlink.FAMILY_V4
+		}
+		typ, err := config.ParseRouteType(raw.Type)
+		if err != nil {
+			return nil, fmt.Errorf("captured route %s: %w", raw.Destination, err)
+		}
+		route := netlink.Route{
+			LinkIndex: entry.Index,
+			Type:      typ,
+			Family:    routeFamily,
+			Dst:       dst,
+			Gw:        net.ParseIP(raw.Gateway),
+			Priority:  raw.Metric,
+			Protocol:  protocolNumber(raw.Protocol),
+		}
+		for _, hop := range raw.Nexthops {
+			info := &netlink.NexthopInfo{Gw: net.ParseIP(hop.Gateway), Hops: max(hop.Weight, 1) - 1}
+			if dev := s.find(hop.Device); dev != nil {
+				info.LinkIndex = dev.Index
+			}
+			route.MultiPath = append(route.MultiPath, info)
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// RouteAdd records a route addition.
+func (s *Simulator) RouteAdd(route *netlink.Route) error {
+	entry := s.findIndex(route.LinkIndex)
+	if entry == nil {
+		return fmt.Errorf("%w: index %d", config.ErrLinkNotFound, route.LinkIndex)
+	}
+	if inTable(route) {
+		routes, err := s.loadTable(entry, route.Table)
+		if err != nil {
+			return err
+		}
+		s.tableRoutes[tableKey{entry.Index, route.Table}] = append(routes, *route)
+	} else {
+		entry.Routes = append(entry.Routes, s.toRoute(route))
+	}
+	s.record("add route %s%s%s%s%s on %s", routeType(route), routeDestination(*route), s.via(route), metric(route), table(route), entry.Name)
+	return nil
+}
+
+// RouteReplace records replacing the route with the same destination.
+func (s *Simulator) RouteReplace(route *netlink.Route) error {
+	entry := s.findIndex(route.LinkIndex)
+	if entry == nil {
+		return fmt.Errorf("%w: index %d", config.ErrLinkNotFound, route.LinkIndex)
+	}
+	if inTable(route) {
+		routes, err := s.loadTable(entry, route.Table)
+		if err != nil {
+			return err
+		}
+		s.tableRoutes[tableKey{entry.Index, route.Table}] = append(removeTableRoute(routes, *route), *route)
+	} else {
+		entry.Routes = append(removeRoute(entry.Routes, routeDestination(*route), route.Priority), s.toRoute(route))
+	}
+	s.record("replace route %s%s%s%s%s on %s", routeType(route), routeDestination(*route), s.via(route), metric(route), table(route), entry.Name)
+	return nil
+}
+
+// RouteDel records a route removal.
+func (s *Simulator) RouteDel(route *netlink.Route) error {
+	entry := s.findIndex(route.LinkIndex)
+	if entry == nil {
+		return fmt.Errorf("%w: index %d", config.ErrLinkNotFound, route.LinkIndex)
+	}
+	if inTable(route) {
+		routes, err := s.loadTable(entry, route.Table)
+		if err != nil {
+			return err
+		}
+		s.tableRoutes[tableKey{entry.Index, route.Table}] = removeTableRoute(routes, *route)
+	} else {
+		entry.Routes = removeRoute(entry.Routes, routeDestination(*route), route.Priority)
+	}
+	s.record("remove route %s%s%s from %s", routeDestination(*route), metric(route), table(route), entry.Name)
+	return nil
+}
+
+// ReadPolicy makes the simulator read policy rules and routes outside the
+// main table from source. Without one there are none to begin with.
+func (s *Simulator) ReadPolicy(source config.RuleProvider) {
+	s.policySource = source
+}
+
+// RouteListTable returns the routes through link in table.
+func (s *Simulator) RouteListTable(link netlink.Link, family, table int) ([]netlink.Route, error) {
+	entry := s.find(link.Attrs().Name)
+	if entry == nil {
+		return nil, fmt.Errorf("%w: %s", config.ErrLinkNotFound, link.Attrs().Name)
+	}
+	routes, err := s.loadTable(entry, table)
+	if err != nil {
+		return nil, err
+	}
+	var listed []netlink.Route
+	for _, route := range routes {
+		if family == netlink.FAMILY_ALL || route.Family == family {
+			listed = append(listed, route)
+		}
+	}
+	return listed, nil
+}
+
+// loadTable returns the routes through entry in table, reading them from
+// the policy source the first time.
+func (s *Simulator) loadTable(entry *Link, table int) ([]netlink.Route, error) {
+	key := tableKey{entry.Index, table}
+	if routes, ok := s.tableRoutes[key]; ok {
+		return routes, nil
+	}
+	var routes []netlink.Route
+	if s.policySource != nil {
+		for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+			list, err := s.policySource.RouteListTable(s.toNetlink(entry), family, table)
+			if err != nil {
+				return nil, err
+			}
+			routes = append(routes, list...)
+		}
+	}
+	if s.tableRoutes == nil {
+		s.tableRoutes = make(map[tableKey][]netlink.Route)
+	}
+	s.tableRoutes[key] = routes
+	return routes, nil
+}
+
+// RuleList returns the policy rules of family.
+func (s *Simulator) RuleList(family int) ([]netlink.Rule, error) {
+	if err := s.loadRules(); err != nil {
+		return nil, err
+	}
+	var rules []netlink.Rule
+	for _, rule := range s.rules {
+		if family == netlink.FAMILY_ALL || rule.Family == family {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// RuleAdd records adding a policy rule.
+func (s *Simulator) RuleAdd(rule *netlink.Rule) error {
+	if err := s.loadRules(); err != nil {
+		return err
+	}
+	s.rules = append(s.rules, *rule)
+	s.record("add rule %s", config.DescribeRule(*rule))
+	return nil
+}
+
+// RuleDel records removing a policy rule.
+func (s *Simulator) RuleDel(rule *netlink.Rule) error {
+	if err := s.loadRules(); err != nil {
+		return err
+	}
+	description := config.DescribeRule(*rule)
+	kept := s.rules[:0]
+	for _, have := range s.rules {
+		if config.DescribeRule(have) != description {
+			kept = append(kept, have)
+		}
+	}
+	s.rules = kept
+	s.record("remove rule %s", description)
+	return nil
+}
+
+func (s *Simulator) loadRules() error {
+	if s.rulesRead || s.policySource == nil {
+		return nil
+	}
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		list, err := s.policySource.RuleList(family)
+		if err != nil {
+			return err
+		}
+		s.rules = append(s.rules, list...)
+	}
+	s.rulesRead = true
+	return nil
+}
+
+// LinkAdd records the creation of a link.
+func (s *Simulator) LinkAdd(link netlink.Link) error {
+	attrs := link.Attrs()
+	if s.find(attrs.Name) != nil {
+		return fmt.Errorf("link %s already exists", attrs.Name)
+	}
+	veth, isVeth := link.(*netlink.Veth)
+	localPeer := isVeth && veth.PeerNamespace == nil
+	if localPeer && (s.find(veth.PeerName) != nil || veth.PeerName == attrs.Name) {
+		return fmt.Errorf("link %s already exists", veth.PeerName)
+	}
+	entry := Link{Name: attrs.Name, Index: s.nextIndex(), Kind: link.Type()}
+	if parent := s.findIndex(attrs.ParentIndex); parent != nil && attrs.ParentIndex != 0 {
+		entry.Parent = parent.Name
+	}
+	detail := ""
+	if vlan, ok := link.(*netlink.Vlan); ok {
+		entry.VlanID = vlan.VlanId
+		detail = fmt.Sprintf(" (id %d)", vlan.VlanId)
+	}
+	if bond, ok := link.(*netlink.Bond); ok && bond.Mode != netlink.BondMode(-1) {
+		entry.BondMode = bond.Mode.String()
+		detail = fmt.Sprintf(" (mode %s)", entry.BondMode)
+	}
+	if macvlan, ok := link.(*netlink.Macvlan); ok {
+		entry.MACVLANMode = macvlanModes[macvlan.Mode]
+		detail = fmt.Sprintf(" (mode %s)", entry.MACVLANMode)
+	}
+	if tuntap, ok := link.(*netlink.Tuntap); ok {
+		entry.TuntapMode = tuntap.Mode.String()
+		detail = fmt.Sprintf(" (%s)", entry.TuntapMode)
+	}
+	if ipvlan, ok := link.(*netlink.IPVlan); ok {
+		entry.IPVLANMode = ipvlanModes[ipvlan.Mode]
+		detail = fmt.Sprintf(" (mode %s)", entry.IPVLANMode)
+	}
+	if setTunnel(&entry, link) {
+		detail = tunnelDetail(entry)
+	}
+	if entry.Parent != "" {
+		detail += " on " + entry.Parent
+	}
+	s.state.Links = append(s.state.Links, entry)
+	switch {
+	case localPeer:
+		s.state.Links = append(s.state.Links, Link{Name: veth.PeerName, Index: s.nextIndex(), Kind: "veth"})
+		detail = fmt.Sprintf(" (peer %s)", veth.PeerName)
+	case isVeth:
+		detail = fmt.Sprintf(" (peer %s in netns %v)", veth.PeerName, veth.PeerNamespace)
+	}
+	s.record("create %s %s%s", entry.Kind, entry.Name, detail)
+	return nil
+}
+
+// LinkDel records the deletion of a link.
+func (s *Simulator) LinkDel(link netlink.Link) error {
+	name := link.Attrs().Name
+	kept := s.state.Links[:0]
+	found := false
+	for _, entry := range s.state.Links {
+		if entry.Name == name {
+			found = true
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	if !found {
+		return fmt.Errorf("%w: %s", config.ErrLinkNotFound, name)
+	}
+	s.state.Links = kept
+	s.record("delete %s %s", link.Type(), name)
+	return nil
+}
+
+// LinkModify records updating the settings of an existing link. Only tunnel
+// endpoints are tracked.
+func (s *Simulator) LinkModify(link netlink.Link) error {
+	entry := s.find(link.Attrs().Name)
+	if entry == nil {
+		return fmt.Errorf("%w: %s", config.ErrLinkNotFound, link.Attrs().Name)
+	}
+	detail := ""
+	if setTunnel(entry, link) {
+		detail = tunnelDetail(*entry)
+	}
+	s.record("update %s %s%s", link.Type(), entry.Name, detail)
+	return nil
+}
+
+// LinkSetMaster records enslaving link to master.
+func (s *Simulator) LinkSetMaster(link, master netlink.Link) error {
+	entry := s.find(link.Attrs().Name)
+	if entry == nil {
+		return fmt.Errorf("%w: %s", config.ErrLinkNotFound, link.Attrs().Name)
+	}
+	entry.Master = master.Attrs().Name
+	s.record("attach %s to %s", entry.Name, entry.Master)
+	return nil
+}
+
+// LinkSetNoMaster records releasing link from its master.
+func (s *Simulator) LinkSetNoMaster(link netlink.Link) error {
+	entry := s.find(link.Attrs().Name)
+	if entry == nil {
+		return fmt.Errorf("%w: %s", config.ErrLinkNotFound, link.Attrs().Name)
+	}
+	s.record("release %s from %s", entry.Name, entry.Master)
+	entry.Master = ""
+	return nil
+}
+
+// LinkSetUp records bringing link up.
+func (s *Simulator) LinkSetUp(link netlink.Link) error {
+	entry := s.find(link.Attrs().Name)
+	if entry == nil {
+		return fmt.Errorf("%w: %s", config.ErrLinkNotFound, link.Attrs().Name)
+	}
+	s.record("set %s up", link.Attrs().Name)
+	entry.Up = true
+	return nil
+}
+
+// LinkSetDown records bringing link down.
+func (s *Simulator) LinkSetDown(link netlink.Link) error {
+	entry := s.find(link.Attrs().Name)
+	if entry == nil {
+		return fmt.Errorf("%w: %s", config.ErrLinkNotFound, link.Attrs().Name)
+	}
+	s.record("set %s down", link.Attrs().Name)
+	entry.Up = false
+	return nil
+}
+
+// LinkSetName records renaming link, updating references held by other links.
+func (s *Simulator) LinkSetName(link netlink.Link, name string) error {
+	old := link.Attrs().Name
+	entry := s.find(old)
+	if entry == nil {
+		return fmt.Errorf("%w: %s", config.ErrLinkNotFound, old)
+	}
+	if s.find(name) != nil {
+		return fmt.Errorf("link %s already exists", name)
+	}
+	entry.Name = name
+	for i := range s.state.Links {
+		if s.state.Links[i].Master == old {
+			s.state.Links[i].Master = name
+		}
+		if s.state.Links[i].Parent == old {
+			s.state.Links[i].Parent = name
+		}
+	}
+	s.record("rename %s to %s", old, name)
+	return nil
+}
+
+// LinkSetMTU records changing the MTU of link.
+func (s *Simulator) LinkSetMTU(link netlink.Link, mtu int) error {
+	entry := s.find(link.Attrs().Name)
+	if entry == nil {
+		return fmt.Errorf("%w: %s", config.ErrLinkNotFound, link.Attrs().Name)
+	}
+	entry.MTU = mtu
+	s.record("set %s mtu %d", entry.Name, mtu)
+	return nil
+}
+
+// LinkSetHardwareAddr records changing the MAC address of link.
+func (s *Simulator) LinkSetHardwareAddr(link netlink.Link, hw net.HardwareAddr) error {
+	entry := s.find(link.Attrs().Name)
+	if entry == nil {
+		return fmt.Errorf("%w: %s", config.ErrLinkNotFound, link.Attrs().Name)
+	}
+	entry.HardwareAddr = hw.String()
+	s.record("set %s mac %s", entry.Name, hw)
+	return nil
+}
+
+// LinkSetVfHardwareAddr records changing the MAC address of a virtual
+// function.
+func (s *Simulator) LinkSetVfHardwareAddr(link netlink.Link, vf int, hwaddr net.HardwareAddr) error {
+	entry, info, err := s.findVF(link, vf)
+	if err != nil {
+		return err
+	}
+	info.MAC = hwaddr.String()
+	s.record("set %s vf %d mac %s", entry.Name, vf, hwaddr)
+	return nil
+}
+
+// LinkSetVfVlanQos records changing the VLAN of a virtual function.
+func (s *Simulator) LinkSetVfVlanQos(link netlink.Link, vf, vlan, qos int) error {
+	entry, info, err := s.findVF(link, vf)
+	if err != nil {
+		return err
+	}
+	info.VLAN, info.QoS = vlan, qos
+	s.record("set %s vf %d vlan %d qos %d", entry.Name, vf, vlan, qos)
+	return nil
+}
+
+// LinkSetVfSpoofchk records changing spoof checking of a virtual function.
+func (s *Simulator) LinkSetVfSpoofchk(link netlink.Link, vf int, check bool) error {
+	entry, info, err := s.findVF(link, vf)
+	if err != nil {
+		return err
+	}
+	info.SpoofCheck = check
+	s.record("set %s vf %d spoofchk %s", entry.Name, vf, onOff(check))
+	return nil
+}
+
+// LinkSetVfTrust records changing trust of a virtual function.
+func (s *Simulator) LinkSetVfTrust(link netlink.Link, vf int, state bool) error {
+	entry, info, err := s.findVF(link, vf)
+	if err != nil {
+		return err
+	}
+	info.Trust = state
+	s.record("set %s vf %d trust %s", entry.Name, vf, onOff(state))
+	return nil
+}
+
+// LinkSetVfRate records changing the rate bounds of a virtual function.
+func (s *Simulator) LinkSetVfRate(link netlink.Link, vf, minRate, maxRate int) error {
+	entry, info, err := s.findVF(link, vf)
+	if err != nil {
+		return err
+	}
+	info.MinRate, info.MaxRate = minRate, maxRate
+	s.record("set %s vf %d min_tx_rate %d max_tx_rate %d", entry.Name, vf, minRate, maxRate)
+	return nil
+}
+
+// ReadPrograms makes the simulator open pinned XDP programs through source
+// to learn their ids. Without one they are unknown, so a declared program
+// is attached.
+func (s *Simulator) ReadPrograms(source config.XDPProvider) {
+	s.programSource = source
+}
+
+// PinnedProgramID returns the id of the program pinned at path as the
+// source reports it, or 0 without a source.
+func (s *Simulator) PinnedProgramID(path string) (uint32, error) {
+	if s.programSource == nil {
+		return 0, nil
+	}
+	return s.programSource.PinnedProgramID(path)
+}
+
+// AttachXDP records attaching the program pinned at path to link.
+func (s *Simulator) AttachXDP(link netlink.Link, pinned string, flags int) error {
+	entry := s.find(link.Attrs().Name)
+	if entry == nil {
+		return fmt.Errorf("%w: %s", config.ErrLinkNotFound, link.Attrs().Name)
+	}
+	id, err := s.PinnedProgramID(pinned)
+	if err != nil {
+		return err
+	}
+	mode := xdpFlagMode(flags)
+	entry.XDP = &XDP{ProgramID: id, Mode: mode}
+	s.record("attach xdp program %s to %s in %s mode", pinned, entry.Name, mode)
+	return nil
+}
+
+// DetachXDP records detaching the XDP program of link.
+func (s *Simulator) DetachXDP(link netlink.Link, flags int) error {
+	entry := s.find(link.Attrs().Name)
+	if entry == nil {
+		return fmt.Errorf("%w: %s", config.ErrLinkNotFound, link.Attrs().Name)
+	}
+	entry.XDP = nil
+	s.record("detach xdp program from %s", entry.Name)
+	return nil
+}
+
+// xdpFlagMode names the attach mode of XDP flags.
+func xdpFlagMode(flags int) string {
+	switch {
+	case flags&unix.XDP_FLAGS_SKB_MODE != 0:
+		return "skb"
+	case flags&unix.XDP_FLAGS_HW_MODE != 0:
+		return "offload"
+	}
+	return "native"
+}
+
+// findVF returns the captured link and its virtual function vf.
+func (s *Simulator) findVF(link netlink.Link, vf int) (*Link, *VF, error) {
+	entry := s.find(link.Attrs().Name)
+	if entry == nil {
+		return nil, nil, fmt.Errorf("%w: %s", config.ErrLinkNotFound, link.Attrs().Name)
+	}
+	for i := range entry.VFs {
+		if entry.VFs[i].ID == vf {
+			return entry, &entry.VFs[i], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%s has no vf %d", entry.Name, vf)
+}
+
+// AnnounceAddresses records announcing ips to the neighbors of link.
+func (s *Simulator) AnnounceAddresses(link netlink.Link, ips []net.IP, count int, interval time.Duration) error {
+	entry := s.find(link.Attrs().Name)
+	if entry == nil {
+		return fmt.Errorf("%w: %s", config.ErrLinkNotFound, link.Attrs().Name)
+	}
+	names := make([]string, len(ips))
+	for i, ip := range ips {
+		names[i] = ip.String()
+	}
+	s.record("announce %s on %s %d times, %s apart", strings.Join(names, ", "), entry.Name, count, interval)
+	return nil
+}
+
+// ProbePathMTU records the probe and assumes the path carries max bytes, as
+// the real path cannot be measured offline.
+func (s *Simulator) ProbePathMTU(device string, target net.IP, max int, _ time.Duration) (int, error) {
+	s.record("probe path mtu from %s to %s (assuming %d)", device, target, max)
+	return max, nil
+}
+
+// AcquireLease records requesting a DHCPv4 lease. The lease a server would
+// grant is not known offline, so it is empty and nothing is assigned.
+func (s *Simulator) AcquireLease(link netlink.Link, requested net.IP, route bool) (dhcp4.Lease, error) {
+	if s.find(link.Attrs().Name) == nil {
+		return dhcp4.Lease{}, fmt.Errorf("%w: %s", config.ErrLinkNotFound, link.Attrs().Name)
+	}
+	step := "obtain a dhcpv4 lease for " + link.Attrs().Name
+	if requested != nil {
+		step += " asking for " + requested.String()
+	}
+	if route {
+		step += " and route through its router"
+	}
+	s.record("%s", step)
+	return dhcp4.Lease{}, nil
+}
+
+// AcquireLease6 records requesting a DHCPv6 lease; like AcquireLease it
+// assigns nothing.
+func (s *Simulator) AcquireLease6(link netlink.Link, requested net.IP) (dhcp6.Lease, error) {
+	if s.find(link.Attrs().Name) == nil {
+		return dhcp6.Lease{}, fmt.Errorf("%w: %s", config.ErrLinkNotFound, link.Attrs().Name)
+	}
+	step := "obtain a dhcpv6 lease for " + link.Attrs().Name
+	if requested != nil {
+		step += " asking for " + requested.String()
+	}
+	s.record("%s", step)
+	return dhcp6.Lease{}, nil
+}
+
+// WaitGlobalIPv6 records the wait and assumes an address arrives, as router
+// advertisements and servers cannot be consulted offline.
+func (s *Simulator) WaitGlobalIPv6(link netlink.Link, timeout time.Duration) (*netlink.Addr, error) {
+	s.record("wait up to %s for a global ipv6 address on %s", timeout, link.Attrs().Name)
+	return nil, nil
+}
+
+// ConfigureWireGuard records configuring a WireGuard device. Peers are not
+// part of the captured state, so the full declared peer set is reported.
+func (s *Simulator) ConfigureWireGuard(name string, device wireguard.Device) error {
+	if s.find(name) == nil {
+		return fmt.Errorf("%w: %s", config.ErrLinkNotFound, name)
+	}
+	port := "auto"
+	if device.ListenPort > 0 {
+		port = strconv.Itoa(device.ListenPort)
+	}
+	s.record("configure wireguard %s (listen port %s)", name, port)
+	for _, peer := range device.Peers {
+		s.record("set wireguard peer %s on %s", peer.PublicKey, name)
+	}
+	return nil
+}
+
+// ReadSysctls makes the simulator read the sysctls it has not set from
+// source. Without one they read as empty, so every declared sysctl is set.
+func (s *Simulator) ReadSysctls(source config.SysctlProvider) {
+	s.source = source
+}
+
+// Sysctl returns the value set during the simulation or read from the source.
+func (s *Simulator) Sysctl(path string) (string, error) {
+	if value, ok := s.sysctls[path]; ok {
+		return value, nil
+	}
+	if s.source != nil {
+		return s.source.Sysctl(path)
+	}
+	return "", nil
+}
+
+// SetSysctl records writing a sysctl.
+func (s *Simulator) SetSysctl(path, value string) error {
+	if s.sysctls == nil {
+		s.sysctls = make(map[string]string)
+	}
+	s.sysctls[path] = value
+	s.record("set sysctl %s to %s", config.SysctlName(path), value)
+	return nil
+}
+
+// ReadQdiscs makes the simulator list the qdiscs of links it has not set one
+// on from source. Without one links have none, so a declared qdisc is set.
+func (s *Simulator) ReadQdiscs(source config.QdiscProvider) {
+	s.qdiscSource = source
+}
+
+// QdiscList returns the root qdisc set during the simulation or the qdiscs
+// read from the source.
+func (s *Simulator) QdiscList(link netlink.Link) ([]netlink.Qdisc, error) {
+	if qdisc, ok := s.qdiscs[link.Attrs().Index]; ok {
+		return []netlink.Qdisc{qdisc}, nil
+	}
+	if s.qdiscSource != nil {
+		return s.qdiscSource.QdiscList(link)
+	}
+	return nil, nil
+}
+
+// QdiscReplace records setting the root qdisc of a link.
+func (s *Simulator) QdiscReplace(qdisc netlink.Qdisc) error {
+	entry := s.findIndex(qdisc.Attrs().LinkIndex)
+	if entry == nil {
+		return fmt.Errorf("%w: index %d", config.ErrLinkNotFound, qdisc.Attrs().LinkIndex)
+	}
+	if s.qdiscs == nil {
+		s.qdiscs = make(map[int]netlink.Qdisc)
+	}
+	s.qdiscs[entry.Index] = qdisc
+	s.record("set qdisc of %s to %s", entry.Name, config.DescribeQdisc(qdisc))
+	return nil
+}
+
+// ReadOffloads makes the simulator read offload features from source.
+// Without one they are unknown, so every declared offload is set.
+func (s *Simulator) ReadOffloads(source config.OffloadProvider) {
+	s.offloadSource = source
+}
+
+// Offloads returns the features read from the source, updated with the
+// offloads set during the simulation.
+func (s *Simulator) Offloads(name string) (ethtool.Features, error) {
+	if s.find(name) == nil {
+		return nil, fmt.Errorf("%w: %s", config.ErrLinkNotFound, name)
+	}
+	if s.offloadSource == nil {
+		return nil, nil
+	}
+	features, err := s.offloadSource.Offloads(name)
+	if err != nil || len(s.offloads[name]) == 0 {
+		return features, err
+	}
+	features = maps.Clone(features)
+	for offload, on := range s.offloads[name] {
+		features.Set(offload, on)
+	}
+	return features, nil
+}
+
+// SetOffloads records toggling offload features.
+func (s *Simulator) SetOffloads(name string, offloads map[string]bool) error {
+	if s.find(name) == nil {
+		return fmt.Errorf("%w: %s", config.ErrLinkNotFound, name)
+	}
+	if s.offloads == nil {
+		s.offloads = make(map[string]map[string]bool)
+	}
+	if s.offloads[name] == nil {
+		s.offloads[name] = make(map[string]bool)
+	}
+	for _, offload := range slices.Sorted(maps.Keys(offloads)) {
+		s.offloads[name][offload] = offloads[offload]
+		s.record("turn offload %s of %s %s", offload, name, onOff(offloads[offload]))
+	}
+	return nil
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// ReadRings makes the simulator read ring sizes from source. Without one
+// they are unknown, so every declared ring is set.
+func (s *Simulator) ReadRings(source config.RingProvider) {
+	s.ringSource = source
+}
+
+// Rings returns the ring sizes read from the source, updated with those set
+// during the simulation.
+func (s *Simulator) Rings(name string) (current, max map[string]uint32, err error) {
+	if s.find(name) == nil {
+		return nil, nil, fmt.Errorf("%w: %s", config.ErrLinkNotFound, name)
+	}
+	if s.ringSource == nil {
+		return nil, nil, nil
+	}
+	current, max, err = s.ringSource.Rings(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return updated(current, s.rings[name]), max, nil
+}
+
+// SetRings records resizing rings.
+func (s *Simulator) SetRings(name string, rings map[string]uint32) error {
+	if s.find(name) == nil {
+		return fmt.Errorf("%w: %s", config.ErrLinkNotFound, name)
+	}
+	s.rings = setValues(s.rings, name, rings)
+	for _, ring := range slices.Sorted(maps.Keys(rings)) {
+		s.record("resize %s ring of %s to %d", ring, name, rings[ring])
+	}
+	return nil
+}
+
+// ReadCoalesce makes the simulator read coalescing parameters from source.
+// Without one they are unknown, so every declared parameter is set.
+func (s *Simulator) ReadCoalesce(source config.CoalesceProvider) {
+	s.coalesceSource = source
+}
+
+// Coalesce returns the parameters read from the source, updated with those
+// set during the simulation.
+func (s *Simulator) Coalesce(name string) (map[string]uint32, error) {
+	if s.find(name) == nil {
+		return nil, fmt.Errorf("%w: %s", config.ErrLinkNotFound, name)
+	}
+	if s.coalesceSource == nil {
+		return nil, nil
+	}
+	params, err := s.coalesceSource.Coalesce(name)
+	if err != nil {
+		return nil, err
+	}
+	return updated(params, s.coalesce[name]), nil
+}
+
+// SetCoalesce records changing coalescing parameters.
+func (s *Simulator) SetCoalesce(name string, params map[string]uint32) error {
+	if s.find(name) == nil {
+		return fmt.Errorf("%w: %s", config.ErrLinkNotFound, name)
+	}
+	s.coalesce = setValues(s.coalesce, name, params)
+	for _, param := range slices.Sorted(maps.Keys(params)) {
+		s.record("set coalesce %s of %s to %d", param, name, params[param])
+	}
+	return nil
+}
+
+// updated returns a copy of values with set applied.
+func updated(values, set map[string]uint32) map[string]uint32 {
+	values = maps.Clone(values)
+	if values == nil && len(set) > 0 {
+		values = make(map[string]uint32, len(set))
+	}
+	maps.Copy(values, set)
+	return values
+}
+
+// setValues records values set on the named link in byLink.
+func setValues(byLink map[string]map[string]uint32, name string, values map[string]uint32) map[string]map[string]uint32 {
+	if byLink == nil {
+		byLink = make(map[string]map[string]uint32)
+	}
+	if byLink[name] == nil {
+		byLink[name] = make(map[string]uint32)
+	}
+	maps.Copy(byLink[name], values)
+	return byLink
+}
+
+// BusAddress returns the PCI address recorded for the link.
+func (s *Simulator) BusAddress(name string) (string, error) {
+	entry := s.find(name)
+	if entry == nil {
+		return "", fmt.Errorf("%w: %s", config.ErrLinkNotFound, name)
+	}
+	if entry.BusAddress == "" {
+		return "", fmt.Errorf("no bus address recorded for %s", name)
+	}
+	return entry.BusAddress, nil
+}
+
+func setTunnel(entry *Link, link netlink.Link) bool {
+	local, remote, ttl, ok := tunnelParams(link)
+	if ok {
+		entry.TunnelLocal = ipString(local)
+		entry.TunnelRemote = ipString(remote)
+		entry.TunnelTTL = int(ttl)
+	}
+	return ok
+}
+
+func tunnelDetail(entry Link) string {
+	return fmt.Sprintf(" (%s -> %s, ttl %d)", entry.TunnelLocal, entry.TunnelRemote, entry.TunnelTTL)
+}
+
+func (s *Simulator) nextIndex() int {
+	highest := 0
+	for _, link := range s.state.Links {
+		if link.Index > highest {
+			highest = link.Index
+		}
+	}
+	return highest + 1
+}
+
+func (s *Simulator) toRoute(route *netlink.Route) Route {
+	return Route{
+		Destination: routeDestination(*route),
+		Type:        config.RouteTypeName(route.Type),
+		Gateway:     ipString(route.Gw),
+		Nexthops:    toNexthops(*route, s.linkName),
+		Metric:      route.Priority,
+		Protocol:    protocolName(route.Protocol),
+	}
+}
+
+func (s *Simulator) via(route *netlink.Route) string {
+	if len(route.MultiPath) > 0 {
+		var b strings.Builder
+		for _, hop := range s.toRoute(route).Nexthops {
+			fmt.Fprintf(&b, " nexthop via %s dev %s weight %d", hop.Gateway, hop.Device, hop.Weight)
+		}
+		return b.String()
+	}
+	if route.Gw == nil {
+		return ""
+	}
+	return " via " + route.Gw.String()
+}
+
+// linkName names the link with the given index, or gives the index when
+// there is none.
+func (s *Simulator) linkName(index int) string {
+	if link := s.findIndex(index); link != nil {
+		return link.Name
+	}
+	return strconv.Itoa(index)
+}
+
+// inTable reports whether route is in a table other than main.
+func inTable(route *netlink.Route) bool {
+	return route.Table != 0 && route.Table != unix.RT_TABLE_MAIN
+}
+
+func table(route *netlink.Route) string {
+	if !inTable(route) {
+		return ""
+	}
+	return fmt.Sprintf(" table %d", route.Table)
+}
+
+func routeType(route *netlink.Route) string {
+	if name := config.RouteTypeName(route.Type); name != "" {
+		return name + " "
+	}
+	return ""
+}
+
+func metric(route *netlink.Route) string {
+	if route.Priority == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" metric %d", route.Priority)
+}
+
+func removeTableRoute(routes []netlink.Route, route netlink.Route) []netlink.Route {
+	var kept []netlink.Route
+	for _, have := range routes {
+		if routeDestination(have) != routeDestination(route) || have.Priority != route.Priority {
+			kept = append(kept, have)
+		}
+	}
+	return kept
+}
+
+// removeRoute drops the routes to destination with metric; routes that
+// differ only in metric are distinct.
+func removeRoute(routes []Route, destination string, metric int) []Route {
+	kept := routes[:0]
+	for _, route := range routes {
+		if route.Destination != destination || route.Metric != metric {
+			kept = append(kept, route)
+		}
+	}
+	return kept
+}
+
+func matchesFamily(ip net.IP, family int) bool {
+	switch family {
+	case netlink.FAMILY_V4:
+		return ip.To4() != nil
+	case netlink.FAMILY_V6:
+		return ip.To4() == nil
+	}
+	return true
+}
+
+// removeCIDR drops target from values, comparing canonical prefix forms so
+// hand-written state files do not need to match netlink's formatting.
+func removeCIDR(values []string, target string) []string {
+	kept := values[:0]
+	for _, v := range values {
+		if addr, err := netlink.ParseAddr(v); err == nil && addr.IPNet.String() == target {
+			continue
+		}
+		kept = append(kept, v)
+	}
+	return kept
+}
@@ -0,0 +1,582 @@
+package snapshot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/user/goeth/internal/config"
+)
+
+func TestSimulatorPlansChanges(t *testing.T) {
+	state := State{Links: []Link{
+		{Name: "eth0", Index: 2, Kind: "device", Addresses: []string{"192.0.2.5/24", "192.0.2.10/24"}},
+		{Name: "eth0.20", Index: 3, Kind: "vlan", Parent: "eth0", VlanID: 20},
+	}}
+	sim := NewSimulator(state)
+	cfg := config.Configuration{
+		Interface: "eth0",
+		Addresses: []config.Address{{CIDR: "192.0.2.10/24"}},
+		Neighbors: []config.Neighbor{{IP: "192.0.2.50", MAC: "02:00:00:00:00:50"}},
+		VLANs:     []config.VLAN{{Name: "eth0.10", ID: 10}},
+	}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{
+		"remove address 192.0.2.5/24 from eth0",
+		"add neighbor 192.0.2.50 lladdr 02:00:00:00:00:50 on eth0",
+		"delete vlan eth0.20",
+		"create vlan eth0.10 (id 10) on eth0",
+		"set eth0.10 up",
+	}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+	if len(state.Links[0].Addresses) != 2 {
+		t.Fatalf("simulation must not modify the captured state: %#v", state.Links[0])
+	}
+}
+
+func TestSimulatorReplacesChangedNeighbors(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device", Neighbors: []Neighbor{
+		{IP: "192.0.2.50", MAC: "02:00:5e:00:53:50"},
+	}}}})
+	cfg := config.Configuration{Interface: "eth0", Neighbors: []config.Neighbor{{IP: "192.0.2.50", MAC: "02:00:5e:00:53:51"}}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"replace neighbor 192.0.2.50 lladdr 02:00:5e:00:53:51 on eth0"}; !reflect.DeepEqual(sim.Plan(), want) {
+		t.Fatalf("Plan() = %#v, want %#v", sim.Plan(), want)
+	}
+	if want := []Neighbor{{IP: "192.0.2.50", MAC: "02:00:5e:00:53:51"}}; !reflect.DeepEqual(sim.state.Links[0].Neighbors, want) {
+		t.Fatalf("neighbors = %#v, want %#v", sim.state.Links[0].Neighbors, want)
+	}
+}
+
+func TestSimulatorReconcilesMACVLANs(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{
+		{Name: "eth0", Index: 2, Kind: "device"},
+		{Name: "mv0", Index: 3, Kind: "macvlan", Parent: "eth0", MACVLANMode: "bridge"},
+		{Name: "mv1", Index: 4, Kind: "macvlan", Parent: "eth0", MACVLANMode: "bridge"},
+	}})
+	cfg := config.Configuration{Interface: "eth0", MACVLANs: []config.MACVLAN{{Name: "mv0"}, {Name: "mv1", Mode: "private"}}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{"delete macvlan mv1", "create macvlan mv1 (mode private) on eth0", "set mv1 up"}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+}
+
+func TestSimulatorCreatesIPVLAN(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device"}}})
+	cfg := config.Configuration{Interface: "eth0", IPVLANs: []config.IPVLAN{{Name: "ipv0", Mode: "l3s"}}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{"create ipvlan ipv0 (mode l3s) on eth0", "set ipv0 up"}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+}
+
+func TestSimulatorKeepsForeignRoutes(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device", Routes: []Route{
+		{Destination: "0.0.0.0/0", Gateway: "192.0.2.1", Protocol: "goeth"},
+		{Destination: "203.0.113.0/24", Gateway: "192.0.2.1", Protocol: "goeth"},
+		{Destination: "10.0.0.0/8", Gateway: "192.0.2.254", Protocol: "bgp"},
+	}}}})
+	cfg := config.Configuration{Interface: "eth0", Routes: []config.Route{
+		{Destination: "default", Gateway: "192.0.2.2"},
+		{Destination: "198.51.100.0/24"},
+	}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{
+		"replace route 0.0.0.0/0 via 192.0.2.2 on eth0",
+		"add route 198.51.100.0/24 on eth0",
+		"remove route 203.0.113.0/24 from eth0",
+	}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+	notes := []string{"foreign route 10.0.0.0/8 on eth0 (proto bgp) left in place"}
+	if got := sim.Notes(); !reflect.DeepEqual(got, notes) {
+		t.Fatalf("Notes() = %#v, want %#v", got, notes)
+	}
+}
+
+func TestSimulatorCreatesTuntap(t *testing.T) {
+	sim := NewSimulator(State{})
+	cfg := config.Configuration{Interface: "tap0", Tuntap: &config.Tuntap{Mode: "tap"}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got, want := sim.Plan(), []string{"create tuntap tap0 (tap)", "set tap0 up"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+}
+
+func TestSimulatorCreatesBridge(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{Name: "eth1", Index: 2, Kind: "device"}}})
+	cfg := config.Configuration{Interface: "br0", Bridge: &config.Bridge{Ports: []string{"eth1"}}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{"create bridge br0", "set br0 up", "attach eth1 to br0"}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+}
+
+func TestSimulatorRestoresPermanentMAC(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device", HardwareAddr: "02:00:00:aa:bb:cc", PermanentAddr: "00:16:3e:12:34:56"}}})
+	cfg := config.Configuration{Interface: "eth0", MAC: "permanent"}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got, want := sim.Plan(), []string{"set eth0 mac 00:16:3e:12:34:56"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+}
+
+func TestSimulatorReplacesAddressScope(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{
+		Name: "eth0", Index: 2, Kind: "device",
+		Addresses: []string{"192.0.2.10/24", "169.254.0.5/16"},
+		Scopes:    map[string]string{"169.254.0.5/16": "link"},
+	}}})
+	cfg := config.Configuration{Interface: "eth0", Addresses: []config.Address{
+		{CIDR: "192.0.2.10/24", Scope: "host"},
+		{CIDR: "169.254.0.5/16", Scope: "link"},
+	}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{
+		"remove address 192.0.2.10/24 from eth0",
+		"add address 192.0.2.10/24 to eth0 with scope host",
+	}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+}
+
+func TestSimulatorRecreatesAddressWithOtherLabel(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{
+		Name: "eth0", Index: 2, Kind: "device",
+		Addresses: []string{"192.0.2.10/24", "192.0.2.11/24"},
+		Labels:    map[string]string{"192.0.2.11/24": "eth0:web"},
+	}}})
+	cfg := config.Configuration{Interface: "eth0", Addresses: []config.Address{
+		{CIDR: "192.0.2.10/24", Label: "eth0:api"},
+		{CIDR: "192.0.2.11/24", Label: "eth0:web"},
+	}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{
+		"remove address 192.0.2.10/24 from eth0",
+		"add address 192.0.2.10/24 to eth0 with label eth0:api",
+	}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+	if got := sim.state.Links[0].Labels; !reflect.DeepEqual(got, map[string]string{"192.0.2.10/24": "eth0:api", "192.0.2.11/24": "eth0:web"}) {
+		t.Fatalf("labels = %v", got)
+	}
+}
+
+func TestSimulatorKeepsAddressPeers(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{
+		Name: "gre1", Index: 5, Kind: "gre",
+		Addresses: []string{"10.255.0.1/32"},
+		Peers:     map[string]string{"10.255.0.1/32": "10.255.0.2"},
+	}}})
+	cfg := config.Configuration{Interface: "gre1", Addresses: []config.Address{
+		{CIDR: "10.255.0.1/32", Peer: "10.255.0.2"},
+		{CIDR: "10.255.1.1/32", Peer: "10.255.1.2"},
+	}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got, want := sim.Plan(), []string{"add address 10.255.1.1/32 to gre1 with peer 10.255.1.2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+}
+
+func TestSimulatorDeprecatesAddress(t *testing.T) {
+	deprecated := config.Duration(0)
+	state := State{Links: []Link{{
+		Name: "eth0", Index: 2, Kind: "device",
+		Addresses: []string{"2001:db8:1::10/64", "2001:db8:2::10/64"},
+		Lifetimes: map[string]Lifetime{"2001:db8:2::10/64": {Valid: lifetimeForever, Preferred: 0}},
+	}}}
+	sim := NewSimulator(state)
+	cfg := config.Configuration{Interface: "eth0", Addresses: []config.Address{
+		{CIDR: "2001:db8:1::10/64", PreferredLifetime: &deprecated},
+		{CIDR: "2001:db8:2::10/64", PreferredLifetime: &deprecated},
+	}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{"set lifetimes of address 2001:db8:1::10/64 on eth0 to valid forever, preferred 0s"}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+	if len(state.Links[0].Lifetimes) != 1 {
+		t.Fatalf("simulating changed the captured lifetimes: %v", state.Links[0].Lifetimes)
+	}
+}
+
+func TestSimulatorUpdatesAddressFlags(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{
+		Name: "eth0", Index: 2, Kind: "device",
+		Addresses: []string{"2001:db8:1::10/64", "2001:db8:2::10/64"},
+		Flags:     map[string][]string{"2001:db8:1::10/64": {"noprefixroute"}, "2001:db8:2::10/64": {"home"}},
+	}}})
+	cfg := config.Configuration{Interface: "eth0", UpdateFlags: true, Addresses: []config.Address{
+		{CIDR: "2001:db8:1::10/64", Flags: []string{"noprefixroute"}},
+		{CIDR: "2001:db8:2::10/64", Flags: []string{"noprefixroute"}},
+		{CIDR: "2001:db8:3::10/64", Flags: []string{"noprefixroute", "nodad"}},
+	}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{
+		"set flags of address 2001:db8:2::10/64 on eth0 to noprefixroute",
+		"add address 2001:db8:3::10/64 to eth0 with flags noprefixroute",
+	}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+}
+
+// sysctlSource serves fixed sysctl values.
+type sysctlSource map[string]string
+
+func (s sysctlSource) Sysctl(path string) (string, error) { return s[path], nil }
+
+func (s sysctlSource) SetSysctl(path, value string) error {
+	return errors.New("unexpected write")
+}
+
+func TestSimulatorSetsSysctls(t *testing.T) {
+	state := State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device"}}}
+	cfg := config.Configuration{Interface: "eth0", Sysctls: map[string]string{
+		"net.ipv6.conf.eth0.accept_ra":  "0",
+		"net.ipv4.conf.eth0.forwarding": "1",
+	}}
+	sim := NewSimulator(state)
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{"set sysctl net.ipv4.conf.eth0.forwarding to 1", "set sysctl net.ipv6.conf.eth0.accept_ra to 0"}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+
+	sim = NewSimulator(state)
+	sim.ReadSysctls(sysctlSource{"net/ipv4/conf/eth0/forwarding": "1", "net/ipv6/conf/eth0/accept_ra": "1"})
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want[1:]) {
+		t.Fatalf("Plan() with live sysctls = %#v, want %#v", got, want[1:])
+	}
+}
+
+func TestSimulatorSetsQdisc(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device"}}})
+	cfg := config.Configuration{Interface: "eth0", Qdisc: &config.Qdisc{Type: "fq_codel", Limit: 1000}}
+	for i := 0; i < 2; i++ {
+		if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+	}
+	want := []string{"set qdisc of eth0 to fq_codel limit 1000"}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+}
+
+func TestSimulatorSetsOffloads(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device"}}})
+	cfg := config.Configuration{Interface: "eth0", Offloads: map[string]bool{"gro": false, "tso": true}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{"turn offload gro of eth0 off", "turn offload tso of eth0 on"}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+}
+
+func TestSimulatorSetsRingsAndCoalesce(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device"}}})
+	cfg := config.Configuration{Interface: "eth0", Rings: map[string]int{"rx": 4096}, Coalesce: map[string]int{"rx-usecs": 50, "rx-frames": 0}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{"resize rx ring of eth0 to 4096", "set coalesce rx-frames of eth0 to 0", "set coalesce rx-usecs of eth0 to 50"}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+}
+
+func TestSimulatorNoChanges(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device", Addresses: []string{"192.0.2.10/24"}}}})
+	cfg := config.Configuration{Interface: "eth0", Addresses: []config.Address{{CIDR: "192.0.2.10/24"}}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if plan := sim.Plan(); len(plan) != 0 {
+		t.Fatalf("expected empty plan, got %v", plan)
+	}
+}
+
+func TestSimulatorMissingInterface(t *testing.T) {
+	sim := NewSimulator(State{})
+	cfg := config.Configuration{Interface: "eth0", Addresses: []config.Address{{CIDR: "192.0.2.10/24"}}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err == nil {
+		t.Fatal("expected error for interface missing from the state")
+	}
+}
+
+func TestSimulatorCreatesWireGuard(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "wg0.key")
+	if err := os.WriteFile(keyFile, []byte("AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	sim := NewSimulator(State{})
+	cfg := config.Configuration{Interface: "wg0", WireGuard: &config.WireGuard{
+		PrivateKeyFile: keyFile,
+		ListenPort:     51820,
+		Peers:          []config.WireGuardPeer{{PublicKey: "AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI=", AllowedIPs: []string{"10.0.0.2/32"}}},
+	}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{
+		"create wireguard wg0",
+		"set wg0 up",
+		"configure wireguard wg0 (listen port 51820)",
+		"set wireguard peer AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI= on wg0",
+	}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+}
+
+func TestSimulatorUpdatesTunnel(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{
+		{Name: "gre1", Index: 5, Kind: "gre", TunnelLocal: "192.0.2.1", TunnelRemote: "198.51.100.1", TunnelTTL: 64},
+	}})
+	cfg := config.Configuration{Interface: "gre1", Tunnel: &config.Tunnel{Mode: "gre", Local: "192.0.2.1", Remote: "198.51.100.2", TTL: 64}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{"update gre gre1 (192.0.2.1 -> 198.51.100.2, ttl 64)"}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("second Apply() error = %v", err)
+	}
+	if got := sim.Plan(); len(got) != 1 {
+		t.Fatalf("re-applying must be a no-op, got %#v", got)
+	}
+}
+
+func TestSimulatorCreatesVethPair(t *testing.T) {
+	sim := NewSimulator(State{})
+	cfg := config.Configuration{Interface: "vethA", Veth: &config.Veth{Peer: "vethB"}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"create veth vethA (peer vethB)", "set vethA up"}; !reflect.DeepEqual(sim.Plan(), want) {
+		t.Fatalf("Plan() = %#v, want %#v", sim.Plan(), want)
+	}
+	if _, err := sim.LinkByName("vethB"); err != nil {
+		t.Fatalf("expected peer to exist after creation: %v", err)
+	}
+}
+
+func TestSimulatorRefusesVethPeerThatExists(t *testing.T) {
+	state := State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device"}}}
+	tests := []struct {
+		name string
+		peer string
+	}{
+		{"existing link", "eth0"},
+		{"the link itself", "vethA"},
+	}
+	for _, tt := range tests {
+		sim := NewSimulator(state)
+		veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "vethA"}, PeerName: tt.peer}
+		if err := sim.LinkAdd(veth); err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Fatalf("%s: LinkAdd() error = %v, want the peer to exist", tt.name, err)
+		}
+		if !reflect.DeepEqual(sim.state, state) || len(sim.Plan()) != 0 {
+			t.Fatalf("%s: state %+v, plan %v after a refused veth; want them unchanged", tt.name, sim.state, sim.Plan())
+		}
+	}
+}
+
+func TestSimulatorRequestsLease(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{
+		Name: "eth0", Index: 2, Kind: "device", Up: true,
+		Addresses: []string{"192.0.2.10/24", "192.0.2.50/24"},
+		Lifetimes: map[string]Lifetime{"192.0.2.50/24": {Valid: 1800, Preferred: 0}},
+	}}})
+	cfg := config.Configuration{Interface: "eth0", DHCP4: true, Addresses: []config.Address{{CIDR: "192.0.2.10/24"}}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{"obtain a dhcpv4 lease for eth0 asking for 192.0.2.50 and route through its router"}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+}
+
+func TestSimulatorAcquiresIPv6(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device", Up: true}}})
+	cfg := config.Configuration{Interface: "eth0", DHCP6: true, AcquireTimeout: config.Duration(5 * time.Second)}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{"obtain a dhcpv6 lease for eth0", "wait up to 5s for a global ipv6 address on eth0"}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+}
+
+func TestSimulatorKeepsPolicyRouting(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device", Up: true}}})
+	cfg := config.Configuration{
+		Interface: "eth0",
+		Routes:    []config.Route{{Destination: "default", Gateway: "192.0.2.1", Table: 100}},
+		Rules:     []config.Rule{{Priority: 1000, From: "192.0.2.10", Table: 100}},
+	}
+	for i := 0; i < 2; i++ {
+		if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+	}
+	want := []string{"add route 0.0.0.0/0 via 192.0.2.1 table 100 on eth0", "add rule 1000: from 192.0.2.10 lookup 100"}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v; the second apply should change nothing", got, want)
+	}
+}
+
+func TestSimulatorKeepsMultipathRoute(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device"}, {Name: "eth1", Index: 3, Kind: "device"}}})
+	cfg := config.Configuration{Interface: "eth0", Routes: []config.Route{{Destination: "default", Nexthops: []config.Nexthop{
+		{Gateway: "192.0.2.1"},
+		{Gateway: "198.51.100.1", Interface: "eth1", Weight: 2},
+	}}}}
+	for i := 0; i < 2; i++ {
+		if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+	}
+	want := []string{"add route 0.0.0.0/0 nexthop via 192.0.2.1 dev eth0 weight 1 nexthop via 198.51.100.1 dev eth1 weight 2 on eth0"}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v; the second apply should change nothing", got, want)
+	}
+}
+
+func TestSimulatorChangesRouteMetric(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device", Routes: []Route{
+		{Destination: "203.0.113.0/24", Gateway: "192.0.2.1", Metric: 100, Protocol: "goeth"},
+	}}}})
+	cfg := config.Configuration{Interface: "eth0", Routes: []config.Route{{Destination: "203.0.113.0/24", Gateway: "192.0.2.1", Metric: 200}}}
+	for i := 0; i < 2; i++ {
+		if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+	}
+	want := []string{
+		"add route 203.0.113.0/24 via 192.0.2.1 metric 200 on eth0",
+		"remove route 203.0.113.0/24 metric 100 from eth0",
+	}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v; the second apply should change nothing", got, want)
+	}
+}
+
+func TestSimulatorKeepsBlackholeRoute(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device"}}})
+	cfg := config.Configuration{Interface: "eth0", Routes: []config.Route{{Destination: "203.0.113.0/24", Type: "blackhole"}}}
+	for i := 0; i < 2; i++ {
+		if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+	}
+	want := []string{"add route blackhole 203.0.113.0/24 on eth0"}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v; the second apply should change nothing", got, want)
+	}
+}
+
+func TestSimulatorSetsVFs(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device", VFs: []VF{
+		{ID: 0, MAC: "00:00:00:00:00:00", SpoofCheck: true},
+	}}}})
+	off := false
+	cfg := config.Configuration{Interface: "eth0", VFs: []config.VF{{ID: 0, MAC: "02:00:00:00:00:01", VLAN: 100, SpoofCheck: &off}}}
+	for i := 0; i < 2; i++ {
+		if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+	}
+	want := []string{
+		"set eth0 vf 0 mac 02:00:00:00:00:01",
+		"set eth0 vf 0 vlan 100 qos 0",
+		"set eth0 vf 0 spoofchk off",
+	}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v; the second apply should change nothing", got, want)
+	}
+}
+
+func TestSimulatorSwitchesXDPMode(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device", XDP: &XDP{ProgramID: 42, Mode: "native"}}}})
+	cfg := config.Configuration{Interface: "eth0", XDP: &config.XDP{Pinned: "/sys/fs/bpf/ddos", Mode: "skb"}}
+	if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{
+		"detach xdp program from eth0",
+		"attach xdp program /sys/fs/bpf/ddos to eth0 in skb mode",
+	}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+}
+
+func TestSimulatorAnnouncesAddedAddresses(t *testing.T) {
+	sim := NewSimulator(State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device", Addresses: []string{"192.0.2.10/24"}}}})
+	cfg := config.Configuration{Interface: "eth0", Addresses: []config.Address{{CIDR: "192.0.2.10/24"}, {CIDR: "192.0.2.20/24"}}, Announce: &config.Announce{Count: 2}}
+	for i := 0; i < 2; i++ {
+		if err := config.NewNetlinkExecutor(sim).Apply(cfg); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+	}
+	want := []string{
+		"add address 192.0.2.20/24 to eth0",
+		"announce 192.0.2.20 on eth0 2 times, 1s apart",
+	}
+	if got := sim.Plan(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Plan() = %#v, want %#v", got, want)
+	}
+}
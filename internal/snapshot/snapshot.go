@@ -0,0 +1,462 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"slices"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/user/goeth/internal/config"
+)
+
+// State is a point-in-time capture of a machine's links, addresses,
+// permanent neighbor entries and main-table routes.
+type State struct {
+	Links []Link `json:"links"`
+}
+
+// maxRouteProtocol is the largest value of the 8-bit rtm_protocol field.
+const maxRouteProtocol = 255
+
+// vfTrusted is the trust setting of a trusted virtual function.
+const vfTrusted = 1
+
+// Link describes a single captured link.
+type Link struct {
+	Name         string `json:"name"`
+	Index        int    `json:"index"`
+	Kind         string `json:"kind"`
+	MTU          int    `json:"mtu,omitempty"`
+	Up           bool   `json:"up,omitempty"`
+	HardwareAddr string `json:"hardware_addr,omitempty"`
+	// PermanentAddr is the MAC address the device was made with, as
+	// reported by physical devices.
+	PermanentAddr string   `json:"permanent_addr,omitempty"`
+	BusAddress    string   `json:"bus_address,omitempty"`
+	Master        string   `json:"master,omitempty"`
+	Parent        string   `json:"parent,omitempty"`
+	VlanID        int      `json:"vlan_id,omitempty"`
+	BondMode      string   `json:"bond_mode,omitempty"`
+	TunnelLocal   string   `json:"tunnel_local,omitempty"`
+	TunnelRemote  string   `json:"tunnel_remote,omitempty"`
+	TunnelTTL     int      `json:"tunnel_ttl,omitempty"`
+	MACVLANMode   string   `json:"macvlan_mode,omitempty"`
+	IPVLANMode    string   `json:"ipvlan_mode,omitempty"`
+	TuntapMode    string   `json:"tuntap_mode,omitempty"`
+	Addresses     []string `json:"addresses,omitempty"`
+	// Scopes holds the scope of addresses that are not global, by CIDR.
+	Scopes map[string]string `json:"scopes,omitempty"`
+	// Peers holds the remote end of point-to-point addresses, by CIDR.
+	Peers map[string]string `json:"peers,omitempty"`
+	// Labels holds the labels of IPv4 addresses other than the name of the
+	// link, by CIDR.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Lifetimes holds the remaining lifetimes of addresses that expire or
+	// are deprecated, by CIDR.
+	Lifetimes map[string]Lifetime `json:"lifetimes,omitempty"`
+	// Flags holds the lasting netlink flags of addresses that have any, by
+	// CIDR.
+	Flags     map[string][]string `json:"flags,omitempty"`
+	Neighbors []Neighbor          `json:"neighbors,omitempty"`
+	Routes    []Route             `json:"routes,omitempty"`
+	// VFs holds the SR-IOV virtual functions of a physical function.
+	VFs []VF `json:"vfs,omitempty"`
+	// XDP is the attached XDP program.
+	XDP *XDP `json:"xdp,omitempty"`
+}
+
+// XDP is an XDP program attached to a link, by the id the kernel gave it
+// and the attach mode, such as native or skb.
+type XDP struct {
+	ProgramID uint32 `json:"program_id"`
+	Mode      string `json:"mode"`
+}
+
+// Lifetime is the remaining valid and preferred lifetime of an address in
+// seconds, where 4294967295 means forever.
+type Lifetime struct {
+	Valid     int `json:"valid"`
+	Preferred int `json:"preferred"`
+}
+
+// lifetimeForever is the kernel's INFINITY_LIFE_TIME.
+const lifetimeForever = math.MaxUint32
+
+// VF is an SR-IOV virtual function as its physical function reports it.
+// Rates are in Mbit/s.
+type VF struct {
+	ID         int    `json:"id"`
+	MAC        string `json:"mac,omitempty"`
+	VLAN       int    `json:"vlan,omitempty"`
+	QoS        int    `json:"qos,omitempty"`
+	SpoofCheck bool   `json:"spoof_check,omitempty"`
+	Trust      bool   `json:"trust,omitempty"`
+	MinRate    int    `json:"min_rate,omitempty"`
+	MaxRate    int    `json:"max_rate,omitempty"`
+}
+
+// Neighbor is a permanent ARP/NDP entry.
+type Neighbor struct {
+	IP  string `json:"ip"`
+	MAC string `json:"mac"`
+}
+
+// Route is a main-table route through a link. Protocol is "goeth" for routes
+// installed by goeth and the iproute2 protocol name otherwise.
+type Route struct {
+	Destination string    `json:"destination"`
+	Type        string    `json:"type,omitempty"`
+	Gateway     string    `json:"gateway,omitempty"`
+	Nexthops    []Nexthop `json:"nexthops,omitempty"`
+	Metric      int       `json:"metric,omitempty"`
+	Protocol    string    `json:"protocol"`
+}
+
+// Nexthop is one next hop of a multipath route.
+type Nexthop struct {
+	Gateway string `json:"gateway"`
+	Device  string `json:"device"`
+	Weight  int    `json:"weight"`
+}
+
+// Source exposes the netlink queries needed to capture a State. Sources that
+// also implement config.BusAddressProvider get PCI addresses recorded.
+type Source interface {
+	LinkList() ([]netlink.Link, error)
+	AddrList(link netlink.Link, family int) ([]netlink.Addr, error)
+	NeighList(linkIndex, family int) ([]netlink.Neigh, error)
+	RouteList(link netlink.Link, family int) ([]netlink.Route, error)
+}
+
+// Capture reads the current state from source.
+func Capture(source Source) (State, error) {
+	if source == nil {
+		return State{}, errors.New("snapshot source is not configured")
+	}
+	links, err := source.LinkList()
+	if err != nil {
+		return State{}, fmt.Errorf("list links: %w", err)
+	}
+	resolver, _ := source.(config.BusAddressProvider)
+	names := make(map[int]string, len(links))
+	for _, link := range links {
+		names[link.Attrs().Index] = link.Attrs().Name
+	}
+	state := State{Links: make([]Link, 0, len(links))}
+	for _, link := range links {
+		attrs := link.Attrs()
+		entry := Link{
+			Name:         attrs.Name,
+			Index:        attrs.Index,
+			Kind:         link.Type(),
+			MTU:          attrs.MTU,
+			Up:           attrs.Flags&net.FlagUp != 0,
+			HardwareAddr: attrs.HardwareAddr.String(),
+			Master:       names[attrs.MasterIndex],
+			Parent:       names[attrs.ParentIndex],
+		}
+		if len(attrs.PermHWAddr) > 0 {
+			entry.PermanentAddr = attrs.PermHWAddr.String()
+		}
+		if xdp := attrs.Xdp; xdp != nil && xdp.ProgId != 0 {
+			entry.XDP = &XDP{ProgramID: xdp.ProgId, Mode: config.XDPModeName(xdp.AttachMode)}
+		}
+		for _, vf := range attrs.Vfs {
+			entry.VFs = append(entry.VFs, VF{
+				ID:         vf.ID,
+				MAC:        vf.Mac.String(),
+				VLAN:       vf.Vlan,
+				QoS:        vf.Qos,
+				SpoofCheck: vf.Spoofchk,
+				Trust:      vf.Trust == vfTrusted,
+				MinRate:    int(vf.MinTxRate),
+				MaxRate:    int(vf.MaxTxRate),
+			})
+		}
+		if resolver != nil && link.Type() == "device" {
+			if addr, err := resolver.BusAddress(attrs.Name); err == nil {
+				entry.BusAddress = addr
+			}
+		}
+		if vlan, ok := link.(*netlink.Vlan); ok {
+			entry.VlanID = vlan.VlanId
+		}
+		if bond, ok := link.(*netlink.Bond); ok {
+			entry.BondMode = bond.Mode.String()
+		}
+		if local, remote, ttl, ok := tunnelParams(link); ok {
+			entry.TunnelLocal = ipString(local)
+			entry.TunnelRemote = ipString(remote)
+			entry.TunnelTTL = int(ttl)
+		}
+		if macvlan, ok := link.(*netlink.Macvlan); ok {
+			entry.MACVLANMode = macvlanModes[macvlan.Mode]
+		}
+		if ipvlan, ok := link.(*netlink.IPVlan); ok {
+			entry.IPVLANMode = ipvlanModes[ipvlan.Mode]
+		}
+		if tuntap, ok := link.(*netlink.Tuntap); ok {
+			entry.TuntapMode = tuntap.Mode.String()
+		}
+		addrs, err := source.AddrList(link, netlink.FAMILY_ALL)
+		if err != nil {
+			return State{}, fmt.Errorf("list addresses for %s: %w", attrs.Name, err)
+		}
+		for _, addr := range addrs {
+			entry.Addresses = append(entry.Addresses, addr.IPNet.String())
+			entry.recordAddress(&addr)
+		}
+		neighs, err := source.NeighList(attrs.Index, netlink.FAMILY_ALL)
+		if err != nil {
+			return State{}, fmt.Errorf("list neighbors for %s: %w", attrs.Name, err)
+		}
+		for _, neigh := range neighs {
+			if neigh.State&netlink.NUD_PERMANENT == 0 {
+				continue
+			}
+			entry.Neighbors = append(entry.Neighbors, Neighbor{IP: neigh.IP.String(), MAC: neigh.HardwareAddr.String()})
+		}
+		routes, err := source.RouteList(link, netlink.FAMILY_ALL)
+		if err != nil {
+			return State{}, fmt.Errorf("list routes for %s: %w", attrs.Name, err)
+		}
+		for _, route := range routes {
+			if len(route.MultiPath) > 0 && route.LinkIndex != attrs.Index {
+				// Captured once, with the link of its first next hop.
+				continue
+			}
+			entry.Routes = append(entry.Routes, Route{
+				Destination: routeDestination(route),
+				Type:        config.RouteTypeName(route.Type),
+				Gateway:     ipString(route.Gw),
+				Nexthops:    toNexthops(route, func(index int) string { return names[index] }),
+				Metric:      route.Priority,
+				Protocol:    protocolName(route.Protocol),
+			})
+		}
+		state.Links = append(state.Links, entry)
+	}
+	return state, nil
+}
+
+func tunnelParams(link netlink.Link) (local, remote net.IP, ttl uint8, ok bool) {
+	switch tun := link.(type) {
+	case *netlink.Gretun:
+		return tun.Local, tun.Remote, tun.Ttl, true
+	case *netlink.Iptun:
+		return tun.Local, tun.Remote, tun.Ttl, true
+	case *netlink.Sittun:
+		return tun.Local, tun.Remote, tun.Ttl, true
+	}
+	return nil, nil, 0, false
+}
+
+// scopeNames names the address scopes other than global the way ip does.
+var scopeNames = map[int]string{
+	unix.RT_SCOPE_SITE:    "site",
+	unix.RT_SCOPE_LINK:    "link",
+	unix.RT_SCOPE_HOST:    "host",
+	unix.RT_SCOPE_NOWHERE: "nowhere",
+}
+
+// addressFlagNames names the lasting address flags the way configuration
+// files do, in the order they are listed.
+var addressFlagNames = []struct {
+	bit  int
+	name string
+}{
+	{unix.IFA_F_NOPREFIXROUTE, "noprefixroute"},
+	{unix.IFA_F_HOMEADDRESS, "home"},
+}
+
+// recordAddress keeps the scope, peer, label, lifetimes and flags of addr,
+// which the plain CIDRs in Addresses do not carry.
+func (l *Link) recordAddress(addr *netlink.Addr) {
+	cidr := addr.IPNet.String()
+	l.forgetAddress(cidr)
+	if name, ok := scopeNames[addr.Scope]; ok {
+		if l.Scopes == nil {
+			l.Scopes = make(map[string]string)
+		}
+		l.Scopes[cidr] = name
+	}
+	if addr.Peer != nil && !addr.Peer.IP.Equal(addr.IP) {
+		if l.Peers == nil {
+			l.Peers = make(map[string]string)
+		}
+		l.Peers[cidr] = addr.Peer.IP.String()
+	}
+	if addr.Label != "" && addr.Label != l.Name {
+		if l.Labels == nil {
+			l.Labels = make(map[string]string)
+		}
+		l.Labels[cidr] = addr.Label
+	}
+	lifetime := Lifetime{Valid: addr.ValidLft, Preferred: addr.PreferedLft}
+	if lifetime != (Lifetime{}) && lifetime != (Lifetime{Valid: lifetimeForever, Preferred: lifetimeForever}) {
+		if l.Lifetimes == nil {
+			l.Lifetimes = make(map[string]Lifetime)
+		}
+		l.Lifetimes[cidr] = lifetime
+	}
+	var flags []string
+	for _, flag := range addressFlagNames {
+		if addr.Flags&flag.bit != 0 {
+			flags = append(flags, flag.name)
+		}
+	}
+	if len(flags) > 0 {
+		if l.Flags == nil {
+			l.Flags = make(map[string][]string)
+		}
+		l.Flags[cidr] = flags
+	}
+}
+
+// forgetAddress drops what recordAddress kept about cidr.
+func (l *Link) forgetAddress(cidr string) {
+	delete(l.Scopes, cidr)
+	delete(l.Peers, cidr)
+	delete(l.Labels, cidr)
+	delete(l.Lifetimes, cidr)
+	delete(l.Flags, cidr)
+}
+
+// restoreAddress sets the scope, peer, label, lifetimes and flags recorded for
+// addr. IPv4 addresses without a recorded label have that of the link, as
+// the kernel labels them.
+func (l *Link) restoreAddress(addr *netlink.Addr) {
+	cidr := addr.IPNet.String()
+	for scope, name := range scopeNames {
+		if l.Scopes[cidr] == name {
+			addr.Scope = scope
+		}
+	}
+	if peer := net.ParseIP(l.Peers[cidr]); peer != nil {
+		addr.Peer = &net.IPNet{IP: peer, Mask: addr.Mask}
+	}
+	if label, ok := l.Labels[cidr]; ok {
+		addr.Label = label
+	} else if addr.IP.To4() != nil {
+		addr.Label = l.Name
+	}
+	if lifetime, ok := l.Lifetimes[cidr]; ok {
+		addr.ValidLft, addr.PreferedLft = lifetime.Valid, lifetime.Preferred
+	}
+	for _, flag := range addressFlagNames {
+		if slices.Contains(l.Flags[cidr], flag.name) {
+			addr.Flags |= flag.bit
+		}
+	}
+}
+
+// macvlanModes names the macvlan modes the same way configuration files do.
+var macvlanModes = map[netlink.MacvlanMode]string{
+	netlink.MACVLAN_MODE_PRIVATE:  "private",
+	netlink.MACVLAN_MODE_VEPA:     "vepa",
+	netlink.MACVLAN_MODE_BRIDGE:   "bridge",
+	netlink.MACVLAN_MODE_PASSTHRU: "passthru",
+	netlink.MACVLAN_MODE_SOURCE:   "source",
+}
+
+func macvlanMode(name string) netlink.MacvlanMode {
+	for mode, n := range macvlanModes {
+		if n == name {
+			return mode
+		}
+	}
+	return netlink.MACVLAN_MODE_DEFAULT
+}
+
+// ipvlanModes names the ipvlan modes the same way configuration files do.
+var ipvlanModes = map[netlink.IPVlanMode]string{
+	netlink.IPVLAN_MODE_L2:  "l2",
+	netlink.IPVLAN_MODE_L3:  "l3",
+	netlink.IPVLAN_MODE_L3S: "l3s",
+}
+
+func ipvlanMode(name string) netlink.IPVlanMode {
+	for mode, n := range ipvlanModes {
+		if n == name {
+			return mode
+		}
+	}
+	return netlink.IPVLAN_MODE_L2
+}
+
+// routeDestination renders the destination the way config.Route spells it.
+// The kernel reports default routes without a destination.
+func routeDestination(route netlink.Route) string {
+	switch {
+	case route.Dst != nil:
+		return route.Dst.String()
+	case route.Family == netlink.FAMILY_V6:
+		return "::/0"
+	}
+	return "0.0.0.0/0"
+}
+
+// toNexthops describes the next hops of a multipath route, naming their
+// links with name.
+func toNexthops(route netlink.Route, name func(index int) string) []Nexthop {
+	var hops []Nexthop
+	for _, hop := range route.MultiPath {
+		hops = append(hops, Nexthop{Gateway: ipString(hop.Gw), Device: name(hop.LinkIndex), Weight: hop.Hops + 1})
+	}
+	return hops
+}
+
+func protocolName(proto netlink.RouteProtocol) string {
+	if proto == config.RouteProtocol {
+		return "goeth"
+	}
+	return proto.String()
+}
+
+// protocolNumber reverses protocolName. Unknown protocols are captured by
+// number, which String also returns for them.
+func protocolNumber(name string) netlink.RouteProtocol {
+	for proto := netlink.RouteProtocol(0); proto <= maxRouteProtocol; proto++ {
+		if protocolName(proto) == name {
+			return proto
+		}
+	}
+	return unix.RTPROT_UNSPEC
+}
+
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// Load reads a State from a JSON file.
+func Load(path string) (State, error) {
+	if path == "" {
+		return State{}, errors.New("state path is required")
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return State{}, err
+	}
+	var state State
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return State{}, fmt.Errorf("parse state: %w", err)
+	}
+	return state, nil
+}
+
+// Write encodes state to w as indented JSON.
+func Write(w io.Writer, state State) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(state)
+}
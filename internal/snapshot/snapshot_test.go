@@ -0,0 +1,109 @@
+package snapshot
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/user/goeth/internal/config"
+)
+
+type stubSource struct {
+	links  []netlink.Link
+	addrs  map[string][]netlink.Addr
+	neighs map[int][]netlink.Neigh
+	routes map[int][]netlink.Route
+}
+
+func (s stubSource) LinkList() ([]netlink.Link, error) { return s.links, nil }
+
+func (s stubSource) AddrList(link netlink.Link, family int) ([]netlink.Addr, error) {
+	return s.addrs[link.Attrs().Name], nil
+}
+
+func (s stubSource) NeighList(linkIndex, family int) ([]netlink.Neigh, error) {
+	return s.neighs[linkIndex], nil
+}
+
+func (s stubSource) RouteList(link netlink.Link, family int) ([]netlink.Route, error) {
+	return s.routes[link.Attrs().Index], nil
+}
+
+func TestCaptureResolvesRelations(t *testing.T) {
+	addr, _ := netlink.ParseAddr("192.0.2.10/24")
+	mac, _ := net.ParseMAC("02:00:00:00:00:50")
+	source := stubSource{
+		links: []netlink.Link{
+			&netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 2, MTU: 1500, MasterIndex: 4}},
+			&netlink.Vlan{LinkAttrs: netlink.LinkAttrs{Name: "eth0.10", Index: 3, ParentIndex: 2}, VlanId: 10},
+			&netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: "br0", Index: 4}},
+		},
+		addrs: map[string][]netlink.Addr{"br0": {*addr}},
+		neighs: map[int][]netlink.Neigh{4: {
+			{IP: net.ParseIP("192.0.2.50"), HardwareAddr: mac, State: netlink.NUD_PERMANENT},
+			{IP: net.ParseIP("192.0.2.51"), HardwareAddr: mac, State: netlink.NUD_REACHABLE},
+		}},
+		routes: map[int][]netlink.Route{4: {
+			{Family: netlink.FAMILY_V4, Gw: net.ParseIP("192.0.2.1"), Protocol: config.RouteProtocol},
+			{Family: netlink.FAMILY_V4, Dst: addr.IPNet, Protocol: unix.RTPROT_KERNEL},
+		}},
+	}
+	state, err := Capture(source)
+	if err != nil {
+		t.Fatalf("Capture() error = %v", err)
+	}
+	want := []Link{
+		{Name: "eth0", Index: 2, Kind: "device", MTU: 1500, Master: "br0"},
+		{Name: "eth0.10", Index: 3, Kind: "vlan", Parent: "eth0", VlanID: 10},
+		{Name: "br0", Index: 4, Kind: "bridge", Addresses: []string{"192.0.2.10/24"},
+			Neighbors: []Neighbor{{IP: "192.0.2.50", MAC: "02:00:00:00:00:50"}},
+			Routes: []Route{
+				{Destination: "0.0.0.0/0", Gateway: "192.0.2.1", Protocol: "goeth"},
+				{Destination: "192.0.2.10/24", Protocol: "kernel"},
+			}},
+	}
+	if !reflect.DeepEqual(state.Links, want) {
+		t.Fatalf("Capture() = %#v, want %#v", state.Links, want)
+	}
+}
+
+func TestCaptureRequiresSource(t *testing.T) {
+	if _, err := Capture(nil); err == nil {
+		t.Fatal("expected error when source is missing")
+	}
+}
+
+func TestWriteLoadRoundTrip(t *testing.T) {
+	state := State{Links: []Link{{Name: "eth0", Index: 2, Kind: "device", Addresses: []string{"192.0.2.10/24"}}}}
+	var buf bytes.Buffer
+	if err := Write(&buf, state); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded, state) {
+		t.Fatalf("Load() = %#v, want %#v", loaded, state)
+	}
+}
+
+func TestLoadInvalidJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("not-json"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected parse error")
+	}
+}
@@ -0,0 +1,70 @@
+package snmp
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Permissions of engine boots files and of the directories made for them.
+const (
+	bootsFileMode = 0o644
+	bootsDirMode  = 0o755
+)
+
+// maxEngineBoots is where snmpEngineBoots stays once reached, as RFC 3414
+// 2.2.2 requires.
+const maxEngineBoots = 1<<31 - 1
+
+// nextBoots counts a boot of the engine engineID in the file at path, making
+// its directory when missing, and returns the count: the snmpEngineBoots of
+// this run. The file holds the engine ID in hex and the latest count; a
+// missing file, or one kept for another engine ID, starts the count at 1.
+func nextBoots(path string, engineID []byte) (uint32, error) {
+	var boots uint64
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return 0, fmt.Errorf("engine boots: %w", err)
+	default:
+		fields := strings.Fields(string(data))
+		if len(fields) != 2 {
+			return 0, fmt.Errorf("engine boots: %s is not an engine ID and a count", path)
+		}
+		if fields[0] == hex.EncodeToString(engineID) {
+			if boots, err = strconv.ParseUint(fields[1], 10, 31); err != nil {
+				return 0, fmt.Errorf("engine boots: %s: %w", path, err)
+			}
+		}
+	}
+	boots = min(boots+1, maxEngineBoots)
+
+	if err := os.MkdirAll(filepath.Dir(path), bootsDirMode); err != nil {
+		return 0, fmt.Errorf("engine boots: %w", err)
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return 0, fmt.Errorf("engine boots: %w", err)
+	}
+	_, err = fmt.Fprintf(temp, "%x %d\n", engineID, boots)
+	if err == nil {
+		err = temp.Chmod(bootsFileMode)
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return 0, fmt.Errorf("engine boots: %w", err)
+	}
+	return uint32(boots), nil
+}
@@ -0,0 +1,52 @@
+package snmp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDialCountsEngineBootsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goeth", "engine-boots")
+	config := Config{Target: "127.0.0.1:0", Version: V3, User: "goeth", EngineID: TextEngineID("edge-1"), BootsFile: path}
+	for want := uint32(1); want <= 3; want++ {
+		sender, err := Dial(config, 1)
+		if err != nil {
+			t.Fatalf("Dial() error = %v", err)
+		}
+		sender.conn.Close()
+		if sender.usm.boots != want {
+			t.Errorf("start %d: engine boots = %d, want %d", want, sender.usm.boots, want)
+		}
+	}
+
+	config.EngineID = TextEngineID("edge-2")
+	sender, err := Dial(config, 1)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	sender.conn.Close()
+	if sender.usm.boots != 1 {
+		t.Errorf("engine boots of a new engine ID = %d, want 1", sender.usm.boots)
+	}
+}
+
+func TestNextBootsStopsAtTheLargestCount(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "engine-boots")
+	if err := os.WriteFile(path, []byte("80001f880465646765 2147483647\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if boots, err := nextBoots(path, []byte{0x80, 0x00, 0x1f, 0x88, 0x04, 'e', 'd', 'g', 'e'}); err != nil || boots != maxEngineBoots {
+		t.Errorf("nextBoots() = %d, %v, want %d", boots, err, maxEngineBoots)
+	}
+}
+
+func TestNextBootsRejectsAnUnreadableFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "engine-boots")
+	if err := os.WriteFile(path, []byte("not-a-count\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if _, err := nextBoots(path, TextEngineID("edge-1")); err == nil {
+		t.Error("nextBoots() accepted a malformed file")
+	}
+}
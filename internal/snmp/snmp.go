@@ -0,0 +1,324 @@
+// Package snmp sends SNMPv2c and SNMPv3 notifications, encoding just enough
+// of BER and the user-based security model for goeth to feed network
+// management systems without an SNMP library.
+package snmp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/goeth/internal/queue"
+)
+
+// defaultPort is the trap port used when a target names none.
+const defaultPort = "162"
+
+// Versions of the protocol a Sender speaks.
+const (
+	V2c = "2c"
+	V3  = "3"
+)
+
+// BER tags of the types used in notifications.
+const (
+	tagInteger   = 0x02
+	tagOctets    = 0x04
+	tagOID       = 0x06
+	tagSequence  = 0x30
+	tagTimeTicks = 0x43
+	tagTrapPDU   = 0xa7
+)
+
+// Wire values of the message header.
+const (
+	versionV2c = 1
+	versionV3  = 3
+
+	// maxMessageSize is the largest message goeth takes, the most a UDP
+	// datagram carries.
+	maxMessageSize = 65507
+	securityUSM    = 3
+
+	// maxRequestID keeps request and message IDs within Integer32.
+	maxRequestID = 1<<31 - 1
+)
+
+// Objects every SNMPv2 notification starts with.
+const (
+	sysUpTime   OID = "1.3.6.1.2.1.1.3.0"
+	snmpTrapOID OID = "1.3.6.1.6.3.1.1.4.1.0"
+)
+
+// OID is an object identifier in dotted form, such as 1.3.6.1.6.3.1.1.5.3.
+type OID string
+
+// TimeTicks is a duration in hundredths of a second.
+type TimeTicks uint32
+
+// VarBind names an object and its value: an int, a string, an OID or
+// TimeTicks.
+type VarBind struct {
+	Name  OID
+	Value any
+}
+
+// Trap is a notification: its identity and the objects that go with it.
+// sysUpTime and snmpTrapOID are added by the Sender.
+type Trap struct {
+	OID      OID
+	VarBinds []VarBind
+}
+
+// Config says where notifications go and how they are secured.
+type Config struct {
+	// Target is the receiver, host[:port] with port 162 by default.
+	Target string
+	// Version is V2c or V3.
+	Version string
+	// Community is the SNMPv2c community.
+	Community string
+	// User is the SNMPv3 user name.
+	User string
+	// Auth is the SNMPv3 authentication protocol, md5 or sha, and
+	// AuthPassword its pass phrase. Without one messages are neither
+	// authenticated nor encrypted.
+	Auth         string
+	AuthPassword string
+	// Priv is the SNMPv3 privacy protocol, des or aes, and PrivPassword its
+	// pass phrase. Privacy requires authentication.
+	Priv         string
+	PrivPassword string
+	// EngineID identifies goeth as the authoritative engine of its SNMPv3
+	// notifications; receivers configure users against it. It is derived
+	// from the host name when empty.
+	EngineID []byte
+	// BootsFile keeps snmpEngineBoots across restarts, so that receivers
+	// can tell replayed SNMPv3 messages from those of a newer run; each Dial
+	// counts a boot. Without one snmpEngineBoots is always 1.
+	BootsFile string
+}
+
+// Sender queues notifications and sends them in the background.
+type Sender struct {
+	*queue.Queue[Trap]
+	conn    net.Conn
+	config  Config
+	usm     *usm
+	start   time.Time
+	request uint32
+}
+
+// Dial checks config, connects to its target and returns a Sender holding
+// up to size unsent notifications.
+func Dial(config Config, size int) (*Sender, error) {
+	s := &Sender{Queue: queue.New[Trap]("snmp", size), config: config, start: time.Now()}
+	switch config.Version {
+	case V2c:
+		if config.Community == "" {
+			return nil, errors.New("snmp: a community is required for version 2c")
+		}
+	case V3:
+		if len(s.config.EngineID) == 0 {
+			host, _ := os.Hostname()
+			s.config.EngineID = TextEngineID(host)
+		}
+		if n := len(s.config.EngineID); n < minEngineID || n > maxEngineID {
+			return nil, fmt.Errorf("snmp: an engine ID is %d to %d octets long, not %d", minEngineID, maxEngineID, n)
+		}
+		security, err := newUSM(s.config)
+		if err != nil {
+			return nil, fmt.Errorf("snmp: %w", err)
+		}
+		if config.BootsFile != "" {
+			if security.boots, err = nextBoots(config.BootsFile, s.config.EngineID); err != nil {
+				return nil, fmt.Errorf("snmp: %w", err)
+			}
+		}
+		s.usm = security
+	default:
+		return nil, fmt.Errorf("snmp: unknown version %q (want %s or %s)", config.Version, V2c, V3)
+	}
+	var id [4]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, fmt.Errorf("snmp: %w", err)
+	}
+	s.request = binary.BigEndian.Uint32(id[:]) & maxRequestID
+	target := config.Target
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(strings.Trim(target, "[]"), defaultPort)
+	}
+	conn, err := net.Dial("udp", target)
+	if err != nil {
+		return nil, fmt.Errorf("snmp: %w", err)
+	}
+	s.conn = conn
+	return s, nil
+}
+
+// enterprise is the private enterprise number in goeth's engine IDs. goeth
+// has none of its own, so it uses that of Net-SNMP, whose playpen subtree
+// is meant for local objects such as goeth's.
+const enterprise = 8072
+
+// The lengths RFC 3411 allows engine IDs.
+const (
+	minEngineID = 5
+	maxEngineID = 32
+)
+
+// TextEngineID returns the RFC 3411 engine ID made of text, such as a host
+// name, cut to the octets that fit.
+func TextEngineID(text string) []byte {
+	const formatText, maxText = 4, maxEngineID - 5
+	if len(text) > maxText {
+		text = text[:maxText]
+	}
+	id := binary.BigEndian.AppendUint32(nil, 0x80000000|enterprise)
+	return append(append(id, formatText), text...)
+}
+
+// Run sends queued notifications until ctx is cancelled, then closes the
+// connection.
+func (s *Sender) Run(ctx context.Context) error {
+	defer s.conn.Close()
+	return s.Queue.Run(ctx, func(_ context.Context, trap Trap) error {
+		msg, err := s.encode(trap, time.Since(s.start))
+		if err == nil {
+			_, err = s.conn.Write(msg)
+		}
+		if err != nil {
+			return fmt.Errorf("snmp: %w", err)
+		}
+		return nil
+	})
+}
+
+// encode returns the message carrying trap, uptime after the Sender
+// started.
+func (s *Sender) encode(trap Trap, uptime time.Duration) ([]byte, error) {
+	s.request = (s.request + 1) & maxRequestID
+	pdu, err := trapPDU(s.request, trap, TimeTicks(uptime/(10*time.Millisecond)))
+	if err != nil {
+		return nil, err
+	}
+	if s.usm == nil {
+		return tlv(tagSequence, integer(versionV2c), octets(s.config.Community), pdu), nil
+	}
+	return s.usm.message(s.request, uptime, pdu)
+}
+
+// trapPDU encodes an SNMPv2-Trap-PDU.
+func trapPDU(request uint32, trap Trap, uptime TimeTicks) ([]byte, error) {
+	binds := append([]VarBind{{sysUpTime, uptime}, {snmpTrapOID, trap.OID}}, trap.VarBinds...)
+	var list [][]byte
+	for _, bind := range binds {
+		name, err := oid(bind.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := encodeValue(bind.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", bind.Name, err)
+		}
+		list = append(list, tlv(tagSequence, name, value))
+	}
+	return tlv(tagTrapPDU, integer(int64(request)), integer(0), integer(0), tlv(tagSequence, list...)), nil
+}
+
+func encodeValue(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case int:
+		return integer(int64(v)), nil
+	case string:
+		return octets(v), nil
+	case OID:
+		return oid(v)
+	case TimeTicks:
+		return unsigned(tagTimeTicks, uint32(v)), nil
+	}
+	return nil, fmt.Errorf("unsupported value type %T", value)
+}
+
+// tlv encodes a value of the given tag made of the parts given.
+func tlv(tag byte, parts ...[]byte) []byte {
+	var content []byte
+	for _, part := range parts {
+		content = append(content, part...)
+	}
+	return append(append([]byte{tag}, length(len(content))...), content...)
+}
+
+// length encodes a BER length in the short form below 128 and the long
+// form from there.
+func length(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var digits []byte
+	for ; n > 0; n >>= 8 {
+		digits = append([]byte{byte(n)}, digits...)
+	}
+	return append([]byte{0x80 | byte(len(digits))}, digits...)
+}
+
+// integer encodes v in the fewest two's complement octets.
+func integer(v int64) []byte {
+	content := []byte{byte(v)}
+	for rest := v >> 8; ; rest >>= 8 {
+		sign := content[0] & 0x80
+		if (rest == 0 && sign == 0) || (rest == -1 && sign != 0) {
+			break
+		}
+		content = append([]byte{byte(rest)}, content...)
+	}
+	return tlv(tagInteger, content)
+}
+
+// unsigned encodes an application type holding an unsigned 32-bit value.
+func unsigned(tag byte, v uint32) []byte {
+	content := binary.BigEndian.AppendUint32([]byte{0}, v)
+	for len(content) > 1 && content[0] == 0 && content[1]&0x80 == 0 {
+		content = content[1:]
+	}
+	return tlv(tag, content)
+}
+
+func octets(s string) []byte {
+	return tlv(tagOctets, []byte(s))
+}
+
+// oid encodes an object identifier, whose first two arcs share an octet.
+func oid(o OID) ([]byte, error) {
+	fields := strings.Split(string(o), ".")
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("invalid OID %q", o)
+	}
+	arcs := make([]uint32, len(fields))
+	for i, field := range fields {
+		arc, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OID %q", o)
+		}
+		arcs[i] = uint32(arc)
+	}
+	if arcs[0] > 2 || (arcs[0] < 2 && arcs[1] >= 40) {
+		return nil, fmt.Errorf("invalid OID %q", o)
+	}
+	var content []byte
+	for _, arc := range append([]uint32{arcs[0]*40 + arcs[1]}, arcs[2:]...) {
+		group := []byte{byte(arc & 0x7f)}
+		for arc >>= 7; arc > 0; arc >>= 7 {
+			group = append([]byte{0x80 | byte(arc&0x7f)}, group...)
+		}
+		content = append(content, group...)
+	}
+	return tlv(tagOID, content), nil
+}
@@ -0,0 +1,159 @@
+package snmp
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// next splits the first BER value off b.
+func next(t *testing.T, b []byte) (tag byte, content, rest []byte) {
+	t.Helper()
+	if len(b) < 2 {
+		t.Fatalf("truncated value % x", b)
+	}
+	n, at := int(b[1]), 2
+	if n >= 0x80 {
+		digits := n & 0x7f
+		n = 0
+		for _, d := range b[2 : 2+digits] {
+			n = n<<8 | int(d)
+		}
+		at += digits
+	}
+	if len(b) < at+n {
+		t.Fatalf("truncated value % x", b)
+	}
+	return b[0], b[at : at+n], b[at+n:]
+}
+
+// fields splits a constructed value into the values it is made of.
+func fields(t *testing.T, content []byte) [][]byte {
+	t.Helper()
+	var list [][]byte
+	for len(content) > 0 {
+		var part []byte
+		_, part, content = next(t, content)
+		list = append(list, part)
+	}
+	return list
+}
+
+func TestEncodeValues(t *testing.T) {
+	mustOID := func(o OID) []byte {
+		b, err := oid(o)
+		if err != nil {
+			t.Fatalf("oid(%s) error = %v", o, err)
+		}
+		return b
+	}
+	tests := []struct {
+		name string
+		got  []byte
+		want []byte
+	}{
+		{"integer 0", integer(0), []byte{0x02, 0x01, 0x00}},
+		{"integer 127", integer(127), []byte{0x02, 0x01, 0x7f}},
+		{"integer 128", integer(128), []byte{0x02, 0x02, 0x00, 0x80}},
+		{"integer 256", integer(256), []byte{0x02, 0x02, 0x01, 0x00}},
+		{"integer -1", integer(-1), []byte{0x02, 0x01, 0xff}},
+		{"integer -129", integer(-129), []byte{0x02, 0x02, 0xff, 0x7f}},
+		{"timeticks 0", unsigned(tagTimeTicks, 0), []byte{0x43, 0x01, 0x00}},
+		{"timeticks 128", unsigned(tagTimeTicks, 128), []byte{0x43, 0x02, 0x00, 0x80}},
+		{"long length", length(200), []byte{0x81, 0xc8}},
+		{"longer length", length(300), []byte{0x82, 0x01, 0x2c}},
+		{"sysUpTime", mustOID(sysUpTime), []byte{0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x03, 0x00}},
+		{"multi-octet arc", mustOID("1.3.6.1.4.1.8072"), []byte{0x06, 0x07, 0x2b, 0x06, 0x01, 0x04, 0x01, 0xbf, 0x08}},
+	}
+	for _, tt := range tests {
+		if !bytes.Equal(tt.got, tt.want) {
+			t.Errorf("%s: got % x, want % x", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestOIDRejectsInvalid(t *testing.T) {
+	for _, o := range []OID{"", "1", "1.3.x", "3.1", "1.40", "1.3.-6"} {
+		if _, err := oid(o); err == nil {
+			t.Errorf("oid(%q) accepted an invalid OID", o)
+		}
+	}
+}
+
+func TestDialChecksConfig(t *testing.T) {
+	tests := []Config{
+		{Target: "192.0.2.162", Version: "1", Community: "public"},
+		{Target: "192.0.2.162", Version: V2c},
+		{Target: "192.0.2.162", Version: V3},
+		{Target: "192.0.2.162", Version: V3, User: "goeth", EngineID: []byte{0x80, 0x00}},
+		{Target: "192.0.2.162", Version: V3, User: "goeth", Priv: "aes", PrivPassword: "example-privacy"},
+		{Target: "192.0.2.162", Version: V3, User: "goeth", Auth: "sha256", AuthPassword: "example-auth"},
+		{Target: "192.0.2.162", Version: V3, User: "goeth", Auth: "sha", AuthPassword: "short"},
+		{Target: "192.0.2.162", Version: V3, User: "goeth", Auth: "sha", AuthPassword: "example-auth", Priv: "3des", PrivPassword: "example-privacy"},
+		{Target: "192.0.2.162", Version: V3, User: "goeth", Auth: "sha", AuthPassword: "example-auth", Priv: "aes"},
+	}
+	for _, config := range tests {
+		if _, err := Dial(config, 1); err == nil {
+			t.Errorf("Dial(%+v) accepted an invalid configuration", config)
+		}
+	}
+}
+
+func TestTextEngineID(t *testing.T) {
+	if got, want := TextEngineID("edge-1"), []byte{0x80, 0x00, 0x1f, 0x88, 0x04, 'e', 'd', 'g', 'e', '-', '1'}; !bytes.Equal(got, want) {
+		t.Fatalf("TextEngineID() = % x, want % x", got, want)
+	}
+	if got := TextEngineID("a-very-long-host-name.example.com"); len(got) != 32 {
+		t.Fatalf("TextEngineID() is %d octets long, want 32", len(got))
+	}
+}
+
+func TestSenderSendsV2cTraps(t *testing.T) {
+	receiver, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() error = %v", err)
+	}
+	defer receiver.Close()
+	sender, err := Dial(Config{Target: receiver.LocalAddr().String(), Version: V2c, Community: "example"}, 1)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sender.Run(ctx)
+	sender.Send(Trap{OID: "1.3.6.1.6.3.1.1.5.3", VarBinds: []VarBind{{"1.3.6.1.2.1.2.2.1.1.2", 2}, {"1.3.6.1.2.1.2.2.1.2.2", "eth0"}}})
+
+	receiver.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, maxMessageSize)
+	n, _, err := receiver.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom() error = %v", err)
+	}
+	tag, content, _ := next(t, buf[:n])
+	if tag != tagSequence {
+		t.Fatalf("message tag = %#x", tag)
+	}
+	_, version, rest := next(t, content)
+	_, community, rest := next(t, rest)
+	if !bytes.Equal(version, []byte{versionV2c}) || string(community) != "example" {
+		t.Fatalf("unexpected message header % x", content)
+	}
+	tag, body, _ := next(t, rest)
+	if tag != tagTrapPDU {
+		t.Fatalf("PDU tag = %#x, want %#x", tag, tagTrapPDU)
+	}
+	pdu := fields(t, body)
+	binds := fields(t, pdu[3])
+	if len(binds) != 4 {
+		t.Fatalf("got %d varbinds, want sysUpTime, snmpTrapOID and the trap's 2", len(binds))
+	}
+	trapOID, _ := oid("1.3.6.1.6.3.1.1.5.3")
+	if !bytes.HasSuffix(binds[1], trapOID) {
+		t.Fatalf("snmpTrapOID varbind % x does not carry the trap OID", binds[1])
+	}
+	if !bytes.HasSuffix(binds[3], octets("eth0")) {
+		t.Fatalf("last varbind % x does not carry the interface name", binds[3])
+	}
+}
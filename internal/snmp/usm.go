@@ -0,0 +1,206 @@
+package snmp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"time"
+)
+
+// Message flags of the SNMPv3 header.
+const (
+	flagAuth = 0x01
+	flagPriv = 0x02
+)
+
+// USM parameters goeth uses as the authoritative engine of its
+// notifications.
+const (
+	// macSize is the length of HMAC-MD5-96 and HMAC-SHA-96 codes.
+	macSize = 12
+	// minPassword is the shortest pass phrase RFC 3414 allows.
+	minPassword = 8
+	// expandedPassword is how much of the repeated pass phrase is hashed
+	// into a key.
+	expandedPassword = 1 << 20
+)
+
+// usm secures SNMPv3 messages with the user-based security model of
+// RFC 3414, and AES of RFC 3826.
+type usm struct {
+	engineID []byte
+	// boots is snmpEngineBoots, how many times the engine has started.
+	boots   uint32
+	user    string
+	flags   byte
+	hash    func() hash.Hash
+	authKey []byte
+	priv    string
+	privKey []byte
+	// salt makes each encryption unique.
+	salt uint64
+}
+
+func newUSM(config Config) (*usm, error) {
+	if config.User == "" {
+		return nil, errors.New("a user is required for version 3")
+	}
+	u := &usm{engineID: config.EngineID, boots: 1, user: config.User}
+	switch config.Auth {
+	case "":
+		if config.Priv != "" {
+			return nil, errors.New("privacy requires authentication")
+		}
+		return u, nil
+	case "md5":
+		u.hash = md5.New
+	case "sha":
+		u.hash = sha1.New
+	default:
+		return nil, fmt.Errorf("unknown authentication protocol %q (want md5 or sha)", config.Auth)
+	}
+	if len(config.AuthPassword) < minPassword {
+		return nil, fmt.Errorf("the authentication pass phrase must be at least %d characters", minPassword)
+	}
+	u.flags = flagAuth
+	u.authKey = localizeKey(u.hash, config.AuthPassword, u.engineID)
+	switch config.Priv {
+	case "":
+		return u, nil
+	case "des", "aes":
+		u.priv = config.Priv
+	default:
+		return nil, fmt.Errorf("unknown privacy protocol %q (want des or aes)", config.Priv)
+	}
+	if len(config.PrivPassword) < minPassword {
+		return nil, fmt.Errorf("the privacy pass phrase must be at least %d characters", minPassword)
+	}
+	u.flags |= flagPriv
+	u.privKey = localizeKey(u.hash, config.PrivPassword, u.engineID)
+	var salt [8]byte
+	if _, err := rand.Read(salt[:]); err != nil {
+		return nil, err
+	}
+	u.salt = binary.BigEndian.Uint64(salt[:])
+	return u, nil
+}
+
+// localizeKey turns a pass phrase into the key of a user at engineID, as
+// RFC 3414 A.2 describes.
+func localizeKey(newHash func() hash.Hash, password string, engineID []byte) []byte {
+	h := newHash()
+	repeated := make([]byte, 0, expandedPassword+len(password))
+	for len(repeated) < expandedPassword {
+		repeated = append(repeated, password...)
+	}
+	h.Write(repeated[:expandedPassword])
+	key := h.Sum(nil)
+	h.Reset()
+	h.Write(key)
+	h.Write(engineID)
+	h.Write(key)
+	return h.Sum(nil)
+}
+
+// message wraps pdu in an SNMPv3 message, encrypting and authenticating it
+// as the user's security level requires.
+func (u *usm) message(id uint32, uptime time.Duration, pdu []byte) ([]byte, error) {
+	engineTime := uint32(uptime / time.Second)
+	data := tlv(tagSequence, tlv(tagOctets, u.engineID), octets(""), pdu)
+	var privParams []byte
+	if u.flags&flagPriv != 0 {
+		var err error
+		if privParams, data, err = u.encrypt(data, engineTime); err != nil {
+			return nil, err
+		}
+		data = tlv(tagOctets, data)
+	}
+	var authParams []byte
+	if u.flags&flagAuth != 0 {
+		authParams = make([]byte, macSize)
+	}
+	leading := [][]byte{tlv(tagOctets, u.engineID), integer(int64(u.boots)), integer(int64(engineTime)), octets(u.user)}
+	security := tlv(tagSequence, append(leading, tlv(tagOctets, authParams), tlv(tagOctets, privParams))...)
+	wrapped := tlv(tagOctets, security)
+	header := tlv(tagSequence, integer(int64(id)), integer(maxMessageSize), tlv(tagOctets, []byte{u.flags}), integer(securityUSM))
+	msg := tlv(tagSequence, integer(versionV3), header, wrapped, data)
+	if u.flags&flagAuth == 0 {
+		return msg, nil
+	}
+	// The code covers the whole message with its own place zeroed, so it is
+	// computed last and written into that place.
+	at := len(msg) - len(data) - len(wrapped) + headerLength(wrapped) + headerLength(security)
+	for _, part := range leading {
+		at += len(part)
+	}
+	at += headerLength(tlv(tagOctets, authParams))
+	mac := hmac.New(u.hash, u.authKey)
+	mac.Write(msg)
+	copy(msg[at:], mac.Sum(nil)[:macSize])
+	return msg, nil
+}
+
+// headerLength is how many octets of an encoded value precede its content.
+func headerLength(encoded []byte) int {
+	if encoded[1] < 0x80 {
+		return 2
+	}
+	return 2 + int(encoded[1]&0x7f)
+}
+
+// encrypt encrypts a scoped PDU, returning the privacy parameters the
+// receiver decrypts it with.
+func (u *usm) encrypt(plain []byte, engineTime uint32) (params, encrypted []byte, err error) {
+	u.salt++
+	if u.priv == "aes" {
+		// RFC 3826: 128-bit CFB with the engine boots, time and salt as IV.
+		block, err := aes.NewCipher(u.privKey[:16])
+		if err != nil {
+			return nil, nil, err
+		}
+		params = binary.BigEndian.AppendUint64(nil, u.salt)
+		iv := binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, u.boots), engineTime)
+		return params, cfbEncrypt(block, append(iv, params...), plain), nil
+	}
+	// RFC 3414 8.1.1: CBC with the pre-IV of the key mixed with the salt.
+	block, err := des.NewCipher(u.privKey[:8])
+	if err != nil {
+		return nil, nil, err
+	}
+	params = binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, u.boots), uint32(u.salt))
+	iv := make([]byte, des.BlockSize)
+	for i := range iv {
+		iv[i] = u.privKey[8+i] ^ params[i]
+	}
+	padded := append([]byte(nil), plain...)
+	for len(padded)%des.BlockSize != 0 {
+		padded = append(padded, 0)
+	}
+	encrypted = make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, padded)
+	return params, encrypted, nil
+}
+
+// cfbEncrypt encrypts plain in full-block cipher feedback mode.
+func cfbEncrypt(block cipher.Block, iv, plain []byte) []byte {
+	out := make([]byte, len(plain))
+	register := append([]byte(nil), iv...)
+	stream := make([]byte, block.BlockSize())
+	for start := 0; start < len(plain); start += block.BlockSize() {
+		block.Encrypt(stream, register)
+		end := min(start+block.BlockSize(), len(plain))
+		for i := start; i < end; i++ {
+			out[i] = plain[i] ^ stream[i-start]
+		}
+		copy(register, out[start:end])
+	}
+	return out
+}
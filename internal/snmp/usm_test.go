@@ -0,0 +1,113 @@
+package snmp
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func TestLocalizeKeyFollowsRFC3414(t *testing.T) {
+	engineID, _ := hex.DecodeString("000000000000000000000002")
+	if got := hex.EncodeToString(localizeKey(md5.New, "maplesyrup", engineID)); got != "526f5eed9fcce26f8964c2930787d82b" {
+		t.Errorf("MD5 key = %s", got)
+	}
+	if got := hex.EncodeToString(localizeKey(sha1.New, "maplesyrup", engineID)); got != "6695febc9288e36282235fc7151f128497b38f3f" {
+		t.Errorf("SHA key = %s", got)
+	}
+}
+
+// decrypt reverses usm.encrypt for the tests.
+func decrypt(t *testing.T, u *usm, params, encrypted []byte, engineTime uint32) []byte {
+	t.Helper()
+	plain := make([]byte, len(encrypted))
+	if u.priv == "aes" {
+		block, _ := aes.NewCipher(u.privKey[:16])
+		iv := append([]byte{0, 0, 0, byte(u.boots), byte(engineTime >> 24), byte(engineTime >> 16), byte(engineTime >> 8), byte(engineTime)}, params...)
+		cipher.NewCFBDecrypter(block, iv).XORKeyStream(plain, encrypted)
+		return plain
+	}
+	block, _ := des.NewCipher(u.privKey[:8])
+	iv := make([]byte, des.BlockSize)
+	for i := range iv {
+		iv[i] = u.privKey[8+i] ^ params[i]
+	}
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, encrypted)
+	return plain
+}
+
+func TestMessageAuthenticatesAndEncrypts(t *testing.T) {
+	engineID := TextEngineID("edge-1")
+	pdu, err := trapPDU(7, Trap{OID: "1.3.6.1.6.3.1.1.5.4"}, 4200)
+	if err != nil {
+		t.Fatalf("trapPDU() error = %v", err)
+	}
+	for _, priv := range []string{"", "des", "aes"} {
+		config := Config{User: "goeth", Auth: "sha", AuthPassword: "example-auth", EngineID: engineID}
+		if priv != "" {
+			config.Priv, config.PrivPassword = priv, "example-privacy"
+		}
+		u, err := newUSM(config)
+		if err != nil {
+			t.Fatalf("newUSM(%s) error = %v", priv, err)
+		}
+		msg, err := u.message(7, 42*time.Second, pdu)
+		if err != nil {
+			t.Fatalf("message(%s) error = %v", priv, err)
+		}
+
+		_, content, _ := next(t, msg)
+		parts := fields(t, content)
+		if len(parts) != 4 || !bytes.Equal(parts[0], []byte{versionV3}) {
+			t.Fatalf("%s: unexpected message % x", priv, msg)
+		}
+		header := fields(t, parts[1])
+		if want := byte(flagAuth); priv != "" && header[2][0] != want|flagPriv || priv == "" && header[2][0] != want {
+			t.Errorf("%s: flags = %#x", priv, header[2][0])
+		}
+		_, security, _ := next(t, parts[2])
+		params := fields(t, security)
+		if !bytes.Equal(params[0], engineID) || !bytes.Equal(params[1], []byte{byte(u.boots)}) || !bytes.Equal(params[2], []byte{42}) || string(params[3]) != "goeth" {
+			t.Errorf("%s: unexpected security parameters % x", priv, security)
+		}
+
+		code := append([]byte(nil), params[4]...)
+		zeroed := bytes.Replace(msg, code, make([]byte, macSize), 1)
+		mac := hmac.New(sha1.New, u.authKey)
+		mac.Write(zeroed)
+		if !bytes.Equal(code, mac.Sum(nil)[:macSize]) {
+			t.Errorf("%s: the message is not authenticated with the user's key", priv)
+		}
+
+		scoped := parts[3]
+		if priv != "" {
+			_, scoped, _ = next(t, decrypt(t, u, params[5], parts[3], 42))
+		}
+		within := fields(t, scoped)
+		if !bytes.Equal(within[0], engineID) || len(within[1]) != 0 || !bytes.Equal(within[2], pdu[2:]) {
+			t.Errorf("%s: unexpected scoped PDU % x", priv, scoped)
+		}
+	}
+}
+
+func TestMessageWithoutAuthentication(t *testing.T) {
+	u, err := newUSM(Config{User: "goeth", EngineID: TextEngineID("edge-1")})
+	if err != nil {
+		t.Fatalf("newUSM() error = %v", err)
+	}
+	msg, err := u.message(1, 0, []byte{tagTrapPDU, 0})
+	if err != nil {
+		t.Fatalf("message() error = %v", err)
+	}
+	_, content, _ := next(t, msg)
+	parts := fields(t, content)
+	if header := fields(t, parts[1]); header[2][0] != 0 {
+		t.Fatalf("flags = %#x, want noAuthNoPriv", header[2][0])
+	}
+}
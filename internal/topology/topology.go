@@ -0,0 +1,186 @@
+// Package topology renders the links of a captured state as a diagram:
+// bridges and bonds with their ports, VLANs and other stacked links on top
+// of their parents, veth pairs, and the addresses of each link.
+package topology
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/user/goeth/internal/snapshot"
+)
+
+// Output formats.
+const (
+	DOT     = "dot"
+	Mermaid = "mermaid"
+)
+
+// node is a link of the diagram.
+type node struct {
+	id    int
+	lines []string
+	down  bool
+}
+
+// edge connects an upper link, such as a bridge or a VLAN, to the link below
+// it. Undirected edges join the two ends of a veth pair.
+type edge struct {
+	from, to   int
+	label      string
+	undirected bool
+}
+
+// graph is the format-independent diagram of a state.
+type graph struct {
+	nodes []node
+	edges []edge
+}
+
+// Render writes the diagram of state to w in format, DOT or Mermaid.
+func Render(w io.Writer, state snapshot.State, format string) error {
+	g := build(state)
+	switch format {
+	case DOT:
+		return g.dot(w)
+	case Mermaid:
+		return g.mermaid(w)
+	}
+	return fmt.Errorf("unknown diagram format %q, want %s or %s", format, DOT, Mermaid)
+}
+
+func build(state snapshot.State) graph {
+	links := append([]snapshot.Link(nil), state.Links...)
+	sort.Slice(links, func(i, j int) bool { return links[i].Index < links[j].Index })
+	byName := make(map[string]snapshot.Link, len(links))
+	for _, link := range links {
+		byName[link.Name] = link
+	}
+	var g graph
+	for _, link := range links {
+		g.nodes = append(g.nodes, node{id: link.Index, lines: describe(link), down: !link.Up})
+		if master, ok := byName[link.Master]; ok {
+			g.edges = append(g.edges, edge{from: master.Index, to: link.Index, label: portLabel(master.Kind)})
+		}
+		parent, ok := byName[link.Parent]
+		switch {
+		case !ok:
+		case link.Kind == "veth":
+			// Both ends name each other; draw the pair once.
+			if parent.Parent != link.Name || link.Index < parent.Index {
+				g.edges = append(g.edges, edge{from: link.Index, to: parent.Index, label: "peer", undirected: true})
+			}
+		case link.Kind == "vlan":
+			g.edges = append(g.edges, edge{from: link.Index, to: parent.Index, label: fmt.Sprintf("vlan %d", link.VlanID)})
+		default:
+			g.edges = append(g.edges, edge{from: link.Index, to: parent.Index, label: link.Kind})
+		}
+	}
+	return g
+}
+
+// describe lists the name, kind, MTU and addresses of link, one per line.
+func describe(link snapshot.Link) []string {
+	kind := link.Kind
+	switch {
+	case link.BondMode != "":
+		kind += " " + link.BondMode
+	case link.MACVLANMode != "":
+		kind += " " + link.MACVLANMode
+	case link.IPVLANMode != "":
+		kind += " " + link.IPVLANMode
+	case link.TunnelRemote != "":
+		kind += " to " + link.TunnelRemote
+	}
+	details := kind
+	if link.MTU != 0 {
+		details += fmt.Sprintf(", mtu %d", link.MTU)
+	}
+	if !link.Up {
+		details += ", down"
+	}
+	return append([]string{link.Name, details}, link.Addresses...)
+}
+
+func portLabel(masterKind string) string {
+	switch masterKind {
+	case "bridge":
+		return "port"
+	case "bond":
+		return "slave"
+	}
+	return "member"
+}
+
+func (g graph) dot(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph topology {\n")
+	b.WriteString("  rankdir=TB;\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, n := range g.nodes {
+		escaped := make([]string, len(n.lines))
+		for i, line := range n.lines {
+			escaped[i] = dotEscape(line)
+		}
+		style := ""
+		if n.down {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&b, "  n%d [label=\"%s\"%s];\n", n.id, strings.Join(escaped, `\n`), style)
+	}
+	for _, e := range g.edges {
+		attrs := fmt.Sprintf("label=\"%s\"", dotEscape(e.label))
+		if e.undirected {
+			attrs += ", dir=none"
+		}
+		fmt.Fprintf(&b, "  n%d -> n%d [%s];\n", e.from, e.to, attrs)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (g graph) mermaid(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("flowchart TB\n")
+	var down []string
+	for _, n := range g.nodes {
+		escaped := make([]string, len(n.lines))
+		for i, line := range n.lines {
+			escaped[i] = mermaidEscape(line)
+		}
+		fmt.Fprintf(&b, "  n%d[\"%s\"]\n", n.id, strings.Join(escaped, "<br/>"))
+		if n.down {
+			down = append(down, fmt.Sprintf("n%d", n.id))
+		}
+	}
+	for _, e := range g.edges {
+		arrow := "-->"
+		if e.undirected {
+			arrow = "---"
+		}
+		fmt.Fprintf(&b, "  n%d %s|%s| n%d\n", e.from, arrow, mermaidEscape(e.label), e.to)
+	}
+	if len(down) > 0 {
+		b.WriteString("  classDef down stroke-dasharray: 4 4\n")
+		fmt.Fprintf(&b, "  class %s down\n", strings.Join(down, ","))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var dotReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func dotEscape(s string) string {
+	return dotReplacer.Replace(s)
+}
+
+// mermaidReplacer uses Mermaid's entity codes for the characters that end a
+// label or are read as markup.
+var mermaidReplacer = strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;", "|", "#124;")
+
+func mermaidEscape(s string) string {
+	return mermaidReplacer.Replace(s)
+}
@@ -0,0 +1,87 @@
+package topology
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/user/goeth/internal/snapshot"
+)
+
+var state = snapshot.State{Links: []snapshot.Link{
+	{Name: "br0", Index: 4, Kind: "bridge", MTU: 1500, Up: true, Addresses: []string{"192.0.2.10/24"}},
+	{Name: "eth0", Index: 2, Kind: "device", MTU: 1500, Up: true, Master: "br0"},
+	{Name: "br0.10", Index: 5, Kind: "vlan", MTU: 1500, Up: true, Parent: "br0", VlanID: 10},
+	{Name: "veth-a", Index: 6, Kind: "veth", MTU: 1500, Up: true, Parent: "veth-b"},
+	{Name: "veth-b", Index: 7, Kind: "veth", MTU: 1500, Parent: "veth-a", Master: "br0"},
+}}
+
+func TestRenderDOT(t *testing.T) {
+	var out bytes.Buffer
+	if err := Render(&out, state, DOT); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	want := `digraph topology {
+  rankdir=TB;
+  node [shape=box];
+  n2 [label="eth0\ndevice, mtu 1500"];
+  n4 [label="br0\nbridge, mtu 1500\n192.0.2.10/24"];
+  n5 [label="br0.10\nvlan, mtu 1500"];
+  n6 [label="veth-a\nveth, mtu 1500"];
+  n7 [label="veth-b\nveth, mtu 1500, down", style=dashed];
+  n4 -> n2 [label="port"];
+  n5 -> n4 [label="vlan 10"];
+  n6 -> n7 [label="peer", dir=none];
+  n4 -> n7 [label="port"];
+}
+`
+	if out.String() != want {
+		t.Fatalf("Render() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestRenderMermaid(t *testing.T) {
+	var out bytes.Buffer
+	if err := Render(&out, state, Mermaid); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	want := `flowchart TB
+  n2["eth0<br/>device, mtu 1500"]
+  n4["br0<br/>bridge, mtu 1500<br/>192.0.2.10/24"]
+  n5["br0.10<br/>vlan, mtu 1500"]
+  n6["veth-a<br/>veth, mtu 1500"]
+  n7["veth-b<br/>veth, mtu 1500, down"]
+  n4 -->|port| n2
+  n5 -->|vlan 10| n4
+  n6 ---|peer| n7
+  n4 -->|port| n7
+  classDef down stroke-dasharray: 4 4
+  class n7 down
+`
+	if out.String() != want {
+		t.Fatalf("Render() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestRenderEscapesLabels(t *testing.T) {
+	quoted := snapshot.State{Links: []snapshot.Link{{Name: `we"ird`, Index: 2, Kind: "dummy", Up: true}}}
+	var dot, mermaid bytes.Buffer
+	if err := Render(&dot, quoted, DOT); err != nil {
+		t.Fatal(err)
+	}
+	if err := Render(&mermaid, quoted, Mermaid); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dot.String(), `label="we\"ird\ndummy"`) {
+		t.Fatalf("unescaped DOT label:\n%s", dot.String())
+	}
+	if !strings.Contains(mermaid.String(), `n2["we#quot;ird<br/>dummy"]`) {
+		t.Fatalf("unescaped Mermaid label:\n%s", mermaid.String())
+	}
+}
+
+func TestRenderRejectsUnknownFormat(t *testing.T) {
+	if err := Render(&bytes.Buffer{}, state, "svg"); err == nil {
+		t.Fatal("expected error for an unknown format")
+	}
+}
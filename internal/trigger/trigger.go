@@ -0,0 +1,89 @@
+// Package trigger runs a user command for each event it is handed, with the
+// details of the event in the environment, so that monitor changes can drive
+// automation such as refreshing firewall rules.
+package trigger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/user/goeth/internal/queue"
+)
+
+// waitDelay bounds how long a command that was killed, or that exited while
+// its children still hold its output open, is waited for.
+const waitDelay = time.Second
+
+// shell runs Command, so that it may use pipes and arguments.
+const shell = "/bin/sh"
+
+// Command runs one shell command line per event.
+type Command struct {
+	// Line is run with /bin/sh -c.
+	Line string
+	// Concurrency is how many runs may overlap; events are run in the order
+	// they arrive only when it is one. Values below one mean one.
+	Concurrency int
+	// Timeout kills a run, together with the processes it started, once it
+	// has taken this long. Zero lets runs take as long as they need.
+	Timeout time.Duration
+	// Output receives the output of the runs; nil discards it.
+	Output io.Writer
+
+	*queue.Queue[[]string]
+}
+
+// NewCommand returns a Command that holds up to size events while all of its
+// runs are busy. Each event sent is a run with its variables added to the
+// environment of goeth.
+func NewCommand(line string, size int) *Command {
+	return &Command{Line: line, Queue: queue.New[[]string]("exec", size)}
+}
+
+// Run starts Concurrency workers and runs the queued events until ctx is
+// cancelled, then those still queued. Runs still in progress once the
+// queue.DrainTimeout given to them is over are killed.
+func (c *Command) Run(ctx context.Context) error {
+	workers := max(c.Concurrency, 1)
+	done := make(chan struct{}, workers)
+	for range workers {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			c.Queue.Run(ctx, c.run)
+		}()
+	}
+	for range workers {
+		<-done
+	}
+	return ctx.Err()
+}
+
+// run runs Line once in its own process group, which is killed as a whole
+// on timeout.
+func (c *Command) run(ctx context.Context, env []string) error {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, shell, "-c", c.Line)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout, cmd.Stderr = c.Output, c.Output
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+	cmd.WaitDelay = waitDelay
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("exec: %s: timed out after %s", c.Line, c.Timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("exec: %s: %w", c.Line, err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package trigger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitFor polls until path exists, failing the test after a while.
+func waitFor(t *testing.T, path string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s was not created", path)
+}
+
+// syncBuffer collects the errors of concurrent runs.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  strings.Builder
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+func TestCommandPassesTheEventInTheEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	command := NewCommand(`printf %s "$GOETH_EVENT_INTERFACE" > "$OUT.tmp" && mv "$OUT.tmp" "$OUT"`, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go command.Run(ctx)
+	out := filepath.Join(dir, "out")
+	command.Send([]string{"GOETH_EVENT_INTERFACE=eth0", "OUT=" + out})
+	waitFor(t, out)
+	if got, _ := os.ReadFile(out); string(got) != "eth0" {
+		t.Fatalf("the command saw %q, want eth0", got)
+	}
+}
+
+func TestCommandRunsConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	// Each run waits for the other, so both only finish when they overlap.
+	command := NewCommand(`touch "$DIR/$NAME"; while [ ! -e "$DIR/a" ] || [ ! -e "$DIR/b" ]; do sleep 0.01; done; touch "$DIR/$NAME.done"`, 2)
+	command.Concurrency = 2
+	command.Timeout = 5 * time.Second
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go command.Run(ctx)
+	command.Send([]string{"DIR=" + dir, "NAME=a"})
+	command.Send([]string{"DIR=" + dir, "NAME=b"})
+	waitFor(t, filepath.Join(dir, "a.done"))
+	waitFor(t, filepath.Join(dir, "b.done"))
+}
+
+func TestCommandKillsRunsThatTimeOut(t *testing.T) {
+	dir := t.TempDir()
+	errs := &syncBuffer{}
+	command := NewCommand(`sleep 5 & wait; touch "$DIR/finished"`, 2)
+	command.Timeout = 50 * time.Millisecond
+	command.Errors = errs
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go command.Run(ctx)
+	command.Send([]string{"DIR=" + dir})
+	command.Send([]string{"DIR=" + dir})
+	deadline := time.Now().Add(5 * time.Second)
+	for strings.Count(errs.String(), "timed out after 50ms") < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected two timeouts, got %q", errs.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "finished")); err == nil {
+		t.Fatal("a run that timed out must not carry on")
+	}
+}
+
+func TestCommandDropsEventsWhenFull(t *testing.T) {
+	errs := &syncBuffer{}
+	command := NewCommand("true", 1)
+	command.Errors = errs
+	command.Send(nil)
+	command.Send(nil)
+	if !strings.Contains(errs.String(), "queue full") {
+		t.Fatalf("expected a dropped event to be reported, got %q", errs.String())
+	}
+}
@@ -0,0 +1,151 @@
+// Package webhook posts JSON documents to an HTTP endpoint, signed with a
+// shared secret and retried while the endpoint is unavailable, so that
+// monitor events can reach an incident system without an extra agent.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/user/goeth/internal/backoff"
+	"github.com/user/goeth/internal/queue"
+)
+
+// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the body
+// keyed with Hook.Secret, as GitHub signs its webhooks.
+const SignatureHeader = "X-Goeth-Signature"
+
+// defaultTimeout bounds each delivery attempt when Hook.Client is nil.
+const defaultTimeout = 10 * time.Second
+
+// defaultContentType labels bodies when Hook.ContentType is empty.
+const defaultContentType = "application/json"
+
+// errorDetailLimit is how much of a rejecting response is quoted.
+const errorDetailLimit = 512
+
+// Hook posts to one endpoint.
+type Hook struct {
+	URL string
+	// Secret signs each body in SignatureHeader when set.
+	Secret string
+	// Retries is how often a failed delivery is attempted again; zero gives
+	// up after the first failure. Network errors, 429 and 5xx responses are
+	// retried, other responses are not.
+	Retries int
+	backoff.Policy
+	// Template renders each body instead of encoding it as JSON, to match
+	// the payloads of services such as Slack or PagerDuty. ContentType
+	// labels what it renders, application/json by default.
+	Template    *template.Template
+	ContentType string
+	// Client defaults to one with a timeout of defaultTimeout.
+	Client *http.Client
+}
+
+// ParseTemplate parses a body template. Besides the built-in functions it
+// has json, which encodes a value as JSON, so that {"text": {{json .Message}}}
+// stays valid whatever the message holds.
+func ParseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(template.FuncMap{"json": encodeJSON}).Parse(text)
+}
+
+func encodeJSON(v any) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// Sign returns the value of SignatureHeader for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Post delivers v as JSON, or rendered by Template, retrying as
+// configured. Cancelling ctx stops the waiting between attempts.
+func (h Hook) Post(ctx context.Context, v any) error {
+	body, err := h.encode(v)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	wait := h.Backoff
+	for attempt := 0; ; attempt++ {
+		retryable, err := h.post(ctx, body)
+		if err == nil || !retryable || attempt >= h.Retries {
+			return err
+		}
+		if waitErr := backoff.Wait(ctx, wait); waitErr != nil {
+			return fmt.Errorf("%w (gave up retrying: %w)", err, waitErr)
+		}
+		wait = h.Next(wait)
+	}
+}
+
+func (h Hook) encode(v any) ([]byte, error) {
+	if h.Template == nil {
+		return json.Marshal(v)
+	}
+	var body bytes.Buffer
+	if err := h.Template.Execute(&body, v); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+// post makes one attempt and reports whether a failure is worth retrying.
+func (h Hook) post(ctx context.Context, body []byte) (retryable bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("webhook: %w", err)
+	}
+	contentType := h.ContentType
+	if contentType == "" {
+		contentType = defaultContentType
+	}
+	req.Header.Set("Content-Type", contentType)
+	if h.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(h.Secret, body))
+	}
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, errorDetailLimit))
+	retryable = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5
+	return retryable, fmt.Errorf("webhook: %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+}
+
+// Queue delivers documents through a Hook in the background and in order.
+type Queue struct {
+	*queue.Queue[any]
+	hook Hook
+}
+
+// NewQueue returns a Queue that holds up to size undelivered documents.
+func NewQueue(hook Hook, size int) *Queue {
+	return &Queue{Queue: queue.New[any]("webhook", size), hook: hook}
+}
+
+// Run delivers queued documents until ctx is cancelled.
+func (q *Queue) Run(ctx context.Context) error {
+	return q.Queue.Run(ctx, q.hook.Post)
+}
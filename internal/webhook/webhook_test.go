@@ -0,0 +1,162 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/goeth/internal/backoff"
+)
+
+type event struct {
+	Interface string `json:"interface"`
+}
+
+func TestPostSignsTheBody(t *testing.T) {
+	var body, signature, contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		body, signature, contentType = string(raw), r.Header.Get(SignatureHeader), r.Header.Get("Content-Type")
+	}))
+	defer server.Close()
+	hook := Hook{URL: server.URL, Secret: "example-secret"}
+	if err := hook.Post(context.Background(), event{Interface: "eth0"}); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if body != `{"interface":"eth0"}` || contentType != "application/json" {
+		t.Fatalf("unexpected request: %q (%s)", body, contentType)
+	}
+	if want := Sign("example-secret", []byte(body)); signature != want || !strings.HasPrefix(signature, "sha256=") {
+		t.Fatalf("signature = %q, want %q", signature, want)
+	}
+}
+
+func TestPostRendersTemplates(t *testing.T) {
+	var body, signature, contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		body, signature, contentType = string(raw), r.Header.Get(SignatureHeader), r.Header.Get("Content-Type")
+	}))
+	defer server.Close()
+	tmpl, err := ParseTemplate("slack", `{"text": {{json (printf "%s went \"down\"" .Interface)}}}`)
+	if err != nil {
+		t.Fatalf("ParseTemplate() error = %v", err)
+	}
+	hook := Hook{URL: server.URL, Secret: "example-secret", Template: tmpl}
+	if err := hook.Post(context.Background(), event{Interface: "eth0"}); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if want := `{"text": "eth0 went \"down\""}`; body != want || contentType != "application/json" {
+		t.Fatalf("unexpected request: %q (%s), want %q", body, contentType, want)
+	}
+	if signature != Sign("example-secret", []byte(body)) {
+		t.Fatal("the rendered body is not what was signed")
+	}
+
+	tmpl, _ = ParseTemplate("text", `{{.Interface}} changed`)
+	hook = Hook{URL: server.URL, Template: tmpl, ContentType: "text/plain"}
+	if err := hook.Post(context.Background(), event{Interface: "eth0"}); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if body != "eth0 changed" || contentType != "text/plain" {
+		t.Fatalf("unexpected request: %q (%s)", body, contentType)
+	}
+	tmpl, _ = ParseTemplate("broken", `{{.Missing}}`)
+	if err := (Hook{URL: server.URL, Template: tmpl}).Post(context.Background(), event{}); err == nil {
+		t.Fatal("expected an error rendering an unknown field")
+	}
+}
+
+func TestPostRetriesUnavailableEndpoints(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls < 3 {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	hook := Hook{URL: server.URL, Retries: 2, Policy: backoff.Policy{Backoff: time.Millisecond}}
+	if err := hook.Post(context.Background(), event{}); err != nil || calls != 3 {
+		t.Fatalf("Post() = %v after %d calls, want success after 3", err, calls)
+	}
+	calls = 0
+	hook.Retries = 1
+	if err := hook.Post(context.Background(), event{}); err == nil || !strings.Contains(err.Error(), "try later") {
+		t.Fatalf("expected the endpoint error once retries ran out, got %v", err)
+	}
+}
+
+func TestPostDoesNotRetryRejectedEvents(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	err := Hook{URL: server.URL, Retries: 3, Policy: backoff.Policy{Backoff: time.Millisecond}}.Post(context.Background(), event{})
+	if err == nil || !strings.Contains(err.Error(), "bad signature") || calls != 1 {
+		t.Fatalf("Post() = %v after %d calls, want one rejected call", err, calls)
+	}
+}
+
+func TestQueueDeliversInOrderAndDropsWhenFull(t *testing.T) {
+	received := make(chan string, 3)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		received <- string(raw)
+	}))
+	defer server.Close()
+	var errs strings.Builder
+	queue := NewQueue(Hook{URL: server.URL}, 2)
+	queue.Errors = &errs
+	queue.Send(event{Interface: "eth0"})
+	queue.Send(event{Interface: "eth1"})
+	queue.Send(event{Interface: "eth2"})
+	if !strings.Contains(errs.String(), "queue full") {
+		t.Fatalf("expected a dropped event to be reported, got %q", errs.String())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go queue.Run(ctx)
+	for _, want := range []string{`{"interface":"eth0"}`, `{"interface":"eth1"}`} {
+		if got := <-received; got != want {
+			t.Fatalf("received %s, want %s", got, want)
+		}
+	}
+}
+
+func TestQueueDeliversWhatIsQueuedWhenStopped(t *testing.T) {
+	received := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		received <- string(raw)
+	}))
+	defer server.Close()
+	queue := NewQueue(Hook{URL: server.URL}, 2)
+	queue.Send(event{Interface: "eth0"})
+	queue.Send(event{Interface: "eth1"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := queue.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run() error = %v, want it cancelled", err)
+	}
+	close(received)
+	var got []string
+	for body := range received {
+		got = append(got, body)
+	}
+	if want := []string{`{"interface":"eth0"}`, `{"interface":"eth1"}`}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("received %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,256 @@
+// Package wireguard configures WireGuard devices through the kernel's
+// "wireguard" generic netlink family.
+package wireguard
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// KeyLen is the size of Curve25519 keys used by WireGuard.
+const KeyLen = 32
+
+// Generic netlink constants from include/uapi/linux/wireguard.h.
+const (
+	genlName    = "wireguard"
+	genlVersion = 1
+
+	cmdGetDevice = 0
+	cmdSetDevice = 1
+
+	deviceAttrIfname     = 2
+	deviceAttrPrivateKey = 3
+	deviceAttrListenPort = 6
+	deviceAttrPeers      = 8
+
+	peerAttrPublicKey         = 1
+	peerAttrPresharedKey      = 2
+	peerAttrFlags             = 3
+	peerAttrEndpoint          = 4
+	peerAttrKeepaliveInterval = 5
+	peerAttrAllowedIPs        = 9
+
+	allowedIPAttrFamily   = 1
+	allowedIPAttrIPAddr   = 2
+	allowedIPAttrCIDRMask = 3
+
+	peerFlagRemoveMe          = 1 << 0
+	peerFlagReplaceAllowedIPs = 1 << 1
+)
+
+// Key is a WireGuard public, private or preshared key.
+type Key [KeyLen]byte
+
+// ParseKey decodes a base64 key as produced by "wg genkey".
+func ParseKey(s string) (Key, error) {
+	var key Key
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return key, fmt.Errorf("decode key: %w", err)
+	}
+	if len(raw) != KeyLen {
+		return key, fmt.Errorf("key must be %d bytes, got %d", KeyLen, len(raw))
+	}
+	copy(key[:], raw)
+	return key, nil
+}
+
+// String returns the base64 form of the key.
+func (k Key) String() string {
+	return base64.StdEncoding.EncodeToString(k[:])
+}
+
+// Peer describes a remote WireGuard peer.
+type Peer struct {
+	PublicKey    Key
+	PresharedKey *Key
+	Endpoint     *net.UDPAddr
+	// PersistentKeepalive is sent in whole seconds; zero disables it.
+	PersistentKeepalive time.Duration
+	AllowedIPs          []net.IPNet
+}
+
+// Device is the desired configuration of a WireGuard device.
+type Device struct {
+	PrivateKey Key
+	// ListenPort is left unchanged when zero.
+	ListenPort int
+	// Peers replaces the device's peers. A nil slice leaves them untouched.
+	Peers []Peer
+}
+
+// Client talks to the kernel WireGuard implementation.
+type Client struct{}
+
+// Configure makes the device called name match dev. Peers that are already
+// configured are updated in place so established sessions survive; peers
+// that are not listed are removed.
+func (Client) Configure(name string, dev Device) error {
+	family, err := netlink.GenlFamilyGet(genlName)
+	if err != nil {
+		return fmt.Errorf("lookup %s generic netlink family: %w", genlName, err)
+	}
+	var stale []Key
+	if dev.Peers != nil {
+		current, err := peerKeys(family.ID, name)
+		if err != nil {
+			return err
+		}
+		stale = stalePeers(current, dev.Peers)
+	}
+	req := nl.NewNetlinkRequest(int(family.ID), unix.NLM_F_ACK)
+	req.AddData(&nl.Genlmsg{Command: cmdSetDevice, Version: genlVersion})
+	for _, attr := range encodeDevice(name, dev, stale) {
+		req.AddData(attr)
+	}
+	if _, err := req.Execute(unix.NETLINK_GENERIC, 0); err != nil {
+		return fmt.Errorf("configure wireguard device %s: %w", name, err)
+	}
+	return nil
+}
+
+func peerKeys(familyID uint16, name string) ([]Key, error) {
+	req := nl.NewNetlinkRequest(int(familyID), unix.NLM_F_DUMP)
+	req.AddData(&nl.Genlmsg{Command: cmdGetDevice, Version: genlVersion})
+	req.AddData(nl.NewRtAttr(deviceAttrIfname, nl.ZeroTerminated(name)))
+	msgs, err := req.Execute(unix.NETLINK_GENERIC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("read wireguard device %s: %w", name, err)
+	}
+	var keys []Key
+	for _, msg := range msgs {
+		if len(msg) < nl.SizeofGenlmsg {
+			return nil, errors.New("short wireguard netlink message")
+		}
+		parsed, err := parsePeerKeys(msg[nl.SizeofGenlmsg:])
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, parsed...)
+	}
+	return keys, nil
+}
+
+// parsePeerKeys extracts the public keys of the peers listed in a
+// WG_CMD_GET_DEVICE response payload.
+func parsePeerKeys(payload []byte) ([]Key, error) {
+	attrs, err := nl.ParseRouteAttr(payload)
+	if err != nil {
+		return nil, fmt.Errorf("parse wireguard device: %w", err)
+	}
+	var keys []Key
+	for _, attr := range attrs {
+		if attr.Attr.Type&^unix.NLA_F_NESTED != deviceAttrPeers {
+			continue
+		}
+		peers, err := nl.ParseRouteAttr(attr.Value)
+		if err != nil {
+			return nil, fmt.Errorf("parse wireguard peers: %w", err)
+		}
+		for _, peer := range peers {
+			fields, err := nl.ParseRouteAttr(peer.Value)
+			if err != nil {
+				return nil, fmt.Errorf("parse wireguard peer: %w", err)
+			}
+			for _, field := range fields {
+				if field.Attr.Type&^unix.NLA_F_NESTED == peerAttrPublicKey && len(field.Value) == KeyLen {
+					var key Key
+					copy(key[:], field.Value)
+					keys = append(keys, key)
+				}
+			}
+		}
+	}
+	return keys, nil
+}
+
+func stalePeers(current []Key, desired []Peer) []Key {
+	wanted := make(map[Key]struct{}, len(desired))
+	for _, peer := range desired {
+		wanted[peer.PublicKey] = struct{}{}
+	}
+	var stale []Key
+	for _, key := range current {
+		if _, ok := wanted[key]; !ok {
+			stale = append(stale, key)
+		}
+	}
+	return stale
+}
+
+// encodeDevice builds the WG_CMD_SET_DEVICE attributes for dev, removing the
+// peers listed in stale.
+func encodeDevice(name string, dev Device, stale []Key) []*nl.RtAttr {
+	attrs := []*nl.RtAttr{
+		nl.NewRtAttr(deviceAttrIfname, nl.ZeroTerminated(name)),
+		nl.NewRtAttr(deviceAttrPrivateKey, dev.PrivateKey[:]),
+	}
+	if dev.ListenPort > 0 {
+		attrs = append(attrs, nl.NewRtAttr(deviceAttrListenPort, nl.Uint16Attr(uint16(dev.ListenPort))))
+	}
+	if dev.Peers == nil {
+		return attrs
+	}
+	peers := nl.NewRtAttr(deviceAttrPeers|unix.NLA_F_NESTED, nil)
+	index := 0
+	for _, key := range stale {
+		peer := peers.AddRtAttr(index|unix.NLA_F_NESTED, nil)
+		peer.AddRtAttr(peerAttrPublicKey, append([]byte(nil), key[:]...))
+		peer.AddRtAttr(peerAttrFlags, nl.Uint32Attr(peerFlagRemoveMe))
+		index++
+	}
+	for _, want := range dev.Peers {
+		peer := peers.AddRtAttr(index|unix.NLA_F_NESTED, nil)
+		encodePeer(peer, want)
+		index++
+	}
+	return append(attrs, peers)
+}
+
+func encodePeer(peer *nl.RtAttr, want Peer) {
+	peer.AddRtAttr(peerAttrPublicKey, append([]byte(nil), want.PublicKey[:]...))
+	peer.AddRtAttr(peerAttrFlags, nl.Uint32Attr(peerFlagReplaceAllowedIPs))
+	if want.PresharedKey != nil {
+		peer.AddRtAttr(peerAttrPresharedKey, append([]byte(nil), want.PresharedKey[:]...))
+	}
+	if want.Endpoint != nil {
+		peer.AddRtAttr(peerAttrEndpoint, encodeSockaddr(want.Endpoint))
+	}
+	peer.AddRtAttr(peerAttrKeepaliveInterval, nl.Uint16Attr(uint16(want.PersistentKeepalive/time.Second)))
+	allowed := peer.AddRtAttr(peerAttrAllowedIPs|unix.NLA_F_NESTED, nil)
+	for i, ipnet := range want.AllowedIPs {
+		entry := allowed.AddRtAttr(i|unix.NLA_F_NESTED, nil)
+		family, ip := unix.AF_INET6, ipnet.IP.To16()
+		if v4 := ipnet.IP.To4(); v4 != nil {
+			family, ip = unix.AF_INET, v4
+		}
+		ones, _ := ipnet.Mask.Size()
+		entry.AddRtAttr(allowedIPAttrFamily, nl.Uint16Attr(uint16(family)))
+		entry.AddRtAttr(allowedIPAttrIPAddr, append([]byte(nil), ip...))
+		entry.AddRtAttr(allowedIPAttrCIDRMask, nl.Uint8Attr(uint8(ones)))
+	}
+}
+
+// encodeSockaddr renders addr as a struct sockaddr_in or sockaddr_in6.
+func encodeSockaddr(addr *net.UDPAddr) []byte {
+	if v4 := addr.IP.To4(); v4 != nil {
+		buf := make([]byte, unix.SizeofSockaddrInet4)
+		nl.NativeEndian().PutUint16(buf[0:2], unix.AF_INET)
+		binary.BigEndian.PutUint16(buf[2:4], uint16(addr.Port))
+		copy(buf[4:8], v4)
+		return buf
+	}
+	buf := make([]byte, unix.SizeofSockaddrInet6)
+	nl.NativeEndian().PutUint16(buf[0:2], unix.AF_INET6)
+	binary.BigEndian.PutUint16(buf[2:4], uint16(addr.Port))
+	copy(buf[8:24], addr.IP.To16())
+	return buf
+}
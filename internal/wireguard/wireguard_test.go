@@ -0,0 +1,95 @@
+package wireguard
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+func testKey(b byte) Key {
+	var key Key
+	for i := range key {
+		key[i] = b
+	}
+	return key
+}
+
+func TestParseKeyRoundTrip(t *testing.T) {
+	want := testKey(7)
+	got, err := ParseKey(want.String())
+	if err != nil {
+		t.Fatalf("ParseKey() error = %v", err)
+	}
+	if got != want {
+		t.Fatalf("ParseKey() = %s, want %s", got, want)
+	}
+}
+
+func TestParseKeyRejectsWrongLength(t *testing.T) {
+	if _, err := ParseKey("c2hvcnQ="); err == nil || !strings.Contains(err.Error(), "32 bytes") {
+		t.Fatalf("expected length error, got %v", err)
+	}
+	if _, err := ParseKey("not base64!"); err == nil {
+		t.Fatal("expected decode error")
+	}
+}
+
+func TestStalePeers(t *testing.T) {
+	current := []Key{testKey(1), testKey(2)}
+	stale := stalePeers(current, []Peer{{PublicKey: testKey(2)}, {PublicKey: testKey(3)}})
+	if len(stale) != 1 || stale[0] != testKey(1) {
+		t.Fatalf("stalePeers() = %v", stale)
+	}
+}
+
+func TestEncodeDeviceListsRemovedAndDesiredPeers(t *testing.T) {
+	_, allowed, _ := net.ParseCIDR("10.0.0.0/24")
+	dev := Device{
+		PrivateKey: testKey(9),
+		ListenPort: 51820,
+		Peers:      []Peer{{PublicKey: testKey(2), AllowedIPs: []net.IPNet{*allowed}}},
+	}
+	var payload []byte
+	for _, attr := range encodeDevice("wg0", dev, []Key{testKey(1)}) {
+		payload = append(payload, attr.Serialize()...)
+	}
+	keys, err := parsePeerKeys(payload)
+	if err != nil {
+		t.Fatalf("parsePeerKeys() error = %v", err)
+	}
+	if len(keys) != 2 || keys[0] != testKey(1) || keys[1] != testKey(2) {
+		t.Fatalf("unexpected encoded peers: %v", keys)
+	}
+	attrs, err := nl.ParseRouteAttrAsMap(payload)
+	if err != nil {
+		t.Fatalf("ParseRouteAttrAsMap() error = %v", err)
+	}
+	if port := attrs[deviceAttrListenPort]; nl.NativeEndian().Uint16(port.Value) != 51820 {
+		t.Fatalf("unexpected listen port attribute: %v", port.Value)
+	}
+}
+
+func TestEncodeDeviceWithoutPeersLeavesThemAlone(t *testing.T) {
+	for _, attr := range encodeDevice("wg0", Device{PrivateKey: testKey(9)}, nil) {
+		if attr.Type&^unix.NLA_F_NESTED == deviceAttrPeers {
+			t.Fatal("peers attribute must be omitted when peers are not declared")
+		}
+		if attr.Type == deviceAttrListenPort {
+			t.Fatal("listen port must be omitted when zero")
+		}
+	}
+}
+
+func TestEncodeSockaddr(t *testing.T) {
+	v4 := encodeSockaddr(&net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 51820})
+	if len(v4) != unix.SizeofSockaddrInet4 || v4[2] != 0xca || v4[3] != 0x6c || v4[4] != 192 {
+		t.Fatalf("unexpected sockaddr_in: %v", v4)
+	}
+	v6 := encodeSockaddr(&net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 51820})
+	if len(v6) != unix.SizeofSockaddrInet6 || v6[8] != 0x20 || v6[23] != 1 {
+		t.Fatalf("unexpected sockaddr_in6: %v", v6)
+	}
+}
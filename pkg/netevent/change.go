@@ -0,0 +1,135 @@
+package netevent
+
+import (
+	"sort"
+	"time"
+
+	"github.com/user/goeth/internal/interfaces"
+	"github.com/user/goeth/internal/neighbors"
+	"github.com/user/goeth/internal/routes"
+)
+
+// Interface is a network interface as the monitor sees it.
+type Interface = interfaces.Interface
+
+// Snapshot is what the monitor saw of the interfaces it watches at one time,
+// each map keyed by interface name. Changes are reported by comparing one
+// Snapshot to the next.
+type Snapshot struct {
+	Taken      time.Time
+	Interfaces map[string]Interface
+	// Addresses holds the addresses of each interface in CIDR notation,
+	// sorted.
+	Addresses map[string][]string
+	Routes    map[string][]routes.Route
+	Neighbors map[string][]neighbors.Neighbor
+}
+
+// InterfaceChange is an interface present in two snapshots whose link state
+// changed between them.
+type InterfaceChange struct {
+	Name   string
+	Before Interface
+	After  Interface
+}
+
+// AddressChange lists the addresses an interface gained and lost between
+// two snapshots, each sorted.
+type AddressChange struct {
+	Name    string
+	Added   []string
+	Removed []string
+}
+
+// DiffInterfaces compares two sets of interfaces keyed by name and returns
+// those that appeared, went away or changed, each sorted by name. Only the
+// link state is compared: address, MTU, flags, operational state, carrier,
+// speed and duplex.
+func DiffInterfaces(prev, curr map[string]Interface) (added, removed []Interface, updated []InterfaceChange) {
+	for name, iface := range curr {
+		p, ok := prev[name]
+		if !ok {
+			added = append(added, iface)
+			continue
+		}
+		if !sameInterface(p, iface) {
+			updated = append(updated, InterfaceChange{Name: name, Before: p, After: iface})
+		}
+	}
+	for name, iface := range prev {
+		if _, ok := curr[name]; !ok {
+			removed = append(removed, iface)
+		}
+	}
+	sort.Slice(added, func(i, j int) bool { return added[i].Name < added[j].Name })
+	sort.Slice(removed, func(i, j int) bool { return removed[i].Name < removed[j].Name })
+	sort.Slice(updated, func(i, j int) bool { return updated[i].Name < updated[j].Name })
+	return added, removed, updated
+}
+
+func sameInterface(a, b Interface) bool {
+	if a.Name != b.Name || a.HardwareAddr != b.HardwareAddr || a.MTU != b.MTU || a.OperState != b.OperState || a.Carrier != b.Carrier ||
+		a.Speed != b.Speed || a.Duplex != b.Duplex {
+		return false
+	}
+	if len(a.Flags) != len(b.Flags) {
+		return false
+	}
+	for i := range a.Flags {
+		if a.Flags[i] != b.Flags[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// DiffAddresses compares the addresses of two snapshots and returns a change
+// for each interface whose addresses differ, sorted by name.
+func DiffAddresses(prev, curr map[string][]string) []AddressChange {
+	seen := make(map[string]struct{})
+	for name := range prev {
+		seen[name] = struct{}{}
+	}
+	for name := range curr {
+		seen[name] = struct{}{}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var changes []AddressChange
+	for _, name := range names {
+		added, removed := diffStringSets(prev[name], curr[name])
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+		changes = append(changes, AddressChange{Name: name, Added: added, Removed: removed})
+	}
+	return changes
+}
+
+func diffStringSets(old, new []string) (added, removed []string) {
+	oldSet := make(map[string]struct{}, len(old))
+	for _, val := range old {
+		oldSet[val] = struct{}{}
+	}
+	newSet := make(map[string]struct{}, len(new))
+	for _, val := range new {
+		newSet[val] = struct{}{}
+	}
+	for val := range newSet {
+		if _, ok := oldSet[val]; !ok {
+			added = append(added, val)
+		}
+	}
+	for val := range oldSet {
+		if _, ok := newSet[val]; !ok {
+			removed = append(removed, val)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
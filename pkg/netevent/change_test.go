@@ -0,0 +1,105 @@
+package netevent
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffInterfacesDetectsChanges(t *testing.T) {
+	prev := map[string]Interface{
+		"eth0": {Name: "eth0", HardwareAddr: "aa:bb", MTU: 1500},
+		"eth1": {Name: "eth1", HardwareAddr: "cc:dd", MTU: 1500, Flags: []string{"up"}},
+	}
+	curr := map[string]Interface{
+		"eth0": {Name: "eth0", HardwareAddr: "aa:bb", MTU: 1400},
+		"eth2": {Name: "eth2", HardwareAddr: "ee:ff", MTU: 1500},
+	}
+
+	added, removed, updated := DiffInterfaces(prev, curr)
+	if len(added) != 1 || added[0].Name != "eth2" {
+		t.Fatalf("expected eth2 to be added, got %#v", added)
+	}
+	if len(removed) != 1 || removed[0].Name != "eth1" {
+		t.Fatalf("expected eth1 to be removed, got %#v", removed)
+	}
+	if len(updated) != 1 || updated[0].Name != "eth0" {
+		t.Fatalf("expected eth0 update, got %#v", updated)
+	}
+}
+
+func TestDiffAddressesDetectsChanges(t *testing.T) {
+	prev := map[string][]string{
+		"eth0": {"192.0.2.1/24", "2001:db8::1/64"},
+		"eth1": {"192.0.2.2/24"},
+	}
+	curr := map[string][]string{
+		"eth0": {"192.0.2.1/24", "198.51.100.1/24"},
+		"eth2": {"203.0.113.5/24"},
+	}
+
+	changes := DiffAddresses(prev, curr)
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %d", len(changes))
+	}
+	first := changes[0]
+	if first.Name != "eth0" || len(first.Added) != 1 || len(first.Removed) != 1 {
+		t.Fatalf("unexpected change for eth0: %#v", first)
+	}
+}
+
+func TestDiffInterfacesComparesLinkState(t *testing.T) {
+	prev := map[string]Interface{"eth0": {Name: "eth0", Index: 2, OperState: "up", Carrier: true, Speed: 1000}}
+	tests := []struct {
+		name    string
+		curr    Interface
+		changed bool
+	}{
+		{name: "same", curr: Interface{Name: "eth0", Index: 2, OperState: "up", Carrier: true, Speed: 1000}},
+		{name: "index only", curr: Interface{Name: "eth0", Index: 7, OperState: "up", Carrier: true, Speed: 1000}},
+		{name: "carrier", curr: Interface{Name: "eth0", Index: 2, OperState: "up", Speed: 1000}, changed: true},
+		{name: "speed", curr: Interface{Name: "eth0", Index: 2, OperState: "up", Carrier: true, Speed: 100}, changed: true},
+		{name: "flags", curr: Interface{Name: "eth0", Index: 2, OperState: "up", Carrier: true, Speed: 1000, Flags: []string{"up"}}, changed: true},
+	}
+	for _, tt := range tests {
+		_, _, updated := DiffInterfaces(prev, map[string]Interface{"eth0": tt.curr})
+		if got := len(updated) == 1; got != tt.changed {
+			t.Errorf("%s: changed = %v, want %v", tt.name, got, tt.changed)
+		}
+	}
+}
+
+func TestDiffAddressesSortsEachChange(t *testing.T) {
+	prev := map[string][]string{"eth0": {"198.51.100.1/24", "192.0.2.1/24"}}
+	curr := map[string][]string{"eth0": {"2001:db8::2/64", "203.0.113.1/24"}}
+	want := []AddressChange{{Name: "eth0", Added: []string{"2001:db8::2/64", "203.0.113.1/24"}, Removed: []string{"192.0.2.1/24", "198.51.100.1/24"}}}
+	if got := DiffAddresses(prev, curr); !reflect.DeepEqual(got, want) {
+		t.Fatalf("DiffAddresses() = %#v, want %#v", got, want)
+	}
+	if got := DiffAddresses(curr, curr); got != nil {
+		t.Fatalf("DiffAddresses() of equal sets = %#v, want none", got)
+	}
+}
+
+func TestEventEncodesAsTheSinksDocument(t *testing.T) {
+	event := Event{
+		Time:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Kind:      Link,
+		Type:      "link_down",
+		Interface: "eth0",
+		Message:   "eth0 link down",
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := `{"time":"2024-01-01T00:00:00Z","kind":"link","type":"link_down","interface":"eth0","message":"eth0 link down"}`
+	if string(data) != want {
+		t.Fatalf("Marshal() = %s, want %s", data, want)
+	}
+	var decoded Event
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded != event {
+		t.Fatalf("Unmarshal() = %+v, %v; want %+v", decoded, err, event)
+	}
+}
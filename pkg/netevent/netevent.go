@@ -0,0 +1,43 @@
+// Package netevent is the model of the changes goeth reports while it
+// monitors the network: what every sink receives, and what Go programs
+// receive from the channel of watch.Events.
+package netevent
+
+import "time"
+
+// Kinds of Event.
+const (
+	Link     = "link"
+	Address  = "address"
+	Route    = "route"
+	Neighbor = "neighbor"
+	Traffic  = "traffic"
+	// Heartbeat is the kind of the events sent every so often, changes or
+	// not, to tell that monitoring goes on.
+	Heartbeat = "heartbeat"
+	// Error is the kind of the last event of a channel of events when
+	// monitoring stopped on an error, which its Message gives.
+	Error = "error"
+)
+
+// Kinds are the kinds of the changes reported, in the order they are
+// reported; Heartbeat and Error are not among them.
+var Kinds = []string{Link, Address, Route, Neighbor, Traffic}
+
+// Event is a change that was reported.
+type Event struct {
+	Time time.Time `json:"time"`
+	// Kind says what changed: one of Link, Address, Route, Neighbor or
+	// Traffic, the last for errors, drops and counter resets but not for
+	// the rates of each interval.
+	Kind string `json:"kind"`
+	// Type says how it changed, such as link_down or address_added.
+	Type      string `json:"type"`
+	Interface string `json:"interface"`
+	// Old and New describe what changed before and after, where that
+	// applies: the state of a link, its addresses, a route or a neighbor.
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+	// Message is the change as printed, in the language of the output.
+	Message string `json:"message"`
+}
@@ -0,0 +1,113 @@
+// Package watch lets Go programs follow the changes to the network
+// interfaces of the host as goeth monitor reports them, as values instead of
+// lines of text to parse.
+package watch
+
+import (
+	"context"
+	"time"
+
+	"github.com/user/goeth/internal/addresses"
+	"github.com/user/goeth/internal/cache"
+	"github.com/user/goeth/internal/interfaces"
+	"github.com/user/goeth/internal/monitor"
+	"github.com/user/goeth/internal/neighbors"
+	"github.com/user/goeth/internal/routes"
+	"github.com/user/goeth/pkg/netevent"
+)
+
+// Options says what to watch. The zero value follows every change to links,
+// addresses and routes through netlink notifications.
+type Options struct {
+	// Interfaces restricts watching to the interfaces named, each name
+	// possibly a shell pattern such as eth*. When empty all interfaces are
+	// watched.
+	Interfaces []string
+	// Kinds restricts the changes to these kinds, such as netevent.Link.
+	// When empty all kinds are delivered.
+	Kinds []string
+	// Neighbors adds the changes to the ARP and NDP entries.
+	Neighbors bool
+	// Interval, when positive, rescans the host this often instead of
+	// following notifications.
+	Interval time.Duration
+}
+
+// Events watches the network namespace of the process until ctx is done and
+// delivers each change on the returned channel, which is closed once
+// watching stops. Each change waits for the previous one to be received.
+// When watching stops on an error, the last event has the kind
+// netevent.Error and the error as its Message.
+func Events(ctx context.Context, options Options) <-chan netevent.Event {
+	watcher := monitor.Watcher{
+		Lister:     interfaces.NewLister(interfaces.NetlinkProvider{}),
+		Viewer:     addresses.NewViewer(addresses.NetProvider{}),
+		Interval:   options.Interval,
+		Interfaces: options.Interfaces,
+		Kinds:      options.Kinds,
+	}
+	routeViewer := routes.NewViewer(routes.NetlinkProvider{})
+	watcher.Routes = &routeViewer
+	if options.Neighbors {
+		neighborViewer := neighbors.NewViewer(neighbors.NetlinkProvider{})
+		watcher.Neighbors = &neighborViewer
+	}
+	if options.Interval > 0 {
+		return watcher.Events(ctx)
+	}
+
+	out := make(chan netevent.Event)
+	go func() {
+		defer close(out)
+		// watching ends with ctx or when the cache fails.
+		watching, cancel := context.WithCancel(ctx)
+		defer cancel()
+		shared := cache.New(cache.NetlinkSource{})
+		cacheErr := make(chan error, 1)
+		go func() {
+			cacheErr <- shared.Run(watching)
+			cancel()
+		}()
+		if err := shared.Wait(watching); err != nil {
+			if cause := <-cacheErr; cause != nil {
+				err = cause
+			}
+			fail(ctx, out, err)
+			return
+		}
+		watcher.Lister = interfaces.NewLister(shared)
+		watcher.Viewer = addresses.NewViewer(shared)
+		cachedRoutes := routes.NewViewer(shared)
+		watcher.Routes = &cachedRoutes
+		if watcher.Neighbors != nil {
+			cachedNeighbors := neighbors.NewViewer(shared)
+			watcher.Neighbors = &cachedNeighbors
+		}
+		watcher.Changes = shared.Changes()
+		for event := range watcher.Events(watching) {
+			select {
+			case out <- event:
+			case <-ctx.Done():
+			}
+		}
+		select {
+		case err := <-cacheErr:
+			if err != nil {
+				fail(ctx, out, err)
+			}
+		default:
+		}
+	}()
+	return out
+}
+
+// fail delivers err as the last event on out, unless watching was stopped.
+func fail(ctx context.Context, out chan<- netevent.Event, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	select {
+	case out <- netevent.Event{Time: time.Now(), Kind: netevent.Error, Message: err.Error()}:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,21 @@
+package watch
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/goeth/pkg/netevent"
+)
+
+func TestEventsReportsInvalidOptions(t *testing.T) {
+	events := Events(context.Background(), Options{Interfaces: []string{"eth["}, Interval: time.Second})
+	event, ok := <-events
+	if !ok || event.Kind != netevent.Error || !strings.Contains(event.Message, `invalid interface pattern "eth["`) {
+		t.Fatalf("expected the invalid pattern, got %+v", event)
+	}
+	if _, ok := <-events; ok {
+		t.Fatal("the channel was not closed once watching stopped")
+	}
+}